@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"agent-metadata-action/internal/config"
+	"agent-metadata-action/internal/logging"
+)
+
+// defaultEmulatedServiceURL is where a local mock server listens by default
+const defaultEmulatedServiceURL = "http://localhost:8080"
+
+// emulateOptions configures the fabricated GitHub environment used by --emulate
+type emulateOptions struct {
+	enabled     bool
+	eventFile   string
+	metadataURL string
+	signingURL  string
+}
+
+// sampleEvent holds the fields read from a sample push or pull_request event payload
+type sampleEvent struct {
+	Before      string `json:"before"`
+	After       string `json:"after"`
+	Ref         string `json:"ref"`
+	PullRequest *struct {
+		Base struct {
+			SHA string `json:"sha"`
+			Ref string `json:"ref"`
+		} `json:"base"`
+		Head struct {
+			SHA string `json:"sha"`
+			Ref string `json:"ref"`
+		} `json:"head"`
+	} `json:"pull_request"`
+}
+
+// gitRevParseFunc resolves a git revision in the workspace
+// This allows tests to override the implementation
+var gitRevParseFunc = func(workspace, rev string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--verify", rev+"^{commit}")
+	cmd.Dir = workspace
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git rev-parse %s failed: %s", rev, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// emulateGitHub fabricates the environment the GitHub Actions runner would provide so the full flow can run locally
+// Variables that are already set are left alone; service calls are sent to the given (mock) URLs
+// The fabricated push event payload is written to eventDir
+func emulateGitHub(ctx context.Context, opts emulateOptions, eventDir string) error {
+	if err := config.EnableLocalEmulation(); err != nil {
+		return err
+	}
+
+	workspace := config.GetWorkspace()
+	if workspace == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to determine workspace: %w", err)
+		}
+		workspace = cwd
+	}
+
+	before, after, ref, err := emulatedCommitRange(workspace, opts.eventFile)
+	if err != nil {
+		return err
+	}
+
+	// The docs flow reads the changed range from a push payload, so pull_request samples are converted
+	eventPath := filepath.Join(eventDir, "event.json")
+	payload, err := json.Marshal(map[string]string{"before": before, "after": after, "ref": ref})
+	if err != nil {
+		return fmt.Errorf("failed to build event payload: %w", err)
+	}
+	if err := os.WriteFile(eventPath, payload, 0600); err != nil {
+		return fmt.Errorf("failed to write event payload: %w", err)
+	}
+
+	env := []struct{ name, value string }{
+		{"GITHUB_WORKSPACE", workspace},
+		{"GITHUB_REPOSITORY", "local/" + filepath.Base(workspace)},
+		{"GITHUB_EVENT_NAME", "push"},
+		{"GITHUB_SHA", after},
+		{"NEWRELIC_TOKEN", "local-token"},
+	}
+	for _, v := range env {
+		if os.Getenv(v.name) == "" {
+			if err := os.Setenv(v.name, v.value); err != nil {
+				return fmt.Errorf("failed to set %s: %w", v.name, err)
+			}
+		}
+	}
+
+	// Always replaced so a local run never reaches real services or publishes a check run
+	overrides := []struct{ name, value string }{
+		{"GITHUB_EVENT_PATH", eventPath},
+		{"METADATA_SERVICE_URL", opts.metadataURL},
+		{"SIGNING_SERVICE_URL", opts.signingURL},
+		{"INPUT_GITHUB_TOKEN", ""},
+	}
+	for _, v := range overrides {
+		if err := os.Setenv(v.name, v.value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", v.name, err)
+		}
+	}
+
+	logging.Noticef(ctx, "Emulating GitHub Actions in %s for commits %s...%s (metadata service %s, signing service %s)",
+		workspace, shortSHA(before), shortSHA(after), opts.metadataURL, opts.signingURL)
+	return nil
+}
+
+// emulatedCommitRange returns the before and after commits (and ref) for the fabricated push event
+// Taken from the sample event if given, otherwise HEAD~1...HEAD of the workspace
+func emulatedCommitRange(workspace, eventFile string) (before, after, ref string, err error) {
+	if eventFile == "" {
+		if before, err = gitRevParseFunc(workspace, "HEAD~1"); err != nil {
+			return "", "", "", err
+		}
+		if after, err = gitRevParseFunc(workspace, "HEAD"); err != nil {
+			return "", "", "", err
+		}
+		return before, after, "refs/heads/local", nil
+	}
+
+	data, err := os.ReadFile(eventFile)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to read sample event: %w", err)
+	}
+	var event sampleEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return "", "", "", fmt.Errorf("failed to parse sample event %s: %w", eventFile, err)
+	}
+
+	before, after, ref = event.Before, event.After, event.Ref
+	if event.PullRequest != nil {
+		before, after, ref = event.PullRequest.Base.SHA, event.PullRequest.Head.SHA, "refs/heads/"+event.PullRequest.Head.Ref
+	}
+	if before == "" || after == "" {
+		return "", "", "", fmt.Errorf("sample event %s must have before and after, or pull_request base and head, SHAs", eventFile)
+	}
+	return before, after, ref, nil
+}
+
+// shortSHA abbreviates a commit SHA for logging
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/config"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	baseSHA = strings.Repeat("a", 40)
+	headSHA = strings.Repeat("b", 40)
+)
+
+// setupEmulatedEnv clears the variables emulateGitHub sets so they are restored after the test
+func setupEmulatedEnv(t *testing.T, workspace string) {
+	t.Helper()
+	for _, name := range []string{"GITHUB_ACTIONS", "GITHUB_REPOSITORY", "GITHUB_EVENT_NAME", "GITHUB_EVENT_PATH",
+		"GITHUB_SHA", "NEWRELIC_TOKEN", "METADATA_SERVICE_URL", "SIGNING_SERVICE_URL", "INPUT_GITHUB_TOKEN"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("GITHUB_WORKSPACE", workspace)
+}
+
+func readEmulatedEvent(t *testing.T) map[string]string {
+	t.Helper()
+	data, err := os.ReadFile(os.Getenv("GITHUB_EVENT_PATH"))
+	require.NoError(t, err)
+	var event map[string]string
+	require.NoError(t, json.Unmarshal(data, &event))
+	return event
+}
+
+func TestEmulateGitHub_DefaultsToLastCommit(t *testing.T) {
+	workspace := t.TempDir()
+	setupEmulatedEnv(t, workspace)
+	t.Setenv("INPUT_GITHUB_TOKEN", "real-token")
+
+	originalRevParse := gitRevParseFunc
+	gitRevParseFunc = func(dir, rev string) (string, error) {
+		assert.Equal(t, workspace, dir)
+		if rev == "HEAD~1" {
+			return baseSHA, nil
+		}
+		return headSHA, nil
+	}
+	defer func() { gitRevParseFunc = originalRevParse }()
+
+	getStdout, _ := testutil.CaptureOutput(t)
+
+	// method under test
+	err := emulateGitHub(context.Background(), emulateOptions{
+		enabled:     true,
+		metadataURL: "http://localhost:9999",
+		signingURL:  "http://localhost:9998",
+	}, t.TempDir())
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"before": baseSHA, "after": headSHA, "ref": "refs/heads/local"}, readEmulatedEvent(t))
+	assert.Equal(t, "push", config.GetEventName())
+	assert.Equal(t, headSHA, config.GetSHA())
+	assert.Equal(t, "local/"+filepath.Base(workspace), config.GetRepo())
+	assert.Equal(t, "local-token", config.GetToken())
+	assert.Empty(t, config.GetGitHubToken(), "check runs are not published from local runs")
+	assert.Equal(t, "http://localhost:9999", config.GetMetadataURL())
+	assert.Equal(t, "http://localhost:9998", config.GetSigningURL())
+	assert.Contains(t, getStdout(), "Emulating GitHub Actions in "+workspace+" for commits aaaaaaa...bbbbbbb")
+}
+
+func TestEmulateGitHub_SampleEvents(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     string
+		expected    map[string]string
+		expectedErr string
+	}{
+		{
+			name:     "pull request",
+			payload:  fmt.Sprintf(`{"pull_request": {"base": {"sha": %q, "ref": "main"}, "head": {"sha": %q, "ref": "release-notes"}}}`, baseSHA, headSHA),
+			expected: map[string]string{"before": baseSHA, "after": headSHA, "ref": "refs/heads/release-notes"},
+		},
+		{
+			name:     "push",
+			payload:  fmt.Sprintf(`{"before": %q, "after": %q, "ref": "refs/heads/main"}`, baseSHA, headSHA),
+			expected: map[string]string{"before": baseSHA, "after": headSHA, "ref": "refs/heads/main"},
+		},
+		{
+			name:        "missing commits",
+			payload:     `{"ref": "refs/heads/main"}`,
+			expectedErr: "must have before and after",
+		},
+		{
+			name:        "invalid json",
+			payload:     `{`,
+			expectedErr: "failed to parse sample event",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupEmulatedEnv(t, t.TempDir())
+			eventFile := filepath.Join(t.TempDir(), "event.json")
+			require.NoError(t, os.WriteFile(eventFile, []byte(tt.payload), 0644))
+
+			getStdout, _ := testutil.CaptureOutput(t)
+			err := emulateGitHub(context.Background(), emulateOptions{enabled: true, eventFile: eventFile}, t.TempDir())
+			getStdout()
+
+			if tt.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, readEmulatedEvent(t))
+		})
+	}
+}
+
+func TestEmulateGitHub_KeepsExistingEnvironment(t *testing.T) {
+	setupEmulatedEnv(t, t.TempDir())
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/java-agent")
+	t.Setenv("NEWRELIC_TOKEN", "my-token")
+
+	eventFile := filepath.Join(t.TempDir(), "event.json")
+	require.NoError(t, os.WriteFile(eventFile, []byte(fmt.Sprintf(`{"before": %q, "after": %q}`, baseSHA, headSHA)), 0644))
+
+	getStdout, _ := testutil.CaptureOutput(t)
+	err := emulateGitHub(context.Background(), emulateOptions{enabled: true, eventFile: eventFile}, t.TempDir())
+	getStdout()
+
+	require.NoError(t, err)
+	assert.Equal(t, "newrelic/java-agent", config.GetRepo())
+	assert.Equal(t, "my-token", config.GetToken())
+}
+
+func TestEmulateGitHub_RefusedInsideGitHubActions(t *testing.T) {
+	setupEmulatedEnv(t, t.TempDir())
+	t.Setenv("GITHUB_ACTIONS", "true")
+
+	err := emulateGitHub(context.Background(), emulateOptions{enabled: true}, t.TempDir())
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot be used inside GitHub Actions")
+}
+
+func TestParseFlags_Emulate(t *testing.T) {
+	opts, err := parseFlags([]string{"--emulate", "--event", "pr.json", "--metadata-url", "http://localhost:1234"}, &strings.Builder{})
+	require.NoError(t, err)
+
+	assert.Equal(t, emulateOptions{
+		enabled:     true,
+		eventFile:   "pr.json",
+		metadataURL: "http://localhost:1234",
+		signingURL:  defaultEmulatedServiceURL,
+	}, opts)
+}
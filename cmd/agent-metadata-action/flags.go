@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"agent-metadata-action/internal/inputs"
+)
+
+// cliFlags maps command line flags to the inputs they set
+// Flags allow the action to run locally outside GitHub Actions, where inputs are not provided as environment variables
+var cliFlags = []struct {
+	name  string
+	input string
+	usage string
+}{
+	{name: "workspace", input: "GITHUB_WORKSPACE", usage: "repository root to read configuration from (default: GITHUB_WORKSPACE)"},
+	{name: "agent-type", input: "agent-type", usage: "type of agent, e.g. NRJavaAgent (default: INPUT_AGENT_TYPE)"},
+	{name: "version", input: "version", usage: "agent version being released (default: INPUT_VERSION)"},
+	{name: "config-directory", input: "config-directory", usage: "configuration directory relative to the workspace (default: .fleetControl)"},
+	{name: "mode", input: "mode", usage: "run mode: empty or one of " + strings.Join(modes, ", ") + " (default: INPUT_MODE)"},
+	{name: "export-directory", input: "export-directory", usage: "directory relative to the workspace to export metadata JSON to"},
+}
+
+// parseFlags parses command line flags and applies them to inputs
+// Precedence is flags, then environment variables, then the --config file
+// Flags that don't map to inputs are returned as options
+func parseFlags(args []string, output io.Writer) (emulateOptions, error) {
+	fs := flag.NewFlagSet("agent-metadata-action", flag.ContinueOnError)
+	fs.SetOutput(output)
+
+	var opts emulateOptions
+	configFile := fs.String("config", "", "YAML or JSON file of input values keyed by input name, used when neither a flag nor an environment variable is set")
+	dryRun := fs.Bool("dry-run", false, "report what would be submitted without sending metadata or uploading binaries")
+	fs.BoolVar(&opts.enabled, "emulate", false, "fabricate the GitHub Actions environment to run the full flow on a developer machine")
+	fs.StringVar(&opts.eventFile, "event", "", "with --emulate, sample push or pull_request event JSON to take the changed commit range from (default: HEAD~1...HEAD)")
+	fs.StringVar(&opts.metadataURL, "metadata-url", defaultEmulatedServiceURL, "with --emulate, instrumentation metadata service to send to")
+	fs.StringVar(&opts.signingURL, "signing-url", defaultEmulatedServiceURL, "with --emulate, signing service to send to")
+	values := make(map[string]*string, len(cliFlags))
+	for _, f := range cliFlags {
+		values[f.name] = fs.String(f.name, "", f.usage)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	if *configFile != "" {
+		if err := inputs.LoadFile(*configFile); err != nil {
+			return opts, err
+		}
+	}
+
+	// Only apply flags that were given so unset flags don't mask the environment
+	var applyErr error
+	fs.Visit(func(f *flag.Flag) {
+		if applyErr != nil {
+			return
+		}
+		switch f.Name {
+		case "config":
+		case "dry-run":
+			applyErr = inputs.SetFlag("dry-run", strconv.FormatBool(*dryRun))
+		case "workspace":
+			workspace, err := filepath.Abs(*values["workspace"])
+			if err != nil {
+				applyErr = fmt.Errorf("invalid --workspace: %w", err)
+				return
+			}
+			applyErr = inputs.SetFlag("GITHUB_WORKSPACE", workspace)
+		case "emulate", "event", "metadata-url", "signing-url":
+		default:
+			for _, cf := range cliFlags {
+				if cf.name == f.Name {
+					applyErr = inputs.SetFlag(cf.input, *values[cf.name])
+				}
+			}
+		}
+	})
+	return opts, applyErr
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent-metadata-action/internal/config"
+	"agent-metadata-action/internal/inputs"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/oci"
+	"agent-metadata-action/internal/preflight"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain hides the test binary's flags from main(), which parses the action's own command line flags
+// Pre-flight checks are disabled so tests never reach the real New Relic services; TestRunPreflight covers them
+func TestMain(m *testing.M) {
+	flag.Parse()
+	os.Args = os.Args[:1]
+	preflightFunc = func(ctx context.Context, checks []preflight.Check) error { return nil }
+	os.Exit(m.Run())
+}
+
+func TestParseFlags(t *testing.T) {
+	t.Cleanup(inputs.Reset)
+
+	workspace := t.TempDir()
+	configFile := filepath.Join(t.TempDir(), "inputs.yml")
+	require.NoError(t, os.WriteFile(configFile, []byte("agent-type: FromFile\nversion: 0.0.1\ndisplay-name: From File\ntags:\n  env: dev\n"), 0644))
+
+	t.Setenv("INPUT_AGENT_TYPE", "FromEnv")
+	t.Setenv("INPUT_VERSION", "")
+	t.Setenv("INPUT_DISPLAY_NAME", "")
+	t.Setenv("INPUT_DRY_RUN", "")
+	t.Setenv("INPUT_TAGS", "")
+
+	// method under test
+	_, err := parseFlags([]string{"--config", configFile, "--workspace", workspace, "--version", "1.2.3", "--dry-run"}, &bytes.Buffer{})
+	require.NoError(t, err)
+
+	assert.Equal(t, workspace, config.GetWorkspace(), "flag")
+	assert.Equal(t, "1.2.3", config.GetVersion(), "flag over config file")
+	assert.Equal(t, "FromEnv", config.GetAgentType(), "env over config file")
+	assert.Equal(t, "From File", config.GetDisplayName(), "config file")
+	assert.JSONEq(t, `{"env":"dev"}`, config.GetTags(), "config file maps are passed as JSON")
+	assert.True(t, config.GetDryRun())
+}
+
+func TestParseFlags_FlagOverridesEnv(t *testing.T) {
+	t.Cleanup(inputs.Reset)
+	t.Setenv("INPUT_AGENT_TYPE", "FromEnv")
+	t.Setenv("INPUT_DRY_RUN", "true")
+
+	_, err := parseFlags([]string{"--agent-type", "FromFlag", "--dry-run=false"}, &bytes.Buffer{})
+	require.NoError(t, err)
+
+	assert.Equal(t, "FromFlag", config.GetAgentType())
+	assert.False(t, config.GetDryRun())
+}
+
+func TestParseFlags_RelativeWorkspace(t *testing.T) {
+	t.Cleanup(inputs.Reset)
+
+	_, err := parseFlags([]string{"--workspace", "."}, &bytes.Buffer{})
+	require.NoError(t, err)
+
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, cwd, config.GetWorkspace())
+}
+
+func TestParseFlags_Errors(t *testing.T) {
+	unknownInput := filepath.Join(t.TempDir(), "inputs.yml")
+	require.NoError(t, os.WriteFile(unknownInput, []byte("agent-kind: java\n"), 0644))
+
+	tests := []struct {
+		name        string
+		args        []string
+		expectedErr string
+	}{
+		{name: "unknown flag", args: []string{"--agent"}, expectedErr: "flag provided but not defined"},
+		{name: "positional arguments", args: []string{"java"}, expectedErr: "unexpected arguments"},
+		{name: "missing config file", args: []string{"--config", "missing.yml"}, expectedErr: "failed to read config file"},
+		{name: "unknown input in config file", args: []string{"--config", unknownInput}, expectedErr: `unknown input "agent-kind"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(inputs.Reset)
+
+			_, err := parseFlags(tt.args, &bytes.Buffer{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+func TestParseFlags_Help(t *testing.T) {
+	var usage bytes.Buffer
+
+	_, err := parseFlags([]string{"-h"}, &usage)
+
+	assert.True(t, errors.Is(err, flag.ErrHelp))
+	assert.Contains(t, usage.String(), "-workspace")
+	assert.Contains(t, usage.String(), "-config")
+	assert.Contains(t, usage.String(), "run mode: empty or one of reconcile, backfill, promote,")
+	assert.Contains(t, usage.String(), "inventory, rollback (default: INPUT_MODE)")
+}
+
+func TestRunAgentFlow_DryRun(t *testing.T) {
+	projectRoot, err := filepath.Abs("../..")
+	require.NoError(t, err)
+	workspace := filepath.Join(projectRoot, "integration-test", "agent-flow")
+
+	t.Setenv("INPUT_DRY_RUN", "true")
+	t.Setenv("INPUT_OCI_REGISTRY", "docker.io/newrelic/agents")
+	t.Setenv("INPUT_BINARIES", `[{"name":"linux-tar","path":"./dist/agent.tar.gz","os":"linux","arch":"amd64","format":"tar+gzip"}]`)
+
+	originalOCIHandler := ociHandleUploadsFunc
+	ociHandleUploadsFunc = func(ctx context.Context, cfg *models.OCIConfig, workspace, version string) (string, error) {
+		t.Fatal("binaries must not be uploaded in dry-run mode")
+		return "", nil
+	}
+	defer func() { ociHandleUploadsFunc = originalOCIHandler }()
+
+	getStdout, _ := testutil.CaptureOutput(t)
+
+	// method under test
+	err = runAgentFlow(context.Background(), &mockFailingMetadataClient{}, workspace, "java", "1.2.3")
+	require.NoError(t, err)
+
+	outputStr := getStdout()
+	assert.Contains(t, outputStr, "Dry run - skipping upload and signing of 1 binaries")
+	assert.Contains(t, outputStr, "Dry run - not sending metadata for java version 1.2.3")
+}
+
+func TestRunAgentFlow_DryRunPlan(t *testing.T) {
+	projectRoot, err := filepath.Abs("../..")
+	require.NoError(t, err)
+	workspace := filepath.Join(projectRoot, "integration-test", "agent-flow")
+
+	t.Setenv("INPUT_DRY_RUN", "true")
+	t.Setenv("INPUT_OCI_REGISTRY", "docker.io/newrelic/agents")
+	t.Setenv("INPUT_BINARIES", `[{"name":"linux-tar","path":"./dist/agent.tar.gz","os":"linux","arch":"amd64","format":"tar+gzip"}]`)
+	t.Setenv("INPUT_UPLOAD_PLAN_FILE", "plan.json")
+
+	originalOCIHandler, originalPlanHandler := ociHandleUploadsFunc, ociHandlePlanFunc
+	ociHandleUploadsFunc = func(ctx context.Context, cfg *models.OCIConfig, workspace, version string) (string, error) {
+		t.Fatal("binaries must not be uploaded in dry-run mode")
+		return "", nil
+	}
+	var planned *models.OCIConfig
+	ociHandlePlanFunc = func(ctx context.Context, cfg *models.OCIConfig, workspace, version string) (oci.Plan, error) {
+		planned = cfg
+		return oci.Plan{}, nil
+	}
+	defer func() { ociHandleUploadsFunc, ociHandlePlanFunc = originalOCIHandler, originalPlanHandler }()
+
+	getStdout, _ := testutil.CaptureOutput(t)
+
+	// method under test
+	err = runAgentFlow(context.Background(), &mockFailingMetadataClient{}, workspace, "java", "1.2.3")
+	require.NoError(t, err)
+
+	require.NotNil(t, planned)
+	assert.Equal(t, "plan.json", planned.PlanFile)
+	assert.Contains(t, getStdout(), "Dry run - planning the upload of 1 binaries without uploading or signing them")
+}
@@ -2,41 +2,185 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"math"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"agent-metadata-action/internal/agenttype"
+	"agent-metadata-action/internal/backfill"
 	"agent-metadata-action/internal/client"
 	"agent-metadata-action/internal/config"
+	"agent-metadata-action/internal/contract"
+	"agent-metadata-action/internal/decrypt"
+	"agent-metadata-action/internal/export"
+	"agent-metadata-action/internal/fileutil"
+	"agent-metadata-action/internal/fixtures"
+	"agent-metadata-action/internal/github"
+	"agent-metadata-action/internal/heartbeat"
+	"agent-metadata-action/internal/hints"
+	"agent-metadata-action/internal/inputs"
+	"agent-metadata-action/internal/inventory"
+	"agent-metadata-action/internal/journal"
+	"agent-metadata-action/internal/lint"
 	"agent-metadata-action/internal/loader"
 	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/metrics"
 	"agent-metadata-action/internal/models"
 	"agent-metadata-action/internal/oci"
+	"agent-metadata-action/internal/pins"
+	"agent-metadata-action/internal/policy"
+	"agent-metadata-action/internal/preflight"
+	"agent-metadata-action/internal/ratelimit"
+	"agent-metadata-action/internal/reconcile"
+	"agent-metadata-action/internal/rego"
+	"agent-metadata-action/internal/resources"
+	"agent-metadata-action/internal/results"
+	"agent-metadata-action/internal/retry"
+	"agent-metadata-action/internal/sanitize"
+	"agent-metadata-action/internal/sarif"
+	"agent-metadata-action/internal/schemadoc"
 	"agent-metadata-action/internal/sign"
+	"agent-metadata-action/internal/verify"
 
 	"github.com/newrelic/go-agent/v3/newrelic"
+	"gopkg.in/yaml.v3"
 )
 
 // metadataClient interface for testing
 type metadataClient interface {
-	SendMetadata(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata) error
+	SubmitMetadata(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata) (models.SubmissionResponse, error)
+	ListAgentTypes(ctx context.Context) ([]string, error)
+}
+
+// incrementalClient is implemented by metadata clients that can submit only the definitions that changed since a
+// version was last submitted
+type incrementalClient interface {
+	GetMetadata(ctx context.Context, agentType string, agentVersion string) (*models.AgentMetadata, error)
+	SubmitIncremental(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata, removed models.RemovedDefinitions) (models.SubmissionResponse, error)
 }
 
 // createMetadataClientFunc is a variable that holds the function to create a metadata client
 // This allows tests to override the implementation
 var createMetadataClientFunc = func(baseURL, token string) metadataClient {
-	return client.NewInstrumentationClient(baseURL, token)
+	return newInstrumentationClient(baseURL, token)
+}
+
+// createReconcileServiceFunc is a variable that holds the function to create the service used for reconciliation
+// This allows tests to override the implementation
+var createReconcileServiceFunc = func(baseURL, token string) reconcile.MetadataService {
+	return newInstrumentationClient(baseURL, token)
+}
+
+// releaseLocker is implemented by metadata clients that can lock releasing an agent version for one run at a time
+type releaseLocker interface {
+	LockRelease(ctx context.Context, agentType, agentVersion, owner string, ttl time.Duration) (models.ReleaseLock, error)
+	UnlockRelease(ctx context.Context, agentType, agentVersion, owner string) error
+}
+
+// metadataDeleter is implemented by metadata clients that can delete the metadata stored for an agent version
+type metadataDeleter interface {
+	DeleteMetadata(ctx context.Context, agentType string, agentVersion string) (bool, error)
+}
+
+// createMetadataDeleterFunc is a variable that holds the function to create the client used to delete metadata
+// This allows tests to override the implementation
+var createMetadataDeleterFunc = func(baseURL, token string) metadataDeleter {
+	return newInstrumentationClient(baseURL, token)
+}
+
+// createInventoryListerFunc is a variable that holds the function to create the client used to list agent versions
+// This allows tests to override the implementation
+var createInventoryListerFunc = func(baseURL, token string) inventory.Lister {
+	return newInstrumentationClient(baseURL, token)
+}
+
+// newInstrumentationClient creates an instrumentation client using the payload-version, compression-threshold,
+// max-payload-size and empty-field-policy inputs
+// Idempotency keys are scoped to the workflow run
+func newInstrumentationClient(baseURL, token string) *client.InstrumentationClient {
+	c := client.NewInstrumentationClient(baseURL, token)
+	c.SetPayloadVersion(config.GetPayloadVersion())
+	c.SetRunID(config.GetRunID())
+	// Invalid thresholds and limits are rejected by runFlow
+	if threshold, err := config.GetCompressionThreshold(); err == nil {
+		c.SetCompressionThreshold(threshold)
+	}
+	if limit, err := config.GetMaxPayloadSize(); err == nil {
+		c.SetMaxPayloadSize(limit)
+	}
+	if policy, err := models.ParseEmptyFieldPolicy(config.GetEmptyFieldPolicy()); err == nil {
+		c.SetEmptyFieldPolicy(policy)
+	}
+	return c
 }
 
+// preflightFunc is a variable that holds the function that checks the backends are reachable
+// This allows tests to override the implementation
+var preflightFunc = preflight.Run
+
 // ociHandleUploadsFunc is a variable that holds the function to handle OCI uploads
 // This allows tests to override the implementation
 var ociHandleUploadsFunc = func(ctx context.Context, ociConfig *models.OCIConfig, workspace, version string) (string, error) {
 	return oci.HandleUploads(ctx, ociConfig, workspace, version)
 }
 
+// ociHandlePlanFunc is a variable that holds the function to plan OCI uploads without pushing anything
+// This allows tests to override the implementation
+var ociHandlePlanFunc = oci.HandlePlan
+
+// ociHandleCopyFunc is a variable that holds the function to copy a signed manifest index between registries
+// This allows tests to override the implementation
+var ociHandleCopyFunc = oci.HandleCopy
+
+// ociHandleCleanupFunc is a variable that holds the function to delete expired prerelease manifests
+// This allows tests to override the implementation
+var ociHandleCleanupFunc = oci.HandleCleanup
+
+// ociHandleAssembleFunc is a variable that holds the function to push the manifest index listing handed off artifacts
+// This allows tests to override the implementation
+var ociHandleAssembleFunc = oci.HandleAssemble
+
+// ociHandleImportFunc is a variable that holds the function to push the manifest index of a prepared OCI layout
+// This allows tests to override the implementation
+var ociHandleImportFunc = oci.HandleImport
+
+// ociHandleResignFunc is a variable that holds the function to resolve a manifest index to sign again
+// This allows tests to override the implementation
+var ociHandleResignFunc = oci.HandleResign
+
+// ociHandleRollbackFunc is a variable that holds the function to undo journaled registry operations
+// This allows tests to override the implementation
+var ociHandleRollbackFunc = oci.HandleRollback
+
+// ociInspectReleaseFunc is a variable that holds the function to look up a released manifest index
+// This allows tests to override the implementation
+var ociInspectReleaseFunc = oci.InspectRelease
+
+// ociHandlePromotionFunc is a variable that holds the function to promote a pending manifest index
+// This allows tests to override the implementation
+var ociHandlePromotionFunc = oci.HandlePromotion
+
+// evaluateRegoFunc is a variable that holds the function to evaluate Rego policies
+// This allows tests to override the implementation
+var evaluateRegoFunc = rego.Evaluate
+
+// publishCheckRunFunc is a variable that holds the function to publish the validation check run
+// This allows tests to override the implementation
+var publishCheckRunFunc = func(ctx context.Context, repo, sha, conclusion, summary string, annotations []github.Annotation) (string, error) {
+	return github.GetClient().PublishCheckRun(ctx, repo, sha, conclusion, summary, annotations)
+}
+
 // initNewRelic initializes the New Relic application
 // Returns nil if APM_CONTROL_NR_LICENSE_KEY is not set (silent no-op mode)
 func initNewRelic(ctx context.Context) *newrelic.Application {
@@ -86,10 +230,32 @@ func main() {
 	// Create base context for early logging
 	ctx := context.Background()
 
+	emulate, err := parseFlags(os.Args[1:], os.Stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		logging.Noticef(ctx, "%v", err)
+		os.Exit(2)
+	}
+
+	// The fabricated event payload is removed before exiting
+	var eventDir string
+	if emulate.enabled {
+		if eventDir, err = os.MkdirTemp("", "agent-metadata-action-event-"); err == nil {
+			err = emulateGitHub(ctx, emulate, eventDir)
+		}
+		if err != nil {
+			os.RemoveAll(eventDir)
+			logging.Noticef(ctx, "%v", err)
+			os.Exit(2)
+		}
+	}
+
 	nrApp := initNewRelic(ctx)
 
 	// Run the action
-	err := run(nrApp)
+	err = run(nrApp)
 
 	// Ensure New Relic shuts down gracefully (even on error)
 	// Must happen BEFORE os.Exit() since os.Exit bypasses defers
@@ -99,9 +265,13 @@ func main() {
 		logging.Notice(ctx, "New Relic shutdown complete")
 	}
 
-	// Exit with appropriate code
+	if eventDir != "" {
+		os.RemoveAll(eventDir)
+	}
+
+	// Exit with appropriate code, with remediation for known failures
 	if err != nil {
-		logging.Noticef(ctx, "%v", err)
+		logging.Error(ctx, hints.Explain(err.Error()))
 		os.Exit(1)
 	}
 }
@@ -121,233 +291,2815 @@ func run(nrApp *newrelic.Application) error {
 		defer logging.Debug(ctx, "New Relic transaction ended")
 	}
 
+	inputs.LogSummary(ctx)
+
+	// Share one retry budget across every phase, so a failing backend can't stretch the job by the sum of retries
+	// An invalid retry-budget is reported by validateEnvironment
+	if total, err := config.GetRetryBudget(); err == nil && total > 0 {
+		budget := retry.NewBudget(total)
+		ctx = retry.WithBudget(ctx, budget)
+		defer func() { logging.Debugf(ctx, "Retry budget: %s of %s remaining", budget.Remaining(), total) }()
+	}
+
+	// Warn as the encoded schemas and content held in memory approach the payload-memory-limit
+	// An invalid payload-memory-limit is reported by runFlow
+	if limit, err := config.GetPayloadMemoryLimit(); err == nil && limit > 0 {
+		budget := resources.NewPayloadBudget(int64(limit) << 20)
+		ctx = resources.WithPayloadBudget(ctx, budget)
+		defer func() {
+			logging.Debugf(ctx, "Encoded payloads: %s held in memory", resources.FormatBytes(uint64(budget.Used())))
+		}()
+	}
+
 	// Validate required environment and setup
 	workspace, token, err := validateEnvironment(ctx)
 	if err != nil {
 		return err
 	}
 
-	// Create metadataClient
-	metadataClient := createMetadataClientFunc(config.GetMetadataURL(), token)
+	// Notice the current phase while the run goes on, so a slow phase isn't mistaken for a hung job
+	// An invalid heartbeat-interval is reported by runFlow
+	if interval, err := config.GetHeartbeatInterval(); err == nil {
+		var stopHeartbeat func()
+		ctx, stopHeartbeat = heartbeat.Start(ctx, interval)
+		defer stopHeartbeat()
+	}
 
-	// Determine which flow to execute
-	agentType := config.GetAgentType()
-	agentVersion := config.GetVersion()
-	monitoringType := config.GetMonitoringType()
-	if monitoringType != "" && monitoringType != "APM" && monitoringType != "INFRA" {
-		return fmt.Errorf("invalid monitoring-type %q: must be APM or INFRA", monitoringType)
+	ctx, stopMetrics, err := startMetrics(ctx, workspace)
+	if err != nil {
+		return err
 	}
+	defer stopMetrics()
 
-	if agentType != "" && agentVersion != "" {
-		return runAgentFlow(ctx, metadataClient, workspace, agentType, agentVersion)
+	enableRateLimit(ctx)
+
+	if config.GetStrictContract() {
+		if err := enableStrictContract(ctx); err != nil {
+			return err
+		}
 	}
 
-	return runDocsFlow(ctx, metadataClient)
-}
+	// Collect validation findings so they can be published as a check run
+	annotations := github.NewAnnotationCollector()
+	ctx = github.WithAnnotationCollector(ctx, annotations)
 
-// validateEnvironment checks required environment variables and workspace
-func validateEnvironment(ctx context.Context) (workspace string, token string, err error) {
-	workspace = config.GetWorkspace()
-	if workspace == "" {
-		err := fmt.Errorf("GITHUB_WORKSPACE is required but not set")
-		logging.NoticeErrorWithCategory(ctx, err, "environment.validation", map[string]interface{}{
-			"error.operation": "validate_workspace",
-			"error.field":     "GITHUB_WORKSPACE",
-		})
-		return "", "", err
+	// Record phase outcomes so they can be written to the results file
+	recorder := results.NewRecorder()
+	recorder.SetRun(results.Run{
+		AgentType:  config.GetAgentType(),
+		Version:    config.GetVersion(),
+		Mode:       config.GetMode(),
+		DryRun:     config.GetDryRun(),
+		Repository: config.GetRepo(),
+		SHA:        config.GetSHA(),
+		Actor:      config.GetActor(),
+		RunID:      config.GetRunID(),
+	})
+	ctx = results.WithRecorder(ctx, recorder)
+
+	ctx, err = startJournal(ctx, workspace)
+	if err != nil {
+		return err
 	}
 
-	if _, err := os.Stat(workspace); err != nil {
-		noticeErr := fmt.Errorf("workspace directory does not exist: %s", workspace)
-		logging.NoticeErrorWithCategory(ctx, noticeErr, "environment.validation", map[string]interface{}{
-			"error.operation": "validate_workspace",
-			"workspace.path":  workspace,
-		})
-		return "", "", noticeErr
+	err = runFlow(ctx, workspace, token)
+	recorder.Finish(err)
+	reportValidationCheck(ctx, annotations, err)
+	recordReleaseEvent(ctx, nrApp, recorder.Results())
+	setOutputs(ctx, recorder.Results())
+	if writeErr := writeResults(ctx, workspace, recorder); writeErr != nil && err == nil {
+		err = writeErr
 	}
+	if writeErr := writeSARIF(ctx, workspace, annotations.Annotations()); writeErr != nil && err == nil {
+		err = writeErr
+	}
+	return err
+}
 
-	token = config.GetToken()
-	if token == "" {
-		err := fmt.Errorf("NEWRELIC_TOKEN is required but not set")
-		logging.NoticeErrorWithCategory(ctx, err, "environment.validation", map[string]interface{}{
-			"error.operation": "validate_token",
-			"error.field":     "NEWRELIC_TOKEN",
-		})
-		return "", "", err
+// metricsInterval is how often metrics-file is rewritten while the run goes on
+const metricsInterval = 10 * time.Second
+
+// startMetrics counts the work of the run and exposes the counters on metrics-address and in metrics-file, if set,
+// so long batch runs can be monitored from the runner
+// The returned function stops serving and writes the file a final time
+func startMetrics(ctx context.Context, workspace string) (context.Context, func(), error) {
+	address := config.GetMetricsAddress()
+	file := config.GetMetricsFile()
+	if address == "" && file == "" {
+		return ctx, func() {}, nil
 	}
+	registry := metrics.NewRegistry(config.GetMode())
+	ctx = metrics.WithRegistry(ctx, registry)
 
-	logging.Notice(ctx, "Environment validated successfully")
-	return workspace, token, nil
+	var stops []func()
+	if address != "" {
+		stop, err := metrics.Serve(ctx, address, registry)
+		if err != nil {
+			return ctx, nil, err
+		}
+		stops = append(stops, stop)
+	}
+	if file != "" {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(workspace, file)
+		}
+		if err := metrics.WriteFile(file, registry); err != nil {
+			for _, stop := range stops {
+				stop()
+			}
+			return ctx, nil, fmt.Errorf("invalid metrics-file: %w", err)
+		}
+		stops = append(stops, metrics.WriteFilePeriodically(ctx, file, metricsInterval, registry))
+	}
+	return ctx, func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}, nil
 }
 
-func validateConfigDirectory(ctx context.Context, workspace string) error {
-	configDir := config.GetRootFolderForAgentRepo()
-
-	fullPath := filepath.Join(workspace, configDir)
-	resolvedPath, err := filepath.Abs(fullPath)
+// startJournal journals the operations the run completes in journal-file, if set, so a rollback can undo them if
+// the run fails partway
+// Rollback runs read the journal instead, so it's left as the failed run wrote it
+func startJournal(ctx context.Context, workspace string) (context.Context, error) {
+	journalFile := config.GetJournalFile()
+	if journalFile == "" || config.GetMode() == modeRollback {
+		return ctx, nil
+	}
+	journalFile, err := workspaceRelativePath("journal-file", journalFile)
 	if err != nil {
-		return fmt.Errorf("failed to resolve config directory path: %w", err)
+		return ctx, err
 	}
-
-	if _, err := os.Stat(resolvedPath); err != nil {
-		return fmt.Errorf("config directory does not exist: %s", configDir)
+	j, err := journal.Create(filepath.Join(workspace, journalFile))
+	if err != nil {
+		return ctx, err
 	}
+	return journal.WithJournal(ctx, j), nil
+}
 
-	logging.Debugf(ctx, "Using config directory: %s", configDir)
-	return nil
+// recordReleaseEvent records the AgentMetadataRelease custom event as an audit trail of the run
+// Skipped if New Relic is not enabled; the event is sent when the application shuts down
+func recordReleaseEvent(ctx context.Context, nrApp *newrelic.Application, recorded results.Results) {
+	if nrApp == nil {
+		logging.Debugf(ctx, "New Relic not enabled - skipping %s event", results.ReleaseEventType)
+		return
+	}
+	nrApp.RecordCustomEvent(results.ReleaseEventType, recorded.ReleaseEvent())
+	logging.Debugf(ctx, "Recorded %s event (%s)", results.ReleaseEventType, recorded.Outcome)
 }
 
-// runAgentFlow handles the agent repository workflow
-func runAgentFlow(ctx context.Context, client metadataClient, workspace, agentType, agentVersion string) error {
-	logging.Debugf(ctx, "Running agent repository flow for %s version %s", agentType, agentVersion)
+// setOutputs exposes the IDs returned by the instrumentation and signing services as step outputs
+// metadata-ids lists the IDs of every stored submission, comma separated; failures only warn
+func setOutputs(ctx context.Context, recorded results.Results) {
+	var ids []string
+	for _, payload := range recorded.Payloads {
+		if payload.ID != "" {
+			ids = append(ids, payload.ID)
+		}
+	}
+	outputs := map[string]string{"metadata-ids": strings.Join(ids, ",")}
+	if recorded.Index != nil {
+		outputs["signature-id"] = recorded.Index.SignatureID
+		outputs["signed"] = strconv.FormatBool(recorded.Index.Signed)
+	}
+	for name, value := range outputs {
+		if err := github.SetOutput(name, value); err != nil {
+			logging.Warnf(ctx, "Unable to set %s output: %v", name, err)
+		}
+	}
+}
 
-	if err := validateConfigDirectory(ctx, workspace); err != nil {
-		return fmt.Errorf("config directory validation failed: %w", err)
+// workspaceRelativePath returns value of input, a path relative to the repository root, with the platform's
+// separators, rejecting absolute paths and paths that leave the repository
+func workspaceRelativePath(input, value string) (string, error) {
+	if !fileutil.IsLocal(value) {
+		return "", fmt.Errorf("invalid %s %s: must be relative to the repository root without directory traversal", input, value)
 	}
+	return fileutil.NormalizePath(value), nil
+}
 
-	// Load configuration definitions (required)
-	configs, err := loader.ReadConfigurationDefinitions(ctx, workspace)
+// writeResults writes the recorded phase outcomes to the results-file input, if set
+// A failure to write is returned so downstream jobs don't consume a missing or stale file
+func writeResults(ctx context.Context, workspace string, recorder *results.Recorder) error {
+	resultsFile := config.GetResultsFile()
+	if resultsFile == "" {
+		return nil
+	}
+	resultsFile, err := workspaceRelativePath("results-file", resultsFile)
 	if err != nil {
-		logging.NoticeErrorWithCategory(ctx, err, "configuration.load", map[string]interface{}{
-			"error.operation": "load_configuration_definitions",
-			"agent.type":      agentType,
-			"agent.version":   agentVersion,
-			"workflow.type":   "agent",
-		})
-		return fmt.Errorf("failed to read configuration definitions: %w", err)
+		logging.Errorf(ctx, "%v", err)
+		return err
 	}
-	logging.Noticef(ctx, "Loaded %d configuration definitions", len(configs))
 
-	// Load agent control definitions (optional)
-	agentControl, err := loader.ReadAgentControlDefinitions(ctx, workspace)
-	if err != nil {
-		logging.Warnf(ctx, "Unable to load agent control definitions: %v - continuing without them", err)
-		agentControl = nil
-	} else {
-		logging.Noticef(ctx, "Loaded %d agent control definitions", len(agentControl))
+	if err := recorder.Write(filepath.Join(workspace, resultsFile)); err != nil {
+		logging.Errorf(ctx, "Failed to write results file: %v", err)
+		return err
 	}
+	logging.Noticef(ctx, "Wrote run results to %s", resultsFile)
+	return nil
+}
 
-	// Load agent definition (optional)
-	agentDef, err := loader.ReadAgentDefinition(ctx, workspace)
+// writeSARIF writes the collected annotations to the sarif-file input as SARIF, if set, so they can be uploaded
+// to code scanning; it is written on failed runs too, since that's when the findings matter
+func writeSARIF(ctx context.Context, workspace string, annotations []github.Annotation) error {
+	sarifFile := config.GetSARIFFile()
+	if sarifFile == "" {
+		return nil
+	}
+	sarifFile, err := workspaceRelativePath("sarif-file", sarifFile)
 	if err != nil {
-		logging.Warnf(ctx, "Unable to load agent definition: %v - continuing without it", err)
-		agentDef = nil
-	} else if agentDef != nil {
-		logging.Notice(ctx, "Loaded agent definition")
+		logging.Errorf(ctx, "%v", err)
+		return err
 	}
 
-	// Build metadata
-	metadata := models.AgentMetadata{
-		ConfigurationDefinitions: configs,
-		Metadata:                 loader.LoadMetadataForAgents(agentVersion),
-		AgentControlDefinitions:  agentControl,
+	descriptions := map[string]string{}
+	for _, rule := range lint.Rules {
+		descriptions[lintRulePrefix+rule.ID] = rule.Description
 	}
-	if agentDef != nil {
-		metadata.Bindings = agentDef.Bindings
-		metadata.BreakingChange = agentDef.BreakingChange
+	levels := map[github.AnnotationLevel]string{
+		github.AnnotationFailure: sarif.LevelError,
+		github.AnnotationWarning: sarif.LevelWarning,
+		github.AnnotationNotice:  sarif.LevelNote,
 	}
 
-	tags, err := loader.ParseTags(config.GetTags())
-	if err != nil {
-		logging.Warnf(ctx, "Unable to parse tags input: %v - continuing without tags", err)
-	} else if len(tags) > 0 {
-		metadata.Metadata["tags"] = tags
+	var rules []sarif.Rule
+	findings := make([]sarif.Result, 0, len(annotations))
+	for _, annotation := range annotations {
+		ruleID := annotation.RuleID()
+		description, ok := descriptions[ruleID]
+		if !ok {
+			description = annotation.Title
+		}
+		rules = append(rules, sarif.Rule{ID: ruleID, Description: description, Level: levels[annotation.AnnotationLevel]})
+		findings = append(findings, sarif.Result{
+			RuleID:  ruleID,
+			Level:   levels[annotation.AnnotationLevel],
+			Path:    annotation.Path,
+			Line:    annotation.StartLine,
+			Column:  annotation.StartColumn,
+			Message: annotation.Message,
+		})
 	}
 
-	printJSON(ctx, "Agent Metadata", metadata)
+	if err := sarif.Write(filepath.Join(workspace, sarifFile), rules, findings); err != nil {
+		logging.Errorf(ctx, "Failed to write SARIF file: %v", err)
+		return err
+	}
+	logging.Noticef(ctx, "Wrote %d finding(s) to %s", len(findings), sarifFile)
+	return nil
+}
 
-	ociConfig, err := oci.LoadConfig()
+// enableStrictContract validates every request to the metadata and signing services against their OpenAPI documents
+// The service clients use the default transport, so it is wrapped for the rest of the run
+func enableStrictContract(ctx context.Context) error {
+	instrumentation, err := contract.Instrumentation()
 	if err != nil {
-		logging.NoticeErrorWithCategory(ctx, err, "oci.configuration", map[string]interface{}{
-			"error.operation": "load_oci_config",
-			"agent.type":      agentType,
-			"agent.version":   agentVersion,
-		})
-		return fmt.Errorf("error loading OCI config: %w", err)
+		return fmt.Errorf("failed to load instrumentation service contract: %w", err)
+	}
+	signing, err := contract.Signing()
+	if err != nil {
+		return fmt.Errorf("failed to load signing service contract: %w", err)
 	}
 
-	if ociConfig.IsEnabled() {
-		// Step 1: Upload binaries
-		indexDigest, err := ociHandleUploadsFunc(ctx, &ociConfig, workspace, agentVersion)
-		if err != nil {
-			return fmt.Errorf("binary upload failed: %w", err)
-		}
+	http.DefaultTransport = contract.NewTransport(http.DefaultTransport, map[string]*contract.Spec{
+		config.GetMetadataURL(): instrumentation,
+		config.GetSigningURL():  signing,
+	})
+	logging.Notice(ctx, "Strict contract mode enabled - requests are validated against the service OpenAPI documents")
+	return nil
+}
 
-		// Step 2: Sign the manifest index
-		githubRepo := config.GetRepo()
-		if githubRepo == "" {
-			return fmt.Errorf("GITHUB_REPOSITORY environment variable is required for artifact signing")
-		}
+// enableRateLimit makes requests to the instrumentation and signing services share a rate-limit budget of requests
+// per second, so batch modes don't trip the backend rate limits and back off on 429s
+// Installed below the strict contract transport, so requests it rejects aren't counted
+func enableRateLimit(ctx context.Context) {
+	rps, _ := config.GetRateLimit()
+	if rps <= 0 {
+		return
+	}
+	limiter := ratelimit.NewLimiter(float64(rps), rps)
+	http.DefaultTransport = ratelimit.NewTransport(http.DefaultTransport, limiter, config.GetMetadataURL(), config.GetSigningURL())
+	logging.Noticef(ctx, "Rate limit enabled - at most %d requests per second to the instrumentation and signing services", rps)
+}
 
-		// Extract repository name from full path (e.g., "agent-metadata-action" from "newrelic/agent-metadata-action")
-		repoParts := strings.Split(githubRepo, "/")
-		repoName := repoParts[len(repoParts)-1]
+// Values of the mode input besides the empty default: reconcile resyncs all metadata in the repository, backfill
+// submits the agent metadata of past releases, promote tags a pending manifest index with its version, copy
+// copies a signed manifest index from a staging registry, cleanup deletes expired prerelease manifests, resign
+// signs a manifest index that is already in the registry, merge adds late-built platforms to the manifest index
+// already pushed for a version, collect uploads the binaries of one job of a build matrix and hands them off in a
+// file, assemble pushes the manifest index listing the artifacts of every handoff, import pushes the manifest index
+// of a prepared OCI layout, verify checks a published release end to end, diff compares the metadata of the
+// repository with the record the service stores, inventory exports every registered agent version, and rollback
+// undoes the operations a failed run journaled
+const (
+	modeReconcile = "reconcile"
+	modeBackfill  = "backfill"
+	modePromote   = "promote"
+	modeCopy      = "copy"
+	modeCleanup   = "cleanup"
+	modeResign    = "resign"
+	modeMerge     = "merge"
+	modeCollect   = "collect"
+	modeAssemble  = "assemble"
+	modeImport    = "import"
+	modeVerify    = "verify"
+	modeDiff      = "diff"
+	modeInventory = "inventory"
+	modeRollback  = "rollback"
+)
 
-		token := config.GetToken()
-		if token == "" {
-			return fmt.Errorf("NEWRELIC_TOKEN is required for artifact signing")
-		}
+// modes lists the values of the mode input besides the empty default, for its validation and usage
+var modes = []string{modeReconcile, modeBackfill, modePromote, modeCopy, modeCleanup, modeResign, modeMerge, modeCollect, modeAssemble, modeImport, modeVerify, modeDiff, modeInventory, modeRollback}
 
-		if err := sign.SignIndex(ctx, ociConfig.Registry, indexDigest, agentVersion, token, repoName); err != nil {
-			return fmt.Errorf("artifact signing failed: %w", err)
-		}
+// Values of the submission input
+const (
+	submissionFull        = "full"
+	submissionIncremental = "incremental"
+)
+
+// Phases of an agent release, selected with the phases input: metadata submits the agent metadata, upload pushes
+// the binaries and their manifest index, and sign signs the index
+const (
+	phaseMetadata = "metadata"
+	phaseUpload   = "upload"
+	phaseSign     = "sign"
+)
+
+// Values of the incremental-baseline input
+const (
+	baselineService  = "service"
+	baselineSnapshot = "snapshot"
+)
+
+// runFlow determines which flow to execute and runs it
+func runFlow(ctx context.Context, workspace, token string) error {
+	if version := config.GetPayloadVersion(); version != client.PayloadVersionAuto && !models.IsPayloadVersion(version) {
+		return fmt.Errorf("invalid payload-version %q: must be %s or one of %v", version, client.PayloadVersionAuto, models.PayloadVersions)
 	}
 
-	// Step 3: Send to metadata service
-	if err := client.SendMetadata(ctx, agentType, agentVersion, &metadata); err != nil {
-		return fmt.Errorf("failed to send metadata for %s: %w", agentType, err)
+	if threshold, err := config.GetCompressionThreshold(); err != nil || threshold < 0 {
+		return fmt.Errorf("invalid compression-threshold %q: must be a number of bytes, or 0 to disable compression", inputs.GetString("compression-threshold"))
 	}
 
-	logging.Noticef(ctx, "Successfully sent metadata for %s version %s", agentType, agentVersion)
-	return nil
-}
+	if limit, err := config.GetMaxPayloadSize(); err != nil || limit < 0 {
+		return fmt.Errorf("invalid max-payload-size %q: must be a number of bytes, or 0 for no limit", inputs.GetString("max-payload-size"))
+	}
 
-// runDocsFlow handles the documentation repository workflow
-func runDocsFlow(ctx context.Context, client metadataClient) error {
-	logging.Debug(ctx, "Running documentation flow")
+	if _, err := models.ParseEmptyFieldPolicy(config.GetEmptyFieldPolicy()); err != nil {
+		return fmt.Errorf("invalid empty-field-policy: %w", err)
+	}
 
-	// Load metadata from changed MDX files
-	metadataList, err := loader.LoadMetadataForDocs(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to load metadata from docs: %w", err)
+	if length, err := config.GetMaxTextLength(); err != nil || length < 0 {
+		return fmt.Errorf("invalid max-text-length %q: must be a number of characters, or 0 for no limit", inputs.GetString("max-text-length"))
 	}
 
-	if len(metadataList) == 0 {
-		logging.Notice(ctx, "No metadata changes detected")
-		return nil
+	if releaseDate := config.GetReleaseDate(); releaseDate != "" {
+		if _, err := models.ParseDate(releaseDate); err != nil {
+			return fmt.Errorf("invalid release-date: %w", err)
+		}
 	}
 
-	logging.Noticef(ctx, "Processing %d metadata entries", len(metadataList))
+	if window, err := config.GetReleaseDateWindow(); err != nil || window < 0 {
+		return fmt.Errorf("invalid release-date-window %q: must be a number of days, or 0 to disable the check", inputs.GetString("release-date-window"))
+	}
 
-	// Send each metadata entry separately
-	successCount := 0
-	for _, entry := range metadataList {
-		if err := sendDocsMetadata(ctx, client, entry); err != nil {
-			logging.Errorf(ctx, "Failed to send metadata for %s: %v", entry.AgentType, err)
-			continue
+	submission := config.GetSubmission()
+	if submission != submissionFull && submission != submissionIncremental {
+		return fmt.Errorf("invalid submission %q: must be %s or %s", submission, submissionFull, submissionIncremental)
+	}
+	switch baseline := config.GetIncrementalBaseline(); baseline {
+	case baselineService:
+	case baselineSnapshot:
+		if submission == submissionIncremental && config.GetExportDirectory() == "" {
+			return fmt.Errorf("incremental-baseline %s requires export-directory", baselineSnapshot)
 		}
-		successCount++
+	default:
+		return fmt.Errorf("invalid incremental-baseline %q: must be %s or %s", baseline, baselineService, baselineSnapshot)
 	}
 
-	logging.Noticef(ctx, "Successfully sent %d of %d metadata entries", successCount, len(metadataList))
-	return nil
-}
+	if _, err := selectedPhases(); err != nil {
+		return err
+	}
+
+	if concurrency, err := config.GetBackfillConcurrency(); err != nil || concurrency < 1 {
+		return fmt.Errorf("invalid backfill-concurrency %q: must be a number of versions, at least 1", inputs.GetString("backfill-concurrency"))
+	}
+
+	if days, err := config.GetRetentionDays(); err != nil || days < 1 {
+		return fmt.Errorf("invalid retention-days %q: must be a number of days, at least 1", inputs.GetString("retention-days"))
+	}
+
+	if rps, err := config.GetRateLimit(); err != nil || rps < 0 {
+		return fmt.Errorf("invalid rate-limit %q: must be a number of requests per second, or 0 for no limit", inputs.GetString("rate-limit"))
+	}
+
+	if budget, err := config.GetRetryBudget(); err != nil || budget < 0 {
+		return fmt.Errorf("invalid retry-budget %q: must be a duration such as 10m, or 0 for no budget", inputs.GetString("retry-budget"))
+	}
+
+	if interval, err := config.GetHeartbeatInterval(); err != nil || interval < 0 {
+		return fmt.Errorf("invalid heartbeat-interval %q: must be a duration such as 60s, or 0 to disable the heartbeat", inputs.GetString("heartbeat-interval"))
+	}
+
+	if ttl, err := config.GetReleaseLockTTL(); err != nil || ttl < 0 || (ttl > 0 && ttl < time.Minute) {
+		return fmt.Errorf("invalid release-lock-ttl %q: must be a duration of at least 1m such as 30m, or 0 to disable the lock", inputs.GetString("release-lock-ttl"))
+	}
+
+	if limit, err := config.GetPayloadMemoryLimit(); err != nil || limit < 0 {
+		return fmt.Errorf("invalid payload-memory-limit %q: must be a number of MiB, or 0 for no limit", inputs.GetString("payload-memory-limit"))
+	}
+
+	heartbeat.SetPhase(ctx, "preflight")
+	if err := runPreflight(ctx); err != nil {
+		return err
+	}
+
+	mode := config.GetMode()
+	if mode != "" {
+		heartbeat.SetPhase(ctx, mode)
+	}
+	switch mode {
+	case "":
+	case modeReconcile:
+		return runReconcileFlow(ctx, createReconcileServiceFunc(config.GetMetadataURL(), token), workspace)
+	case modeBackfill:
+		return runBackfillFlow(ctx, createMetadataClientFunc(config.GetMetadataURL(), token), workspace)
+	case modePromote:
+		return runPromoteFlow(ctx)
+	case modeCopy:
+		return runCopyFlow(ctx)
+	case modeCleanup:
+		return runCleanupFlow(ctx)
+	case modeResign:
+		return runResignFlow(ctx)
+	case modeMerge:
+		return runMergeFlow(ctx, workspace)
+	case modeCollect:
+		return runCollectFlow(ctx, workspace)
+	case modeAssemble:
+		return runAssembleFlow(ctx, workspace)
+	case modeImport:
+		return runImportFlow(ctx, workspace)
+	case modeVerify:
+		return runVerifyFlow(ctx, createReconcileServiceFunc(config.GetMetadataURL(), token), workspace)
+	case modeDiff:
+		return runDiffFlow(ctx, createReconcileServiceFunc(config.GetMetadataURL(), token), workspace)
+	case modeInventory:
+		return runInventoryFlow(ctx, createInventoryListerFunc(config.GetMetadataURL(), token), workspace)
+	case modeRollback:
+		return runRollbackFlow(ctx, createMetadataDeleterFunc(config.GetMetadataURL(), token), workspace)
+	default:
+		return fmt.Errorf("invalid mode %q: must be empty, %s or %s", mode, strings.Join(modes[:len(modes)-1], ", "), modes[len(modes)-1])
+	}
+
+	// Create metadataClient
+	metadataClient := createMetadataClientFunc(config.GetMetadataURL(), token)
+
+	// Determine which flow to execute
+	agentType := config.GetAgentType()
+	agentVersion := config.GetVersion()
+	monitoringType := config.GetMonitoringType()
+	if monitoringType != "" && monitoringType != "APM" && monitoringType != "INFRA" {
+		return fmt.Errorf("invalid monitoring-type %q: must be APM or INFRA", monitoringType)
+	}
+
+	if agentType != "" && agentVersion != "" {
+		if err := validateAgentType(ctx, metadataClient, agentType); err != nil {
+			return err
+		}
+		unlock, err := lockRelease(ctx, metadataClient, agentType, agentVersion)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		return runAgentFlow(ctx, metadataClient, workspace, agentType, agentVersion)
+	}
+
+	return runDocsFlow(ctx, metadataClient)
+}
+
+// runPreflight checks that the services and registry the run will use are reachable, and that the GitHub token can
+// do what the run needs, before any slow work
+// The signing service and registry are only checked for agent releases that upload binaries to a registry, in the
+// phases that need them, and copies, which check both registries, and the registry alone for promotions, cleanups,
+// verifications and rollbacks
+// Dry runs are skipped since they may be run offline
+func runPreflight(ctx context.Context) error {
+	if config.GetDryRun() {
+		logging.Debug(ctx, "Dry run - skipping pre-flight checks")
+		return nil
+	}
+
+	checks := []preflight.Check{preflight.ServiceCheck("instrumentation metadata service", config.GetMetadataURL())}
+	signingCheck := preflight.ServiceCheck("signing service", config.GetSigningURL())
+	signingCheck.Optional = !config.GetSigningRequired()
+	agentRelease := config.GetMode() == "" && config.GetAgentType() != "" && config.GetVersion() != ""
+	// Invalid OCI configuration is reported by the agent and promote flows
+	// Runs of some phases only need the registry, or the signing service alone when they sign an earlier upload
+	if ociConfig, err := oci.LoadConfig(); agentRelease && err == nil {
+		phases, _ := selectedPhases()
+		if phases[phaseSign] && (ociConfig.Registry != "" || !phases[phaseUpload]) {
+			checks = append(checks, signingCheck)
+		}
+		if phases[phaseUpload] && ociConfig.Registry != "" {
+			checks = append(checks, registryCheck(ociConfig))
+		}
+	}
+	registryOnly := config.GetMode() == modePromote || config.GetMode() == modeCleanup || config.GetMode() == modeVerify || config.GetMode() == modeRollback
+	if ociConfig, err := oci.LoadRegistryConfig(); registryOnly && err == nil {
+		checks = append(checks, registryCheck(ociConfig))
+	}
+	if ociConfig, err := oci.LoadRegistryConfig(); config.GetMode() == modeResign && err == nil {
+		// Signing is the whole point of the run, so it's never optional here
+		checks = append(checks, preflight.ServiceCheck("signing service", config.GetSigningURL()), registryCheck(ociConfig))
+	}
+	if ociConfig, err := oci.LoadConfig(); config.GetMode() == modeMerge && err == nil && ociConfig.Registry != "" {
+		checks = append(checks, signingCheck, registryCheck(ociConfig))
+	}
+	// Collect jobs only push manifests; the assemble job signs the index
+	if ociConfig, err := oci.LoadConfig(); config.GetMode() == modeCollect && err == nil && ociConfig.Registry != "" {
+		checks = append(checks, registryCheck(ociConfig))
+	}
+	if ociConfig, err := oci.LoadAssembleConfig(); (config.GetMode() == modeAssemble || config.GetMode() == modeImport) && err == nil {
+		checks = append(checks, signingCheck, registryCheck(ociConfig))
+	}
+	if config.GetMode() == modeCopy {
+		source, sourceErr := oci.LoadCopySourceConfig()
+		destination, destinationErr := oci.LoadRegistryConfig()
+		if sourceErr == nil && destinationErr == nil {
+			checks = append(checks, signingCheck, registryCheck(source), registryCheck(destination))
+		}
+	}
+	if err := preflightFunc(ctx, checks); err != nil {
+		return err
+	}
+	return checkGitHubToken(ctx, agentRelease || config.GetMode() == modeBackfill)
+}
+
+// registryCheck returns the pre-flight check of the OCI registry, reached the way the OCI client reaches it
+func registryCheck(ociConfig models.OCIConfig) preflight.Check {
+	check := preflight.RegistryCheck(ociConfig.Registry, ociConfig.PlainHTTP)
+	check.InsecureSkipTLSVerify = ociConfig.InsecureSkipTLSVerify
+	return check
+}
+
+// checkGitHubToken checks the github-token input can publish the check run and read the repositories remote schemas
+// are fetched from, which only agent metadata loads
+// Skipped without a token: only public repositories can be read then, and no check run is published
+func checkGitHubToken(ctx context.Context, loadsSchemas bool) error {
+	if config.GetGitHubToken() == "" {
+		return nil
+	}
+
+	var needs github.TokenNeeds
+	if config.GetRepo() != "" && config.GetSHA() != "" {
+		needs.CheckRunRepo = config.GetRepo()
+	}
+	if loadsSchemas {
+		needs.ReadRepos = loader.RemoteSchemaRepos(config.GetWorkspace(), config.GetPlaceholders())
+	}
+	if err := github.CheckTokenFunc(ctx, needs); err != nil {
+		return fmt.Errorf("github-token pre-flight check failed: %w", err)
+	}
+	logging.Debugf(ctx, "github-token can read the %d repositories remote schemas are fetched from", len(needs.ReadRepos))
+	return nil
+}
+
+// validateAgentType checks the agent-type input against the agent types registered with the instrumentation service
+// If the catalog can't be fetched the built-in list is used instead, but only to warn since it may be out of date
+func validateAgentType(ctx context.Context, client metadataClient, agentType string) error {
+	catalog, err := client.ListAgentTypes(ctx)
+	if err == nil && len(catalog) > 0 {
+		if err := agenttype.Validate(agentType, catalog); err != nil {
+			return fmt.Errorf("invalid agent-type: %w", err)
+		}
+		return nil
+	}
+
+	if err != nil {
+		logging.Debugf(ctx, "Unable to fetch registered agent types: %v - checking against the built-in list", err)
+	}
+	if err := agenttype.Validate(agentType, agenttype.Known); err != nil {
+		logging.Warnf(ctx, "%v (checked against the built-in list, which may be out of date)", err)
+	}
+	return nil
+}
+
+// reportValidationCheck publishes the collected annotations as a check run
+// Skipped if no GitHub token, repository or SHA is available; failures only warn
+func reportValidationCheck(ctx context.Context, annotations *github.AnnotationCollector, runErr error) {
+	repo := config.GetRepo()
+	sha := config.GetSHA()
+	if config.GetGitHubToken() == "" || repo == "" || sha == "" {
+		logging.Debug(ctx, "GitHub token, repository or SHA not available - skipping check run")
+		return
+	}
+
+	findings := annotations.Annotations()
+	conclusion := github.CheckConclusion(runErr, findings)
+	summary := fmt.Sprintf("Agent metadata validation completed with %d finding(s).", len(findings))
+	if runErr != nil {
+		summary = fmt.Sprintf("Agent metadata action failed: %v\n\n%s", runErr, summary)
+	}
+	if recorder := results.FromContext(ctx); recorder != nil {
+		if evaluated := policySummary(recorder.Results().Policy); evaluated != "" {
+			summary += "\n\n" + evaluated
+		}
+	}
+
+	checkURL, err := publishCheckRunFunc(ctx, repo, sha, conclusion, summary, findings)
+	if err != nil {
+		logging.Warnf(ctx, "Unable to publish %s check run: %s", github.CheckRunName, hints.Explain(err.Error()))
+		return
+	}
+	logging.Noticef(ctx, "Published %s check run (%s): %s", github.CheckRunName, conclusion, checkURL)
+}
+
+// validateEnvironment checks required environment variables and workspace
+func validateEnvironment(ctx context.Context) (workspace string, token string, err error) {
+	workspace = config.GetWorkspace()
+	if workspace == "" {
+		err := fmt.Errorf("GITHUB_WORKSPACE is required but not set")
+		logging.NoticeErrorWithCategory(ctx, err, "environment.validation", map[string]interface{}{
+			"error.operation": "validate_workspace",
+			"error.field":     "GITHUB_WORKSPACE",
+		})
+		return "", "", err
+	}
+
+	if _, err := os.Stat(workspace); err != nil {
+		noticeErr := fmt.Errorf("workspace directory does not exist: %s", workspace)
+		logging.NoticeErrorWithCategory(ctx, noticeErr, "environment.validation", map[string]interface{}{
+			"error.operation": "validate_workspace",
+			"workspace.path":  workspace,
+		})
+		return "", "", noticeErr
+	}
+
+	token = config.GetToken()
+	if token == "" {
+		err := fmt.Errorf("NEWRELIC_TOKEN is required but not set")
+		logging.NoticeErrorWithCategory(ctx, err, "environment.validation", map[string]interface{}{
+			"error.operation": "validate_token",
+			"error.field":     "NEWRELIC_TOKEN",
+		})
+		return "", "", err
+	}
+
+	if _, err := config.RegionURLs(config.GetRegion()); err != nil {
+		logging.NoticeErrorWithCategory(ctx, err, "environment.validation", map[string]interface{}{
+			"error.operation": "validate_region",
+			"error.field":     "INPUT_REGION",
+		})
+		return "", "", err
+	}
+
+	if err := config.ValidateEnvironment(); err != nil {
+		logging.NoticeErrorWithCategory(ctx, err, "environment.validation", map[string]interface{}{
+			"error.operation": "validate_environment",
+			"error.field":     "INPUT_ENVIRONMENT",
+		})
+		return "", "", err
+	}
+	if config.GetEnvironment() == config.EnvironmentStaging {
+		logging.Notice(ctx, "Using staging instrumentation metadata and signing services")
+	}
+
+	logging.Notice(ctx, "Environment validated successfully")
+	return workspace, token, nil
+}
+
+func validateConfigDirectory(ctx context.Context, workspace string) error {
+	configDir := config.GetRootFolderForAgentRepo()
+
+	fullPath := filepath.Join(workspace, configDir)
+	resolvedPath, err := filepath.Abs(fullPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve config directory path: %w", err)
+	}
+
+	if _, err := os.Stat(resolvedPath); err != nil {
+		return fmt.Errorf("config directory does not exist: %s", configDir)
+	}
+
+	logging.Debugf(ctx, "Using config directory: %s", configDir)
+	return nil
+}
+
+// runAgentFlow handles the agent repository workflow
+func runAgentFlow(ctx context.Context, client metadataClient, workspace, agentType, agentVersion string) error {
+	logging.Debugf(ctx, "Running agent repository flow for %s version %s", agentType, agentVersion)
+	heartbeat.SetPhase(ctx, "validate")
+
+	if err := validateConfigDirectory(ctx, workspace); err != nil {
+		return fmt.Errorf("config directory validation failed: %w", err)
+	}
+
+	placeholders := config.Placeholders{Version: agentVersion, AgentType: agentType}
+	if err := lintConfigDirectory(ctx, workspace, placeholders); err != nil {
+		return err
+	}
+
+	metadata, err := buildAgentMetadata(ctx, workspace, agentType, agentVersion)
+	if err != nil {
+		return err
+	}
+
+	if err := checkDeprecatedDefinitions(ctx, workspace, metadata.ConfigurationDefinitions); err != nil {
+		return err
+	}
+
+	if err := checkFixtures(ctx, workspace, agentType, metadata.ConfigurationDefinitions); err != nil {
+		return err
+	}
+
+	addChangelogs(ctx, workspace, agentType, agentVersion, metadata.ConfigurationDefinitions)
+
+	checkReleaseDate(ctx, workspace, agentVersion, metadata.Metadata, "")
+
+	printJSON(ctx, "Agent Metadata", metadata)
+
+	// The committed snapshot is read before the export overwrites it
+	snapshot, err := readSnapshotBaseline(workspace, agentType, agentVersion)
+	if err != nil {
+		return err
+	}
+
+	if err := exportMetadata(ctx, workspace, agentType, agentVersion, metadata); err != nil {
+		return err
+	}
+
+	if err := writeSchemaDocs(ctx, workspace, agentType, agentVersion, metadata.ConfigurationDefinitions); err != nil {
+		return err
+	}
+
+	ociConfig, err := oci.LoadConfig()
+	if err != nil {
+		logging.NoticeErrorWithCategory(ctx, err, "oci.configuration", map[string]interface{}{
+			"error.operation": "load_oci_config",
+			"agent.type":      agentType,
+			"agent.version":   agentVersion,
+		})
+		github.AddWorkflowAnnotation(ctx, github.AnnotationFailure, "Invalid binaries input", err.Error())
+		return fmt.Errorf("error loading OCI config: %w", err)
+	}
+
+	release := policy.Release{Kind: policy.KindAgent, AgentType: agentType, Version: agentVersion, Metadata: metadata.Metadata}
+	for _, artifact := range ociConfig.Artifacts {
+		release.OS = append(release.OS, artifact.OS)
+	}
+	if err := checkPolicy(ctx, workspace, release); err != nil {
+		return err
+	}
+
+	dryRun := config.GetDryRun()
+
+	regoInput := rego.Input{AgentType: agentType, Version: agentVersion, DryRun: dryRun, Metadata: metadata}
+	if ociConfig.IsEnabled() {
+		regoInput.Upload = &rego.Upload{Registry: ociConfig.Registry, Tag: oci.IndexTag(&ociConfig, agentVersion), Artifacts: ociConfig.Artifacts}
+	}
+	if err := checkRegoPolicies(ctx, workspace, regoInput); err != nil {
+		return err
+	}
+
+	if err := validateDownstreamInputs(); err != nil {
+		return err
+	}
+
+	phases, err := selectedPhases()
+	if err != nil {
+		return err
+	}
+
+	resumed, err := readResumeResults(ctx, workspace, agentType, agentVersion)
+	if err != nil {
+		return err
+	}
+
+	if !phases[phaseUpload] && phases[phaseSign] {
+		if err := signUploadedIndex(ctx, workspace, agentType, agentVersion, dryRun); err != nil {
+			return err
+		}
+	} else if !phases[phaseUpload] {
+		if ociConfig.IsEnabled() {
+			logging.Noticef(ctx, "The %s phase isn't selected - not uploading %d binaries", phaseUpload, len(ociConfig.Artifacts))
+		}
+	} else if ociConfig.IsEnabled() && dryRun && ociConfig.PlanFile != "" {
+		logging.Noticef(ctx, "Dry run - planning the upload of %d binaries without uploading or signing them", len(ociConfig.Artifacts))
+		heartbeat.SetPhase(ctx, "plan")
+		if _, err := ociHandlePlanFunc(ctx, &ociConfig, workspace, agentVersion); err != nil {
+			return fmt.Errorf("binary upload planning failed: %w", err)
+		}
+	} else if ociConfig.IsEnabled() && dryRun {
+		logging.Noticef(ctx, "Dry run - skipping upload and signing of %d binaries", len(ociConfig.Artifacts))
+	} else if signed, ok := resumedIndex(ctx, &ociConfig, agentVersion, resumed); ok {
+		// The earlier attempt pushed the index, so at most the signing is left
+		if err := resumeSigning(ctx, workspace, agentType, agentVersion, *resumed, signed, phases[phaseSign]); err != nil {
+			return err
+		}
+	} else if ociConfig.IsEnabled() {
+		// Step 1: Upload binaries
+		heartbeat.SetPhase(ctx, "upload")
+		resumeArtifacts(ctx, workspace, &ociConfig, resumed)
+		indexDigest, err := ociHandleUploadsFunc(ctx, &ociConfig, workspace, agentVersion)
+		if err != nil {
+			return fmt.Errorf("binary upload failed: %w", err)
+		}
+
+		if ociConfig.Registry == "" {
+			// The signing service signs in a registry, and pins point installers to one
+			logging.Noticef(ctx, "No oci-registry - the manifest index written to %s isn't signed", ociConfig.Layout)
+		} else if !phases[phaseSign] {
+			logging.Noticef(ctx, "The %s phase isn't selected - the manifest index %s is left for a run with the %s phase to sign", phaseSign, indexDigest, phaseSign)
+			if config.GetResultsFile() == "" {
+				logging.Warn(ctx, "results-file isn't set, so the uploaded manifest index isn't handed off to the sign phase")
+			}
+		} else {
+			// Step 2: Sign the manifest index
+			heartbeat.SetPhase(ctx, "sign")
+			if err := signIndex(ctx, ociConfig.Registry, indexDigest, oci.IndexTag(&ociConfig, agentVersion)); err != nil {
+				return err
+			}
+
+			// Step 2b: Pin the release to its digests for GitOps consumers
+			if err := writePins(ctx, workspace, agentType, agentVersion); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !phases[phaseMetadata] {
+		logging.Noticef(ctx, "The %s phase isn't selected - not sending metadata for %s version %s", phaseMetadata, agentType, agentVersion)
+		return nil
+	}
+
+	payloadDigest, err := metadataDigest(metadata)
+	if err != nil {
+		return err
+	}
+	payload := results.Payload{AgentType: agentType, Version: agentVersion, Source: config.GetRootFolderForAgentRepo(), Digest: payloadDigest}
+	if dryRun {
+		results.RecordPayload(ctx, payload)
+		logging.Noticef(ctx, "Dry run - not sending metadata for %s version %s", agentType, agentVersion)
+		return nil
+	}
+
+	if submitted, ok := resumedPayload(resumed, payload); ok {
+		// The idempotency key of a submission is derived from its payload and run, so the service would only return
+		// the outcome of the earlier attempt
+		payload.ID = submitted.ID
+		payload.Submitted = true
+		results.RecordPayload(ctx, payload)
+		logging.Noticef(ctx, "Resuming: the earlier attempt sent the same metadata for %s version %s (submission %s) - not sending it again", agentType, agentVersion, submitted.ID)
+	} else {
+		// Step 3: Send to metadata service
+		heartbeat.SetPhase(ctx, "submit")
+		response, err := submitMetadata(ctx, client, agentType, agentVersion, metadata, snapshot)
+		if err != nil {
+			payload.Error = err.Error()
+			results.RecordPayload(ctx, payload)
+			annotateValidationError(ctx, err)
+			return fmt.Errorf("failed to send metadata for %s: %w", agentType, err)
+		}
+		payload.ID = response.ID
+		payload.Submitted = true
+		results.RecordPayload(ctx, payload)
+		journal.Record(ctx, journal.Entry{Operation: journal.OperationMetadata, AgentType: agentType, Version: agentVersion, ID: response.ID})
+
+		logging.Noticef(ctx, "Successfully sent metadata for %s version %s", agentType, agentVersion)
+	}
+
+	// Step 4: Propose the generated pin and export files downstream
+	heartbeat.SetPhase(ctx, "downstream")
+	return proposeDownstream(ctx, workspace, agentType, agentVersion)
+}
+
+// readResumeResults reads the resume-results file of an earlier attempt of the run, whose completed work this attempt
+// skips, returning nil if there is nothing to resume: no resume-results, no file yet, or the file of another run
+func readResumeResults(ctx context.Context, workspace, agentType, agentVersion string) (*results.Results, error) {
+	resultsFile := config.GetResumeResults()
+	if resultsFile == "" {
+		return nil, nil
+	}
+	resultsFile, err := workspaceRelativePath("resume-results", resultsFile)
+	if err != nil {
+		return nil, err
+	}
+	earlier, err := results.Read(filepath.Join(workspace, resultsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		logging.Noticef(ctx, "No resume-results file %s - running every step", resultsFile)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resume-results: %w", err)
+	}
+	if earlier.AgentType != agentType || earlier.Version != agentVersion {
+		return nil, fmt.Errorf("resume-results %s is the release of %s %s, not %s %s", resultsFile, earlier.AgentType, earlier.Version, agentType, agentVersion)
+	}
+	if runID := config.GetRunID(); earlier.RunID == "" || earlier.RunID != runID {
+		logging.Warnf(ctx, "resume-results %s is of workflow run %q, not this run %q - running every step", resultsFile, earlier.RunID, runID)
+		return nil, nil
+	}
+	if earlier.DryRun {
+		logging.Noticef(ctx, "resume-results %s is of a dry run - running every step", resultsFile)
+		return nil, nil
+	}
+	logging.Noticef(ctx, "Resuming the release of %s version %s from the earlier attempt recorded in %s", agentType, agentVersion, resultsFile)
+	return &earlier, nil
+}
+
+// resumedIndex reports whether the earlier attempt pushed the manifest index of the release to oci-registry and
+// the tag still points to it, and whether it is signed, as the registry tells
+func resumedIndex(ctx context.Context, ociConfig *models.OCIConfig, agentVersion string, resumed *results.Results) (bool, bool) {
+	if resumed == nil || resumed.Index == nil || !ociConfig.IsEnabled() || ociConfig.Registry == "" {
+		return false, false
+	}
+	index := resumed.Index
+	tag := oci.IndexTag(ociConfig, agentVersion)
+	if index.Registry != ociConfig.Registry || index.Tag != tag || index.Digest == "" {
+		return false, false
+	}
+	release, err := ociInspectReleaseFunc(ctx, ociConfig, tag)
+	if err != nil {
+		logging.Warnf(ctx, "Unable to find the manifest index %s:%s the earlier attempt pushed: %v - uploading again", index.Registry, tag, err)
+		return false, false
+	}
+	if release.Index.Digest.String() != index.Digest {
+		logging.Warnf(ctx, "%s:%s points to %s, not %s as pushed by the earlier attempt - uploading again", index.Registry, tag, release.Index.Digest, index.Digest)
+		return false, false
+	}
+	return release.Signed, true
+}
+
+// resumeSigning continues the release from the manifest index the earlier attempt pushed: it is recorded again with
+// its registry and artifacts, signed unless it already is, and pinned
+func resumeSigning(ctx context.Context, workspace, agentType, agentVersion string, resumed results.Results, signed, sign bool) error {
+	// The signing outcome is this attempt's
+	index := *resumed.Index
+	signatureID := index.SignatureID
+	index.Signed, index.SignatureID, index.SigningError, index.ResignTask = false, "", "", ""
+	resumed.Index = &index
+	results.RecordUpload(ctx, resumed)
+	logging.Noticef(ctx, "Resuming: the earlier attempt pushed the manifest index %s:%s (%s) - not uploading %d binaries again", index.Registry, index.Tag, index.Digest, len(resumed.Artifacts))
+
+	if signed {
+		results.RecordSigning(ctx, signatureID, nil)
+		logging.Noticef(ctx, "Resuming: %s:%s is already signed - not signing it again", index.Registry, index.Tag)
+	} else if !sign {
+		logging.Noticef(ctx, "The %s phase isn't selected - the manifest index %s is left for a run with the %s phase to sign", phaseSign, index.Digest, phaseSign)
+		return nil
+	} else {
+		heartbeat.SetPhase(ctx, "sign")
+		if err := signIndex(ctx, index.Registry, index.Digest, index.Tag); err != nil {
+			return err
+		}
+	}
+	return writePins(ctx, workspace, agentType, agentVersion)
+}
+
+// resumeArtifacts turns the binaries the earlier attempt uploaded to oci-registry into references to the manifests
+// it pushed, so only the binaries it didn't upload are uploaded
+// A binary is only referenced while its file has the layer digest the earlier attempt uploaded, so one rebuilt since
+// is uploaded again
+func resumeArtifacts(ctx context.Context, workspace string, ociConfig *models.OCIConfig, resumed *results.Results) {
+	if resumed == nil || resumed.Registry == nil || ociConfig.Registry == "" || resumed.Registry.URL != ociConfig.Registry {
+		return
+	}
+	uploaded := map[string]results.Artifact{}
+	for _, artifact := range resumed.Artifacts {
+		if artifact.Uploaded && artifact.Digest != "" {
+			uploaded[artifact.Name] = artifact
+		}
+	}
+	for i := range ociConfig.Artifacts {
+		artifact := &ociConfig.Artifacts[i]
+		earlier, ok := uploaded[artifact.Name]
+		if !ok || artifact.IsReference() || earlier.OS != artifact.OS || earlier.Arch != artifact.Arch {
+			continue
+		}
+		layerDigest, err := oci.LayerDigest(workspace, artifact.Path)
+		if err != nil {
+			logging.Debugf(ctx, "Unable to hash %s: %v - uploading it again", artifact.Name, err)
+			continue
+		}
+		if earlier.LayerDigest == "" {
+			logging.Noticef(ctx, "Resuming: the earlier attempt didn't record the layer digest of %s - uploading it again", artifact.Name)
+			continue
+		}
+		if layerDigest != earlier.LayerDigest {
+			logging.Noticef(ctx, "Resuming: %s has changed since the earlier attempt uploaded it (%s, not %s) - uploading it again", artifact.Name, layerDigest, earlier.LayerDigest)
+			continue
+		}
+		artifact.Digest = earlier.Digest
+		logging.Noticef(ctx, "Resuming: the earlier attempt uploaded %s as %s - referencing it rather than uploading it again", artifact.Name, earlier.Digest)
+	}
+}
+
+// resumedPayload returns the submission of the earlier attempt if it sent the same metadata as payload
+// Payloads without a digest, as recorded before digests were, can't be compared and are sent again
+func resumedPayload(resumed *results.Results, payload results.Payload) (results.Payload, bool) {
+	if resumed == nil || payload.Digest == "" {
+		return results.Payload{}, false
+	}
+	for _, earlier := range resumed.Payloads {
+		if earlier.Submitted && earlier.AgentType == payload.AgentType && earlier.Version == payload.Version && earlier.Digest == payload.Digest {
+			return earlier, true
+		}
+	}
+	return results.Payload{}, false
+}
+
+// metadataDigest returns the SHA-256 digest of the canonical JSON of metadata, which the same metadata always gives
+func metadataDigest(metadata *models.AgentMetadata) (string, error) {
+	canonical, err := metadata.Canonical()
+	if err != nil {
+		return "", fmt.Errorf("failed to put the metadata in canonical order: %w", err)
+	}
+	data, err := json.Marshal(canonical)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data)), nil
+}
+
+// lockRelease takes the lock on releasing agentType version for release-lock-ttl, so a concurrent run releasing the
+// same version fails rather than racing this one, and renews it while the run goes on
+// The returned function releases the lock; dry runs, release-lock-ttl 0 and services without release locks don't lock
+func lockRelease(ctx context.Context, metadata metadataClient, agentType, agentVersion string) (func(), error) {
+	// An invalid release-lock-ttl is rejected by runFlow
+	ttl, _ := config.GetReleaseLockTTL()
+	locker, ok := metadata.(releaseLocker)
+	if !ok || ttl == 0 || config.GetDryRun() {
+		return func() {}, nil
+	}
+
+	owner := releaseLockOwner()
+	lock, err := locker.LockRelease(ctx, agentType, agentVersion, owner, ttl)
+	var locked *client.ReleaseLockedError
+	switch {
+	case errors.Is(err, client.ErrReleaseLockUnsupported):
+		logging.Warnf(ctx, "%v - concurrent releases of %s version %s aren't prevented", err, agentType, agentVersion)
+		return func() {}, nil
+	case errors.As(err, &locked):
+		github.AddWorkflowAnnotation(ctx, github.AnnotationFailure, "Release already in progress", err.Error())
+		return nil, err
+	case err != nil:
+		return nil, fmt.Errorf("failed to lock the release of %s version %s: %w", agentType, agentVersion, err)
+	}
+	logging.Noticef(ctx, "Locked the release of %s version %s for %s until %s", agentType, agentVersion, owner, lock.ExpiresAt.UTC().Format(time.RFC3339))
+
+	// Renewed well before it lapses, so one failed renewal doesn't lose it
+	renewCtx, stopRenewing := context.WithCancel(ctx)
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-renewCtx.Done():
+				return
+			case <-ticker.C:
+				if _, err := locker.LockRelease(renewCtx, agentType, agentVersion, owner, ttl); err != nil && renewCtx.Err() == nil {
+					logging.Warnf(ctx, "Failed to renew the release lock of %s version %s: %v", agentType, agentVersion, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		stopRenewing()
+		<-renewed
+		if err := locker.UnlockRelease(ctx, agentType, agentVersion, owner); err != nil {
+			logging.Warnf(ctx, "Failed to release the lock of %s version %s: %v - it lapses on its own", agentType, agentVersion, err)
+			return
+		}
+		logging.Debugf(ctx, "Released the lock of %s version %s", agentType, agentVersion)
+	}, nil
+}
+
+// releaseLockOwner identifies the run holding a release lock: the job and attempt of the workflow run, so two jobs of
+// one run don't share the lock, or the process of a local run
+func releaseLockOwner() string {
+	if runID := config.GetRunID(); runID != "" {
+		owner := fmt.Sprintf("%s run %s", config.GetRepo(), runID)
+		if attempt := config.GetRunAttempt(); attempt != "" {
+			owner += " attempt " + attempt
+		}
+		if job := config.GetJob(); job != "" {
+			owner += " job " + job
+		}
+		return owner
+	}
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("local run %d on %s", os.Getpid(), hostname)
+}
+
+// selectedPhases returns the phases of an agent release the phases input selects
+func selectedPhases() (map[string]bool, error) {
+	phases := make(map[string]bool)
+	for _, phase := range config.GetPhases() {
+		if phase != phaseMetadata && phase != phaseUpload && phase != phaseSign {
+			return nil, fmt.Errorf("invalid phases %q: each must be %s, %s or %s", inputs.GetString("phases"), phaseMetadata, phaseUpload, phaseSign)
+		}
+		phases[phase] = true
+	}
+	if len(phases) == 0 {
+		return nil, fmt.Errorf("invalid phases: select at least one of %s, %s and %s", phaseMetadata, phaseUpload, phaseSign)
+	}
+	return phases, nil
+}
+
+// signUploadedIndex signs the manifest index recorded in the phase-results file by the run that performed the upload
+// phase, and pins the release to its digests
+// The registry, index and artifacts of that run are recorded again, so the results file of this run is complete
+func signUploadedIndex(ctx context.Context, workspace, agentType, agentVersion string, dryRun bool) error {
+	resultsFile := config.GetPhaseResults()
+	if resultsFile == "" {
+		return fmt.Errorf("the %s phase without the %s phase requires phase-results, the results file of the run that uploaded the binaries", phaseSign, phaseUpload)
+	}
+	resultsFile, err := workspaceRelativePath("phase-results", resultsFile)
+	if err != nil {
+		return err
+	}
+	uploaded, err := results.Read(filepath.Join(workspace, resultsFile))
+	if err != nil {
+		return fmt.Errorf("failed to read phase-results: %w", err)
+	}
+	if uploaded.AgentType != agentType || uploaded.Version != agentVersion {
+		return fmt.Errorf("phase-results %s is the release of %s %s, not %s %s", resultsFile, uploaded.AgentType, uploaded.Version, agentType, agentVersion)
+	}
+	if uploaded.Index == nil || uploaded.Index.Registry == "" {
+		return fmt.Errorf("phase-results %s records no manifest index in a registry to sign", resultsFile)
+	}
+	results.RecordUpload(ctx, uploaded)
+	index := uploaded.Index
+	if dryRun {
+		logging.Noticef(ctx, "Dry run - not signing %s:%s (%s)", index.Registry, index.Tag, index.Digest)
+		return nil
+	}
+
+	heartbeat.SetPhase(ctx, "sign")
+	if err := signIndex(ctx, index.Registry, index.Digest, index.Tag); err != nil {
+		return err
+	}
+	return writePins(ctx, workspace, agentType, agentVersion)
+}
+
+// signIndex signs the manifest index with digest in registry, tagged tag, as the repository running the workflow
+// With signing-required false, a failing signing service doesn't fail the release: the index is left unsigned with
+// a warning and a resign task is written for a follow-up workflow
+func signIndex(ctx context.Context, registry, digest, tag string) error {
+	signature, err := signManifest(ctx, registry, digest, tag)
+	if errors.Is(err, errSigningNotConfigured) {
+		return err
+	}
+	results.RecordSigning(ctx, signature.ID, err)
+	if err == nil {
+		journal.Record(ctx, journal.Entry{Operation: journal.OperationSignature, Registry: registry, Tag: tag, Digest: digest, ID: signature.ID})
+		return nil
+	}
+	if config.GetSigningRequired() {
+		return fmt.Errorf("artifact signing failed: %w", err)
+	}
+
+	taskPath := filepath.Join(config.GetWorkspace(), sign.ResignTaskFile)
+	task := sign.ResignTask{Registry: registry, Tag: tag, Digest: digest, Repository: config.GetRepo(), RunID: config.GetRunID(), Error: err.Error()}
+	if writeErr := sign.WriteResignTask(taskPath, task); writeErr != nil {
+		return fmt.Errorf("artifact signing failed: %w (and the resign task couldn't be written: %v)", err, writeErr)
+	}
+	results.RecordResignTask(ctx, sign.ResignTaskFile)
+	message := fmt.Sprintf("Manifest index %s was uploaded but not signed: %v. signing-required is false, so the release continues unsigned; sign it with mode: resign, using the task in %s", digest, err, sign.ResignTaskFile)
+	logging.Warn(ctx, message)
+	github.AddWorkflowAnnotation(ctx, github.AnnotationWarning, "Manifest index not signed", message)
+	return nil
+}
+
+// errSigningNotConfigured is returned by signManifest when the run lacks what signing requests need
+var errSigningNotConfigured = errors.New("signing not configured")
+
+// signManifest asks the signing service to sign the manifest or index with digest in registry, tagged tag, as the
+// repository running the workflow
+func signManifest(ctx context.Context, registry, digest, tag string) (models.SigningResponse, error) {
+	githubRepo := config.GetRepo()
+	if githubRepo == "" {
+		return models.SigningResponse{}, fmt.Errorf("%w: GITHUB_REPOSITORY environment variable is required for artifact signing", errSigningNotConfigured)
+	}
+
+	// Extract repository name from full path (e.g., "agent-metadata-action" from "newrelic/agent-metadata-action")
+	repoParts := strings.Split(githubRepo, "/")
+	repoName := repoParts[len(repoParts)-1]
+
+	token := config.GetToken()
+	if token == "" {
+		return models.SigningResponse{}, fmt.Errorf("%w: NEWRELIC_TOKEN is required for artifact signing", errSigningNotConfigured)
+	}
+
+	return sign.SignIndex(ctx, registry, digest, tag, token, repoName)
+}
+
+// validateDownstreamInputs checks the pins and downstream inputs before anything is published, so a typo doesn't
+// fail the run after the release is out
+func validateDownstreamInputs() error {
+	pinsDir := config.GetPinsDirectory()
+	if pinsDir != "" {
+		if _, err := workspaceRelativePath("pins-directory", pinsDir); err != nil {
+			return err
+		}
+	}
+	downstreamRepo := config.GetDownstreamRepository()
+	if downstreamRepo == "" {
+		return nil
+	}
+	if pinsDir == "" && config.GetExportDirectory() == "" {
+		return fmt.Errorf("downstream-repository requires pins-directory or export-directory, the files to propose")
+	}
+	if err := github.ValidateRepo(downstreamRepo); err != nil {
+		return fmt.Errorf("invalid downstream-repository: %w", err)
+	}
+	return nil
+}
+
+// writePins writes the digest pin file of the release to the pins-directory input, if set
+func writePins(ctx context.Context, workspace, agentType, agentVersion string) error {
+	pinsDir := config.GetPinsDirectory()
+	if pinsDir == "" {
+		return nil
+	}
+	recorder := results.FromContext(ctx)
+	if recorder == nil {
+		return fmt.Errorf("no results recorded to pin %s version %s", agentType, agentVersion)
+	}
+	pin, err := pins.FromResults(agentType, agentVersion, recorder.Results())
+	if err != nil {
+		return fmt.Errorf("failed to pin the release: %w", err)
+	}
+
+	pinPath, err := pins.Write(filepath.Join(workspace, pinsDir), pin)
+	if err != nil {
+		return fmt.Errorf("failed to write pins: %w", err)
+	}
+	relative := github.RelativeToWorkspace(workspace, pinPath)
+	results.RecordPins(ctx, results.Pins{Path: relative})
+	logging.Noticef(ctx, "Wrote digest pins for %s version %s to %s", agentType, agentVersion, relative)
+	return nil
+}
+
+// proposeDownstream opens a pull request against the downstream-repository input, if set, with the pin and export
+// files generated for the release, at the same paths as in the workspace
+// Reruns update the branch and pull request of the release
+func proposeDownstream(ctx context.Context, workspace, agentType, agentVersion string) error {
+	downstreamRepo := config.GetDownstreamRepository()
+	if downstreamRepo == "" {
+		return nil
+	}
+
+	var paths []string
+	if recorder := results.FromContext(ctx); recorder != nil && recorder.Results().Pins != nil {
+		paths = append(paths, recorder.Results().Pins.Path)
+	}
+	if exportDir := config.GetExportDirectory(); exportDir != "" {
+		agentDir := path.Join(filepath.ToSlash(filepath.Clean(exportDir)), export.AgentsDirectory, agentType)
+		paths = append(paths, path.Join(agentDir, agentVersion+".json"), path.Join(agentDir, export.IndexFilename))
+	}
+	if len(paths) == 0 {
+		logging.Noticef(ctx, "No pin or export files were generated for %s version %s - not opening a pull request against %s", agentType, agentVersion, downstreamRepo)
+		return nil
+	}
+
+	files := make(map[string][]byte, len(paths))
+	for _, file := range paths {
+		content, err := os.ReadFile(filepath.Join(workspace, filepath.FromSlash(file)))
+		if err != nil {
+			return fmt.Errorf("failed to read %s for the downstream pull request: %w", file, err)
+		}
+		files[file] = content
+	}
+
+	change := github.Change{
+		Repo:   downstreamRepo,
+		Branch: fmt.Sprintf("agent-metadata/%s/%s", agentType, agentVersion),
+		Title:  fmt.Sprintf("Release %s %s", agentType, agentVersion),
+		Body:   downstreamDescription(ctx, agentType, agentVersion, paths),
+		Files:  files,
+	}
+	recorded := results.PullRequest{Repository: downstreamRepo, Files: paths}
+	pullRequest, err := github.NewClient(config.GetGitHubAPIURL(), config.GetDownstreamToken()).ProposeChange(ctx, change)
+	if err != nil {
+		results.RecordPullRequest(ctx, recorded)
+		return fmt.Errorf("failed to open the downstream pull request: %w", err)
+	}
+	recorded.URL = pullRequest
+	results.RecordPullRequest(ctx, recorded)
+	logging.Noticef(ctx, "Proposed %d files for %s version %s to %s in %s", len(paths), agentType, agentVersion, downstreamRepo, pullRequest)
+	return nil
+}
+
+// downstreamDescription returns the body of the downstream pull request: the files and, if pinned, the digests
+func downstreamDescription(ctx context.Context, agentType, agentVersion string, paths []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Generated by agent-metadata-action for %s %s", agentType, agentVersion)
+	if repo, runID := config.GetRepo(), config.GetRunID(); repo != "" && runID != "" {
+		fmt.Fprintf(&b, " in %s (run %s)", repo, runID)
+	}
+	b.WriteString(".\n\n")
+	for _, file := range paths {
+		fmt.Fprintf(&b, "- `%s`\n", file)
+	}
+	if recorder := results.FromContext(ctx); recorder != nil {
+		if pin, err := pins.FromResults(agentType, agentVersion, recorder.Results()); err == nil && recorder.Results().Pins != nil {
+			b.WriteString("\n")
+			b.WriteString(pins.Description(pin))
+		}
+	}
+	return b.String()
+}
+
+// readSnapshotBaseline reads the exported snapshot of an agent version that incremental submissions with the
+// snapshot baseline are compared against
+// Returns nil if the run doesn't use it or the version has no snapshot
+func readSnapshotBaseline(workspace, agentType, agentVersion string) (*models.AgentMetadata, error) {
+	if config.GetSubmission() != submissionIncremental || config.GetIncrementalBaseline() != baselineSnapshot {
+		return nil, nil
+	}
+	snapshot, err := export.Read(filepath.Join(workspace, config.GetExportDirectory()), agentType, agentVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the incremental baseline: %w", err)
+	}
+	return snapshot, nil
+}
+
+// submitMetadata sends metadata to the service in full or, with submission: incremental, as only the definitions
+// added, changed or removed since the baseline: the metadata the service stores for the version, or its snapshot
+// Versions without a baseline, definitions that can't be compared and clients without incremental support are
+// submitted in full
+func submitMetadata(ctx context.Context, client metadataClient, agentType, agentVersion string, metadata, snapshot *models.AgentMetadata) (models.SubmissionResponse, error) {
+	if config.GetSubmission() != submissionIncremental {
+		return client.SubmitMetadata(ctx, agentType, agentVersion, metadata)
+	}
+	incremental, ok := client.(incrementalClient)
+	if !ok {
+		logging.Warn(ctx, "The metadata client doesn't support incremental submissions - submitting in full")
+		return client.SubmitMetadata(ctx, agentType, agentVersion, metadata)
+	}
+
+	// Snapshots have decoded schemas and content, so they are compared with the metadata in the same form
+	baseline, current := snapshot, export.Resolve(metadata)
+	if config.GetIncrementalBaseline() == baselineService {
+		var err error
+		if baseline, err = incremental.GetMetadata(ctx, agentType, agentVersion); err != nil {
+			return models.SubmissionResponse{}, fmt.Errorf("failed to fetch the incremental baseline: %w", err)
+		}
+		current = metadata
+	}
+	if baseline == nil {
+		logging.Noticef(ctx, "No %s baseline for %s version %s - submitting in full", config.GetIncrementalBaseline(), agentType, agentVersion)
+		return client.SubmitMetadata(ctx, agentType, agentVersion, metadata)
+	}
+
+	changes, err := models.DiffDefinitions(baseline, current)
+	if err != nil {
+		logging.Warnf(ctx, "Unable to compare the definitions with the %s baseline: %v - submitting in full", config.GetIncrementalBaseline(), err)
+		return client.SubmitMetadata(ctx, agentType, agentVersion, metadata)
+	}
+	logging.Noticef(ctx, "Submitting %d added, changed or removed definitions of %s version %s incrementally", changes.Count(), agentType, agentVersion)
+	return incremental.SubmitIncremental(ctx, agentType, agentVersion, changes.Select(metadata), changes.Removed)
+}
+
+// lintConfigDirectory checks the configuration definitions and their schemas against the lint rules
+// configured in lint.yml, annotating each finding
+// Findings at error severity fail the run; placeholders are those of the release being checked
+func lintConfigDirectory(ctx context.Context, workspace string, placeholders config.Placeholders) error {
+	cfg, err := lint.LoadConfig(filepath.Join(workspace, config.GetLintConfigFilepath()))
+	if err != nil {
+		github.AddAnnotation(ctx, github.AnnotationFailure, config.GetLintConfigFilepath(), "Invalid lint configuration", err.Error())
+		return fmt.Errorf("invalid lint configuration: %w", err)
+	}
+
+	docs, err := lint.Load(ctx, workspace, config.GetRootFolderForAgentRepo(), config.GetConfigurationDefinitionsFilepath(), placeholders)
+	if err != nil {
+		// Loading the metadata reports a missing or malformed definitions file
+		logging.Debugf(ctx, "Skipping schema lint: %v", err)
+		return nil
+	}
+	findings, err := lint.Run(docs, cfg)
+	if err != nil {
+		github.AddAnnotation(ctx, github.AnnotationFailure, config.GetLintConfigFilepath(), "Invalid lint configuration", err.Error())
+		return fmt.Errorf("invalid lint configuration: %w", err)
+	}
+
+	levels := map[lint.Severity]github.AnnotationLevel{
+		lint.SeverityError:   github.AnnotationFailure,
+		lint.SeverityWarning: github.AnnotationWarning,
+		lint.SeverityNote:    github.AnnotationNotice,
+	}
+	for _, finding := range findings {
+		switch finding.Severity {
+		case lint.SeverityError:
+			logging.Errorf(ctx, "%s", finding)
+		case lint.SeverityWarning:
+			logging.Warnf(ctx, "%s", finding)
+		default:
+			logging.Noticef(ctx, "%s", finding)
+		}
+		github.RecordAnnotation(ctx, github.Annotation{
+			Path:            finding.Path,
+			StartLine:       finding.Line,
+			StartColumn:     finding.Column,
+			AnnotationLevel: levels[finding.Severity],
+			Title:           "Lint: " + finding.RuleID,
+			Message:         finding.Message,
+			Rule:            lintRulePrefix + finding.RuleID,
+		})
+	}
+
+	if errorCount := lint.Count(findings, lint.SeverityError); errorCount > 0 {
+		return fmt.Errorf("schema lint found %d error(s)", errorCount)
+	}
+	logging.Debugf(ctx, "Schema lint found %d finding(s)", len(findings))
+	return nil
+}
+
+// checkReleaseDate warns when the releaseDate of a release is more than release-date-window days from when git says
+// the version was released, which usually means the frontmatter was copied from a previous release
+// sourceFile is the release note, if any; the warning is annotated on it, or on the workflow file for the input
+func checkReleaseDate(ctx context.Context, workspace, version string, metadata models.Metadata, sourceFile string) {
+	window, _ := config.GetReleaseDateWindow()
+	if window == 0 || !metadata.IsSet("releaseDate") {
+		return
+	}
+	// Invalid dates are left for the service to report
+	releaseDate, err := models.ParseDate(metadata["releaseDate"])
+	if err != nil {
+		return
+	}
+
+	released, source, err := github.VersionDateFunc(ctx, workspace, version, sourceFile)
+	if err != nil {
+		logging.Debugf(ctx, "Unable to find when %s was released: %v - skipping the releaseDate check", version, err)
+		return
+	}
+	if released.IsZero() {
+		logging.Debugf(ctx, "No tag or commit found for %s - skipping the releaseDate check", version)
+		return
+	}
+
+	// Compare calendar days, taking the git date in the timezone it was recorded in
+	releasedDay := time.Date(released.Year(), released.Month(), released.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(math.Abs(releaseDate.Sub(releasedDay).Hours() / 24))
+	if days <= window {
+		logging.Debugf(ctx, "releaseDate %s of %s is within %d days of the %s", releaseDate.Format(models.DateLayout), version, window, source)
+		return
+	}
+
+	message := fmt.Sprintf("releaseDate %s of %s is %d days from the %s (%s) - was it copied from a previous release?",
+		releaseDate.Format(models.DateLayout), version, days, source, releasedDay.Format(models.DateLayout))
+	logging.Warnf(ctx, "%s", message)
+	if sourceFile != "" {
+		github.AddAnnotation(ctx, github.AnnotationWarning, github.RelativeToWorkspace(workspace, sourceFile), "Suspicious releaseDate", message)
+	} else {
+		github.AddWorkflowAnnotation(ctx, github.AnnotationWarning, "Suspicious releaseDate", message)
+	}
+}
+
+// checkDeprecatedDefinitions fails the run when a configuration definition type is deprecated in the same release
+// that introduces it, since nothing could have used it yet; deprecated types must exist in the previous release
+// The previous release is the newest tag before the checked out commit; without one the check is skipped
+func checkDeprecatedDefinitions(ctx context.Context, workspace string, definitions []models.ConfigurationDefinition) error {
+	var deprecated []models.ConfigurationDefinition
+	for _, definition := range definitions {
+		if models.IsDeprecated(definition) {
+			deprecated = append(deprecated, definition)
+		}
+	}
+	if len(deprecated) == 0 {
+		return nil
+	}
+
+	path := config.GetConfigurationDefinitionsFilepath()
+	content, tag, err := github.PreviousReleaseFileFunc(ctx, workspace, path)
+	if err != nil {
+		logging.Debugf(ctx, "Unable to read the previous release of %s: %v - skipping the deprecation check", path, err)
+		return nil
+	}
+	if tag == "" {
+		logging.Debug(ctx, "No previous release found - skipping the deprecation check")
+		return nil
+	}
+
+	var previous models.ConfigFile
+	if err := yaml.Unmarshal(content, &previous); err != nil {
+		logging.Debugf(ctx, "Unable to parse %s at %s: %v - skipping the deprecation check", path, tag, err)
+		return nil
+	}
+	released := map[interface{}]bool{}
+	for _, definition := range previous.Configs {
+		released[definition["type"]] = true
+	}
+
+	var introduced []string
+	for _, definition := range deprecated {
+		if released[definition["type"]] {
+			continue
+		}
+		message := fmt.Sprintf("configuration definition type %v is deprecated but was not in the previous release %s - remove it instead", definition["type"], tag)
+		logging.Errorf(ctx, "%s", message)
+		github.AddAnnotation(ctx, github.AnnotationFailure, path, "Deprecated type newly introduced", message)
+		introduced = append(introduced, fmt.Sprint(definition["type"]))
+	}
+	if len(introduced) > 0 {
+		return fmt.Errorf("deprecated configuration definition types not in the previous release %s: %s", tag, strings.Join(introduced, ", "))
+	}
+	return nil
+}
+
+// checkFixtures validates the configuration samples committed in the fixtures directory against the schema of each
+// configuration definition of their type, and against the schema of the same definition in the previous release,
+// so schema changes that reject configurations customers already use are reported before the release
+// Rejected samples are warnings, or fail the run with strict-fixtures
+func checkFixtures(ctx context.Context, workspace, agentType string, definitions []models.ConfigurationDefinition) error {
+	dir := config.GetFixturesDirectory()
+	samples, err := fixtures.Load(workspace, dir)
+	if err != nil {
+		github.AddAnnotation(ctx, github.AnnotationFailure, dir, "Invalid fixture", err.Error())
+		return fmt.Errorf("invalid fixtures: %w", err)
+	}
+	if len(samples) == 0 {
+		logging.Debugf(ctx, "No configuration samples in %s - skipping the fixture checks", dir)
+		return nil
+	}
+
+	previous, tag := previousSchemas(ctx, workspace, agentType)
+	strict := config.GetStrictFixtures()
+	level := github.AnnotationWarning
+	if strict {
+		level = github.AnnotationFailure
+	}
+	checked, rejected, broken := 0, 0, 0
+	for _, sample := range samples {
+		matched := false
+		for _, definition := range definitions {
+			if definition["type"] != sample.Type {
+				continue
+			}
+			matched = true
+			platform, _ := definition["platform"].(string)
+			current, err := definitionSchema(definition)
+			if err != nil {
+				logging.Debugf(ctx, "Not checking %s against the %s schema of %s: %v", sample.Path, platform, sample.Type, err)
+				continue
+			}
+			previousSchema := previous[definitionKey{Type: sample.Type, Platform: platform}]
+			result := fixtures.Check(sample.Value, current, previousSchema)
+			checked++
+
+			fixture := results.Fixture{Path: sample.Path, Type: sample.Type, Platform: platform, Outcome: string(result.Outcome), Errors: result.Errors}
+			if previousSchema != nil {
+				fixture.PreviousRelease = tag
+			}
+			results.RecordFixture(ctx, fixture)
+
+			var message string
+			switch result.Outcome {
+			case fixtures.OutcomeBreaks:
+				broken++
+				message = fmt.Sprintf("valid against the %s %s schema of %s, but rejected by the new one: %s", platform, sample.Type, tag, strings.Join(result.Errors, "; "))
+			case fixtures.OutcomeInvalid:
+				message = fmt.Sprintf("rejected by the %s %s schema: %s", platform, sample.Type, strings.Join(result.Errors, "; "))
+			default:
+				logging.Debugf(ctx, "Configuration sample %s is %s with the %s %s schema", sample.Path, result.Outcome, platform, sample.Type)
+				continue
+			}
+			rejected++
+			if strict {
+				logging.Errorf(ctx, "Configuration sample %s is %s", sample.Path, message)
+			} else {
+				logging.Warnf(ctx, "Configuration sample %s is %s", sample.Path, message)
+			}
+			title := "Configuration sample rejected"
+			if result.Outcome == fixtures.OutcomeBreaks {
+				title = "Configuration sample broken by the schema"
+			}
+			github.AddAnnotation(ctx, level, sample.Path, title, "The sample is "+message)
+		}
+		if !matched {
+			message := fmt.Sprintf("Configuration sample %s isn't checked: there is no configuration definition of type %s", sample.Path, sample.Type)
+			logging.Warnf(ctx, "%s", message)
+			github.AddAnnotation(ctx, github.AnnotationWarning, sample.Path, "Configuration sample not checked", message)
+		}
+	}
+
+	logging.Noticef(ctx, "Checked %d configuration samples against the new schemas: %d rejected, %d of them valid in the previous release", checked, rejected, broken)
+	if strict && rejected > 0 {
+		return fmt.Errorf("the configuration schemas reject %d configuration samples, %d of them valid in the previous release", rejected, broken)
+	}
+	return nil
+}
+
+// addChangelogs adds the changelogs of the configuration definitions to the job summary; they are sent with the
+// definitions, so Fleet Control can show what changed in the configuration between versions
+// With derive-changelog, a definition without a changelog field gets one listing how its schema changed since the
+// previous release: every property for a definition that had no schema, none for one whose schemas can't be compared
+func addChangelogs(ctx context.Context, workspace, agentType, agentVersion string, definitions []models.ConfigurationDefinition) {
+	var previous map[definitionKey][]byte
+	var tag string
+	if config.GetDeriveChangelog() {
+		if previous, tag = previousSchemaFiles(ctx, workspace, agentType); tag == "" {
+			logging.Notice(ctx, "No previous release to derive configuration changelogs from")
+		}
+	}
+
+	var summary strings.Builder
+	written, derived := 0, 0
+	for _, definition := range definitions {
+		typ, _ := definition["type"].(string)
+		platform, _ := definition["platform"].(string)
+		entries := models.Changelog(definition)
+		source := ""
+		if entries != nil {
+			written++
+		} else if tag != "" {
+			if entries = deriveChangelog(ctx, definition, previous, tag); len(entries) == 0 {
+				continue
+			}
+			definition[models.ChangelogField] = entries
+			derived++
+			source = fmt.Sprintf("_Derived from the schema changes since %s_\n\n", tag)
+		} else {
+			continue
+		}
+
+		fmt.Fprintf(&summary, "### %s (%s)\n\n%s", typ, platform, source)
+		for _, entry := range entries {
+			fmt.Fprintf(&summary, "- %s\n", entry)
+		}
+		summary.WriteString("\n")
+	}
+	if written+derived == 0 {
+		return
+	}
+
+	message := fmt.Sprintf("Sending changelogs with %d configuration definitions", written+derived)
+	if derived > 0 {
+		message += fmt.Sprintf(", %d of them derived from the schema changes since %s", derived, tag)
+	}
+	logging.Notice(ctx, message)
+	markdown := fmt.Sprintf("## Configuration changelog of %s %s\n\n%s", agentType, agentVersion, summary.String())
+	if err := github.AppendStepSummary(markdown); err != nil {
+		logging.Warnf(ctx, "Unable to write the configuration changelog to the job summary: %v", err)
+	}
+}
+
+// deriveChangelog lists how the schema of a configuration definition changed since the previous release tag
+func deriveChangelog(ctx context.Context, definition models.ConfigurationDefinition, previous map[definitionKey][]byte, tag string) []string {
+	typ, _ := definition["type"].(string)
+	platform, _ := definition["platform"].(string)
+	before, ok := previous[definitionKey{Type: typ, Platform: platform}]
+	if !ok {
+		logging.Debugf(ctx, "No schema of the %s %s configuration at %s to derive its changelog from", platform, typ, tag)
+		return nil
+	}
+	if before == nil {
+		before = []byte("{}")
+	}
+	encoded, _ := definition["schema"].(string)
+	after, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || encoded == "" {
+		logging.Debugf(ctx, "The %s %s configuration has no schema to derive its changelog from", platform, typ)
+		return nil
+	}
+	changes, err := schemadoc.Changes(before, after)
+	if err != nil {
+		logging.Debugf(ctx, "Unable to compare the %s %s schema with %s: %v", platform, typ, tag, err)
+		return nil
+	}
+	if len(changes) == 0 {
+		logging.Debugf(ctx, "The %s %s schema is unchanged since %s", platform, typ, tag)
+	}
+	return changes
+}
+
+// definitionKey identifies a configuration definition across releases
+type definitionKey struct {
+	Type     string
+	Platform string
+}
+
+// definitionSchema parses the schema of a configuration definition, which the loader base64-encodes
+func definitionSchema(definition models.ConfigurationDefinition) (*fixtures.Schema, error) {
+	encoded, ok := definition["schema"].(string)
+	if !ok || encoded == "" {
+		return nil, fmt.Errorf("no schema")
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the schema: %w", err)
+	}
+	return fixtures.ParseSchema(data)
+}
+
+// previousSchemas returns the schemas of the configuration definitions in the previous release, and its tag
+// Schemas that can't be read (see previousSchemaFiles) are left out, so samples are only checked against the new
+// schema; without a previous release there are none
+func previousSchemas(ctx context.Context, workspace, agentType string) (map[definitionKey]*fixtures.Schema, string) {
+	files, tag := previousSchemaFiles(ctx, workspace, agentType)
+	if tag == "" {
+		logging.Debug(ctx, "Checking configuration samples against the new schemas only")
+		return nil, ""
+	}
+	schemas := map[definitionKey]*fixtures.Schema{}
+	for key, data := range files {
+		if data == nil {
+			continue
+		}
+		schema, err := fixtures.ParseSchema(data)
+		if err != nil {
+			logging.Debugf(ctx, "Unable to parse the %s %s schema at %s: %v", key.Platform, key.Type, tag, err)
+			continue
+		}
+		schemas[key] = schema
+	}
+	return schemas, tag
+}
+
+// previousSchemaFiles reads the schema files of the configuration definitions in the previous release, and returns
+// them with its tag
+// Definitions the previous release had without a schema map to nil; schemas in other repositories, encrypted
+// schemas and ones that can't be read are left out; without a previous release there are none and the tag is empty
+// Placeholders in the schema paths are expanded with the version of the previous release
+func previousSchemaFiles(ctx context.Context, workspace, agentType string) (map[definitionKey][]byte, string) {
+	path := config.GetConfigurationDefinitionsFilepath()
+	content, tag, err := github.PreviousReleaseFileFunc(ctx, workspace, path)
+	if err != nil || tag == "" || content == nil {
+		logging.Debugf(ctx, "No previous release of %s (%v)", path, err)
+		return nil, ""
+	}
+	var previous models.ConfigFile
+	if err := yaml.Unmarshal(content, &previous); err != nil {
+		logging.Debugf(ctx, "Unable to parse %s at %s: %v", path, tag, err)
+		return nil, ""
+	}
+
+	placeholders := config.Placeholders{Version: strings.TrimPrefix(tag, "v"), AgentType: agentType}
+	files := map[definitionKey][]byte{}
+	for _, definition := range previous.Configs {
+		typ, _ := definition["type"].(string)
+		platform, _ := definition["platform"].(string)
+		key := definitionKey{Type: typ, Platform: platform}
+		ref, _ := definition["schema"].(string)
+		ref = placeholders.Expand(ref)
+		if ref == "" {
+			files[key] = nil
+			continue
+		}
+		if _, isRemote, _ := github.ParseContentRef(ref); isRemote {
+			continue
+		}
+		schemaPath := filepath.Join(config.GetRootFolderForAgentRepo(), fileutil.NormalizePath(ref))
+		data, _, err := github.PreviousReleaseFileFunc(ctx, workspace, schemaPath)
+		if err != nil || data == nil || decrypt.Detect(schemaPath, data) != decrypt.None {
+			logging.Debugf(ctx, "Unable to read %s at %s", schemaPath, tag)
+			continue
+		}
+		files[key] = data
+	}
+	return files, tag
+}
+
+// checkPolicy evaluates the rules enabled in policy.yml against a release before it is submitted
+// Each result is recorded and failures are annotated on policy.yml; with strict-policy a failure blocks the release,
+// otherwise it only warns
+func checkPolicy(ctx context.Context, workspace string, release policy.Release) error {
+	path := config.GetPolicyFilepath()
+	cfg, err := policy.LoadConfig(filepath.Join(workspace, path))
+	if err != nil {
+		github.AddAnnotation(ctx, github.AnnotationFailure, path, "Invalid policy", err.Error())
+		return fmt.Errorf("invalid policy: %w", err)
+	}
+	if len(cfg.Rules) == 0 {
+		logging.Debug(ctx, "No policy rules enabled - skipping policy checks")
+		return nil
+	}
+
+	commits, err := github.GetCommitMessagesFunc(ctx)
+	if err != nil {
+		logging.Debugf(ctx, "Unable to read commit messages: %v - checking the policy without them", err)
+	}
+	release.Commits = commits
+
+	evaluated, err := policy.Evaluate(cfg, release)
+	if err != nil {
+		github.AddAnnotation(ctx, github.AnnotationFailure, path, "Invalid policy", err.Error())
+		return fmt.Errorf("invalid policy: %w", err)
+	}
+
+	strict := config.GetStrictPolicy()
+	level := github.AnnotationWarning
+	if strict {
+		level = github.AnnotationFailure
+	}
+	for _, result := range evaluated {
+		results.RecordPolicy(ctx, results.Policy{
+			AgentType: release.AgentType,
+			Version:   release.Version,
+			Rule:      result.Rule,
+			Outcome:   string(result.Outcome),
+			Message:   result.Message,
+		})
+		switch result.Outcome {
+		case policy.OutcomeFail:
+			if strict {
+				logging.Errorf(ctx, "Policy %s failed for %s %s: %s", result.Rule, release.AgentType, release.Version, result.Message)
+			} else {
+				logging.Warnf(ctx, "Policy %s failed for %s %s: %s", result.Rule, release.AgentType, release.Version, result.Message)
+			}
+			github.RecordAnnotation(ctx, github.Annotation{
+				Path:            path,
+				AnnotationLevel: level,
+				Title:           "Policy: " + result.Rule,
+				Message:         fmt.Sprintf("%s %s: %s", release.AgentType, release.Version, result.Message),
+				Rule:            policyRulePrefix + result.Rule,
+			})
+		default:
+			logging.Debugf(ctx, "Policy %s %s for %s %s: %s", result.Rule, result.Outcome, release.AgentType, release.Version, result.Message)
+		}
+	}
+
+	failed := policy.Count(evaluated, policy.OutcomeFail)
+	if failed == 0 {
+		logging.Noticef(ctx, "%s %s passed %d policy rule(s)", release.AgentType, release.Version, policy.Count(evaluated, policy.OutcomePass))
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("%s %s violates %d policy rule(s)", release.AgentType, release.Version, failed)
+	}
+	return nil
+}
+
+// checkRegoPolicies evaluates the assembled metadata and upload plan against the rego-policies input, if set
+// Matching rules are logged, annotated on the workflow file and listed in the job summary; a deny fails the run
+func checkRegoPolicies(ctx context.Context, workspace string, input rego.Input) error {
+	sources := rego.Sources(config.GetRegoPolicies())
+	if len(sources) == 0 {
+		return nil
+	}
+
+	decision, err := evaluateRegoFunc(ctx, workspace, sources, input)
+	if err != nil {
+		github.AddWorkflowAnnotation(ctx, github.AnnotationFailure, "Rego policies not evaluated", err.Error())
+		return err
+	}
+
+	for _, violation := range decision.Deny {
+		logging.Errorf(ctx, "Rego policy denied %s %s: %s", input.AgentType, input.Version, violation)
+		github.AddWorkflowAnnotation(ctx, github.AnnotationFailure, "Rego: "+violation.Rule, violation.Message)
+	}
+	for _, violation := range decision.Warn {
+		logging.Warnf(ctx, "Rego policy warning for %s %s: %s", input.AgentType, input.Version, violation)
+		github.AddWorkflowAnnotation(ctx, github.AnnotationWarning, "Rego: "+violation.Rule, violation.Message)
+	}
+	if err := github.AppendStepSummary(rego.Summary(input.AgentType, input.Version, decision)); err != nil {
+		logging.Warnf(ctx, "Unable to write Rego policy results to the job summary: %v", err)
+	}
+
+	if len(decision.Deny) > 0 {
+		return fmt.Errorf("%s %s denied by %d Rego policy rule(s)", input.AgentType, input.Version, len(decision.Deny))
+	}
+	logging.Noticef(ctx, "%s %s passed Rego policies", input.AgentType, input.Version)
+	return nil
+}
+
+// policyRulePrefix namespaces policy rule IDs among the other kinds of findings written to SARIF
+const policyRulePrefix = "policy/"
+
+// policySummary lists the policy rules each release was checked against for the check run summary
+func policySummary(evaluated []results.Policy) string {
+	if len(evaluated) == 0 {
+		return ""
+	}
+	lines := []string{"Policy evaluation:"}
+	for _, result := range evaluated {
+		lines = append(lines, fmt.Sprintf("- %s %s `%s`: %s - %s", result.AgentType, result.Version, result.Rule, result.Outcome, result.Message))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// lintRulePrefix namespaces lint rule IDs among the other kinds of findings written to SARIF
+const lintRulePrefix = "lint/"
+
+// annotateValidationError records each problem the instrumentation service found with rejected metadata
+// against the configuration definitions file; other errors are ignored
+func annotateValidationError(ctx context.Context, err error) {
+	var validationErr *client.ValidationError
+	if !errors.As(err, &validationErr) {
+		return
+	}
+	path := config.GetConfigurationDefinitionsFilepath()
+	if len(validationErr.Details) == 0 {
+		github.AddAnnotation(ctx, github.AnnotationFailure, path, "Metadata rejected", validationErr.Message)
+		return
+	}
+	for _, detail := range validationErr.Details {
+		github.AddAnnotation(ctx, github.AnnotationFailure, path, "Metadata rejected", detail.String())
+	}
+}
+
+// buildAgentMetadata loads the agent metadata described by the config directory of an agent repository
+func buildAgentMetadata(ctx context.Context, workspace, agentType, agentVersion string) (*models.AgentMetadata, error) {
+	placeholders := config.Placeholders{Version: agentVersion, AgentType: agentType}
+
+	// Load configuration definitions (required)
+	configs, err := loader.ReadConfigurationDefinitions(ctx, workspace, placeholders)
+	if err != nil {
+		logging.NoticeErrorWithCategory(ctx, err, "configuration.load", map[string]interface{}{
+			"error.operation": "load_configuration_definitions",
+			"agent.type":      agentType,
+			"agent.version":   agentVersion,
+			"workflow.type":   "agent",
+		})
+		github.AddAnnotation(ctx, github.AnnotationFailure, config.GetConfigurationDefinitionsFilepath(),
+			"Configuration definitions not loaded", err.Error())
+		return nil, fmt.Errorf("failed to read configuration definitions: %w", err)
+	}
+	logging.Noticef(ctx, "Loaded %d configuration definitions", len(configs))
+
+	// Load agent control definitions (optional)
+	agentControl, err := loader.ReadAgentControlDefinitions(ctx, workspace, placeholders)
+	if err != nil {
+		logging.Warnf(ctx, "Unable to load agent control definitions: %v - continuing without them", err)
+		agentControl = nil
+	} else {
+		logging.Noticef(ctx, "Loaded %d agent control definitions", len(agentControl))
+	}
+
+	// Load agent definition (optional)
+	agentDef, err := loader.ReadAgentDefinition(ctx, workspace)
+	if err != nil {
+		logging.Warnf(ctx, "Unable to load agent definition: %v - continuing without it", err)
+		agentDef = nil
+	} else if agentDef != nil {
+		logging.Notice(ctx, "Loaded agent definition")
+	}
+
+	// Build metadata
+	metadata := &models.AgentMetadata{
+		ConfigurationDefinitions: configs,
+		Metadata:                 loader.LoadMetadataForAgents(agentVersion),
+		AgentControlDefinitions:  agentControl,
+	}
+	if agentDef != nil {
+		metadata.Bindings = agentDef.Bindings
+		metadata.BreakingChange = agentDef.BreakingChange
+	}
+	results.RecordConfigs(ctx, results.Configs{
+		ConfigurationDefinitions: len(configs),
+		AgentControlDefinitions:  len(agentControl),
+		AgentDefinition:          agentDef != nil,
+	})
+
+	tags, err := loader.ParseTags(config.GetTags())
+	if err != nil {
+		logging.Warnf(ctx, "Unable to parse tags input: %v - continuing without tags", err)
+	} else if len(tags) > 0 {
+		metadata.Metadata["tags"] = tags
+	}
+
+	// Free text is cleaned before it is printed, exported or sent; invalid lengths are rejected by runFlow
+	maxLength, _ := config.GetMaxTextLength()
+	sanitize.Report(ctx, config.GetConfigurationDefinitionsFilepath(), sanitize.Definitions("configurationDefinitions", metadata.ConfigurationDefinitions, maxLength))
+	sanitize.Report(ctx, config.GetAgentControlDefinitionsFilepath(), sanitize.Definitions("agentControlDefinitions", metadata.AgentControlDefinitions, maxLength))
+	sanitize.Report(ctx, "", sanitize.Metadata(metadata.Metadata, maxLength))
+
+	return metadata, nil
+}
+
+// runDocsFlow handles the documentation repository workflow
+func runDocsFlow(ctx context.Context, client metadataClient) error {
+	logging.Debug(ctx, "Running documentation flow")
+	heartbeat.SetPhase(ctx, "docs")
+
+	// Load metadata from changed MDX files
+	metadataList, err := loader.LoadMetadataForDocs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load metadata from docs: %w", err)
+	}
+
+	if len(metadataList) == 0 {
+		logging.Notice(ctx, "No metadata changes detected")
+		return nil
+	}
+
+	logging.Noticef(ctx, "Processing %d metadata entries", len(metadataList))
+
+	// Send each metadata entry separately
+	successCount := 0
+	for _, entry := range metadataList {
+		if err := sendDocsMetadata(ctx, client, entry); err != nil {
+			logging.Errorf(ctx, "Failed to send metadata for %s: %s", entry.AgentType, hints.Explain(err.Error()))
+			continue
+		}
+		successCount++
+	}
+
+	logging.Noticef(ctx, "Successfully sent %d of %d metadata entries", successCount, len(metadataList))
+	return nil
+}
 
 // sendDocsMetadata sends a single docs metadata entry to the service
 func sendDocsMetadata(ctx context.Context, client metadataClient, entry loader.MetadataForDocs) error {
 	version, _ := entry.AgentMetadataFromDocs["version"].(string)
 
-	metadata := models.AgentMetadata{
-		Metadata: entry.AgentMetadataFromDocs,
+	metadata := models.AgentMetadata{
+		Metadata: entry.AgentMetadataFromDocs,
+	}
+
+	checkReleaseDate(ctx, config.GetWorkspace(), version, entry.AgentMetadataFromDocs, entry.SourceFile)
+
+	printJSON(ctx, fmt.Sprintf("Docs Metadata (%s %s)", entry.AgentType, version), entry.AgentMetadataFromDocs)
+
+	if err := exportMetadata(ctx, config.GetWorkspace(), entry.AgentType, version, &metadata); err != nil {
+		return err
+	}
+
+	release := policy.Release{Kind: policy.KindReleaseNotes, AgentType: entry.AgentType, Version: version, Metadata: entry.AgentMetadataFromDocs}
+	if err := checkPolicy(ctx, config.GetWorkspace(), release); err != nil {
+		return err
+	}
+
+	payload := results.Payload{
+		AgentType: entry.AgentType,
+		Version:   version,
+		Source:    github.RelativeToWorkspace(config.GetWorkspace(), entry.SourceFile),
+	}
+	if config.GetDryRun() {
+		results.RecordPayload(ctx, payload)
+		logging.Noticef(ctx, "Dry run - not sending metadata for %s version %s", entry.AgentType, version)
+		return nil
+	}
+
+	response, err := client.SubmitMetadata(ctx, entry.AgentType, version, &metadata)
+	if err != nil {
+		payload.Error = err.Error()
+		results.RecordPayload(ctx, payload)
+		return err
+	}
+	payload.ID = response.ID
+	payload.Submitted = true
+	results.RecordPayload(ctx, payload)
+
+	logging.Noticef(ctx, "Sent metadata for %s version %s", entry.AgentType, version)
+	return nil
+}
+
+// runReconcileFlow compares the metadata in the repository against the instrumentation service
+// and re-submits missing or drifted entries (unless dry-run is enabled)
+func runReconcileFlow(ctx context.Context, svc reconcile.MetadataService, workspace string) error {
+	dryRun := config.GetDryRun()
+	logging.Debugf(ctx, "Running reconcile flow (dry run: %t)", dryRun)
+
+	var targets []reconcile.Target
+	// Empty fields the policy doesn't send can't drift, so they aren't compared
+	emptyFields, _ := models.ParseEmptyFieldPolicy(config.GetEmptyFieldPolicy())
+
+	agentType := config.GetAgentType()
+	agentVersion := config.GetVersion()
+	if agentType != "" && agentVersion != "" {
+		if err := validateConfigDirectory(ctx, workspace); err != nil {
+			return fmt.Errorf("config directory validation failed: %w", err)
+		}
+		metadata, err := buildAgentMetadata(ctx, workspace, agentType, agentVersion)
+		if err != nil {
+			return err
+		}
+		metadata.Metadata = emptyFields.Metadata(metadata.Metadata)
+		targets = append(targets, reconcile.Target{
+			Source:    config.GetRootFolderForAgentRepo(),
+			AgentType: agentType,
+			Version:   agentVersion,
+			Metadata:  metadata,
+		})
+	}
+
+	if config.GetReconcileReleaseNotes() {
+		entries, err := loader.LoadAllMetadataForDocs(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load metadata from docs: %w", err)
+		}
+		for _, entry := range entries {
+			version, _ := entry.AgentMetadataFromDocs["version"].(string)
+			targets = append(targets, reconcile.Target{
+				Source:    github.RelativeToWorkspace(workspace, entry.SourceFile),
+				AgentType: entry.AgentType,
+				Version:   version,
+				Metadata:  &models.AgentMetadata{Metadata: emptyFields.Metadata(entry.AgentMetadataFromDocs)},
+			})
+		}
+	}
+
+	if len(targets) == 0 {
+		return fmt.Errorf("%s mode requires agent-type and version, or reconcile-release-notes, to select what to reconcile", modeReconcile)
+	}
+
+	logging.Noticef(ctx, "Reconciling %d metadata entries", len(targets))
+	reconciled := reconcile.Run(ctx, svc, targets, dryRun)
+	summary := reconcile.Report(ctx, reconciled, dryRun)
+	for _, r := range reconciled {
+		results.RecordPayload(ctx, results.Payload{
+			AgentType: r.Target.AgentType,
+			Version:   r.Target.Version,
+			Source:    r.Target.Source,
+			Status:    string(r.Status),
+			Submitted: r.Submitted,
+			Error:     r.Error,
+		})
+	}
+
+	if summary.Failed > 0 {
+		return fmt.Errorf("failed to reconcile %d of %d metadata entries", summary.Failed, len(targets))
+	}
+	return nil
+}
+
+// runPromoteFlow tags the signed manifest index pushed under the pending tag of the version input with the version,
+// and with latest if promote-latest is set
+// Metadata isn't submitted: the run that uploaded the binaries did that
+func runPromoteFlow(ctx context.Context) error {
+	version := config.GetVersion()
+	if version == "" || config.GetOCIRegistry() == "" {
+		return fmt.Errorf("%s mode requires version and oci-registry", modePromote)
+	}
+	ociConfig, err := oci.LoadRegistryConfig()
+	if err != nil {
+		return fmt.Errorf("error loading OCI config: %w", err)
+	}
+
+	var extraTags []string
+	if config.GetPromoteLatest() {
+		extraTags = append(extraTags, "latest")
+	}
+	if _, err := ociHandlePromotionFunc(ctx, &ociConfig, version, extraTags, config.GetDryRun()); err != nil {
+		return fmt.Errorf("promotion of %s failed: %w", version, err)
+	}
+	return nil
+}
+
+// runCopyFlow copies the signed manifest index of the version input, or of copy-digest, from copy-source to
+// oci-registry with everything it lists, tags it with the version and signs it for its new location
+// Nothing is rebuilt: the copy has the digests that were tested in the source registry
+func runCopyFlow(ctx context.Context) error {
+	version := config.GetVersion()
+	if version == "" || config.GetOCIRegistry() == "" || config.GetCopySource() == "" {
+		return fmt.Errorf("%s mode requires version, oci-registry and copy-source", modeCopy)
+	}
+	destination, err := oci.LoadRegistryConfig()
+	if err != nil {
+		return fmt.Errorf("error loading OCI config: %w", err)
+	}
+	source, err := oci.LoadCopySourceConfig()
+	if err != nil {
+		return fmt.Errorf("error loading OCI config: %w", err)
+	}
+
+	reference := version
+	if digest := config.GetCopyDigest(); digest != "" {
+		reference = digest
+	}
+	dryRun := config.GetDryRun()
+	indexDigest, err := ociHandleCopyFunc(ctx, &source, &destination, reference, version, dryRun)
+	if err != nil {
+		return fmt.Errorf("copy of %s failed: %w", version, err)
+	}
+	if dryRun {
+		return nil
+	}
+	return signIndex(ctx, destination.Registry, indexDigest, version)
+}
+
+// runResignFlow signs the manifest index tagged with the version input, or resign-digest, and every manifest it
+// lists again, without uploading anything, to recover releases uploaded while the signing service was failing
+// Every manifest is tried even if one fails; dry runs only list what would be signed
+func runResignFlow(ctx context.Context) error {
+	version := config.GetVersion()
+	if version == "" || config.GetOCIRegistry() == "" {
+		return fmt.Errorf("%s mode requires version and oci-registry", modeResign)
+	}
+	ociConfig, err := oci.LoadRegistryConfig()
+	if err != nil {
+		return fmt.Errorf("error loading OCI config: %w", err)
 	}
 
-	printJSON(ctx, fmt.Sprintf("Docs Metadata (%s %s)", entry.AgentType, version), entry.AgentMetadataFromDocs)
+	reference := version
+	if digest := config.GetResignDigest(); digest != "" {
+		reference = digest
+	}
+	target, err := ociHandleResignFunc(ctx, &ociConfig, reference, version)
+	if err != nil {
+		return fmt.Errorf("resign of %s failed: %w", reference, err)
+	}
+	indexDigest := target.Index.Digest.String()
+	if config.GetDryRun() {
+		logging.Noticef(ctx, "Dry run - not signing %s and the %d manifests it lists", indexDigest, len(target.Manifests))
+		return nil
+	}
 
-	if err := client.SendMetadata(ctx, entry.AgentType, version, &metadata); err != nil {
+	signature, err := signManifest(ctx, ociConfig.Registry, indexDigest, version)
+	if errors.Is(err, errSigningNotConfigured) {
 		return err
 	}
+	results.RecordSigning(ctx, signature.ID, err)
+	var failed []string
+	if err != nil {
+		failed = append(failed, fmt.Sprintf("index %s (%v)", indexDigest, err))
+	}
+	for _, manifest := range target.Manifests {
+		manifestDigest := manifest.Digest.String()
+		signature, err := signManifest(ctx, ociConfig.Registry, manifestDigest, version)
+		results.RecordManifestSigning(ctx, manifestDigest, signature.ID, err)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("manifest %s (%v)", manifestDigest, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to sign %d of %d manifests: %s", len(failed), len(target.Manifests)+1, strings.Join(failed, "; "))
+	}
+	logging.Noticef(ctx, "Signed %s and the %d manifests it lists", indexDigest, len(target.Manifests))
+	return nil
+}
 
-	logging.Noticef(ctx, "Sent metadata for %s version %s", entry.AgentType, version)
+// runMergeFlow uploads the binaries input and merges them into the manifest index already pushed for the version,
+// replacing the manifests listed for the same platforms, then signs the merged index, so a platform built on a
+// separate runner can be added after the release
+// Metadata isn't submitted again; dry runs only check the binaries, or plan their upload with upload-plan-file
+func runMergeFlow(ctx context.Context, workspace string) error {
+	version := config.GetVersion()
+	if version == "" || config.GetOCIRegistry() == "" || config.GetBinaries() == "" {
+		return fmt.Errorf("%s mode requires version, oci-registry and binaries", modeMerge)
+	}
+	ociConfig, err := oci.LoadConfig()
+	if err != nil {
+		github.AddWorkflowAnnotation(ctx, github.AnnotationFailure, "Invalid binaries input", err.Error())
+		return fmt.Errorf("error loading OCI config: %w", err)
+	}
+	ociConfig.MergeIndex = true
+
+	if config.GetDryRun() {
+		if ociConfig.PlanFile == "" {
+			logging.Noticef(ctx, "Dry run - not merging %d binaries into the manifest index of %s", len(ociConfig.Artifacts), version)
+			return nil
+		}
+		if _, err := ociHandlePlanFunc(ctx, &ociConfig, workspace, version); err != nil {
+			return fmt.Errorf("binary upload planning failed: %w", err)
+		}
+		return nil
+	}
+
+	indexDigest, err := ociHandleUploadsFunc(ctx, &ociConfig, workspace, version)
+	if err != nil {
+		return fmt.Errorf("merge into %s failed: %w", version, err)
+	}
+	if err := signIndex(ctx, ociConfig.Registry, indexDigest, oci.IndexTag(&ociConfig, version)); err != nil {
+		return err
+	}
+	if agentType := config.GetAgentType(); agentType != "" {
+		return writePins(ctx, workspace, agentType, version)
+	}
+	return nil
+}
+
+// runCollectFlow uploads the binaries input of one job of a build matrix without pushing a manifest index, and
+// writes the manifests it pushed to handoff-file for a final job to assemble into the index of the version
+// Nothing is signed; dry runs only check the binaries, or plan their upload with upload-plan-file
+func runCollectFlow(ctx context.Context, workspace string) error {
+	version := config.GetVersion()
+	handoffFile := config.GetHandoffFile()
+	if version == "" || config.GetOCIRegistry() == "" || config.GetBinaries() == "" || handoffFile == "" {
+		return fmt.Errorf("%s mode requires version, oci-registry, binaries and handoff-file", modeCollect)
+	}
+	handoffFile, err := workspaceRelativePath("handoff-file", handoffFile)
+	if err != nil {
+		return err
+	}
+	ociConfig, err := oci.LoadConfig()
+	if err != nil {
+		github.AddWorkflowAnnotation(ctx, github.AnnotationFailure, "Invalid binaries input", err.Error())
+		return fmt.Errorf("error loading OCI config: %w", err)
+	}
+	ociConfig.HandoffFile = handoffFile
+
+	if config.GetDryRun() {
+		if ociConfig.PlanFile == "" {
+			logging.Noticef(ctx, "Dry run - not uploading %d binaries for %s", len(ociConfig.Artifacts), version)
+			return nil
+		}
+		if _, err := ociHandlePlanFunc(ctx, &ociConfig, workspace, version); err != nil {
+			return fmt.Errorf("binary upload planning failed: %w", err)
+		}
+		return nil
+	}
+
+	if _, err := ociHandleUploadsFunc(ctx, &ociConfig, workspace, version); err != nil {
+		return fmt.Errorf("collect for %s failed: %w", version, err)
+	}
+	return nil
+}
+
+// runAssembleFlow pushes the manifest index of the version listing the artifacts in every handoff file in
+// handoff-directory, written by the collect jobs of a build matrix, then signs it
+// Metadata isn't submitted; dry runs only read and check the handoffs
+func runAssembleFlow(ctx context.Context, workspace string) error {
+	version := config.GetVersion()
+	handoffDir := config.GetHandoffDirectory()
+	if version == "" || config.GetOCIRegistry() == "" || handoffDir == "" {
+		return fmt.Errorf("%s mode requires version, oci-registry and handoff-directory", modeAssemble)
+	}
+	handoffDir, err := workspaceRelativePath("handoff-directory", handoffDir)
+	if err != nil {
+		return err
+	}
+	ociConfig, err := oci.LoadAssembleConfig()
+	if err != nil {
+		return fmt.Errorf("error loading OCI config: %w", err)
+	}
+
+	dryRun := config.GetDryRun()
+	indexDigest, err := ociHandleAssembleFunc(ctx, &ociConfig, workspace, handoffDir, version, dryRun)
+	if err != nil {
+		return fmt.Errorf("assemble of %s failed: %w", version, err)
+	}
+	if dryRun {
+		return nil
+	}
+	if err := signIndex(ctx, ociConfig.Registry, indexDigest, oci.IndexTag(&ociConfig, version)); err != nil {
+		return err
+	}
+	if agentType := config.GetAgentType(); agentType != "" {
+		return writePins(ctx, workspace, agentType, version)
+	}
+	return nil
+}
+
+// runImportFlow pushes the manifest index tagged with the version in the OCI layout directory oci-layout, prepared
+// by a build system, to the registry with the annotations of the action, then signs it and pins the version's
+// digests if agent-type is set
+func runImportFlow(ctx context.Context, workspace string) error {
+	version := config.GetVersion()
+	layoutDir := config.GetOCILayout()
+	if version == "" || config.GetOCIRegistry() == "" || layoutDir == "" {
+		return fmt.Errorf("%s mode requires version, oci-registry and oci-layout", modeImport)
+	}
+	layoutDir, err := workspaceRelativePath("oci-layout", layoutDir)
+	if err != nil {
+		return err
+	}
+	ociConfig, err := oci.LoadAssembleConfig()
+	if err != nil {
+		return fmt.Errorf("error loading OCI config: %w", err)
+	}
+
+	dryRun := config.GetDryRun()
+	indexDigest, err := ociHandleImportFunc(ctx, &ociConfig, workspace, layoutDir, version, dryRun)
+	if err != nil {
+		return fmt.Errorf("import of %s failed: %w", version, err)
+	}
+	if dryRun {
+		return nil
+	}
+	if err := signIndex(ctx, ociConfig.Registry, indexDigest, oci.IndexTag(&ociConfig, version)); err != nil {
+		return err
+	}
+	if agentType := config.GetAgentType(); agentType != "" {
+		return writePins(ctx, workspace, agentType, version)
+	}
+	return nil
+}
+
+// runInventoryFlow writes the versions of the registered agent types, or of inventory-agent-types, with their
+// release and EOL dates to inventory-file, leaving out those without an EOL date before eol-before if it is set
+func runInventoryFlow(ctx context.Context, lister inventory.Lister, workspace string) error {
+	inventoryFile := config.GetInventoryFile()
+	if inventoryFile == "" {
+		return fmt.Errorf("%s mode requires inventory-file", modeInventory)
+	}
+	inventoryFile, err := workspaceRelativePath("inventory-file", inventoryFile)
+	if err != nil {
+		return err
+	}
+	if _, err := inventory.FormatOf(inventoryFile); err != nil {
+		return fmt.Errorf("invalid inventory-file: %w", err)
+	}
+	filter := inventory.Filter{AgentTypes: config.GetInventoryAgentTypes()}
+	if eolBefore := config.GetEOLBefore(); eolBefore != "" {
+		date, err := models.ParseDate(eolBefore)
+		if err != nil {
+			return fmt.Errorf("invalid eol-before: %w", err)
+		}
+		filter.EOLBefore = date
+	}
+
+	entries, err := inventory.Collect(ctx, lister, filter)
+	if err != nil {
+		return err
+	}
+	if err := inventory.Write(filepath.Join(workspace, inventoryFile), entries); err != nil {
+		return err
+	}
+	agentTypes := map[string]bool{}
+	for _, entry := range entries {
+		agentTypes[entry.AgentType] = true
+	}
+	logging.Noticef(ctx, "Wrote %d versions of %d agent types to %s", len(entries), len(agentTypes), inventoryFile)
+	return nil
+}
+
+// runDiffFlow compares the metadata built from the repository for agent-type and version, field by field, against
+// the record the instrumentation service stores, to find drift and partial writes
+// Nothing is submitted: differences are printed, and reported as a warning rather than failing the run
+func runDiffFlow(ctx context.Context, svc reconcile.MetadataService, workspace string) error {
+	agentType := config.GetAgentType()
+	version := config.GetVersion()
+	if agentType == "" || version == "" {
+		return fmt.Errorf("%s mode requires agent-type and version", modeDiff)
+	}
+
+	if err := validateConfigDirectory(ctx, workspace); err != nil {
+		return fmt.Errorf("config directory validation failed: %w", err)
+	}
+	local, err := buildAgentMetadata(ctx, workspace, agentType, version)
+	if err != nil {
+		return err
+	}
+	// Empty fields the policy doesn't send aren't stored, so they aren't compared
+	emptyFields, _ := models.ParseEmptyFieldPolicy(config.GetEmptyFieldPolicy())
+	local.Metadata = emptyFields.Metadata(local.Metadata)
+
+	stored, err := svc.GetMetadata(ctx, agentType, version)
+	if err != nil {
+		return fmt.Errorf("failed to fetch the metadata of %s %s: %w", agentType, version, err)
+	}
+	status := reconcile.StatusMissing
+	var changes []reconcile.Change
+	if stored != nil {
+		// Definitions are compared in canonical order, as the service may store them in another order
+		if local, err = local.Canonical(); err != nil {
+			return err
+		}
+		if stored, err = stored.Canonical(); err != nil {
+			return err
+		}
+		if changes, err = reconcile.Compare(local, stored); err != nil {
+			return err
+		}
+		status = reconcile.StatusInSync
+		if len(changes) > 0 {
+			status = reconcile.StatusDrifted
+		}
+	}
+	results.RecordPayload(ctx, results.Payload{
+		AgentType: agentType,
+		Version:   version,
+		Source:    config.GetRootFolderForAgentRepo(),
+		Status:    string(status),
+	})
+
+	label := fmt.Sprintf("%s %s", agentType, version)
+	switch {
+	case status == reconcile.StatusMissing:
+		logging.Warnf(ctx, "The instrumentation service has no metadata for %s", label)
+		github.AddWorkflowAnnotation(ctx, github.AnnotationWarning, "Metadata missing", "The instrumentation service has no metadata for "+label)
+	case status == reconcile.StatusDrifted:
+		logging.Log(ctx, "group", fmt.Sprintf("Diff of %s (- service only, + repository only, ~ changed)", label))
+		// Plain output so each line shows inside the group rather than as an annotation
+		for _, change := range changes {
+			fmt.Println(change.String())
+		}
+		logging.Log(ctx, "endgroup", "")
+		logging.Warnf(ctx, "%d fields of %s differ between the repository and the instrumentation service", len(changes), label)
+		github.AddWorkflowAnnotation(ctx, github.AnnotationWarning, "Metadata drift",
+			fmt.Sprintf("%d fields of %s differ between the repository and the instrumentation service", len(changes), label))
+	default:
+		logging.Noticef(ctx, "The instrumentation service matches the repository for %s", label)
+	}
+	if err := github.AppendStepSummary(reconcile.CompareSummary(agentType, version, status, changes)); err != nil {
+		logging.Warnf(ctx, "Unable to write the metadata diff to the job summary: %v", err)
+	}
+	return nil
+}
+
+// runVerifyFlow checks a published release end to end: the instrumentation service has its metadata and, with
+// oci-registry set, the registry has its signed manifest index listing every platform, with the digests the
+// release run recorded in the verify-results file
+// Every check is run and reported, to the results file and job summary, before failing on any that didn't pass
+func runVerifyFlow(ctx context.Context, service reconcile.MetadataService, workspace string) error {
+	agentType := config.GetAgentType()
+	version := config.GetVersion()
+	if agentType == "" || version == "" {
+		return fmt.Errorf("%s mode requires agent-type and version", modeVerify)
+	}
+
+	in := verify.Input{AgentType: agentType, Version: version}
+	if resultsFile := config.GetVerifyResults(); resultsFile != "" {
+		resultsFile, err := workspaceRelativePath("verify-results", resultsFile)
+		if err != nil {
+			return err
+		}
+		released, err := results.Read(filepath.Join(workspace, resultsFile))
+		if err != nil {
+			return fmt.Errorf("failed to read verify-results: %w", err)
+		}
+		if (released.AgentType != "" && released.AgentType != agentType) || (released.Version != "" && released.Version != version) {
+			return fmt.Errorf("verify-results %s is the release of %s %s, not %s %s", resultsFile, released.AgentType, released.Version, agentType, version)
+		}
+		in.Released = &released
+	}
+
+	in.Metadata, in.MetadataErr = service.GetMetadata(ctx, agentType, version)
+	if config.GetOCIRegistry() != "" {
+		ociConfig, err := oci.LoadRegistryConfig()
+		if err != nil {
+			return fmt.Errorf("error loading OCI config: %w", err)
+		}
+		in.Registry = ociConfig.Registry
+		in.Release, in.ReleaseErr = ociInspectReleaseFunc(ctx, &ociConfig, version)
+	}
+
+	checks := verify.Verify(in)
+	for _, check := range checks {
+		results.RecordVerify(ctx, check)
+		if check.Passed {
+			logging.Noticef(ctx, "Verify %s passed: %s", check.Check, check.Detail)
+		} else {
+			logging.Errorf(ctx, "Verify %s failed: %s", check.Check, check.Detail)
+			github.AddWorkflowAnnotation(ctx, github.AnnotationFailure, "Verify: "+check.Check, check.Detail)
+		}
+	}
+	if err := github.AppendStepSummary(verify.Summary(agentType, version, checks)); err != nil {
+		logging.Warnf(ctx, "Unable to write verification results to the job summary: %v", err)
+	}
+
+	if failed := verify.Failed(checks); failed > 0 {
+		return fmt.Errorf("verification of %s %s failed: %d of %d checks did not pass", agentType, version, failed, len(checks))
+	}
+	logging.Noticef(ctx, "Verified %s %s: all %d checks passed", agentType, version, len(checks))
+	return nil
+}
+
+// runCleanupFlow deletes the prerelease manifest indexes in oci-registry created more than retention-days ago
+// Dry runs only list them
+func runCleanupFlow(ctx context.Context) error {
+	if config.GetOCIRegistry() == "" {
+		return fmt.Errorf("%s mode requires oci-registry", modeCleanup)
+	}
+	ociConfig, err := oci.LoadRegistryConfig()
+	if err != nil {
+		return fmt.Errorf("error loading OCI config: %w", err)
+	}
+	// Invalid retention is rejected by runFlow
+	days, _ := config.GetRetentionDays()
+	if err := ociHandleCleanupFunc(ctx, &ociConfig, time.Duration(days)*24*time.Hour, config.GetDryRun()); err != nil {
+		return fmt.Errorf("cleanup of %s failed: %w", ociConfig.Registry, err)
+	}
+	return nil
+}
+
+// runRollbackFlow undoes the operations the failed run that kept journal-file completed, latest first: the metadata
+// it stored is deleted from the instrumentation service, then the signatures, index and manifests it pushed are
+// deleted from oci-registry
+// Every operation is attempted and recorded before failing; dry runs only list them
+func runRollbackFlow(ctx context.Context, deleter metadataDeleter, workspace string) error {
+	journalFile := config.GetJournalFile()
+	if journalFile == "" {
+		return fmt.Errorf("%s mode requires journal-file, the journal of the run to roll back", modeRollback)
+	}
+	journalFile, err := workspaceRelativePath("journal-file", journalFile)
+	if err != nil {
+		return err
+	}
+	entries, err := journal.Read(filepath.Join(workspace, journalFile))
+	if err != nil {
+		return fmt.Errorf("failed to read journal-file: %w", err)
+	}
+	if len(entries) == 0 {
+		logging.Noticef(ctx, "The journal %s is empty - nothing to roll back", journalFile)
+		return nil
+	}
+
+	var metadata, registryEntries []journal.Entry
+	for _, entry := range entries {
+		if entry.Operation == journal.OperationMetadata {
+			metadata = append(metadata, entry)
+		} else {
+			registryEntries = append(registryEntries, entry)
+		}
+	}
+	var ociConfig models.OCIConfig
+	if len(registryEntries) > 0 {
+		if config.GetOCIRegistry() == "" {
+			return fmt.Errorf("the journal %s lists %d registry operations: %s mode requires oci-registry to undo them", journalFile, len(registryEntries), modeRollback)
+		}
+		if ociConfig, err = oci.LoadRegistryConfig(); err != nil {
+			return fmt.Errorf("error loading OCI config: %w", err)
+		}
+	}
+	logging.Noticef(ctx, "Rolling back %d operations journaled in %s", len(entries), journalFile)
+
+	dryRun := config.GetDryRun()
+	var failed []string
+	for i := len(metadata) - 1; i >= 0; i-- {
+		entry := metadata[i]
+		rollback := results.Rollback{Operation: entry.Operation, Target: entry.AgentType + " " + entry.Version}
+		if dryRun {
+			rollback.Detail = "dry run"
+			logging.Noticef(ctx, "Dry run - not deleting the metadata of %s version %s", entry.AgentType, entry.Version)
+			results.RecordRollback(ctx, rollback)
+			continue
+		}
+		deleted, err := deleter.DeleteMetadata(ctx, entry.AgentType, entry.Version)
+		switch {
+		case err != nil:
+			rollback.Error = err.Error()
+			failed = append(failed, rollback.Target)
+			logging.Errorf(ctx, "Failed to delete the metadata of %s version %s: %v", entry.AgentType, entry.Version, err)
+		case !deleted:
+			rollback.Detail = "not stored"
+			logging.Noticef(ctx, "No metadata is stored for %s version %s", entry.AgentType, entry.Version)
+		default:
+			rollback.Undone = true
+			rollback.Detail = "deleted"
+			logging.Noticef(ctx, "Deleted the metadata of %s version %s", entry.AgentType, entry.Version)
+		}
+		results.RecordRollback(ctx, rollback)
+	}
+
+	var registryErr error
+	if len(registryEntries) > 0 {
+		registryErr = ociHandleRollbackFunc(ctx, &ociConfig, registryEntries, dryRun)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("rollback failed to delete the metadata of %s", strings.Join(failed, ", "))
+	}
+	if registryErr != nil {
+		return fmt.Errorf("rollback failed: %w", registryErr)
+	}
+	return nil
+}
+
+// runBackfillFlow submits the agent metadata of the past releases selected by the backfill-versions input, reading
+// each from a worktree of its tag, several at a time
+// Binaries aren't uploaded; releases without a releaseDate input are dated by their tag
+func runBackfillFlow(ctx context.Context, client metadataClient, workspace string) error {
+	agentType := config.GetAgentType()
+	spec := config.GetBackfillVersions()
+	if agentType == "" || spec == "" {
+		return fmt.Errorf("%s mode requires agent-type and backfill-versions", modeBackfill)
+	}
+	if err := validateAgentType(ctx, client, agentType); err != nil {
+		return err
+	}
+
+	tags, err := github.ListTagsFunc(ctx, workspace)
+	if err != nil {
+		return fmt.Errorf("failed to list tags: %w", err)
+	}
+	releases, err := backfill.Resolve(spec, tags)
+	if err != nil {
+		return fmt.Errorf("invalid backfill-versions: %w", err)
+	}
+
+	dryRun := config.GetDryRun()
+	// Invalid concurrency is rejected by runFlow
+	concurrency, _ := config.GetBackfillConcurrency()
+	logging.Noticef(ctx, "Backfilling %d versions of %s, %d at a time", len(releases), agentType, concurrency)
+	backfilled := backfill.Run(ctx, releases, concurrency, func(ctx context.Context, release backfill.Release) (string, error) {
+		return backfillRelease(ctx, client, workspace, agentType, release, dryRun)
+	})
+	summary := backfill.Report(ctx, backfilled, dryRun)
+	for _, r := range backfilled {
+		results.RecordPayload(ctx, results.Payload{
+			AgentType: agentType,
+			Version:   r.Release.Version,
+			Source:    "tag " + r.Release.Tag,
+			ID:        r.ID,
+			Submitted: r.Error == "" && !dryRun,
+			Error:     r.Error,
+		})
+	}
+
+	if summary.Failed > 0 {
+		return fmt.Errorf("failed to backfill %d of %d versions", summary.Failed, len(releases))
+	}
+	return nil
+}
+
+// backfillRelease reads the agent metadata of a release from a worktree of its tag and submits it, returning the
+// submission ID
+func backfillRelease(ctx context.Context, client metadataClient, workspace, agentType string, release backfill.Release, dryRun bool) (string, error) {
+	dir, cleanup, err := github.CheckoutTagFunc(ctx, workspace, release.Tag)
+	if err != nil {
+		return "", fmt.Errorf("failed to check out tag %s: %w", release.Tag, err)
+	}
+	defer cleanup()
+
+	if err := validateConfigDirectory(ctx, dir); err != nil {
+		return "", fmt.Errorf("config directory validation failed: %w", err)
+	}
+	metadata, err := buildAgentMetadata(ctx, dir, agentType, release.Version)
+	if err != nil {
+		return "", err
+	}
+	if config.GetReleaseDate() == "" {
+		date, _, err := github.VersionDateFunc(ctx, workspace, release.Version, "")
+		if err != nil {
+			logging.Warnf(ctx, "Unable to date version %s: %v - submitting it without a releaseDate", release.Version, err)
+		} else if !date.IsZero() {
+			metadata.Metadata["releaseDate"] = date.UTC().Format(models.DateLayout)
+		}
+	}
+	printJSON(ctx, fmt.Sprintf("Agent Metadata (%s)", release.Version), metadata)
+
+	if dryRun {
+		return "", nil
+	}
+	response, err := client.SubmitMetadata(ctx, agentType, release.Version, metadata)
+	if err != nil {
+		annotateValidationError(ctx, err)
+		return "", fmt.Errorf("failed to send metadata: %w", err)
+	}
+	return response.ID, nil
+}
+
+// exportMetadata writes the resolved metadata to the export-directory input, if set
+func exportMetadata(ctx context.Context, workspace, agentType, agentVersion string, metadata *models.AgentMetadata) error {
+	exportDir := config.GetExportDirectory()
+	if exportDir == "" {
+		return nil
+	}
+	exportDir, err := workspaceRelativePath("export-directory", exportDir)
+	if err != nil {
+		return err
+	}
+
+	path, err := export.Write(filepath.Join(workspace, exportDir), agentType, agentVersion, metadata)
+	if err != nil {
+		return fmt.Errorf("failed to export metadata: %w", err)
+	}
+
+	logging.Noticef(ctx, "Exported metadata for %s version %s to %s", agentType, agentVersion, github.RelativeToWorkspace(workspace, path))
+	return nil
+}
+
+// writeSchemaDocs renders the schema of each configuration definition as Markdown to the schema-docs-directory
+// input, if set, and to the job summary, for documentation writers to copy from the release run
+// Definitions whose schema isn't a JSON or YAML object are left out with a warning
+func writeSchemaDocs(ctx context.Context, workspace, agentType, agentVersion string, definitions []models.ConfigurationDefinition) error {
+	docsDir := config.GetSchemaDocsDirectory()
+	if docsDir == "" {
+		return nil
+	}
+	docsDir, err := workspaceRelativePath("schema-docs-directory", docsDir)
+	if err != nil {
+		return err
+	}
+
+	var docs []schemadoc.Document
+	for _, definition := range definitions {
+		typ, _ := definition["type"].(string)
+		platform, _ := definition["platform"].(string)
+		encoded, _ := definition["schema"].(string)
+		schema, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || encoded == "" {
+			logging.Warnf(ctx, "Not documenting the %s %s configuration: it has no schema", platform, typ)
+			continue
+		}
+		doc, err := schemadoc.Render(agentType, agentVersion, typ, platform, schema)
+		if err != nil {
+			logging.Warnf(ctx, "Not documenting the %s %s configuration: %v", platform, typ, err)
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	if len(docs) == 0 {
+		logging.Noticef(ctx, "No configuration schemas of %s version %s to document", agentType, agentVersion)
+		return nil
+	}
+
+	paths, err := schemadoc.Write(filepath.Join(workspace, docsDir), agentType, agentVersion, docs)
+	if err != nil {
+		return fmt.Errorf("failed to write schema docs: %w", err)
+	}
+	relative := make([]string, len(paths))
+	for i, path := range paths {
+		relative[i] = github.RelativeToWorkspace(workspace, path)
+	}
+	results.RecordSchemaDocs(ctx, relative)
+	logging.Noticef(ctx, "Documented %d configuration schemas of %s version %s in %s", len(docs), agentType, agentVersion, strings.Join(relative, ", "))
+
+	summary := fmt.Sprintf("## Configuration of %s %s\n\n", agentType, agentVersion)
+	for _, doc := range docs {
+		summary += doc.Markdown + "\n"
+	}
+	if err := github.AppendStepSummary(summary); err != nil {
+		logging.Warnf(ctx, "Unable to write the schema docs to the job summary: %v", err)
+	}
 	return nil
 }
 
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,13 +10,31 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"agent-metadata-action/internal/client"
+	"agent-metadata-action/internal/config"
+	"agent-metadata-action/internal/export"
 	"agent-metadata-action/internal/github"
+	"agent-metadata-action/internal/journal"
 	"agent-metadata-action/internal/loader"
+	"agent-metadata-action/internal/metrics"
+	"agent-metadata-action/internal/mockserver"
 	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/oci"
+	"agent-metadata-action/internal/policy"
+	"agent-metadata-action/internal/preflight"
+	"agent-metadata-action/internal/rego"
+	"agent-metadata-action/internal/results"
+	"agent-metadata-action/internal/retry"
+	"agent-metadata-action/internal/sign"
 	"agent-metadata-action/internal/testutil"
 
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -23,27 +42,86 @@ import (
 // mockMetadataClient is a mock implementation for testing
 type mockMetadataClient struct{}
 
-func (m *mockMetadataClient) SendMetadata(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata) error {
+func (m *mockMetadataClient) SubmitMetadata(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata) (models.SubmissionResponse, error) {
 	// Mock implementation - does nothing, returns success
-	return nil
+	return models.SubmissionResponse{}, nil
+}
+
+// ListAgentTypes fails so the agent type is only checked against the built-in list
+func (m *mockMetadataClient) ListAgentTypes(ctx context.Context) ([]string, error) {
+	return nil, assert.AnError
 }
 
 type mockFailingMetadataClient struct{}
 
-func (m *mockFailingMetadataClient) SendMetadata(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata) error {
-	return assert.AnError
+func (m *mockFailingMetadataClient) SubmitMetadata(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata) (models.SubmissionResponse, error) {
+	return models.SubmissionResponse{}, assert.AnError
+}
+
+func (m *mockFailingMetadataClient) ListAgentTypes(ctx context.Context) ([]string, error) {
+	return nil, assert.AnError
 }
 
 type mockSelectiveFailClient struct {
 	callCount *int
 }
 
-func (m *mockSelectiveFailClient) SendMetadata(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata) error {
+func (m *mockSelectiveFailClient) ListAgentTypes(ctx context.Context) ([]string, error) {
+	return nil, assert.AnError
+}
+
+func (m *mockSelectiveFailClient) SubmitMetadata(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata) (models.SubmissionResponse, error) {
 	*m.callCount++
 	if *m.callCount == 1 {
-		return assert.AnError
+		return models.SubmissionResponse{}, assert.AnError
 	}
-	return nil
+	return models.SubmissionResponse{}, nil
+}
+
+// mockIncrementalClient records full and incremental submissions, returning baseline as the stored metadata
+type mockIncrementalClient struct {
+	mockMetadataClient
+	baseline    *models.AgentMetadata
+	full        []*models.AgentMetadata
+	incremental []*models.AgentMetadata
+	removed     []models.RemovedDefinitions
+}
+
+func (m *mockIncrementalClient) SubmitMetadata(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata) (models.SubmissionResponse, error) {
+	m.full = append(m.full, metadata)
+	return models.SubmissionResponse{}, nil
+}
+
+func (m *mockIncrementalClient) GetMetadata(ctx context.Context, agentType string, agentVersion string) (*models.AgentMetadata, error) {
+	return m.baseline, nil
+}
+
+func (m *mockIncrementalClient) SubmitIncremental(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata, removed models.RemovedDefinitions) (models.SubmissionResponse, error) {
+	m.incremental = append(m.incremental, metadata)
+	m.removed = append(m.removed, removed)
+	return models.SubmissionResponse{}, nil
+}
+
+// mockBackfillClient records the release date each version is submitted with, failing the versions in fail
+type mockBackfillClient struct {
+	mockMetadataClient
+	mu           sync.Mutex
+	fail         map[string]bool
+	releaseDates map[string]interface{}
+	definitions  map[string][]models.ConfigurationDefinition // by version, when set
+}
+
+func (m *mockBackfillClient) SubmitMetadata(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata) (models.SubmissionResponse, error) {
+	if m.fail[agentVersion] {
+		return models.SubmissionResponse{}, assert.AnError
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.releaseDates[agentVersion] = metadata.Metadata["releaseDate"]
+	if m.definitions != nil {
+		m.definitions[agentVersion] = metadata.ConfigurationDefinitions
+	}
+	return models.SubmissionResponse{ID: "id-" + agentVersion}, nil
 }
 
 // createSuccessfulUploadResult creates a mock successful upload result
@@ -213,6 +291,29 @@ func TestRun_InvalidEnvironment(t *testing.T) {
 	assert.Contains(t, err.Error(), "workspace directory does not exist")
 }
 
+func TestRun_InvalidRegion(t *testing.T) {
+	t.Setenv("GITHUB_WORKSPACE", t.TempDir())
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("INPUT_REGION", "apac")
+
+	err := run(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid region "apac": must be one of [eu gov us]`)
+}
+
+func TestRun_StagingNotAllowed(t *testing.T) {
+	t.Setenv("GITHUB_WORKSPACE", t.TempDir())
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("GITHUB_REPOSITORY", "someone/agent")
+	t.Setenv("INPUT_ENVIRONMENT", "staging")
+
+	err := run(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `environment staging is not enabled for repository "someone/agent"`)
+}
+
 func TestRun_InvalidMonitoringType(t *testing.T) {
 	originalCreateClient := createMetadataClientFunc
 	createMetadataClientFunc = func(baseURL, token string) metadataClient {
@@ -234,6 +335,113 @@ func TestRun_InvalidMonitoringType(t *testing.T) {
 	assert.Contains(t, err.Error(), "must be APM or INFRA")
 }
 
+func TestRun_InvalidPayloadVersion(t *testing.T) {
+	originalCreateClient := createMetadataClientFunc
+	createMetadataClientFunc = func(baseURL, token string) metadataClient {
+		return &mockMetadataClient{}
+	}
+	defer func() { createMetadataClientFunc = originalCreateClient }()
+
+	workspace := t.TempDir()
+	t.Setenv("GITHUB_WORKSPACE", workspace)
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("INPUT_AGENT_TYPE", "java")
+	t.Setenv("INPUT_VERSION", "1.0.0")
+	t.Setenv("INPUT_PAYLOAD_VERSION", "v9")
+
+	err := run(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid payload-version "v9": must be auto or one of [v1 v2 v3]`)
+}
+
+func TestRun_InvalidCompressionThreshold(t *testing.T) {
+	workspace := t.TempDir()
+	t.Setenv("GITHUB_WORKSPACE", workspace)
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("INPUT_COMPRESSION_THRESHOLD", "1MB")
+
+	err := run(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid compression-threshold "1MB"`)
+}
+
+func TestRun_InvalidEmptyFieldPolicy(t *testing.T) {
+	workspace := t.TempDir()
+	t.Setenv("GITHUB_WORKSPACE", workspace)
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("INPUT_EMPTY_FIELD_POLICY", "drop")
+
+	err := run(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid empty-field-policy: invalid empty field policy "drop"`)
+}
+
+func TestRun_InvalidMaxTextLength(t *testing.T) {
+	workspace := t.TempDir()
+	t.Setenv("GITHUB_WORKSPACE", workspace)
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("INPUT_MAX_TEXT_LENGTH", "-1")
+
+	err := run(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid max-text-length "-1"`)
+}
+
+func TestRun_InvalidReleaseDate(t *testing.T) {
+	workspace := t.TempDir()
+	t.Setenv("GITHUB_WORKSPACE", workspace)
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("INPUT_RELEASE_DATE", "2024-13-45")
+
+	err := run(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid release-date: "2024-13-45" is not a valid date`)
+}
+
+func TestRun_InvalidReleaseDateWindow(t *testing.T) {
+	workspace := t.TempDir()
+	t.Setenv("GITHUB_WORKSPACE", workspace)
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("INPUT_RELEASE_DATE_WINDOW", "-1")
+
+	err := run(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid release-date-window "-1"`)
+}
+
+func TestRun_InvalidSubmission(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected string
+	}{
+		{name: "unknown submission", env: map[string]string{"INPUT_SUBMISSION": "partial"}, expected: `invalid submission "partial": must be full or incremental`},
+		{name: "unknown baseline", env: map[string]string{"INPUT_INCREMENTAL_BASELINE": "git"}, expected: `invalid incremental-baseline "git": must be service or snapshot`},
+		{name: "snapshot without export directory", env: map[string]string{"INPUT_SUBMISSION": "incremental", "INPUT_INCREMENTAL_BASELINE": "snapshot"}, expected: "incremental-baseline snapshot requires export-directory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GITHUB_WORKSPACE", t.TempDir())
+			t.Setenv("NEWRELIC_TOKEN", "mock-token")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			err := run(nil)
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
+
 func TestRun_ValidMonitoringTypes(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -491,7 +699,7 @@ func TestRunAgentFlow_AgentControlDefinitionsError(t *testing.T) {
 	// Create valid configurationDefinitions.yml
 	configFile := filepath.Join(fleetControlPath, "configurationDefinitions.yml")
 	configContent := `configurationDefinitions:
-  - name: test-config
+  - platform: all
     type: string
 `
 	require.NoError(t, os.WriteFile(configFile, []byte(configContent), 0644))
@@ -809,22 +1017,21 @@ func TestRunAgentFlow_SigningDisabled_OCINotEnabled(t *testing.T) {
 	assert.NotContains(t, stderrStr, "::error::")
 }
 
-func TestRunAgentFlow_SigningSkipped_AllUploadsFailed(t *testing.T) {
-	// Override metadata client with mock
+func TestRunAgentFlow_LayoutOnly_NotSigned(t *testing.T) {
 	originalCreateClient := createMetadataClientFunc
 	createMetadataClientFunc = func(baseURL, token string) metadataClient {
 		return &mockMetadataClient{}
 	}
 	defer func() { createMetadataClientFunc = originalCreateClient }()
 
-	// Mock OCI handler to return error (fail-fast behavior)
+	var uploaded *models.OCIConfig
 	originalOCIHandler := ociHandleUploadsFunc
 	ociHandleUploadsFunc = func(ctx context.Context, cfg *models.OCIConfig, workspace, version string) (string, error) {
-		return "", fmt.Errorf("artifact upload failed for linux-tar: upload error")
+		uploaded = cfg
+		return "sha256:index123", nil
 	}
 	defer func() { ociHandleUploadsFunc = originalOCIHandler }()
 
-	// Create mock signing service that should NOT be called
 	requestCount := 0
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		requestCount++
@@ -832,7 +1039,45 @@ func TestRunAgentFlow_SigningSkipped_AllUploadsFailed(t *testing.T) {
 	}))
 	defer server.Close()
 
-	// Setup workspace and environment variables
+	projectRoot, err := filepath.Abs("../..")
+	require.NoError(t, err)
+	workspace := filepath.Join(projectRoot, "integration-test", "agent-flow")
+
+	t.Setenv("GITHUB_WORKSPACE", workspace)
+	t.Setenv("NEWRELIC_TOKEN", "test-token")
+	t.Setenv("INPUT_OCI_LAYOUT", "oci-layout")
+	t.Setenv("INPUT_BINARIES", `[{"name":"linux-tar","path":"./dist/agent.tar.gz","os":"linux","arch":"amd64","format":"tar+gzip"}]`)
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
+	t.Setenv("SIGNING_SERVICE_URL", server.URL)
+	getStdout, _ := testutil.CaptureOutput(t)
+
+	// method under test
+	err = runAgentFlow(context.Background(), &mockMetadataClient{}, workspace, "java", "1.2.3")
+
+	require.NoError(t, err)
+	require.NotNil(t, uploaded)
+	assert.Equal(t, "oci-layout", uploaded.Layout)
+	assert.Empty(t, uploaded.Registry)
+	assert.Equal(t, 0, requestCount, "Nothing is signed without a registry")
+	assert.Contains(t, getStdout(), "No oci-registry - the manifest index written to oci-layout isn't signed")
+}
+
+func TestRunAgentFlow_Phases(t *testing.T) {
+	uploads := 0
+	originalOCIHandler := ociHandleUploadsFunc
+	ociHandleUploadsFunc = func(ctx context.Context, cfg *models.OCIConfig, workspace, version string) (string, error) {
+		uploads++
+		return "sha256:index123", nil
+	}
+	defer func() { ociHandleUploadsFunc = originalOCIHandler }()
+
+	signings := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signings++
+		w.Write([]byte(`{"success": true}`))
+	}))
+	defer server.Close()
+
 	projectRoot, err := filepath.Abs("../..")
 	require.NoError(t, err)
 	workspace := filepath.Join(projectRoot, "integration-test", "agent-flow")
@@ -844,26 +1089,429 @@ func TestRunAgentFlow_SigningSkipped_AllUploadsFailed(t *testing.T) {
 	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
 	t.Setenv("SIGNING_SERVICE_URL", server.URL)
 
-	// Capture output
-	getStdout, _ := testutil.CaptureOutput(t)
+	t.Run("metadata only", func(t *testing.T) {
+		uploads, signings = 0, 0
+		t.Setenv("INPUT_PHASES", "metadata")
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := runAgentFlow(context.Background(), &mockFailingMetadataClient{}, workspace, "java", "1.2.3")
+
+		assert.ErrorContains(t, err, "failed to send metadata", "The metadata is submitted")
+		assert.Zero(t, uploads)
+		assert.Zero(t, signings)
+		assert.Contains(t, getStdout(), "The upload phase isn't selected - not uploading 1 binaries")
+	})
+
+	t.Run("upload only", func(t *testing.T) {
+		uploads, signings = 0, 0
+		t.Setenv("INPUT_PHASES", "upload")
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := runAgentFlow(context.Background(), &mockFailingMetadataClient{}, workspace, "java", "1.2.3")
+
+		require.NoError(t, err, "The metadata isn't submitted")
+		assert.Equal(t, 1, uploads)
+		assert.Zero(t, signings)
+		stdout := getStdout()
+		assert.Contains(t, stdout, "The sign phase isn't selected - the manifest index sha256:index123 is left for a run with the sign phase to sign")
+		assert.Contains(t, stdout, "results-file isn't set")
+	})
+
+	t.Run("upload and sign", func(t *testing.T) {
+		uploads, signings = 0, 0
+		t.Setenv("INPUT_PHASES", "upload,sign")
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := runAgentFlow(context.Background(), &mockFailingMetadataClient{}, workspace, "java", "1.2.3")
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, uploads)
+		assert.Equal(t, 1, signings)
+	})
+
+	t.Run("sign without the upload requires phase-results", func(t *testing.T) {
+		uploads, signings = 0, 0
+		t.Setenv("INPUT_PHASES", "sign")
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := runAgentFlow(context.Background(), &mockFailingMetadataClient{}, workspace, "java", "1.2.3")
+
+		assert.ErrorContains(t, err, "the sign phase without the upload phase requires phase-results")
+		assert.Zero(t, uploads)
+		assert.Zero(t, signings)
+	})
+}
 
-	// Execute
-	ctx := context.Background()
-	mockClient := &mockMetadataClient{}
-	err = runAgentFlow(ctx, mockClient, workspace, "java", "1.2.3")
+func TestSignUploadedIndex(t *testing.T) {
+	var signed []models.SigningRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var signingReq models.SigningRequest
+		json.NewDecoder(r.Body).Decode(&signingReq)
+		signed = append(signed, signingReq)
+		w.Write([]byte(`{"success": true, "id": "sig-1"}`))
+	}))
+	defer server.Close()
 
-	// Verify error (fail-fast behavior)
-	require.Error(t, err)
-	assert.Contains(t, err.Error(), "binary upload failed")
+	t.Setenv("NEWRELIC_TOKEN", "test-token")
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
+	t.Setenv("SIGNING_SERVICE_URL", server.URL)
+	t.Setenv("INPUT_PHASE_RESULTS", "upload/results.json")
+	testutil.CaptureOutput(t)
+
+	writeUploadResults := func(t *testing.T, agentType string, index *results.Index) string {
+		workspace := t.TempDir()
+		recorder := results.NewRecorder()
+		recorder.SetRun(results.Run{AgentType: agentType, Version: "1.2.3"})
+		ctx := results.WithRecorder(context.Background(), recorder)
+		results.RecordArtifacts(ctx, []models.ArtifactUploadResult{{Name: "linux-amd64", OS: "linux", Arch: "amd64", Digest: "sha256:linux", Uploaded: true}})
+		if index != nil {
+			results.RecordIndex(ctx, index.Registry, index.Tag, index.Digest)
+		}
+		require.NoError(t, recorder.Write(filepath.Join(workspace, "upload", "results.json")))
+		return workspace
+	}
+	index := &results.Index{Registry: "docker.io/newrelic/agents", Tag: "1.2.3-pending", Digest: "sha256:index"}
 
-	outputStr := getStdout()
+	t.Run("signs the index the upload recorded", func(t *testing.T) {
+		signed = nil
+		workspace := writeUploadResults(t, "java", index)
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
 
-	// Verify NO signing requests were made
-	assert.Equal(t, 0, requestCount, "Should have made 0 signing requests")
-	assert.NotContains(t, outputStr, "Starting manifest index signing")
+		// method under test
+		err := signUploadedIndex(ctx, workspace, "java", "1.2.3", false)
+
+		require.NoError(t, err)
+		assert.Equal(t, []models.SigningRequest{{Registry: "docker.io", Repository: "newrelic/agents", Tag: "1.2.3-pending", Digest: "sha256:index"}}, signed)
+		recorded := recorder.Results()
+		assert.True(t, recorded.Index.Signed)
+		require.Len(t, recorded.Artifacts, 1)
+		assert.True(t, recorded.Artifacts[0].Signed)
+	})
+
+	t.Run("dry run signs nothing", func(t *testing.T) {
+		signed = nil
+		workspace := writeUploadResults(t, "java", index)
+
+		// method under test
+		err := signUploadedIndex(context.Background(), workspace, "java", "1.2.3", true)
+
+		require.NoError(t, err)
+		assert.Empty(t, signed)
+	})
+
+	t.Run("results of another release", func(t *testing.T) {
+		workspace := writeUploadResults(t, "dotnet", index)
+
+		// method under test
+		err := signUploadedIndex(context.Background(), workspace, "java", "1.2.3", false)
+
+		assert.EqualError(t, err, "phase-results upload/results.json is the release of dotnet 1.2.3, not java 1.2.3")
+	})
+
+	t.Run("results without an index", func(t *testing.T) {
+		workspace := writeUploadResults(t, "java", nil)
+
+		// method under test
+		err := signUploadedIndex(context.Background(), workspace, "java", "1.2.3", false)
+
+		assert.EqualError(t, err, "phase-results upload/results.json records no manifest index in a registry to sign")
+	})
+
+	t.Run("rejects directory traversal", func(t *testing.T) {
+		t.Setenv("INPUT_PHASE_RESULTS", "../results.json")
+
+		// method under test
+		err := signUploadedIndex(context.Background(), t.TempDir(), "java", "1.2.3", false)
+
+		assert.ErrorContains(t, err, "invalid phase-results ../results.json")
+	})
 }
 
-func TestRunAgentFlow_SigningError_ServiceFailure(t *testing.T) {
+func TestRunAgentFlow_Resume(t *testing.T) {
+	var uploaded []models.ArtifactDefinition
+	originalOCIHandler := ociHandleUploadsFunc
+	ociHandleUploadsFunc = func(ctx context.Context, cfg *models.OCIConfig, workspace, version string) (string, error) {
+		uploaded = append([]models.ArtifactDefinition(nil), cfg.Artifacts...)
+		results.RecordRegistry(ctx, results.Registry{URL: cfg.Registry})
+		results.RecordArtifacts(ctx, []models.ArtifactUploadResult{createSuccessfulUploadResult("linux-tar", "sha256:abc", version)})
+		results.RecordIndex(ctx, cfg.Registry, version, "sha256:index123")
+		return "sha256:index123", nil
+	}
+	defer func() { ociHandleUploadsFunc = originalOCIHandler }()
+
+	tagged, signed := "sha256:index123", false
+	originalInspect := ociInspectReleaseFunc
+	ociInspectReleaseFunc = func(ctx context.Context, ociConfig *models.OCIConfig, tag string) (oci.Release, error) {
+		return oci.Release{ResignTarget: oci.ResignTarget{Index: ocispec.Descriptor{Digest: digest.Digest(tagged)}}, Signed: signed}, nil
+	}
+	defer func() { ociInspectReleaseFunc = originalInspect }()
+
+	signings := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signings++
+		w.Write([]byte(`{"success": true, "id": "sig-2"}`))
+	}))
+	defer server.Close()
+
+	projectRoot, err := filepath.Abs("../..")
+	require.NoError(t, err)
+	source := filepath.Join(projectRoot, "integration-test", "agent-flow")
+
+	t.Setenv("NEWRELIC_TOKEN", "test-token")
+	t.Setenv("INPUT_OCI_REGISTRY", "docker.io/newrelic/agents")
+	t.Setenv("INPUT_BINARIES", `[{"name":"linux-tar","path":"./dist/agent.tar.gz","os":"linux","arch":"amd64","format":"tar+gzip"},{"name":"windows-zip","path":"./dist/agent.zip","os":"windows","arch":"amd64","format":"zip"}]`)
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
+	t.Setenv("GITHUB_RUN_ID", "42")
+	t.Setenv("SIGNING_SERVICE_URL", server.URL)
+	t.Setenv("INPUT_RESUME_RESULTS", "release/results.json")
+
+	// attempt runs the release in workspace, returning what it recorded and the metadata it submitted
+	attempt := func(t *testing.T, workspace string) (results.Results, int, error) {
+		uploaded, signings = nil, 0
+		t.Setenv("GITHUB_WORKSPACE", workspace)
+		recorder := results.NewRecorder()
+		recorder.SetRun(results.Run{AgentType: "java", Version: "1.2.3", RunID: "42"})
+		ctx := results.WithRecorder(context.Background(), recorder)
+		client := &mockIncrementalClient{}
+		err := runAgentFlow(ctx, client, workspace, "java", "1.2.3")
+		return recorder.Results(), len(client.full), err
+	}
+	newWorkspace := func(t *testing.T) string {
+		workspace := t.TempDir()
+		require.NoError(t, os.CopyFS(workspace, os.DirFS(source)))
+		return workspace
+	}
+	writeEarlier := func(t *testing.T, workspace string, earlier results.Results) {
+		data, err := json.Marshal(earlier)
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Join(workspace, "release"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(workspace, "release", "results.json"), data, 0644))
+	}
+	run := results.Run{AgentType: "java", Version: "1.2.3", RunID: "42"}
+
+	t.Run("skips everything the earlier attempt completed", func(t *testing.T) {
+		testutil.CaptureOutput(t)
+		workspace := newWorkspace(t)
+		first, submissions, err := attempt(t, workspace)
+		require.NoError(t, err)
+		require.Equal(t, 1, submissions)
+		writeEarlier(t, workspace, first)
+		tagged, signed = "sha256:index123", true
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		recorded, submissions, err := attempt(t, workspace)
+
+		require.NoError(t, err)
+		assert.Nil(t, uploaded, "Nothing is uploaded")
+		assert.Zero(t, signings, "The index is signed")
+		assert.Zero(t, submissions, "The same metadata was submitted")
+		require.NotNil(t, recorded.Index)
+		assert.Equal(t, "sha256:index123", recorded.Index.Digest)
+		assert.True(t, recorded.Index.Signed)
+		require.Len(t, recorded.Artifacts, 1)
+		require.Len(t, recorded.Payloads, 1)
+		assert.True(t, recorded.Payloads[0].Submitted)
+		assert.Equal(t, first.Payloads[0].Digest, recorded.Payloads[0].Digest)
+		stdout := getStdout()
+		assert.Contains(t, stdout, "Resuming: the earlier attempt pushed the manifest index docker.io/newrelic/agents:1.2.3 (sha256:index123) - not uploading 1 binaries again")
+		assert.Contains(t, stdout, "Resuming: the earlier attempt sent the same metadata for java version 1.2.3")
+	})
+
+	t.Run("signs an index the earlier attempt left unsigned", func(t *testing.T) {
+		testutil.CaptureOutput(t)
+		workspace := newWorkspace(t)
+		writeEarlier(t, workspace, results.Results{
+			Run:       run,
+			Registry:  &results.Registry{URL: "docker.io/newrelic/agents"},
+			Index:     &results.Index{Registry: "docker.io/newrelic/agents", Tag: "1.2.3", Digest: "sha256:index123", SigningError: "status 503"},
+			Artifacts: []results.Artifact{{Name: "linux-tar", OS: "linux", Arch: "amd64", Digest: "sha256:abc", Uploaded: true}},
+		})
+		tagged, signed = "sha256:index123", false
+
+		// method under test
+		recorded, submissions, err := attempt(t, workspace)
+
+		require.NoError(t, err)
+		assert.Nil(t, uploaded)
+		assert.Equal(t, 1, signings)
+		assert.Equal(t, 1, submissions, "The earlier attempt didn't submit the metadata")
+		require.NotNil(t, recorded.Index)
+		assert.True(t, recorded.Index.Signed)
+		assert.Equal(t, "sig-2", recorded.Index.SignatureID)
+		assert.Empty(t, recorded.Index.SigningError, "The signing error of the earlier attempt isn't carried over")
+	})
+
+	t.Run("uploads again when the tag moved", func(t *testing.T) {
+		workspace := newWorkspace(t)
+		writeEarlier(t, workspace, results.Results{
+			Run:   run,
+			Index: &results.Index{Registry: "docker.io/newrelic/agents", Tag: "1.2.3", Digest: "sha256:index123"},
+		})
+		tagged = "sha256:other"
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		_, _, err := attempt(t, workspace)
+
+		require.NoError(t, err)
+		assert.Len(t, uploaded, 2)
+		assert.Contains(t, getStdout(), "docker.io/newrelic/agents:1.2.3 points to sha256:other, not sha256:index123 as pushed by the earlier attempt - uploading again")
+	})
+
+	// writeBinary writes the linux-tar binary to workspace, returning its layer digest
+	writeBinary := func(t *testing.T, workspace, content string) string {
+		require.NoError(t, os.MkdirAll(filepath.Join(workspace, "dist"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(workspace, "dist", "agent.tar.gz"), []byte(content), 0644))
+		return digest.FromString(content).String()
+	}
+
+	t.Run("references the binaries the earlier attempt uploaded", func(t *testing.T) {
+		testutil.CaptureOutput(t)
+		workspace := newWorkspace(t)
+		layerDigest := writeBinary(t, workspace, "linux binary")
+		writeEarlier(t, workspace, results.Results{
+			Run:      run,
+			Registry: &results.Registry{URL: "docker.io/newrelic/agents"},
+			Artifacts: []results.Artifact{
+				{Name: "linux-tar", OS: "linux", Arch: "amd64", Digest: "sha256:abc", LayerDigest: layerDigest, Uploaded: true},
+				{Name: "windows-zip", OS: "windows", Arch: "amd64", Error: "upload failed"},
+			},
+		})
+
+		// method under test
+		_, _, err := attempt(t, workspace)
+
+		require.NoError(t, err)
+		require.Len(t, uploaded, 2)
+		assert.Equal(t, "sha256:abc", uploaded[0].Digest, "The uploaded binary is referenced")
+		assert.Empty(t, uploaded[1].Digest, "The failed binary is uploaded")
+		assert.Equal(t, "./dist/agent.zip", uploaded[1].Path)
+	})
+
+	t.Run("uploads a binary rebuilt since the earlier attempt", func(t *testing.T) {
+		workspace := newWorkspace(t)
+		layerDigest := writeBinary(t, workspace, "linux binary")
+		rebuiltDigest := writeBinary(t, workspace, "rebuilt linux binary")
+		writeEarlier(t, workspace, results.Results{
+			Run:       run,
+			Registry:  &results.Registry{URL: "docker.io/newrelic/agents"},
+			Artifacts: []results.Artifact{{Name: "linux-tar", OS: "linux", Arch: "amd64", Digest: "sha256:abc", LayerDigest: layerDigest, Uploaded: true}},
+		})
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		_, _, err := attempt(t, workspace)
+
+		require.NoError(t, err)
+		require.Len(t, uploaded, 2)
+		assert.Empty(t, uploaded[0].Digest, "The rebuilt binary is uploaded")
+		assert.Contains(t, getStdout(), fmt.Sprintf("Resuming: linux-tar has changed since the earlier attempt uploaded it (%s, not %s) - uploading it again", rebuiltDigest, layerDigest))
+	})
+
+	t.Run("sends changed metadata again", func(t *testing.T) {
+		testutil.CaptureOutput(t)
+		workspace := newWorkspace(t)
+		writeEarlier(t, workspace, results.Results{
+			Run:      run,
+			Payloads: []results.Payload{{AgentType: "java", Version: "1.2.3", Digest: "sha256:before", Submitted: true}},
+		})
+
+		// method under test
+		_, submissions, err := attempt(t, workspace)
+
+		require.NoError(t, err)
+		assert.Equal(t, 1, submissions)
+	})
+
+	t.Run("results of another run resume nothing", func(t *testing.T) {
+		workspace := newWorkspace(t)
+		writeEarlier(t, workspace, results.Results{
+			Run:       results.Run{AgentType: "java", Version: "1.2.3", RunID: "41"},
+			Registry:  &results.Registry{URL: "docker.io/newrelic/agents"},
+			Artifacts: []results.Artifact{{Name: "linux-tar", OS: "linux", Arch: "amd64", Digest: "sha256:abc", Uploaded: true}},
+		})
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		_, _, err := attempt(t, workspace)
+
+		require.NoError(t, err)
+		require.Len(t, uploaded, 2)
+		assert.Empty(t, uploaded[0].Digest)
+		assert.Contains(t, getStdout(), `resume-results release/results.json is of workflow run "41", not this run "42" - running every step`)
+	})
+
+	t.Run("first attempt runs everything", func(t *testing.T) {
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		_, submissions, err := attempt(t, newWorkspace(t))
+
+		require.NoError(t, err)
+		assert.Len(t, uploaded, 2)
+		assert.Equal(t, 1, submissions)
+		assert.Contains(t, getStdout(), "No resume-results file release/results.json - running every step")
+	})
+
+	t.Run("results of another release", func(t *testing.T) {
+		testutil.CaptureOutput(t)
+		workspace := newWorkspace(t)
+		writeEarlier(t, workspace, results.Results{Run: results.Run{AgentType: "dotnet", Version: "1.2.3", RunID: "42"}})
+
+		// method under test
+		_, _, err := attempt(t, workspace)
+
+		assert.EqualError(t, err, "resume-results release/results.json is the release of dotnet 1.2.3, not java 1.2.3")
+	})
+
+	t.Run("rejects directory traversal", func(t *testing.T) {
+		testutil.CaptureOutput(t)
+		t.Setenv("INPUT_RESUME_RESULTS", "../results.json")
+
+		// method under test
+		_, _, err := attempt(t, newWorkspace(t))
+
+		assert.ErrorContains(t, err, "invalid resume-results ../results.json")
+	})
+}
+
+func TestSelectedPhases(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]bool
+		err      string
+	}{
+		{name: "all by default", input: "", expected: map[string]bool{phaseMetadata: true, phaseUpload: true, phaseSign: true}},
+		{name: "one phase", input: "metadata", expected: map[string]bool{phaseMetadata: true}},
+		{name: "separated by commas and newlines", input: "upload,\nsign", expected: map[string]bool{phaseUpload: true, phaseSign: true}},
+		{name: "unknown phase", input: "upload,publish", err: `invalid phases "upload,publish": each must be metadata, upload or sign`},
+		{name: "no phase", input: " , ", err: "invalid phases: select at least one of metadata, upload and sign"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("INPUT_PHASES", tt.input)
+
+			// method under test
+			phases, err := selectedPhases()
+
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, phases)
+		})
+	}
+}
+
+func TestRunAgentFlow_SigningSkipped_AllUploadsFailed(t *testing.T) {
 	// Override metadata client with mock
 	originalCreateClient := createMetadataClientFunc
 	createMetadataClientFunc = func(baseURL, token string) metadataClient {
@@ -871,19 +1519,18 @@ func TestRunAgentFlow_SigningError_ServiceFailure(t *testing.T) {
 	}
 	defer func() { createMetadataClientFunc = originalCreateClient }()
 
-	// Mock OCI handler to return index digest
+	// Mock OCI handler to return error (fail-fast behavior)
 	originalOCIHandler := ociHandleUploadsFunc
 	ociHandleUploadsFunc = func(ctx context.Context, cfg *models.OCIConfig, workspace, version string) (string, error) {
-		return "sha256:index123", nil
+		return "", fmt.Errorf("artifact upload failed for linux-tar: upload error")
 	}
 	defer func() { ociHandleUploadsFunc = originalOCIHandler }()
 
-	// Create mock signing service that always returns 500
+	// Create mock signing service that should NOT be called
 	requestCount := 0
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		requestCount++
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"error": "internal server error"}`))
+		w.WriteHeader(http.StatusOK)
 	}))
 	defer server.Close()
 
@@ -907,7 +1554,62 @@ func TestRunAgentFlow_SigningError_ServiceFailure(t *testing.T) {
 	mockClient := &mockMetadataClient{}
 	err = runAgentFlow(ctx, mockClient, workspace, "java", "1.2.3")
 
-	// Verify error
+	// Verify error (fail-fast behavior)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "binary upload failed")
+
+	outputStr := getStdout()
+
+	// Verify NO signing requests were made
+	assert.Equal(t, 0, requestCount, "Should have made 0 signing requests")
+	assert.NotContains(t, outputStr, "Starting manifest index signing")
+}
+
+func TestRunAgentFlow_SigningError_ServiceFailure(t *testing.T) {
+	// Override metadata client with mock
+	originalCreateClient := createMetadataClientFunc
+	createMetadataClientFunc = func(baseURL, token string) metadataClient {
+		return &mockMetadataClient{}
+	}
+	defer func() { createMetadataClientFunc = originalCreateClient }()
+
+	// Mock OCI handler to return index digest
+	originalOCIHandler := ociHandleUploadsFunc
+	ociHandleUploadsFunc = func(ctx context.Context, cfg *models.OCIConfig, workspace, version string) (string, error) {
+		return "sha256:index123", nil
+	}
+	defer func() { ociHandleUploadsFunc = originalOCIHandler }()
+
+	// Create mock signing service that always returns 500
+	requestCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "internal server error"}`))
+	}))
+	defer server.Close()
+
+	// Setup workspace and environment variables
+	projectRoot, err := filepath.Abs("../..")
+	require.NoError(t, err)
+	workspace := filepath.Join(projectRoot, "integration-test", "agent-flow")
+
+	t.Setenv("GITHUB_WORKSPACE", workspace)
+	t.Setenv("NEWRELIC_TOKEN", "test-token")
+	t.Setenv("INPUT_OCI_REGISTRY", "docker.io/newrelic/agents")
+	t.Setenv("INPUT_BINARIES", `[{"name":"linux-tar","path":"./dist/agent.tar.gz","os":"linux","arch":"amd64","format":"tar+gzip"}]`)
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
+	t.Setenv("SIGNING_SERVICE_URL", server.URL)
+
+	// Capture output
+	getStdout, _ := testutil.CaptureOutput(t)
+
+	// Execute
+	ctx := context.Background()
+	mockClient := &mockMetadataClient{}
+	err = runAgentFlow(ctx, mockClient, workspace, "java", "1.2.3")
+
+	// Verify error
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "artifact signing failed")
 
@@ -919,3 +1621,2969 @@ func TestRunAgentFlow_SigningError_ServiceFailure(t *testing.T) {
 	assert.Contains(t, outputStr, "Signing attempt 2 failed")
 	assert.Contains(t, outputStr, "Failed to sign manifest index")
 }
+
+func TestRunAgentFlow_SigningError_NotRequired(t *testing.T) {
+	originalOCIHandler := ociHandleUploadsFunc
+	ociHandleUploadsFunc = func(ctx context.Context, cfg *models.OCIConfig, workspace, version string) (string, error) {
+		results.RecordArtifacts(ctx, []models.ArtifactUploadResult{createSuccessfulUploadResult("linux-tar", "sha256:abc", version)})
+		results.RecordIndex(ctx, cfg.Registry, version, "sha256:index123")
+		return "sha256:index123", nil
+	}
+	defer func() { ociHandleUploadsFunc = originalOCIHandler }()
+
+	// Signing service rejects the request, which isn't retried
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "bad request"}`))
+	}))
+	defer server.Close()
+
+	projectRoot, err := filepath.Abs("../..")
+	require.NoError(t, err)
+	source := filepath.Join(projectRoot, "integration-test", "agent-flow")
+	workspace := t.TempDir()
+	require.NoError(t, os.CopyFS(workspace, os.DirFS(source)))
+
+	t.Setenv("GITHUB_WORKSPACE", workspace)
+	t.Setenv("NEWRELIC_TOKEN", "test-token")
+	t.Setenv("INPUT_OCI_REGISTRY", "docker.io/newrelic/agents")
+	t.Setenv("INPUT_BINARIES", `[{"name":"linux-tar","path":"./dist/agent.tar.gz","os":"linux","arch":"amd64","format":"tar+gzip"}]`)
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
+	t.Setenv("GITHUB_RUN_ID", "42")
+	t.Setenv("SIGNING_SERVICE_URL", server.URL)
+	t.Setenv("INPUT_SIGNING_REQUIRED", "false")
+
+	recorder := results.NewRecorder()
+	ctx := results.WithRecorder(context.Background(), recorder)
+	getStdout, _ := testutil.CaptureOutput(t)
+
+	// method under test
+	err = runAgentFlow(ctx, &mockMetadataClient{}, workspace, "java", "1.2.3")
+
+	require.NoError(t, err)
+	assert.Contains(t, getStdout(), "was uploaded but not signed")
+	recorded := recorder.Results()
+	require.NotNil(t, recorded.Index)
+	assert.False(t, recorded.Index.Signed)
+	assert.Contains(t, recorded.Index.SigningError, "status 400")
+	assert.Equal(t, sign.ResignTaskFile, recorded.Index.ResignTask)
+	require.Len(t, recorded.Payloads, 1)
+	assert.True(t, recorded.Payloads[0].Submitted)
+
+	content, err := os.ReadFile(filepath.Join(workspace, sign.ResignTaskFile))
+	require.NoError(t, err)
+	var task sign.ResignTask
+	require.NoError(t, json.Unmarshal(content, &task))
+	assert.Equal(t, "docker.io/newrelic/agents", task.Registry)
+	assert.Equal(t, "1.2.3", task.Tag)
+	assert.Equal(t, "sha256:index123", task.Digest)
+	assert.Equal(t, "newrelic/agent-metadata-action", task.Repository)
+	assert.Equal(t, "42", task.RunID)
+}
+
+func TestReportValidationCheck(t *testing.T) {
+	tests := []struct {
+		name               string
+		token              string
+		runErr             error
+		annotations        []github.Annotation
+		publishErr         error
+		expectPublish      bool
+		expectedConclusion string
+		expectedOutput     string
+	}{
+		{
+			name:           "skipped without GitHub token",
+			expectPublish:  false,
+			expectedOutput: "skipping check run",
+		},
+		{
+			name:               "success with no findings",
+			token:              "gh-token",
+			expectPublish:      true,
+			expectedConclusion: github.ConclusionSuccess,
+			expectedOutput:     "Published Agent Metadata Validation check run (success)",
+		},
+		{
+			name:  "neutral with warnings",
+			token: "gh-token",
+			annotations: []github.Annotation{
+				{Path: ".fleetControl/configurationDefinitions.yml", AnnotationLevel: github.AnnotationWarning, Message: "schema missing"},
+			},
+			expectPublish:      true,
+			expectedConclusion: github.ConclusionNeutral,
+		},
+		{
+			name:               "failure on run error",
+			token:              "gh-token",
+			runErr:             assert.AnError,
+			expectPublish:      true,
+			expectedConclusion: github.ConclusionFailure,
+		},
+		{
+			name:               "publish error only warns",
+			token:              "gh-token",
+			publishErr:         assert.AnError,
+			expectPublish:      true,
+			expectedConclusion: github.ConclusionSuccess,
+			expectedOutput:     "::warn::Unable to publish Agent Metadata Validation check run",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("INPUT_GITHUB_TOKEN", tt.token)
+			t.Setenv("GITHUB_REPOSITORY", "newrelic/test-agent")
+			t.Setenv("GITHUB_SHA", "abc123")
+
+			published := false
+			originalPublish := publishCheckRunFunc
+			publishCheckRunFunc = func(ctx context.Context, repo, sha, conclusion, summary string, annotations []github.Annotation) (string, error) {
+				published = true
+				assert.Equal(t, "newrelic/test-agent", repo)
+				assert.Equal(t, "abc123", sha)
+				assert.Equal(t, tt.expectedConclusion, conclusion)
+				assert.Len(t, annotations, len(tt.annotations))
+				return "https://github.com/checks/1", tt.publishErr
+			}
+			defer func() { publishCheckRunFunc = originalPublish }()
+
+			collector := github.NewAnnotationCollector()
+			for _, a := range tt.annotations {
+				collector.Add(a)
+			}
+
+			getStdout, _ := testutil.CaptureOutput(t)
+			reportValidationCheck(context.Background(), collector, tt.runErr)
+			stdout := getStdout()
+
+			assert.Equal(t, tt.expectPublish, published)
+			if tt.expectedOutput != "" {
+				assert.Contains(t, stdout, tt.expectedOutput)
+			}
+		})
+	}
+}
+
+// mockReconcileService records submissions and has no stored metadata
+type mockReconcileService struct {
+	submitted []string
+}
+
+func (m *mockReconcileService) ListVersions(ctx context.Context, agentType string) ([]string, error) {
+	return []string{}, nil
+}
+
+func (m *mockReconcileService) GetMetadata(ctx context.Context, agentType string, agentVersion string) (*models.AgentMetadata, error) {
+	return nil, nil
+}
+
+func (m *mockReconcileService) SendMetadata(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata) error {
+	m.submitted = append(m.submitted, agentType+" "+agentVersion)
+	return nil
+}
+
+func TestRunReconcileFlow(t *testing.T) {
+	projectRoot, err := filepath.Abs("../..")
+	require.NoError(t, err)
+
+	t.Run("release notes dry run", func(t *testing.T) {
+		workspace := filepath.Join(projectRoot, "integration-test", "docs-flow")
+		t.Setenv("GITHUB_WORKSPACE", workspace)
+		t.Setenv("INPUT_RECONCILE_RELEASE_NOTES", "true")
+		t.Setenv("INPUT_DRY_RUN", "true")
+
+		svc := &mockReconcileService{}
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := runReconcileFlow(context.Background(), svc, workspace)
+
+		require.NoError(t, err)
+		assert.Empty(t, svc.submitted)
+		assert.Contains(t, getStdout(), "Reconciliation (dry run) complete: 0 in sync, 3 missing, 0 drifted, 0 failed")
+	})
+
+	t.Run("agent repository", func(t *testing.T) {
+		workspace := filepath.Join(projectRoot, "integration-test", "agent-flow")
+		t.Setenv("GITHUB_WORKSPACE", workspace)
+		t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+		t.Setenv("INPUT_VERSION", "1.2.3")
+
+		svc := &mockReconcileService{}
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := runReconcileFlow(context.Background(), svc, workspace)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"NRJavaAgent 1.2.3"}, svc.submitted)
+		assert.Contains(t, getStdout(), "Re-submitted missing NRJavaAgent 1.2.3 (.fleetControl)")
+	})
+
+	t.Run("nothing selected", func(t *testing.T) {
+		// method under test
+		err := runReconcileFlow(context.Background(), &mockReconcileService{}, t.TempDir())
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "reconcile mode requires agent-type and version")
+	})
+}
+
+func TestRunDiffFlow(t *testing.T) {
+	projectRoot, err := filepath.Abs("../..")
+	require.NoError(t, err)
+	workspace := filepath.Join(projectRoot, "integration-test", "agent-flow")
+	t.Setenv("GITHUB_WORKSPACE", workspace)
+	t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+	t.Setenv("INPUT_VERSION", "1.2.3")
+	t.Setenv("GITHUB_STEP_SUMMARY", filepath.Join(t.TempDir(), "summary.md"))
+	local, err := buildAgentMetadata(context.Background(), workspace, "NRJavaAgent", "1.2.3")
+	require.NoError(t, err)
+
+	t.Run("in sync", func(t *testing.T) {
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := runDiffFlow(ctx, &mockVerifyService{metadata: local}, workspace)
+
+		require.NoError(t, err)
+		assert.Contains(t, getStdout(), "The instrumentation service matches the repository for NRJavaAgent 1.2.3")
+		assert.Equal(t, "in-sync", recorder.Results().Payloads[0].Status)
+	})
+
+	t.Run("drifted", func(t *testing.T) {
+		stored := *local
+		stored.Metadata = models.Metadata{}
+		for k, v := range local.Metadata {
+			stored.Metadata[k] = v
+		}
+		stored.Metadata["version"] = "1.2.2"
+		stored.Metadata["stale"] = "left over"
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := runDiffFlow(ctx, &mockVerifyService{metadata: &stored}, workspace)
+
+		require.NoError(t, err)
+		stdout := getStdout()
+		assert.Contains(t, stdout, `~ metadata.version: "1.2.2" -> "1.2.3"`)
+		assert.Contains(t, stdout, `- metadata.stale: "left over"`)
+		assert.Contains(t, stdout, "2 fields of NRJavaAgent 1.2.3 differ")
+		assert.Equal(t, "drifted", recorder.Results().Payloads[0].Status)
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := runDiffFlow(ctx, &mockVerifyService{}, workspace)
+
+		require.NoError(t, err)
+		assert.Contains(t, getStdout(), "has no metadata for NRJavaAgent 1.2.3")
+		assert.Equal(t, "missing", recorder.Results().Payloads[0].Status)
+	})
+
+	t.Run("requires agent type and version", func(t *testing.T) {
+		t.Setenv("INPUT_VERSION", "")
+
+		// method under test
+		err := runDiffFlow(context.Background(), &mockVerifyService{}, workspace)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "diff mode requires agent-type and version")
+	})
+}
+
+// mockInventoryLister lists one release per agent type
+type mockInventoryLister struct{}
+
+func (m *mockInventoryLister) ListAgentTypes(ctx context.Context) ([]string, error) {
+	return []string{"NRJavaAgent", "NRNodeAgent"}, nil
+}
+
+func (m *mockInventoryLister) ListReleases(ctx context.Context, agentType string) ([]models.ReleaseSummary, error) {
+	if agentType == "NRJavaAgent" {
+		return []models.ReleaseSummary{{Version: "8.0.0", ReleaseDate: "2024-01-01", EOL: "2026-01-01"}}, nil
+	}
+	return []models.ReleaseSummary{{Version: "12.0.0", ReleaseDate: "2025-01-01"}}, nil
+}
+
+func TestRunInventoryFlow(t *testing.T) {
+	t.Run("writes every registered version", func(t *testing.T) {
+		workspace := t.TempDir()
+		t.Setenv("INPUT_INVENTORY_FILE", "reports/inventory.csv")
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := runInventoryFlow(context.Background(), &mockInventoryLister{}, workspace)
+
+		require.NoError(t, err)
+		data, err := os.ReadFile(filepath.Join(workspace, "reports", "inventory.csv"))
+		require.NoError(t, err)
+		assert.Equal(t, "agentType,version,releaseDate,eol\nNRJavaAgent,8.0.0,2024-01-01,2026-01-01\nNRNodeAgent,12.0.0,2025-01-01,\n", string(data))
+		assert.Contains(t, getStdout(), "Wrote 2 versions of 2 agent types to reports/inventory.csv")
+	})
+
+	t.Run("filters by eol", func(t *testing.T) {
+		workspace := t.TempDir()
+		t.Setenv("INPUT_INVENTORY_FILE", "inventory.json")
+		t.Setenv("INPUT_EOL_BEFORE", "2026-06-01")
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := runInventoryFlow(context.Background(), &mockInventoryLister{}, workspace)
+
+		require.NoError(t, err)
+		data, err := os.ReadFile(filepath.Join(workspace, "inventory.json"))
+		require.NoError(t, err)
+		assert.JSONEq(t, `[{"agentType":"NRJavaAgent","version":"8.0.0","releaseDate":"2024-01-01","eol":"2026-01-01"}]`, string(data))
+	})
+
+	tests := []struct {
+		name     string
+		file     string
+		before   string
+		expected string
+	}{
+		{name: "requires a file", expected: "inventory mode requires inventory-file"},
+		{name: "directory traversal", file: "../inventory.csv", expected: "without directory traversal"},
+		{name: "unknown format", file: "inventory.txt", expected: "must have a .csv or .json extension"},
+		{name: "invalid date", file: "inventory.csv", before: "June 2026", expected: "invalid eol-before"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("INPUT_INVENTORY_FILE", tt.file)
+			t.Setenv("INPUT_EOL_BEFORE", tt.before)
+
+			// method under test
+			err := runInventoryFlow(context.Background(), &mockInventoryLister{}, t.TempDir())
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
+
+func TestRunFlow_InvalidMode(t *testing.T) {
+	t.Setenv("INPUT_MODE", "resync")
+
+	// method under test
+	err := runFlow(context.Background(), t.TempDir(), "token")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid mode "resync": must be empty, reconcile, backfill,`)
+	assert.Contains(t, err.Error(), "diff, inventory or rollback")
+}
+
+func TestRunPromoteFlow(t *testing.T) {
+	var promoted *models.OCIConfig
+	var promotedVersion string
+	var promotedTags []string
+	originalPromotion := ociHandlePromotionFunc
+	ociHandlePromotionFunc = func(ctx context.Context, ociConfig *models.OCIConfig, version string, extraTags []string, dryRun bool) (string, error) {
+		promoted, promotedVersion, promotedTags = ociConfig, version, extraTags
+		return "sha256:index", nil
+	}
+	defer func() { ociHandlePromotionFunc = originalPromotion }()
+
+	t.Setenv("INPUT_VERSION", "1.2.3")
+	t.Setenv("INPUT_OCI_REGISTRY", "ghcr.io/newrelic/agents")
+
+	t.Run("promotes the version", func(t *testing.T) {
+		// method under test
+		require.NoError(t, runPromoteFlow(context.Background()))
+
+		assert.Equal(t, "ghcr.io/newrelic/agents", promoted.Registry)
+		assert.Equal(t, "1.2.3", promotedVersion)
+		assert.Empty(t, promotedTags)
+	})
+
+	t.Run("promotes to latest too", func(t *testing.T) {
+		t.Setenv("INPUT_PROMOTE_LATEST", "true")
+
+		// method under test
+		require.NoError(t, runPromoteFlow(context.Background()))
+
+		assert.Equal(t, []string{"latest"}, promotedTags)
+	})
+
+	t.Run("requires a registry", func(t *testing.T) {
+		t.Setenv("INPUT_OCI_REGISTRY", "")
+
+		// method under test
+		err := runPromoteFlow(context.Background())
+
+		assert.ErrorContains(t, err, "promote mode requires version and oci-registry")
+	})
+
+	t.Run("reports failed promotions", func(t *testing.T) {
+		ociHandlePromotionFunc = func(ctx context.Context, ociConfig *models.OCIConfig, version string, extraTags []string, dryRun bool) (string, error) {
+			return "", fmt.Errorf("ghcr.io/newrelic/agents:1.2.3-pending (sha256:index) isn't signed: only signed indexes are promoted")
+		}
+
+		// method under test
+		err := runPromoteFlow(context.Background())
+
+		assert.ErrorContains(t, err, "promotion of 1.2.3 failed: ghcr.io/newrelic/agents:1.2.3-pending (sha256:index) isn't signed")
+	})
+}
+
+func TestRunCopyFlow(t *testing.T) {
+	var copiedFrom, copiedTo *models.OCIConfig
+	var copiedReference string
+	originalCopy := ociHandleCopyFunc
+	ociHandleCopyFunc = func(ctx context.Context, source, destination *models.OCIConfig, reference, version string, dryRun bool) (string, error) {
+		copiedFrom, copiedTo, copiedReference = source, destination, reference
+		return "sha256:index123", nil
+	}
+	defer func() { ociHandleCopyFunc = originalCopy }()
+
+	var signed []models.SigningRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var signingReq models.SigningRequest
+		json.NewDecoder(r.Body).Decode(&signingReq)
+		signed = append(signed, signingReq)
+		w.Write([]byte(`{"success": true}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("INPUT_VERSION", "1.2.3")
+	t.Setenv("INPUT_OCI_REGISTRY", "docker.io/newrelic/agents")
+	t.Setenv("INPUT_COPY_SOURCE", "staging.example.com/agents")
+	t.Setenv("INPUT_COPY_SOURCE_USERNAME", "staging-user")
+	t.Setenv("NEWRELIC_TOKEN", "test-token")
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
+	t.Setenv("SIGNING_SERVICE_URL", server.URL)
+	testutil.CaptureOutput(t)
+
+	t.Run("copies the version and signs it for the destination", func(t *testing.T) {
+		signed = nil
+
+		// method under test
+		require.NoError(t, runCopyFlow(context.Background()))
+
+		assert.Equal(t, "staging.example.com/agents", copiedFrom.Registry)
+		assert.Equal(t, "staging-user", copiedFrom.Username)
+		assert.Equal(t, "docker.io/newrelic/agents", copiedTo.Registry)
+		assert.Equal(t, "1.2.3", copiedReference)
+		assert.Equal(t, []models.SigningRequest{{Registry: "docker.io", Repository: "newrelic/agents", Tag: "1.2.3", Digest: "sha256:index123"}}, signed)
+	})
+
+	t.Run("copies by digest", func(t *testing.T) {
+		t.Setenv("INPUT_COPY_DIGEST", "sha256:index123")
+
+		// method under test
+		require.NoError(t, runCopyFlow(context.Background()))
+
+		assert.Equal(t, "sha256:index123", copiedReference)
+	})
+
+	t.Run("dry run isn't signed", func(t *testing.T) {
+		signed = nil
+		t.Setenv("INPUT_DRY_RUN", "true")
+
+		// method under test
+		require.NoError(t, runCopyFlow(context.Background()))
+
+		assert.Empty(t, signed)
+	})
+
+	t.Run("requires a source", func(t *testing.T) {
+		t.Setenv("INPUT_COPY_SOURCE", "")
+
+		// method under test
+		err := runCopyFlow(context.Background())
+
+		assert.ErrorContains(t, err, "copy mode requires version, oci-registry and copy-source")
+	})
+}
+
+func TestRunResignFlow(t *testing.T) {
+	linux := "sha256:" + strings.Repeat("1", 64)
+	windows := "sha256:" + strings.Repeat("2", 64)
+	var resolved string
+	originalResign := ociHandleResignFunc
+	ociHandleResignFunc = func(ctx context.Context, ociConfig *models.OCIConfig, reference, tag string) (oci.ResignTarget, error) {
+		resolved = reference
+		results.RecordIndex(ctx, ociConfig.Registry, tag, "sha256:index123")
+		results.RecordArtifacts(ctx, []models.ArtifactUploadResult{
+			{Name: "linux-amd64", Digest: linux, Referenced: true},
+			{Name: "windows-amd64", Digest: windows, Referenced: true},
+		})
+		return oci.ResignTarget{
+			Index:     ocispec.Descriptor{MediaType: ocispec.MediaTypeImageIndex, Digest: "sha256:index123"},
+			Manifests: []ocispec.Descriptor{{Digest: digest.Digest(linux)}, {Digest: digest.Digest(windows)}},
+		}, nil
+	}
+	defer func() { ociHandleResignFunc = originalResign }()
+
+	var signed []models.SigningRequest
+	failDigest := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var signingReq models.SigningRequest
+		json.NewDecoder(r.Body).Decode(&signingReq)
+		signed = append(signed, signingReq)
+		if signingReq.Digest == failDigest {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"success": true, "id": "sig-` + signingReq.Digest[7:10] + `"}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("INPUT_VERSION", "1.2.3")
+	t.Setenv("INPUT_OCI_REGISTRY", "docker.io/newrelic/agents")
+	t.Setenv("NEWRELIC_TOKEN", "test-token")
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
+	t.Setenv("SIGNING_SERVICE_URL", server.URL)
+	testutil.CaptureOutput(t)
+
+	t.Run("signs the index and every manifest it lists", func(t *testing.T) {
+		signed, failDigest = nil, ""
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+
+		// method under test
+		require.NoError(t, runResignFlow(ctx))
+
+		assert.Equal(t, "1.2.3", resolved)
+		assert.Equal(t, []models.SigningRequest{
+			{Registry: "docker.io", Repository: "newrelic/agents", Tag: "1.2.3", Digest: "sha256:index123"},
+			{Registry: "docker.io", Repository: "newrelic/agents", Tag: "1.2.3", Digest: linux},
+			{Registry: "docker.io", Repository: "newrelic/agents", Tag: "1.2.3", Digest: windows},
+		}, signed)
+		recorded := recorder.Results()
+		assert.True(t, recorded.Index.Signed)
+		assert.Equal(t, "sig-ind", recorded.Index.SignatureID)
+		assert.True(t, recorded.Artifacts[0].Signed)
+		assert.Equal(t, "sig-111", recorded.Artifacts[0].SignatureID)
+		assert.True(t, recorded.Artifacts[1].Signed)
+	})
+
+	t.Run("signs the index with resign-digest", func(t *testing.T) {
+		t.Setenv("INPUT_RESIGN_DIGEST", "sha256:index123")
+
+		// method under test
+		require.NoError(t, runResignFlow(context.Background()))
+
+		assert.Equal(t, "sha256:index123", resolved)
+	})
+
+	t.Run("tries every manifest when one fails", func(t *testing.T) {
+		signed, failDigest = nil, linux
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+
+		// method under test
+		err := runResignFlow(ctx)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to sign 1 of 3 manifests: manifest "+linux)
+		assert.Len(t, signed, 3)
+		recorded := recorder.Results()
+		assert.False(t, recorded.Artifacts[0].Signed)
+		assert.Contains(t, recorded.Artifacts[0].Error, "status 400")
+		assert.True(t, recorded.Artifacts[1].Signed)
+	})
+
+	t.Run("dry run isn't signed", func(t *testing.T) {
+		signed = nil
+		t.Setenv("INPUT_DRY_RUN", "true")
+
+		// method under test
+		require.NoError(t, runResignFlow(context.Background()))
+
+		assert.Empty(t, signed)
+	})
+
+	t.Run("requires a version", func(t *testing.T) {
+		t.Setenv("INPUT_VERSION", "")
+
+		// method under test
+		err := runResignFlow(context.Background())
+
+		assert.ErrorContains(t, err, "resign mode requires version and oci-registry")
+	})
+}
+
+func TestRunMergeFlow(t *testing.T) {
+	var merged *models.OCIConfig
+	originalUploads := ociHandleUploadsFunc
+	ociHandleUploadsFunc = func(ctx context.Context, ociConfig *models.OCIConfig, workspace, version string) (string, error) {
+		merged = ociConfig
+		return "sha256:merged", nil
+	}
+	defer func() { ociHandleUploadsFunc = originalUploads }()
+
+	var signed []models.SigningRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var signingReq models.SigningRequest
+		json.NewDecoder(r.Body).Decode(&signingReq)
+		signed = append(signed, signingReq)
+		w.Write([]byte(`{"success": true}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("INPUT_VERSION", "1.2.3")
+	t.Setenv("INPUT_OCI_REGISTRY", "docker.io/newrelic/agents")
+	t.Setenv("INPUT_BINARIES", `[{"name": "windows-arm64", "path": "./dist/agent-windows-arm64.zip", "os": "windows", "arch": "arm64", "format": "zip"}]`)
+	t.Setenv("NEWRELIC_TOKEN", "test-token")
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
+	t.Setenv("SIGNING_SERVICE_URL", server.URL)
+	testutil.CaptureOutput(t)
+
+	t.Run("merges the binaries and signs the merged index", func(t *testing.T) {
+		merged, signed = nil, nil
+
+		// method under test
+		require.NoError(t, runMergeFlow(context.Background(), t.TempDir()))
+
+		require.NotNil(t, merged)
+		assert.True(t, merged.MergeIndex)
+		assert.Equal(t, "windows-arm64", merged.Artifacts[0].Name)
+		assert.Equal(t, []models.SigningRequest{{Registry: "docker.io", Repository: "newrelic/agents", Tag: "1.2.3", Digest: "sha256:merged"}}, signed)
+	})
+
+	t.Run("dry run uploads nothing", func(t *testing.T) {
+		merged, signed = nil, nil
+		t.Setenv("INPUT_DRY_RUN", "true")
+
+		// method under test
+		require.NoError(t, runMergeFlow(context.Background(), t.TempDir()))
+
+		assert.Nil(t, merged)
+		assert.Empty(t, signed)
+	})
+
+	t.Run("requires binaries", func(t *testing.T) {
+		t.Setenv("INPUT_BINARIES", "")
+
+		// method under test
+		err := runMergeFlow(context.Background(), t.TempDir())
+
+		assert.ErrorContains(t, err, "merge mode requires version, oci-registry and binaries")
+	})
+
+	t.Run("reports failed merges", func(t *testing.T) {
+		ociHandleUploadsFunc = func(ctx context.Context, ociConfig *models.OCIConfig, workspace, version string) (string, error) {
+			return "", fmt.Errorf("failed to merge into the manifest index: no manifest index tagged 1.2.3 in docker.io/newrelic/agents to merge into")
+		}
+
+		// method under test
+		err := runMergeFlow(context.Background(), t.TempDir())
+
+		assert.ErrorContains(t, err, "merge into 1.2.3 failed: failed to merge into the manifest index")
+	})
+}
+
+func TestRunCollectFlow(t *testing.T) {
+	var collected *models.OCIConfig
+	originalUploads := ociHandleUploadsFunc
+	ociHandleUploadsFunc = func(ctx context.Context, ociConfig *models.OCIConfig, workspace, version string) (string, error) {
+		collected = ociConfig
+		return "", nil
+	}
+	defer func() { ociHandleUploadsFunc = originalUploads }()
+
+	t.Setenv("INPUT_VERSION", "1.2.3")
+	t.Setenv("INPUT_OCI_REGISTRY", "docker.io/newrelic/agents")
+	t.Setenv("INPUT_BINARIES", `[{"name": "linux-arm64", "path": "./dist/agent-linux-arm64.tar.gz", "os": "linux", "arch": "arm64", "format": "tar+gzip"}]`)
+	t.Setenv("INPUT_HANDOFF_FILE", "handoff/linux-arm64.json")
+	testutil.CaptureOutput(t)
+
+	t.Run("uploads the binaries with a handoff file", func(t *testing.T) {
+		collected = nil
+
+		// method under test
+		require.NoError(t, runCollectFlow(context.Background(), t.TempDir()))
+
+		require.NotNil(t, collected)
+		assert.Equal(t, "handoff/linux-arm64.json", collected.HandoffFile)
+		assert.Equal(t, "linux-arm64", collected.Artifacts[0].Name)
+	})
+
+	t.Run("dry run uploads nothing", func(t *testing.T) {
+		collected = nil
+		t.Setenv("INPUT_DRY_RUN", "true")
+
+		// method under test
+		require.NoError(t, runCollectFlow(context.Background(), t.TempDir()))
+
+		assert.Nil(t, collected)
+	})
+
+	t.Run("requires handoff-file", func(t *testing.T) {
+		t.Setenv("INPUT_HANDOFF_FILE", "")
+
+		// method under test
+		err := runCollectFlow(context.Background(), t.TempDir())
+
+		assert.ErrorContains(t, err, "collect mode requires version, oci-registry, binaries and handoff-file")
+	})
+
+	t.Run("rejects directory traversal", func(t *testing.T) {
+		t.Setenv("INPUT_HANDOFF_FILE", "../handoff.json")
+
+		// method under test
+		err := runCollectFlow(context.Background(), t.TempDir())
+
+		assert.ErrorContains(t, err, "invalid handoff-file ../handoff.json")
+	})
+}
+
+func TestRunAssembleFlow(t *testing.T) {
+	var assembledDir string
+	originalAssemble := ociHandleAssembleFunc
+	ociHandleAssembleFunc = func(ctx context.Context, ociConfig *models.OCIConfig, workspace, handoffDir, version string, dryRun bool) (string, error) {
+		assembledDir = handoffDir
+		if dryRun {
+			return "", nil
+		}
+		return "sha256:assembled", nil
+	}
+	defer func() { ociHandleAssembleFunc = originalAssemble }()
+
+	var signed []models.SigningRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var signingReq models.SigningRequest
+		json.NewDecoder(r.Body).Decode(&signingReq)
+		signed = append(signed, signingReq)
+		w.Write([]byte(`{"success": true}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("INPUT_VERSION", "1.2.3")
+	t.Setenv("INPUT_OCI_REGISTRY", "docker.io/newrelic/agents")
+	t.Setenv("INPUT_HANDOFF_DIRECTORY", "handoffs")
+	t.Setenv("NEWRELIC_TOKEN", "test-token")
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
+	t.Setenv("SIGNING_SERVICE_URL", server.URL)
+	testutil.CaptureOutput(t)
+
+	t.Run("assembles and signs the index", func(t *testing.T) {
+		assembledDir, signed = "", nil
+
+		// method under test
+		require.NoError(t, runAssembleFlow(context.Background(), t.TempDir()))
+
+		assert.Equal(t, "handoffs", assembledDir)
+		assert.Equal(t, []models.SigningRequest{{Registry: "docker.io", Repository: "newrelic/agents", Tag: "1.2.3", Digest: "sha256:assembled"}}, signed)
+	})
+
+	t.Run("signs the pending tag", func(t *testing.T) {
+		signed = nil
+		t.Setenv("INPUT_OCI_PENDING_TAG", "true")
+
+		// method under test
+		require.NoError(t, runAssembleFlow(context.Background(), t.TempDir()))
+
+		require.Len(t, signed, 1)
+		assert.Equal(t, "1.2.3-pending", signed[0].Tag)
+	})
+
+	t.Run("dry run signs nothing", func(t *testing.T) {
+		assembledDir, signed = "", nil
+		t.Setenv("INPUT_DRY_RUN", "true")
+
+		// method under test
+		require.NoError(t, runAssembleFlow(context.Background(), t.TempDir()))
+
+		assert.Equal(t, "handoffs", assembledDir)
+		assert.Empty(t, signed)
+	})
+
+	t.Run("requires handoff-directory", func(t *testing.T) {
+		t.Setenv("INPUT_HANDOFF_DIRECTORY", "")
+
+		// method under test
+		err := runAssembleFlow(context.Background(), t.TempDir())
+
+		assert.ErrorContains(t, err, "assemble mode requires version, oci-registry and handoff-directory")
+	})
+
+	t.Run("reports failed assembles", func(t *testing.T) {
+		ociHandleAssembleFunc = func(ctx context.Context, ociConfig *models.OCIConfig, workspace, handoffDir, version string, dryRun bool) (string, error) {
+			return "", fmt.Errorf("no handoff files in handoffs")
+		}
+
+		// method under test
+		err := runAssembleFlow(context.Background(), t.TempDir())
+
+		assert.ErrorContains(t, err, "assemble of 1.2.3 failed: no handoff files in handoffs")
+	})
+}
+
+func TestRunImportFlow(t *testing.T) {
+	var importedDir string
+	originalImport := ociHandleImportFunc
+	ociHandleImportFunc = func(ctx context.Context, ociConfig *models.OCIConfig, workspace, layoutDir, version string, dryRun bool) (string, error) {
+		importedDir = layoutDir
+		if dryRun {
+			return "", nil
+		}
+		return "sha256:imported", nil
+	}
+	defer func() { ociHandleImportFunc = originalImport }()
+
+	var signed []models.SigningRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var signingReq models.SigningRequest
+		json.NewDecoder(r.Body).Decode(&signingReq)
+		signed = append(signed, signingReq)
+		w.Write([]byte(`{"success": true}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("INPUT_VERSION", "1.2.3")
+	t.Setenv("INPUT_OCI_REGISTRY", "docker.io/newrelic/agents")
+	t.Setenv("INPUT_OCI_LAYOUT", "build/layout")
+	t.Setenv("NEWRELIC_TOKEN", "test-token")
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
+	t.Setenv("SIGNING_SERVICE_URL", server.URL)
+	testutil.CaptureOutput(t)
+
+	t.Run("imports and signs the index", func(t *testing.T) {
+		importedDir, signed = "", nil
+
+		// method under test
+		require.NoError(t, runImportFlow(context.Background(), t.TempDir()))
+
+		assert.Equal(t, "build/layout", importedDir)
+		assert.Equal(t, []models.SigningRequest{{Registry: "docker.io", Repository: "newrelic/agents", Tag: "1.2.3", Digest: "sha256:imported"}}, signed)
+	})
+
+	t.Run("dry run signs nothing", func(t *testing.T) {
+		importedDir, signed = "", nil
+		t.Setenv("INPUT_DRY_RUN", "true")
+
+		// method under test
+		require.NoError(t, runImportFlow(context.Background(), t.TempDir()))
+
+		assert.Equal(t, "build/layout", importedDir)
+		assert.Empty(t, signed)
+	})
+
+	t.Run("requires oci-layout", func(t *testing.T) {
+		t.Setenv("INPUT_OCI_LAYOUT", "")
+
+		// method under test
+		err := runImportFlow(context.Background(), t.TempDir())
+
+		assert.ErrorContains(t, err, "import mode requires version, oci-registry and oci-layout")
+	})
+
+	t.Run("rejects directory traversal", func(t *testing.T) {
+		t.Setenv("INPUT_OCI_LAYOUT", "../layout")
+
+		// method under test
+		err := runImportFlow(context.Background(), t.TempDir())
+
+		assert.ErrorContains(t, err, "invalid oci-layout ../layout")
+	})
+
+	t.Run("reports failed imports", func(t *testing.T) {
+		ociHandleImportFunc = func(ctx context.Context, ociConfig *models.OCIConfig, workspace, layoutDir, version string, dryRun bool) (string, error) {
+			return "", fmt.Errorf("no manifest index tagged 1.2.3 in the OCI layout build/layout")
+		}
+
+		// method under test
+		err := runImportFlow(context.Background(), t.TempDir())
+
+		assert.ErrorContains(t, err, "import of 1.2.3 failed: no manifest index tagged 1.2.3")
+	})
+}
+
+// mockMetadataDeleter deletes the metadata of the versions in stored, failing for those in failing
+type mockMetadataDeleter struct {
+	stored  map[string]bool
+	failing map[string]bool
+	deleted []string
+}
+
+func (m *mockMetadataDeleter) DeleteMetadata(ctx context.Context, agentType string, agentVersion string) (bool, error) {
+	key := agentType + " " + agentVersion
+	if m.failing[key] {
+		return false, assert.AnError
+	}
+	if !m.stored[key] {
+		return false, nil
+	}
+	m.deleted = append(m.deleted, key)
+	return true, nil
+}
+
+// writeJournal writes entries to journal-file in a new workspace, as a run journaling them would
+func writeJournal(t *testing.T, entries ...journal.Entry) string {
+	t.Helper()
+	workspace := t.TempDir()
+	j, err := journal.Create(filepath.Join(workspace, ".release", "journal.jsonl"))
+	require.NoError(t, err)
+	for _, entry := range entries {
+		require.NoError(t, j.Append(entry))
+	}
+	return workspace
+}
+
+func TestRunRollbackFlow(t *testing.T) {
+	var rolledBack []journal.Entry
+	var rolledBackRegistry string
+	originalRollback := ociHandleRollbackFunc
+	ociHandleRollbackFunc = func(ctx context.Context, ociConfig *models.OCIConfig, entries []journal.Entry, dryRun bool) error {
+		rolledBack, rolledBackRegistry = entries, ociConfig.Registry
+		return nil
+	}
+	defer func() { ociHandleRollbackFunc = originalRollback }()
+
+	t.Setenv("INPUT_JOURNAL_FILE", ".release/journal.jsonl")
+	t.Setenv("INPUT_OCI_REGISTRY", "docker.io/newrelic/agents")
+	testutil.CaptureOutput(t)
+
+	manifest := journal.Entry{Operation: journal.OperationManifest, Registry: "docker.io/newrelic/agents", Digest: "sha256:manifest"}
+	index := journal.Entry{Operation: journal.OperationIndex, Registry: "docker.io/newrelic/agents", Tag: "1.2.3", Digest: "sha256:index"}
+	metadata := journal.Entry{Operation: journal.OperationMetadata, AgentType: "dotnet-agent", Version: "1.2.3", ID: "submission-1"}
+
+	t.Run("deletes the metadata and undoes the registry operations", func(t *testing.T) {
+		rolledBack, rolledBackRegistry = nil, ""
+		workspace := writeJournal(t, manifest, index, metadata)
+		deleter := &mockMetadataDeleter{stored: map[string]bool{"dotnet-agent 1.2.3": true}}
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+
+		// method under test
+		err := runRollbackFlow(ctx, deleter, workspace)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dotnet-agent 1.2.3"}, deleter.deleted)
+		assert.Equal(t, "docker.io/newrelic/agents", rolledBackRegistry)
+		require.Len(t, rolledBack, 2)
+		assert.Equal(t, manifest.Digest, rolledBack[0].Digest, "Registry operations are passed on in the order they completed")
+		assert.Equal(t, index.Digest, rolledBack[1].Digest)
+		assert.Equal(t, []results.Rollback{{Operation: journal.OperationMetadata, Target: "dotnet-agent 1.2.3", Undone: true, Detail: "deleted"}}, recorder.Results().Rollback)
+	})
+
+	t.Run("metadata no longer stored", func(t *testing.T) {
+		workspace := writeJournal(t, metadata)
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+
+		// method under test
+		err := runRollbackFlow(ctx, &mockMetadataDeleter{}, workspace)
+
+		require.NoError(t, err)
+		assert.Equal(t, []results.Rollback{{Operation: journal.OperationMetadata, Target: "dotnet-agent 1.2.3", Detail: "not stored"}}, recorder.Results().Rollback)
+	})
+
+	t.Run("dry run deletes nothing", func(t *testing.T) {
+		rolledBack = nil
+		t.Setenv("INPUT_DRY_RUN", "true")
+		workspace := writeJournal(t, manifest, metadata)
+		deleter := &mockMetadataDeleter{stored: map[string]bool{"dotnet-agent 1.2.3": true}}
+
+		// method under test
+		err := runRollbackFlow(context.Background(), deleter, workspace)
+
+		require.NoError(t, err)
+		assert.Empty(t, deleter.deleted)
+		assert.Len(t, rolledBack, 1, "The registry operations are listed by the dry run")
+	})
+
+	t.Run("undoes the registry operations when a metadata deletion fails", func(t *testing.T) {
+		rolledBack = nil
+		workspace := writeJournal(t, manifest, metadata)
+		deleter := &mockMetadataDeleter{failing: map[string]bool{"dotnet-agent 1.2.3": true}}
+
+		// method under test
+		err := runRollbackFlow(context.Background(), deleter, workspace)
+
+		assert.EqualError(t, err, "rollback failed to delete the metadata of dotnet-agent 1.2.3")
+		assert.Len(t, rolledBack, 1)
+	})
+
+	t.Run("registry operations require oci-registry", func(t *testing.T) {
+		t.Setenv("INPUT_OCI_REGISTRY", "")
+		workspace := writeJournal(t, manifest, metadata)
+		deleter := &mockMetadataDeleter{stored: map[string]bool{"dotnet-agent 1.2.3": true}}
+
+		// method under test
+		err := runRollbackFlow(context.Background(), deleter, workspace)
+
+		assert.ErrorContains(t, err, "rollback mode requires oci-registry")
+		assert.Empty(t, deleter.deleted, "Nothing is undone")
+	})
+
+	t.Run("requires journal-file", func(t *testing.T) {
+		t.Setenv("INPUT_JOURNAL_FILE", "")
+
+		// method under test
+		err := runRollbackFlow(context.Background(), &mockMetadataDeleter{}, t.TempDir())
+
+		assert.ErrorContains(t, err, "rollback mode requires journal-file")
+	})
+
+	t.Run("missing journal", func(t *testing.T) {
+		// method under test
+		err := runRollbackFlow(context.Background(), &mockMetadataDeleter{}, t.TempDir())
+
+		assert.ErrorContains(t, err, "failed to read journal-file")
+	})
+}
+
+func TestStartJournal(t *testing.T) {
+	t.Setenv("INPUT_JOURNAL_FILE", ".release/journal.jsonl")
+
+	t.Run("journals the operations of the run", func(t *testing.T) {
+		workspace := t.TempDir()
+
+		// method under test
+		ctx, err := startJournal(context.Background(), workspace)
+
+		require.NoError(t, err)
+		journal.Record(ctx, journal.Entry{Operation: journal.OperationMetadata, AgentType: "dotnet-agent", Version: "1.2.3"})
+		entries, err := journal.Read(filepath.Join(workspace, ".release", "journal.jsonl"))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "dotnet-agent", entries[0].AgentType)
+	})
+
+	t.Run("rollback runs leave the journal as it is", func(t *testing.T) {
+		t.Setenv("INPUT_MODE", modeRollback)
+		workspace := writeJournal(t, journal.Entry{Operation: journal.OperationMetadata, AgentType: "dotnet-agent", Version: "1.2.3"})
+
+		// method under test
+		ctx, err := startJournal(context.Background(), workspace)
+
+		require.NoError(t, err)
+		assert.Nil(t, journal.FromContext(ctx))
+		entries, err := journal.Read(filepath.Join(workspace, ".release", "journal.jsonl"))
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+
+	t.Run("rejects directory traversal", func(t *testing.T) {
+		t.Setenv("INPUT_JOURNAL_FILE", "../journal.jsonl")
+
+		// method under test
+		_, err := startJournal(context.Background(), t.TempDir())
+
+		assert.ErrorContains(t, err, "invalid journal-file ../journal.jsonl")
+	})
+}
+
+// mockVerifyService serves the stored metadata of every version
+type mockVerifyService struct {
+	mockReconcileService
+	metadata *models.AgentMetadata
+}
+
+func (m *mockVerifyService) GetMetadata(ctx context.Context, agentType string, agentVersion string) (*models.AgentMetadata, error) {
+	return m.metadata, nil
+}
+
+func TestRunVerifyFlow(t *testing.T) {
+	linux := "sha256:" + strings.Repeat("1", 64)
+	indexDigest := "sha256:" + strings.Repeat("a", 64)
+	signed := true
+	originalInspect := ociInspectReleaseFunc
+	ociInspectReleaseFunc = func(ctx context.Context, ociConfig *models.OCIConfig, tag string) (oci.Release, error) {
+		return oci.Release{
+			ResignTarget: oci.ResignTarget{
+				Index:     ocispec.Descriptor{MediaType: ocispec.MediaTypeImageIndex, Digest: digest.Digest(indexDigest)},
+				Manifests: []ocispec.Descriptor{{Digest: digest.Digest(linux), Platform: &ocispec.Platform{OS: "linux", Architecture: "amd64"}}},
+			},
+			Signed: signed,
+		}, nil
+	}
+	defer func() { ociInspectReleaseFunc = originalInspect }()
+
+	workspace := t.TempDir()
+	released := `{"agentType":"NRDotNetAgent","version":"1.2.3","index":{"registry":"docker.io/newrelic/agents","tag":"1.2.3","digest":"` + indexDigest + `"},` +
+		`"artifacts":[{"name":"linux-amd64","os":"linux","arch":"amd64","digest":"` + linux + `"}]}`
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "release.json"), []byte(released), 0644))
+	t.Setenv("INPUT_AGENT_TYPE", "NRDotNetAgent")
+	t.Setenv("INPUT_VERSION", "1.2.3")
+	t.Setenv("INPUT_OCI_REGISTRY", "docker.io/newrelic/agents")
+	t.Setenv("INPUT_VERIFY_RESULTS", "release.json")
+	summaryPath := filepath.Join(t.TempDir(), "summary.md")
+	t.Setenv("GITHUB_STEP_SUMMARY", summaryPath)
+	testutil.CaptureOutput(t)
+	service := &mockVerifyService{metadata: &models.AgentMetadata{}}
+
+	t.Run("passes a complete release", func(t *testing.T) {
+		signed = true
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+
+		// method under test
+		err := runVerifyFlow(ctx, service, workspace)
+
+		require.NoError(t, err)
+		recorded := recorder.Results()
+		require.Len(t, recorded.Verify, 5)
+		for _, check := range recorded.Verify {
+			assert.True(t, check.Passed, check.Check)
+		}
+		summary, err := os.ReadFile(summaryPath)
+		require.NoError(t, err)
+		assert.Contains(t, string(summary), "### Verification of NRDotNetAgent 1.2.3")
+	})
+
+	t.Run("fails an unsigned release", func(t *testing.T) {
+		signed = false
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+
+		// method under test
+		err := runVerifyFlow(ctx, service, workspace)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "verification of NRDotNetAgent 1.2.3 failed: 1 of 5 checks did not pass")
+		assert.Len(t, recorder.Results().Verify, 5)
+	})
+
+	t.Run("rejects the results of another release", func(t *testing.T) {
+		t.Setenv("INPUT_VERSION", "1.2.4")
+
+		// method under test
+		err := runVerifyFlow(context.Background(), service, workspace)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is the release of NRDotNetAgent 1.2.3, not NRDotNetAgent 1.2.4")
+	})
+
+	t.Run("requires agent type and version", func(t *testing.T) {
+		t.Setenv("INPUT_AGENT_TYPE", "")
+
+		// method under test
+		err := runVerifyFlow(context.Background(), service, workspace)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "verify mode requires agent-type and version")
+	})
+}
+
+func TestRunCleanupFlow(t *testing.T) {
+	var cleaned *models.OCIConfig
+	var cleanedRetention time.Duration
+	var cleanedDryRun bool
+	originalCleanup := ociHandleCleanupFunc
+	ociHandleCleanupFunc = func(ctx context.Context, ociConfig *models.OCIConfig, retention time.Duration, dryRun bool) error {
+		cleaned, cleanedRetention, cleanedDryRun = ociConfig, retention, dryRun
+		return nil
+	}
+	defer func() { ociHandleCleanupFunc = originalCleanup }()
+
+	t.Setenv("INPUT_OCI_REGISTRY", "ghcr.io/newrelic/agents")
+
+	t.Run("cleans up the registry", func(t *testing.T) {
+		t.Setenv("INPUT_RETENTION_DAYS", "14")
+		t.Setenv("INPUT_DRY_RUN", "true")
+
+		// method under test
+		require.NoError(t, runCleanupFlow(context.Background()))
+
+		assert.Equal(t, "ghcr.io/newrelic/agents", cleaned.Registry)
+		assert.Equal(t, 14*24*time.Hour, cleanedRetention)
+		assert.True(t, cleanedDryRun)
+	})
+
+	t.Run("defaults to 30 days", func(t *testing.T) {
+		// method under test
+		require.NoError(t, runCleanupFlow(context.Background()))
+
+		assert.Equal(t, 30*24*time.Hour, cleanedRetention)
+	})
+
+	t.Run("requires a registry", func(t *testing.T) {
+		t.Setenv("INPUT_OCI_REGISTRY", "")
+
+		// method under test
+		err := runCleanupFlow(context.Background())
+
+		assert.ErrorContains(t, err, "cleanup mode requires oci-registry")
+	})
+
+	t.Run("reports failed cleanups", func(t *testing.T) {
+		ociHandleCleanupFunc = func(ctx context.Context, ociConfig *models.OCIConfig, retention time.Duration, dryRun bool) error {
+			return fmt.Errorf("failed to delete 1 of 2 expired prerelease manifests: 1.2.3-beta.1")
+		}
+
+		// method under test
+		err := runCleanupFlow(context.Background())
+
+		assert.ErrorContains(t, err, "cleanup of ghcr.io/newrelic/agents failed: failed to delete 1 of 2")
+	})
+}
+
+func TestRunBackfillFlow(t *testing.T) {
+	projectRoot, err := filepath.Abs("../..")
+	require.NoError(t, err)
+	checkout := filepath.Join(projectRoot, "integration-test", "agent-flow")
+
+	originalTags, originalCheckout, originalDate := github.ListTagsFunc, github.CheckoutTagFunc, github.VersionDateFunc
+	t.Cleanup(func() {
+		github.ListTagsFunc, github.CheckoutTagFunc, github.VersionDateFunc = originalTags, originalCheckout, originalDate
+	})
+	github.ListTagsFunc = func(ctx context.Context, workspace string) ([]string, error) {
+		return []string{"v1.0.0", "v1.1.0", "v1.2.0", "v2.0.0"}, nil
+	}
+	var checkedOut []string
+	var mu sync.Mutex
+	github.CheckoutTagFunc = func(ctx context.Context, workspace, tag string) (string, func(), error) {
+		mu.Lock()
+		defer mu.Unlock()
+		checkedOut = append(checkedOut, tag)
+		return checkout, func() {}, nil
+	}
+	github.VersionDateFunc = func(ctx context.Context, workspace, version, path string) (time.Time, string, error) {
+		return time.Date(2024, 5, 1, 23, 0, 0, 0, time.FixedZone("", -2*60*60)), "tag v" + version, nil
+	}
+
+	t.Run("tag range", func(t *testing.T) {
+		checkedOut = nil
+		t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+		t.Setenv("INPUT_BACKFILL_VERSIONS", "v1.1.0..v2.0.0")
+		t.Setenv("INPUT_BACKFILL_CONCURRENCY", "2")
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+		client := &mockBackfillClient{releaseDates: map[string]interface{}{}}
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := runBackfillFlow(ctx, client, t.TempDir())
+
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"v1.1.0", "v1.2.0", "v2.0.0"}, checkedOut)
+		assert.Equal(t, map[string]interface{}{"1.1.0": "2024-05-02", "1.2.0": "2024-05-02", "2.0.0": "2024-05-02"}, client.releaseDates)
+		payloads := recorder.Results().Payloads
+		require.Len(t, payloads, 3)
+		assert.Equal(t, results.Payload{AgentType: "NRJavaAgent", Version: "1.1.0", Source: "tag v1.1.0", ID: "id-1.1.0", Submitted: true}, payloads[0])
+		stdout := getStdout()
+		assert.Contains(t, stdout, "Backfilling 3 versions of NRJavaAgent, 2 at a time")
+		assert.Contains(t, stdout, "Backfill complete: 3 of 3 versions succeeded, 0 failed")
+	})
+
+	t.Run("placeholders are expanded with the version of each release", func(t *testing.T) {
+		t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+		t.Setenv("INPUT_VERSION", "")
+		t.Setenv("INPUT_BACKFILL_VERSIONS", "1.1.0,1.2.0")
+		t.Setenv("INPUT_BACKFILL_CONCURRENCY", "2")
+		checkouts := map[string]string{}
+		for _, version := range []string{"1.1.0", "1.2.0"} {
+			dir := t.TempDir()
+			configDir := filepath.Join(dir, config.GetRootFolderForAgentRepo())
+			require.NoError(t, os.MkdirAll(filepath.Join(configDir, "schemas", version), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(configDir, "schemas", version, "config.json"), []byte(`{"title": "`+version+`"}`), 0o644))
+			require.NoError(t, os.WriteFile(filepath.Join(configDir, config.GetConfigurationDefinitionsFilename()), []byte(`configurationDefinitions:
+  - platform: ALL
+    description: ${AGENT_TYPE} configuration
+    type: agent-config
+    version: ${VERSION}
+    schema: ./schemas/${VERSION}/config.json
+`), 0o644))
+			checkouts["v"+version] = dir
+		}
+		checkoutAgentFlow := github.CheckoutTagFunc
+		t.Cleanup(func() { github.CheckoutTagFunc = checkoutAgentFlow })
+		github.CheckoutTagFunc = func(ctx context.Context, workspace, tag string) (string, func(), error) {
+			return checkouts[tag], func() {}, nil
+		}
+		client := &mockBackfillClient{releaseDates: map[string]interface{}{}, definitions: map[string][]models.ConfigurationDefinition{}}
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := runBackfillFlow(context.Background(), client, t.TempDir())
+
+		require.NoError(t, err)
+		for _, version := range []string{"1.1.0", "1.2.0"} {
+			require.Len(t, client.definitions[version], 1)
+			definition := client.definitions[version][0]
+			assert.Equal(t, version, definition["version"])
+			assert.Equal(t, "NRJavaAgent configuration", definition["description"])
+			assert.Equal(t, base64.StdEncoding.EncodeToString([]byte(`{"title": "`+version+`"}`)), definition["schema"])
+		}
+	})
+
+	t.Run("concurrent releases wait for the payload version negotiation", func(t *testing.T) {
+		t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+		t.Setenv("INPUT_BACKFILL_VERSIONS", "v1.0.0..v2.0.0")
+		t.Setenv("INPUT_BACKFILL_CONCURRENCY", "4")
+		t.Setenv("INPUT_PAYLOAD_VERSION", "auto")
+		server := mockserver.New("")
+		// A slow probe, so every release asks for the payload version while it is in flight
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/v1/capabilities" {
+				time.Sleep(100 * time.Millisecond)
+			}
+			server.ServeHTTP(w, r)
+		}))
+		defer ts.Close()
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := runBackfillFlow(context.Background(), newInstrumentationClient(ts.URL, "token"), t.TempDir())
+
+		require.NoError(t, err)
+		probes := 0
+		var versions []string
+		for _, request := range server.Requests() {
+			switch {
+			case request.Path == "/v1/capabilities":
+				probes++
+			case request.Method == http.MethodPost && strings.HasPrefix(request.Path, "/v1/agents/NRJavaAgent/versions/"):
+				versions = append(versions, request.Header.Get("Accept-Version"))
+			}
+		}
+		assert.Equal(t, 1, probes)
+		assert.Equal(t, []string{models.PayloadV3, models.PayloadV3, models.PayloadV3, models.PayloadV3}, versions)
+	})
+
+	t.Run("release date input and a failed version", func(t *testing.T) {
+		t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+		t.Setenv("INPUT_BACKFILL_VERSIONS", "1.0.0,2.0.0")
+		t.Setenv("INPUT_RELEASE_DATE", "2025-01-01")
+		client := &mockBackfillClient{fail: map[string]bool{"1.0.0": true}, releaseDates: map[string]interface{}{}}
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := runBackfillFlow(context.Background(), client, t.TempDir())
+
+		assert.EqualError(t, err, "failed to backfill 1 of 2 versions")
+		assert.Equal(t, map[string]interface{}{"2.0.0": "2025-01-01"}, client.releaseDates)
+		assert.Contains(t, getStdout(), "::error::Versions that failed to backfill: 1.0.0")
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+		t.Setenv("INPUT_BACKFILL_VERSIONS", "1.0.0")
+		t.Setenv("INPUT_DRY_RUN", "true")
+		client := &mockBackfillClient{releaseDates: map[string]interface{}{}}
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := runBackfillFlow(context.Background(), client, t.TempDir())
+
+		require.NoError(t, err)
+		assert.Empty(t, client.releaseDates)
+		assert.Contains(t, getStdout(), "Backfill (dry run) complete: 1 of 1 versions succeeded, 0 failed")
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+		t.Setenv("INPUT_BACKFILL_VERSIONS", "3.0.0")
+
+		// method under test
+		err := runBackfillFlow(context.Background(), &mockBackfillClient{}, t.TempDir())
+
+		assert.EqualError(t, err, "invalid backfill-versions: no tag v3.0.0 or 3.0.0 for version 3.0.0")
+	})
+
+	t.Run("nothing selected", func(t *testing.T) {
+		t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+
+		// method under test
+		err := runBackfillFlow(context.Background(), &mockBackfillClient{}, t.TempDir())
+
+		assert.EqualError(t, err, "backfill mode requires agent-type and backfill-versions")
+	})
+}
+
+func TestRun_InvalidBackfillConcurrency(t *testing.T) {
+	t.Setenv("GITHUB_WORKSPACE", t.TempDir())
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("INPUT_BACKFILL_CONCURRENCY", "0")
+
+	// method under test
+	err := run(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid backfill-concurrency "0": must be a number of versions, at least 1`)
+}
+
+func TestRun_InvalidRetentionDays(t *testing.T) {
+	t.Setenv("GITHUB_WORKSPACE", t.TempDir())
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("INPUT_RETENTION_DAYS", "a month")
+
+	// method under test
+	err := run(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid retention-days "a month": must be a number of days, at least 1`)
+}
+
+func TestRun_InvalidRateLimit(t *testing.T) {
+	t.Setenv("GITHUB_WORKSPACE", t.TempDir())
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("INPUT_RATE_LIMIT", "-1")
+
+	// method under test
+	err := run(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid rate-limit "-1": must be a number of requests per second, or 0 for no limit`)
+}
+
+func TestRun_InvalidRetryBudget(t *testing.T) {
+	t.Setenv("GITHUB_WORKSPACE", t.TempDir())
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("INPUT_RETRY_BUDGET", "forever")
+
+	// method under test
+	err := run(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid retry-budget "forever": must be a duration such as 10m, or 0 for no budget`)
+}
+
+func TestStartMetrics(t *testing.T) {
+	testutil.CaptureOutput(t)
+
+	t.Run("disabled by default", func(t *testing.T) {
+		// method under test
+		ctx, stop, err := startMetrics(context.Background(), t.TempDir())
+
+		require.NoError(t, err)
+		stop()
+		assert.Nil(t, metrics.FromContext(ctx))
+	})
+
+	t.Run("writes the metrics file", func(t *testing.T) {
+		workspace := t.TempDir()
+		t.Setenv("INPUT_MODE", "reconcile")
+		t.Setenv("INPUT_METRICS_FILE", "metrics/agent_metadata.prom")
+
+		// method under test
+		ctx, stop, err := startMetrics(context.Background(), workspace)
+
+		require.NoError(t, err)
+		metrics.Add(ctx, metrics.ItemsProcessed, 3)
+		stop()
+		data, err := os.ReadFile(filepath.Join(workspace, "metrics", "agent_metadata.prom"))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `agent_metadata_items_processed_total{mode="reconcile"} 3`)
+	})
+
+	t.Run("rejects a non-loopback address", func(t *testing.T) {
+		t.Setenv("INPUT_METRICS_ADDRESS", "0.0.0.0:9464")
+
+		// method under test
+		_, _, err := startMetrics(context.Background(), t.TempDir())
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must be a loopback address")
+	})
+}
+
+func TestRun_InvalidHeartbeatInterval(t *testing.T) {
+	t.Setenv("GITHUB_WORKSPACE", t.TempDir())
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("INPUT_HEARTBEAT_INTERVAL", "-5s")
+
+	// method under test
+	err := run(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid heartbeat-interval "-5s": must be a duration such as 60s, or 0 to disable the heartbeat`)
+}
+
+func TestRun_InvalidPayloadMemoryLimit(t *testing.T) {
+	t.Setenv("GITHUB_WORKSPACE", t.TempDir())
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("INPUT_PAYLOAD_MEMORY_LIMIT", "-1")
+
+	// method under test
+	err := run(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid payload-memory-limit "-1": must be a number of MiB, or 0 for no limit`)
+}
+
+func TestExportMetadata(t *testing.T) {
+	metadata := &models.AgentMetadata{Metadata: models.Metadata{"version": "1.2.3"}}
+
+	t.Run("disabled when export-directory is not set", func(t *testing.T) {
+		workspace := t.TempDir()
+		t.Setenv("INPUT_EXPORT_DIRECTORY", "")
+
+		require.NoError(t, exportMetadata(context.Background(), workspace, "NRJavaAgent", "1.2.3", metadata))
+
+		entries, err := os.ReadDir(workspace)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("writes versioned file", func(t *testing.T) {
+		workspace := t.TempDir()
+		t.Setenv("INPUT_EXPORT_DIRECTORY", "out")
+
+		getStdout, _ := testutil.CaptureOutput(t)
+		require.NoError(t, exportMetadata(context.Background(), workspace, "NRJavaAgent", "1.2.3", metadata))
+
+		assert.FileExists(t, filepath.Join(workspace, "out", "agents", "NRJavaAgent", "1.2.3.json"))
+		assert.Contains(t, getStdout(), "Exported metadata for NRJavaAgent version 1.2.3")
+	})
+
+	t.Run("rejects directory traversal", func(t *testing.T) {
+		t.Setenv("INPUT_EXPORT_DIRECTORY", "../out")
+
+		err := exportMetadata(context.Background(), t.TempDir(), "NRJavaAgent", "1.2.3", metadata)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid export-directory")
+	})
+}
+
+func TestWriteSchemaDocs(t *testing.T) {
+	schema := base64.StdEncoding.EncodeToString([]byte(`{"type": "object", "properties": {"log_level": {"type": "string", "default": "info"}}}`))
+	definitions := []models.ConfigurationDefinition{
+		{"type": "agent-config", "platform": "linux", "schema": schema},
+		{"type": "agent-config", "platform": "windows", "schema": "not base64!"},
+	}
+
+	t.Run("disabled when schema-docs-directory is not set", func(t *testing.T) {
+		workspace := t.TempDir()
+		t.Setenv("INPUT_SCHEMA_DOCS_DIRECTORY", "")
+
+		require.NoError(t, writeSchemaDocs(context.Background(), workspace, "NRJavaAgent", "1.2.3", definitions))
+
+		entries, err := os.ReadDir(workspace)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("writes the docs and the job summary", func(t *testing.T) {
+		workspace := t.TempDir()
+		t.Setenv("INPUT_SCHEMA_DOCS_DIRECTORY", "docs")
+		summaryPath := filepath.Join(t.TempDir(), "summary.md")
+		t.Setenv("GITHUB_STEP_SUMMARY", summaryPath)
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+
+		getStdout, _ := testutil.CaptureOutput(t)
+		require.NoError(t, writeSchemaDocs(ctx, workspace, "NRJavaAgent", "1.2.3", definitions))
+
+		content, err := os.ReadFile(filepath.Join(workspace, "docs", "NRJavaAgent", "1.2.3", "agent-config-linux.md"))
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "| `log_level` | string | no | `\"info\"` |  |")
+		assert.Equal(t, []string{"docs/NRJavaAgent/1.2.3/agent-config-linux.md"}, recorder.Results().SchemaDocs)
+		summary, err := os.ReadFile(summaryPath)
+		require.NoError(t, err)
+		assert.Contains(t, string(summary), "## Configuration of NRJavaAgent 1.2.3")
+		assert.Contains(t, string(summary), string(content))
+		stdout := getStdout()
+		assert.Contains(t, stdout, "Not documenting the windows agent-config configuration")
+		assert.Contains(t, stdout, "Documented 1 configuration schemas of NRJavaAgent version 1.2.3")
+	})
+
+	t.Run("rejects directory traversal", func(t *testing.T) {
+		t.Setenv("INPUT_SCHEMA_DOCS_DIRECTORY", "../docs")
+
+		err := writeSchemaDocs(context.Background(), t.TempDir(), "NRJavaAgent", "1.2.3", definitions)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid schema-docs-directory")
+	})
+}
+
+func TestWritePins(t *testing.T) {
+	record := func(ctx context.Context) {
+		results.RecordArtifacts(ctx, []models.ArtifactUploadResult{createSuccessfulUploadResult("linux-tar", "sha256:abc", "1.2.3")})
+		results.RecordIndex(ctx, "docker.io/newrelic/agents", "1.2.3", "sha256:index123")
+		results.RecordSigning(ctx, "sig-123", nil)
+	}
+
+	t.Run("disabled when pins-directory is not set", func(t *testing.T) {
+		workspace := t.TempDir()
+		t.Setenv("INPUT_PINS_DIRECTORY", "")
+
+		require.NoError(t, writePins(context.Background(), workspace, "NRJavaAgent", "1.2.3"))
+
+		entries, err := os.ReadDir(workspace)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("writes the pin file", func(t *testing.T) {
+		workspace := t.TempDir()
+		t.Setenv("INPUT_PINS_DIRECTORY", "pins")
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+		record(ctx)
+
+		getStdout, _ := testutil.CaptureOutput(t)
+		require.NoError(t, writePins(ctx, workspace, "NRJavaAgent", "1.2.3"))
+
+		content, err := os.ReadFile(filepath.Join(workspace, "pins", "NRJavaAgent", "1.2.3.yaml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "index: docker.io/newrelic/agents@sha256:index123")
+		assert.Contains(t, string(content), "id: sig-123")
+		assert.Equal(t, &results.Pins{Path: "pins/NRJavaAgent/1.2.3.yaml"}, recorder.Results().Pins)
+		assert.Contains(t, getStdout(), "Wrote digest pins for NRJavaAgent version 1.2.3")
+	})
+
+}
+
+func TestValidateDownstreamInputs(t *testing.T) {
+	t.Run("downstream-repository requires files to propose", func(t *testing.T) {
+		t.Setenv("INPUT_PINS_DIRECTORY", "")
+		t.Setenv("INPUT_EXPORT_DIRECTORY", "")
+		t.Setenv("INPUT_DOWNSTREAM_REPOSITORY", "newrelic/fleet-gitops")
+
+		err := validateDownstreamInputs()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "downstream-repository requires pins-directory or export-directory")
+	})
+
+	t.Run("rejects an invalid downstream-repository", func(t *testing.T) {
+		t.Setenv("INPUT_PINS_DIRECTORY", "pins")
+		t.Setenv("INPUT_DOWNSTREAM_REPOSITORY", "fleet-gitops")
+
+		err := validateDownstreamInputs()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid downstream-repository")
+	})
+
+	t.Run("rejects directory traversal", func(t *testing.T) {
+		t.Setenv("INPUT_PINS_DIRECTORY", "../pins")
+		t.Setenv("INPUT_DOWNSTREAM_REPOSITORY", "")
+
+		err := validateDownstreamInputs()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid pins-directory")
+	})
+}
+
+func TestProposeDownstream(t *testing.T) {
+	t.Run("disabled when downstream-repository is not set", func(t *testing.T) {
+		t.Setenv("INPUT_DOWNSTREAM_REPOSITORY", "")
+
+		require.NoError(t, proposeDownstream(context.Background(), t.TempDir(), "NRJavaAgent", "1.2.3"))
+	})
+
+	t.Run("proposes the pin and export files", func(t *testing.T) {
+		workspace := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(workspace, "pins", "NRJavaAgent"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(workspace, "pins", "NRJavaAgent", "1.2.3.yaml"), []byte("version: 1.2.3\n"), 0644))
+		require.NoError(t, os.MkdirAll(filepath.Join(workspace, "out", "agents", "NRJavaAgent"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(workspace, "out", "agents", "NRJavaAgent", "1.2.3.json"), []byte("{}"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(workspace, "out", "agents", "NRJavaAgent", "index.json"), []byte("[]"), 0644))
+
+		var trees []map[string]any
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "Bearer downstream-token", r.Header.Get("Authorization"))
+			switch {
+			case r.URL.Path == "/repos/newrelic/fleet-gitops":
+				_, _ = w.Write([]byte(`{"default_branch": "main"}`))
+			case r.URL.Path == "/repos/newrelic/fleet-gitops/git/ref/heads/agent-metadata/NRJavaAgent/1.2.3":
+				w.WriteHeader(http.StatusNotFound)
+			case r.URL.Path == "/repos/newrelic/fleet-gitops/git/ref/heads/main":
+				_, _ = w.Write([]byte(`{"object": {"sha": "main123"}}`))
+			case r.URL.Path == "/repos/newrelic/fleet-gitops/git/commits/main123":
+				_, _ = w.Write([]byte(`{"sha": "main123", "tree": {"sha": "maintree"}}`))
+			case r.URL.Path == "/repos/newrelic/fleet-gitops/git/trees":
+				var tree map[string]any
+				_ = json.NewDecoder(r.Body).Decode(&tree)
+				trees = append(trees, tree)
+				_, _ = w.Write([]byte(`{"sha": "newtree"}`))
+			case r.URL.Path == "/repos/newrelic/fleet-gitops/git/commits":
+				_, _ = w.Write([]byte(`{"sha": "commit456"}`))
+			case r.URL.Path == "/repos/newrelic/fleet-gitops/pulls" && r.Method == http.MethodGet:
+				_, _ = w.Write([]byte(`[]`))
+			case r.URL.Path == "/repos/newrelic/fleet-gitops/pulls":
+				_, _ = w.Write([]byte(`{"number": 7, "html_url": "https://github.com/newrelic/fleet-gitops/pull/7"}`))
+			default:
+				w.WriteHeader(http.StatusCreated)
+			}
+		}))
+		defer server.Close()
+
+		t.Setenv("GITHUB_API_URL", server.URL)
+		t.Setenv("INPUT_DOWNSTREAM_REPOSITORY", "newrelic/fleet-gitops")
+		t.Setenv("INPUT_DOWNSTREAM_TOKEN", "downstream-token")
+		t.Setenv("INPUT_EXPORT_DIRECTORY", "out")
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+		results.RecordPins(ctx, results.Pins{Path: "pins/NRJavaAgent/1.2.3.yaml"})
+
+		testutil.CaptureOutput(t)
+		require.NoError(t, proposeDownstream(ctx, workspace, "NRJavaAgent", "1.2.3"))
+
+		files := []string{"pins/NRJavaAgent/1.2.3.yaml", "out/agents/NRJavaAgent/1.2.3.json", "out/agents/NRJavaAgent/index.json"}
+		assert.Equal(t, &results.PullRequest{
+			Repository: "newrelic/fleet-gitops",
+			URL:        "https://github.com/newrelic/fleet-gitops/pull/7",
+			Files:      files,
+		}, recorder.Results().PullRequest)
+		require.Len(t, trees, 1)
+		assert.Len(t, trees[0]["tree"], 3)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Setenv("INPUT_DOWNSTREAM_REPOSITORY", "newrelic/fleet-gitops")
+		t.Setenv("INPUT_EXPORT_DIRECTORY", "out")
+
+		err := proposeDownstream(context.Background(), t.TempDir(), "NRJavaAgent", "1.2.3")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read out/agents/NRJavaAgent/1.2.3.json")
+	})
+}
+
+func TestRunAgentFlow_RecordsResults(t *testing.T) {
+	originalOCIHandler := ociHandleUploadsFunc
+	ociHandleUploadsFunc = func(ctx context.Context, cfg *models.OCIConfig, workspace, version string) (string, error) {
+		results.RecordArtifacts(ctx, []models.ArtifactUploadResult{createSuccessfulUploadResult("linux-tar", "sha256:abc", version)})
+		results.RecordIndex(ctx, cfg.Registry, version, "sha256:index123")
+		return "sha256:index123", nil
+	}
+	defer func() { ociHandleUploadsFunc = originalOCIHandler }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	projectRoot, err := filepath.Abs("../..")
+	require.NoError(t, err)
+	workspace := filepath.Join(projectRoot, "integration-test", "agent-flow")
+
+	t.Setenv("NEWRELIC_TOKEN", "test-token")
+	t.Setenv("INPUT_OCI_REGISTRY", "docker.io/newrelic/agents")
+	t.Setenv("INPUT_BINARIES", `[{"name":"linux-tar","path":"./dist/agent.tar.gz","os":"linux","arch":"amd64","format":"tar+gzip"}]`)
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
+	t.Setenv("SIGNING_SERVICE_URL", server.URL)
+	testutil.CaptureOutput(t)
+
+	recorder := results.NewRecorder()
+	ctx := results.WithRecorder(context.Background(), recorder)
+
+	// method under test
+	err = runAgentFlow(ctx, &mockFailingMetadataClient{}, workspace, "NRJavaAgent", "1.2.3")
+	require.Error(t, err)
+
+	recorded := recorder.Results()
+	require.NotNil(t, recorded.Configs)
+	assert.NotZero(t, recorded.Configs.ConfigurationDefinitions)
+	require.NotNil(t, recorded.Index)
+	assert.Equal(t, "sha256:index123", recorded.Index.Digest)
+	assert.True(t, recorded.Index.Signed)
+	require.Len(t, recorded.Artifacts, 1)
+	assert.Equal(t, int64(1024), recorded.Artifacts[0].Size)
+	assert.True(t, recorded.Artifacts[0].Signed)
+	require.Len(t, recorded.Payloads, 1)
+	assert.False(t, recorded.Payloads[0].Submitted)
+	assert.Equal(t, assert.AnError.Error(), recorded.Payloads[0].Error)
+}
+
+func TestAnnotateValidationError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected []string
+	}{
+		{
+			name: "one annotation per detail",
+			err: retry.NewNonRetryableError(&client.ValidationError{
+				Message: "metadata is invalid",
+				Details: []client.FieldError{{Field: "metadata.version", Message: "is required"}, {Message: "unknown platform"}},
+			}),
+			expected: []string{"metadata.version: is required", "unknown platform"},
+		},
+		{
+			name:     "message when there are no details",
+			err:      &client.ValidationError{Message: "metadata is invalid"},
+			expected: []string{"metadata is invalid"},
+		},
+		{
+			name: "other errors are ignored",
+			err:  assert.AnError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			annotations := github.NewAnnotationCollector()
+			ctx := github.WithAnnotationCollector(context.Background(), annotations)
+
+			// method under test
+			annotateValidationError(ctx, fmt.Errorf("wrapped: %w", tt.err))
+
+			var messages []string
+			for _, annotation := range annotations.Annotations() {
+				assert.Equal(t, github.AnnotationFailure, annotation.AnnotationLevel)
+				assert.Equal(t, "Metadata rejected", annotation.Title)
+				messages = append(messages, annotation.Message)
+			}
+			assert.Equal(t, tt.expected, messages)
+		})
+	}
+}
+
+func TestWorkspaceRelativePath(t *testing.T) {
+	// method under test
+	path, err := workspaceRelativePath("results-file", "./out/a..b/results.json")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("out", "a..b", "results.json"), path)
+
+	for _, value := range []string{"../results.json", "out/../../results.json", "/tmp/results.json"} {
+		// method under test
+		_, err := workspaceRelativePath("results-file", value)
+		assert.EqualError(t, err, "invalid results-file "+value+": must be relative to the repository root without directory traversal")
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	t.Run("disabled when results-file is not set", func(t *testing.T) {
+		workspace := t.TempDir()
+		t.Setenv("INPUT_RESULTS_FILE", "")
+
+		require.NoError(t, writeResults(context.Background(), workspace, results.NewRecorder()))
+
+		entries, err := os.ReadDir(workspace)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("writes the run outcome", func(t *testing.T) {
+		workspace := t.TempDir()
+		t.Setenv("INPUT_RESULTS_FILE", "out/results.json")
+		recorder := results.NewRecorder()
+		recorder.SetRun(results.Run{AgentType: "NRJavaAgent", Version: "1.2.3", Repository: "newrelic/agent-metadata-action"})
+		recorder.Finish(assert.AnError)
+
+		getStdout, _ := testutil.CaptureOutput(t)
+		require.NoError(t, writeResults(context.Background(), workspace, recorder))
+
+		data, err := os.ReadFile(filepath.Join(workspace, "out", "results.json"))
+		require.NoError(t, err)
+		var written results.Results
+		require.NoError(t, json.Unmarshal(data, &written))
+		assert.Equal(t, "NRJavaAgent", written.AgentType)
+		assert.Equal(t, results.OutcomeFailure, written.Outcome)
+		assert.Equal(t, assert.AnError.Error(), written.Error)
+		assert.Contains(t, getStdout(), "Wrote run results to out/results.json")
+	})
+
+	t.Run("rejects directory traversal", func(t *testing.T) {
+		t.Setenv("INPUT_RESULTS_FILE", "../results.json")
+		testutil.CaptureOutput(t)
+
+		err := writeResults(context.Background(), t.TempDir(), results.NewRecorder())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid results-file")
+	})
+}
+
+func TestRun_AgentFlowAgainstMockServer(t *testing.T) {
+	server := mockserver.New("mock-token")
+	// The first submission fails with a retryable error to exercise the client's retry
+	server.AddFault(mockserver.Fault{Method: "POST", Path: "/v1/agents", Statuses: []int{http.StatusServiceUnavailable}})
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	projectRoot, err := filepath.Abs("../..")
+	require.NoError(t, err)
+
+	t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+	t.Setenv("INPUT_VERSION", "1.2.3")
+	t.Setenv("GITHUB_WORKSPACE", filepath.Join(projectRoot, "integration-test", "agent-flow"))
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
+	t.Setenv("METADATA_SERVICE_URL", ts.URL)
+	t.Setenv("INPUT_OCI_REGISTRY", "")
+	t.Setenv("INPUT_GITHUB_TOKEN", "")
+	outputPath := filepath.Join(t.TempDir(), "output")
+	t.Setenv("GITHUB_OUTPUT", outputPath)
+
+	getStdout, _ := testutil.CaptureOutput(t)
+
+	// method under test
+	err = run(nil)
+	require.NoError(t, err)
+
+	assert.Contains(t, getStdout(), "Successfully sent metadata for NRJavaAgent version 1.2.3")
+	outputs, err := os.ReadFile(outputPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(outputs), "metadata-ids=NRJavaAgent@1.2.3\n")
+	requests := server.Requests()
+	require.Len(t, requests, 6)
+	assert.Equal(t, "/v1/agents", requests[0].Path)
+	assert.Equal(t, "PUT /v1/agents/NRJavaAgent/versions/1.2.3/lock", requests[1].Method+" "+requests[1].Path)
+	assert.Equal(t, "/v1/capabilities", requests[2].Path)
+	assert.Equal(t, http.StatusServiceUnavailable, requests[3].Status)
+	assert.Equal(t, http.StatusOK, requests[4].Status)
+	assert.Equal(t, models.PayloadV3, requests[4].Header.Get("Accept-Version"))
+	assert.Equal(t, "DELETE /v1/agents/NRJavaAgent/versions/1.2.3/lock", requests[5].Method+" "+requests[5].Path)
+	_, locked := server.Lock("NRJavaAgent", "1.2.3")
+	assert.False(t, locked, "The release lock is released at the end of the run")
+
+	stored, ok := server.Metadata("NRJavaAgent", "1.2.3")
+	require.True(t, ok)
+	var metadata models.AgentMetadata
+	require.NoError(t, json.Unmarshal(stored, &metadata))
+	assert.NotEmpty(t, metadata.ConfigurationDefinitions)
+	assert.Equal(t, "1.2.3", metadata.Metadata["version"])
+}
+
+func TestLockRelease(t *testing.T) {
+	server := mockserver.New("")
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/newrelic-java-agent")
+	t.Setenv("GITHUB_RUN_ID", "42")
+	t.Setenv("GITHUB_RUN_ATTEMPT", "1")
+	t.Setenv("GITHUB_JOB", "release")
+	testutil.CaptureOutput(t)
+
+	t.Run("holds the lock until released", func(t *testing.T) {
+		server.Reset()
+
+		// method under test
+		unlock, err := lockRelease(context.Background(), newInstrumentationClient(ts.URL, "token"), "NRJavaAgent", "1.2.3")
+
+		require.NoError(t, err)
+		lock, locked := server.Lock("NRJavaAgent", "1.2.3")
+		require.True(t, locked)
+		assert.Equal(t, "newrelic/newrelic-java-agent run 42 attempt 1 job release", lock.Owner)
+		assert.WithinDuration(t, time.Now().Add(30*time.Minute), lock.ExpiresAt, time.Minute)
+		unlock()
+		_, locked = server.Lock("NRJavaAgent", "1.2.3")
+		assert.False(t, locked)
+	})
+
+	t.Run("fails while another run releases the version", func(t *testing.T) {
+		server.Reset()
+		server.SetLock("NRJavaAgent", "1.2.3", models.ReleaseLock{Owner: "newrelic/newrelic-java-agent run 41", ExpiresAt: time.Now().Add(10 * time.Minute)})
+
+		// method under test
+		_, err := lockRelease(context.Background(), newInstrumentationClient(ts.URL, "token"), "NRJavaAgent", "1.2.3")
+
+		assert.ErrorContains(t, err, "release of NRJavaAgent version 1.2.3 already in progress by newrelic/newrelic-java-agent run 41")
+	})
+
+	t.Run("two jobs of the same run contend for the lock", func(t *testing.T) {
+		server.Reset()
+		unlock, err := lockRelease(context.Background(), newInstrumentationClient(ts.URL, "token"), "NRJavaAgent", "1.2.3")
+		require.NoError(t, err)
+		defer unlock()
+		t.Setenv("GITHUB_JOB", "release-windows")
+
+		// method under test
+		_, err = lockRelease(context.Background(), newInstrumentationClient(ts.URL, "token"), "NRJavaAgent", "1.2.3")
+
+		assert.ErrorContains(t, err, "release of NRJavaAgent version 1.2.3 already in progress by newrelic/newrelic-java-agent run 42 attempt 1 job release")
+	})
+
+	t.Run("a re-run doesn't take over the lock of an earlier attempt", func(t *testing.T) {
+		server.Reset()
+		server.SetLock("NRJavaAgent", "1.2.3", models.ReleaseLock{Owner: "newrelic/newrelic-java-agent run 42 attempt 1 job release", ExpiresAt: time.Now().Add(10 * time.Minute)})
+		t.Setenv("GITHUB_RUN_ATTEMPT", "2")
+
+		// method under test
+		_, err := lockRelease(context.Background(), newInstrumentationClient(ts.URL, "token"), "NRJavaAgent", "1.2.3")
+
+		assert.ErrorContains(t, err, "already in progress by newrelic/newrelic-java-agent run 42 attempt 1 job release")
+	})
+
+	t.Run("dry runs and release-lock-ttl 0 don't lock", func(t *testing.T) {
+		server.Reset()
+		for _, env := range [][2]string{{"INPUT_DRY_RUN", "true"}, {"INPUT_RELEASE_LOCK_TTL", "0"}} {
+			t.Run(env[0], func(t *testing.T) {
+				t.Setenv(env[0], env[1])
+
+				// method under test
+				unlock, err := lockRelease(context.Background(), newInstrumentationClient(ts.URL, "token"), "NRJavaAgent", "1.2.3")
+
+				require.NoError(t, err)
+				unlock()
+				assert.Empty(t, server.Requests())
+			})
+		}
+	})
+
+	t.Run("services without release locks only warn", func(t *testing.T) {
+		unsupported := httptest.NewServer(http.NotFoundHandler())
+		defer unsupported.Close()
+
+		// method under test
+		unlock, err := lockRelease(context.Background(), newInstrumentationClient(unsupported.URL, "token"), "NRJavaAgent", "1.2.3")
+
+		require.NoError(t, err)
+		unlock()
+	})
+}
+
+func TestRun_StrictContract(t *testing.T) {
+	server := mockserver.New("")
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	originalTransport := http.DefaultTransport
+	defer func() { http.DefaultTransport = originalTransport }()
+
+	projectRoot, err := filepath.Abs("../..")
+	require.NoError(t, err)
+
+	t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+	t.Setenv("GITHUB_WORKSPACE", filepath.Join(projectRoot, "integration-test", "agent-flow"))
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
+	t.Setenv("METADATA_SERVICE_URL", ts.URL)
+	t.Setenv("INPUT_OCI_REGISTRY", "")
+	t.Setenv("INPUT_GITHUB_TOKEN", "")
+	t.Setenv("INPUT_STRICT_CONTRACT", "true")
+
+	t.Run("conforming payload is sent", func(t *testing.T) {
+		t.Setenv("INPUT_VERSION", "1.2.3")
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := run(nil)
+		require.NoError(t, err)
+
+		assert.Contains(t, getStdout(), "Strict contract mode enabled")
+		_, ok := server.Metadata("NRJavaAgent", "1.2.3")
+		assert.True(t, ok)
+		requests := server.Requests()
+		submission := requests[len(requests)-2]
+		assert.Equal(t, http.MethodPost, submission.Method, "The submission is followed by the release of the lock")
+		assert.Equal(t, models.PayloadV3, submission.Header.Get("Accept-Version"))
+	})
+
+	t.Run("violation is not sent", func(t *testing.T) {
+		server.Reset()
+		t.Setenv("INPUT_VERSION", "-1.2.4")
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := run(nil)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "contract violation")
+		assert.Contains(t, err.Error(), `path parameter agentVersion "-1.2.4" does not match pattern`)
+		// Only the agent type catalog and capability probe reach the service
+		for _, req := range server.Requests() {
+			assert.Equal(t, http.MethodGet, req.Method)
+		}
+	})
+}
+
+func TestRun_UnknownAgentType(t *testing.T) {
+	server := mockserver.New("")
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgnet")
+	t.Setenv("INPUT_VERSION", "1.2.3")
+	t.Setenv("GITHUB_WORKSPACE", t.TempDir())
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
+	t.Setenv("METADATA_SERVICE_URL", ts.URL)
+	t.Setenv("INPUT_OCI_REGISTRY", "")
+	t.Setenv("INPUT_GITHUB_TOKEN", "")
+	testutil.CaptureOutput(t)
+
+	// method under test
+	err := run(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid agent-type: unknown agent type "NRJavaAgnet" - did you mean "NRJavaAgent"?`)
+	requests := server.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "/v1/agents", requests[0].Path)
+}
+
+func TestValidateAgentType_BuiltInFallback(t *testing.T) {
+	getStdout, _ := testutil.CaptureOutput(t)
+
+	// method under test
+	err := validateAgentType(context.Background(), &mockMetadataClient{}, "NRNodeAgnet")
+
+	require.NoError(t, err, "the built-in list only warns")
+	assert.Contains(t, getStdout(), `::warn::unknown agent type "NRNodeAgnet" - did you mean "NRNodeAgent"? (checked against the built-in list, which may be out of date)`)
+}
+
+func TestRunPreflight(t *testing.T) {
+	var checked []preflight.Check
+	originalPreflight := preflightFunc
+	preflightFunc = func(ctx context.Context, checks []preflight.Check) error {
+		checked = checks
+		return nil
+	}
+	defer func() { preflightFunc = originalPreflight }()
+
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
+	t.Setenv("METADATA_SERVICE_URL", "http://metadata.test")
+	t.Setenv("SIGNING_SERVICE_URL", "http://signing.test")
+	t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+	t.Setenv("INPUT_VERSION", "1.2.3")
+	t.Setenv("INPUT_DRY_RUN", "")
+
+	t.Run("docs and metadata only runs check the instrumentation service", func(t *testing.T) {
+		t.Setenv("INPUT_OCI_REGISTRY", "")
+
+		require.NoError(t, runPreflight(context.Background()))
+		assert.Equal(t, []preflight.Check{{Name: "instrumentation metadata service", URL: "http://metadata.test/v1/health"}}, checked)
+	})
+
+	t.Run("binary uploads also check the signing service and registry", func(t *testing.T) {
+		t.Setenv("INPUT_OCI_REGISTRY", "localhost:5000/agents")
+		t.Setenv("INPUT_BINARIES", `[{"name":"agent","path":"./agent.tar.gz","os":"linux","arch":"amd64","format":"tar+gzip"}]`)
+
+		require.NoError(t, runPreflight(context.Background()))
+		assert.Equal(t, []preflight.Check{
+			{Name: "instrumentation metadata service", URL: "http://metadata.test/v1/health"},
+			{Name: "signing service", URL: "http://signing.test/v1/health"},
+			{Name: "OCI registry localhost:5000", URL: "http://localhost:5000/v2/"},
+		}, checked)
+	})
+
+	t.Run("upload phases check the registry but not the signing service", func(t *testing.T) {
+		t.Setenv("INPUT_OCI_REGISTRY", "localhost:5000/agents")
+		t.Setenv("INPUT_BINARIES", `[{"name":"agent","path":"./agent.tar.gz","os":"linux","arch":"amd64","format":"tar+gzip"}]`)
+		t.Setenv("INPUT_PHASES", "upload")
+
+		require.NoError(t, runPreflight(context.Background()))
+		assert.Equal(t, []preflight.Check{
+			{Name: "instrumentation metadata service", URL: "http://metadata.test/v1/health"},
+			{Name: "OCI registry localhost:5000", URL: "http://localhost:5000/v2/"},
+		}, checked)
+	})
+
+	t.Run("sign phases of an earlier upload check the signing service", func(t *testing.T) {
+		t.Setenv("INPUT_OCI_REGISTRY", "")
+		t.Setenv("INPUT_BINARIES", "")
+		t.Setenv("INPUT_PHASES", "sign")
+
+		require.NoError(t, runPreflight(context.Background()))
+		assert.Equal(t, []preflight.Check{
+			{Name: "instrumentation metadata service", URL: "http://metadata.test/v1/health"},
+			{Name: "signing service", URL: "http://signing.test/v1/health"},
+		}, checked)
+	})
+
+	t.Run("promotions check the registry but not the signing service", func(t *testing.T) {
+		t.Setenv("INPUT_MODE", "promote")
+		t.Setenv("INPUT_OCI_REGISTRY", "localhost:5000/agents")
+		t.Setenv("INPUT_BINARIES", "")
+
+		require.NoError(t, runPreflight(context.Background()))
+		assert.Equal(t, []preflight.Check{
+			{Name: "instrumentation metadata service", URL: "http://metadata.test/v1/health"},
+			{Name: "OCI registry localhost:5000", URL: "http://localhost:5000/v2/"},
+		}, checked)
+	})
+
+	t.Run("cleanups check the registry but not the signing service", func(t *testing.T) {
+		t.Setenv("INPUT_MODE", "cleanup")
+		t.Setenv("INPUT_OCI_REGISTRY", "localhost:5000/agents")
+
+		require.NoError(t, runPreflight(context.Background()))
+		assert.Equal(t, []preflight.Check{
+			{Name: "instrumentation metadata service", URL: "http://metadata.test/v1/health"},
+			{Name: "OCI registry localhost:5000", URL: "http://localhost:5000/v2/"},
+		}, checked)
+	})
+
+	t.Run("dry runs are not checked", func(t *testing.T) {
+		checked = nil
+		t.Setenv("INPUT_DRY_RUN", "true")
+		testutil.CaptureOutput(t)
+
+		require.NoError(t, runPreflight(context.Background()))
+		assert.Nil(t, checked)
+	})
+}
+
+func TestRunPreflight_GitHubToken(t *testing.T) {
+	originalPreflight, originalCheckToken := preflightFunc, github.CheckTokenFunc
+	defer func() { preflightFunc, github.CheckTokenFunc = originalPreflight, originalCheckToken }()
+	preflightFunc = func(ctx context.Context, checks []preflight.Check) error { return nil }
+	var checked *github.TokenNeeds
+	github.CheckTokenFunc = func(ctx context.Context, needs github.TokenNeeds) error {
+		checked = &needs
+		if len(needs.ReadRepos) > 0 && needs.ReadRepos[0] == "newrelic/private-schemas" {
+			return fmt.Errorf("github-token can't read newrelic/private-schemas")
+		}
+		return nil
+	}
+
+	workspace := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(workspace, ".fleetControl"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, ".fleetControl", "configurationDefinitions.yml"),
+		[]byte("configurationDefinitions:\n  - type: agent-config\n    schema: newrelic/fleet-schemas:config.json@v1\n"), 0644))
+	t.Setenv("GITHUB_WORKSPACE", workspace)
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/java-agent")
+	t.Setenv("GITHUB_SHA", "abc123")
+	t.Setenv("INPUT_DRY_RUN", "")
+
+	t.Run("no token", func(t *testing.T) {
+		checked = nil
+		t.Setenv("INPUT_GITHUB_TOKEN", "")
+
+		require.NoError(t, runPreflight(context.Background()))
+		assert.Nil(t, checked)
+	})
+
+	t.Run("agent release", func(t *testing.T) {
+		t.Setenv("INPUT_GITHUB_TOKEN", "gh-token")
+		t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+		t.Setenv("INPUT_VERSION", "1.2.3")
+
+		require.NoError(t, runPreflight(context.Background()))
+		assert.Equal(t, &github.TokenNeeds{CheckRunRepo: "newrelic/java-agent", ReadRepos: []string{"newrelic/fleet-schemas"}}, checked)
+	})
+
+	t.Run("docs run", func(t *testing.T) {
+		t.Setenv("INPUT_GITHUB_TOKEN", "gh-token")
+
+		require.NoError(t, runPreflight(context.Background()))
+		assert.Equal(t, &github.TokenNeeds{CheckRunRepo: "newrelic/java-agent"}, checked)
+	})
+
+	t.Run("missing permission", func(t *testing.T) {
+		t.Setenv("INPUT_GITHUB_TOKEN", "gh-token")
+		t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+		t.Setenv("INPUT_VERSION", "1.2.3")
+		require.NoError(t, os.WriteFile(filepath.Join(workspace, ".fleetControl", "configurationDefinitions.yml"),
+			[]byte("configurationDefinitions:\n  - type: agent-config\n    schema: newrelic/private-schemas:config.json@v1\n"), 0644))
+
+		err := runPreflight(context.Background())
+
+		assert.EqualError(t, err, "github-token pre-flight check failed: github-token can't read newrelic/private-schemas")
+	})
+}
+
+func TestRun_BackendUnreachable(t *testing.T) {
+	originalPreflight := preflightFunc
+	preflightFunc = preflight.Run
+	defer func() { preflightFunc = originalPreflight }()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+	t.Setenv("INPUT_VERSION", "1.2.3")
+	t.Setenv("GITHUB_WORKSPACE", t.TempDir())
+	t.Setenv("NEWRELIC_TOKEN", "mock-token")
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
+	t.Setenv("METADATA_SERVICE_URL", closed.URL)
+	t.Setenv("INPUT_OCI_REGISTRY", "")
+	t.Setenv("INPUT_GITHUB_TOKEN", "")
+	testutil.CaptureOutput(t)
+
+	// method under test
+	err := run(nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "backend unreachable: instrumentation metadata service")
+}
+
+func TestWriteSARIF(t *testing.T) {
+	annotations := []github.Annotation{
+		{Path: ".fleetControl/schema.json", StartLine: 3, StartColumn: 5, AnnotationLevel: github.AnnotationWarning, Title: "Lint: property-title", Message: "no title", Rule: "lint/property-title"},
+		{Path: "src/content/docs/release-notes/java.mdx", StartLine: 1, AnnotationLevel: github.AnnotationFailure, Title: "Missing version", Message: "version is required"},
+		{Path: ".github/workflows/release.yml", StartLine: 1, AnnotationLevel: github.AnnotationFailure, Title: "Invalid artifact", Message: "binary file is empty"},
+	}
+
+	t.Run("disabled when sarif-file is not set", func(t *testing.T) {
+		workspace := t.TempDir()
+		t.Setenv("INPUT_SARIF_FILE", "")
+
+		require.NoError(t, writeSARIF(context.Background(), workspace, annotations))
+
+		entries, err := os.ReadDir(workspace)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("writes every finding", func(t *testing.T) {
+		workspace := t.TempDir()
+		t.Setenv("INPUT_SARIF_FILE", "out/findings.sarif")
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		require.NoError(t, writeSARIF(context.Background(), workspace, annotations))
+
+		data, err := os.ReadFile(filepath.Join(workspace, "out", "findings.sarif"))
+		require.NoError(t, err)
+		var log struct {
+			Runs []struct {
+				Tool struct {
+					Driver struct {
+						Rules []struct {
+							ID               string `json:"id"`
+							ShortDescription struct {
+								Text string `json:"text"`
+							} `json:"shortDescription"`
+						} `json:"rules"`
+					} `json:"driver"`
+				} `json:"tool"`
+				Results []struct {
+					RuleID string `json:"ruleId"`
+					Level  string `json:"level"`
+				} `json:"results"`
+			} `json:"runs"`
+		}
+		require.NoError(t, json.Unmarshal(data, &log))
+		require.Len(t, log.Runs, 1)
+		rules := log.Runs[0].Tool.Driver.Rules
+		require.Len(t, rules, 3)
+		assert.Equal(t, "lint/property-title", rules[0].ID)
+		assert.Equal(t, "Every schema property must have a title", rules[0].ShortDescription.Text)
+		assert.Equal(t, "missing-version", rules[1].ID)
+		assert.Equal(t, "Missing version", rules[1].ShortDescription.Text)
+		require.Len(t, log.Runs[0].Results, 3)
+		assert.Equal(t, "warning", log.Runs[0].Results[0].Level)
+		assert.Equal(t, "invalid-artifact", log.Runs[0].Results[2].RuleID)
+		assert.Equal(t, "error", log.Runs[0].Results[2].Level)
+		assert.Contains(t, getStdout(), "Wrote 3 finding(s) to out/findings.sarif")
+	})
+
+	t.Run("honors the deprecated lint-sarif-file input", func(t *testing.T) {
+		workspace := t.TempDir()
+		t.Setenv("INPUT_SARIF_FILE", "")
+		t.Setenv("INPUT_LINT_SARIF_FILE", "lint.sarif")
+		testutil.CaptureOutput(t)
+
+		require.NoError(t, writeSARIF(context.Background(), workspace, nil))
+
+		assert.FileExists(t, filepath.Join(workspace, "lint.sarif"))
+	})
+
+	t.Run("rejects directory traversal", func(t *testing.T) {
+		t.Setenv("INPUT_SARIF_FILE", "../findings.sarif")
+		testutil.CaptureOutput(t)
+
+		err := writeSARIF(context.Background(), t.TempDir(), annotations)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid sarif-file")
+	})
+}
+
+func TestLintConfigDirectory(t *testing.T) {
+	workspace := t.TempDir()
+	configDir := filepath.Join(workspace, ".fleetControl")
+	require.NoError(t, os.MkdirAll(configDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "configurationDefinitions.yml"), []byte(`configurationDefinitions:
+  - platform: ALL
+    type: agent-config
+    version: 1.0.0
+    schema: ./schema.json
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "schema.json"), []byte(`{"type": "object", "additionalProperties": true}`), 0644))
+
+	t.Run("warnings by default", func(t *testing.T) {
+		getStdout, _ := testutil.CaptureOutput(t)
+		annotations := github.NewAnnotationCollector()
+		ctx := github.WithAnnotationCollector(context.Background(), annotations)
+
+		// method under test
+		err := lintConfigDirectory(ctx, workspace, config.Placeholders{})
+
+		require.NoError(t, err)
+		assert.Contains(t, getStdout(), "[top-level-additional-properties]")
+		require.Len(t, annotations.Annotations(), 1)
+		assert.Equal(t, github.Annotation{
+			Path:            ".fleetControl/schema.json",
+			StartLine:       1,
+			EndLine:         1,
+			StartColumn:     44,
+			AnnotationLevel: github.AnnotationWarning,
+			Title:           "Lint: top-level-additional-properties",
+			Message:         "#: additionalProperties: true accepts any top-level key - list the supported properties instead",
+			Rule:            "lint/top-level-additional-properties",
+		}, annotations.Annotations()[0])
+	})
+
+	t.Run("errors fail the run", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(configDir, "lint.yml"), []byte("rules:\n  top-level-additional-properties:\n    severity: error\n"), 0644))
+		defer os.Remove(filepath.Join(configDir, "lint.yml"))
+		testutil.CaptureOutput(t)
+		annotations := github.NewAnnotationCollector()
+		ctx := github.WithAnnotationCollector(context.Background(), annotations)
+
+		// method under test
+		err := lintConfigDirectory(ctx, workspace, config.Placeholders{})
+
+		require.Error(t, err)
+		assert.Equal(t, "schema lint found 1 error(s)", err.Error())
+		assert.Equal(t, github.AnnotationFailure, annotations.Annotations()[0].AnnotationLevel)
+	})
+
+	t.Run("invalid lint configuration", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(configDir, "lint.yml"), []byte("rules:\n  no-such-rule: {}\n"), 0644))
+		defer os.Remove(filepath.Join(configDir, "lint.yml"))
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := lintConfigDirectory(context.Background(), workspace, config.Placeholders{})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown lint rule "no-such-rule"`)
+	})
+}
+
+func TestSubmitMetadata(t *testing.T) {
+	schema := base64.StdEncoding.EncodeToString([]byte(`{"type": "object"}`))
+	metadata := &models.AgentMetadata{
+		Metadata: models.Metadata{"version": "1.2.3"},
+		ConfigurationDefinitions: []models.ConfigurationDefinition{
+			{"platform": "HOST", "type": "agent-config", "schema": schema},
+			{"platform": "HOST", "type": "logging", "description": "Logging"},
+		},
+	}
+	baseline := &models.AgentMetadata{
+		Metadata: models.Metadata{"version": "1.2.3"},
+		ConfigurationDefinitions: []models.ConfigurationDefinition{
+			{"platform": "HOST", "type": "agent-config", "schema": schema},
+			{"platform": "HOST", "type": "legacy"},
+		},
+	}
+
+	t.Run("full by default", func(t *testing.T) {
+		c := &mockIncrementalClient{baseline: baseline}
+
+		// method under test
+		_, err := submitMetadata(context.Background(), c, "NRJavaAgent", "1.2.3", metadata, nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, []*models.AgentMetadata{metadata}, c.full)
+		assert.Empty(t, c.incremental)
+	})
+
+	t.Run("incremental against the service", func(t *testing.T) {
+		t.Setenv("INPUT_SUBMISSION", "incremental")
+		getStdout, _ := testutil.CaptureOutput(t)
+		c := &mockIncrementalClient{baseline: baseline}
+
+		// method under test
+		_, err := submitMetadata(context.Background(), c, "NRJavaAgent", "1.2.3", metadata, nil)
+
+		require.NoError(t, err)
+		assert.Empty(t, c.full)
+		require.Len(t, c.incremental, 1)
+		assert.Equal(t, []models.ConfigurationDefinition{metadata.ConfigurationDefinitions[1]}, c.incremental[0].ConfigurationDefinitions)
+		assert.Equal(t, models.RemovedDefinitions{ConfigurationDefinitions: []models.ConfigurationDefinition{{"platform": "HOST", "type": "legacy"}}}, c.removed[0])
+		assert.Contains(t, getStdout(), "Submitting 2 added, changed or removed definitions of NRJavaAgent version 1.2.3 incrementally")
+	})
+
+	t.Run("incremental against the snapshot", func(t *testing.T) {
+		t.Setenv("INPUT_SUBMISSION", "incremental")
+		t.Setenv("INPUT_INCREMENTAL_BASELINE", "snapshot")
+		testutil.CaptureOutput(t)
+		c := &mockIncrementalClient{}
+
+		// method under test
+		_, err := submitMetadata(context.Background(), c, "NRJavaAgent", "1.2.3", metadata, export.Resolve(baseline))
+
+		require.NoError(t, err)
+		require.Len(t, c.incremental, 1)
+		assert.Equal(t, []models.ConfigurationDefinition{metadata.ConfigurationDefinitions[1]}, c.incremental[0].ConfigurationDefinitions)
+	})
+
+	t.Run("no baseline", func(t *testing.T) {
+		t.Setenv("INPUT_SUBMISSION", "incremental")
+		getStdout, _ := testutil.CaptureOutput(t)
+		c := &mockIncrementalClient{}
+
+		// method under test
+		_, err := submitMetadata(context.Background(), c, "NRJavaAgent", "1.2.3", metadata, nil)
+
+		require.NoError(t, err)
+		assert.Len(t, c.full, 1)
+		assert.Empty(t, c.incremental)
+		assert.Contains(t, getStdout(), "No service baseline for NRJavaAgent version 1.2.3 - submitting in full")
+	})
+
+	t.Run("client without incremental support", func(t *testing.T) {
+		t.Setenv("INPUT_SUBMISSION", "incremental")
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		_, err := submitMetadata(context.Background(), &mockMetadataClient{}, "NRJavaAgent", "1.2.3", metadata, nil)
+
+		require.NoError(t, err)
+		assert.Contains(t, getStdout(), "::warn::The metadata client doesn't support incremental submissions - submitting in full")
+	})
+}
+
+func TestCheckDeprecatedDefinitions(t *testing.T) {
+	workspace := t.TempDir()
+	stubPreviousRelease := func(t *testing.T, content, tag string) {
+		original := github.PreviousReleaseFileFunc
+		github.PreviousReleaseFileFunc = func(ctx context.Context, ws, path string) ([]byte, string, error) {
+			assert.Equal(t, filepath.Join(".fleetControl", "configurationDefinitions.yml"), path)
+			if content == "" {
+				return nil, tag, nil
+			}
+			return []byte(content), tag, nil
+		}
+		t.Cleanup(func() { github.PreviousReleaseFileFunc = original })
+	}
+	definitions := []models.ConfigurationDefinition{
+		{"type": "agent-config"},
+		{"type": "legacy-config", "deprecated": true, "supersededBy": "agent-config"},
+	}
+
+	tests := []struct {
+		name     string
+		previous string
+		tag      string
+		wantErr  string
+	}{
+		{name: "deprecated type was released", previous: "configurationDefinitions:\n  - type: legacy-config\n", tag: "v1.0.0"},
+		{name: "no previous release", tag: ""},
+		{name: "deprecated type newly introduced", previous: "configurationDefinitions:\n  - type: agent-config\n", tag: "v1.0.0",
+			wantErr: "deprecated configuration definition types not in the previous release v1.0.0: legacy-config"},
+		{name: "file not in the previous release", tag: "v1.0.0",
+			wantErr: "deprecated configuration definition types not in the previous release v1.0.0: legacy-config"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubPreviousRelease(t, tt.previous, tt.tag)
+			getStdout, _ := testutil.CaptureOutput(t)
+			annotations := github.NewAnnotationCollector()
+			ctx := github.WithAnnotationCollector(context.Background(), annotations)
+
+			// method under test
+			err := checkDeprecatedDefinitions(ctx, workspace, definitions)
+
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				assert.Empty(t, annotations.Annotations())
+				return
+			}
+			require.EqualError(t, err, tt.wantErr)
+			assert.Contains(t, getStdout(), "::error::configuration definition type legacy-config is deprecated but was not in the previous release v1.0.0 - remove it instead")
+			require.Len(t, annotations.Annotations(), 1)
+			assert.Equal(t, "Deprecated type newly introduced", annotations.Annotations()[0].Title)
+		})
+	}
+
+	t.Run("nothing deprecated", func(t *testing.T) {
+		stubPreviousRelease(t, "", "v1.0.0")
+
+		// method under test
+		err := checkDeprecatedDefinitions(context.Background(), workspace, definitions[:1])
+
+		require.NoError(t, err)
+	})
+}
+
+func TestAddChangelogs(t *testing.T) {
+	previousSchema := `{"type": "object", "properties": {"log_file": {"type": "string"}, "log_level": {"type": "string", "default": "info"}}}`
+	newSchema := `{"type": "object", "properties": {"log_level": {"type": "string", "default": "warn"}, "proxy": {"type": "object", "properties": {"host": {"type": "string"}}}}}`
+	original := github.PreviousReleaseFileFunc
+	github.PreviousReleaseFileFunc = func(ctx context.Context, ws, path string) ([]byte, string, error) {
+		switch filepath.ToSlash(path) {
+		case ".fleetControl/configurationDefinitions.yml":
+			return []byte("configurationDefinitions:\n  - type: agent-config\n    platform: ALL\n    schema: ./schemas/agent-config.json\n  - type: logging\n    platform: ALL\n"), "v1.0.0", nil
+		case ".fleetControl/schemas/agent-config.json":
+			return []byte(previousSchema), "v1.0.0", nil
+		}
+		return nil, "v1.0.0", nil
+	}
+	t.Cleanup(func() { github.PreviousReleaseFileFunc = original })
+	encoded := base64.StdEncoding.EncodeToString([]byte(newSchema))
+	newDefinitions := func() []models.ConfigurationDefinition {
+		return []models.ConfigurationDefinition{
+			{"type": "agent-config", "platform": "ALL", "schema": encoded},
+			{"type": "logging", "platform": "ALL", "schema": encoded},
+			{"type": "tracing", "platform": "ALL", "changelog": []string{"Added sampling settings"}},
+		}
+	}
+
+	t.Run("derives changelogs from the schema changes", func(t *testing.T) {
+		t.Setenv("INPUT_DERIVE_CHANGELOG", "true")
+		summaryPath := filepath.Join(t.TempDir(), "summary.md")
+		t.Setenv("GITHUB_STEP_SUMMARY", summaryPath)
+		getStdout, _ := testutil.CaptureOutput(t)
+		definitions := newDefinitions()
+
+		// method under test
+		addChangelogs(context.Background(), t.TempDir(), "NRJavaAgent", "1.2.3", definitions)
+
+		assert.Equal(t, []string{
+			"Removed `log_file`",
+			"Changed the default of `log_level` from `\"info\"` to `\"warn\"`",
+			"Added `proxy` (object)",
+		}, definitions[0]["changelog"])
+		assert.Equal(t, []string{"Added `log_level` (string)", "Added `proxy` (object)"}, definitions[1]["changelog"], "Every property is new to a definition that had no schema")
+		assert.Equal(t, []string{"Added sampling settings"}, definitions[2]["changelog"], "A written changelog is kept")
+		summary, err := os.ReadFile(summaryPath)
+		require.NoError(t, err)
+		assert.Contains(t, string(summary), "## Configuration changelog of NRJavaAgent 1.2.3")
+		assert.Contains(t, string(summary), "### agent-config (ALL)\n\n_Derived from the schema changes since v1.0.0_\n\n- Removed `log_file`\n")
+		assert.Contains(t, string(summary), "### tracing (ALL)\n\n- Added sampling settings\n")
+		assert.Contains(t, getStdout(), "Sending changelogs with 3 configuration definitions, 2 of them derived from the schema changes since v1.0.0")
+	})
+
+	t.Run("only written changelogs without derive-changelog", func(t *testing.T) {
+		t.Setenv("INPUT_DERIVE_CHANGELOG", "false")
+		t.Setenv("GITHUB_STEP_SUMMARY", filepath.Join(t.TempDir(), "summary.md"))
+		testutil.CaptureOutput(t)
+		definitions := newDefinitions()
+
+		// method under test
+		addChangelogs(context.Background(), t.TempDir(), "NRJavaAgent", "1.2.3", definitions)
+
+		assert.NotContains(t, definitions[0], "changelog")
+		assert.NotContains(t, definitions[1], "changelog")
+		assert.Equal(t, []string{"Added sampling settings"}, definitions[2]["changelog"])
+	})
+}
+
+func TestCheckFixtures(t *testing.T) {
+	workspace := t.TempDir()
+	writeSample := func(t *testing.T, path, content string) {
+		full := filepath.Join(workspace, ".fleetControl", "fixtures", path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, os.WriteFile(full, []byte(content), 0644))
+	}
+	writeSample(t, "agent-config/minimal.yml", "common:\n  license_key: abc\n")
+	writeSample(t, "agent-config/logging.yml", "common:\n  license_key: abc\n  log_level: trace\n")
+
+	previousSchema := `{"type": "object", "properties": {"common": {"type": "object", "required": ["license_key"], "properties": {"log_level": {"type": "string"}}}}}`
+	newSchema := `{"type": "object", "properties": {"common": {"type": "object", "required": ["license_key"], "properties": {"log_level": {"enum": ["debug", "info"]}}}}}`
+	original := github.PreviousReleaseFileFunc
+	github.PreviousReleaseFileFunc = func(ctx context.Context, ws, path string) ([]byte, string, error) {
+		switch filepath.ToSlash(path) {
+		case ".fleetControl/configurationDefinitions.yml":
+			return []byte("configurationDefinitions:\n  - type: agent-config\n    platform: ALL\n    schema: ./schemas/agent-config.json\n"), "v1.0.0", nil
+		case ".fleetControl/schemas/agent-config.json":
+			return []byte(previousSchema), "v1.0.0", nil
+		}
+		return nil, "v1.0.0", nil
+	}
+	t.Cleanup(func() { github.PreviousReleaseFileFunc = original })
+
+	definitions := []models.ConfigurationDefinition{
+		{"type": "agent-config", "platform": "ALL", "schema": base64.StdEncoding.EncodeToString([]byte(newSchema))},
+	}
+
+	t.Run("reports samples the new schema breaks", func(t *testing.T) {
+		getStdout, _ := testutil.CaptureOutput(t)
+		annotations := github.NewAnnotationCollector()
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(github.WithAnnotationCollector(context.Background(), annotations), recorder)
+
+		// method under test
+		err := checkFixtures(ctx, workspace, "NRJavaAgent", definitions)
+
+		require.NoError(t, err, "Rejected samples are warnings")
+		recorded := recorder.Results().Fixtures
+		require.Len(t, recorded, 2)
+		assert.Equal(t, results.Fixture{
+			Path:            ".fleetControl/fixtures/agent-config/logging.yml",
+			Type:            "agent-config",
+			Platform:        "ALL",
+			Outcome:         "breaks",
+			PreviousRelease: "v1.0.0",
+			Errors:          []string{`$.common.log_level must be one of ["debug", "info"], got "trace"`},
+		}, recorded[0])
+		assert.Equal(t, "compatible", recorded[1].Outcome)
+		require.Len(t, annotations.Annotations(), 1)
+		assert.Equal(t, github.AnnotationWarning, annotations.Annotations()[0].AnnotationLevel)
+		assert.Equal(t, "Configuration sample broken by the schema", annotations.Annotations()[0].Title)
+		assert.Contains(t, getStdout(), "Checked 2 configuration samples against the new schemas: 1 rejected, 1 of them valid in the previous release")
+	})
+
+	t.Run("strict fails the run", func(t *testing.T) {
+		t.Setenv("INPUT_STRICT_FIXTURES", "true")
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := checkFixtures(context.Background(), workspace, "NRJavaAgent", definitions)
+
+		assert.EqualError(t, err, "the configuration schemas reject 1 configuration samples, 1 of them valid in the previous release")
+	})
+
+	t.Run("sample of an unknown type", func(t *testing.T) {
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := checkFixtures(context.Background(), workspace, "NRJavaAgent", []models.ConfigurationDefinition{{"type": "other-config"}})
+
+		require.NoError(t, err)
+		assert.Contains(t, getStdout(), "Configuration sample .fleetControl/fixtures/agent-config/logging.yml isn't checked: there is no configuration definition of type agent-config")
+	})
+
+	t.Run("no fixtures", func(t *testing.T) {
+		// method under test
+		err := checkFixtures(context.Background(), t.TempDir(), "NRJavaAgent", definitions)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid sample", func(t *testing.T) {
+		broken := t.TempDir()
+		path := filepath.Join(broken, ".fleetControl", "fixtures", "agent-config", "broken.yml")
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte("common: [unclosed"), 0644))
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := checkFixtures(context.Background(), broken, "NRJavaAgent", definitions)
+
+		assert.ErrorContains(t, err, "invalid fixtures: invalid fixture .fleetControl/fixtures/agent-config/broken.yml")
+	})
+}
+
+func TestCheckReleaseDate(t *testing.T) {
+	workspace := t.TempDir()
+	note := filepath.Join(workspace, "src/content/docs/release-notes/java-agent-800.mdx")
+	stubVersionDate := func(t *testing.T, date time.Time, source string) {
+		original := github.VersionDateFunc
+		github.VersionDateFunc = func(ctx context.Context, ws, version, path string) (time.Time, string, error) {
+			return date, source, nil
+		}
+		t.Cleanup(func() { github.VersionDateFunc = original })
+	}
+	tagged := time.Date(2025, 1, 12, 23, 30, 0, 0, time.FixedZone("", -5*60*60))
+
+	tests := []struct {
+		name        string
+		window      string
+		releaseDate interface{}
+		wantWarning string
+	}{
+		{name: "same day in the tag's timezone", releaseDate: "2025-01-12"},
+		{name: "within the window", releaseDate: "2025-01-19"},
+		{name: "unquoted YAML date", releaseDate: time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{name: "copied from a previous release", releaseDate: "2024-10-01",
+			wantWarning: "releaseDate 2024-10-01 of 8.0.0 is 103 days from the tag v8.0.0 (2025-01-12) - was it copied from a previous release?"},
+		{name: "wider window", window: "120", releaseDate: "2024-10-01"},
+		{name: "check disabled", window: "0", releaseDate: "2020-01-01"},
+		{name: "invalid dates are left to the service", releaseDate: "2024-13-45"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("INPUT_RELEASE_DATE_WINDOW", tt.window)
+			stubVersionDate(t, tagged, "tag v8.0.0")
+			getStdout, _ := testutil.CaptureOutput(t)
+			annotations := github.NewAnnotationCollector()
+			ctx := github.WithAnnotationCollector(context.Background(), annotations)
+
+			// method under test
+			checkReleaseDate(ctx, workspace, "8.0.0", models.Metadata{"releaseDate": tt.releaseDate}, note)
+
+			if tt.wantWarning == "" {
+				assert.NotContains(t, getStdout(), "::warn::")
+				assert.Empty(t, annotations.Annotations())
+				return
+			}
+			assert.Contains(t, getStdout(), "::warn::"+tt.wantWarning)
+			require.Len(t, annotations.Annotations(), 1)
+			assert.Equal(t, "src/content/docs/release-notes/java-agent-800.mdx", annotations.Annotations()[0].Path)
+			assert.Equal(t, "Suspicious releaseDate", annotations.Annotations()[0].Title)
+		})
+	}
+
+	t.Run("no tag or commit found", func(t *testing.T) {
+		stubVersionDate(t, time.Time{}, "")
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		checkReleaseDate(context.Background(), workspace, "8.0.0", models.Metadata{"releaseDate": "2020-01-01"}, "")
+
+		assert.NotContains(t, getStdout(), "::warn::")
+	})
+}
+
+func TestCheckPolicy(t *testing.T) {
+	workspace := t.TempDir()
+	configDir := filepath.Join(workspace, ".fleetControl")
+	require.NoError(t, os.MkdirAll(configDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "policy.yml"), []byte(`rules:
+  security-notes-for-cves: {}
+  eol-for-major-versions: {}
+  supported-os:
+    os: [linux]
+`), 0644))
+
+	originalGetCommitMessages := github.GetCommitMessagesFunc
+	defer func() { github.GetCommitMessagesFunc = originalGetCommitMessages }()
+	github.GetCommitMessagesFunc = func(ctx context.Context) ([]string, error) {
+		return []string{"Bump netty for CVE-2024-1234"}, nil
+	}
+
+	release := policy.Release{
+		Kind:      policy.KindReleaseNotes,
+		AgentType: "NRJavaAgent",
+		Version:   "9.0.0",
+		Metadata:  models.Metadata{"version": "9.0.0"},
+	}
+
+	t.Run("violations warn by default", func(t *testing.T) {
+		getStdout, _ := testutil.CaptureOutput(t)
+		annotations := github.NewAnnotationCollector()
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(github.WithAnnotationCollector(context.Background(), annotations), recorder)
+
+		// method under test
+		err := checkPolicy(ctx, workspace, release)
+
+		require.NoError(t, err)
+		assert.Contains(t, getStdout(), "::warn::Policy security-notes-for-cves failed for NRJavaAgent 9.0.0: commits mention CVE-2024-1234 but the release notes have no security notes")
+		require.Len(t, annotations.Annotations(), 2)
+		assert.Equal(t, github.Annotation{
+			Path:            ".fleetControl/policy.yml",
+			StartLine:       1,
+			EndLine:         1,
+			AnnotationLevel: github.AnnotationWarning,
+			Title:           "Policy: eol-for-major-versions",
+			Message:         "NRJavaAgent 9.0.0: 9.0.0 is a major version but the release notes don't set eol",
+			Rule:            "policy/eol-for-major-versions",
+		}, annotations.Annotations()[0])
+		assert.Equal(t, []results.Policy{
+			{AgentType: "NRJavaAgent", Version: "9.0.0", Rule: "eol-for-major-versions", Outcome: "fail", Message: "9.0.0 is a major version but the release notes don't set eol"},
+			{AgentType: "NRJavaAgent", Version: "9.0.0", Rule: "security-notes-for-cves", Outcome: "fail", Message: "commits mention CVE-2024-1234 but the release notes have no security notes"},
+			{AgentType: "NRJavaAgent", Version: "9.0.0", Rule: "supported-os", Outcome: "skipped", Message: "does not apply to release-notes releases"},
+		}, recorder.Results().Policy)
+	})
+
+	t.Run("strict mode blocks the release", func(t *testing.T) {
+		t.Setenv("INPUT_STRICT_POLICY", "true")
+		testutil.CaptureOutput(t)
+		annotations := github.NewAnnotationCollector()
+		ctx := github.WithAnnotationCollector(context.Background(), annotations)
+
+		// method under test
+		err := checkPolicy(ctx, workspace, release)
+
+		require.Error(t, err)
+		assert.Equal(t, "NRJavaAgent 9.0.0 violates 2 policy rule(s)", err.Error())
+		assert.Equal(t, github.AnnotationFailure, annotations.Annotations()[0].AnnotationLevel)
+	})
+
+	t.Run("compliant release", func(t *testing.T) {
+		t.Setenv("INPUT_STRICT_POLICY", "true")
+		getStdout, _ := testutil.CaptureOutput(t)
+		compliant := release
+		compliant.Metadata = models.Metadata{"version": "9.0.0", "eol": "2027-01-01", "security": []interface{}{"Fixed CVE-2024-1234"}}
+
+		// method under test
+		err := checkPolicy(context.Background(), workspace, compliant)
+
+		require.NoError(t, err)
+		assert.Contains(t, getStdout(), "NRJavaAgent 9.0.0 passed 2 policy rule(s)")
+	})
+
+	t.Run("no policy file", func(t *testing.T) {
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := checkPolicy(context.Background(), t.TempDir(), release)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		invalid := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(invalid, ".fleetControl"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(invalid, ".fleetControl", "policy.yml"), []byte("rules:\n  no-such-rule: {}\n"), 0644))
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := checkPolicy(context.Background(), invalid, release)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown policy rule "no-such-rule"`)
+	})
+}
+
+func TestCheckRegoPolicies(t *testing.T) {
+	input := rego.Input{AgentType: "NRJavaAgent", Version: "1.2.3", Metadata: &models.AgentMetadata{}}
+	stubEvaluate := func(t *testing.T, decision rego.Decision, err error) *[]string {
+		var sources []string
+		original := evaluateRegoFunc
+		evaluateRegoFunc = func(ctx context.Context, workspace string, policySources []string, got rego.Input) (rego.Decision, error) {
+			assert.Equal(t, input, got)
+			sources = policySources
+			return decision, err
+		}
+		t.Cleanup(func() { evaluateRegoFunc = original })
+		return &sources
+	}
+
+	t.Run("skipped without policies", func(t *testing.T) {
+		t.Setenv("INPUT_REGO_POLICIES", "")
+		sources := stubEvaluate(t, rego.Decision{}, nil)
+
+		// method under test
+		err := checkRegoPolicies(context.Background(), t.TempDir(), input)
+
+		require.NoError(t, err)
+		assert.Nil(t, *sources)
+	})
+
+	t.Run("deny fails the run", func(t *testing.T) {
+		t.Setenv("INPUT_REGO_POLICIES", "policies, https://example.com/org.tar.gz")
+		t.Setenv("GITHUB_WORKFLOW_REF", "newrelic/newrelic-java-agent/.github/workflows/release.yml@refs/tags/v1.2.3")
+		summaryPath := filepath.Join(t.TempDir(), "summary")
+		t.Setenv("GITHUB_STEP_SUMMARY", summaryPath)
+		sources := stubEvaluate(t, rego.Decision{
+			Deny: []rego.Violation{{Rule: "OCI-1", Message: "linux/arm64 binary is required"}},
+			Warn: []rego.Violation{{Rule: "warn", Message: "no tags set"}},
+		}, nil)
+		getStdout, _ := testutil.CaptureOutput(t)
+		annotations := github.NewAnnotationCollector()
+		ctx := github.WithAnnotationCollector(context.Background(), annotations)
+
+		// method under test
+		err := checkRegoPolicies(ctx, t.TempDir(), input)
+
+		require.Error(t, err)
+		assert.Equal(t, "NRJavaAgent 1.2.3 denied by 1 Rego policy rule(s)", err.Error())
+		assert.Equal(t, []string{"policies", "https://example.com/org.tar.gz"}, *sources)
+		assert.Contains(t, getStdout(), "::error::Rego policy denied NRJavaAgent 1.2.3: [OCI-1] linux/arm64 binary is required")
+		require.Len(t, annotations.Annotations(), 2)
+		assert.Equal(t, ".github/workflows/release.yml", annotations.Annotations()[0].Path)
+		assert.Equal(t, "Rego: OCI-1", annotations.Annotations()[0].Title)
+		assert.Equal(t, github.AnnotationFailure, annotations.Annotations()[0].AnnotationLevel)
+		assert.Equal(t, github.AnnotationWarning, annotations.Annotations()[1].AnnotationLevel)
+		summary, readErr := os.ReadFile(summaryPath)
+		require.NoError(t, readErr)
+		assert.Contains(t, string(summary), "| deny | `OCI-1` | linux/arm64 binary is required |")
+	})
+
+	t.Run("warnings pass", func(t *testing.T) {
+		t.Setenv("INPUT_REGO_POLICIES", "policies")
+		stubEvaluate(t, rego.Decision{Warn: []rego.Violation{{Rule: "warn", Message: "no tags set"}}}, nil)
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := checkRegoPolicies(context.Background(), t.TempDir(), input)
+
+		require.NoError(t, err)
+		assert.Contains(t, getStdout(), "::warn::Rego policy warning for NRJavaAgent 1.2.3: [warn] no tags set")
+	})
+
+	t.Run("evaluation error", func(t *testing.T) {
+		t.Setenv("INPUT_REGO_POLICIES", "policies")
+		stubEvaluate(t, rego.Decision{}, assert.AnError)
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := checkRegoPolicies(context.Background(), t.TempDir(), input)
+
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
+
+func TestPolicySummary(t *testing.T) {
+	// method under test
+	summary := policySummary([]results.Policy{
+		{AgentType: "NRJavaAgent", Version: "9.0.0", Rule: "supported-os", Outcome: "pass", Message: "ships binaries for linux"},
+	})
+
+	assert.Equal(t, "Policy evaluation:\n- NRJavaAgent 9.0.0 `supported-os`: pass - ships binaries for linux", summary)
+	assert.Empty(t, policySummary(nil))
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"agent-metadata-action/internal/mockserver"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	token := flag.String("token", "", "bearer token requests must present (default: accept any token)")
+	faultsFile := flag.String("faults", "", "JSON file of faults to inject, e.g. [{\"path\": \"/v1/signing\", \"statuses\": [503, 0]}]")
+	flag.Parse()
+
+	server := mockserver.New(*token)
+	server.OnRequest = func(r mockserver.Request) {
+		log.Printf("%s %s -> %d", r.Method, r.Path, r.Status)
+	}
+
+	if *faultsFile != "" {
+		data, err := os.ReadFile(*faultsFile)
+		if err != nil {
+			log.Fatalf("failed to read faults file: %v", err)
+		}
+		faults, err := mockserver.ParseFaults(data)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		for _, f := range faults {
+			server.AddFault(f)
+		}
+		log.Printf("Injecting %d fault(s) from %s", len(faults), *faultsFile)
+	}
+
+	log.Printf("Mock instrumentation metadata and signing service listening on http://%s", *addr)
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,70 @@
+package agenttype
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Known lists the agent types registered with the instrumentation service when this version of the action was built
+// Used when the service's catalog can't be fetched, so it may lag behind newly registered agent types
+var Known = []string{
+	"NRDOT",
+	"NRDotNetAgent",
+	"NRInfra",
+	"NRJavaAgent",
+	"NRNodeAgent",
+	"NRPythonAgent",
+	"NRRubyAgent",
+	"NReBPFAgent",
+}
+
+// Validate checks that agentType is in catalog, suggesting the closest registered agent type for typos
+func Validate(agentType string, catalog []string) error {
+	for _, known := range catalog {
+		if known == agentType {
+			return nil
+		}
+	}
+	if suggestion := Suggest(agentType, catalog); suggestion != "" {
+		return fmt.Errorf("unknown agent type %q - did you mean %q?", agentType, suggestion)
+	}
+	return fmt.Errorf("unknown agent type %q - registered agent types are %v", agentType, catalog)
+}
+
+// Suggest returns the catalog entry closest to agentType, or "" if none is close enough to be a likely typo
+// Comparison ignores case, so a differently cased agent type is always suggested
+func Suggest(agentType string, catalog []string) string {
+	best, bestDistance := "", -1
+	for _, known := range catalog {
+		distance := levenshtein(strings.ToLower(agentType), strings.ToLower(known))
+		if bestDistance == -1 || distance < bestDistance {
+			best, bestDistance = known, distance
+		}
+	}
+	// Allow roughly one edit per four characters, and at least two
+	if bestDistance == -1 || bestDistance > max(2, len(best)/4) {
+		return ""
+	}
+	return best
+}
+
+// levenshtein returns the number of single character insertions, deletions and substitutions between a and b
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
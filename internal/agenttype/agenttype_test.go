@@ -0,0 +1,41 @@
+package agenttype
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		agentType   string
+		expectedErr string
+	}{
+		{name: "registered", agentType: "NRJavaAgent"},
+		{name: "typo", agentType: "NRJavaAgnet", expectedErr: `unknown agent type "NRJavaAgnet" - did you mean "NRJavaAgent"?`},
+		{name: "wrong case", agentType: "nrdotnetagent", expectedErr: `did you mean "NRDotNetAgent"?`},
+		{name: "missing prefix", agentType: "JavaAgent", expectedErr: `did you mean "NRJavaAgent"?`},
+		{name: "unrelated", agentType: "java", expectedErr: `unknown agent type "java" - registered agent types are [NRDOT NRDotNetAgent`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			err := Validate(tt.agentType, Known)
+
+			if tt.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	assert.Equal(t, 0, levenshtein("abc", "abc"))
+	assert.Equal(t, 3, levenshtein("", "abc"))
+	assert.Equal(t, 2, levenshtein("agnet", "agent"))
+	assert.Equal(t, 3, levenshtein("kitten", "sitting"))
+}
@@ -0,0 +1,171 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/metrics"
+)
+
+// Release is a historical version of an agent and the tag its metadata is read at
+type Release struct {
+	Version string
+	Tag     string
+}
+
+// Resolve selects the releases to backfill from tags, which must be sorted oldest version first
+// spec is either a comma or newline separated list of versions, each released under a v<version> or <version> tag,
+// or a range of tags <from>..<to> that selects both tags and every tag between them
+func Resolve(spec string, tags []string) ([]Release, error) {
+	if from, to, ok := strings.Cut(spec, ".."); ok {
+		return resolveRange(strings.TrimSpace(from), strings.TrimSpace(to), tags)
+	}
+
+	known := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		known[tag] = true
+	}
+	var releases []Release
+	seen := map[string]bool{}
+	for _, version := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		version = strings.TrimSpace(version)
+		if version == "" || seen[version] {
+			continue
+		}
+		seen[version] = true
+		switch {
+		case known["v"+version]:
+			releases = append(releases, Release{Version: version, Tag: "v" + version})
+		case known[version]:
+			releases = append(releases, Release{Version: version, Tag: version})
+		default:
+			return nil, fmt.Errorf("no tag v%s or %s for version %s", version, version, version)
+		}
+	}
+	if len(releases) == 0 {
+		return nil, fmt.Errorf("no versions given")
+	}
+	return releases, nil
+}
+
+// resolveRange selects the tags from one tag to another, inclusive
+// Versions are the tag names without a v prefix
+func resolveRange(from, to string, tags []string) ([]Release, error) {
+	start, end := tagIndex(from, tags), tagIndex(to, tags)
+	switch {
+	case start < 0:
+		return nil, fmt.Errorf("no tag %s", from)
+	case end < 0:
+		return nil, fmt.Errorf("no tag %s", to)
+	case start > end:
+		return nil, fmt.Errorf("tag %s is newer than %s", from, to)
+	}
+
+	releases := make([]Release, 0, end-start+1)
+	for _, tag := range tags[start : end+1] {
+		releases = append(releases, Release{Version: strings.TrimPrefix(tag, "v"), Tag: tag})
+	}
+	return releases, nil
+}
+
+// tagIndex returns the index of the tag named name, or v<name>, or -1
+func tagIndex(name string, tags []string) int {
+	for i, tag := range tags {
+		if tag == name || tag == "v"+name {
+			return i
+		}
+	}
+	return -1
+}
+
+// SubmitFunc reads and submits the metadata of a release, returning the submission ID
+type SubmitFunc func(ctx context.Context, release Release) (string, error)
+
+// Result is the outcome of backfilling a single release
+type Result struct {
+	Release Release
+	ID      string
+	Error   string
+}
+
+// Run backfills releases with at most concurrency of them in progress at once, logging progress as each completes
+// Results are in the order of releases; releases backfilled concurrently don't open log groups, which would interleave
+func Run(ctx context.Context, releases []Release, concurrency int, submit SubmitFunc) []Result {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	submitCtx := ctx
+	if concurrency > 1 {
+		submitCtx = logging.WithoutGroups(ctx)
+	}
+	results := make([]Result, len(releases))
+	slots := make(chan struct{}, concurrency)
+	var mu sync.Mutex
+	completed := 0
+
+	var wg sync.WaitGroup
+	for i, release := range releases {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			result := Result{Release: release}
+			id, err := submit(submitCtx, release)
+			if err != nil {
+				result.Error = err.Error()
+			}
+			result.ID = id
+			results[i] = result
+			metrics.Add(ctx, metrics.ItemsProcessed, 1)
+			if err != nil {
+				metrics.Add(ctx, metrics.ItemsFailed, 1)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			completed++
+			if err != nil {
+				logging.Errorf(ctx, "[%d/%d] Failed to backfill version %s (tag %s): %v", completed, len(releases), release.Version, release.Tag, err)
+				return
+			}
+			logging.Noticef(ctx, "[%d/%d] Backfilled version %s (tag %s)", completed, len(releases), release.Version, release.Tag)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// Summary counts results by outcome
+type Summary struct {
+	Succeeded int
+	Failed    int
+}
+
+// Report logs a summary of the results, listing the versions that failed
+func Report(ctx context.Context, results []Result, dryRun bool) Summary {
+	var summary Summary
+	var failed []string
+	for _, r := range results {
+		if r.Error != "" {
+			summary.Failed++
+			failed = append(failed, r.Release.Version)
+			continue
+		}
+		summary.Succeeded++
+	}
+
+	prefix := "Backfill"
+	if dryRun {
+		prefix = "Backfill (dry run)"
+	}
+	logging.Noticef(ctx, "%s complete: %d of %d versions succeeded, %d failed", prefix, summary.Succeeded, len(results), summary.Failed)
+	if len(failed) > 0 {
+		logging.Errorf(ctx, "Versions that failed to backfill: %s", strings.Join(failed, ", "))
+	}
+	return summary
+}
@@ -0,0 +1,120 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/metrics"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	tags := []string{"v1.0.0", "v1.1.0", "1.2.0", "v2.0.0", "nightly"}
+
+	tests := []struct {
+		name     string
+		spec     string
+		expected []Release
+		err      string
+	}{
+		{
+			name:     "list of versions",
+			spec:     "1.1.0, 1.2.0\n1.1.0",
+			expected: []Release{{Version: "1.1.0", Tag: "v1.1.0"}, {Version: "1.2.0", Tag: "1.2.0"}},
+		},
+		{
+			name:     "tag range",
+			spec:     "v1.1.0..v2.0.0",
+			expected: []Release{{Version: "1.1.0", Tag: "v1.1.0"}, {Version: "1.2.0", Tag: "1.2.0"}, {Version: "2.0.0", Tag: "v2.0.0"}},
+		},
+		{
+			name:     "range of versions",
+			spec:     "1.0.0 .. 1.0.0",
+			expected: []Release{{Version: "1.0.0", Tag: "v1.0.0"}},
+		},
+		{name: "unknown version", spec: "1.0.0,3.0.0", err: "no tag v3.0.0 or 3.0.0 for version 3.0.0"},
+		{name: "unknown range end", spec: "v1.0.0..v3.0.0", err: "no tag v3.0.0"},
+		{name: "reversed range", spec: "v2.0.0..v1.0.0", err: "tag v2.0.0 is newer than v1.0.0"},
+		{name: "empty list", spec: " , ", err: "no versions given"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			releases, err := Resolve(tt.spec, tags)
+
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, releases)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	releases := []Release{{Version: "1.0.0", Tag: "v1.0.0"}, {Version: "1.1.0", Tag: "v1.1.0"}, {Version: "1.2.0", Tag: "v1.2.0"}, {Version: "2.0.0", Tag: "v2.0.0"}}
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	submit := func(ctx context.Context, release Release) (string, error) {
+		mu.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+		logging.Log(ctx, "group", "Sending metadata to instrumentation service")
+		defer logging.Log(ctx, "endgroup", "")
+		if release.Version == "1.1.0" {
+			return "", fmt.Errorf("no configuration definitions")
+		}
+		return "id-" + release.Version, nil
+	}
+	getStdout, _ := testutil.CaptureOutput(t)
+	registry := metrics.NewRegistry("backfill")
+	ctx := metrics.WithRegistry(context.Background(), registry)
+
+	// method under test
+	results := Run(ctx, releases, 2, submit)
+
+	require.Len(t, results, 4)
+	assert.Equal(t, Result{Release: releases[0], ID: "id-1.0.0"}, results[0])
+	assert.Equal(t, Result{Release: releases[1], Error: "no configuration definitions"}, results[1])
+	assert.Equal(t, "id-2.0.0", results[3].ID)
+	assert.LessOrEqual(t, maxRunning, 2)
+	stdout := getStdout()
+	assert.Contains(t, stdout, "::error::[")
+	assert.Contains(t, stdout, "/4] Failed to backfill version 1.1.0 (tag v1.1.0): no configuration definitions")
+	assert.Contains(t, stdout, "/4] Backfilled version 2.0.0 (tag v2.0.0)")
+	assert.Contains(t, stdout, "[4/4]")
+	assert.NotContains(t, stdout, "::group::", "Releases backfilled concurrently don't open log groups")
+	assert.Equal(t, int64(4), registry.Value(metrics.ItemsProcessed))
+	assert.Equal(t, int64(1), registry.Value(metrics.ItemsFailed))
+}
+
+func TestReport(t *testing.T) {
+	results := []Result{
+		{Release: Release{Version: "1.0.0"}, ID: "id-1"},
+		{Release: Release{Version: "1.1.0"}, Error: "failed"},
+		{Release: Release{Version: "1.2.0"}},
+	}
+	getStdout, _ := testutil.CaptureOutput(t)
+
+	// method under test
+	summary := Report(context.Background(), results, true)
+
+	assert.Equal(t, Summary{Succeeded: 2, Failed: 1}, summary)
+	stdout := getStdout()
+	assert.Contains(t, stdout, "Backfill (dry run) complete: 2 of 3 versions succeeded, 1 failed")
+	assert.Contains(t, stdout, "::error::Versions that failed to backfill: 1.1.0")
+}
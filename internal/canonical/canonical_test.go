@@ -0,0 +1,67 @@
+package canonical
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    any
+		expected string
+	}{
+		{name: "sorted keys", value: map[string]any{"b": 1, "a": map[string]any{"d": true, "c": nil}}, expected: `{"a":{"c":null,"d":true},"b":1}`},
+		{name: "struct fields sorted", value: struct {
+			Zebra string `json:"zebra"`
+			Apple string `json:"apple,omitempty"`
+		}{Zebra: "z"}, expected: `{"zebra":"z"}`},
+		{name: "array order kept", value: []any{"b", "a", 2.5, []int{3, 1}}, expected: `["b","a",2.5,[3,1]]`},
+		{name: "no html escaping", value: map[string]string{"url": "https://example.com/?a=1&b=<2>"}, expected: `{"url":"https://example.com/?a=1&b=<2>"}`},
+		{name: "control characters escaped", value: "line\n\"quoted\"", expected: `"line\n\"quoted\""`},
+		{name: "large integers kept", value: map[string]int64{"n": 9007199254740993}, expected: `{"n":9007199254740993}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			data, err := Marshal(tt.value)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(data))
+		})
+	}
+}
+
+func TestMarshal_Deterministic(t *testing.T) {
+	build := func() map[string]any {
+		value := map[string]any{}
+		for _, key := range []string{"k", "c", "x", "a", "q", "m", "b", "z"} {
+			value[key] = map[string]any{"nested-" + key: key, "a": []any{key}}
+		}
+		return value
+	}
+
+	first, err := Marshal(build())
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		data, err := Marshal(build())
+		require.NoError(t, err)
+		assert.Equal(t, first, data)
+	}
+}
+
+func TestMarshalIndent(t *testing.T) {
+	// method under test
+	data, err := MarshalIndent(map[string]any{"b": []int{1}, "a": "<x>"}, "", "  ")
+
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"a\": \"<x>\",\n  \"b\": [\n    1\n  ]\n}", string(data))
+}
+
+func TestMarshal_Unsupported(t *testing.T) {
+	_, err := Marshal(map[string]any{"fn": func() {}})
+	require.Error(t, err)
+}
@@ -0,0 +1,218 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"agent-metadata-action/internal/canonical"
+	"agent-metadata-action/internal/idempotency"
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/retry"
+)
+
+// uploadResponse is the body returned when a chunked upload is started
+type uploadResponse struct {
+	UploadID string `json:"uploadId"`
+}
+
+// definitionBatch is the body of a request adding configuration definitions to a chunked upload
+type definitionBatch struct {
+	ConfigurationDefinitions []models.ConfigurationDefinition `json:"configurationDefinitions"`
+}
+
+// submitChunked submits metadata too large for a single request as a chunked upload:
+// the base metadata (everything but the configuration definitions) starts the upload, the definitions follow in
+// batches that each fit maxPayloadSize, and a commit stores the assembled metadata as the version
+// If any part fails the upload is aborted, so the service never stores a partially submitted version
+func (c *InstrumentationClient) submitChunked(ctx context.Context, agentType, agentVersion, payloadVersion string, metadata *models.AgentMetadata, payloadHash string) (response models.SubmissionResponse, err error) {
+	// send doesn't know which version a rejected part belonged to
+	defer func() {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			validationErr.AgentType, validationErr.Version = agentType, agentVersion
+		}
+	}()
+
+	base := *metadata
+	base.ConfigurationDefinitions = nil
+	baseBody, err := models.EncodePayload(payloadVersion, &base, c.emptyFields, c.features)
+	if err != nil {
+		return response, retry.NewNonRetryableError(fmt.Errorf("failed to marshal metadata: %w", err))
+	}
+	if len(baseBody) > c.maxPayloadSize {
+		return response, retry.NewNonRetryableError(fmt.Errorf("metadata without configuration definitions is %d bytes, larger than the %d byte payload limit", len(baseBody), c.maxPayloadSize))
+	}
+
+	// Batches follow the canonical order of the definitions so their bodies and idempotency keys are reproducible
+	sorted, err := metadata.Canonical()
+	if err != nil {
+		return response, retry.NewNonRetryableError(err)
+	}
+	batches, err := c.batchDefinitions(models.PayloadConfigurationDefinitions(payloadVersion, c.features, sorted.ConfigurationDefinitions))
+	if err != nil {
+		return response, retry.NewNonRetryableError(err)
+	}
+	logging.Noticef(ctx, "Metadata payload exceeds %d bytes - submitting it in %d parts", c.maxPayloadSize, len(batches)+1)
+
+	uploadsURL := fmt.Sprintf("%s/v1/agents/%s/versions/%s/uploads", c.baseURL, agentType, agentVersion)
+	body, err := c.send(ctx, "Chunked upload start", http.MethodPost, uploadsURL, baseBody, payloadVersion,
+		idempotency.Key(agentType, agentVersion, payloadHash, idempotency.RunScope(c.runID), "upload"))
+	if err != nil {
+		return response, fmt.Errorf("failed to start chunked metadata submission: %w", err)
+	}
+	var upload uploadResponse
+	if err := json.Unmarshal(body, &upload); err != nil || upload.UploadID == "" {
+		return response, fmt.Errorf("chunked metadata submission returned no upload ID: %s", truncate(string(body), 500))
+	}
+	logging.Debugf(ctx, "Started chunked upload %s", upload.UploadID)
+
+	uploadURL := fmt.Sprintf("%s/%s", uploadsURL, upload.UploadID)
+	for i, batch := range batches {
+		logging.Debugf(ctx, "Sending configuration definitions part %d of %d (%d bytes)", i+1, len(batches), len(batch))
+		if _, err := c.send(ctx, "Chunked upload part", http.MethodPatch, uploadURL, batch, payloadVersion,
+			idempotency.Key(upload.UploadID, strconv.Itoa(i), idempotency.PayloadHash(batch))); err != nil {
+			return response, c.abortUpload(ctx, uploadURL, fmt.Errorf("failed to send configuration definitions part %d of %d: %w", i+1, len(batches), err))
+		}
+	}
+
+	body, err = c.send(ctx, "Chunked upload commit", http.MethodPost, uploadURL+"/commit", nil, payloadVersion,
+		idempotency.Key(upload.UploadID, "commit"))
+	if err != nil {
+		return response, c.abortUpload(ctx, uploadURL, fmt.Errorf("failed to commit chunked metadata submission: %w", err))
+	}
+	response, err = models.ParseSubmissionResponse(body, agentType, agentVersion)
+	if err != nil {
+		return response, retry.NewNonRetryableError(fmt.Errorf("chunked metadata submission returned an unexpected response: %w", err))
+	}
+	return response, nil
+}
+
+// batchDefinitions encodes configuration definitions into as few batch bodies as fit maxPayloadSize, keeping their order
+// Batches are sized uncompressed, so they also fit when compression is enabled
+func (c *InstrumentationClient) batchDefinitions(definitions []models.ConfigurationDefinition) ([][]byte, error) {
+	var batches [][]byte
+	var current []models.ConfigurationDefinition
+	var currentBody []byte
+
+	for i, definition := range definitions {
+		candidate := append(append([]models.ConfigurationDefinition{}, current...), definition)
+		body, err := canonical.Marshal(definitionBatch{ConfigurationDefinitions: candidate})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal configuration definitions: %w", err)
+		}
+		if len(body) <= c.maxPayloadSize {
+			current, currentBody = candidate, body
+			continue
+		}
+		if len(current) == 0 {
+			return nil, fmt.Errorf("configuration definition %d is %d bytes, larger than the %d byte payload limit", i, len(body), c.maxPayloadSize)
+		}
+		batches = append(batches, currentBody)
+		current = nil
+		// Retry the definition on its own in a new batch
+		body, err = canonical.Marshal(definitionBatch{ConfigurationDefinitions: []models.ConfigurationDefinition{definition}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal configuration definitions: %w", err)
+		}
+		if len(body) > c.maxPayloadSize {
+			return nil, fmt.Errorf("configuration definition %d is %d bytes, larger than the %d byte payload limit", i, len(body), c.maxPayloadSize)
+		}
+		current, currentBody = []models.ConfigurationDefinition{definition}, body
+	}
+	if len(current) > 0 {
+		batches = append(batches, currentBody)
+	}
+	return batches, nil
+}
+
+// abortUpload discards a chunked upload after cause made it fail, returning cause
+// The abort isn't tied to ctx so a cancelled run still cleans up; a failed abort is added to the returned error
+func (c *InstrumentationClient) abortUpload(ctx context.Context, uploadURL string, cause error) error {
+	logging.Warnf(ctx, "Aborting chunked metadata submission: %v", cause)
+	abortCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	if _, err := c.send(abortCtx, "Chunked upload abort", http.MethodDelete, uploadURL, nil, "", ""); err != nil {
+		logging.Errorf(ctx, "Failed to abort chunked upload %s: %v", uploadURL, err)
+		return errors.Join(cause, fmt.Errorf("failed to abort chunked upload: %w", err))
+	}
+	return cause
+}
+
+// send executes an authenticated request with retries and returns the body of the 2xx response
+// Bodies are compressed like single submissions; a 422 is returned as a ValidationError
+func (c *InstrumentationClient) send(ctx context.Context, operation, method, url string, body []byte, payloadVersion, idempotencyKey string) ([]byte, error) {
+	requestBody, contentEncoding := body, ""
+	if c.compressAbove > 0 && len(body) > c.compressAbove {
+		if compressed, err := gzipBody(body); err == nil {
+			requestBody, contentEncoding = compressed, "gzip"
+		}
+	}
+
+	retryConfig := retry.Config{
+		MaxAttempts: 3,
+		BaseDelay:   2 * time.Second,
+		Operation:   operation,
+	}
+
+	var responseBody []byte
+	err := retry.Do(ctx, retryConfig, func() error {
+		var reader io.Reader
+		if requestBody != nil {
+			reader = bytes.NewReader(requestBody)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, url, reader)
+		if err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
+		}
+		if requestBody != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if contentEncoding != "" {
+			req.Header.Set("Content-Encoding", contentEncoding)
+		}
+		if payloadVersion != "" {
+			req.Header.Set("Accept-Version", payloadVersion)
+		}
+		if idempotencyKey != "" {
+			req.Header.Set(idempotency.Header, idempotencyKey)
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("HTTP request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		if resp.StatusCode == http.StatusUnprocessableEntity {
+			validationErr := newValidationError("", "", respBody)
+			for _, detail := range validationErr.Details {
+				logging.Errorf(ctx, "Invalid metadata: %s", detail)
+			}
+			return retry.NewNonRetryableError(validationErr)
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			err := fmt.Errorf("%s failed with status %d: %s", operation, resp.StatusCode, truncate(string(respBody), 500))
+			if !IsRetryableStatus(resp.StatusCode) {
+				return retry.NewNonRetryableError(err)
+			}
+			return err
+		}
+		responseBody = respBody
+		return nil
+	})
+	return responseBody, err
+}
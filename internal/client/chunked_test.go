@@ -0,0 +1,80 @@
+package client
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchDefinitions(t *testing.T) {
+	definition := func(name string, size int) models.ConfigurationDefinition {
+		return models.ConfigurationDefinition{"type": name, "schema": strings.Repeat("a", size)}
+	}
+
+	tests := []struct {
+		name        string
+		definitions []models.ConfigurationDefinition
+		limit       int
+		expected    [][]string
+		expectedErr string
+	}{
+		{
+			name:        "everything fits one batch",
+			definitions: []models.ConfigurationDefinition{definition("a", 10), definition("b", 10)},
+			limit:       1024,
+			expected:    [][]string{{"a", "b"}},
+		},
+		{
+			name:        "definitions split in order",
+			definitions: []models.ConfigurationDefinition{definition("a", 300), definition("b", 300), definition("c", 300)},
+			limit:       700,
+			expected:    [][]string{{"a", "b"}, {"c"}},
+		},
+		{
+			name:        "a large definition gets its own batch",
+			definitions: []models.ConfigurationDefinition{definition("a", 100), definition("b", 600), definition("c", 100)},
+			limit:       700,
+			expected:    [][]string{{"a"}, {"b"}, {"c"}},
+		},
+		{
+			name:        "a definition larger than the limit",
+			definitions: []models.ConfigurationDefinition{definition("a", 100), definition("b", 2000)},
+			limit:       700,
+			expectedErr: "configuration definition 1 is",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewInstrumentationClient("http://localhost", "token")
+			c.SetMaxPayloadSize(tt.limit)
+
+			// method under test
+			batches, err := c.batchDefinitions(tt.definitions)
+
+			if tt.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			var got [][]string
+			for _, body := range batches {
+				assert.LessOrEqual(t, len(body), tt.limit)
+				var batch definitionBatch
+				require.NoError(t, json.Unmarshal(body, &batch))
+				var names []string
+				for _, definition := range batch.ConfigurationDefinitions {
+					names = append(names, definition["type"].(string))
+				}
+				got = append(got, names)
+			}
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"agent-metadata-action/internal/models"
+)
+
+// IsRetryableStatus reports whether a request that failed with the given status is worth retrying
+// 5xx (server errors), 408 (timeout) and 429 (rate limit) are retried; other 4xx responses such as
+// 400, 401, 403, 409 and 422 would fail the same way again
+func IsRetryableStatus(status int) bool {
+	return status >= 500 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests
+}
+
+// FieldError is a single problem the service found with a submitted payload
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when the instrumentation service rejects metadata as invalid (422)
+// Details lists the individual problems when the service reports them
+type ValidationError struct {
+	AgentType string
+	Version   string
+	Message   string
+	Details   []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msg := fmt.Sprintf("metadata submission failed with status %d: %s", http.StatusUnprocessableEntity, e.Message)
+	if len(e.Details) > 0 {
+		details := make([]string, 0, len(e.Details))
+		for _, detail := range e.Details {
+			details = append(details, detail.String())
+		}
+		msg += " (" + strings.Join(details, "; ") + ")"
+	}
+	return msg
+}
+
+// ReleaseLockedError is returned when another run holds the lock on releasing an agent version (409)
+type ReleaseLockedError struct {
+	AgentType string
+	Version   string
+	Lock      models.ReleaseLock
+}
+
+func (e *ReleaseLockedError) Error() string {
+	owner := e.Lock.Owner
+	if owner == "" {
+		owner = "another run"
+	}
+	msg := fmt.Sprintf("release of %s version %s already in progress by %s", e.AgentType, e.Version, owner)
+	if !e.Lock.ExpiresAt.IsZero() {
+		msg += fmt.Sprintf(" (its lock lapses at %s unless renewed)", e.Lock.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	return msg
+}
+
+func (f FieldError) String() string {
+	if f.Field == "" {
+		return f.Message
+	}
+	return f.Field + ": " + f.Message
+}
+
+// validationResponse is the body the service returns with a 422
+// Older deployments use "error" and "details" rather than "message" and "errors"
+type validationResponse struct {
+	Message string       `json:"message"`
+	Error   string       `json:"error"`
+	Errors  []FieldError `json:"errors"`
+	Details []FieldError `json:"details"`
+}
+
+// newValidationError builds a ValidationError from a 422 response body
+// Bodies that aren't in the expected shape are kept whole as the message
+func newValidationError(agentType, version string, body []byte) *ValidationError {
+	validationErr := &ValidationError{AgentType: agentType, Version: version}
+
+	var response validationResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		validationErr.Message = truncate(strings.TrimSpace(string(body)), 500)
+		return validationErr
+	}
+
+	validationErr.Message = response.Message
+	if validationErr.Message == "" {
+		validationErr.Message = response.Error
+	}
+	if validationErr.Message == "" {
+		validationErr.Message = "metadata is invalid"
+	}
+	validationErr.Details = append(response.Errors, response.Details...)
+	return validationErr
+}
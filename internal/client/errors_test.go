@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/retry"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetryableStatus(t *testing.T) {
+	for _, status := range []int{500, 502, 503, 504, 408, 429} {
+		assert.True(t, IsRetryableStatus(status), "status %d should be retried", status)
+	}
+	for _, status := range []int{400, 401, 403, 404, 409, 422} {
+		assert.False(t, IsRetryableStatus(status), "status %d should not be retried", status)
+	}
+}
+
+func TestSendMetadata_RetryClassification(t *testing.T) {
+	tests := []struct {
+		statusCode       int
+		expectedAttempts int
+	}{
+		{statusCode: http.StatusServiceUnavailable, expectedAttempts: 3},
+		{statusCode: http.StatusTooManyRequests, expectedAttempts: 3},
+		{statusCode: http.StatusForbidden, expectedAttempts: 1},
+		{statusCode: http.StatusConflict, expectedAttempts: 1},
+		{statusCode: http.StatusUnprocessableEntity, expectedAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.statusCode), func(t *testing.T) {
+			attempts := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer server.Close()
+			testutil.CaptureOutput(t)
+
+			client := NewInstrumentationClient(server.URL, "test-token")
+
+			// method under test
+			err := client.SendMetadata(context.Background(), "NRJavaAgent", "1.2.3", &models.AgentMetadata{
+				Metadata: models.Metadata{"version": "1.2.3"},
+			})
+
+			require.Error(t, err)
+			assert.Equal(t, tt.expectedAttempts, attempts)
+			assert.Equal(t, tt.expectedAttempts == 1, retry.IsNonRetryable(err))
+		})
+	}
+}
+
+func TestSendMetadata_ValidationError(t *testing.T) {
+	tests := []struct {
+		name            string
+		body            string
+		expectedMessage string
+		expectedDetails []FieldError
+	}{
+		{
+			name:            "field errors",
+			body:            `{"message": "metadata is invalid", "errors": [{"field": "metadata.version", "message": "is required"}, {"field": "configurationDefinitions[0].schema", "message": "is not valid JSON"}]}`,
+			expectedMessage: "metadata is invalid",
+			expectedDetails: []FieldError{
+				{Field: "metadata.version", Message: "is required"},
+				{Field: "configurationDefinitions[0].schema", Message: "is not valid JSON"},
+			},
+		},
+		{
+			name:            "legacy error and details",
+			body:            `{"error": "validation failed", "details": [{"message": "unknown platform"}]}`,
+			expectedMessage: "validation failed",
+			expectedDetails: []FieldError{{Message: "unknown platform"}},
+		},
+		{
+			name:            "plain text body",
+			body:            "schema is invalid",
+			expectedMessage: "schema is invalid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			getStdout, _ := testutil.CaptureOutput(t)
+
+			client := NewInstrumentationClient(server.URL, "test-token")
+
+			// method under test
+			err := client.SendMetadata(context.Background(), "NRJavaAgent", "1.2.3", &models.AgentMetadata{
+				Metadata: models.Metadata{"version": "1.2.3"},
+			})
+
+			var validationErr *ValidationError
+			require.True(t, errors.As(err, &validationErr), "expected a ValidationError, got %v", err)
+			assert.Equal(t, "NRJavaAgent", validationErr.AgentType)
+			assert.Equal(t, "1.2.3", validationErr.Version)
+			assert.Equal(t, tt.expectedMessage, validationErr.Message)
+			assert.Equal(t, tt.expectedDetails, validationErr.Details)
+			assert.Contains(t, err.Error(), "metadata submission failed with status 422")
+			stdout := getStdout()
+			for _, detail := range tt.expectedDetails {
+				assert.Contains(t, err.Error(), detail.String())
+				assert.Contains(t, stdout, "Invalid metadata: "+detail.String())
+			}
+		})
+	}
+}
@@ -2,23 +2,39 @@ package client
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
+	"sync"
 	"time"
 
+	"agent-metadata-action/internal/idempotency"
 	"agent-metadata-action/internal/logging"
 	"agent-metadata-action/internal/models"
 	"agent-metadata-action/internal/retry"
 )
 
+// PayloadVersionAuto selects the newest payload version supported by both the service and this build
+const PayloadVersionAuto = "auto"
+
 // InstrumentationClient handles instrumentation metadata operations
 type InstrumentationClient struct {
-	baseURL    string
-	httpClient *http.Client
-	token      string
+	baseURL        string
+	httpClient     *http.Client
+	token          string
+	payloadVersion string
+	negotiateMu    sync.Mutex // held while negotiating, so concurrent submissions wait for the probe to finish
+	negotiated     string
+	features       models.Features // payload features the service advertised when the version was negotiated
+	runID          string
+	compressAbove  int
+	maxPayloadSize int
+	emptyFields    models.EmptyFieldPolicy
 }
 
 // NewInstrumentationClient creates a new instrumentation client
@@ -28,32 +44,163 @@ func NewInstrumentationClient(baseURL, token string) *InstrumentationClient {
 		httpClient: &http.Client{
 			Timeout: 1 * time.Minute,
 		},
-		token: token,
+		token:          token,
+		payloadVersion: models.PayloadV1,
+	}
+}
+
+// SetPayloadVersion selects the payload version (see models.PayloadVersions) or PayloadVersionAuto
+// Clients send v1 payloads unless set otherwise
+func (c *InstrumentationClient) SetPayloadVersion(version string) {
+	c.negotiateMu.Lock()
+	defer c.negotiateMu.Unlock()
+	c.payloadVersion = version
+	c.negotiated = ""
+	c.features = nil
+}
+
+// SetRunID scopes idempotency keys to a workflow run, so re-run attempts of the run reuse the keys of the first attempt
+// Without a run ID keys are scoped to the process (see idempotency.RunScope), so they only cover its retries
+func (c *InstrumentationClient) SetRunID(runID string) {
+	c.runID = runID
+}
+
+// SetCompressionThreshold gzip-compresses metadata submissions whose JSON body is larger than threshold bytes
+// Zero or less sends every submission uncompressed (the default)
+func (c *InstrumentationClient) SetCompressionThreshold(threshold int) {
+	c.compressAbove = threshold
+}
+
+// SetMaxPayloadSize sets the largest request body in bytes the service accepts
+// Larger metadata submissions are split into a chunked upload; zero or less sends every submission whole (the default)
+func (c *InstrumentationClient) SetMaxPayloadSize(limit int) {
+	c.maxPayloadSize = limit
+}
+
+// SetEmptyFieldPolicy sets how empty metadata fields and definition lists are sent (models.EmptyOmit by default)
+func (c *InstrumentationClient) SetEmptyFieldPolicy(policy models.EmptyFieldPolicy) {
+	c.emptyFields = policy
+}
+
+// capabilitiesResponse is the body returned by the capability probe
+type capabilitiesResponse struct {
+	PayloadVersions []string `json:"payloadVersions"`
+	Features        []string `json:"features"`
+}
+
+// PayloadVersion returns the payload version to use, probing the service once in auto mode
+// GET /v1/capabilities
+// Services without the capabilities endpoint (or that can't be reached) get v1
+// The payload features the service advertises are recorded too; a pinned version is never probed, so it gets none
+// Safe for concurrent use: callers arriving during the probe wait for its outcome
+func (c *InstrumentationClient) PayloadVersion(ctx context.Context) string {
+	c.negotiateMu.Lock()
+	defer c.negotiateMu.Unlock()
+	switch c.payloadVersion {
+	case "":
+		return models.PayloadV1
+	case PayloadVersionAuto:
+	default:
+		return c.payloadVersion
+	}
+	if c.negotiated != "" {
+		return c.negotiated
+	}
+
+	c.negotiated, c.features = c.negotiate(ctx)
+	return c.negotiated
+}
+
+// negotiate probes the service for the newest payload version and the payload features both sides support
+func (c *InstrumentationClient) negotiate(ctx context.Context) (string, models.Features) {
+	url := fmt.Sprintf("%s/v1/capabilities", c.baseURL)
+	body, status, err := c.get(ctx, url, "")
+	switch {
+	case err != nil:
+		logging.Warnf(ctx, "Unable to probe instrumentation service capabilities: %v - using payload %s", err, models.PayloadV1)
+		return models.PayloadV1, nil
+	case status == http.StatusNotFound:
+		logging.Debugf(ctx, "Instrumentation service has no capabilities endpoint - using payload %s", models.PayloadV1)
+		return models.PayloadV1, nil
+	case status < 200 || status >= 300:
+		logging.Warnf(ctx, "Instrumentation service capability probe failed with status %d - using payload %s", status, models.PayloadV1)
+		return models.PayloadV1, nil
+	}
+
+	var capabilities capabilitiesResponse
+	if err := json.Unmarshal(body, &capabilities); err != nil {
+		logging.Warnf(ctx, "Unable to parse instrumentation service capabilities: %v - using payload %s", err, models.PayloadV1)
+		return models.PayloadV1, nil
+	}
+	// PayloadVersions is ordered oldest first, so the last supported one wins
+	negotiated := models.PayloadV1
+	for _, version := range models.PayloadVersions {
+		for _, supported := range capabilities.PayloadVersions {
+			if version == supported {
+				negotiated = version
+			}
+		}
+	}
+	var features models.Features
+	for _, feature := range models.PayloadFeatures {
+		if models.Features(capabilities.Features).Has(feature) {
+			features = append(features, feature)
+		}
+	}
+	logging.Debugf(ctx, "Instrumentation service supports payloads %v and features %v - using payload %s", capabilities.PayloadVersions, features, negotiated)
+	return negotiated, features
+}
+
+// warnGatedFields warns about each kind of field in metadata that is left out of the payload because the service
+// didn't advertise the feature it needs
+func (c *InstrumentationClient) warnGatedFields(ctx context.Context, payloadVersion string, metadata *models.AgentMetadata) {
+	for _, feature := range metadata.GatedFields() {
+		switch {
+		case feature == models.FeatureLocalizedDescriptions && payloadVersion != models.PayloadV3:
+			logging.Warnf(ctx, "Payload %s has no localized descriptions - sending descriptions untranslated (payload %s is required)", payloadVersion, models.PayloadV3)
+		case !c.features.Has(feature):
+			logging.Warnf(ctx, "Instrumentation service doesn't advertise the %s payload feature - leaving those fields out", feature)
+		}
 	}
 }
 
 // SendMetadata sends agent metadata to the instrumentation service
 // POST /v1/agents/{agentType}/versions/{agentVersion}
 func (c *InstrumentationClient) SendMetadata(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata) error {
+	_, err := c.SubmitMetadata(ctx, agentType, agentVersion, metadata)
+	return err
+}
+
+// SubmitMetadata sends agent metadata to the instrumentation service and returns the parsed response
+// A success response that contradicts the submission (e.g. echoes a different version) is returned as an error
+// POST /v1/agents/{agentType}/versions/{agentVersion}
+func (c *InstrumentationClient) SubmitMetadata(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata) (models.SubmissionResponse, error) {
 	logging.Log(ctx, "group", "Sending metadata to instrumentation service")
 	defer logging.Log(ctx, "endgroup", "")
 
+	var response models.SubmissionResponse
+
 	// Validate inputs
 	logging.Debug(ctx, "Validating inputs...")
 	if metadata == nil {
 		logging.Error(ctx, "Metadata is required but was nil")
-		return fmt.Errorf("metadata is required")
+		return response, fmt.Errorf("metadata is required")
 	}
 	if agentType == "" {
 		logging.Error(ctx, "Agent type is required but was empty")
-		return fmt.Errorf("agent type is required")
+		return response, fmt.Errorf("agent type is required")
 	}
 	if agentVersion == "" {
 		logging.Error(ctx, "Agent version is required but was empty")
-		return fmt.Errorf("agent version is required")
+		return response, fmt.Errorf("agent version is required")
 	}
 	logging.Debugf(ctx, "Agent type: %s", agentType)
 	logging.Debugf(ctx, "Agent version: %s", agentVersion)
+	// Last gate before the payload is built: the service rejects the whole submission over one corrupt schema
+	if err := metadata.Validate(); err != nil {
+		logging.Errorf(ctx, "Invalid metadata content: %v", err)
+		return response, retry.NewNonRetryableError(fmt.Errorf("invalid metadata content: %w", err))
+	}
 
 	// Construct URL
 	url := fmt.Sprintf("%s/v1/agents/%s/versions/%s", c.baseURL, agentType, agentVersion)
@@ -61,8 +208,10 @@ func (c *InstrumentationClient) SendMetadata(ctx context.Context, agentType stri
 	logging.Debugf(ctx, "Base URL: %s", c.baseURL)
 
 	// Marshal metadata to JSON
-	logging.Debug(ctx, "Marshaling metadata to JSON...")
-	jsonBody, err := json.Marshal(metadata)
+	payloadVersion := c.PayloadVersion(ctx)
+	c.warnGatedFields(ctx, payloadVersion, metadata)
+	logging.Debugf(ctx, "Marshaling metadata to JSON (payload %s)...", payloadVersion)
+	jsonBody, err := models.EncodePayload(payloadVersion, metadata, c.emptyFields, c.features)
 	if err != nil {
 		logging.NoticeErrorWithCategory(ctx, err, "metadata.send", map[string]interface{}{
 			"error.operation": "marshal_metadata",
@@ -70,12 +219,38 @@ func (c *InstrumentationClient) SendMetadata(ctx context.Context, agentType stri
 			"agent.version":   agentVersion,
 		})
 		logging.Errorf(ctx, "Failed to marshal metadata: %v", err)
-		return retry.NewNonRetryableError(fmt.Errorf("failed to marshal metadata: %w", err))
+		return response, retry.NewNonRetryableError(fmt.Errorf("failed to marshal metadata: %w", err))
 	}
 	logging.Debugf(ctx, "JSON payload size: %d bytes", len(jsonBody))
 	logging.Debugf(ctx, "Configuration definitions count: %d", len(metadata.ConfigurationDefinitions))
 	logging.Debugf(ctx, "Agent control entries: %d", len(metadata.AgentControlDefinitions))
 
+	// The key is shared by every retry so a submission that succeeded after a client timeout isn't recorded twice
+	idempotencyKey := idempotency.Key(agentType, agentVersion, idempotency.PayloadHash(jsonBody), idempotency.RunScope(c.runID))
+	logging.Debugf(ctx, "Idempotency key: %s", idempotencyKey)
+
+	// Large payloads (mostly base64 schemas) are compressed once and the result reused by every retry
+	requestBody, contentEncoding := jsonBody, ""
+	if c.compressAbove > 0 && len(jsonBody) > c.compressAbove {
+		compressed, err := gzipBody(jsonBody)
+		if err != nil {
+			logging.Warnf(ctx, "Unable to compress metadata payload: %v - sending it uncompressed", err)
+		} else {
+			requestBody, contentEncoding = compressed, "gzip"
+			logging.Debugf(ctx, "Compressed payload from %d to %d bytes", len(jsonBody), len(compressed))
+		}
+	}
+
+	if c.maxPayloadSize > 0 && len(requestBody) > c.maxPayloadSize {
+		response, err = c.submitChunked(ctx, agentType, agentVersion, payloadVersion, metadata, idempotency.PayloadHash(jsonBody))
+		if err != nil {
+			logging.Errorf(ctx, "Chunked metadata submission failed: %v", err)
+			return response, err
+		}
+		logging.Noticef(ctx, "Metadata successfully submitted to instrumentation service in parts (ID: %s)", response.ID)
+		return response, nil
+	}
+
 	// Execute request with retry logic
 	retryConfig := retry.Config{
 		MaxAttempts: 3,
@@ -86,7 +261,7 @@ func (c *InstrumentationClient) SendMetadata(ctx context.Context, agentType stri
 	err = retry.Do(ctx, retryConfig, func() error {
 		// Create HTTP request (must be recreated for each retry)
 		logging.Debug(ctx, "Creating HTTP POST request...")
-		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
 		if err != nil {
 			logging.NoticeErrorWithCategory(ctx, err, "metadata.send", map[string]interface{}{
 				"error.operation": "create_http_request",
@@ -101,6 +276,11 @@ func (c *InstrumentationClient) SendMetadata(ctx context.Context, agentType stri
 		// Set headers
 		logging.Debug(ctx, "Setting request headers...")
 		req.Header.Set("Content-Type", "application/json")
+		if contentEncoding != "" {
+			req.Header.Set("Content-Encoding", contentEncoding)
+		}
+		req.Header.Set("Accept-Version", payloadVersion)
+		req.Header.Set(idempotency.Header, idempotencyKey)
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
 
 		// Execute request
@@ -154,11 +334,17 @@ func (c *InstrumentationClient) SendMetadata(ctx context.Context, agentType stri
 			logging.Errorf(ctx, "Metadata submission failed with status %d", resp.StatusCode)
 			logging.Debugf(ctx, "Error response body: %s", responsePreview)
 
-			// Determine if error is retryable
-			// Retry on: 5xx (server errors), 408 (timeout), 429 (rate limit)
-			// Don't retry: 4xx (client errors, except 408 and 429)
-			isRetryable := resp.StatusCode >= 500 || resp.StatusCode == 408 || resp.StatusCode == 429
-			if !isRetryable {
+			if resp.StatusCode == http.StatusUnprocessableEntity {
+				validationErr := newValidationError(agentType, agentVersion, body)
+				for _, detail := range validationErr.Details {
+					logging.Errorf(ctx, "Invalid metadata: %s", detail)
+				}
+				return retry.NewNonRetryableError(validationErr)
+			}
+			if resp.StatusCode == http.StatusRequestEntityTooLarge {
+				return retry.NewNonRetryableError(fmt.Errorf("metadata submission of %d bytes exceeds the service payload limit (status 413) - set max-payload-size to submit it in parts", len(requestBody)))
+			}
+			if !IsRetryableStatus(resp.StatusCode) {
 				return retry.NewNonRetryableError(err)
 			}
 			return err
@@ -169,13 +355,452 @@ func (c *InstrumentationClient) SendMetadata(ctx context.Context, agentType stri
 			logging.Debugf(ctx, "Success response: %s", string(body))
 		}
 
+		// Retrying won't change a response that contradicts the submission
+		response, err = models.ParseSubmissionResponse(body, agentType, agentVersion)
+		if err != nil {
+			err = fmt.Errorf("metadata submission returned status %d with an unexpected response: %w", resp.StatusCode, err)
+			logging.NoticeErrorWithCategory(ctx, err, "metadata.send", map[string]interface{}{
+				"error.operation":    "validate_response",
+				"http.status_code":   resp.StatusCode,
+				"http.url":           url,
+				"http.response_body": truncate(string(body), 500),
+				"agent.type":         agentType,
+				"agent.version":      agentVersion,
+			})
+			return retry.NewNonRetryableError(err)
+		}
+
 		return nil
 	})
 
 	if err != nil {
-		return err
+		return response, err
+	}
+
+	if response.ID != "" {
+		logging.Noticef(ctx, "Metadata successfully submitted to instrumentation service (ID: %s)", response.ID)
+	} else {
+		logging.Notice(ctx, "Metadata successfully submitted to instrumentation service")
+	}
+	return response, nil
+}
+
+// SubmitIncremental updates the metadata the instrumentation service stores for a version with only the definitions
+// that changed since it was submitted: metadata has the added and changed definitions, which replace stored
+// definitions with the same key, and removed identifies the stored definitions to drop
+// The metadata fields are always sent in full. The response is parsed like SubmitMetadata's
+// PATCH /v1/agents/{agentType}/versions/{agentVersion}
+func (c *InstrumentationClient) SubmitIncremental(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata, removed models.RemovedDefinitions) (response models.SubmissionResponse, err error) {
+	if metadata == nil {
+		return response, fmt.Errorf("metadata is required")
+	}
+	if agentType == "" {
+		return response, fmt.Errorf("agent type is required")
+	}
+	if agentVersion == "" {
+		return response, fmt.Errorf("agent version is required")
+	}
+	if err := metadata.Validate(); err != nil {
+		return response, retry.NewNonRetryableError(fmt.Errorf("invalid metadata content: %w", err))
+	}
+	// send doesn't know which version a rejected submission was for
+	defer func() {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			validationErr.AgentType, validationErr.Version = agentType, agentVersion
+		}
+	}()
+
+	payloadVersion := c.PayloadVersion(ctx)
+	c.warnGatedFields(ctx, payloadVersion, metadata)
+	body, err := models.EncodeIncrementalPayload(payloadVersion, metadata, removed, c.emptyFields, c.features)
+	if err != nil {
+		return response, retry.NewNonRetryableError(fmt.Errorf("failed to marshal metadata: %w", err))
+	}
+	if c.maxPayloadSize > 0 && len(body) > c.maxPayloadSize {
+		return response, retry.NewNonRetryableError(fmt.Errorf("incremental metadata submission of %d bytes exceeds the %d byte payload limit - submit it in full to send it in parts", len(body), c.maxPayloadSize))
 	}
+	logging.Debugf(ctx, "Incremental payload size: %d bytes", len(body))
 
-	logging.Notice(ctx, "Metadata successfully submitted to instrumentation service")
-	return nil
+	url := fmt.Sprintf("%s/v1/agents/%s/versions/%s", c.baseURL, agentType, agentVersion)
+	respBody, err := c.send(ctx, "Incremental metadata submission", http.MethodPatch, url, body, payloadVersion,
+		idempotency.Key(agentType, agentVersion, idempotency.PayloadHash(body), idempotency.RunScope(c.runID), "incremental"))
+	if err != nil {
+		return response, err
+	}
+	response, err = models.ParseSubmissionResponse(respBody, agentType, agentVersion)
+	if err != nil {
+		return response, retry.NewNonRetryableError(fmt.Errorf("incremental metadata submission returned an unexpected response: %w", err))
+	}
+	return response, nil
+}
+
+// GetMetadata fetches the agent metadata currently stored by the instrumentation service
+// GET /v1/agents/{agentType}/versions/{agentVersion}
+// Returns nil, nil if the service has no metadata for the version (404)
+func (c *InstrumentationClient) GetMetadata(ctx context.Context, agentType string, agentVersion string) (*models.AgentMetadata, error) {
+	if agentType == "" {
+		return nil, fmt.Errorf("agent type is required")
+	}
+	if agentVersion == "" {
+		return nil, fmt.Errorf("agent version is required")
+	}
+
+	url := fmt.Sprintf("%s/v1/agents/%s/versions/%s", c.baseURL, agentType, agentVersion)
+	logging.Debugf(ctx, "Fetching metadata from %s", url)
+
+	retryConfig := retry.Config{
+		MaxAttempts: 3,
+		BaseDelay:   2 * time.Second,
+		Operation:   "Metadata fetch",
+	}
+
+	payloadVersion := c.PayloadVersion(ctx)
+	var metadata *models.AgentMetadata
+	err := retry.Do(ctx, retryConfig, func() error {
+		body, status, err := c.get(ctx, url, payloadVersion)
+		if err != nil {
+			return err
+		}
+
+		if status == http.StatusNotFound {
+			logging.Debugf(ctx, "No metadata found for %s version %s", agentType, agentVersion)
+			metadata = nil
+			return nil
+		}
+
+		if status < 200 || status >= 300 {
+			err := fmt.Errorf("metadata fetch failed with status %d: %s", status, truncate(string(body), 500))
+			if !IsRetryableStatus(status) {
+				return retry.NewNonRetryableError(err)
+			}
+			return err
+		}
+
+		result, err := models.DecodePayload(payloadVersion, body)
+		if err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to parse metadata response: %w", err))
+		}
+		metadata = result
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return metadata, nil
+}
+
+// DeleteMetadata deletes the metadata the instrumentation service stores for an agent version, as rollback mode does
+// for a release that failed after submitting it
+// DELETE /v1/agents/{agentType}/versions/{agentVersion}
+// Returns false if the service has no metadata for the version (404)
+func (c *InstrumentationClient) DeleteMetadata(ctx context.Context, agentType string, agentVersion string) (bool, error) {
+	if agentType == "" {
+		return false, fmt.Errorf("agent type is required")
+	}
+	if agentVersion == "" {
+		return false, fmt.Errorf("agent version is required")
+	}
+
+	url := fmt.Sprintf("%s/v1/agents/%s/versions/%s", c.baseURL, agentType, agentVersion)
+	logging.Debugf(ctx, "Deleting metadata at %s", url)
+
+	retryConfig := retry.Config{
+		MaxAttempts: 3,
+		BaseDelay:   2 * time.Second,
+		Operation:   "Metadata deletion",
+	}
+
+	deleted := false
+	err := retry.Do(ctx, retryConfig, func() error {
+		body, status, err := c.do(ctx, http.MethodDelete, url, "", nil)
+		if err != nil {
+			return err
+		}
+
+		if status == http.StatusNotFound {
+			logging.Debugf(ctx, "No metadata found for %s version %s", agentType, agentVersion)
+			deleted = false
+			return nil
+		}
+
+		if status < 200 || status >= 300 {
+			err := fmt.Errorf("metadata deletion failed with status %d: %s", status, truncate(string(body), 500))
+			if !IsRetryableStatus(status) {
+				return retry.NewNonRetryableError(err)
+			}
+			return err
+		}
+		deleted = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return deleted, nil
+}
+
+// ErrReleaseLockUnsupported is returned by LockRelease when the instrumentation service has no release locks
+var ErrReleaseLockUnsupported = errors.New("the instrumentation service doesn't support release locks")
+
+// lockRequest is the body of a request for the release lock of an agent version
+type lockRequest struct {
+	Owner      string `json:"owner"`
+	TTLSeconds int    `json:"ttlSeconds"`
+}
+
+// LockRelease locks releasing an agent version for owner until ttl from now, or extends the lock owner already
+// holds, and returns the lock
+// PUT /v1/agents/{agentType}/versions/{agentVersion}/lock
+// Returns a *ReleaseLockedError if another owner holds the lock (409), and ErrReleaseLockUnsupported if the service
+// has no release locks (404 or 405)
+func (c *InstrumentationClient) LockRelease(ctx context.Context, agentType, agentVersion, owner string, ttl time.Duration) (models.ReleaseLock, error) {
+	if agentType == "" {
+		return models.ReleaseLock{}, fmt.Errorf("agent type is required")
+	}
+	if agentVersion == "" {
+		return models.ReleaseLock{}, fmt.Errorf("agent version is required")
+	}
+
+	url := fmt.Sprintf("%s/v1/agents/%s/versions/%s/lock", c.baseURL, agentType, agentVersion)
+	requestBody, err := json.Marshal(lockRequest{Owner: owner, TTLSeconds: int(ttl.Round(time.Second) / time.Second)})
+	if err != nil {
+		return models.ReleaseLock{}, fmt.Errorf("failed to marshal lock request: %w", err)
+	}
+	logging.Debugf(ctx, "Locking the release at %s for %s", url, owner)
+
+	retryConfig := retry.Config{
+		MaxAttempts: 3,
+		BaseDelay:   2 * time.Second,
+		Operation:   "Release lock",
+	}
+
+	var lock models.ReleaseLock
+	err = retry.Do(ctx, retryConfig, func() error {
+		body, status, err := c.do(ctx, http.MethodPut, url, "", requestBody)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case status == http.StatusNotFound || status == http.StatusMethodNotAllowed:
+			return retry.NewNonRetryableError(ErrReleaseLockUnsupported)
+		case status == http.StatusConflict:
+			locked := &ReleaseLockedError{AgentType: agentType, Version: agentVersion}
+			if err := json.Unmarshal(body, &locked.Lock); err != nil {
+				logging.Debugf(ctx, "Unable to parse the lock held on %s version %s: %v", agentType, agentVersion, err)
+			}
+			return retry.NewNonRetryableError(locked)
+		case status < 200 || status >= 300:
+			err := fmt.Errorf("release lock failed with status %d: %s", status, truncate(string(body), 500))
+			if !IsRetryableStatus(status) {
+				return retry.NewNonRetryableError(err)
+			}
+			return err
+		}
+
+		if err := json.Unmarshal(body, &lock); err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to parse lock response: %w", err))
+		}
+		return nil
+	})
+	if err != nil {
+		return models.ReleaseLock{}, err
+	}
+
+	return lock, nil
+}
+
+// UnlockRelease releases the lock owner holds on releasing an agent version
+// DELETE /v1/agents/{agentType}/versions/{agentVersion}/lock?owner={owner}
+// A lock that has already lapsed (404) isn't an error; a *ReleaseLockedError is returned if another owner has
+// taken it since (409)
+func (c *InstrumentationClient) UnlockRelease(ctx context.Context, agentType, agentVersion, owner string) error {
+	url := fmt.Sprintf("%s/v1/agents/%s/versions/%s/lock?owner=%s", c.baseURL, agentType, agentVersion, neturl.QueryEscape(owner))
+	logging.Debugf(ctx, "Unlocking the release at %s", url)
+
+	retryConfig := retry.Config{
+		MaxAttempts: 3,
+		BaseDelay:   2 * time.Second,
+		Operation:   "Release unlock",
+	}
+
+	return retry.Do(ctx, retryConfig, func() error {
+		body, status, err := c.do(ctx, http.MethodDelete, url, "", nil)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case status == http.StatusNotFound:
+			logging.Debugf(ctx, "No lock held on %s version %s", agentType, agentVersion)
+			return nil
+		case status == http.StatusConflict:
+			locked := &ReleaseLockedError{AgentType: agentType, Version: agentVersion}
+			if err := json.Unmarshal(body, &locked.Lock); err != nil {
+				logging.Debugf(ctx, "Unable to parse the lock held on %s version %s: %v", agentType, agentVersion, err)
+			}
+			return retry.NewNonRetryableError(locked)
+		case status < 200 || status >= 300:
+			err := fmt.Errorf("release unlock failed with status %d: %s", status, truncate(string(body), 500))
+			if !IsRetryableStatus(status) {
+				return retry.NewNonRetryableError(err)
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// versionsResponse is the body returned when listing an agent type's versions
+type versionsResponse struct {
+	Versions []string `json:"versions"`
+}
+
+// ListVersions lists the versions the instrumentation service has metadata for
+// GET /v1/agents/{agentType}/versions, following nextCursor across pages
+// Returns an empty list if the service does not know the agent type (404)
+func (c *InstrumentationClient) ListVersions(ctx context.Context, agentType string) ([]string, error) {
+	if agentType == "" {
+		return nil, fmt.Errorf("agent type is required")
+	}
+
+	url := fmt.Sprintf("%s/v1/agents/%s/versions", c.baseURL, agentType)
+	logging.Debugf(ctx, "Listing versions from %s", url)
+
+	versions := []string{}
+	err := c.listPages(ctx, url, "Version listing", true, func(body []byte) error {
+		var result versionsResponse
+		if err := json.Unmarshal(body, &result); err != nil {
+			return err
+		}
+		versions = append(versions, result.Versions...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// releasesResponse is the body returned when listing an agent type's versions with their dates
+type releasesResponse struct {
+	Releases []models.ReleaseSummary `json:"releases"`
+}
+
+// ListReleases lists the versions the instrumentation service has metadata for, with their release and EOL dates
+// GET /v1/agents/{agentType}/releases, following nextCursor across pages
+// Returns an empty list if the service does not know the agent type (404)
+func (c *InstrumentationClient) ListReleases(ctx context.Context, agentType string) ([]models.ReleaseSummary, error) {
+	if agentType == "" {
+		return nil, fmt.Errorf("agent type is required")
+	}
+
+	url := fmt.Sprintf("%s/v1/agents/%s/releases", c.baseURL, agentType)
+	logging.Debugf(ctx, "Listing releases from %s", url)
+
+	releases := []models.ReleaseSummary{}
+	err := c.listPages(ctx, url, "Release listing", true, func(body []byte) error {
+		var result releasesResponse
+		if err := json.Unmarshal(body, &result); err != nil {
+			return err
+		}
+		releases = append(releases, result.Releases...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return releases, nil
+}
+
+// agentTypesResponse is the body returned when listing registered agent types
+type agentTypesResponse struct {
+	AgentTypes []string `json:"agentTypes"`
+}
+
+// ListAgentTypes lists the agent types registered with the instrumentation service
+// GET /v1/agents, following nextCursor across pages
+func (c *InstrumentationClient) ListAgentTypes(ctx context.Context) ([]string, error) {
+	url := fmt.Sprintf("%s/v1/agents", c.baseURL)
+	logging.Debugf(ctx, "Listing agent types from %s", url)
+
+	var agentTypes []string
+	err := c.listPages(ctx, url, "Agent type listing", false, func(body []byte) error {
+		var result agentTypesResponse
+		if err := json.Unmarshal(body, &result); err != nil {
+			return err
+		}
+		agentTypes = append(agentTypes, result.AgentTypes...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return agentTypes, nil
+}
+
+// get executes an authenticated GET request and returns the response body and status code
+// payloadVersion, if set, is sent as the Accept-Version header
+func (c *InstrumentationClient) get(ctx context.Context, url, payloadVersion string) ([]byte, int, error) {
+	return c.do(ctx, http.MethodGet, url, payloadVersion, nil)
+}
+
+// do executes an authenticated request, with a JSON body if body isn't nil, and returns the response body and
+// status code
+func (c *InstrumentationClient) do(ctx context.Context, method, url, payloadVersion string, body []byte) ([]byte, int, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, 0, retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if payloadVersion != "" {
+		req.Header.Set("Accept-Version", payloadVersion)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response: %w", err)
+	}
+	return responseBody, resp.StatusCode, nil
+}
+
+// gzipBody returns body compressed with gzip
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// truncate shortens s to at most n bytes, marking it as truncated
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "... (truncated)"
 }
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"io"
@@ -8,8 +9,11 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"agent-metadata-action/internal/idempotency"
 	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/retry"
 	"agent-metadata-action/internal/testutil"
 
 	"github.com/stretchr/testify/assert"
@@ -378,7 +382,7 @@ func TestSendMetadata_WithConfigurationDefinitionsAndAgentControl(t *testing.T)
 				"description": "Config 1",
 				"type":        "string",
 				"format":      "text",
-				"schema":      "schema1",
+				"schema":      "c2NoZW1hMQ==",
 			},
 			{
 				"version":     "1.0.0",
@@ -386,13 +390,13 @@ func TestSendMetadata_WithConfigurationDefinitionsAndAgentControl(t *testing.T)
 				"description": "Config 2",
 				"type":        "string",
 				"format":      "text",
-				"schema":      "schema2",
+				"schema":      "c2NoZW1hMg==",
 			},
 		},
 		AgentControlDefinitions: []models.AgentControlDefinition{
 			{
 				"platform": "all",
-				"content":  "base64content",
+				"content":  "Y29udGVudA==",
 			},
 		},
 	}
@@ -434,6 +438,32 @@ func TestSendMetadata_MarshalError(t *testing.T) {
 	assert.Contains(t, outputStr, "Failed to marshal metadata")
 }
 
+func TestSendMetadata_InvalidContent(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	client := NewInstrumentationClient(server.URL, "test-token")
+
+	metadata := &models.AgentMetadata{
+		Metadata:                 models.Metadata{"version": "1.2.3"},
+		ConfigurationDefinitions: []models.ConfigurationDefinition{{"type": "agent-config", "schema": "./schemas/config.json"}},
+	}
+
+	getStdout, _ := testutil.CaptureOutput(t)
+
+	// method under test
+	err := client.SendMetadata(context.Background(), "NRJavaAgent", "1.2.3", metadata)
+
+	require.Error(t, err)
+	assert.True(t, retry.IsNonRetryable(err))
+	assert.Contains(t, err.Error(), "invalid metadata content: configurationDefinitions[0].schema: is not valid base64")
+	assert.Contains(t, getStdout(), "Invalid metadata content")
+	assert.Zero(t, requests)
+}
+
 func TestSendMetadata_ResponseBodyReadError(t *testing.T) {
 	// Create test server with custom response that fails to read
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -470,3 +500,486 @@ func TestSendMetadata_ResponseBodyReadError(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to read response")
 	assert.Contains(t, outputStr, "Failed to read response body")
 }
+
+func TestGetMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+
+		switch r.URL.Path {
+		case "/v1/agents/NRJavaAgent/versions/1.2.3":
+			_, _ = w.Write([]byte(`{"metadata": {"version": "1.2.3"}}`))
+		case "/v1/agents/NRJavaAgent/versions/9.9.9":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error": "bad request"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewInstrumentationClient(server.URL, "test-token")
+	ctx := context.Background()
+
+	metadata, err := client.GetMetadata(ctx, "NRJavaAgent", "1.2.3")
+	require.NoError(t, err)
+	require.NotNil(t, metadata)
+	assert.Equal(t, "1.2.3", metadata.Metadata["version"])
+
+	metadata, err = client.GetMetadata(ctx, "NRJavaAgent", "9.9.9")
+	require.NoError(t, err)
+	assert.Nil(t, metadata)
+
+	_, err = client.GetMetadata(ctx, "NRJavaAgent", "bad")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "metadata fetch failed with status 400")
+
+	_, err = client.GetMetadata(ctx, "", "1.2.3")
+	assert.EqualError(t, err, "agent type is required")
+}
+
+func TestDeleteMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "DELETE", r.Method)
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+
+		switch r.URL.Path {
+		case "/v1/agents/NRJavaAgent/versions/1.2.3":
+			w.WriteHeader(http.StatusNoContent)
+		case "/v1/agents/NRJavaAgent/versions/9.9.9":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"error": "forbidden"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewInstrumentationClient(server.URL, "test-token")
+	ctx := context.Background()
+
+	deleted, err := client.DeleteMetadata(ctx, "NRJavaAgent", "1.2.3")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+
+	deleted, err = client.DeleteMetadata(ctx, "NRJavaAgent", "9.9.9")
+	require.NoError(t, err)
+	assert.False(t, deleted, "A version without metadata has nothing to delete")
+
+	_, err = client.DeleteMetadata(ctx, "NRJavaAgent", "bad")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "metadata deletion failed with status 403")
+
+	_, err = client.DeleteMetadata(ctx, "NRJavaAgent", "")
+	assert.EqualError(t, err, "agent version is required")
+}
+
+func TestLockRelease_Unsupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "PUT", r.Method)
+		assert.Equal(t, "/v1/agents/NRJavaAgent/versions/1.2.3/lock", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"owner": "newrelic/java-agent run 1", "ttlSeconds": 1800}`, string(body))
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewInstrumentationClient(server.URL, "test-token")
+
+	_, err := client.LockRelease(context.Background(), "NRJavaAgent", "1.2.3", "newrelic/java-agent run 1", 30*time.Minute)
+
+	assert.ErrorIs(t, err, ErrReleaseLockUnsupported)
+}
+
+func TestListVersions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+
+		switch r.URL.Path {
+		case "/v1/agents/NRJavaAgent/versions":
+			_, _ = w.Write([]byte(`{"versions": ["1.2.3", "1.2.4"]}`))
+		case "/v1/agents/NRUnknownAgent/versions":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	client := NewInstrumentationClient(server.URL, "test-token")
+	ctx := context.Background()
+
+	versions, err := client.ListVersions(ctx, "NRJavaAgent")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.2.3", "1.2.4"}, versions)
+
+	versions, err = client.ListVersions(ctx, "NRUnknownAgent")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+
+	_, err = client.ListVersions(ctx, "NRDotNetAgent")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "version listing failed with status 401")
+}
+
+func TestPayloadVersion(t *testing.T) {
+	tests := []struct {
+		name         string
+		setting      string
+		capabilities func(w http.ResponseWriter)
+		expected     string
+		expectProbe  bool
+	}{
+		{
+			name:    "auto picks newest common version",
+			setting: PayloadVersionAuto,
+			capabilities: func(w http.ResponseWriter) {
+				_, _ = w.Write([]byte(`{"payloadVersions": ["v1", "v2", "v9"]}`))
+			},
+			expected:    models.PayloadV2,
+			expectProbe: true,
+		},
+		{
+			name:    "auto without capabilities endpoint falls back to v1",
+			setting: PayloadVersionAuto,
+			capabilities: func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			expected:    models.PayloadV1,
+			expectProbe: true,
+		},
+		{
+			name:    "auto with unparseable capabilities falls back to v1",
+			setting: PayloadVersionAuto,
+			capabilities: func(w http.ResponseWriter) {
+				_, _ = w.Write([]byte(`not json`))
+			},
+			expected:    models.PayloadV1,
+			expectProbe: true,
+		},
+		{
+			name:     "explicit version skips the probe",
+			setting:  models.PayloadV2,
+			expected: models.PayloadV2,
+		},
+		{
+			name:     "unset defaults to v1",
+			expected: models.PayloadV1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probes := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/v1/capabilities", r.URL.Path)
+				probes++
+				tt.capabilities(w)
+			}))
+			defer server.Close()
+			testutil.CaptureOutput(t)
+
+			client := NewInstrumentationClient(server.URL, "test-token")
+			client.SetPayloadVersion(tt.setting)
+
+			// method under test
+			assert.Equal(t, tt.expected, client.PayloadVersion(context.Background()))
+			assert.Equal(t, tt.expected, client.PayloadVersion(context.Background()))
+
+			if tt.expectProbe {
+				assert.Equal(t, 1, probes, "the service should be probed once")
+			} else {
+				assert.Zero(t, probes)
+			}
+		})
+	}
+}
+
+func TestSendMetadata_PayloadV2(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/capabilities" {
+			_, _ = w.Write([]byte(`{"payloadVersions": ["v1", "v2"]}`))
+			return
+		}
+		assert.Equal(t, models.PayloadV2, r.Header.Get("Accept-Version"))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		metadata, err := models.DecodePayload(models.PayloadV2, body)
+		require.NoError(t, err)
+		assert.Equal(t, "1.2.3", metadata.Metadata["version"])
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	testutil.CaptureOutput(t)
+
+	client := NewInstrumentationClient(server.URL, "test-token")
+	client.SetPayloadVersion(PayloadVersionAuto)
+
+	// method under test
+	err := client.SendMetadata(context.Background(), "NRJavaAgent", "1.2.3", &models.AgentMetadata{
+		Metadata: models.Metadata{"version": "1.2.3"},
+	})
+	require.NoError(t, err)
+}
+
+func TestSendMetadata_PayloadFeatures(t *testing.T) {
+	metadata := &models.AgentMetadata{
+		ConfigurationDefinitions: []models.ConfigurationDefinition{{"platform": "ALL", "description": "Agent configuration", "description_i18n": map[string]interface{}{"de": "Agentenkonfiguration"}}},
+		Metadata:                 models.Metadata{"version": "1.2.3"},
+		AgentControlDefinitions:  []models.AgentControlDefinition{{"platform": "KUBERNETES", "x-chart": "agent-control"}},
+	}
+
+	tests := []struct {
+		name         string
+		capabilities string
+		setting      string
+		expected     string
+		warnings     []string
+	}{
+		{
+			name:         "advertised features are sent",
+			capabilities: `{"payloadVersions": ["v1", "v2", "v3"], "features": ["localizedDescriptions", "agentControlExtensions"]}`,
+			setting:      PayloadVersionAuto,
+			expected:     `{"definitions":{"agentControl":[{"platform":"KUBERNETES","x-chart":"agent-control"}],"configuration":[{"description":"Agent configuration","localizedDescriptions":{"de":"Agentenkonfiguration"},"platform":"ALL"}]},"metadata":{"version":"1.2.3"},"schemaVersion":"v3"}`,
+		},
+		{
+			name:         "fields for features that aren't advertised are left out",
+			capabilities: `{"payloadVersions": ["v1", "v2", "v3"], "features": ["agentControlExtensions"]}`,
+			setting:      PayloadVersionAuto,
+			expected:     `{"definitions":{"agentControl":[{"platform":"KUBERNETES","x-chart":"agent-control"}],"configuration":[{"description":"Agent configuration","platform":"ALL"}]},"metadata":{"version":"1.2.3"},"schemaVersion":"v3"}`,
+			warnings:     []string{"doesn't advertise the localizedDescriptions payload feature"},
+		},
+		{
+			name:     "a pinned version has no features",
+			setting:  models.PayloadV3,
+			expected: `{"definitions":{"agentControl":[{"platform":"KUBERNETES"}],"configuration":[{"description":"Agent configuration","platform":"ALL"}]},"metadata":{"version":"1.2.3"},"schemaVersion":"v3"}`,
+			warnings: []string{"doesn't advertise the localizedDescriptions payload feature", "doesn't advertise the agentControlExtensions payload feature"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var submitted []byte
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/v1/capabilities" {
+					_, _ = w.Write([]byte(tt.capabilities))
+					return
+				}
+				submitted, _ = io.ReadAll(r.Body)
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+			getStdout, _ := testutil.CaptureOutput(t)
+
+			client := NewInstrumentationClient(server.URL, "test-token")
+			client.SetPayloadVersion(tt.setting)
+
+			// method under test
+			err := client.SendMetadata(context.Background(), "NRJavaAgent", "1.2.3", metadata)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(submitted))
+			stdout := getStdout()
+			for _, warning := range tt.warnings {
+				assert.Contains(t, stdout, warning)
+			}
+			if len(tt.warnings) == 0 {
+				assert.NotContains(t, stdout, "::warn::")
+			}
+		})
+	}
+}
+
+func TestSendMetadata_IdempotencyKey(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(idempotency.Header))
+		// The first attempt fails so the retry can be compared with it
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	testutil.CaptureOutput(t)
+
+	metadata := &models.AgentMetadata{Metadata: models.Metadata{"version": "1.2.3"}}
+	send := func(runID string) {
+		client := NewInstrumentationClient(server.URL, "test-token")
+		client.SetRunID(runID)
+		require.NoError(t, client.SendMetadata(context.Background(), "NRJavaAgent", "1.2.3", metadata))
+	}
+
+	// method under test
+	send("42")
+	send("42")
+	send("43")
+
+	require.Len(t, keys, 4)
+	assert.Regexp(t, `^[a-f0-9]{64}$`, keys[0])
+	assert.Equal(t, keys[0], keys[1], "retries should reuse the key")
+	assert.Equal(t, keys[0], keys[2], "re-run attempts of the workflow run should reuse the key")
+	assert.NotEqual(t, keys[0], keys[3], "a different workflow run should get a new key")
+}
+
+func TestSubmitMetadata_Response(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		expectedID string
+		errMsg     string
+	}{
+		{name: "returns the stored ID", body: `{"id": "12345", "agentType": "NRJavaAgent", "version": "1.2.3"}`, expectedID: "12345"},
+		{name: "accepts an empty body", body: ""},
+		{name: "wrong version echoed", body: `{"id": "12345", "version": "1.2.4"}`, errMsg: `service registered version "1.2.4" but "1.2.3" was submitted`},
+		{name: "malformed body", body: `{"id": 12345}`, errMsg: "invalid response body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestCount := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requestCount++
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			testutil.CaptureOutput(t)
+
+			client := NewInstrumentationClient(server.URL, "test-token")
+
+			// method under test
+			response, err := client.SubmitMetadata(context.Background(), "NRJavaAgent", "1.2.3", &models.AgentMetadata{
+				Metadata: models.Metadata{"version": "1.2.3"},
+			})
+
+			// A contradictory response is not retried
+			assert.Equal(t, 1, requestCount)
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "unexpected response")
+				assert.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedID, response.ID)
+		})
+	}
+}
+
+func TestSendMetadata_Compression(t *testing.T) {
+	metadata := &models.AgentMetadata{
+		Metadata:                 models.Metadata{"version": "1.2.3"},
+		ConfigurationDefinitions: []models.ConfigurationDefinition{{"schema": strings.Repeat("a", 4096)}},
+	}
+
+	tests := []struct {
+		name       string
+		threshold  int
+		compressed bool
+	}{
+		{name: "disabled", threshold: 0, compressed: false},
+		{name: "payload below threshold", threshold: 1 << 20, compressed: false},
+		{name: "payload above threshold", threshold: 1024, compressed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var reader io.Reader = r.Body
+				if tt.compressed {
+					assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+					gz, err := gzip.NewReader(r.Body)
+					require.NoError(t, err)
+					reader = gz
+				} else {
+					assert.Empty(t, r.Header.Get("Content-Encoding"))
+				}
+				body, err := io.ReadAll(reader)
+				require.NoError(t, err)
+				var received models.AgentMetadata
+				require.NoError(t, json.Unmarshal(body, &received))
+				assert.Equal(t, "1.2.3", received.Metadata["version"])
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+			testutil.CaptureOutput(t)
+
+			client := NewInstrumentationClient(server.URL, "test-token")
+			client.SetCompressionThreshold(tt.threshold)
+
+			// method under test
+			require.NoError(t, client.SendMetadata(context.Background(), "NRJavaAgent", "1.2.3", metadata))
+		})
+	}
+}
+
+func TestListAgentTypes(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/v1/agents", r.URL.Path)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"agentTypes": ["NRJavaAgent", "NRNodeAgent"]}`))
+	}))
+	defer server.Close()
+
+	client := NewInstrumentationClient(server.URL, "test-token")
+	ctx := context.Background()
+
+	agentTypes, err := client.ListAgentTypes(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"NRJavaAgent", "NRNodeAgent"}, agentTypes)
+
+	status = http.StatusNotFound
+	_, err = client.ListAgentTypes(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "agent type listing failed with status 404")
+}
+
+func TestListReleases(t *testing.T) {
+	var cursors []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "100", r.URL.Query().Get("pageSize"))
+		cursors = append(cursors, r.URL.Query().Get("cursor"))
+
+		switch r.URL.Path {
+		case "/v1/agents/NRJavaAgent/releases":
+			if r.URL.Query().Get("cursor") == "" {
+				_, _ = w.Write([]byte(`{"releases": [{"version": "1.2.3", "releaseDate": "2026-01-01", "eol": "2027-01-01"}], "nextCursor": "page-2"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"releases": [{"version": "1.2.4"}]}`))
+		case "/v1/agents/NRUnknownAgent/releases":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	client := NewInstrumentationClient(server.URL, "test-token")
+	ctx := context.Background()
+
+	// method under test
+	releases, err := client.ListReleases(ctx, "NRJavaAgent")
+
+	require.NoError(t, err)
+	assert.Equal(t, []models.ReleaseSummary{
+		{Version: "1.2.3", ReleaseDate: "2026-01-01", EOL: "2027-01-01"},
+		{Version: "1.2.4"},
+	}, releases)
+	assert.Equal(t, []string{"", "page-2"}, cursors)
+
+	releases, err = client.ListReleases(ctx, "NRUnknownAgent")
+	require.NoError(t, err)
+	assert.Empty(t, releases)
+
+	_, err = client.ListReleases(ctx, "NRDotNetAgent")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "release listing failed with status 401")
+}
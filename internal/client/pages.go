@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/retry"
+)
+
+// pageSize is how many entries list requests ask for per page
+const pageSize = 100
+
+// maxPages bounds how many pages a listing follows, in case a service keeps returning a cursor
+const maxPages = 1000
+
+// page is the pagination envelope of list responses
+// NextCursor is empty on the last page, and on services that return everything in one response
+type page struct {
+	NextCursor string `json:"nextCursor"`
+}
+
+// listPages fetches every page of a list endpoint, passing each body to decode, following nextCursor
+// Each page is retried on its own, so a failure on a late page doesn't repeat the earlier ones
+// With emptyOnNotFound a 404 is taken as an empty list, without calling decode
+func (c *InstrumentationClient) listPages(ctx context.Context, endpoint, operation string, emptyOnNotFound bool, decode func(body []byte) error) error {
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages == maxPages {
+			return fmt.Errorf("%s returned more than %d pages", strings.ToLower(operation), maxPages)
+		}
+		query := url.Values{"pageSize": {strconv.Itoa(pageSize)}}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		pageURL := endpoint + "?" + query.Encode()
+
+		retryConfig := retry.Config{
+			MaxAttempts: 3,
+			BaseDelay:   2 * time.Second,
+			Operation:   operation,
+		}
+		var next page
+		err := retry.Do(ctx, retryConfig, func() error {
+			body, status, err := c.get(ctx, pageURL, "")
+			if err != nil {
+				return err
+			}
+			if status == http.StatusNotFound && emptyOnNotFound && cursor == "" {
+				return nil
+			}
+			if status < 200 || status >= 300 {
+				err := fmt.Errorf("%s failed with status %d: %s", strings.ToLower(operation), status, truncate(string(body), 500))
+				if !IsRetryableStatus(status) {
+					return retry.NewNonRetryableError(err)
+				}
+				return err
+			}
+			if err := json.Unmarshal(body, &next); err != nil {
+				return retry.NewNonRetryableError(fmt.Errorf("failed to parse %s response: %w", strings.ToLower(operation), err))
+			}
+			if err := decode(body); err != nil {
+				return retry.NewNonRetryableError(fmt.Errorf("failed to parse %s response: %w", strings.ToLower(operation), err))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if next.NextCursor == "" {
+			return nil
+		}
+		logging.Debugf(ctx, "%s continues on page %d", operation, pages+2)
+		cursor = next.NextCursor
+	}
+}
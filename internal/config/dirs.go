@@ -3,6 +3,8 @@ package config
 import (
 	"path/filepath"
 	"strings"
+
+	"agent-metadata-action/internal/fileutil"
 )
 
 // GetRootFolderForAgentRepo loads the root folder where configuration info is stored
@@ -12,7 +14,7 @@ func GetRootFolderForAgentRepo() string {
 	if configDir == "" {
 		return ".fleetControl"
 	}
-	return strings.TrimSpace(configDir)
+	return fileutil.NormalizePath(strings.TrimSpace(configDir))
 }
 
 // GetConfigurationDefinitionsFilepath loads the root folder where configuration info is st
@@ -38,6 +40,21 @@ func GetAgentDefinitionFilepath() string {
 	return filepath.Join(GetRootFolderForAgentRepo(), "agentDefinition.yml")
 }
 
+// GetLintConfigFilepath returns the path of the schema lint configuration within the config directory
+func GetLintConfigFilepath() string {
+	return filepath.Join(GetRootFolderForAgentRepo(), "lint.yml")
+}
+
+// GetFixturesDirectory returns the directory of configuration samples within the config directory
+func GetFixturesDirectory() string {
+	return filepath.Join(GetRootFolderForAgentRepo(), "fixtures")
+}
+
+// GetPolicyFilepath returns the path of the release policy within the config directory
+func GetPolicyFilepath() string {
+	return filepath.Join(GetRootFolderForAgentRepo(), "policy.yml")
+}
+
 func GetReleaseNotesDirectory() string {
 	return "src/content/docs/release-notes"
 }
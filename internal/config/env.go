@@ -2,82 +2,613 @@ package config
 
 import (
 	"os"
+	"strings"
+	"time"
+
+	"agent-metadata-action/internal/inputs"
 )
 
 // GetWorkspace loads the GH workspace path from environment variables
 func GetWorkspace() string {
-	return os.Getenv("GITHUB_WORKSPACE")
+	return inputs.GetString("GITHUB_WORKSPACE")
 }
 
 // GetRepo loads the GH repo from environment variables
 func GetRepo() string {
-	return os.Getenv("GITHUB_REPOSITORY")
+	return inputs.GetString("GITHUB_REPOSITORY")
+}
+
+// GetOutputPath loads the path of the file GitHub Actions reads step outputs from
+func GetOutputPath() string {
+	return inputs.GetString("GITHUB_OUTPUT")
+}
+
+// GetStepSummaryPath loads the path of the file GitHub Actions renders as the job summary
+func GetStepSummaryPath() string {
+	return inputs.GetString("GITHUB_STEP_SUMMARY")
+}
+
+// GetRunnerTemp loads the temporary directory of the runner, which GitHub Actions empties at the end of each job
+func GetRunnerTemp() string {
+	return inputs.GetString("RUNNER_TEMP")
+}
+
+// GetActor loads the GitHub user that triggered the workflow from environment variables
+func GetActor() string {
+	return inputs.GetString("GITHUB_ACTOR")
+}
+
+// GetRunID loads the GitHub Actions workflow run ID from environment variables
+func GetRunID() string {
+	return inputs.GetString("GITHUB_RUN_ID")
+}
+
+// GetRunAttempt loads the attempt number of the workflow run, starting at 1 and incremented by re-runs
+func GetRunAttempt() string {
+	return inputs.GetString("GITHUB_RUN_ATTEMPT")
+}
+
+// GetJob loads the ID of the workflow job running the action
+func GetJob() string {
+	return inputs.GetString("GITHUB_JOB")
+}
+
+// GetWorkflowName loads the name of the running workflow, or its file path if it has no name
+func GetWorkflowName() string {
+	return inputs.GetString("GITHUB_WORKFLOW")
+}
+
+// GetRef loads the fully-formed ref that triggered the workflow, e.g. refs/tags/v1.2.3
+func GetRef() string {
+	return inputs.GetString("GITHUB_REF")
+}
+
+// GetWorkflowFile loads the repository-relative path of the running workflow file from GITHUB_WORKFLOW_REF
+// (owner/repo/.github/workflows/release.yml@refs/heads/main), or an empty string if it is unknown
+func GetWorkflowFile() string {
+	ref, _, _ := strings.Cut(inputs.GetString("GITHUB_WORKFLOW_REF"), "@")
+	_, path, found := strings.Cut(ref, "/.github/")
+	if !found {
+		return ""
+	}
+	return ".github/" + path
 }
 
 // GetAgentType loads the agent type from environment variables
 func GetAgentType() string {
-	return os.Getenv("INPUT_AGENT_TYPE")
+	return inputs.GetString("agent-type")
 }
 
 // GetVersion loads the version from environment variables
 func GetVersion() string {
-	return os.Getenv("INPUT_VERSION")
+	return inputs.GetString("version")
 }
 
 // GetEventPath loads the GitHub event path from environment variables
 func GetEventPath() string {
-	return os.Getenv("GITHUB_EVENT_PATH")
+	return inputs.GetString("GITHUB_EVENT_PATH")
+}
+
+// GetEventName loads the name of the event that triggered the workflow from environment variables
+func GetEventName() string {
+	return inputs.GetString("GITHUB_EVENT_NAME")
+}
+
+// Placeholders are the values of the ${VERSION} and ${AGENT_TYPE} placeholders in configuration definitions values,
+// those of the release whose definitions are read
+type Placeholders struct {
+	Version   string
+	AgentType string
+}
+
+// GetPlaceholders loads the placeholder values of the release the version and agent-type inputs name
+func GetPlaceholders() Placeholders {
+	return Placeholders{Version: GetVersion(), AgentType: GetAgentType()}
+}
+
+// Expand replaces ${VERSION} and ${AGENT_TYPE} in a configuration definitions value; other text, including unknown
+// placeholders, is left unchanged
+func (p Placeholders) Expand(value string) string {
+	if !strings.Contains(value, "${") {
+		return value
+	}
+	return strings.NewReplacer("${VERSION}", p.Version, "${AGENT_TYPE}", p.AgentType).Replace(value)
+}
+
+// GetMDXFiles loads the explicit list of MDX files to process from environment variables
+// Used for manual (workflow_dispatch) runs instead of deriving changes from the event diff
+func GetMDXFiles() string {
+	return inputs.GetString("mdx-files")
+}
+
+// GetReleaseNotePath loads the explicit release note file or directory to process from environment variables
+// Used for manual (workflow_dispatch) runs instead of deriving changes from the event diff
+func GetReleaseNotePath() string {
+	return inputs.GetString("release-note-path")
+}
+
+// GetMode loads the run mode from environment variables
+// An empty mode submits metadata for the triggering change; the other modes are listed in the README
+func GetMode() string {
+	return strings.ToLower(inputs.GetString("mode"))
+}
+
+// GetDryRun reports whether the dry-run input is enabled
+// In dry-run mode metadata is resolved and logged (or diffed when reconciling) without submitting or uploading anything
+func GetDryRun() bool {
+	return inputs.GetBool("dry-run")
+}
+
+// GetBackfillVersions loads the versions backfill mode submits: a comma or newline separated list of versions, or a
+// range of tags such as v1.0.0..v2.0.0
+func GetBackfillVersions() string {
+	return inputs.GetString("backfill-versions")
+}
+
+// GetBackfillConcurrency loads how many versions backfill mode reads and submits at once
+func GetBackfillConcurrency() (int, error) {
+	return inputs.GetInt("backfill-concurrency")
+}
+
+// GetReconcileReleaseNotes reports whether reconciliation should include every historical release note
+func GetReconcileReleaseNotes() bool {
+	return inputs.GetBool("reconcile-release-notes")
+}
+
+// GetExportDirectory loads the directory (relative to workspace) to export resolved metadata JSON files to
+// Returns an empty string if exporting is disabled
+func GetExportDirectory() string {
+	return inputs.GetString("export-directory")
+}
+
+// GetSchemaDocsDirectory loads the directory (relative to workspace) to write the Markdown documentation of the
+// configuration schemas to
+// Returns an empty string if no documentation is generated
+func GetSchemaDocsDirectory() string {
+	return inputs.GetString("schema-docs-directory")
+}
+
+// GetSubmission loads how agent metadata is submitted: full, or incremental to only send the definitions that
+// changed since the version was last submitted
+func GetSubmission() string {
+	return inputs.GetString("submission")
+}
+
+// GetIncrementalBaseline loads what incremental submissions are compared against: the metadata stored by the service,
+// or the snapshot committed in the export directory
+func GetIncrementalBaseline() string {
+	return inputs.GetString("incremental-baseline")
+}
+
+// GetResultsFile loads the path (relative to workspace) to write the run results JSON file to
+// Returns an empty string if the results file is disabled
+func GetResultsFile() string {
+	return inputs.GetString("results-file")
+}
+
+// GetPhases loads the phases of an agent release the run performs, separated by commas or newlines
+func GetPhases() []string {
+	var phases []string
+	for _, field := range strings.FieldsFunc(inputs.GetString("phases"), func(r rune) bool { return r == ',' || r == '\n' }) {
+		if phase := strings.TrimSpace(field); phase != "" {
+			phases = append(phases, phase)
+		}
+	}
+	return phases
+}
+
+// GetPhaseResults loads the path (relative to workspace) to the results file of the run that performed the upload
+// phase a sign phase continues
+func GetPhaseResults() string {
+	return inputs.GetString("phase-results")
+}
+
+// GetJournalFile loads the path (relative to workspace) to journal the completed operations of the run in, or to
+// read the journal of a failed run from in rollback mode
+// Returns an empty string if no journal is kept
+func GetJournalFile() string {
+	return inputs.GetString("journal-file")
+}
+
+// GetResumeResults loads the path (relative to workspace) to the results file of an earlier attempt of the run,
+// whose completed work a re-run skips
+// Returns an empty string if the run doesn't resume
+func GetResumeResults() string {
+	return inputs.GetString("resume-results")
+}
+
+// GetSARIFFile loads the path (relative to workspace) to write validation and lint findings to as SARIF
+// Returns an empty string if no SARIF file is written
+func GetSARIFFile() string {
+	return inputs.GetString("sarif-file")
+}
+
+// GetPinsDirectory loads the directory (relative to workspace) to write the digest pin file of agent releases to
+// Returns an empty string if no pin file is written
+func GetPinsDirectory() string {
+	return strings.TrimSpace(inputs.GetString("pins-directory"))
+}
+
+// GetDownstreamRepository loads the owner/repo repository to propose the generated pin and export files to with a
+// pull request
+// Returns an empty string if no pull request is opened
+func GetDownstreamRepository() string {
+	return strings.TrimSpace(inputs.GetString("downstream-repository"))
+}
+
+// GetDownstreamToken loads the token used to open the downstream pull request, falling back to the GitHub token
+func GetDownstreamToken() string {
+	if token := inputs.GetString("downstream-token"); token != "" {
+		return token
+	}
+	return GetGitHubToken()
+}
+
+// GetPayloadVersion loads the instrumentation service payload version to send (v1, v2 or auto)
+func GetPayloadVersion() string {
+	return strings.ToLower(inputs.GetString("payload-version"))
+}
+
+// GetCompressionThreshold loads the payload size in bytes above which metadata submissions are gzip-compressed
+// Returns 0 (compression disabled) if the input is unset
+func GetCompressionThreshold() (int, error) {
+	return inputs.GetInt("compression-threshold")
+}
+
+// GetMaxPayloadSize loads the largest metadata request body in bytes the instrumentation service accepts
+// Returns 0 (no limit, submissions are never split) if the input is unset
+func GetMaxPayloadSize() (int, error) {
+	return inputs.GetInt("max-payload-size")
+}
+
+// GetEmptyFieldPolicy loads how empty metadata fields are sent (send-empty-as-null, omit or empty-array)
+func GetEmptyFieldPolicy() string {
+	return strings.ToLower(inputs.GetString("empty-field-policy"))
+}
+
+// GetMaxTextLength loads the longest free-text metadata value kept, in characters; longer values are truncated
+// Returns 0 (no limit) if the input is set to 0
+func GetMaxTextLength() (int, error) {
+	return inputs.GetInt("max-text-length")
+}
+
+// GetRateLimit loads how many requests per second are sent to the instrumentation and signing services
+// Returns 0 (no limit) if the input is unset
+func GetRateLimit() (int, error) {
+	return inputs.GetInt("rate-limit")
+}
+
+// GetStrictContract reports whether requests to New Relic services are validated against their OpenAPI documents
+func GetStrictContract() bool {
+	return inputs.GetBool("strict-contract")
+}
+
+// GetStrictFixtures reports whether releases whose schemas reject a committed configuration sample are blocked
+// rather than only warned about
+func GetStrictFixtures() bool {
+	return inputs.GetBool("strict-fixtures")
+}
+
+// GetDeriveChangelog reports whether configuration definitions without a changelog field get one summarizing how
+// their schema changed since the previous release
+func GetDeriveChangelog() bool {
+	return inputs.GetBool("derive-changelog")
+}
+
+// GetStrictPolicy reports whether releases that violate a policy.yml rule are blocked rather than only warned about
+func GetStrictPolicy() bool {
+	return inputs.GetBool("strict-policy")
+}
+
+// GetRegoPolicies loads the Rego policy sources the assembled metadata and upload plan are evaluated against:
+// comma or newline separated paths relative to the workspace, or https URLs
+func GetRegoPolicies() string {
+	return inputs.GetString("rego-policies")
+}
+
+// GetSHA loads the commit SHA that triggered the workflow from environment variables
+func GetSHA() string {
+	return inputs.GetString("GITHUB_SHA")
+}
+
+// GetDecryptionKey loads the age key used to decrypt encrypted schema and content files
+// Returns an empty string if encrypted files aren't supported
+func GetDecryptionKey() string {
+	return inputs.GetString("decryption-key")
+}
+
+// GetGitHubToken loads the GitHub token used for GitHub API calls from environment variables
+func GetGitHubToken() string {
+	return inputs.GetString("github-token")
+}
+
+// GetGitHubAPIURL loads the GitHub API base URL from environment variables
+// Returns the public GitHub API URL if not set
+func GetGitHubAPIURL() string {
+	return inputs.GetString("GITHUB_API_URL")
+}
+
+// GetRepositoryURL loads the web URL of the GH repo, e.g. https://github.com/newrelic/newrelic-java-agent, or an
+// empty string if the repo is unknown
+func GetRepositoryURL() string {
+	repo := GetRepo()
+	if repo == "" {
+		return ""
+	}
+	return strings.TrimSuffix(inputs.GetString("GITHUB_SERVER_URL"), "/") + "/" + repo
 }
 
 // GetToken loads the newrelic token from the environment variables
 func GetToken() string {
-	return os.Getenv("NEWRELIC_TOKEN")
+	return inputs.GetString("newrelic-token")
 }
 
 // GetOCIRegistry loads the OCI registry from environment variables
 func GetOCIRegistry() string {
-	return os.Getenv("INPUT_OCI_REGISTRY")
+	return inputs.GetString("oci-registry")
 }
 
 // GetOCIUsername loads the OCI username from environment variables
 func GetOCIUsername() string {
-	return os.Getenv("INPUT_OCI_USERNAME")
+	return inputs.GetString("oci-username")
 }
 
 // GetOCIPassword loads the OCI password from environment variables
 func GetOCIPassword() string {
-	return os.Getenv("INPUT_OCI_PASSWORD")
+	return inputs.GetString("oci-password")
+}
+
+// GetOCIPlainHTTP returns whether the OCI registry is reached over plain HTTP
+func GetOCIPlainHTTP() bool {
+	return inputs.GetBool("oci-plain-http")
+}
+
+// GetOCIInsecureSkipTLSVerify returns whether the TLS certificate of the OCI registry is left unverified
+func GetOCIInsecureSkipTLSVerify() bool {
+	return inputs.GetBool("oci-insecure-skip-tls-verify")
+}
+
+// GetOCIMaxBlobSize loads the largest blob in bytes the OCI registry accepts
+// Returns 0 (no limit, artifacts are never split) if the input is unset
+func GetOCIMaxBlobSize() (int, error) {
+	return inputs.GetInt("oci-max-blob-size")
+}
+
+// GetScanCommand loads the scanner command run on each binary before upload, e.g. clamscan
+func GetScanCommand() string {
+	return inputs.GetString("scan-command")
+}
+
+// GetScanURL loads the URL of the scanning service each binary is posted to before upload
+func GetScanURL() string {
+	return inputs.GetString("scan-url")
+}
+
+// GetScanToken loads the Bearer token for the scanning service
+func GetScanToken() string {
+	return inputs.GetString("scan-token")
+}
+
+// GetRequiredLegalFiles loads the legal files every binary archive must contain, separated by commas or newlines
+// Returns nil (no check) if the input is unset
+func GetRequiredLegalFiles() []string {
+	var files []string
+	for _, field := range strings.FieldsFunc(inputs.GetString("required-legal-files"), func(r rune) bool { return r == ',' || r == '\n' }) {
+		if file := strings.TrimSpace(field); file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// GetContentManifests returns whether the file list of each binary archive is attached as a referrer of its manifest
+func GetContentManifests() bool {
+	return inputs.GetBool("content-manifests")
+}
+
+// GetNormalizeArchives returns whether binary archives are re-packaged before upload so their digests are reproducible
+func GetNormalizeArchives() bool {
+	return inputs.GetBool("normalize-archives")
+}
+
+// GetNotarizationStrict returns whether darwin binaries checked for notarization are blocked unless notarized
+func GetNotarizationStrict() bool {
+	return inputs.GetBool("notarization-strict")
+}
+
+// GetOCIReleaseNotes loads the path of the release notes attached to the manifest index, an MDX release note or a
+// changelog; empty means none are attached
+func GetOCIReleaseNotes() string {
+	return strings.TrimSpace(inputs.GetString("oci-release-notes"))
+}
+
+// GetUploadPlanFile loads the path, relative to the workspace, the upload plan is written to; empty means none is
+// written
+func GetUploadPlanFile() string {
+	return strings.TrimSpace(inputs.GetString("upload-plan-file"))
+}
+
+// GetOCILayout loads the OCI image layout directory, relative to the workspace, uploads are written to; empty means
+// none is written
+func GetOCILayout() string {
+	return strings.TrimSpace(inputs.GetString("oci-layout"))
+}
+
+// GetHandoffFile loads the path, relative to the workspace, mode: collect writes the uploaded artifacts to
+func GetHandoffFile() string {
+	return strings.TrimSpace(inputs.GetString("handoff-file"))
+}
+
+// GetHandoffDirectory loads the directory, relative to the workspace, mode: assemble reads the handoff files from
+func GetHandoffDirectory() string {
+	return strings.TrimSpace(inputs.GetString("handoff-directory"))
+}
+
+// GetOCIAnnotations loads the vendor annotations added to every layer, manifest and manifest index pushed, a JSON
+// object of annotation keys to values
+// Returns nil (no vendor annotations) if the input is unset
+func GetOCIAnnotations() (map[string]string, error) {
+	var annotations map[string]string
+	if err := inputs.GetJSON("oci-annotations", &annotations); err != nil {
+		return nil, err
+	}
+	return annotations, nil
+}
+
+// GetOCICreatedSource loads where the creation time stamped on manifests and the index comes from: now, or the
+// commit for reproducible digests
+func GetOCICreatedSource() string {
+	return strings.ToLower(strings.TrimSpace(inputs.GetString("oci-created-source")))
+}
+
+// GetOCIPendingTag returns whether the manifest index is pushed under a pending tag for later promotion
+func GetOCIPendingTag() bool {
+	return inputs.GetBool("oci-pending-tag")
+}
+
+// GetPromoteLatest returns whether promote mode also tags the promoted index as latest
+func GetPromoteLatest() bool {
+	return inputs.GetBool("promote-latest")
+}
+
+// GetCopySource loads the registry copy mode copies from
+func GetCopySource() string {
+	return inputs.GetString("copy-source")
+}
+
+// GetCopySourceUsername loads the username for the registry copy mode copies from
+func GetCopySourceUsername() string {
+	return inputs.GetString("copy-source-username")
+}
+
+// GetCopySourcePassword loads the password or token for the registry copy mode copies from
+func GetCopySourcePassword() string {
+	return inputs.GetString("copy-source-password")
+}
+
+// GetCopyDigest loads the digest of the manifest index copy mode copies, if it isn't looked up by version
+func GetCopyDigest() string {
+	return strings.TrimSpace(inputs.GetString("copy-digest"))
+}
+
+// GetSigningRequired reports whether a manifest index that can't be signed fails the run
+// When false the release completes unsigned, with a warning and a resign task
+func GetSigningRequired() bool {
+	return inputs.GetBool("signing-required")
+}
+
+// GetResignDigest loads the digest of the manifest index resign mode signs, in place of the version tag
+func GetResignDigest() string {
+	return inputs.GetString("resign-digest")
+}
+
+// GetVerifyResults loads the path (relative to workspace) to the results file of the release run verify mode
+// compares the release against
+func GetVerifyResults() string {
+	return inputs.GetString("verify-results")
+}
+
+// GetInventoryFile loads the path (relative to workspace) inventory mode writes the agent inventory to
+func GetInventoryFile() string {
+	return strings.TrimSpace(inputs.GetString("inventory-file"))
+}
+
+// GetInventoryAgentTypes loads the agent types inventory mode lists, separated by commas or newlines
+// Returns nil (every registered agent type) if the input is unset
+func GetInventoryAgentTypes() []string {
+	var agentTypes []string
+	for _, field := range strings.FieldsFunc(inputs.GetString("inventory-agent-types"), func(r rune) bool { return r == ',' || r == '\n' }) {
+		if agentType := strings.TrimSpace(field); agentType != "" {
+			agentTypes = append(agentTypes, agentType)
+		}
+	}
+	return agentTypes
+}
+
+// GetEOLBefore loads the date (YYYY-MM-DD) inventory mode only lists versions with an earlier EOL date before
+func GetEOLBefore() string {
+	return strings.TrimSpace(inputs.GetString("eol-before"))
+}
+
+// GetRetentionDays loads how many days cleanup mode keeps prerelease manifests for
+func GetRetentionDays() (int, error) {
+	return inputs.GetInt("retention-days")
+}
+
+// GetRetryBudget loads the most time a run spends retrying failed requests, across every service
+// Returns 0 (no budget, each request retries independently) if the input is set to 0
+func GetRetryBudget() (time.Duration, error) {
+	return inputs.GetDuration("retry-budget")
+}
+
+// GetHeartbeatInterval loads how often the run notices which phase it is in while it goes on
+// Returns 0 (no heartbeat) if the input is set to 0
+func GetHeartbeatInterval() (time.Duration, error) {
+	return inputs.GetDuration("heartbeat-interval")
+}
+
+// GetReleaseLockTTL loads how long the lock an agent release takes on its version lasts unless the run renews it
+// Returns 0 (no lock) if the input is set to 0
+func GetReleaseLockTTL() (time.Duration, error) {
+	return inputs.GetDuration("release-lock-ttl")
+}
+
+// GetPayloadMemoryLimit loads the MiB of encoded schemas and content a run holds in memory before it warns
+// Returns 0 (no warnings) if the input is set to 0
+func GetPayloadMemoryLimit() (int, error) {
+	return inputs.GetInt("payload-memory-limit")
+}
+
+// GetMetricsAddress loads the loopback address, such as 127.0.0.1:9464, the run serves Prometheus metrics on
+func GetMetricsAddress() string {
+	return strings.TrimSpace(inputs.GetString("metrics-address"))
+}
+
+// GetMetricsFile loads the path, absolute or relative to workspace, the run keeps Prometheus metrics up to date in
+func GetMetricsFile() string {
+	return strings.TrimSpace(inputs.GetString("metrics-file"))
 }
 
 // GetBinaries loads the binaries JSON from environment variables
 func GetBinaries() string {
-	return os.Getenv("INPUT_BINARIES")
+	return inputs.GetString("binaries")
 }
 
 // GetTags loads the tags JSON from environment variables
 func GetTags() string {
-	return os.Getenv("INPUT_TAGS")
+	return inputs.GetString("tags")
 }
 
 // GetNRAgentLicenseKey gets the license key to use the go agent and monitor this app
 func GetNRAgentLicenseKey() string {
-	return os.Getenv("APM_CONTROL_NR_LICENSE_KEY")
+	return inputs.GetString("apm-control-nr-license-key")
 }
 
 // GetConfigDirectory loads the config directory from environment variables
 // Returns the directory where configuration files are located (relative to workspace)
 func GetConfigDirectory() string {
-	return os.Getenv("INPUT_CONFIG_DIRECTORY")
+	return inputs.GetString("config-directory")
 }
 
 // GetMonitoringType loads the monitoring type from environment variables
 func GetMonitoringType() string {
-	return os.Getenv("INPUT_MONITORING_TYPE")
+	return inputs.GetString("monitoring-type")
 }
 
 // GetDisplayName loads the display name from environment variables
 func GetDisplayName() string {
-	return os.Getenv("INPUT_DISPLAY_NAME")
+	return inputs.GetString("display-name")
+}
+
+// GetReleaseDate loads the release date (YYYY-MM-DD) of an agent release, sent as the releaseDate metadata field
+func GetReleaseDate() string {
+	return inputs.GetString("release-date")
+}
+
+// GetReleaseDateWindow loads how many days a releaseDate may differ from the version's git tag date before a warning
+// Returns 0 (no check) if the input is set to 0
+func GetReleaseDateWindow() (int, error) {
+	return inputs.GetInt("release-date-window")
 }
 
 // SetNRAgentHost sets the host to use for the go agent that will be used to monitor this app
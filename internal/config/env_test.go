@@ -0,0 +1,68 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaceholders_Expand(t *testing.T) {
+	placeholders := Placeholders{Version: "1.2.3", AgentType: "NRJavaAgent"}
+
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{"no placeholders", "./schemas/config.json", "./schemas/config.json"},
+		{"version", "./schemas/${VERSION}/config.json", "./schemas/1.2.3/config.json"},
+		{"agent type and version", "${AGENT_TYPE} ${VERSION} settings", "NRJavaAgent 1.2.3 settings"},
+		{"repeated", "${VERSION}-${VERSION}", "1.2.3-1.2.3"},
+		{"unknown placeholder unchanged", "${HOME}/config", "${HOME}/config"},
+		{"unbraced unchanged", "$VERSION", "$VERSION"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, placeholders.Expand(tt.value))
+		})
+	}
+}
+
+func TestGetPlaceholders(t *testing.T) {
+	t.Setenv("INPUT_VERSION", "1.2.3")
+	t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+
+	assert.Equal(t, Placeholders{Version: "1.2.3", AgentType: "NRJavaAgent"}, GetPlaceholders())
+}
+
+func TestGetRepositoryURL(t *testing.T) {
+	t.Setenv("GITHUB_REPOSITORY", "")
+	assert.Empty(t, GetRepositoryURL())
+
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/newrelic-java-agent")
+	assert.Equal(t, "https://github.com/newrelic/newrelic-java-agent", GetRepositoryURL())
+
+	t.Setenv("GITHUB_SERVER_URL", "https://github.example.com/")
+	assert.Equal(t, "https://github.example.com/newrelic/newrelic-java-agent", GetRepositoryURL())
+}
+
+func TestGetWorkflowFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		ref      string
+		expected string
+	}{
+		{"workflow ref", "newrelic/java-agent/.github/workflows/release.yml@refs/heads/main", ".github/workflows/release.yml"},
+		{"without ref", "newrelic/java-agent/.github/workflows/release.yml", ".github/workflows/release.yml"},
+		{"unset", "", ""},
+		{"unexpected format", "release.yml@refs/heads/main", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GITHUB_WORKFLOW_REF", tt.ref)
+			assert.Equal(t, tt.expected, GetWorkflowFile())
+		})
+	}
+}
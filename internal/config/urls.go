@@ -1,16 +1,29 @@
 package config
 
-import "os"
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"agent-metadata-action/internal/inputs"
+)
 
 // Service URL configuration - hardcoded for security
 const (
-	// MetadataURL is the instrumentation metadata service endpoint
+	// MetadataURL is the instrumentation metadata service endpoint (US region)
 	MetadataURL = "https://instrumentation-metadata.service.newrelic.com"
 
-	// SigningURL is the OCI artifact signing service endpoint
+	// SigningURL is the OCI artifact signing service endpoint (US region)
 	SigningURL = "https://oci-signer.service.newrelic.com"
 )
 
+// New Relic data center regions selected with the region input
+const (
+	RegionUS  = "us"
+	RegionEU  = "eu"
+	RegionGov = "gov" // FedRAMP
+)
+
 // ServiceURLs holds all service endpoint URLs
 type ServiceURLs struct {
 	MetadataURL    string
@@ -18,31 +31,153 @@ type ServiceURLs struct {
 	SigningURL     string
 }
 
-// GetMetadataURL returns the metadata service URL.
+// regionURLs holds the service endpoints of each region
+var regionURLs = map[string]ServiceURLs{
+	RegionUS: {
+		MetadataURL: MetadataURL,
+		SigningURL:  SigningURL,
+	},
+	RegionEU: {
+		MetadataURL: "https://instrumentation-metadata.service.eu.newrelic.com",
+		SigningURL:  "https://oci-signer.service.eu.newrelic.com",
+	},
+	RegionGov: {
+		MetadataURL: "https://gov-instrumentation-metadata.service.newrelic.com",
+		SigningURL:  "https://gov-oci-signer.service.newrelic.com",
+	},
+}
+
+// Regions returns the supported regions, sorted
+func Regions() []string {
+	regions := make([]string, 0, len(regionURLs))
+	for region := range regionURLs {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+// GetRegion returns the region input, lowercased (us when unset)
+func GetRegion() string {
+	return strings.ToLower(inputs.GetString("region"))
+}
+
+// RegionURLs returns the service endpoints of a region
+func RegionURLs(region string) (ServiceURLs, error) {
+	urls, ok := regionURLs[region]
+	if !ok {
+		return ServiceURLs{}, fmt.Errorf("invalid region %q: must be one of %v", region, Regions())
+	}
+	return urls, nil
+}
+
+// Service environments selected with the environment input
+const (
+	EnvironmentProduction = "production"
+	EnvironmentStaging    = "staging"
+)
+
+// stagingURLs holds the staging service endpoints, which only exist in the US region
+var stagingURLs = ServiceURLs{
+	MetadataURL: "https://staging-instrumentation-metadata.service.newrelic.com",
+	SigningURL:  "https://staging-oci-signer.service.newrelic.com",
+}
+
+// stagingRepositories are the repositories allowed to submit to staging
+// Like the URL overrides, this keeps other repositories' tokens away from non-production services
+var stagingRepositories = []string{
+	"newrelic/agent-metadata-action",
+	"newrelic/docs-website",
+	"newrelic/infrastructure-agent",
+	"newrelic/newrelic-dotnet-agent",
+	"newrelic/newrelic-java-agent",
+	"newrelic/newrelic-python-agent",
+	"newrelic/newrelic-ruby-agent",
+	"newrelic/node-newrelic",
+	"newrelic/nrdot-collector-releases",
+}
+
+// GetEnvironment returns the environment input, lowercased (production when unset)
+func GetEnvironment() string {
+	return strings.ToLower(inputs.GetString("environment"))
+}
+
+// ValidateEnvironment checks that the configured environment exists and may be used by this repository
+// Staging is limited to the US region and to repositories in stagingRepositories
+func ValidateEnvironment() error {
+	switch environment := GetEnvironment(); environment {
+	case EnvironmentProduction:
+		return nil
+	case EnvironmentStaging:
+		if region := GetRegion(); region != RegionUS {
+			return fmt.Errorf("environment %s is only available in region %s, not %s", EnvironmentStaging, RegionUS, region)
+		}
+		repo := GetRepo()
+		for _, allowed := range stagingRepositories {
+			if strings.EqualFold(repo, allowed) {
+				return nil
+			}
+		}
+		return fmt.Errorf("environment %s is not enabled for repository %q", EnvironmentStaging, repo)
+	default:
+		return fmt.Errorf("invalid environment %q: must be %s or %s", environment, EnvironmentProduction, EnvironmentStaging)
+	}
+}
+
+// serviceURLs returns the endpoints of the configured environment and region
+// Invalid environments and regions are rejected when the action's environment is validated,
+// so production US endpoints are only a safe default here
+func serviceURLs() ServiceURLs {
+	if GetEnvironment() == EnvironmentStaging && ValidateEnvironment() == nil {
+		return stagingURLs
+	}
+	urls, err := RegionURLs(GetRegion())
+	if err != nil {
+		return regionURLs[RegionUS]
+	}
+	return urls
+}
+
+// localEmulation is set when the action is run on a developer machine with a fabricated GitHub environment
+var localEmulation bool
+
+// EnableLocalEmulation allows service URL overrides from any repository so a local run can target a mock server
+// Refused inside GitHub Actions, where the override restriction protects the workflow's token
+func EnableLocalEmulation() error {
+	if inputs.GetBool("GITHUB_ACTIONS") {
+		return fmt.Errorf("local emulation cannot be used inside GitHub Actions")
+	}
+	localEmulation = true
+	return nil
+}
+
+// GetMetadataURL returns the metadata service URL of the configured environment and region.
 // Can be overridden with METADATA_SERVICE_URL environment variable ONLY when
-// GITHUB_REPOSITORY matches the action's own repository (for testing).
+// GITHUB_REPOSITORY matches the action's own repository (for testing), or during local emulation.
 // This prevents users from redirecting requests to steal tokens.
 func GetMetadataURL() string {
 	// Only allow override in the action's own repository for testing
-	if url := os.Getenv("METADATA_SERVICE_URL"); url != "" {
+	if url := inputs.GetString("METADATA_SERVICE_URL"); url != "" {
 		repo := GetRepo()
-		if repo == "newrelic/agent-metadata-action" {
+		if repo == "newrelic/agent-metadata-action" || localEmulation {
 			return url
 		}
 		// Silently ignore override attempts from other repositories
 		// This prevents token theft attacks
 	}
-	return MetadataURL
+	return serviceURLs().MetadataURL
 }
 
+// GetSigningURL returns the signing service URL of the configured environment and region.
+// Overridden with SIGNING_SERVICE_URL under the same restrictions as GetMetadataURL.
 func GetSigningURL() string {
-	if url := os.Getenv("SIGNING_SERVICE_URL"); url != "" {
+	if url := inputs.GetString("SIGNING_SERVICE_URL"); url != "" {
 		repo := GetRepo()
-		if repo == "newrelic/agent-metadata-action" {
+		if repo == "newrelic/agent-metadata-action" || localEmulation {
 			return url
 		}
 		// Silently ignore override attempts from other repositories
 		// This prevents token theft attacks
 	}
-	return SigningURL
+	return serviceURLs().SigningURL
 }
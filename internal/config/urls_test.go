@@ -0,0 +1,95 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceURLs_Region(t *testing.T) {
+	tests := []struct {
+		region      string
+		metadataURL string
+		signingURL  string
+	}{
+		{region: "", metadataURL: MetadataURL, signingURL: SigningURL},
+		{region: "us", metadataURL: MetadataURL, signingURL: SigningURL},
+		{region: "EU", metadataURL: "https://instrumentation-metadata.service.eu.newrelic.com", signingURL: "https://oci-signer.service.eu.newrelic.com"},
+		{region: "gov", metadataURL: "https://gov-instrumentation-metadata.service.newrelic.com", signingURL: "https://gov-oci-signer.service.newrelic.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.region, func(t *testing.T) {
+			t.Setenv("INPUT_REGION", tt.region)
+			t.Setenv("INPUT_ENVIRONMENT", "")
+			t.Setenv("METADATA_SERVICE_URL", "")
+			t.Setenv("SIGNING_SERVICE_URL", "")
+
+			// method under test
+			assert.Equal(t, tt.metadataURL, GetMetadataURL())
+			assert.Equal(t, tt.signingURL, GetSigningURL())
+		})
+	}
+}
+
+func TestServiceURLs_OverrideRestrictedToActionRepository(t *testing.T) {
+	t.Setenv("INPUT_REGION", "eu")
+	t.Setenv("METADATA_SERVICE_URL", "http://localhost:8080")
+	t.Setenv("SIGNING_SERVICE_URL", "http://localhost:8081")
+
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/agent-metadata-action")
+	assert.Equal(t, "http://localhost:8080", GetMetadataURL())
+	assert.Equal(t, "http://localhost:8081", GetSigningURL())
+
+	t.Setenv("GITHUB_REPOSITORY", "attacker/repo")
+	assert.Equal(t, "https://instrumentation-metadata.service.eu.newrelic.com", GetMetadataURL())
+	assert.Equal(t, "https://oci-signer.service.eu.newrelic.com", GetSigningURL())
+}
+
+func TestRegionURLs_Invalid(t *testing.T) {
+	_, err := RegionURLs("apac")
+	require.Error(t, err)
+	assert.EqualError(t, err, `invalid region "apac": must be one of [eu gov us]`)
+}
+
+func TestServiceURLs_Staging(t *testing.T) {
+	tests := []struct {
+		name        string
+		repo        string
+		region      string
+		environment string
+		expectedErr string
+		metadataURL string
+	}{
+		{name: "production", repo: "someone/agent", environment: "production", metadataURL: MetadataURL},
+		{name: "staging from allowlisted repository", repo: "newrelic/newrelic-java-agent", environment: "staging", metadataURL: stagingURLs.MetadataURL},
+		{name: "allowlist ignores case", repo: "NewRelic/NewRelic-Java-Agent", environment: "STAGING", metadataURL: stagingURLs.MetadataURL},
+		{name: "staging from other repository", repo: "someone/agent", environment: "staging",
+			expectedErr: `environment staging is not enabled for repository "someone/agent"`, metadataURL: MetadataURL},
+		{name: "staging outside US", repo: "newrelic/newrelic-java-agent", region: "eu", environment: "staging",
+			expectedErr: "environment staging is only available in region us, not eu", metadataURL: "https://instrumentation-metadata.service.eu.newrelic.com"},
+		{name: "unknown environment", repo: "newrelic/newrelic-java-agent", environment: "dev",
+			expectedErr: `invalid environment "dev": must be production or staging`, metadataURL: MetadataURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GITHUB_REPOSITORY", tt.repo)
+			t.Setenv("INPUT_REGION", tt.region)
+			t.Setenv("INPUT_ENVIRONMENT", tt.environment)
+			t.Setenv("METADATA_SERVICE_URL", "")
+			t.Setenv("SIGNING_SERVICE_URL", "")
+
+			// method under test
+			err := ValidateEnvironment()
+
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.metadataURL, GetMetadataURL(), "disallowed staging must never fall through to staging URLs")
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// validate checks a value decoded from JSON against an OpenAPI schema object
+// Supports the subset the service documents use: $ref, oneOf, type, nullable, enum, required, properties,
+// additionalProperties, items, pattern, minLength and minItems
+func (s *Spec) validate(rawSchema any, value any, at string) error {
+	schema, err := s.resolveSchema(rawSchema)
+	if err != nil {
+		return fmt.Errorf("%s: %w", at, err)
+	}
+	if len(schema) == 0 {
+		return nil
+	}
+
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		return s.validateOneOf(oneOf, value, at)
+	}
+
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable {
+			return nil
+		}
+		return fmt.Errorf("%s must not be null", at)
+	}
+
+	if typ, ok := schema["type"].(string); ok && !hasType(value, typ) {
+		return fmt.Errorf("%s must be of type %s, got %s", at, typ, typeName(value))
+	}
+
+	if enum, ok := schema["enum"].([]any); ok && !inEnum(value, enum) {
+		return fmt.Errorf("%s must be one of %v, got %v", at, enum, value)
+	}
+
+	switch v := value.(type) {
+	case string:
+		if minLength, ok := schema["minLength"].(int); ok && len(v) < minLength {
+			return fmt.Errorf("%s must be at least %d characters", at, minLength)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: invalid pattern %q in schema: %w", at, pattern, err)
+			}
+			if !re.MatchString(v) {
+				return fmt.Errorf("%s %q does not match pattern %s", at, v, pattern)
+			}
+		}
+	case []any:
+		if minItems, ok := schema["minItems"].(int); ok && len(v) < minItems {
+			return fmt.Errorf("%s must have at least %d items", at, minItems)
+		}
+		for i, item := range v {
+			if err := s.validate(schema["items"], item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		return s.validateObject(schema, v, at)
+	}
+	return nil
+}
+
+// validateOneOf requires value to match exactly one of the schemas
+func (s *Spec) validateOneOf(schemas []any, value any, at string) error {
+	var errs []string
+	for _, schema := range schemas {
+		if err := s.validate(schema, value, at); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	switch matched := len(schemas) - len(errs); {
+	case matched == 1:
+		return nil
+	case matched > 1:
+		return fmt.Errorf("%s matches %d schemas of oneOf, expected exactly one", at, matched)
+	}
+	return fmt.Errorf("%s matches none of oneOf: %s", at, strings.Join(errs, "; "))
+}
+
+func (s *Spec) validateObject(schema map[string]any, object map[string]any, at string) error {
+	required, _ := schema["required"].([]any)
+	for _, name := range required {
+		if _, ok := object[fmt.Sprint(name)]; !ok {
+			return fmt.Errorf("%s is missing required property %s", at, name)
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	// Sorted so the first violation reported is deterministic
+	sort.Strings(names)
+
+	for _, name := range names {
+		if propertySchema, ok := properties[name]; ok {
+			if err := s.validate(propertySchema, object[name], at+"."+name); err != nil {
+				return err
+			}
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s has unexpected property %s", at, name)
+			}
+		case map[string]any:
+			if err := s.validate(additional, object[name], at+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveSchema follows $ref into components, returning an empty schema for an absent one
+func (s *Spec) resolveSchema(raw any) (map[string]any, error) {
+	schema, _ := raw.(map[string]any)
+	for depth := 0; depth < 32; depth++ {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			return schema, nil
+		}
+		name, found := strings.CutPrefix(ref, "#/components/schemas/")
+		resolved, exists := s.schemas[name].(map[string]any)
+		if !found || !exists {
+			return nil, fmt.Errorf("unresolvable schema reference %s", ref)
+		}
+		schema = resolved
+	}
+	return nil, fmt.Errorf("schema references are nested too deeply")
+}
+
+func hasType(value any, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	}
+	return true
+}
+
+func typeName(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func inEnum(value any, enum []any) bool {
+	for _, allowed := range enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
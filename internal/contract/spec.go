@@ -0,0 +1,211 @@
+package contract
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed specs/*.yaml
+var specFiles embed.FS
+
+// Spec is a parsed OpenAPI 3 document, reduced to what is needed to validate outgoing requests
+type Spec struct {
+	Title      string
+	operations []operation
+	schemas    map[string]any
+	parameters map[string]any
+}
+
+// operation is a method and path template with its path parameter and request body schemas
+type operation struct {
+	method     string
+	template   string
+	segments   []string
+	params     map[string]any
+	bodySchema any
+	bodyNeeded bool
+}
+
+// Instrumentation returns the embedded OpenAPI document of the instrumentation metadata service
+func Instrumentation() (*Spec, error) {
+	return loadEmbedded("specs/instrumentation.yaml")
+}
+
+// Signing returns the embedded OpenAPI document of the OCI artifact signing service
+func Signing() (*Spec, error) {
+	return loadEmbedded("specs/signing.yaml")
+}
+
+func loadEmbedded(name string) (*Spec, error) {
+	data, err := specFiles.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return Parse(data)
+}
+
+// Parse parses an OpenAPI 3 document in YAML or JSON
+func Parse(data []byte) (*Spec, error) {
+	var doc struct {
+		OpenAPI string `yaml:"openapi"`
+		Info    struct {
+			Title string `yaml:"title"`
+		} `yaml:"info"`
+		Paths      map[string]map[string]any `yaml:"paths"`
+		Components struct {
+			Schemas    map[string]any `yaml:"schemas"`
+			Parameters map[string]any `yaml:"parameters"`
+		} `yaml:"components"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q: must be 3.x", doc.OpenAPI)
+	}
+
+	spec := &Spec{
+		Title:      doc.Info.Title,
+		schemas:    doc.Components.Schemas,
+		parameters: doc.Components.Parameters,
+	}
+
+	for template, item := range doc.Paths {
+		shared, _ := item["parameters"].([]any)
+		for method, raw := range item {
+			method = strings.ToUpper(method)
+			if !isHTTPMethod(method) {
+				continue
+			}
+			op, _ := raw.(map[string]any)
+			parsed, err := spec.parseOperation(method, template, shared, op)
+			if err != nil {
+				return nil, err
+			}
+			spec.operations = append(spec.operations, parsed)
+		}
+	}
+	return spec, nil
+}
+
+func (s *Spec) parseOperation(method, template string, shared []any, op map[string]any) (operation, error) {
+	parsed := operation{
+		method:   method,
+		template: template,
+		segments: strings.Split(strings.Trim(template, "/"), "/"),
+		params:   map[string]any{},
+	}
+
+	own, _ := op["parameters"].([]any)
+	for _, raw := range append(append([]any{}, shared...), own...) {
+		param, err := s.resolveParameter(raw)
+		if err != nil {
+			return operation{}, fmt.Errorf("%s %s: %w", method, template, err)
+		}
+		if param["in"] == "path" {
+			name, _ := param["name"].(string)
+			parsed.params[name] = param["schema"]
+		}
+	}
+
+	if body, ok := op["requestBody"].(map[string]any); ok {
+		parsed.bodyNeeded, _ = body["required"].(bool)
+		content, _ := body["content"].(map[string]any)
+		if media, ok := content["application/json"].(map[string]any); ok {
+			parsed.bodySchema = media["schema"]
+		}
+	}
+	return parsed, nil
+}
+
+// resolveParameter follows a parameter $ref into components
+func (s *Spec) resolveParameter(raw any) (map[string]any, error) {
+	param, _ := raw.(map[string]any)
+	ref, ok := param["$ref"].(string)
+	if !ok {
+		return param, nil
+	}
+	name, found := strings.CutPrefix(ref, "#/components/parameters/")
+	resolved, exists := s.parameters[name].(map[string]any)
+	if !found || !exists {
+		return nil, fmt.Errorf("unresolvable parameter reference %s", ref)
+	}
+	return resolved, nil
+}
+
+// find returns the operation matching a method and path, and the path parameters
+func (s *Spec) find(method, path string) (*operation, map[string]string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := range s.operations {
+		op := &s.operations[i]
+		if op.method != method || len(op.segments) != len(segments) {
+			continue
+		}
+		params := map[string]string{}
+		matched := true
+		for j, segment := range op.segments {
+			if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+				params[segment[1:len(segment)-1]] = segments[j]
+				continue
+			}
+			if segment != segments[j] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return op, params
+		}
+	}
+	return nil, nil
+}
+
+// ValidateRequest checks a request against the document
+// path is relative to the service base URL; body may be empty for operations without a request body
+func (s *Spec) ValidateRequest(method, path string, body []byte) error {
+	op, params := s.find(method, path)
+	if op == nil {
+		return fmt.Errorf("%s %s is not an operation of %s", method, path, s.Title)
+	}
+
+	for name, value := range params {
+		if err := s.validate(op.params[name], value, "path parameter "+name); err != nil {
+			return fmt.Errorf("%s %s: %w", method, op.template, err)
+		}
+	}
+
+	if len(body) == 0 {
+		if op.bodyNeeded {
+			return fmt.Errorf("%s %s: request body is required", method, op.template)
+		}
+		return nil
+	}
+	if op.bodySchema == nil {
+		return fmt.Errorf("%s %s: operation does not take a JSON request body", method, op.template)
+	}
+
+	var decoded any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return fmt.Errorf("%s %s: request body is not valid JSON: %w", method, op.template, err)
+	}
+	if err := s.validate(op.bodySchema, decoded, "body"); err != nil {
+		return fmt.Errorf("%s %s: %w", method, op.template, err)
+	}
+	return nil
+}
+
+func isHTTPMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
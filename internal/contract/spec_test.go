@@ -0,0 +1,173 @@
+package contract
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func marshal(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return data
+}
+
+func marshalPayload(t *testing.T, version string, metadata *models.AgentMetadata) []byte {
+	t.Helper()
+	data, err := models.EncodePayload(version, metadata, models.EmptyOmit, models.PayloadFeatures)
+	require.NoError(t, err)
+	return data
+}
+
+func TestInstrumentation_ValidateRequest(t *testing.T) {
+	spec, err := Instrumentation()
+	require.NoError(t, err)
+
+	breaking := "yes"
+	valid := &models.AgentMetadata{
+		ConfigurationDefinitions: []models.ConfigurationDefinition{{"platform": "ALL", "type": "agent-config", "version": "1.0.0", "schema": "e30="}},
+		Metadata:                 models.Metadata{"version": "1.2.3", "monitoringType": "APM", "tags": map[string]string{"team": "java"}},
+		AgentControlDefinitions:  []models.AgentControlDefinition{{"platform": "KUBERNETES", "content": "e30="}},
+		BreakingChange:           &breaking,
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		body        []byte
+		expectedErr string
+	}{
+		{name: "agent metadata", method: http.MethodPost, path: "/v1/agents/NRJavaAgent/versions/1.2.3", body: marshal(t, valid)},
+		{name: "docs metadata without definitions", method: http.MethodPost, path: "/v1/agents/NRJavaAgent/versions/1.2.3",
+			body: marshal(t, &models.AgentMetadata{Metadata: models.Metadata{"version": "1.2.3", "features": []string{"a"}}})},
+		{name: "v2 agent metadata", method: http.MethodPost, path: "/v1/agents/NRJavaAgent/versions/1.2.3", body: marshalPayload(t, models.PayloadV2, valid)},
+		{name: "v3 localized agent metadata", method: http.MethodPost, path: "/v1/agents/NRJavaAgent/versions/1.2.3", body: marshalPayload(t, models.PayloadV3, &models.AgentMetadata{
+			ConfigurationDefinitions: []models.ConfigurationDefinition{{"platform": "ALL", "type": "agent-config", "version": "1.0.0", "description": "Agent configuration", "description_i18n": map[string]interface{}{"de": "Agentenkonfiguration"}}},
+			Metadata:                 valid.Metadata,
+		})},
+		{name: "v3 empty translation", method: http.MethodPost, path: "/v1/agents/NRJavaAgent/versions/1.2.3",
+			body:        []byte(`{"schemaVersion": "v3", "metadata": {"version": "1", "localizedDescriptions": {"de": ""}}}`),
+			expectedErr: "body matches none of oneOf"},
+		{name: "v2 without schema version", method: http.MethodPost, path: "/v1/agents/NRJavaAgent/versions/1.2.3", body: []byte(`{"metadata": {"version": "1"}, "definitions": {}}`),
+			expectedErr: "body matches none of oneOf"},
+		{name: "start chunked upload", method: http.MethodPost, path: "/v1/agents/NRJavaAgent/versions/1.2.3/uploads",
+			body: marshal(t, &models.AgentMetadata{Metadata: valid.Metadata, AgentControlDefinitions: valid.AgentControlDefinitions})},
+		{name: "chunked upload part", method: http.MethodPatch, path: "/v1/agents/NRJavaAgent/versions/1.2.3/uploads/upload-1",
+			body: marshal(t, map[string]any{"configurationDefinitions": valid.ConfigurationDefinitions})},
+		{name: "empty chunked upload part", method: http.MethodPatch, path: "/v1/agents/NRJavaAgent/versions/1.2.3/uploads/upload-1",
+			body: []byte(`{"configurationDefinitions": []}`), expectedErr: "body.configurationDefinitions must have at least 1 items"},
+		{name: "commit chunked upload", method: http.MethodPost, path: "/v1/agents/NRJavaAgent/versions/1.2.3/uploads/upload-1/commit"},
+		{name: "abort chunked upload", method: http.MethodDelete, path: "/v1/agents/NRJavaAgent/versions/1.2.3/uploads/upload-1"},
+		{name: "capabilities", method: http.MethodGet, path: "/v1/capabilities"},
+		{name: "list agent types", method: http.MethodGet, path: "/v1/agents"},
+		{name: "health", method: http.MethodGet, path: "/v1/health"},
+		{name: "get metadata", method: http.MethodGet, path: "/v1/agents/NRJavaAgent/versions/1.2.3"},
+		{name: "list versions", method: http.MethodGet, path: "/v1/agents/NRJavaAgent/versions"},
+		{name: "unknown operation", method: http.MethodPut, path: "/v1/agents/NRJavaAgent/versions/1.2.3",
+			expectedErr: "is not an operation of Instrumentation Metadata Service"},
+		{name: "lock release", method: http.MethodPut, path: "/v1/agents/NRJavaAgent/versions/1.2.3/lock", body: []byte(`{"owner": "newrelic/java-agent run 1", "ttlSeconds": 1800}`)},
+		{name: "lock without ttl", method: http.MethodPut, path: "/v1/agents/NRJavaAgent/versions/1.2.3/lock", body: []byte(`{"owner": "newrelic/java-agent run 1"}`),
+			expectedErr: "ttlSeconds"},
+		{name: "unknown path", method: http.MethodGet, path: "/v2/agents",
+			expectedErr: "is not an operation"},
+		{name: "invalid agent type", method: http.MethodGet, path: "/v1/agents/-java/versions",
+			expectedErr: `path parameter agentType "-java" does not match pattern`},
+		{name: "missing body", method: http.MethodPost, path: "/v1/agents/NRJavaAgent/versions/1.2.3",
+			expectedErr: "request body is required"},
+		{name: "invalid json", method: http.MethodPost, path: "/v1/agents/NRJavaAgent/versions/1.2.3", body: []byte("{"),
+			expectedErr: "request body is not valid JSON"},
+		{name: "missing metadata", method: http.MethodPost, path: "/v1/agents/NRJavaAgent/versions/1.2.3", body: []byte(`{"configurationDefinitions": []}`),
+			expectedErr: "body is missing required property metadata"},
+		{name: "missing version", method: http.MethodPost, path: "/v1/agents/NRJavaAgent/versions/1.2.3", body: []byte(`{"metadata": {}}`),
+			expectedErr: "body.metadata is missing required property version"},
+		{name: "wrong version type", method: http.MethodPost, path: "/v1/agents/NRJavaAgent/versions/1.2.3", body: []byte(`{"metadata": {"version": 1.2}}`),
+			expectedErr: "body.metadata.version must be of type string, got number"},
+		{name: "invalid monitoring type", method: http.MethodPost, path: "/v1/agents/NRJavaAgent/versions/1.2.3", body: []byte(`{"metadata": {"version": "1", "monitoringType": "RUM"}}`),
+			expectedErr: "body.metadata.monitoringType must be one of [APM INFRA], got RUM"},
+		{name: "non string tag", method: http.MethodPost, path: "/v1/agents/NRJavaAgent/versions/1.2.3", body: []byte(`{"metadata": {"version": "1", "tags": {"count": 3}}}`),
+			expectedErr: "body.metadata.tags.count must be of type string"},
+		{name: "unexpected top level property", method: http.MethodPost, path: "/v1/agents/NRJavaAgent/versions/1.2.3", body: []byte(`{"metadata": {"version": "1"}, "extra": true}`),
+			expectedErr: "body has unexpected property extra"},
+		{name: "configuration definition missing platform", method: http.MethodPost, path: "/v1/agents/NRJavaAgent/versions/1.2.3",
+			body:        []byte(`{"metadata": {"version": "1"}, "configurationDefinitions": [{"type": "agent-config", "version": "1.0.0"}]}`),
+			expectedErr: "body.configurationDefinitions[0] is missing required property platform"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			err := spec.ValidateRequest(tt.method, tt.path, tt.body)
+
+			if tt.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSigning_ValidateRequest(t *testing.T) {
+	spec, err := Signing()
+	require.NoError(t, err)
+
+	valid := &models.SigningRequest{Registry: "docker.io", Repository: "newrelic/agents", Tag: "1.2.3", Digest: "sha256:" + strings.Repeat("a", 64)}
+	assert.NoError(t, spec.ValidateRequest(http.MethodPost, "/v1/signing/java-agent/sign", marshal(t, valid)))
+
+	invalid := *valid
+	invalid.Digest = "sha256:abc"
+	err = spec.ValidateRequest(http.MethodPost, "/v1/signing/java-agent/sign", marshal(t, &invalid))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `body.digest "sha256:abc" does not match pattern`)
+
+	invalid = *valid
+	invalid.Tag = ""
+	err = spec.ValidateRequest(http.MethodPost, "/v1/signing/java-agent/sign", marshal(t, &invalid))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "body.tag must be at least 1 characters")
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		doc         string
+		expectedErr string
+	}{
+		{name: "json document", doc: `{"openapi": "3.1.0", "info": {"title": "t"}, "paths": {"/x": {"get": {}}}}`},
+		{name: "swagger 2", doc: `{"swagger": "2.0"}`, expectedErr: "unsupported OpenAPI version"},
+		{name: "invalid document", doc: `[`, expectedErr: "failed to parse OpenAPI document"},
+		{name: "unresolvable parameter", doc: `{"openapi": "3.0.0", "paths": {"/x/{id}": {"get": {"parameters": [{"$ref": "#/components/parameters/Id"}]}}}}`,
+			expectedErr: "unresolvable parameter reference #/components/parameters/Id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.doc))
+			if tt.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestValidate_UnresolvableSchema(t *testing.T) {
+	spec, err := Parse([]byte(`{"openapi": "3.0.0", "paths": {"/x": {"post": {"requestBody": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Missing"}}}}}}}}`))
+	require.NoError(t, err)
+
+	err = spec.ValidateRequest(http.MethodPost, "/x", []byte(`{}`))
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unresolvable schema reference #/components/schemas/Missing")
+}
@@ -0,0 +1,75 @@
+package contract
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"agent-metadata-action/internal/retry"
+)
+
+// Transport validates requests to known services against their OpenAPI documents before sending them
+// Requests to other hosts are passed through unchanged
+type Transport struct {
+	Base     http.RoundTripper
+	services map[string]*Spec
+}
+
+// NewTransport wraps base, validating requests under each base URL against its document
+func NewTransport(base http.RoundTripper, services map[string]*Spec) *Transport {
+	normalized := make(map[string]*Spec, len(services))
+	for baseURL, spec := range services {
+		normalized[strings.TrimRight(baseURL, "/")] = spec
+	}
+	return &Transport{Base: base, services: normalized}
+}
+
+// RoundTrip validates the request and sends it if it conforms
+// Violations are returned as non-retryable errors without sending the request
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	spec, path := t.match(req)
+	if spec == nil {
+		return t.Base.RoundTrip(req)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body for contract validation: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	// Compressed bodies are validated as the JSON they decompress to
+	if req.Header.Get("Content-Encoding") == "gzip" && len(body) > 0 {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err == nil {
+			body, err = io.ReadAll(reader)
+		}
+		if err != nil {
+			return nil, retry.NewNonRetryableError(fmt.Errorf("contract violation: invalid gzip request body: %w", err))
+		}
+	}
+
+	if err := spec.ValidateRequest(req.Method, path, body); err != nil {
+		return nil, retry.NewNonRetryableError(fmt.Errorf("contract violation: %w", err))
+	}
+	return t.Base.RoundTrip(req)
+}
+
+// match returns the document for the service the request is sent to, and the path relative to its base URL
+func (t *Transport) match(req *http.Request) (*Spec, string) {
+	target := req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+	for baseURL, spec := range t.services {
+		if path, ok := strings.CutPrefix(target, baseURL); ok && (path == "" || strings.HasPrefix(path, "/")) {
+			return spec, path
+		}
+	}
+	return nil, ""
+}
@@ -0,0 +1,110 @@
+package contract
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"agent-metadata-action/internal/retry"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r.Method+" "+r.URL.Path+" "+string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	spec, err := Instrumentation()
+	require.NoError(t, err)
+	client := &http.Client{Transport: NewTransport(http.DefaultTransport, map[string]*Spec{server.URL + "/": spec})}
+
+	// Conforming request is sent with its body intact
+	body := `{"metadata": {"version": "1.2.3"}}`
+	resp, err := client.Post(server.URL+"/v1/agents/NRJavaAgent/versions/1.2.3", "application/json", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []string{"POST /v1/agents/NRJavaAgent/versions/1.2.3 " + body}, received)
+
+	// Violation is not sent and is not retried
+	_, err = client.Post(server.URL+"/v1/agents/NRJavaAgent/versions/1.2.3", "application/json", bytes.NewBufferString(`{"metadata": {}}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "contract violation")
+	assert.Contains(t, err.Error(), "missing required property version")
+	assert.True(t, retry.IsNonRetryable(err))
+	assert.Len(t, received, 1)
+}
+
+func TestTransport_GzipBody(t *testing.T) {
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	spec, err := Instrumentation()
+	require.NoError(t, err)
+	client := &http.Client{Transport: NewTransport(http.DefaultTransport, map[string]*Spec{server.URL: spec})}
+
+	post := func(body []byte) error {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/agents/NRJavaAgent/versions/1.2.3", bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// The decompressed body is validated and the compressed body is sent
+	compressed := gzipString(t, `{"metadata": {"version": "1.2.3"}}`)
+	require.NoError(t, post(compressed))
+	assert.Equal(t, compressed, received)
+
+	err = post(gzipString(t, `{"metadata": {}}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing required property version")
+
+	err = post([]byte(`{"metadata": {"version": "1.2.3"}}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid gzip request body")
+}
+
+func gzipString(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestTransport_OtherHostsPassThrough(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	spec, err := Signing()
+	require.NoError(t, err)
+	client := &http.Client{Transport: NewTransport(http.DefaultTransport, map[string]*Spec{"https://oci-signer.service.newrelic.com": spec})}
+
+	resp, err := client.Get(server.URL + "/anything")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.True(t, called)
+}
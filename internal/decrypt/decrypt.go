@@ -0,0 +1,116 @@
+package decrypt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"agent-metadata-action/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the encryption a schema or content file is stored with
+type Format string
+
+const (
+	None Format = ""
+	Age  Format = "age"
+	SOPS Format = "sops"
+)
+
+// ageHeaders start binary and ASCII-armored age files
+var ageHeaders = [][]byte{[]byte("age-encryption.org/v1\n"), []byte("-----BEGIN AGE ENCRYPTED FILE-----")}
+
+// Detect returns how a file is encrypted from its name and content
+// age files have a .age extension or an age header; SOPS files are JSON or YAML with top-level sops metadata
+func Detect(name string, data []byte) Format {
+	if strings.HasSuffix(name, ".age") {
+		return Age
+	}
+	trimmed := bytes.TrimSpace(data)
+	for _, header := range ageHeaders {
+		if bytes.HasPrefix(trimmed, header) {
+			return Age
+		}
+	}
+	if !bytes.Contains(data, []byte("sops")) {
+		return None
+	}
+	var document struct {
+		SOPS struct {
+			MAC string `yaml:"mac"`
+		} `yaml:"sops"`
+	}
+	if yaml.Unmarshal(data, &document) == nil && document.SOPS.MAC != "" {
+		return SOPS
+	}
+	return None
+}
+
+// runFunc runs a decryption command with extra environment and returns its stdout
+// This allows tests to override the implementation
+var runFunc = func(ctx context.Context, env []string, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("the %s command is not installed on the runner", name)
+		}
+		return nil, fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// File returns the plaintext of a file encrypted with age or SOPS (with an age key), or data unchanged if it isn't
+// encrypted; name is the file's path, used to detect the format and in errors
+// The key is the decryption-key input; decryption shells out to the age and sops CLIs, which must be on the PATH
+func File(ctx context.Context, name string, data []byte) ([]byte, error) {
+	format := Detect(name, data)
+	if format == None {
+		return data, nil
+	}
+	key := strings.TrimSpace(config.GetDecryptionKey())
+	if key == "" {
+		return nil, fmt.Errorf("%s is encrypted with %s but the decryption-key input is not set", name, format)
+	}
+
+	// The CLIs read the key and ciphertext from files, kept private and removed once decrypted
+	dir, err := os.MkdirTemp("", "agent-metadata-decrypt-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create decryption directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	// sops picks the document format from the extension
+	encryptedPath := filepath.Join(dir, filepath.Base(name))
+	if err := os.WriteFile(encryptedPath, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to stage %s for decryption: %w", name, err)
+	}
+
+	var plaintext []byte
+	switch format {
+	case Age:
+		keyPath := filepath.Join(dir, "key.txt")
+		if err := os.WriteFile(keyPath, []byte(key+"\n"), 0600); err != nil {
+			return nil, fmt.Errorf("failed to stage decryption key: %w", err)
+		}
+		plaintext, err = runFunc(ctx, nil, "age", "--decrypt", "--identity", keyPath, encryptedPath)
+	case SOPS:
+		plaintext, err = runFunc(ctx, []string{"SOPS_AGE_KEY=" + key}, "sops", "--decrypt", encryptedPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s with %s: %w", name, format, err)
+	}
+	if len(plaintext) == 0 {
+		return nil, fmt.Errorf("%s decrypted to an empty file", name)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,140 @@
+package decrypt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sopsSchema = `{
+	"type": "ENC[AES256_GCM,data:abc=,iv:def=,tag:ghi=,type:str]",
+	"sops": {"age": [{"recipient": "age1xyz"}], "mac": "ENC[AES256_GCM,data:jkl=,type:str]", "version": "3.9.0"}
+}`
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		data     string
+		expected Format
+	}{
+		{"plain schema", "schema.json", `{"type": "object"}`, None},
+		{"age extension", "schema.json.age", "binary", Age},
+		{"age binary header", "schema.json", "age-encryption.org/v1\n-> X25519 abc\n", Age},
+		{"age armored header", "schema.json", "\n-----BEGIN AGE ENCRYPTED FILE-----\nYWdl\n-----END AGE ENCRYPTED FILE-----\n", Age},
+		{"sops json", "schema.json", sopsSchema, SOPS},
+		{"sops yaml", "content.yml", "key: ENC[AES256_GCM,data:abc=]\nsops:\n  mac: ENC[AES256_GCM,data:def=]\n", SOPS},
+		{"sops property without metadata", "schema.json", `{"properties": {"sops": {"type": "string"}}}`, None},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Detect(tt.file, []byte(tt.data)))
+		})
+	}
+}
+
+// stubRun replaces the decryption command, recording how it was called
+func stubRun(t *testing.T, output string, err error) *[]string {
+	t.Helper()
+	var calls []string
+	original := runFunc
+	runFunc = func(ctx context.Context, env []string, name string, args ...string) ([]byte, error) {
+		call := fmt.Sprintf("%s %v %v", name, env, args[:len(args)-1])
+		if name == "age" {
+			key, readErr := os.ReadFile(args[2])
+			require.NoError(t, readErr)
+			call += " key=" + string(key)
+		}
+		encrypted, readErr := os.ReadFile(args[len(args)-1])
+		require.NoError(t, readErr)
+		calls = append(calls, call+" file="+filepath.Base(args[len(args)-1])+" size="+fmt.Sprint(len(encrypted)))
+		return []byte(output), err
+	}
+	t.Cleanup(func() { runFunc = original })
+	return &calls
+}
+
+func TestFile(t *testing.T) {
+	t.Run("plain files are unchanged", func(t *testing.T) {
+		calls := stubRun(t, "", nil)
+
+		data, err := File(context.Background(), "schema.json", []byte(`{"type": "object"}`))
+
+		require.NoError(t, err)
+		assert.Equal(t, `{"type": "object"}`, string(data))
+		assert.Empty(t, *calls)
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		t.Setenv("INPUT_DECRYPTION_KEY", "")
+		stubRun(t, "", nil)
+
+		_, err := File(context.Background(), "schemas/config.json.age", []byte("ciphertext"))
+
+		require.Error(t, err)
+		assert.Equal(t, "schemas/config.json.age is encrypted with age but the decryption-key input is not set", err.Error())
+	})
+
+	t.Run("age", func(t *testing.T) {
+		t.Setenv("INPUT_DECRYPTION_KEY", " AGE-SECRET-KEY-1TEST \n")
+		calls := stubRun(t, `{"type": "object"}`, nil)
+
+		// method under test
+		data, err := File(context.Background(), "schemas/config.json.age", []byte("ciphertext"))
+
+		require.NoError(t, err)
+		assert.Equal(t, `{"type": "object"}`, string(data))
+		require.Len(t, *calls, 1)
+		assert.Contains(t, (*calls)[0], "age [] [--decrypt --identity")
+		assert.Contains(t, (*calls)[0], "key=AGE-SECRET-KEY-1TEST\n file=config.json.age size=10")
+	})
+
+	t.Run("sops", func(t *testing.T) {
+		t.Setenv("INPUT_DECRYPTION_KEY", "AGE-SECRET-KEY-1TEST")
+		calls := stubRun(t, `{"type": "object"}`, nil)
+
+		// method under test
+		data, err := File(context.Background(), "schemas/config.json", []byte(sopsSchema))
+
+		require.NoError(t, err)
+		assert.Equal(t, `{"type": "object"}`, string(data))
+		require.Len(t, *calls, 1)
+		assert.Equal(t, fmt.Sprintf("sops [SOPS_AGE_KEY=AGE-SECRET-KEY-1TEST] [--decrypt] file=config.json size=%d", len(sopsSchema)), (*calls)[0])
+	})
+
+	t.Run("decryption failure", func(t *testing.T) {
+		t.Setenv("INPUT_DECRYPTION_KEY", "AGE-SECRET-KEY-1TEST")
+		stubRun(t, "", fmt.Errorf("age failed: exit status 1: no identity matched any of the recipients"))
+
+		_, err := File(context.Background(), "schemas/config.json.age", []byte("ciphertext"))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to decrypt schemas/config.json.age with age: age failed")
+		assert.Contains(t, err.Error(), "no identity matched")
+	})
+
+	t.Run("empty plaintext", func(t *testing.T) {
+		t.Setenv("INPUT_DECRYPTION_KEY", "AGE-SECRET-KEY-1TEST")
+		stubRun(t, "", nil)
+
+		_, err := File(context.Background(), "schemas/config.json.age", []byte("ciphertext"))
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "decrypted to an empty file")
+	})
+}
+
+func TestRun_MissingCommand(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	_, err := runFunc(context.Background(), nil, "age", "--decrypt")
+
+	require.Error(t, err)
+	assert.Equal(t, "the age command is not installed on the runner", err.Error())
+}
@@ -0,0 +1,179 @@
+package export
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"agent-metadata-action/internal/canonical"
+	"agent-metadata-action/internal/models"
+)
+
+// AgentsDirectory is the directory under the export root that holds one directory per agent type
+const AgentsDirectory = "agents"
+
+// IndexFilename lists the exported versions of an agent type so static consumers don't need directory listings
+const IndexFilename = "index.json"
+
+// encodedFields are the definition fields the loader base64-encodes and the exporter decodes
+var encodedFields = []string{"schema", "content"}
+
+// pathSegmentRegex limits agent types and versions to characters that are safe as a single path segment
+var pathSegmentRegex = regexp.MustCompile(`^[A-Za-z0-9._+-]+$`)
+
+// Index is the content of an agent type's index.json
+type Index struct {
+	AgentType string   `json:"agentType"`
+	Versions  []string `json:"versions"`
+}
+
+// Write writes the resolved metadata to <dir>/agents/<agentType>/<version>.json, refreshes the agent type's index.json
+// and returns the path of the written file
+func Write(dir, agentType, version string, metadata *models.AgentMetadata) (string, error) {
+	if metadata == nil {
+		return "", fmt.Errorf("metadata is required")
+	}
+	if err := validatePathSegment("agent type", agentType); err != nil {
+		return "", err
+	}
+	if err := validatePathSegment("version", version); err != nil {
+		return "", err
+	}
+
+	agentDir := filepath.Join(dir, AgentsDirectory, agentType)
+	if err := os.MkdirAll(agentDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create export directory %s: %w", agentDir, err)
+	}
+
+	// Snapshots use the canonical definition order so re-exporting the same metadata doesn't change the file
+	sorted, err := metadata.Canonical()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(agentDir, version+".json")
+	if err := writeJSON(path, Resolve(sorted)); err != nil {
+		return "", err
+	}
+
+	if err := writeIndex(agentDir, agentType); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Read reads the metadata exported for an agent version, in the resolved form Write exports it in
+// Returns nil, nil if the version has not been exported
+func Read(dir, agentType, version string) (*models.AgentMetadata, error) {
+	if err := validatePathSegment("agent type", agentType); err != nil {
+		return nil, err
+	}
+	if err := validatePathSegment("version", version); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, AgentsDirectory, agentType, version+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var metadata models.AgentMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &metadata, nil
+}
+
+// Resolve returns a copy of metadata with base64-encoded schema and content fields decoded
+// Decoded JSON documents are embedded as JSON; anything else (e.g. YAML) is embedded as text
+func Resolve(metadata *models.AgentMetadata) *models.AgentMetadata {
+	resolved := *metadata
+
+	resolved.ConfigurationDefinitions = make([]models.ConfigurationDefinition, len(metadata.ConfigurationDefinitions))
+	for i, def := range metadata.ConfigurationDefinitions {
+		resolved.ConfigurationDefinitions[i] = models.ConfigurationDefinition(decodeFields(def))
+	}
+
+	resolved.AgentControlDefinitions = make([]models.AgentControlDefinition, len(metadata.AgentControlDefinitions))
+	for i, def := range metadata.AgentControlDefinitions {
+		resolved.AgentControlDefinitions[i] = models.AgentControlDefinition(decodeFields(def))
+	}
+
+	return &resolved
+}
+
+// decodeFields copies a definition, decoding any encodedFields that hold base64 strings
+func decodeFields(def map[string]interface{}) map[string]interface{} {
+	decoded := make(map[string]interface{}, len(def))
+	for k, v := range def {
+		decoded[k] = v
+	}
+
+	for _, field := range encodedFields {
+		encoded, ok := decoded[field].(string)
+		if !ok || encoded == "" {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			// Not something the loader encoded; export it as-is
+			continue
+		}
+		var document interface{}
+		if json.Unmarshal(data, &document) == nil {
+			decoded[field] = document
+		} else {
+			decoded[field] = string(data)
+		}
+	}
+	return decoded
+}
+
+// writeIndex rewrites index.json from the version files present in agentDir
+func writeIndex(agentDir, agentType string) error {
+	entries, err := os.ReadDir(agentDir)
+	if err != nil {
+		return fmt.Errorf("failed to list export directory %s: %w", agentDir, err)
+	}
+
+	index := Index{AgentType: agentType, Versions: []string{}}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == IndexFilename || filepath.Ext(name) != ".json" {
+			continue
+		}
+		index.Versions = append(index.Versions, strings.TrimSuffix(name, ".json"))
+	}
+	sort.Strings(index.Versions)
+
+	return writeJSON(filepath.Join(agentDir, IndexFilename), index)
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := canonical.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", filepath.Base(path), err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// validatePathSegment rejects values that would escape or nest within the export tree
+func validatePathSegment(name, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s is required", name)
+	}
+	if value == "." || value == ".." || !pathSegmentRegex.MatchString(value) {
+		return fmt.Errorf("invalid %s %q: must only contain letters, digits, '.', '_', '+' or '-'", name, value)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package export
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	metadata := &models.AgentMetadata{
+		Metadata: models.Metadata{"version": "1.2.3"},
+		ConfigurationDefinitions: []models.ConfigurationDefinition{
+			{"type": "agent-config", "schema": encode(`{"type": "object"}`)},
+		},
+		AgentControlDefinitions: []models.AgentControlDefinition{
+			{"platform": "KUBERNETES", "content": encode("key: value\n")},
+		},
+	}
+
+	// method under test
+	path, err := Write(dir, "NRJavaAgent", "1.2.3", metadata)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "agents", "NRJavaAgent", "1.2.3.json"), path)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var exported map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &exported))
+
+	assert.Equal(t, map[string]interface{}{"version": "1.2.3"}, exported["metadata"])
+	configDef := exported["configurationDefinitions"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "object"}, configDef["schema"])
+	agentControlDef := exported["agentControlDefinitions"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "key: value\n", agentControlDef["content"])
+
+	// The original metadata is sent to the service encoded, so it must not be modified
+	assert.Equal(t, encode(`{"type": "object"}`), metadata.ConfigurationDefinitions[0]["schema"])
+
+	_, err = Write(dir, "NRJavaAgent", "1.10.0", metadata)
+	require.NoError(t, err)
+
+	data, err = os.ReadFile(filepath.Join(dir, "agents", "NRJavaAgent", IndexFilename))
+	require.NoError(t, err)
+	var index Index
+	require.NoError(t, json.Unmarshal(data, &index))
+	assert.Equal(t, Index{AgentType: "NRJavaAgent", Versions: []string{"1.10.0", "1.2.3"}}, index)
+}
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+	metadata := &models.AgentMetadata{
+		Metadata: models.Metadata{"version": "1.2.3"},
+		ConfigurationDefinitions: []models.ConfigurationDefinition{
+			{"type": "agent-config", "schema": encode(`{"type": "object"}`)},
+		},
+	}
+	_, err := Write(dir, "NRJavaAgent", "1.2.3", metadata)
+	require.NoError(t, err)
+
+	// method under test
+	exported, err := Read(dir, "NRJavaAgent", "1.2.3")
+
+	require.NoError(t, err)
+	assert.Equal(t, Resolve(metadata).ConfigurationDefinitions[0]["schema"], exported.ConfigurationDefinitions[0]["schema"])
+	assert.Equal(t, "1.2.3", exported.Metadata["version"])
+
+	missing, err := Read(dir, "NRJavaAgent", "9.9.9")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	_, err = Read(dir, "NRJavaAgent", "../1.2.3")
+	require.Error(t, err)
+}
+
+func TestWrite_InvalidPathSegments(t *testing.T) {
+	metadata := &models.AgentMetadata{}
+
+	tests := []struct {
+		name      string
+		agentType string
+		version   string
+		errMsg    string
+	}{
+		{name: "empty agent type", agentType: "", version: "1.0.0", errMsg: "agent type is required"},
+		{name: "empty version", agentType: "NRJavaAgent", version: "", errMsg: "version is required"},
+		{name: "traversal", agentType: "..", version: "1.0.0", errMsg: `invalid agent type ".."`},
+		{name: "separator", agentType: "NRJavaAgent", version: "1.0/../../x", errMsg: "invalid version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Write(t.TempDir(), tt.agentType, tt.version, metadata)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
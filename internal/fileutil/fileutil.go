@@ -0,0 +1,72 @@
+package fileutil
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// NormalizePath converts a user-supplied path to the platform's separators and cleans it.
+// Both '/' and '\' are treated as separators so inputs written for one runner OS
+// (e.g. ".\dist\agent.zip" or "./dist/agent.zip") resolve the same way on every runner.
+func NormalizePath(path string) string {
+	if path == "" {
+		return ""
+	}
+	return filepath.Clean(filepath.FromSlash(strings.ReplaceAll(path, `\`, "/")))
+}
+
+// HasTraversal reports whether any segment of path is "..", using either separator
+func HasTraversal(path string) bool {
+	for _, segment := range strings.FieldsFunc(path, isSeparator) {
+		if segment == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRelative reports whether path is relative on this platform and on Windows, rejecting absolute paths,
+// rooted paths ("/x", "\x") and paths with a volume or drive ("C:x", "\\server\share")
+func IsRelative(path string) bool {
+	if path == "" {
+		return true
+	}
+	if filepath.IsAbs(path) || filepath.VolumeName(path) != "" || isSeparator(rune(path[0])) {
+		return false
+	}
+	// Drive letters only have a volume name on Windows, so check for them explicitly
+	return !(len(path) >= 2 && path[1] == ':' && isLetter(path[0]))
+}
+
+// IsLocal reports whether path, with either separator, is relative on every platform (see IsRelative) and stays
+// within the directory it is relative to (see filepath.IsLocal); names merely containing "..", like "a..b", are fine
+func IsLocal(path string) bool {
+	return IsRelative(path) && filepath.IsLocal(NormalizePath(path))
+}
+
+// IsWithin reports whether path is dir or inside dir once both are made absolute.
+// filepath.Rel compares case-insensitively on Windows, so differences in drive letter case don't matter.
+func IsWithin(dir, path string) (bool, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		// Different volumes
+		return false, nil
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)), nil
+}
+
+func isSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
+
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
@@ -0,0 +1,115 @@
+package fileutil
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"", ""},
+		{"./dist/agent.zip", filepath.Join("dist", "agent.zip")},
+		{`.\dist\agent.zip`, filepath.Join("dist", "agent.zip")},
+		{`dist\nested/agent.zip`, filepath.Join("dist", "nested", "agent.zip")},
+		{".fleetControl/", ".fleetControl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.expected, NormalizePath(tt.path))
+		})
+	}
+}
+
+func TestHasTraversal(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"dist/agent.zip", false},
+		{"dist/agent..zip", false},
+		{"../agent.zip", true},
+		{"dist/../../agent.zip", true},
+		{`..\agent.zip`, true},
+		{`dist\..\..\agent.zip`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.expected, HasTraversal(tt.path))
+		})
+	}
+}
+
+func TestIsRelative(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"dist/agent.zip", true},
+		{`dist\agent.zip`, true},
+		{"/etc/passwd", false},
+		{`\Windows\System32`, false},
+		{`C:\dist\agent.zip`, false},
+		{"C:dist", false},
+		{`\\server\share\agent.zip`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsRelative(tt.path))
+		})
+	}
+}
+
+func TestIsLocal(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"results.json", true},
+		{"out/a..b/results.json", true},
+		{"out/../results.json", true},
+		{"../results.json", false},
+		{`out\..\..\results.json`, false},
+		{"/tmp/results.json", false},
+		{"C:results.json", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsLocal(tt.path))
+		})
+	}
+}
+
+func TestIsWithin(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		path     string
+		expected bool
+	}{
+		{"directory itself", dir, true},
+		{"nested file", filepath.Join(dir, "schemas", "config.json"), true},
+		{"parent traversal", filepath.Join(dir, "..", "outside.json"), false},
+		{"sibling with shared prefix", dir + "-other", false},
+		{"dotted file name", filepath.Join(dir, "..config.json"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			within, err := IsWithin(dir, tt.path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, within)
+		})
+	}
+}
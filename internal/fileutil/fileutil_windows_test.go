@@ -0,0 +1,39 @@
+package fileutil
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizePath_Windows(t *testing.T) {
+	assert.Equal(t, `dist\agent.zip`, NormalizePath("./dist/agent.zip"))
+	assert.Equal(t, `dist\agent.zip`, NormalizePath(`.\dist\agent.zip`))
+	assert.Equal(t, `C:\dist\agent.zip`, NormalizePath("C:/dist/agent.zip"))
+}
+
+func TestIsWithin_WindowsDriveLetterCase(t *testing.T) {
+	dir := t.TempDir()
+	require.True(t, filepath.VolumeName(dir) != "")
+
+	// GITHUB_WORKSPACE and resolved paths can disagree on drive letter case
+	lowerDrive := strings.ToLower(dir[:1]) + dir[1:]
+	within, err := IsWithin(dir, filepath.Join(lowerDrive, "schemas", "config.json"))
+	require.NoError(t, err)
+	assert.True(t, within)
+}
+
+func TestIsWithin_WindowsOtherVolume(t *testing.T) {
+	dir := t.TempDir()
+	other := `Z:\outside.json`
+	if strings.EqualFold(filepath.VolumeName(dir), "Z:") {
+		other = `Y:\outside.json`
+	}
+
+	within, err := IsWithin(dir, other)
+	require.NoError(t, err)
+	assert.False(t, within)
+}
@@ -0,0 +1,133 @@
+package fixtures
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sample is a known-good, customer-style configuration committed as a fixture below the fixtures directory, in the
+// subdirectory named after the configuration definition type it configures, e.g. fixtures/agent-config/minimal.yml
+type Sample struct {
+	Path  string // relative to the workspace
+	Type  string
+	Value any
+}
+
+// Load reads the samples below dir, relative to workspace: the .yml, .yaml and .json files in its subdirectories
+// No directory gives no samples; a file outside a type subdirectory or one that can't be parsed is an error, since
+// a fixture that isn't checked would pass silently
+func Load(workspace, dir string) ([]Sample, error) {
+	root := filepath.Join(workspace, dir)
+	if _, err := os.Stat(root); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	var samples []Sample
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yml", ".yaml", ".json":
+		default:
+			return nil
+		}
+		relative, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		samplePath := filepath.ToSlash(filepath.Join(dir, relative))
+		typ, _, nested := strings.Cut(filepath.ToSlash(relative), "/")
+		if !nested {
+			return fmt.Errorf("fixture %s must be in a subdirectory named after the configuration definition type it configures", samplePath)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read fixture %s: %w", samplePath, err)
+		}
+		value, err := decode(data)
+		if err != nil {
+			return fmt.Errorf("invalid fixture %s: %w", samplePath, err)
+		}
+		samples = append(samples, Sample{Path: samplePath, Type: typ, Value: value})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Path < samples[j].Path })
+	return samples, nil
+}
+
+// decode parses a YAML or JSON document into the values encoding/json gives with UseNumber, so samples and schemas
+// compare the same whichever format they are written in
+func decode(data []byte) (any, error) {
+	var document any
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	if document == nil {
+		return nil, fmt.Errorf("the document is empty")
+	}
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("the document isn't representable as JSON: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Outcome is how a sample fares against the schema of the release and the schema of the previous release
+type Outcome string
+
+const (
+	OutcomeCompatible Outcome = "compatible" // valid against the new schema
+	OutcomeBreaks     Outcome = "breaks"     // valid against the previous schema, but not the new one
+	OutcomeInvalid    Outcome = "invalid"    // valid against neither schema, or against the new one without a previous
+	OutcomeFixed      Outcome = "fixed"      // valid against the new schema, but not the previous one
+)
+
+// Result is the outcome of checking a sample, with the violations of each schema
+type Result struct {
+	Outcome  Outcome
+	Errors   []string // violations of the new schema
+	Previous []string // violations of the previous schema
+}
+
+// Check validates value against the new schema and, if there is one, the previous schema of its configuration
+// definition
+func Check(value any, current, previous *Schema) Result {
+	result := Result{Errors: current.Validate(value)}
+	if previous != nil {
+		result.Previous = previous.Validate(value)
+	}
+	switch {
+	case len(result.Errors) == 0 && previous != nil && len(result.Previous) > 0:
+		result.Outcome = OutcomeFixed
+	case len(result.Errors) == 0:
+		result.Outcome = OutcomeCompatible
+	case previous != nil && len(result.Previous) == 0:
+		result.Outcome = OutcomeBreaks
+	default:
+		result.Outcome = OutcomeInvalid
+	}
+	return result
+}
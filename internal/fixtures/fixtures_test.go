@@ -0,0 +1,101 @@
+package fixtures
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFixture(t *testing.T, workspace, path, content string) {
+	t.Helper()
+	full := filepath.Join(workspace, ".fleetControl", "fixtures", path)
+	require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+	require.NoError(t, os.WriteFile(full, []byte(content), 0644))
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("samples by type", func(t *testing.T) {
+		workspace := t.TempDir()
+		writeFixture(t, workspace, "agent-config/minimal.yml", "license_key: abc\n")
+		writeFixture(t, workspace, "agent-config/customers/large.json", `{"license_key": "abc", "port": 8080}`)
+		writeFixture(t, workspace, "agent-config/README.md", "Samples from support tickets")
+
+		// method under test
+		samples, err := Load(workspace, ".fleetControl/fixtures")
+
+		require.NoError(t, err)
+		require.Len(t, samples, 2)
+		assert.Equal(t, ".fleetControl/fixtures/agent-config/customers/large.json", samples[0].Path)
+		assert.Equal(t, "agent-config", samples[0].Type)
+		assert.Equal(t, ".fleetControl/fixtures/agent-config/minimal.yml", samples[1].Path)
+		assert.Equal(t, map[string]any{"license_key": "abc"}, samples[1].Value)
+	})
+
+	t.Run("no fixtures directory", func(t *testing.T) {
+		// method under test
+		samples, err := Load(t.TempDir(), ".fleetControl/fixtures")
+
+		require.NoError(t, err)
+		assert.Empty(t, samples)
+	})
+
+	t.Run("sample outside a type directory", func(t *testing.T) {
+		workspace := t.TempDir()
+		writeFixture(t, workspace, "minimal.yml", "license_key: abc\n")
+
+		// method under test
+		_, err := Load(workspace, ".fleetControl/fixtures")
+
+		assert.EqualError(t, err, "fixture .fleetControl/fixtures/minimal.yml must be in a subdirectory named after the configuration definition type it configures")
+	})
+
+	t.Run("empty sample", func(t *testing.T) {
+		workspace := t.TempDir()
+		writeFixture(t, workspace, "agent-config/empty.yml", "")
+
+		// method under test
+		_, err := Load(workspace, ".fleetControl/fixtures")
+
+		assert.EqualError(t, err, "invalid fixture .fleetControl/fixtures/agent-config/empty.yml: the document is empty")
+	})
+}
+
+func TestCheck(t *testing.T) {
+	previous, err := ParseSchema([]byte(`{"properties": {"log_level": {"type": "string"}}}`))
+	require.NoError(t, err)
+	current, err := ParseSchema([]byte(`{"properties": {"log_level": {"enum": ["debug", "info", "trace"]}}, "required": ["log_level"]}`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		value    any
+		previous *Schema
+		outcome  Outcome
+	}{
+		{name: "compatible", value: map[string]any{"log_level": "info"}, previous: previous, outcome: OutcomeCompatible},
+		{name: "breaks", value: map[string]any{"log_level": "warn"}, previous: previous, outcome: OutcomeBreaks},
+		{name: "fixed", value: map[string]any{"log_level": "trace", "extra": true}, previous: mustParse(t, `{"additionalProperties": false, "properties": {"log_level": {}}}`), outcome: OutcomeFixed},
+		{name: "invalid against both", value: map[string]any{"log_level": json.Number("3")}, previous: previous, outcome: OutcomeInvalid},
+		{name: "invalid without a previous schema", value: map[string]any{}, outcome: OutcomeInvalid},
+		{name: "compatible without a previous schema", value: map[string]any{"log_level": "debug"}, outcome: OutcomeCompatible},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			result := Check(tt.value, current, tt.previous)
+
+			assert.Equal(t, tt.outcome, result.Outcome)
+		})
+	}
+}
+
+func mustParse(t *testing.T, schema string) *Schema {
+	t.Helper()
+	parsed, err := ParseSchema([]byte(schema))
+	require.NoError(t, err)
+	return parsed
+}
@@ -0,0 +1,421 @@
+package fixtures
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a JSON Schema a configuration definition validates configurations with
+type Schema struct {
+	root any
+}
+
+// ParseSchema parses a JSON Schema given as JSON or YAML
+func ParseSchema(data []byte) (*Schema, error) {
+	root, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	switch root.(type) {
+	case map[string]any, bool:
+		return &Schema{root: root}, nil
+	}
+	return nil, fmt.Errorf("a schema must be an object or a boolean, got %s", typeName(root))
+}
+
+// Validate returns every violation of the schema by value, decoded as by decode, each prefixed with the path of the
+// offending value
+// Supports the keywords configuration schemas use: $ref within the schema, type, enum, const, required,
+// properties, patternProperties, additionalProperties, items, prefixItems, minItems, maxItems, minLength,
+// maxLength, pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum, allOf, anyOf, oneOf, not and
+// if/then/else; other keywords, such as format, are ignored
+func (s *Schema) Validate(value any) []string {
+	return s.validate(s.root, value, "$", 0)
+}
+
+func (s *Schema) validate(raw any, value any, at string, depth int) []string {
+	if depth > 64 {
+		return []string{fmt.Sprintf("%s: schema references are nested too deeply", at)}
+	}
+	if allowed, ok := raw.(bool); ok {
+		if allowed {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s is not allowed", at)}
+	}
+	schema, _ := raw.(map[string]any)
+	if len(schema) == 0 {
+		return nil
+	}
+
+	var violations []string
+	if ref, ok := schema["$ref"].(string); ok {
+		resolved, err := s.resolve(ref)
+		if err != nil {
+			return []string{fmt.Sprintf("%s: %v", at, err)}
+		}
+		violations = append(violations, s.validate(resolved, value, at, depth+1)...)
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !hasAnyType(value, types) {
+		return append(violations, fmt.Sprintf("%s must be of type %s, got %s", at, strings.Join(types, " or "), typeName(value)))
+	}
+	if enum, ok := schema["enum"].([]any); ok && !inEnum(value, enum) {
+		violations = append(violations, fmt.Sprintf("%s must be one of %s, got %s", at, formatValues(enum), formatValue(value)))
+	}
+	if constant, ok := schema["const"]; ok && !equal(value, constant) {
+		violations = append(violations, fmt.Sprintf("%s must be %s, got %s", at, formatValue(constant), formatValue(value)))
+	}
+
+	switch v := value.(type) {
+	case string:
+		violations = append(violations, validateString(schema, v, at)...)
+	case json.Number:
+		violations = append(violations, validateNumber(schema, v, at)...)
+	case []any:
+		violations = append(violations, s.validateArray(schema, v, at, depth)...)
+	case map[string]any:
+		violations = append(violations, s.validateObject(schema, v, at, depth)...)
+	}
+
+	violations = append(violations, s.validateCombinators(schema, value, at, depth)...)
+	return violations
+}
+
+func validateString(schema map[string]any, value, at string) []string {
+	var violations []string
+	length := utf8.RuneCountInString(value)
+	if minLength, ok := integer(schema["minLength"]); ok && length < minLength {
+		violations = append(violations, fmt.Sprintf("%s must be at least %d characters", at, minLength))
+	}
+	if maxLength, ok := integer(schema["maxLength"]); ok && length > maxLength {
+		violations = append(violations, fmt.Sprintf("%s must be at most %d characters", at, maxLength))
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			violations = append(violations, fmt.Sprintf("%s: invalid pattern %q in schema: %v", at, pattern, err))
+		} else if !re.MatchString(value) {
+			violations = append(violations, fmt.Sprintf("%s %q does not match pattern %s", at, value, pattern))
+		}
+	}
+	return violations
+}
+
+func validateNumber(schema map[string]any, value json.Number, at string) []string {
+	n, ok := number(value)
+	if !ok {
+		return nil
+	}
+	var violations []string
+	bounds := []struct {
+		keyword string
+		fails   func(cmp int) bool
+		message string
+	}{
+		{"minimum", func(cmp int) bool { return cmp < 0 }, "at least"},
+		{"maximum", func(cmp int) bool { return cmp > 0 }, "at most"},
+		{"exclusiveMinimum", func(cmp int) bool { return cmp <= 0 }, "greater than"},
+		{"exclusiveMaximum", func(cmp int) bool { return cmp >= 0 }, "less than"},
+	}
+	for _, bound := range bounds {
+		limit, ok := number(schema[bound.keyword])
+		if ok && bound.fails(n.Cmp(limit)) {
+			violations = append(violations, fmt.Sprintf("%s must be %s %s, got %s", at, bound.message, limit.RatString(), value))
+		}
+	}
+	return violations
+}
+
+func (s *Schema) validateArray(schema map[string]any, items []any, at string, depth int) []string {
+	var violations []string
+	if minItems, ok := integer(schema["minItems"]); ok && len(items) < minItems {
+		violations = append(violations, fmt.Sprintf("%s must have at least %d items", at, minItems))
+	}
+	if maxItems, ok := integer(schema["maxItems"]); ok && len(items) > maxItems {
+		violations = append(violations, fmt.Sprintf("%s must have at most %d items", at, maxItems))
+	}
+	prefix, _ := schema["prefixItems"].([]any)
+	for i, item := range items {
+		itemAt := fmt.Sprintf("%s[%d]", at, i)
+		if i < len(prefix) {
+			violations = append(violations, s.validate(prefix[i], item, itemAt, depth+1)...)
+		} else if itemSchema, ok := schema["items"]; ok {
+			violations = append(violations, s.validate(itemSchema, item, itemAt, depth+1)...)
+		}
+	}
+	return violations
+}
+
+func (s *Schema) validateObject(schema map[string]any, object map[string]any, at string, depth int) []string {
+	var violations []string
+	required, _ := schema["required"].([]any)
+	for _, name := range required {
+		if _, ok := object[fmt.Sprint(name)]; !ok {
+			violations = append(violations, fmt.Sprintf("%s is missing required property %s", at, name))
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]any)
+	patterns, _ := schema["patternProperties"].(map[string]any)
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	// Sorted so violations are reported in a stable order
+	sort.Strings(names)
+
+	for _, name := range names {
+		propertyAt := at + "." + name
+		matched := false
+		if propertySchema, ok := properties[name]; ok {
+			matched = true
+			violations = append(violations, s.validate(propertySchema, object[name], propertyAt, depth+1)...)
+		}
+		for pattern, patternSchema := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil || !re.MatchString(name) {
+				continue
+			}
+			matched = true
+			violations = append(violations, s.validate(patternSchema, object[name], propertyAt, depth+1)...)
+		}
+		if matched {
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				violations = append(violations, fmt.Sprintf("%s has unexpected property %s", at, name))
+			}
+		case map[string]any:
+			violations = append(violations, s.validate(additional, object[name], propertyAt, depth+1)...)
+		}
+	}
+	return violations
+}
+
+func (s *Schema) validateCombinators(schema map[string]any, value any, at string, depth int) []string {
+	var violations []string
+	if allOf, ok := schema["allOf"].([]any); ok {
+		for _, sub := range allOf {
+			violations = append(violations, s.validate(sub, value, at, depth+1)...)
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		var errs []string
+		for _, sub := range anyOf {
+			subViolations := s.validate(sub, value, at, depth+1)
+			if len(subViolations) == 0 {
+				errs = nil
+				break
+			}
+			errs = append(errs, subViolations...)
+		}
+		if len(errs) > 0 {
+			violations = append(violations, fmt.Sprintf("%s matches none of anyOf: %s", at, strings.Join(errs, "; ")))
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		var errs []string
+		matched := 0
+		for _, sub := range oneOf {
+			subViolations := s.validate(sub, value, at, depth+1)
+			if len(subViolations) == 0 {
+				matched++
+			}
+			errs = append(errs, subViolations...)
+		}
+		switch {
+		case matched == 0:
+			violations = append(violations, fmt.Sprintf("%s matches none of oneOf: %s", at, strings.Join(errs, "; ")))
+		case matched > 1:
+			violations = append(violations, fmt.Sprintf("%s matches %d schemas of oneOf, expected exactly one", at, matched))
+		}
+	}
+	if not, ok := schema["not"]; ok && len(s.validate(not, value, at, depth+1)) == 0 {
+		violations = append(violations, fmt.Sprintf("%s must not match the schema of not", at))
+	}
+	if condition, ok := schema["if"]; ok {
+		if len(s.validate(condition, value, at, depth+1)) == 0 {
+			violations = append(violations, s.validate(schema["then"], value, at, depth+1)...)
+		} else {
+			violations = append(violations, s.validate(schema["else"], value, at, depth+1)...)
+		}
+	}
+	return violations
+}
+
+// resolve follows a $ref to a location within the schema, given as a JSON pointer fragment such as #/$defs/port
+func (s *Schema) resolve(ref string) (any, error) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("unsupported schema reference %s: only references within the schema are resolved", ref)
+	}
+	current := s.root
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable schema reference %s", ref)
+		}
+		if current, ok = object[token]; !ok {
+			return nil, fmt.Errorf("unresolvable schema reference %s", ref)
+		}
+	}
+	return current, nil
+}
+
+// schemaTypes returns the types the type keyword allows, given as a string or a list
+func schemaTypes(raw any) []string {
+	switch t := raw.(type) {
+	case string:
+		return []string{t}
+	case []any:
+		types := make([]string, 0, len(t))
+		for _, typ := range t {
+			types = append(types, fmt.Sprint(typ))
+		}
+		return types
+	}
+	return nil
+}
+
+func hasAnyType(value any, types []string) bool {
+	for _, typ := range types {
+		if hasType(value, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasType(value any, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		n, ok := number(value)
+		return ok && n.IsInt()
+	}
+	return true
+}
+
+func typeName(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// number returns a JSON number as an exact rational, so integers and decimals compare without rounding
+func number(value any) (*big.Rat, bool) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return nil, false
+	}
+	return new(big.Rat).SetString(n.String())
+}
+
+func integer(value any) (int, bool) {
+	n, ok := value.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := n.Int64()
+	return int(i), err == nil
+}
+
+func inEnum(value any, enum []any) bool {
+	for _, allowed := range enum {
+		if equal(value, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// equal compares decoded values as JSON does, so 1 and 1.0 are the same number
+func equal(a, b any) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x.Cmp(y) == 0
+	}
+	switch x := a.(type) {
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, ok := y[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func formatValue(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func formatValues(values []any) string {
+	formatted := make([]string, len(values))
+	for i, value := range values {
+		formatted[i] = formatValue(value)
+	}
+	return "[" + strings.Join(formatted, ", ") + "]"
+}
@@ -0,0 +1,88 @@
+package fixtures
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := ParseSchema([]byte(`{
+		"$defs": {"port": {"type": "integer", "minimum": 1, "maximum": 65535}},
+		"type": "object",
+		"required": ["license_key"],
+		"properties": {
+			"license_key": {"type": "string", "minLength": 1, "pattern": "^[a-z0-9]+$"},
+			"port": {"$ref": "#/$defs/port"},
+			"ratio": {"type": "number", "exclusiveMaximum": 1},
+			"labels": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+			"proxy": {"oneOf": [{"type": "string"}, {"type": "object", "required": ["host"]}]},
+			"mode": {"const": "standard"}
+		},
+		"patternProperties": {"^x_": {"type": "boolean"}},
+		"additionalProperties": false
+	}`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		sample     string
+		violations []string
+	}{
+		{name: "valid", sample: "license_key: abc\nport: 8080\nratio: 0.5\nlabels: [a, b]\nproxy: {host: example.com}\nmode: standard\nx_debug: true\n"},
+		{name: "integer written as a decimal", sample: "license_key: abc\nport: 8080.0\n"},
+		{name: "missing required property", sample: "port: 80\n", violations: []string{"$ is missing required property license_key"}},
+		{name: "every violation is reported", sample: "license_key: ABC\nport: 0\nlabels: [a, 1, c]\n", violations: []string{
+			"$.labels must have at most 2 items",
+			"$.labels[1] must be of type string, got number",
+			`$.license_key "ABC" does not match pattern ^[a-z0-9]+$`,
+			"$.port must be at least 1, got 0",
+		}},
+		{name: "unexpected property", sample: "license_key: abc\nhost: example.com\n", violations: []string{"$ has unexpected property host"}},
+		{name: "pattern property", sample: "license_key: abc\nx_debug: yes please\n", violations: []string{"$.x_debug must be of type boolean, got string"}},
+		{name: "exclusive bound", sample: "license_key: abc\nratio: 1\n", violations: []string{"$.ratio must be less than 1, got 1"}},
+		{name: "const", sample: "license_key: abc\nmode: fast\n", violations: []string{`$.mode must be "standard", got "fast"`}},
+		{name: "oneOf", sample: "license_key: abc\nproxy: 3\n", violations: []string{
+			"$.proxy matches none of oneOf: $.proxy must be of type string, got number; $.proxy must be of type object, got number",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := decode([]byte(tt.sample))
+			require.NoError(t, err)
+
+			// method under test
+			violations := schema.Validate(value)
+
+			assert.Equal(t, tt.violations, violations)
+		})
+	}
+
+	t.Run("unresolvable reference", func(t *testing.T) {
+		schema, err := ParseSchema([]byte(`{"$ref": "https://example.com/schema.json"}`))
+		require.NoError(t, err)
+
+		// method under test
+		violations := schema.Validate(map[string]any{})
+
+		assert.Equal(t, []string{"$: unsupported schema reference https://example.com/schema.json: only references within the schema are resolved"}, violations)
+	})
+}
+
+func TestParseSchema(t *testing.T) {
+	t.Run("YAML schema", func(t *testing.T) {
+		// method under test
+		schema, err := ParseSchema([]byte("type: object\nrequired: [name]\n"))
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"$ is missing required property name"}, schema.Validate(map[string]any{}))
+	})
+
+	t.Run("not a schema", func(t *testing.T) {
+		// method under test
+		_, err := ParseSchema([]byte(`["type"]`))
+
+		assert.EqualError(t, err, "a schema must be an object or a boolean, got array")
+	})
+}
@@ -0,0 +1,77 @@
+package github
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// diskCacheEntry is a cached response as stored on disk
+// The URL is kept so an entry is only used for the request it was stored for
+type diskCacheEntry struct {
+	URL  string `json:"url"`
+	ETag string `json:"etag"`
+	Body []byte `json:"body"`
+}
+
+// SetCacheDir persists the ETag cache of content fetches to dir, so later runs on the same runner (other steps of
+// the job) send conditional requests for files they have already fetched instead of downloading them again
+// Responses that aren't file content, such as pull request file lists, are only cached in memory
+func (c *Client) SetCacheDir(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cacheDir = dir
+}
+
+// isContentURL reports whether a request URL fetches a file through the contents API
+func isContentURL(url string) bool {
+	return strings.Contains(url, "/contents/")
+}
+
+// cachePath returns the file a response for url is stored in
+func cachePath(dir, url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// readCached returns the response for url stored in dir, if any
+// Unreadable entries are ignored; they are replaced by the next response
+func readCached(dir, url string) (cachedResponse, bool) {
+	data, err := os.ReadFile(cachePath(dir, url))
+	if err != nil {
+		return cachedResponse{}, false
+	}
+	var entry diskCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != url || entry.ETag == "" {
+		return cachedResponse{}, false
+	}
+	return cachedResponse{etag: entry.ETag, body: entry.Body}, true
+}
+
+// writeCached stores the response for url in dir
+// The entry is written to a temporary file and renamed, so concurrent runs never read a partial entry
+func writeCached(dir, url string, response cachedResponse) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(diskCacheEntry{URL: url, ETag: response.etag, Body: response.body})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "entry-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), cachePath(dir, url))
+}
@@ -0,0 +1,254 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"agent-metadata-action/internal/config"
+)
+
+// CheckRunName is the name of the check run shown in the GitHub Checks tab
+const CheckRunName = "Agent Metadata Validation"
+
+// maxAnnotationsPerRequest is the GitHub API limit on annotations per check run create/update call
+const maxAnnotationsPerRequest = 50
+
+// AnnotationLevel is the severity of a check run annotation
+type AnnotationLevel string
+
+const (
+	AnnotationNotice  AnnotationLevel = "notice"
+	AnnotationWarning AnnotationLevel = "warning"
+	AnnotationFailure AnnotationLevel = "failure"
+)
+
+// Check run conclusions
+const (
+	ConclusionSuccess = "success"
+	ConclusionNeutral = "neutral"
+	ConclusionFailure = "failure"
+)
+
+// Annotation is a single finding attached to a file in a check run
+type Annotation struct {
+	Path            string          `json:"path"`
+	StartLine       int             `json:"start_line"`
+	EndLine         int             `json:"end_line"`
+	AnnotationLevel AnnotationLevel `json:"annotation_level"`
+	Title           string          `json:"title,omitempty"`
+	StartColumn     int             `json:"start_column,omitempty"` // only sent by GitHub when the annotation spans one line
+	Message         string          `json:"message"`
+	Rule            string          `json:"-"` // identifies the kind of finding in SARIF; derived from Title if empty
+}
+
+// RuleID returns the rule the annotation reports, or its title in kebab case if no rule is set
+func (a Annotation) RuleID() string {
+	if a.Rule != "" {
+		return a.Rule
+	}
+	title := a.Title
+	if title == "" {
+		title = string(a.AnnotationLevel)
+	}
+	var b strings.Builder
+	for _, field := range strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(field)
+	}
+	return b.String()
+}
+
+// AnnotationCollector gathers annotations during a run so they can be published as a check run
+type AnnotationCollector struct {
+	mu          sync.Mutex
+	annotations []Annotation
+}
+
+// NewAnnotationCollector creates an empty annotation collector
+func NewAnnotationCollector() *AnnotationCollector {
+	return &AnnotationCollector{}
+}
+
+// Add records an annotation, defaulting to line 1 when no line is known
+func (c *AnnotationCollector) Add(annotation Annotation) {
+	if annotation.StartLine < 1 {
+		annotation.StartLine = 1
+	}
+	if annotation.EndLine < annotation.StartLine {
+		annotation.EndLine = annotation.StartLine
+	}
+	annotation.Path = filepath.ToSlash(annotation.Path)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.annotations = append(c.annotations, annotation)
+}
+
+// Annotations returns a copy of the collected annotations
+func (c *AnnotationCollector) Annotations() []Annotation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Annotation(nil), c.annotations...)
+}
+
+type annotationCollectorKey struct{}
+
+// WithAnnotationCollector returns a context carrying the annotation collector
+func WithAnnotationCollector(ctx context.Context, collector *AnnotationCollector) context.Context {
+	return context.WithValue(ctx, annotationCollectorKey{}, collector)
+}
+
+// AnnotationCollectorFromContext returns the annotation collector in the context, or nil
+func AnnotationCollectorFromContext(ctx context.Context) *AnnotationCollector {
+	collector, _ := ctx.Value(annotationCollectorKey{}).(*AnnotationCollector)
+	return collector
+}
+
+// AddAnnotation records an annotation on the collector in the context
+// No-op if the context has no collector
+func AddAnnotation(ctx context.Context, level AnnotationLevel, path, title, message string) {
+	RecordAnnotation(ctx, Annotation{Path: path, AnnotationLevel: level, Title: title, Message: message})
+}
+
+// RecordAnnotation records a fully specified annotation on the collector in the context
+// No-op if the context has no collector
+func RecordAnnotation(ctx context.Context, annotation Annotation) {
+	collector := AnnotationCollectorFromContext(ctx)
+	if collector == nil {
+		return
+	}
+	collector.Add(annotation)
+}
+
+// AddWorkflowAnnotation records an annotation on the running workflow file, where inputs such as binaries are set
+// No-op if the workflow file is unknown (outside GitHub Actions) or the context has no collector
+func AddWorkflowAnnotation(ctx context.Context, level AnnotationLevel, title, message string) {
+	path := config.GetWorkflowFile()
+	if path == "" {
+		return
+	}
+	AddAnnotation(ctx, level, path, title, message)
+}
+
+// RelativeToWorkspace converts an absolute path inside the workspace into a repository-relative path
+// Paths outside the workspace are returned unchanged
+func RelativeToWorkspace(workspace, path string) string {
+	if workspace == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	rel, err := filepath.Rel(workspace, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
+
+// CheckConclusion derives the check run conclusion from the run error and annotations
+// Returns failure if the run failed or any failure annotation exists, neutral if there are warnings
+func CheckConclusion(runErr error, annotations []Annotation) string {
+	if runErr != nil {
+		return ConclusionFailure
+	}
+	conclusion := ConclusionSuccess
+	for _, a := range annotations {
+		switch a.AnnotationLevel {
+		case AnnotationFailure:
+			return ConclusionFailure
+		case AnnotationWarning:
+			conclusion = ConclusionNeutral
+		}
+	}
+	return conclusion
+}
+
+type checkRunOutput struct {
+	Title       string       `json:"title"`
+	Summary     string       `json:"summary"`
+	Annotations []Annotation `json:"annotations,omitempty"`
+}
+
+type checkRunRequest struct {
+	Name        string          `json:"name,omitempty"`
+	HeadSHA     string          `json:"head_sha,omitempty"`
+	Status      string          `json:"status,omitempty"`
+	Conclusion  string          `json:"conclusion,omitempty"`
+	CompletedAt string          `json:"completed_at,omitempty"`
+	Output      *checkRunOutput `json:"output,omitempty"`
+}
+
+type checkRunResponse struct {
+	ID      int64  `json:"id"`
+	HTMLURL string `json:"html_url"`
+}
+
+// PublishCheckRun creates a completed check run on headSHA with the given conclusion and annotations
+// POST /repos/{owner}/{repo}/check-runs
+// Annotations beyond the per-request limit are appended with follow-up PATCH calls
+// Returns the check run URL on success
+func (c *Client) PublishCheckRun(ctx context.Context, repo, headSHA, conclusion, summary string, annotations []Annotation) (string, error) {
+	if repo == "" {
+		return "", fmt.Errorf("repository is required")
+	}
+	if headSHA == "" {
+		return "", fmt.Errorf("head SHA is required")
+	}
+
+	title := fmt.Sprintf("%d finding(s)", len(annotations))
+	batches := batchAnnotations(annotations)
+
+	createReq := checkRunRequest{
+		Name:        CheckRunName,
+		HeadSHA:     headSHA,
+		Status:      "completed",
+		Conclusion:  conclusion,
+		CompletedAt: time.Now().UTC().Format(time.RFC3339),
+		Output: &checkRunOutput{
+			Title:       title,
+			Summary:     summary,
+			Annotations: batches[0],
+		},
+	}
+
+	var created checkRunResponse
+	if err := c.Do(ctx, "POST", fmt.Sprintf("/repos/%s/check-runs", repo), createReq, &created); err != nil {
+		return "", fmt.Errorf("failed to create check run: %w", err)
+	}
+
+	for _, batch := range batches[1:] {
+		updateReq := checkRunRequest{
+			Output: &checkRunOutput{
+				Title:       title,
+				Summary:     summary,
+				Annotations: batch,
+			},
+		}
+		if err := c.Do(ctx, "PATCH", fmt.Sprintf("/repos/%s/check-runs/%d", repo, created.ID), updateReq, nil); err != nil {
+			return created.HTMLURL, fmt.Errorf("failed to add annotations to check run %d: %w", created.ID, err)
+		}
+	}
+
+	return created.HTMLURL, nil
+}
+
+// batchAnnotations splits annotations into chunks the API accepts; always returns at least one (possibly empty) batch
+func batchAnnotations(annotations []Annotation) [][]Annotation {
+	batches := [][]Annotation{nil}
+	for i := 0; i < len(annotations); i += maxAnnotationsPerRequest {
+		end := min(i+maxAnnotationsPerRequest, len(annotations))
+		if i == 0 {
+			batches[0] = annotations[i:end]
+			continue
+		}
+		batches = append(batches, annotations[i:end])
+	}
+	return batches
+}
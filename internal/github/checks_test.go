@@ -0,0 +1,167 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotationCollector_Add(t *testing.T) {
+	collector := NewAnnotationCollector()
+	collector.Add(Annotation{Path: "a/b.yml", AnnotationLevel: AnnotationWarning, Message: "msg"})
+	collector.Add(Annotation{Path: "c.mdx", StartLine: 5, AnnotationLevel: AnnotationFailure, Message: "msg"})
+
+	annotations := collector.Annotations()
+	require.Len(t, annotations, 2)
+	assert.Equal(t, 1, annotations[0].StartLine)
+	assert.Equal(t, 1, annotations[0].EndLine)
+	assert.Equal(t, 5, annotations[1].StartLine)
+	assert.Equal(t, 5, annotations[1].EndLine)
+}
+
+func TestAddAnnotation_Context(t *testing.T) {
+	// No collector in context - must not panic
+	AddAnnotation(context.Background(), AnnotationWarning, "file.yml", "title", "message")
+
+	collector := NewAnnotationCollector()
+	ctx := WithAnnotationCollector(context.Background(), collector)
+	AddAnnotation(ctx, AnnotationWarning, "file.yml", "title", "message")
+
+	annotations := collector.Annotations()
+	require.Len(t, annotations, 1)
+	assert.Equal(t, "file.yml", annotations[0].Path)
+	assert.Equal(t, "title", annotations[0].Title)
+	assert.Equal(t, AnnotationWarning, annotations[0].AnnotationLevel)
+}
+
+func TestAnnotation_RuleID(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation Annotation
+		expected   string
+	}{
+		{"explicit rule", Annotation{Rule: "lint/enum-naming", Title: "Lint: enum-naming"}, "lint/enum-naming"},
+		{"derived from title", Annotation{Title: "Invalid MDX file"}, "invalid-mdx-file"},
+		{"punctuation collapsed", Annotation{Title: "Schema not loaded: (remote)"}, "schema-not-loaded-remote"},
+		{"no title", Annotation{AnnotationLevel: AnnotationWarning}, "warning"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.annotation.RuleID())
+		})
+	}
+}
+
+func TestRelativeToWorkspace(t *testing.T) {
+	tests := []struct {
+		name      string
+		workspace string
+		path      string
+		expected  string
+	}{
+		{"absolute path inside workspace", "/work", "/work/docs/a.mdx", "docs/a.mdx"},
+		{"absolute path outside workspace", "/work", "/other/a.mdx", "/other/a.mdx"},
+		{"relative path unchanged", "/work", "docs/a.mdx", "docs/a.mdx"},
+		{"empty workspace", "", "/work/a.mdx", "/work/a.mdx"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, RelativeToWorkspace(tt.workspace, tt.path))
+		})
+	}
+}
+
+func TestCheckConclusion(t *testing.T) {
+	tests := []struct {
+		name        string
+		runErr      error
+		annotations []Annotation
+		expected    string
+	}{
+		{"no findings", nil, nil, ConclusionSuccess},
+		{"notices only", nil, []Annotation{{AnnotationLevel: AnnotationNotice}}, ConclusionSuccess},
+		{"warnings", nil, []Annotation{{AnnotationLevel: AnnotationWarning}}, ConclusionNeutral},
+		{"failure annotation", nil, []Annotation{{AnnotationLevel: AnnotationWarning}, {AnnotationLevel: AnnotationFailure}}, ConclusionFailure},
+		{"run error", errors.New("boom"), nil, ConclusionFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, CheckConclusion(tt.runErr, tt.annotations))
+		})
+	}
+}
+
+func TestPublishCheckRun_BatchesAnnotations(t *testing.T) {
+	var posts, patches int
+	var received []Annotation
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer gh-token", r.Header.Get("Authorization"))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req checkRunRequest
+		require.NoError(t, json.Unmarshal(body, &req))
+		received = append(received, req.Output.Annotations...)
+
+		switch r.Method {
+		case "POST":
+			posts++
+			assert.Equal(t, "/repos/newrelic/test-repo/check-runs", r.URL.Path)
+			assert.Equal(t, CheckRunName, req.Name)
+			assert.Equal(t, "abc123", req.HeadSHA)
+			assert.Equal(t, ConclusionNeutral, req.Conclusion)
+			_, _ = w.Write([]byte(`{"id": 42, "html_url": "https://github.com/checks/42"}`))
+		case "PATCH":
+			patches++
+			assert.Equal(t, "/repos/newrelic/test-repo/check-runs/42", r.URL.Path)
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	annotations := make([]Annotation, 0, 120)
+	for i := 0; i < 120; i++ {
+		annotations = append(annotations, Annotation{Path: fmt.Sprintf("f%d.yml", i), StartLine: 1, EndLine: 1, AnnotationLevel: AnnotationWarning, Message: "m"})
+	}
+
+	client := NewClient(server.URL, "gh-token")
+	url, err := client.PublishCheckRun(context.Background(), "newrelic/test-repo", "abc123", ConclusionNeutral, "summary", annotations)
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/checks/42", url)
+	assert.Equal(t, 1, posts)
+	assert.Equal(t, 2, patches)
+	assert.Len(t, received, 120)
+}
+
+func TestPublishCheckRun_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"message": "Resource not accessible by integration"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "gh-token")
+
+	_, err := client.PublishCheckRun(context.Background(), "", "abc123", ConclusionSuccess, "summary", nil)
+	assert.ErrorContains(t, err, "repository is required")
+
+	_, err = client.PublishCheckRun(context.Background(), "newrelic/test-repo", "", ConclusionSuccess, "summary", nil)
+	assert.ErrorContains(t, err, "head SHA is required")
+
+	_, err = client.PublishCheckRun(context.Background(), "newrelic/test-repo", "abc123", ConclusionSuccess, "summary", nil)
+	assert.ErrorContains(t, err, "failed to create check run")
+	assert.ErrorContains(t, err, "status 403")
+}
@@ -0,0 +1,414 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"agent-metadata-action/internal/config"
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/retry"
+)
+
+// maxRateLimitWait is the longest the client will sleep waiting for a rate limit to reset
+// Longer waits fail fast instead of stalling the workflow
+const maxRateLimitWait = 1 * time.Minute
+
+// linkNextRegex extracts the rel="next" URL from a GitHub Link header
+var linkNextRegex = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// Client is a GitHub REST API client with retries, rate-limit handling and ETag caching
+type Client struct {
+	baseURL     string
+	token       string
+	httpClient  *http.Client
+	retryConfig retry.Config
+
+	mu                 sync.Mutex
+	etagCache          map[string]cachedResponse
+	cacheDir           string // where content fetches are also cached, if set
+	rateLimitRemaining int
+	rateLimitReset     time.Time
+}
+
+// cachedResponse is a GET response body stored with its ETag for conditional requests
+type cachedResponse struct {
+	etag string
+	body []byte
+}
+
+// APIError is a GitHub API response with a non-2xx status
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string // truncated to 500 characters
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("GitHub API %s %s failed with status %d: %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+// Response is a completed GitHub API response
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// FromCache is true when the server answered 304 Not Modified and Body came from the ETag cache
+	FromCache bool
+}
+
+var (
+	defaultClient     *Client
+	defaultClientOnce sync.Once
+)
+
+// GetClient returns the shared GitHub API client configured from the environment
+func GetClient() *Client {
+	defaultClientOnce.Do(func() {
+		defaultClient = NewClient(config.GetGitHubAPIURL(), config.GetGitHubToken())
+		if runnerTemp := config.GetRunnerTemp(); runnerTemp != "" {
+			defaultClient.SetCacheDir(filepath.Join(runnerTemp, "agent-metadata-action", "github-cache"))
+		}
+	})
+	return defaultClient
+}
+
+// NewClient creates a new GitHub API client
+// baseURL: GitHub API base URL (e.g., "https://api.github.com")
+// token: GitHub token used for Bearer authentication
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		retryConfig: retry.Config{
+			MaxAttempts: 3,
+			BaseDelay:   2 * time.Second,
+			Operation:   "GitHub API request",
+		},
+		etagCache:          map[string]cachedResponse{},
+		rateLimitRemaining: -1,
+	}
+}
+
+// HasToken reports whether the client has a token to authenticate with
+func (c *Client) HasToken() bool {
+	return c.token != ""
+}
+
+// Do sends a request to the GitHub API with body marshaled as JSON (if non-nil)
+// and decodes the JSON response into out (if non-nil)
+// Returns error on non-2xx status codes
+func (c *Client) Do(ctx context.Context, method, path string, body any, out any) error {
+	resp, err := c.Request(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	if out != nil && len(resp.Body) > 0 {
+		if err := json.Unmarshal(resp.Body, out); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Request sends a request to the GitHub API and returns the raw response
+// path may be relative to the base URL or an absolute URL (e.g., from a Link header); the token is only sent to
+// absolute URLs on the scheme and host of the base URL
+// Retries server errors and rate limiting; GET requests use ETags for conditional requests
+func (c *Client) Request(ctx context.Context, method, path string, body any) (*Response, error) {
+	var jsonBody []byte
+	if body != nil {
+		var err error
+		jsonBody, err = json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+	}
+
+	url := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		url = c.baseURL + path
+	}
+
+	var result *Response
+	err := retry.Do(ctx, c.retryConfig, func() error {
+		resp, err := c.send(ctx, method, url, jsonBody)
+		if err != nil {
+			return err
+		}
+		result = resp
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// send executes a single attempt, classifying failures as retryable or not
+func (c *Client) send(ctx context.Context, method, url string, jsonBody []byte) (*Response, error) {
+	if err := c.waitForRateLimit(ctx); err != nil {
+		return nil, retry.NewNonRetryableError(err)
+	}
+
+	var reqBody io.Reader
+	if jsonBody != nil {
+		reqBody = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if jsonBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		if c.isAPIHost(req.URL) {
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+		} else {
+			logging.Debugf(ctx, "Not sending the GitHub token to %s, which isn't on the API host %s", req.URL.Host, c.baseURL)
+		}
+	}
+
+	cached, hasCached := c.cachedFor(method, url)
+	if hasCached {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("GitHub API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	c.updateRateLimit(resp.Header)
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusNotModified && hasCached {
+		logging.Debugf(ctx, "GitHub API %s %s not modified - using cached response", method, url)
+		return &Response{StatusCode: http.StatusOK, Header: resp.Header, Body: cached.body, FromCache: true}, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		preview := string(respBody)
+		if len(preview) > 500 {
+			preview = preview[:500] + "... (truncated)"
+		}
+		apiErr := &APIError{Method: method, URL: url, StatusCode: resp.StatusCode, Body: preview}
+
+		if wait, limited := rateLimitWait(resp); limited {
+			if wait > maxRateLimitWait {
+				return nil, retry.NewNonRetryableError(fmt.Errorf("rate limited for %s (longer than %s): %w", wait, maxRateLimitWait, apiErr))
+			}
+			logging.Warnf(ctx, "GitHub API rate limited - waiting %s before retrying", wait)
+			if err := sleepContext(ctx, wait); err != nil {
+				return nil, retry.NewNonRetryableError(err)
+			}
+			return nil, apiErr
+		}
+
+		// Retry on: 5xx (server errors), 408 (timeout)
+		// Don't retry: other 4xx (client errors)
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout {
+			return nil, apiErr
+		}
+		return nil, retry.NewNonRetryableError(apiErr)
+	}
+
+	if method == http.MethodGet {
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			c.store(ctx, url, cachedResponse{etag: etag, body: respBody})
+		}
+	}
+
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: respBody}, nil
+}
+
+// isAPIHost reports whether target has the scheme and host of the base URL, so it may be sent the token
+func (c *Client) isAPIHost(target *neturl.URL) bool {
+	base, err := neturl.Parse(c.baseURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(target.Scheme, base.Scheme) && strings.EqualFold(target.Host, base.Host)
+}
+
+// cachedFor returns the cached response for a GET request, if any, from memory or the cache directory
+func (c *Client) cachedFor(method, url string) (cachedResponse, bool) {
+	if method != http.MethodGet {
+		return cachedResponse{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.etagCache[url]; ok {
+		return cached, true
+	}
+	if c.cacheDir == "" || !isContentURL(url) {
+		return cachedResponse{}, false
+	}
+	cached, ok := readCached(c.cacheDir, url)
+	if ok {
+		c.etagCache[url] = cached
+	}
+	return cached, ok
+}
+
+// store caches a GET response in memory and, for content fetches, in the cache directory
+// Failing to write the cache directory only costs a download in a later run, so it is logged and ignored
+func (c *Client) store(ctx context.Context, url string, response cachedResponse) {
+	c.mu.Lock()
+	c.etagCache[url] = response
+	dir := c.cacheDir
+	c.mu.Unlock()
+
+	if dir == "" || !isContentURL(url) {
+		return
+	}
+	if err := writeCached(dir, url, response); err != nil {
+		logging.Debugf(ctx, "Unable to cache the GitHub API response for %s: %v", url, err)
+	}
+}
+
+// updateRateLimit records the X-RateLimit-Remaining and X-RateLimit-Reset headers
+func (c *Client) updateRateLimit(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, _ := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rateLimitRemaining = remaining
+	c.rateLimitReset = time.Unix(reset, 0)
+}
+
+// waitForRateLimit blocks until the rate limit resets if the last response exhausted it
+func (c *Client) waitForRateLimit(ctx context.Context) error {
+	c.mu.Lock()
+	remaining, reset := c.rateLimitRemaining, c.rateLimitReset
+	c.mu.Unlock()
+
+	if remaining != 0 {
+		return nil
+	}
+	wait := time.Until(reset)
+	if wait <= 0 {
+		return nil
+	}
+	if wait > maxRateLimitWait {
+		return fmt.Errorf("GitHub API rate limit exhausted until %s", reset.UTC().Format(time.RFC3339))
+	}
+	logging.Warnf(ctx, "GitHub API rate limit exhausted - waiting %s for reset", wait.Round(time.Second))
+	return sleepContext(ctx, wait)
+}
+
+// rateLimitWait reports whether a failed response was caused by rate limiting and how long to wait
+// Honors Retry-After first, then X-RateLimit-Reset when X-RateLimit-Remaining is 0
+func rateLimitWait(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusForbidden {
+		return 0, false
+	}
+
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil {
+			return 0, true
+		}
+		return max(time.Until(time.Unix(reset, 0)), 0), true
+	}
+
+	// 429 without hints is still rate limiting; 403 without hints is a permissions error
+	return 0, resp.StatusCode == http.StatusTooManyRequests
+}
+
+// sleepContext waits for d or until the context is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait cancelled: %w", ctx.Err())
+	}
+}
+
+// nextPageURL returns the rel="next" URL from a Link header, or "" on the last page
+func nextPageURL(header http.Header) string {
+	match := linkNextRegex.FindStringSubmatch(header.Get("Link"))
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// maxPages bounds pagination so a misbehaving Link header can't loop forever
+const maxPages = 100
+
+// GetAllPages follows Link header pagination for a GET request and calls fn with each page body
+func (c *Client) GetAllPages(ctx context.Context, path string, fn func(page []byte) error) error {
+	next := path
+	for page := 1; next != ""; page++ {
+		if page > maxPages {
+			return fmt.Errorf("pagination exceeded %d pages for %s", maxPages, path)
+		}
+		resp, err := c.Request(ctx, http.MethodGet, next, nil)
+		if err != nil {
+			return err
+		}
+		if err := fn(resp.Body); err != nil {
+			return err
+		}
+		next = nextPageURL(resp.Header)
+	}
+	return nil
+}
+
+// ListAll fetches every page of a GitHub list endpoint that returns a JSON array
+func ListAll[T any](ctx context.Context, c *Client, path string) ([]T, error) {
+	var all []T
+	err := c.GetAllPages(ctx, path, func(page []byte) error {
+		var items []T
+		if err := json.Unmarshal(page, &items); err != nil {
+			return fmt.Errorf("failed to parse page: %w", err)
+		}
+		all = append(all, items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return all, nil
+}
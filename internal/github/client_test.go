@@ -0,0 +1,345 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientDo(t *testing.T) {
+	tests := []struct {
+		name        string
+		token       string
+		status      int
+		response    string
+		expectedErr string
+		expectedOut map[string]any
+	}{
+		{
+			name:        "success with token",
+			token:       "gh-token",
+			status:      http.StatusOK,
+			response:    `{"key": "value"}`,
+			expectedOut: map[string]any{"key": "value"},
+		},
+		{
+			name:        "non-2xx status",
+			token:       "gh-token",
+			status:      http.StatusNotFound,
+			response:    `{"message": "Not Found"}`,
+			expectedErr: "failed with status 404",
+		},
+		{
+			name:        "invalid JSON response",
+			status:      http.StatusOK,
+			response:    `not json`,
+			expectedErr: "failed to parse response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "application/vnd.github+json", r.Header.Get("Accept"))
+				if tt.token != "" {
+					assert.Equal(t, "Bearer "+tt.token, r.Header.Get("Authorization"))
+				} else {
+					assert.Empty(t, r.Header.Get("Authorization"))
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			client := NewClient(server.URL+"/", tt.token)
+			var out map[string]any
+			err := client.Do(context.Background(), "GET", "/test", nil, &out)
+
+			if tt.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedOut, out)
+		})
+	}
+}
+
+func TestClient_HasToken(t *testing.T) {
+	assert.True(t, NewClient("https://api.github.com", "token").HasToken())
+	assert.False(t, NewClient("https://api.github.com", "").HasToken())
+}
+
+// newTestClient creates a client pointed at the test server with fast retries
+func newTestClient(serverURL string) *Client {
+	client := NewClient(serverURL, "gh-token")
+	client.retryConfig.BaseDelay = time.Millisecond
+	return client
+}
+
+func TestClientRequest_Retries(t *testing.T) {
+	tests := []struct {
+		name          string
+		responses     []int
+		headers       map[string]string
+		expectedCalls int
+		expectedErr   string
+	}{
+		{
+			name:          "retries server errors then succeeds",
+			responses:     []int{http.StatusBadGateway, http.StatusOK},
+			expectedCalls: 2,
+		},
+		{
+			name:          "retries 429 honoring Retry-After",
+			responses:     []int{http.StatusTooManyRequests, http.StatusOK},
+			headers:       map[string]string{"Retry-After": "0"},
+			expectedCalls: 2,
+		},
+		{
+			name:          "retries 403 with exhausted rate limit",
+			responses:     []int{http.StatusForbidden, http.StatusOK},
+			headers:       map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "0"},
+			expectedCalls: 2,
+		},
+		{
+			name:          "does not retry 403 permission errors",
+			responses:     []int{http.StatusForbidden},
+			expectedCalls: 1,
+			expectedErr:   "status 403",
+		},
+		{
+			name:          "fails fast when Retry-After exceeds max wait",
+			responses:     []int{http.StatusTooManyRequests},
+			headers:       map[string]string{"Retry-After": "3600"},
+			expectedCalls: 1,
+			expectedErr:   "rate limited",
+		},
+		{
+			name:          "gives up after max attempts",
+			responses:     []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			expectedCalls: 3,
+			expectedErr:   "after 3 attempts",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.responses[calls]
+				calls++
+				if status != http.StatusOK {
+					for k, v := range tt.headers {
+						w.Header().Set(k, v)
+					}
+				}
+				w.WriteHeader(status)
+				_, _ = w.Write([]byte(`{}`))
+			}))
+			defer server.Close()
+
+			_, err := newTestClient(server.URL).Request(context.Background(), "GET", "/test", nil)
+
+			assert.Equal(t, tt.expectedCalls, calls)
+			if tt.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestClientRequest_RateLimitExhaustedBeforeRequest(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL)
+	_, err := client.Request(context.Background(), "GET", "/first", nil)
+	require.NoError(t, err)
+
+	_, err = client.Request(context.Background(), "GET", "/second", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rate limit exhausted")
+	assert.Equal(t, 1, calls)
+}
+
+func TestClientRequest_ETagConditionalRequests(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"value": 1}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL)
+
+	first, err := client.Request(context.Background(), "GET", "/resource", nil)
+	require.NoError(t, err)
+	assert.False(t, first.FromCache)
+
+	second, err := client.Request(context.Background(), "GET", "/resource", nil)
+	require.NoError(t, err)
+	assert.True(t, second.FromCache)
+	assert.Equal(t, http.StatusOK, second.StatusCode)
+	assert.JSONEq(t, `{"value": 1}`, string(second.Body))
+	assert.Equal(t, 2, calls)
+}
+
+func TestClientRequest_CacheDir(t *testing.T) {
+	var downloads, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"value": 1}`))
+	}))
+	defer server.Close()
+	dir := filepath.Join(t.TempDir(), "github-cache")
+	ctx := context.Background()
+
+	t.Run("content fetches are reused by a later client", func(t *testing.T) {
+		first := newTestClient(server.URL)
+		first.SetCacheDir(dir)
+		_, err := first.Request(ctx, "GET", "/repos/newrelic/schemas/contents/agent.json?ref=main", nil)
+		require.NoError(t, err)
+
+		second := newTestClient(server.URL)
+		second.SetCacheDir(dir)
+
+		// method under test
+		resp, err := second.Request(ctx, "GET", "/repos/newrelic/schemas/contents/agent.json?ref=main", nil)
+
+		require.NoError(t, err)
+		assert.True(t, resp.FromCache)
+		assert.JSONEq(t, `{"value": 1}`, string(resp.Body))
+		assert.Equal(t, 1, downloads)
+		assert.Equal(t, 1, notModified)
+	})
+
+	t.Run("other responses are only cached in memory", func(t *testing.T) {
+		downloads = 0
+		first := newTestClient(server.URL)
+		first.SetCacheDir(dir)
+		_, err := first.Request(ctx, "GET", "/repos/newrelic/docs/pulls/1/files", nil)
+		require.NoError(t, err)
+
+		second := newTestClient(server.URL)
+		second.SetCacheDir(dir)
+
+		// method under test
+		resp, err := second.Request(ctx, "GET", "/repos/newrelic/docs/pulls/1/files", nil)
+
+		require.NoError(t, err)
+		assert.False(t, resp.FromCache)
+		assert.Equal(t, 2, downloads)
+	})
+
+	t.Run("unreadable entries are downloaded again", func(t *testing.T) {
+		downloads = 0
+		url := server.URL + "/repos/newrelic/schemas/contents/broken.json?ref=main"
+		require.NoError(t, os.WriteFile(cachePath(dir, url), []byte("not json"), 0600))
+		client := newTestClient(server.URL)
+		client.SetCacheDir(dir)
+
+		// method under test
+		resp, err := client.Request(ctx, "GET", url, nil)
+
+		require.NoError(t, err)
+		assert.False(t, resp.FromCache)
+		assert.Equal(t, 1, downloads)
+		cached, ok := readCached(dir, url)
+		require.True(t, ok)
+		assert.Equal(t, `"v1"`, cached.etag)
+	})
+}
+
+func TestListAll_FollowsPagination(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		if page < 3 {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/items?page=%d>; rel="next", <%s/items?page=3>; rel="last"`, server.URL, page+1, server.URL))
+		}
+		_, _ = fmt.Fprintf(w, `[{"id": %d}, {"id": %d}]`, page*10, page*10+1)
+	}))
+	defer server.Close()
+
+	type item struct {
+		ID int `json:"id"`
+	}
+	items, err := ListAll[item](context.Background(), newTestClient(server.URL), "/items")
+
+	require.NoError(t, err)
+	require.Len(t, items, 6)
+	assert.Equal(t, 10, items[0].ID)
+	assert.Equal(t, 31, items[5].ID)
+}
+
+func TestListAll_DoesNotSendTokenToForeignHost(t *testing.T) {
+	var foreignAuth []string
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		foreignAuth = append(foreignAuth, r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`[{"id": 20}]`))
+	}))
+	defer foreign.Close()
+	var apiAuth string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiAuth = r.Header.Get("Authorization")
+		w.Header().Set("Link", fmt.Sprintf(`<%s/items?page=2>; rel="next"`, foreign.URL))
+		_, _ = w.Write([]byte(`[{"id": 10}]`))
+	}))
+	defer api.Close()
+
+	type item struct {
+		ID int `json:"id"`
+	}
+	// method under test
+	items, err := ListAll[item](context.Background(), newTestClient(api.URL), "/items")
+
+	require.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, "Bearer gh-token", apiAuth)
+	assert.Equal(t, []string{""}, foreignAuth, "The token is only sent to the API host")
+}
+
+func TestListAll_InvalidPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"not": "an array"}`))
+	}))
+	defer server.Close()
+
+	_, err := ListAll[map[string]any](context.Background(), newTestClient(server.URL), "/items")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse page")
+}
@@ -0,0 +1,98 @@
+package github
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// MaxRemoteContentSize is the largest file the action will fetch through the contents API
+const MaxRemoteContentSize = 1 << 20 // 1 MiB
+
+// contentRefRegex matches remote file references of the form "owner/repo:path/to/file@ref"
+var contentRefRegex = regexp.MustCompile(`^([A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+):([^@]+)@([^@\s]+)$`)
+
+// ContentRef identifies a file in another repository at a specific git ref
+type ContentRef struct {
+	Repo string // owner/repo
+	Path string // path within the repository
+	Ref  string // branch, tag or commit SHA
+}
+
+// String returns the reference in "owner/repo:path@ref" form
+func (r ContentRef) String() string {
+	return fmt.Sprintf("%s:%s@%s", r.Repo, r.Path, r.Ref)
+}
+
+// ParseContentRef parses an "owner/repo:path@ref" reference
+// Returns false if value is not a remote reference (e.g., a local relative path)
+func ParseContentRef(value string) (ContentRef, bool, error) {
+	match := contentRefRegex.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return ContentRef{}, false, nil
+	}
+
+	ref := ContentRef{
+		Repo: match[1],
+		Path: strings.TrimPrefix(match[2], "/"),
+		Ref:  match[3],
+	}
+
+	for _, segment := range strings.Split(ref.Path, "/") {
+		if segment == ".." {
+			return ContentRef{}, true, fmt.Errorf("invalid remote path %q: contains directory traversal", ref.Path)
+		}
+	}
+	if ref.Path == "" {
+		return ContentRef{}, true, fmt.Errorf("invalid remote reference %q: path is required", value)
+	}
+
+	return ref, true, nil
+}
+
+type contentResponse struct {
+	Type     string `json:"type"`
+	Size     int64  `json:"size"`
+	Encoding string `json:"encoding"`
+	Content  string `json:"content"`
+}
+
+// GetFileContent fetches a file from another repository via the contents API
+// GET /repos/{owner}/{repo}/contents/{path}?ref={ref}
+// Returns error if the file is not a regular file, is empty, or exceeds maxSize bytes
+func (c *Client) GetFileContent(ctx context.Context, ref ContentRef, maxSize int64) ([]byte, error) {
+	var escapedPath []string
+	for _, segment := range strings.Split(ref.Path, "/") {
+		escapedPath = append(escapedPath, url.PathEscape(segment))
+	}
+	path := fmt.Sprintf("/repos/%s/contents/%s?ref=%s", ref.Repo, strings.Join(escapedPath, "/"), url.QueryEscape(ref.Ref))
+
+	var content contentResponse
+	if err := c.Do(ctx, "GET", path, nil, &content); err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", ref, err)
+	}
+
+	if content.Type != "file" {
+		return nil, fmt.Errorf("%s is a %s, not a file", ref, content.Type)
+	}
+	if content.Size > maxSize {
+		return nil, fmt.Errorf("%s is %d bytes which exceeds the %d byte limit", ref, content.Size, maxSize)
+	}
+	if content.Encoding != "base64" {
+		return nil, fmt.Errorf("%s has unsupported encoding %q", ref, content.Encoding)
+	}
+
+	// The API wraps base64 content at 60 characters
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(content.Content, "\n", ""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", ref, err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%s is empty", ref)
+	}
+
+	return data, nil
+}
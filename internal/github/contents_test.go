@@ -0,0 +1,141 @@
+package github
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseContentRef(t *testing.T) {
+	tests := []struct {
+		name           string
+		value          string
+		expectedRemote bool
+		expected       ContentRef
+		expectedErr    string
+	}{
+		{
+			name:           "remote reference",
+			value:          "newrelic/fleet-schemas:schemas/java/config.json@v1.2.0",
+			expectedRemote: true,
+			expected:       ContentRef{Repo: "newrelic/fleet-schemas", Path: "schemas/java/config.json", Ref: "v1.2.0"},
+		},
+		{
+			name:           "leading slash in path is trimmed",
+			value:          "newrelic/fleet-schemas:/config.json@main",
+			expectedRemote: true,
+			expected:       ContentRef{Repo: "newrelic/fleet-schemas", Path: "config.json", Ref: "main"},
+		},
+		{
+			name:           "local relative path",
+			value:          "./schemas/config.json",
+			expectedRemote: false,
+		},
+		{
+			name:           "windows absolute path is not remote",
+			value:          `C:\schemas\config.json`,
+			expectedRemote: false,
+		},
+		{
+			name:           "missing ref is not remote",
+			value:          "newrelic/fleet-schemas:config.json",
+			expectedRemote: false,
+		},
+		{
+			name:           "directory traversal rejected",
+			value:          "newrelic/fleet-schemas:../secrets.json@main",
+			expectedRemote: true,
+			expectedErr:    "directory traversal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, isRemote, err := ParseContentRef(tt.value)
+			assert.Equal(t, tt.expectedRemote, isRemote)
+			if tt.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ref)
+		})
+	}
+}
+
+func TestGetFileContent(t *testing.T) {
+	schema := `{"type": "object"}`
+	encoded := base64.StdEncoding.EncodeToString([]byte(schema))
+
+	tests := []struct {
+		name        string
+		response    string
+		maxSize     int64
+		expectedErr string
+	}{
+		{
+			name:     "file content decoded",
+			response: fmt.Sprintf(`{"type": "file", "size": %d, "encoding": "base64", "content": "%s\n"}`, len(schema), encoded),
+			maxSize:  MaxRemoteContentSize,
+		},
+		{
+			name:        "directory rejected",
+			response:    `[{"type": "file"}]`,
+			maxSize:     MaxRemoteContentSize,
+			expectedErr: "failed to parse response",
+		},
+		{
+			name:        "non-file type rejected",
+			response:    `{"type": "symlink", "size": 10}`,
+			maxSize:     MaxRemoteContentSize,
+			expectedErr: "is a symlink, not a file",
+		},
+		{
+			name:        "oversized file rejected",
+			response:    fmt.Sprintf(`{"type": "file", "size": %d, "encoding": "base64", "content": "%s"}`, len(schema), encoded),
+			maxSize:     5,
+			expectedErr: "exceeds the 5 byte limit",
+		},
+		{
+			name:        "unsupported encoding",
+			response:    `{"type": "file", "size": 2000000, "encoding": "none", "content": ""}`,
+			maxSize:     5000000,
+			expectedErr: "unsupported encoding",
+		},
+		{
+			name:        "empty file rejected",
+			response:    `{"type": "file", "size": 0, "encoding": "base64", "content": ""}`,
+			maxSize:     MaxRemoteContentSize,
+			expectedErr: "is empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/repos/newrelic/fleet-schemas/contents/schemas/config.json", r.URL.Path)
+				assert.Equal(t, "v1.0.0", r.URL.Query().Get("ref"))
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			ref := ContentRef{Repo: "newrelic/fleet-schemas", Path: "schemas/config.json", Ref: "v1.0.0"}
+			data, err := newTestClient(server.URL).GetFileContent(context.Background(), ref, tt.maxSize)
+
+			if tt.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, schema, string(data))
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"agent-metadata-action/internal/config"
+	"agent-metadata-action/internal/fileutil"
+	"agent-metadata-action/internal/logging"
+)
+
+// WorkflowDispatchEvent is the event name for manually triggered workflows
+const WorkflowDispatchEvent = "workflow_dispatch"
+
+// explicitMDXFiles returns the MDX files named by the mdx-files or release-note-path inputs
+// The bool result is false when neither input is set and files should be derived from the event diff
+func explicitMDXFiles(ctx context.Context) ([]string, bool, error) {
+	mdxFilesInput := strings.TrimSpace(config.GetMDXFiles())
+	releaseNotePath := strings.TrimSpace(config.GetReleaseNotePath())
+
+	if mdxFilesInput == "" && releaseNotePath == "" {
+		if config.GetEventName() == WorkflowDispatchEvent {
+			return nil, true, fmt.Errorf("%s runs require the mdx-files or release-note-path input", WorkflowDispatchEvent)
+		}
+		return nil, false, nil
+	}
+
+	workspace := config.GetWorkspace()
+	var files []string
+
+	for _, entry := range splitList(mdxFilesInput) {
+		fullPath, err := resolveWorkspacePath(workspace, entry)
+		if err != nil {
+			return nil, true, err
+		}
+		if err := validateReleaseNoteFile(entry, fullPath); err != nil {
+			return nil, true, err
+		}
+		files = append(files, fullPath)
+	}
+
+	if releaseNotePath != "" {
+		found, err := releaseNoteFilesAt(workspace, releaseNotePath)
+		if err != nil {
+			return nil, true, err
+		}
+		files = append(files, found...)
+	}
+
+	logging.Noticef(ctx, "Using %d explicitly provided MDX files instead of the event diff", len(files))
+	return dedupe(files), true, nil
+}
+
+// GetAllReleaseNoteFiles returns every release note under the release notes directory of the workspace, excluding IgnoredFilenames
+func GetAllReleaseNoteFiles(workspace string) ([]string, error) {
+	return releaseNoteFilesAt(workspace, config.GetReleaseNotesDirectory())
+}
+
+// releaseNoteFilesAt returns the release note at path, or every release note under path if it is a directory
+func releaseNoteFilesAt(workspace, path string) ([]string, error) {
+	fullPath, err := resolveWorkspacePath(workspace, path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("release note path %s not found: %w", path, err)
+	}
+	if !info.IsDir() {
+		if err := validateReleaseNoteFile(path, fullPath); err != nil {
+			return nil, err
+		}
+		return []string{fullPath}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(fullPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, ReleaseNotesFileExtension) || isIgnoredFilename(d.Name()) {
+			return nil
+		}
+		files = append(files, p)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list release notes under %s: %w", path, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no %s release notes found under %s", ReleaseNotesFileExtension, path)
+	}
+	return files, nil
+}
+
+// resolveWorkspacePath resolves a workspace-relative input path, rejecting paths that escape the workspace
+func resolveWorkspacePath(workspace, path string) (string, error) {
+	if fileutil.HasTraversal(path) {
+		return "", fmt.Errorf("invalid path %s: contains directory traversal", path)
+	}
+	if !fileutil.IsRelative(path) {
+		return "", fmt.Errorf("invalid path %s: must be relative to the repository root", path)
+	}
+	return filepath.Join(workspace, fileutil.NormalizePath(path)), nil
+}
+
+// validateReleaseNoteFile checks an explicit file is an existing release note that would be picked up from a diff
+func validateReleaseNoteFile(input, fullPath string) error {
+	if !strings.HasSuffix(input, ReleaseNotesFileExtension) {
+		return fmt.Errorf("invalid MDX file %s: must have %s extension", input, ReleaseNotesFileExtension)
+	}
+	slashed := filepath.ToSlash(fileutil.NormalizePath(input))
+	if isIgnoredFilename(path.Base(slashed)) {
+		return fmt.Errorf("invalid MDX file %s: %s files are not release notes", input, path.Base(slashed))
+	}
+	if !strings.Contains(slashed, config.GetReleaseNotesDirectory()) {
+		return fmt.Errorf("invalid MDX file %s: must be under %s", input, config.GetReleaseNotesDirectory())
+	}
+	if _, err := os.Stat(fullPath); err != nil {
+		return fmt.Errorf("MDX file %s not found: %w", input, err)
+	}
+	return nil
+}
+
+// splitList splits a newline or comma separated input into trimmed, non-empty entries
+func splitList(input string) []string {
+	var entries []string
+	for _, entry := range strings.FieldsFunc(input, func(r rune) bool { return r == '\n' || r == ',' }) {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// dedupe removes duplicate entries while preserving order
+func dedupe(entries []string) []string {
+	seen := make(map[string]bool, len(entries))
+	result := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !seen[entry] {
+			seen[entry] = true
+			result = append(result, entry)
+		}
+	}
+	return result
+}
@@ -0,0 +1,163 @@
+package github
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupReleaseNotes creates release notes under the docs release notes directory and returns the workspace
+func setupReleaseNotes(t *testing.T) string {
+	t.Helper()
+	workspace := t.TempDir()
+	dir := filepath.Join(workspace, config.GetReleaseNotesDirectory(), "agent-release-notes", "java-release-notes")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	for _, name := range []string{"java-agent-130.mdx", "java-agent-131.mdx", "index.mdx"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("---\nversion: 1.3.0\n---\n"), 0644))
+	}
+	return workspace
+}
+
+func TestGetChangedMDXFiles_ExplicitInputs(t *testing.T) {
+	javaDir := filepath.Join(config.GetReleaseNotesDirectory(), "agent-release-notes", "java-release-notes")
+
+	tests := []struct {
+		name            string
+		eventName       string
+		mdxFiles        string
+		releaseNotePath string
+		expected        []string
+		expectedErr     string
+	}{
+		{
+			name:      "newline separated mdx files",
+			eventName: WorkflowDispatchEvent,
+			mdxFiles:  filepath.Join(javaDir, "java-agent-130.mdx") + "\n" + filepath.Join(javaDir, "java-agent-131.mdx") + "\n",
+			expected:  []string{"java-agent-130.mdx", "java-agent-131.mdx"},
+		},
+		{
+			name:      "comma separated mdx files are deduplicated",
+			eventName: WorkflowDispatchEvent,
+			mdxFiles:  filepath.Join(javaDir, "java-agent-130.mdx") + ", " + filepath.Join(javaDir, "java-agent-130.mdx"),
+			expected:  []string{"java-agent-130.mdx"},
+		},
+		{
+			name:            "release note file path",
+			eventName:       WorkflowDispatchEvent,
+			releaseNotePath: filepath.Join(javaDir, "java-agent-131.mdx"),
+			expected:        []string{"java-agent-131.mdx"},
+		},
+		{
+			name:            "release note directory skips index files",
+			eventName:       WorkflowDispatchEvent,
+			releaseNotePath: javaDir,
+			expected:        []string{"java-agent-130.mdx", "java-agent-131.mdx"},
+		},
+		{
+			name:        "workflow_dispatch without inputs",
+			eventName:   WorkflowDispatchEvent,
+			expectedErr: "require the mdx-files or release-note-path input",
+		},
+		{
+			name:        "directory traversal rejected",
+			mdxFiles:    "../outside.mdx",
+			expectedErr: "directory traversal",
+		},
+		{
+			name:      "windows separators",
+			eventName: WorkflowDispatchEvent,
+			mdxFiles:  strings.ReplaceAll(filepath.ToSlash(filepath.Join(javaDir, "java-agent-130.mdx")), "/", `\`),
+			expected:  []string{"java-agent-130.mdx"},
+		},
+		{
+			name:        "windows directory traversal rejected",
+			mdxFiles:    `..\outside.mdx`,
+			expectedErr: "directory traversal",
+		},
+		{
+			name:        "windows drive path rejected",
+			mdxFiles:    `C:\notes\release.mdx`,
+			expectedErr: "must be relative",
+		},
+		{
+			name:        "absolute path rejected",
+			mdxFiles:    "/etc/notes.mdx",
+			expectedErr: "must be relative",
+		},
+		{
+			name:        "non mdx file rejected",
+			mdxFiles:    filepath.Join(javaDir, "notes.md"),
+			expectedErr: "must have .mdx extension",
+		},
+		{
+			name:        "index file rejected",
+			mdxFiles:    filepath.Join(javaDir, "index.mdx"),
+			expectedErr: "are not release notes",
+		},
+		{
+			name:        "file outside release notes directory rejected",
+			mdxFiles:    "docs/notes.mdx",
+			expectedErr: "must be under",
+		},
+		{
+			name:        "missing file rejected",
+			mdxFiles:    filepath.Join(javaDir, "java-agent-999.mdx"),
+			expectedErr: "not found",
+		},
+		{
+			name:            "missing release note path rejected",
+			releaseNotePath: filepath.Join(javaDir, "missing"),
+			expectedErr:     "not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workspace := setupReleaseNotes(t)
+			t.Setenv("GITHUB_WORKSPACE", workspace)
+			t.Setenv("GITHUB_EVENT_NAME", tt.eventName)
+			t.Setenv("INPUT_MDX_FILES", tt.mdxFiles)
+			t.Setenv("INPUT_RELEASE_NOTE_PATH", tt.releaseNotePath)
+
+			// The event diff must not be consulted when explicit inputs are set
+			originalFunc := GetChangedMDXFilesFunc
+			GetChangedMDXFilesFunc = getChangedMDXFilesImpl
+			t.Setenv("GITHUB_EVENT_PATH", "")
+			defer func() { GetChangedMDXFilesFunc = originalFunc }()
+
+			files, err := GetChangedMDXFiles()
+
+			if tt.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, files, len(tt.expected))
+			for i, name := range tt.expected {
+				assert.Equal(t, filepath.Join(workspace, javaDir, name), files[i])
+			}
+		})
+	}
+}
+
+func TestGetChangedMDXFiles_NoExplicitInputsUsesEventDiff(t *testing.T) {
+	t.Setenv("GITHUB_EVENT_NAME", "push")
+	t.Setenv("INPUT_MDX_FILES", "")
+	t.Setenv("INPUT_RELEASE_NOTE_PATH", "")
+	t.Setenv("GITHUB_EVENT_PATH", "")
+
+	originalFunc := GetChangedMDXFilesFunc
+	GetChangedMDXFilesFunc = getChangedMDXFilesImpl
+	defer func() { GetChangedMDXFilesFunc = originalFunc }()
+
+	_, err := GetChangedMDXFiles()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "GITHUB_EVENT_PATH not set")
+}
@@ -0,0 +1,52 @@
+package github
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"agent-metadata-action/internal/config"
+)
+
+// SetOutput sets a step output by appending it to the file named by GITHUB_OUTPUT
+// No-op outside GitHub Actions, where GITHUB_OUTPUT is not set
+func SetOutput(name, value string) error {
+	path := config.GetOutputPath()
+	if path == "" {
+		return nil
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("output %s must be a single line", name)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open GITHUB_OUTPUT: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintf(file, "%s=%s\n", name, value); err != nil {
+		return fmt.Errorf("failed to write output %s: %w", name, err)
+	}
+	return nil
+}
+
+// AppendStepSummary appends markdown to the job summary in the file named by GITHUB_STEP_SUMMARY
+// No-op outside GitHub Actions, where GITHUB_STEP_SUMMARY is not set
+func AppendStepSummary(markdown string) error {
+	path := config.GetStepSummaryPath()
+	if path == "" {
+		return nil
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open GITHUB_STEP_SUMMARY: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintf(file, "%s\n", strings.TrimRight(markdown, "\n")); err != nil {
+		return fmt.Errorf("failed to write job summary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package github
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetOutput(t *testing.T) {
+	t.Run("no-op without GITHUB_OUTPUT", func(t *testing.T) {
+		t.Setenv("GITHUB_OUTPUT", "")
+
+		// method under test
+		require.NoError(t, SetOutput("metadata-id", "12345"))
+	})
+
+	t.Run("appends outputs", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "output")
+		t.Setenv("GITHUB_OUTPUT", path)
+
+		// method under test
+		require.NoError(t, SetOutput("metadata-id", "12345"))
+		require.NoError(t, SetOutput("signature-id", "sig-1"))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "metadata-id=12345\nsignature-id=sig-1\n", string(data))
+	})
+
+	t.Run("rejects multi-line values", func(t *testing.T) {
+		t.Setenv("GITHUB_OUTPUT", filepath.Join(t.TempDir(), "output"))
+
+		err := SetOutput("metadata-id", "a\nb")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "single line")
+	})
+}
+
+func TestAppendStepSummary(t *testing.T) {
+	t.Run("no-op without GITHUB_STEP_SUMMARY", func(t *testing.T) {
+		t.Setenv("GITHUB_STEP_SUMMARY", "")
+
+		// method under test
+		require.NoError(t, AppendStepSummary("### Rego policies"))
+	})
+
+	t.Run("appends markdown", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "summary")
+		t.Setenv("GITHUB_STEP_SUMMARY", path)
+
+		// method under test
+		require.NoError(t, AppendStepSummary("### Rego policies\n"))
+		require.NoError(t, AppendStepSummary("- no violations"))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "### Rego policies\n- no violations\n", string(data))
+	})
+}
@@ -0,0 +1,82 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"agent-metadata-action/internal/logging"
+)
+
+// TokenNeeds are the operations a run will use the GitHub token for
+type TokenNeeds struct {
+	CheckRunRepo string   // repository the validation check run is published to, if any (checks: write)
+	ReadRepos    []string // repositories files are fetched from, e.g. remote schemas (contents: read)
+}
+
+// rateLimitResponse is the part of the GET /rate_limit response the token check uses
+type rateLimitResponse struct {
+	Resources struct {
+		Core struct {
+			Limit     int   `json:"limit"`
+			Remaining int   `json:"remaining"`
+			Reset     int64 `json:"reset"`
+		} `json:"core"`
+	} `json:"resources"`
+}
+
+// CheckTokenFunc is a variable that holds the function to check the GitHub token can do what the run needs
+// This allows tests to override the implementation
+var CheckTokenFunc = func(ctx context.Context, needs TokenNeeds) error {
+	return GetClient().CheckToken(ctx, needs)
+}
+
+// CheckToken checks the token is valid, has API budget left, and can read the repositories the run fetches files from
+// GET /rate_limit, which doesn't count against the rate limit, then GET /repos/{owner}/{repo} for each repository
+// The GITHUB_TOKEN doesn't expose its permissions, so check run publishing can't be verified up front; personal
+// access tokens, which can't publish check runs at all, are only warned about since the check run is optional
+func (c *Client) CheckToken(ctx context.Context, needs TokenNeeds) error {
+	resp, err := c.Request(ctx, http.MethodGet, "/rate_limit", nil)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("github-token was rejected by the GitHub API: it is invalid or has expired")
+	}
+	if err != nil {
+		return fmt.Errorf("unable to check github-token: %w", err)
+	}
+
+	var limits rateLimitResponse
+	if err := json.Unmarshal(resp.Body, &limits); err != nil {
+		return fmt.Errorf("unable to check github-token: unexpected rate limit response: %w", err)
+	}
+	core := limits.Resources.Core
+	if core.Limit > 0 && core.Remaining == 0 {
+		return fmt.Errorf("github-token has used its %d GitHub API requests until %s - rerun the workflow after then", core.Limit, time.Unix(core.Reset, 0).UTC().Format(time.RFC3339))
+	}
+	logging.Debugf(ctx, "github-token has %d of %d GitHub API requests left", core.Remaining, core.Limit)
+
+	// Classic personal access tokens list their scopes; app and GITHUB_TOKEN installation tokens don't
+	if scopes, isPAT := resp.Header[http.CanonicalHeaderKey("X-OAuth-Scopes")]; isPAT && needs.CheckRunRepo != "" {
+		logging.Warnf(ctx, "github-token is a personal access token (scopes: %s), which can't publish the %s check run - use the GITHUB_TOKEN with checks: write instead", strings.Join(scopes, ", "), CheckRunName)
+	}
+
+	var unreadable []string
+	for _, repo := range needs.ReadRepos {
+		_, err := c.Request(ctx, http.MethodGet, "/repos/"+repo, nil)
+		if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusForbidden) {
+			unreadable = append(unreadable, repo)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("unable to check github-token access to %s: %w", repo, err)
+		}
+	}
+	if len(unreadable) > 0 {
+		return fmt.Errorf("github-token can't read %s, which schemas are fetched from: add contents: read to the permissions block of the workflow, and for private repositories other than the one running the workflow pass a github-token with read access to them, since the GITHUB_TOKEN can only read its own repository", strings.Join(unreadable, ", "))
+	}
+	return nil
+}
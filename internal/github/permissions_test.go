@@ -0,0 +1,94 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		rateLimit string
+		status    int
+		scopes    string
+		needs     TokenNeeds
+		expected  string
+		warning   string
+	}{
+		{
+			name:      "token can read the schema repositories",
+			rateLimit: `{"resources": {"core": {"limit": 5000, "remaining": 4999, "reset": 1767225600}}}`,
+			needs:     TokenNeeds{CheckRunRepo: "newrelic/java-agent", ReadRepos: []string{"newrelic/fleet-schemas"}},
+		},
+		{
+			name:     "invalid token",
+			status:   http.StatusUnauthorized,
+			expected: "github-token was rejected by the GitHub API: it is invalid or has expired",
+		},
+		{
+			name:      "rate limit exhausted",
+			rateLimit: `{"resources": {"core": {"limit": 1000, "remaining": 0, "reset": 1767225600}}}`,
+			expected:  "github-token has used its 1000 GitHub API requests until 2026-01-01T00:00:00Z - rerun the workflow after then",
+		},
+		{
+			name:      "private schema repositories",
+			rateLimit: `{"resources": {"core": {"limit": 5000, "remaining": 4999, "reset": 1767225600}}}`,
+			needs:     TokenNeeds{ReadRepos: []string{"newrelic/fleet-schemas", "newrelic/private-schemas", "newrelic/forbidden-schemas"}},
+			expected:  "github-token can't read newrelic/private-schemas, newrelic/forbidden-schemas, which schemas are fetched from: add contents: read to the permissions block of the workflow",
+		},
+		{
+			name:      "personal access token",
+			rateLimit: `{"resources": {"core": {"limit": 5000, "remaining": 4999, "reset": 1767225600}}}`,
+			scopes:    "repo, workflow",
+			needs:     TokenNeeds{CheckRunRepo: "newrelic/java-agent"},
+			warning:   "::warn::github-token is a personal access token (scopes: repo, workflow), which can't publish the Agent Metadata Validation check run",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/rate_limit":
+					if tt.scopes != "" {
+						w.Header().Set("X-OAuth-Scopes", tt.scopes)
+					}
+					if tt.status != 0 {
+						w.WriteHeader(tt.status)
+						_, _ = w.Write([]byte(`{"message": "Bad credentials"}`))
+						return
+					}
+					_, _ = w.Write([]byte(tt.rateLimit))
+				case "/repos/newrelic/private-schemas":
+					w.WriteHeader(http.StatusNotFound)
+				case "/repos/newrelic/forbidden-schemas":
+					w.WriteHeader(http.StatusForbidden)
+				default:
+					_, _ = w.Write([]byte(`{}`))
+				}
+			}))
+			defer server.Close()
+			getStdout, _ := testutil.CaptureOutput(t)
+
+			// method under test
+			err := newTestClient(server.URL).CheckToken(context.Background(), tt.needs)
+
+			if tt.expected != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expected)
+			} else {
+				require.NoError(t, err)
+			}
+			if tt.warning != "" {
+				assert.Contains(t, getStdout(), tt.warning)
+			}
+		})
+	}
+}
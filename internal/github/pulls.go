@@ -0,0 +1,182 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// repoRegex matches repositories of the form "owner/repo"
+var repoRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+// Change is a set of files to commit to a branch of another repository and propose with a pull request
+type Change struct {
+	Repo   string            // owner/repo
+	Branch string            // created from the default branch if it doesn't exist
+	Title  string            // commit message and pull request title
+	Body   string            // pull request description
+	Files  map[string][]byte // content by path within the repository
+}
+
+// ValidateRepo checks repo has the "owner/repo" form
+func ValidateRepo(repo string) error {
+	if !repoRegex.MatchString(repo) {
+		return fmt.Errorf("invalid repository %q: must be owner/repo", repo)
+	}
+	return nil
+}
+
+type gitRef struct {
+	Object struct {
+		SHA string `json:"sha"`
+	} `json:"object"`
+}
+
+type gitCommit struct {
+	SHA  string `json:"sha"`
+	Tree struct {
+		SHA string `json:"sha"`
+	} `json:"tree"`
+}
+
+type treeEntry struct {
+	Path    string `json:"path"`
+	Mode    string `json:"mode"`
+	Type    string `json:"type"`
+	Content string `json:"content"`
+}
+
+type pullRequest struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+}
+
+// ProposeChange commits change.Files to change.Branch in a single commit and opens a pull request of the branch
+// against the default branch, returning its URL
+// It is idempotent: an existing branch gets a new commit only if the files differ from its head, and an open pull
+// request for the branch is updated with the title and body rather than opened again
+func (c *Client) ProposeChange(ctx context.Context, change Change) (string, error) {
+	if err := ValidateRepo(change.Repo); err != nil {
+		return "", err
+	}
+	if len(change.Files) == 0 {
+		return "", fmt.Errorf("no files to propose to %s", change.Repo)
+	}
+
+	var repo struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := c.Do(ctx, http.MethodGet, "/repos/"+change.Repo, nil, &repo); err != nil {
+		return "", fmt.Errorf("failed to look up %s: %w", change.Repo, err)
+	}
+
+	if err := c.commitFiles(ctx, change, repo.DefaultBranch); err != nil {
+		return "", err
+	}
+	return c.upsertPullRequest(ctx, change, repo.DefaultBranch)
+}
+
+// commitFiles commits the files on top of the branch, creating it from base if it doesn't exist
+func (c *Client) commitFiles(ctx context.Context, change Change, base string) error {
+	var head gitRef
+	err := c.Do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/git/ref/heads/%s", change.Repo, escapePath(change.Branch)), nil, &head)
+	branchExists := err == nil
+	if err != nil && !isStatus(err, http.StatusNotFound) {
+		return fmt.Errorf("failed to look up branch %s of %s: %w", change.Branch, change.Repo, err)
+	}
+	if !branchExists {
+		if err := c.Do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/git/ref/heads/%s", change.Repo, escapePath(base)), nil, &head); err != nil {
+			return fmt.Errorf("failed to look up branch %s of %s: %w", base, change.Repo, err)
+		}
+	}
+
+	var parent gitCommit
+	if err := c.Do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/git/commits/%s", change.Repo, head.Object.SHA), nil, &parent); err != nil {
+		return fmt.Errorf("failed to look up commit %s of %s: %w", head.Object.SHA, change.Repo, err)
+	}
+
+	paths := make([]string, 0, len(change.Files))
+	for path := range change.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	entries := make([]treeEntry, 0, len(paths))
+	for _, path := range paths {
+		entries = append(entries, treeEntry{Path: path, Mode: "100644", Type: "blob", Content: string(change.Files[path])})
+	}
+	var tree struct {
+		SHA string `json:"sha"`
+	}
+	treeBody := map[string]any{"base_tree": parent.Tree.SHA, "tree": entries}
+	if err := c.Do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/git/trees", change.Repo), treeBody, &tree); err != nil {
+		return fmt.Errorf("failed to create tree in %s: %w", change.Repo, err)
+	}
+	if branchExists && tree.SHA == parent.Tree.SHA {
+		// A rerun with the same files; the branch already has them
+		return nil
+	}
+
+	var commit gitCommit
+	commitBody := map[string]any{"message": change.Title, "tree": tree.SHA, "parents": []string{parent.SHA}}
+	if err := c.Do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/git/commits", change.Repo), commitBody, &commit); err != nil {
+		return fmt.Errorf("failed to create commit in %s: %w", change.Repo, err)
+	}
+
+	if branchExists {
+		refBody := map[string]any{"sha": commit.SHA, "force": false}
+		if err := c.Do(ctx, http.MethodPatch, fmt.Sprintf("/repos/%s/git/refs/heads/%s", change.Repo, escapePath(change.Branch)), refBody, nil); err != nil {
+			return fmt.Errorf("failed to update branch %s of %s: %w", change.Branch, change.Repo, err)
+		}
+		return nil
+	}
+	refBody := map[string]string{"ref": "refs/heads/" + change.Branch, "sha": commit.SHA}
+	if err := c.Do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/git/refs", change.Repo), refBody, nil); err != nil {
+		return fmt.Errorf("failed to create branch %s of %s: %w", change.Branch, change.Repo, err)
+	}
+	return nil
+}
+
+// upsertPullRequest updates the open pull request of the branch against base, or opens one
+func (c *Client) upsertPullRequest(ctx context.Context, change Change, base string) (string, error) {
+	owner, _, _ := strings.Cut(change.Repo, "/")
+	var open []pullRequest
+	query := fmt.Sprintf("/repos/%s/pulls?state=open&base=%s&head=%s", change.Repo, url.QueryEscape(base), url.QueryEscape(owner+":"+change.Branch))
+	if err := c.Do(ctx, http.MethodGet, query, nil, &open); err != nil {
+		return "", fmt.Errorf("failed to list pull requests of %s: %w", change.Repo, err)
+	}
+
+	body := map[string]string{"title": change.Title, "body": change.Body}
+	if len(open) > 0 {
+		if err := c.Do(ctx, http.MethodPatch, fmt.Sprintf("/repos/%s/pulls/%d", change.Repo, open[0].Number), body, nil); err != nil {
+			return "", fmt.Errorf("failed to update pull request %s: %w", open[0].HTMLURL, err)
+		}
+		return open[0].HTMLURL, nil
+	}
+
+	body["head"] = change.Branch
+	body["base"] = base
+	var created pullRequest
+	if err := c.Do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/pulls", change.Repo), body, &created); err != nil {
+		return "", fmt.Errorf("failed to open a pull request against %s: %w", change.Repo, err)
+	}
+	return created.HTMLURL, nil
+}
+
+// escapePath escapes each segment of a slash-separated path
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func isStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
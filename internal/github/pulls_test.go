@@ -0,0 +1,161 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// downstreamRepo fakes the GitHub API of a repository for ProposeChange
+type downstreamRepo struct {
+	branchExists bool
+	treeSHA      string // returned by tree creation
+	pullExists   bool
+
+	tree       map[string]any
+	commit     map[string]any
+	createdRef map[string]any
+	updatedRef map[string]any
+	pull       map[string]string
+	updatedPR  map[string]string
+}
+
+func (d *downstreamRepo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	decode := func(v any) { _ = json.NewDecoder(r.Body).Decode(v) }
+	const repo = "/repos/newrelic/fleet-gitops"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == repo:
+		_, _ = w.Write([]byte(`{"default_branch": "main"}`))
+	case r.Method == http.MethodGet && r.URL.Path == repo+"/git/ref/heads/main":
+		_, _ = w.Write([]byte(`{"object": {"sha": "main123"}}`))
+	case r.Method == http.MethodGet && r.URL.Path == repo+"/git/ref/heads/agent-metadata/NRJavaAgent/1.2.3":
+		if !d.branchExists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"object": {"sha": "branch123"}}`))
+	case r.Method == http.MethodGet && r.URL.Path == repo+"/git/commits/main123":
+		_, _ = w.Write([]byte(`{"sha": "main123", "tree": {"sha": "maintree"}}`))
+	case r.Method == http.MethodGet && r.URL.Path == repo+"/git/commits/branch123":
+		_, _ = w.Write([]byte(`{"sha": "branch123", "tree": {"sha": "branchtree"}}`))
+	case r.Method == http.MethodPost && r.URL.Path == repo+"/git/trees":
+		decode(&d.tree)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"sha": "` + d.treeSHA + `"}`))
+	case r.Method == http.MethodPost && r.URL.Path == repo+"/git/commits":
+		decode(&d.commit)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"sha": "commit456"}`))
+	case r.Method == http.MethodPost && r.URL.Path == repo+"/git/refs":
+		decode(&d.createdRef)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPatch && r.URL.Path == repo+"/git/refs/heads/agent-metadata/NRJavaAgent/1.2.3":
+		decode(&d.updatedRef)
+	case r.Method == http.MethodGet && r.URL.Path == repo+"/pulls":
+		if !d.pullExists || r.URL.Query().Get("head") != "newrelic:agent-metadata/NRJavaAgent/1.2.3" {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[{"number": 6, "html_url": "https://github.com/newrelic/fleet-gitops/pull/6"}]`))
+	case r.Method == http.MethodPost && r.URL.Path == repo+"/pulls":
+		decode(&d.pull)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"number": 7, "html_url": "https://github.com/newrelic/fleet-gitops/pull/7"}`))
+	case r.Method == http.MethodPatch && r.URL.Path == repo+"/pulls/6":
+		decode(&d.updatedPR)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestProposeChange(t *testing.T) {
+	change := Change{
+		Repo:   "newrelic/fleet-gitops",
+		Branch: "agent-metadata/NRJavaAgent/1.2.3",
+		Title:  "Release NRJavaAgent 1.2.3",
+		Body:   "Generated for NRJavaAgent 1.2.3",
+		Files: map[string][]byte{
+			"pins/NRJavaAgent/1.2.3.yaml":            []byte("version: 1.2.3\n"),
+			"metadata/agents/NRJavaAgent/1.2.3.json": []byte("{}\n"),
+		},
+	}
+
+	t.Run("opens a pull request from a new branch", func(t *testing.T) {
+		repo := &downstreamRepo{treeSHA: "newtree"}
+		server := httptest.NewServer(repo)
+		defer server.Close()
+
+		// method under test
+		url, err := newTestClient(server.URL).ProposeChange(context.Background(), change)
+
+		require.NoError(t, err)
+		assert.Equal(t, "https://github.com/newrelic/fleet-gitops/pull/7", url)
+		assert.Equal(t, "maintree", repo.tree["base_tree"])
+		assert.Equal(t, []any{
+			map[string]any{"path": "metadata/agents/NRJavaAgent/1.2.3.json", "mode": "100644", "type": "blob", "content": "{}\n"},
+			map[string]any{"path": "pins/NRJavaAgent/1.2.3.yaml", "mode": "100644", "type": "blob", "content": "version: 1.2.3\n"},
+		}, repo.tree["tree"])
+		assert.Equal(t, map[string]any{"message": change.Title, "tree": "newtree", "parents": []any{"main123"}}, repo.commit)
+		assert.Equal(t, map[string]any{"ref": "refs/heads/agent-metadata/NRJavaAgent/1.2.3", "sha": "commit456"}, repo.createdRef)
+		assert.Equal(t, map[string]string{"title": change.Title, "body": change.Body, "head": change.Branch, "base": "main"}, repo.pull)
+	})
+
+	t.Run("rerun with new files updates the branch and the open pull request", func(t *testing.T) {
+		repo := &downstreamRepo{branchExists: true, treeSHA: "newtree", pullExists: true}
+		server := httptest.NewServer(repo)
+		defer server.Close()
+
+		// method under test
+		url, err := newTestClient(server.URL).ProposeChange(context.Background(), change)
+
+		require.NoError(t, err)
+		assert.Equal(t, "https://github.com/newrelic/fleet-gitops/pull/6", url)
+		assert.Equal(t, "branchtree", repo.tree["base_tree"])
+		assert.Equal(t, []any{"branch123"}, repo.commit["parents"])
+		assert.Equal(t, map[string]any{"sha": "commit456", "force": false}, repo.updatedRef)
+		assert.Nil(t, repo.createdRef)
+		assert.Nil(t, repo.pull)
+		assert.Equal(t, map[string]string{"title": change.Title, "body": change.Body}, repo.updatedPR)
+	})
+
+	t.Run("rerun with the same files doesn't commit", func(t *testing.T) {
+		repo := &downstreamRepo{branchExists: true, treeSHA: "branchtree", pullExists: true}
+		server := httptest.NewServer(repo)
+		defer server.Close()
+
+		// method under test
+		url, err := newTestClient(server.URL).ProposeChange(context.Background(), change)
+
+		require.NoError(t, err)
+		assert.Equal(t, "https://github.com/newrelic/fleet-gitops/pull/6", url)
+		assert.Nil(t, repo.commit)
+		assert.Nil(t, repo.updatedRef)
+	})
+
+	t.Run("invalid repository", func(t *testing.T) {
+		invalid := change
+		invalid.Repo = "fleet-gitops"
+
+		// method under test
+		_, err := newTestClient("http://localhost").ProposeChange(context.Background(), invalid)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must be owner/repo")
+	})
+
+	t.Run("no files", func(t *testing.T) {
+		empty := change
+		empty.Files = nil
+
+		// method under test
+		_, err := newTestClient("http://localhost").ProposeChange(context.Background(), empty)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no files to propose")
+	})
+}
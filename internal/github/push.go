@@ -24,14 +24,26 @@ var gitSHARegex = regexp.MustCompile(`^[0-9a-f]{40}$`)
 
 // PushEvent represents the GitHub PR event payload
 type PushEvent struct {
-	Before string `json:"before"`
-	After  string `json:"after"`
-	Ref    string `json:"ref"`
+	Before  string   `json:"before"`
+	After   string   `json:"after"`
+	Ref     string   `json:"ref"`
+	Commits []Commit `json:"commits"`
+}
+
+// Commit is a commit listed in a push event payload
+type Commit struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
 }
 
 // GetChangedMDXFiles returns ReleaseNotesFileExtension type files changed in the PR under the expected release notes direcotry, excluding IgnoredFilenames
+// Explicit mdx-files or release-note-path inputs (e.g., for workflow_dispatch runs) take precedence over the event diff
 func GetChangedMDXFiles() ([]string, error) {
-	return GetChangedMDXFilesFunc(context.Background())
+	ctx := context.Background()
+	if files, explicit, err := explicitMDXFiles(ctx); explicit {
+		return files, err
+	}
+	return GetChangedMDXFilesFunc(ctx)
 }
 
 // isIgnoredFilename checks if the filename should be ignored
@@ -124,3 +136,33 @@ func getChangedMDXFilesImpl(ctx context.Context) ([]string, error) {
 
 	return mdxFiles, nil
 }
+
+// GetCommitMessagesFunc is a variable that holds the function to get the messages of the commits that triggered the run
+// This allows tests to override the implementation
+var GetCommitMessagesFunc = getCommitMessagesImpl
+
+// getCommitMessagesImpl reads the commit messages from the push event payload
+// Events other than pushes list no commits
+func getCommitMessagesImpl(ctx context.Context) ([]string, error) {
+	eventPath := config.GetEventPath()
+	if eventPath == "" {
+		return nil, fmt.Errorf("GITHUB_EVENT_PATH not set")
+	}
+
+	data, err := os.ReadFile(eventPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read event payload: %w", err)
+	}
+
+	var event PushEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, fmt.Errorf("failed to parse event payload: %w", err)
+	}
+
+	messages := make([]string, 0, len(event.Commits))
+	for _, commit := range event.Commits {
+		messages = append(messages, commit.Message)
+	}
+	logging.Debugf(ctx, "Read %d commit messages from the event payload", len(messages))
+	return messages, nil
+}
@@ -2,6 +2,7 @@ package github
 
 import (
 	"agent-metadata-action/internal/config"
+	"context"
 	"encoding/json"
 	"os"
 	"os/exec"
@@ -348,3 +349,35 @@ func TestGetChangedMDXFiles_CommandInjectionAttempt(t *testing.T) {
 		t.Errorf("Expected error about invalid after SHA, got: %v", err)
 	}
 }
+
+func TestGetCommitMessages(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "event.json")
+	event := `{
+		"ref": "refs/heads/main",
+		"commits": [
+			{"id": "a1b2c3d4e5f6789012345678901234567890abcd", "message": "Fix CVE-2024-1234"},
+			{"id": "b1b2c3d4e5f6789012345678901234567890abcd", "message": "Add release notes"}
+		]
+	}`
+	if err := os.WriteFile(tmpFile, []byte(event), 0644); err != nil {
+		t.Fatalf("Failed to write event file: %v", err)
+	}
+	t.Setenv("GITHUB_EVENT_PATH", tmpFile)
+
+	messages, err := GetCommitMessagesFunc(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(messages) != 2 || messages[0] != "Fix CVE-2024-1234" || messages[1] != "Add release notes" {
+		t.Errorf("Unexpected commit messages: %v", messages)
+	}
+}
+
+func TestGetCommitMessages_NoEventPath(t *testing.T) {
+	t.Setenv("GITHUB_EVENT_PATH", "")
+
+	_, err := GetCommitMessagesFunc(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "GITHUB_EVENT_PATH not set") {
+		t.Errorf("Expected error about GITHUB_EVENT_PATH, got: %v", err)
+	}
+}
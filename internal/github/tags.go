@@ -0,0 +1,156 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"agent-metadata-action/internal/logging"
+)
+
+// versionRefRegex limits the versions looked up as tags to plain ref names, so they can't be taken as git options
+var versionRefRegex = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._+-]*$`)
+
+// VersionDateFunc is a variable that holds the function to find when a version was released according to git
+// This allows tests to override the implementation
+var VersionDateFunc = versionDateImpl
+
+// versionDateImpl returns when version was released according to the git history of workspace, and what the date
+// was taken from: the version tag (v<version> or <version>, tagger date for annotated tags) if it exists, otherwise
+// the last commit to path, if set
+// A zero time means neither was found
+func versionDateImpl(ctx context.Context, workspace, version, path string) (time.Time, string, error) {
+	if versionRefRegex.MatchString(version) {
+		for _, tag := range []string{"v" + version, version} {
+			out, err := runGit(ctx, workspace, "for-each-ref", "--format=%(creatordate:iso-strict)", "refs/tags/"+tag)
+			if err != nil {
+				return time.Time{}, "", err
+			}
+			if out != "" {
+				date, err := time.Parse(time.RFC3339, out)
+				if err != nil {
+					return time.Time{}, "", fmt.Errorf("unexpected date %q for tag %s: %w", out, tag, err)
+				}
+				return date, "tag " + tag, nil
+			}
+		}
+	}
+
+	if path == "" {
+		return time.Time{}, "", nil
+	}
+	out, err := runGit(ctx, workspace, "log", "-1", "--format=%cI", "--", path)
+	if err != nil || out == "" {
+		return time.Time{}, "", err
+	}
+	date, err := time.Parse(time.RFC3339, out)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("unexpected commit date %q for %s: %w", out, path, err)
+	}
+	logging.Debugf(ctx, "No tag found for version %s - using the last commit to %s", version, path)
+	return date, "last commit to " + RelativeToWorkspace(workspace, path), nil
+}
+
+// CommitDateFunc is a variable that holds the function to find when the checked out commit was made
+// This allows tests to override the implementation
+var CommitDateFunc = commitDateImpl
+
+// commitDateImpl returns the committer date of the commit checked out in workspace
+func commitDateImpl(ctx context.Context, workspace string) (time.Time, error) {
+	out, err := runGit(ctx, workspace, "log", "-1", "--format=%cI", "HEAD")
+	if err != nil {
+		return time.Time{}, err
+	}
+	date, err := time.Parse(time.RFC3339, out)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unexpected commit date %q: %w", out, err)
+	}
+	return date, nil
+}
+
+// PreviousReleaseFileFunc is a variable that holds the function to read a file as it was in the previous release
+// This allows tests to override the implementation
+var PreviousReleaseFileFunc = previousReleaseFileImpl
+
+// previousReleaseFileImpl returns the content of path, relative to workspace, at the newest tag before the checked
+// out commit, and that tag
+// No tag (e.g. a shallow clone without tags) gives an empty tag; a file that didn't exist at the tag gives nil content
+func previousReleaseFileImpl(ctx context.Context, workspace, path string) ([]byte, string, error) {
+	tag, err := runGit(ctx, workspace, "describe", "--tags", "--abbrev=0", "HEAD^")
+	if err != nil {
+		logging.Debugf(ctx, "No previous release tag found: %v", err)
+		return nil, "", nil
+	}
+
+	object := tag + ":./" + filepath.ToSlash(path)
+	if _, err := runGit(ctx, workspace, "cat-file", "-e", object); err != nil {
+		logging.Debugf(ctx, "%s did not exist at tag %s", path, tag)
+		return nil, tag, nil
+	}
+	content, err := runGit(ctx, workspace, "show", object)
+	if err != nil {
+		return nil, "", err
+	}
+	return []byte(content), tag, nil
+}
+
+// ListTagsFunc is a variable that holds the function to list the tags of a repository
+// This allows tests to override the implementation
+var ListTagsFunc = listTagsImpl
+
+// listTagsImpl returns the tags of the repository in workspace, oldest version first
+func listTagsImpl(ctx context.Context, workspace string) ([]string, error) {
+	out, err := runGit(ctx, workspace, "tag", "--list", "--sort=v:refname")
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// CheckoutTagFunc is a variable that holds the function to check out a tag of a repository
+// This allows tests to override the implementation
+var CheckoutTagFunc = checkoutTagImpl
+
+// checkoutTagImpl checks out tag as a detached git worktree of the repository in workspace, in a new temporary
+// directory, and returns the directory and a function that removes the worktree
+// Worktrees share the repository, so several tags can be checked out at once without cloning
+func checkoutTagImpl(ctx context.Context, workspace, tag string) (string, func(), error) {
+	if !versionRefRegex.MatchString(tag) {
+		return "", nil, fmt.Errorf("invalid tag %q", tag)
+	}
+	dir, err := os.MkdirTemp("", "agent-metadata-"+tag+"-")
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := runGit(ctx, workspace, "worktree", "add", "--detach", dir, "refs/tags/"+tag); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, err
+	}
+	cleanup := func() {
+		// The worktree is removed even if the run was cancelled
+		if _, err := runGit(context.Background(), workspace, "worktree", "remove", "--force", dir); err != nil {
+			logging.Debugf(ctx, "Unable to remove the worktree of tag %s: %v", tag, err)
+			_ = os.RemoveAll(dir)
+		}
+	}
+	return dir, cleanup, nil
+}
+
+// runGit runs a git command in workspace and returns its trimmed output
+func runGit(ctx context.Context, workspace string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = workspace
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,187 @@
+package github
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gitAt runs a git command in dir with the author and committer dates set to date
+func gitAt(t *testing.T, dir, date string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test User", "GIT_AUTHOR_EMAIL=test@example.com", "GIT_AUTHOR_DATE="+date,
+		"GIT_COMMITTER_NAME=Test User", "GIT_COMMITTER_EMAIL=test@example.com", "GIT_COMMITTER_DATE="+date)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+func TestCommitDate(t *testing.T) {
+	workspace := t.TempDir()
+	gitAt(t, workspace, "2025-01-10T12:00:00Z", "init")
+	gitAt(t, workspace, "2025-01-11T08:15:00+02:00", "commit", "--allow-empty", "-m", "Release 8.0.0")
+
+	// method under test
+	date, err := CommitDateFunc(context.Background(), workspace)
+
+	require.NoError(t, err)
+	assert.True(t, date.Equal(time.Date(2025, 1, 11, 6, 15, 0, 0, time.UTC)), date)
+
+	_, err = CommitDateFunc(context.Background(), t.TempDir())
+	assert.Error(t, err, "Not a git repository")
+}
+
+func TestVersionDate(t *testing.T) {
+	workspace := t.TempDir()
+	gitAt(t, workspace, "2025-01-10T12:00:00Z", "init")
+	note := filepath.Join(workspace, "java-agent-800.mdx")
+	require.NoError(t, os.WriteFile(note, []byte("---\nversion: 8.0.0\n---\n"), 0644))
+	gitAt(t, workspace, "2025-01-10T12:00:00Z", "add", ".")
+	gitAt(t, workspace, "2025-01-10T12:00:00Z", "commit", "-m", "Add release notes")
+	gitAt(t, workspace, "2025-01-12T09:30:00+02:00", "tag", "-a", "v8.0.0", "-m", "8.0.0")
+	gitAt(t, workspace, "2025-01-10T12:00:00Z", "tag", "8.1.0")
+	ctx := context.Background()
+
+	t.Run("annotated tag with a v prefix", func(t *testing.T) {
+		// method under test
+		date, source, err := VersionDateFunc(ctx, workspace, "8.0.0", note)
+
+		require.NoError(t, err)
+		assert.Equal(t, "tag v8.0.0", source)
+		assert.True(t, date.Equal(time.Date(2025, 1, 12, 7, 30, 0, 0, time.UTC)), date)
+	})
+
+	t.Run("lightweight tag", func(t *testing.T) {
+		// method under test
+		date, source, err := VersionDateFunc(ctx, workspace, "8.1.0", "")
+
+		require.NoError(t, err)
+		assert.Equal(t, "tag 8.1.0", source)
+		assert.Equal(t, "2025-01-10", date.Format("2006-01-02"))
+	})
+
+	t.Run("last commit to the release note without a tag", func(t *testing.T) {
+		// method under test
+		date, source, err := VersionDateFunc(ctx, workspace, "9.0.0", note)
+
+		require.NoError(t, err)
+		assert.Equal(t, "last commit to java-agent-800.mdx", source)
+		assert.Equal(t, "2025-01-10", date.Format("2006-01-02"))
+	})
+
+	t.Run("nothing found", func(t *testing.T) {
+		// method under test
+		date, source, err := VersionDateFunc(ctx, workspace, "--all", "")
+
+		require.NoError(t, err)
+		assert.True(t, date.IsZero())
+		assert.Empty(t, source)
+	})
+}
+
+func TestPreviousReleaseFile(t *testing.T) {
+	workspace := t.TempDir()
+	ctx := context.Background()
+	path := filepath.Join(".fleetControl", "configurationDefinitions.yml")
+	commit := func(content string) {
+		require.NoError(t, os.MkdirAll(filepath.Join(workspace, ".fleetControl"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(workspace, path), []byte(content), 0644))
+		gitAt(t, workspace, "2025-01-10T12:00:00Z", "add", ".")
+		gitAt(t, workspace, "2025-01-10T12:00:00Z", "commit", "-m", "Update definitions")
+	}
+	gitAt(t, workspace, "2025-01-10T12:00:00Z", "init")
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "README.md"), []byte("agent"), 0644))
+	gitAt(t, workspace, "2025-01-10T12:00:00Z", "add", ".")
+	gitAt(t, workspace, "2025-01-10T12:00:00Z", "commit", "-m", "Initial commit")
+
+	t.Run("no previous tag", func(t *testing.T) {
+		commit("configurationDefinitions: []\n")
+
+		// method under test
+		content, tag, err := PreviousReleaseFileFunc(ctx, workspace, path)
+
+		require.NoError(t, err)
+		assert.Empty(t, tag)
+		assert.Nil(t, content)
+	})
+
+	t.Run("file added after the previous tag", func(t *testing.T) {
+		gitAt(t, workspace, "2025-01-10T12:00:00Z", "tag", "v1.0.0", "HEAD^")
+
+		// method under test
+		content, tag, err := PreviousReleaseFileFunc(ctx, workspace, path)
+
+		require.NoError(t, err)
+		assert.Equal(t, "v1.0.0", tag)
+		assert.Nil(t, content)
+	})
+
+	t.Run("file at the previous tag", func(t *testing.T) {
+		gitAt(t, workspace, "2025-01-10T12:00:00Z", "tag", "v1.1.0")
+		commit("configurationDefinitions:\n  - type: agent-config\n")
+		gitAt(t, workspace, "2025-01-10T12:00:00Z", "tag", "v1.2.0")
+
+		// method under test
+		content, tag, err := PreviousReleaseFileFunc(ctx, workspace, path)
+
+		require.NoError(t, err)
+		assert.Equal(t, "v1.1.0", tag)
+		assert.Equal(t, "configurationDefinitions: []", string(content))
+	})
+}
+
+func TestListTagsAndCheckoutTag(t *testing.T) {
+	workspace := t.TempDir()
+	ctx := context.Background()
+	gitAt(t, workspace, "2025-01-10T12:00:00Z", "init")
+	for _, version := range []string{"1.10.0", "1.2.0", "1.9.0"} {
+		require.NoError(t, os.WriteFile(filepath.Join(workspace, "VERSION"), []byte(version), 0644))
+		gitAt(t, workspace, "2025-01-10T12:00:00Z", "add", ".")
+		gitAt(t, workspace, "2025-01-10T12:00:00Z", "commit", "-m", "Release "+version)
+		gitAt(t, workspace, "2025-01-10T12:00:00Z", "tag", "v"+version)
+	}
+
+	t.Run("tags in version order", func(t *testing.T) {
+		// method under test
+		tags, err := ListTagsFunc(ctx, workspace)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"v1.2.0", "v1.9.0", "v1.10.0"}, tags)
+	})
+
+	t.Run("worktree of a tag", func(t *testing.T) {
+		// method under test
+		dir, cleanup, err := CheckoutTagFunc(ctx, workspace, "v1.2.0")
+
+		require.NoError(t, err)
+		content, err := os.ReadFile(filepath.Join(dir, "VERSION"))
+		require.NoError(t, err)
+		assert.Equal(t, "1.2.0", string(content))
+
+		cleanup()
+		assert.NoDirExists(t, dir)
+	})
+
+	t.Run("unknown tag", func(t *testing.T) {
+		// method under test
+		_, _, err := CheckoutTagFunc(ctx, workspace, "v3.0.0")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "git worktree failed")
+	})
+
+	t.Run("tag that could be taken as an option", func(t *testing.T) {
+		// method under test
+		_, _, err := CheckoutTagFunc(ctx, workspace, "--force")
+
+		assert.EqualError(t, err, `invalid tag "--force"`)
+	})
+}
@@ -0,0 +1,69 @@
+package heartbeat
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"agent-metadata-action/internal/logging"
+)
+
+// Heartbeat notices which phase a run is in at a fixed interval, so log watchdogs and people following the job
+// can tell a long upload or backfill from a hung one
+type Heartbeat struct {
+	mu      sync.Mutex
+	phase   string
+	started time.Time
+}
+
+type heartbeatKey struct{}
+
+// Start prints a "still working" notice every interval until the returned function is called
+// The phase is "startup" until SetPhase is called; an interval of 0 or less disables the heartbeat
+func Start(ctx context.Context, interval time.Duration) (context.Context, func()) {
+	if interval <= 0 {
+		return ctx, func() {}
+	}
+	h := &Heartbeat{phase: "startup", started: time.Now()}
+	ctx = context.WithValue(ctx, heartbeatKey{}, h)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				phase, elapsed := h.current()
+				logging.Noticef(ctx, "still working: phase=%s elapsed=%s", phase, elapsed.Round(time.Second))
+			case <-done:
+				return
+			}
+		}
+	}()
+	return ctx, func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// SetPhase names the phase the run is in from now on, resetting its elapsed time; a no-op without a heartbeat
+func SetPhase(ctx context.Context, phase string) {
+	h, _ := ctx.Value(heartbeatKey{}).(*Heartbeat)
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.phase = phase
+	h.started = time.Now()
+}
+
+// current returns the phase and how long the run has been in it
+func (h *Heartbeat) current() (string, time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.phase, time.Since(h.started)
+}
@@ -0,0 +1,40 @@
+package heartbeat
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStart(t *testing.T) {
+	getStdout, _ := testutil.CaptureOutput(t)
+
+	// method under test
+	ctx, stop := Start(context.Background(), 10*time.Millisecond)
+
+	time.Sleep(35 * time.Millisecond)
+	SetPhase(ctx, "upload")
+	time.Sleep(35 * time.Millisecond)
+	stop()
+
+	stdout := getStdout()
+	assert.Contains(t, stdout, "::notice::still working: phase=startup elapsed=0s")
+	assert.Contains(t, stdout, "::notice::still working: phase=upload elapsed=0s")
+	assert.Less(t, strings.Index(stdout, "phase=startup"), strings.Index(stdout, "phase=upload"))
+}
+
+func TestStart_Disabled(t *testing.T) {
+	getStdout, _ := testutil.CaptureOutput(t)
+
+	// method under test
+	ctx, stop := Start(context.Background(), 0)
+
+	SetPhase(ctx, "upload")
+	stop()
+	assert.NotContains(t, getStdout(), "still working")
+}
@@ -0,0 +1,94 @@
+package hints
+
+import (
+	"regexp"
+	"strings"
+)
+
+// docsURL is the README the hints link to; each hint links to one of its sections
+const docsURL = "https://github.com/newrelic/agent-metadata-action"
+
+// Hint is the remediation for a known failure
+type Hint struct {
+	ID      string         // stable name of the failure, e.g. signing-unauthorized
+	Pattern *regexp.Regexp // matched against the error message
+	Text    string         // what to do about the failure
+	Section string         // README section anchor with more detail
+}
+
+// URL returns the link to the documentation of the hint
+func (h Hint) URL() string {
+	return docsURL + "#" + h.Section
+}
+
+// Catalog lists the known failures, matched in order
+// Patterns match the error messages of the packages that produce them, so a reworded message needs its pattern updated
+var Catalog = []Hint{
+	{
+		ID:      "signing-unauthorized",
+		Pattern: regexp.MustCompile(`artifact signing failed with status 40[13]`),
+		Text:    "The signing service rejected the New Relic token. Check that newrelic-client-id and newrelic-private-key (base64 encoded) are the OAuth credentials of the system identity registered for this repository, and ask the platform team to grant it artifact signing if it is new.",
+		Section: "prerequisites",
+	},
+	{
+		ID:      "metadata-unauthorized",
+		Pattern: regexp.MustCompile(`(?i)(metadata submission|metadata fetch|version listing|agent type listing|chunked upload \w+) failed with status 40[13]`),
+		Text:    "The instrumentation metadata service rejected the New Relic token. Check that newrelic-client-id and newrelic-private-key (base64 encoded) are the OAuth credentials of the system identity registered for this agent type, and that region matches the region the identity was created in.",
+		Section: "prerequisites",
+	},
+	{
+		ID:      "check-run-forbidden",
+		Pattern: regexp.MustCompile(`/check-runs failed with status 403`),
+		Text:    "Add checks: write to the permissions block of the workflow so the GITHUB_TOKEN passed as github-token can publish the check run; personal access tokens can't publish check runs.",
+		Section: "validation-check-run",
+	},
+	{
+		ID:      "unknown-agent-type",
+		Pattern: regexp.MustCompile(`(?i)unknown agent type|metadata submission failed with status 404`),
+		Text:    "The instrumentation metadata service doesn't know the agent type. Check agent-type matches a registered agent type exactly, including case; new agent types are registered by the platform team before their first release.",
+		Section: "example-workflow-for-releasing-a-new-agent-version",
+	},
+	{
+		ID:      "payload-too-large",
+		Pattern: regexp.MustCompile(`exceeds the service payload limit|byte payload limit`),
+		Text:    "Set max-payload-size to the service limit so large metadata is submitted in parts; a single definition larger than the limit has to be made smaller.",
+		Section: "chunked-submissions",
+	},
+	{
+		ID:      "schema-too-large",
+		Pattern: regexp.MustCompile(`byte content limit|bytes which exceeds the \d+ byte limit`),
+		Text:    "Schemas and agent control content must be at most 1 MiB. Remove embedded examples and long descriptions, or split the configuration into several configuration definition types.",
+		Section: "configuration-file-format-agent-scenario",
+	},
+	{
+		ID:      "directory-traversal",
+		Pattern: regexp.MustCompile(`directory traversal`),
+		Text:    "Paths must be relative to the repository root and stay inside it: remove leading / and .. segments, e.g. use ./dist/agent.tar.gz rather than ../dist/agent.tar.gz.",
+		Section: "configuration-file-format-agent-scenario",
+	},
+}
+
+// Match returns the hints whose pattern matches message, in catalog order
+func Match(message string) []Hint {
+	var matched []Hint
+	for _, hint := range Catalog {
+		if hint.Pattern.MatchString(message) {
+			matched = append(matched, hint)
+		}
+	}
+	return matched
+}
+
+// Explain returns message with the remediation of each matching hint appended, or message unchanged if none match
+// The result stays on one line so the hints are part of the annotation of an ::error:: workflow command
+func Explain(message string) string {
+	var explained strings.Builder
+	explained.WriteString(message)
+	for _, hint := range Match(message) {
+		explained.WriteString(" - hint: ")
+		explained.WriteString(hint.Text)
+		explained.WriteString(" See ")
+		explained.WriteString(hint.URL())
+	}
+	return explained.String()
+}
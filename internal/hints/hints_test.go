@@ -0,0 +1,55 @@
+package hints
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		expected []string
+	}{
+		{name: "signing 401", message: "artifact signing failed: artifact signing failed with status 401: unauthorized", expected: []string{"signing-unauthorized"}},
+		{name: "metadata 403", message: "failed to send metadata for NRJavaAgent: metadata submission failed with status 403: forbidden", expected: []string{"metadata-unauthorized"}},
+		{name: "chunked upload 401", message: "Chunked upload start failed with status 401: unauthorized", expected: []string{"metadata-unauthorized"}},
+		{name: "check run 403", message: "GitHub API POST https://api.github.com/repos/newrelic/java-agent/check-runs failed with status 403: Resource not accessible by integration", expected: []string{"check-run-forbidden"}},
+		{name: "unknown agent type", message: `invalid agent-type: unknown agent type "NRJavaAgnt" - did you mean "NRJavaAgent"?`, expected: []string{"unknown-agent-type"}},
+		{name: "agent type 404", message: "metadata submission failed with status 404: agent type not found", expected: []string{"unknown-agent-type"}},
+		{name: "payload too large", message: "metadata submission of 5000000 bytes exceeds the service payload limit (status 413) - set max-payload-size to submit it in parts", expected: []string{"payload-too-large"}},
+		{name: "schema too large", message: "configurationDefinitions[0].schema: decodes to 2000000 bytes, more than the 1048576 byte content limit", expected: []string{"schema-too-large"}},
+		{name: "remote schema too large", message: "newrelic/schemas/agent.json@main is 2000000 bytes which exceeds the 1048576 byte limit", expected: []string{"schema-too-large"}},
+		{name: "directory traversal", message: "invalid export-directory ../out: must be relative to the repository root without directory traversal", expected: []string{"directory-traversal"}},
+		{name: "unknown failure", message: "failed to read configuration definitions: file not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			matched := Match(tt.message)
+
+			var ids []string
+			for _, hint := range matched {
+				ids = append(ids, hint.ID)
+			}
+			assert.Equal(t, tt.expected, ids)
+		})
+	}
+}
+
+func TestExplain(t *testing.T) {
+	t.Run("known failure", func(t *testing.T) {
+		// method under test
+		explained := Explain("invalid binary path: contains directory traversal")
+
+		assert.Equal(t, "invalid binary path: contains directory traversal - hint: Paths must be relative to the repository root and stay inside it: remove leading / and .. segments, e.g. use ./dist/agent.tar.gz rather than ../dist/agent.tar.gz. See https://github.com/newrelic/agent-metadata-action#configuration-file-format-agent-scenario", explained)
+		assert.NotContains(t, explained, "\n")
+	})
+
+	t.Run("unknown failure", func(t *testing.T) {
+		// method under test
+		assert.Equal(t, "something else failed", Explain("something else failed"))
+	})
+}
@@ -0,0 +1,42 @@
+package idempotency
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+)
+
+// Header is the request header carrying the idempotency key
+// Services treat a repeated key as the same operation and return the original outcome instead of applying it again
+const Header = "Idempotency-Key"
+
+// Key derives an idempotency key from the values that identify an operation
+// The same values always give the same key, so retries and re-runs of a workflow run are recognized as duplicates
+func Key(parts ...string) string {
+	hash := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(hash[:])
+}
+
+// PayloadHash returns the hex SHA-256 digest of a request body, for use as a Key part
+func PayloadHash(body []byte) string {
+	hash := sha256.Sum256(body)
+	return hex.EncodeToString(hash[:])
+}
+
+// processScope is the run scope of keys made outside a workflow run
+var processScope = sync.OnceValue(func() string {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	return "process-" + hex.EncodeToString(nonce)
+})
+
+// RunScope returns the Key part that scopes keys to a workflow run: runID, or without one a nonce drawn once per
+// process, so separate local runs sending the same payload aren't taken for duplicates of each other
+func RunScope(runID string) string {
+	if runID == "" {
+		return processScope()
+	}
+	return runID
+}
@@ -0,0 +1,26 @@
+package idempotency
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKey(t *testing.T) {
+	key := Key("NRJavaAgent", "1.2.3", PayloadHash([]byte(`{}`)), "42")
+
+	assert.Regexp(t, `^[a-f0-9]{64}$`, key)
+	assert.Equal(t, key, Key("NRJavaAgent", "1.2.3", PayloadHash([]byte(`{}`)), "42"), "key should be deterministic")
+	assert.NotEqual(t, key, Key("NRJavaAgent", "1.2.3", PayloadHash([]byte(`{"a":1}`)), "42"), "payload should change the key")
+	assert.NotEqual(t, key, Key("NRJavaAgent", "1.2.3", PayloadHash([]byte(`{}`)), "43"), "run should change the key")
+	// Parts are delimited so values can't run into each other
+	assert.NotEqual(t, Key("ab", "c"), Key("a", "bc"))
+}
+
+func TestRunScope(t *testing.T) {
+	assert.Equal(t, "42", RunScope("42"))
+
+	scope := RunScope("")
+	assert.Regexp(t, `^process-[a-f0-9]{32}$`, scope)
+	assert.Equal(t, scope, RunScope(""), "The scope is the same within the process")
+}
@@ -0,0 +1,51 @@
+package inputs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFile reads input values from a YAML (or JSON) config file keyed by input name
+// Values in the file are used when neither a flag nor an environment variable sets the input
+// Lists and maps are stored as JSON so inputs such as binaries and tags can be written inline
+func LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// Decode into nodes so scalars keep their text, e.g. version 1.10 is not read as the number 1.1
+	var values map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	parsed := make(map[string]string, len(values))
+	for name, node := range values {
+		if _, ok := Lookup(name); !ok {
+			return fmt.Errorf("config file %s: unknown input %q", path, name)
+		}
+		if node.Tag == "!!null" {
+			continue
+		}
+		if node.Kind == yaml.ScalarNode {
+			parsed[name] = node.Value
+			continue
+		}
+		var value any
+		if err := node.Decode(&value); err != nil {
+			return fmt.Errorf("config file %s: invalid value for %s: %w", path, name, err)
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("config file %s: invalid value for %s: %w", path, name, err)
+		}
+		parsed[name] = string(encoded)
+	}
+
+	fileValues = parsed
+	return nil
+}
@@ -0,0 +1,348 @@
+package inputs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent-metadata-action/internal/logging"
+)
+
+// Type is the kind of value an input holds
+type Type string
+
+const (
+	String   Type = "string"
+	Bool     Type = "bool"
+	Int      Type = "int"
+	Duration Type = "duration"
+	JSON     Type = "json"
+)
+
+// Input declares an action input (or environment variable the action reads)
+type Input struct {
+	Name     string   // action.yml input name, or the variable name for non-input environment
+	Env      string   // environment variable the value is read from
+	Type     Type     // how the value is parsed
+	Default  string   // raw value used when the variable is unset or empty
+	Required bool     // flagged in the summary when missing
+	Secret   bool     // redacted in the configuration summary
+	Aliases  []string // deprecated environment variables still honored, with a warning
+}
+
+// Inputs declares every input the action reads, in the order they are summarized
+var Inputs = []Input{
+	{Name: "agent-type", Env: "INPUT_AGENT_TYPE", Type: String},
+	{Name: "version", Env: "INPUT_VERSION", Type: String},
+	{Name: "config-directory", Env: "INPUT_CONFIG_DIRECTORY", Type: String, Default: ".fleetControl"},
+	{Name: "monitoring-type", Env: "INPUT_MONITORING_TYPE", Type: String},
+	{Name: "display-name", Env: "INPUT_DISPLAY_NAME", Type: String},
+	{Name: "release-date", Env: "INPUT_RELEASE_DATE", Type: String},
+	{Name: "release-date-window", Env: "INPUT_RELEASE_DATE_WINDOW", Type: Int, Default: "7"},
+	{Name: "tags", Env: "INPUT_TAGS", Type: JSON},
+	{Name: "mode", Env: "INPUT_MODE", Type: String},
+	{Name: "dry-run", Env: "INPUT_DRY_RUN", Type: Bool, Default: "false"},
+	{Name: "backfill-versions", Env: "INPUT_BACKFILL_VERSIONS", Type: String},
+	{Name: "backfill-concurrency", Env: "INPUT_BACKFILL_CONCURRENCY", Type: Int, Default: "4"},
+	{Name: "reconcile-release-notes", Env: "INPUT_RECONCILE_RELEASE_NOTES", Type: Bool, Default: "false"},
+	{Name: "export-directory", Env: "INPUT_EXPORT_DIRECTORY", Type: String},
+	{Name: "schema-docs-directory", Env: "INPUT_SCHEMA_DOCS_DIRECTORY", Type: String},
+	{Name: "submission", Env: "INPUT_SUBMISSION", Type: String, Default: "full"},
+	{Name: "incremental-baseline", Env: "INPUT_INCREMENTAL_BASELINE", Type: String, Default: "service"},
+	{Name: "results-file", Env: "INPUT_RESULTS_FILE", Type: String},
+	{Name: "phases", Env: "INPUT_PHASES", Type: String, Default: "metadata,upload,sign"},
+	{Name: "phase-results", Env: "INPUT_PHASE_RESULTS", Type: String},
+	{Name: "journal-file", Env: "INPUT_JOURNAL_FILE", Type: String},
+	{Name: "resume-results", Env: "INPUT_RESUME_RESULTS", Type: String},
+	{Name: "sarif-file", Env: "INPUT_SARIF_FILE", Type: String, Aliases: []string{"INPUT_LINT_SARIF_FILE"}},
+	{Name: "pins-directory", Env: "INPUT_PINS_DIRECTORY", Type: String},
+	{Name: "downstream-repository", Env: "INPUT_DOWNSTREAM_REPOSITORY", Type: String},
+	{Name: "downstream-token", Env: "INPUT_DOWNSTREAM_TOKEN", Type: String, Secret: true},
+	{Name: "region", Env: "INPUT_REGION", Type: String, Default: "us"},
+	{Name: "environment", Env: "INPUT_ENVIRONMENT", Type: String, Default: "production"},
+	{Name: "payload-version", Env: "INPUT_PAYLOAD_VERSION", Type: String, Default: "auto"},
+	{Name: "compression-threshold", Env: "INPUT_COMPRESSION_THRESHOLD", Type: Int, Default: "0"},
+	{Name: "max-payload-size", Env: "INPUT_MAX_PAYLOAD_SIZE", Type: Int, Default: "0"},
+	{Name: "empty-field-policy", Env: "INPUT_EMPTY_FIELD_POLICY", Type: String, Default: "omit"},
+	{Name: "max-text-length", Env: "INPUT_MAX_TEXT_LENGTH", Type: Int, Default: "4000"},
+	{Name: "rate-limit", Env: "INPUT_RATE_LIMIT", Type: Int, Default: "0"},
+	{Name: "strict-contract", Env: "INPUT_STRICT_CONTRACT", Type: Bool, Default: "false"},
+	{Name: "strict-policy", Env: "INPUT_STRICT_POLICY", Type: Bool, Default: "false"},
+	{Name: "strict-fixtures", Env: "INPUT_STRICT_FIXTURES", Type: Bool, Default: "false"},
+	{Name: "derive-changelog", Env: "INPUT_DERIVE_CHANGELOG", Type: Bool, Default: "false"},
+	{Name: "rego-policies", Env: "INPUT_REGO_POLICIES", Type: String},
+	{Name: "mdx-files", Env: "INPUT_MDX_FILES", Type: String},
+	{Name: "release-note-path", Env: "INPUT_RELEASE_NOTE_PATH", Type: String},
+	{Name: "oci-registry", Env: "INPUT_OCI_REGISTRY", Type: String},
+	{Name: "oci-username", Env: "INPUT_OCI_USERNAME", Type: String},
+	{Name: "oci-password", Env: "INPUT_OCI_PASSWORD", Type: String, Secret: true},
+	{Name: "oci-plain-http", Env: "INPUT_OCI_PLAIN_HTTP", Type: Bool, Default: "false"},
+	{Name: "oci-insecure-skip-tls-verify", Env: "INPUT_OCI_INSECURE_SKIP_TLS_VERIFY", Type: Bool, Default: "false"},
+	{Name: "oci-max-blob-size", Env: "INPUT_OCI_MAX_BLOB_SIZE", Type: Int, Default: "0"},
+	{Name: "scan-command", Env: "INPUT_SCAN_COMMAND", Type: String},
+	{Name: "scan-url", Env: "INPUT_SCAN_URL", Type: String},
+	{Name: "scan-token", Env: "INPUT_SCAN_TOKEN", Type: String, Secret: true},
+	{Name: "required-legal-files", Env: "INPUT_REQUIRED_LEGAL_FILES", Type: String},
+	{Name: "content-manifests", Env: "INPUT_CONTENT_MANIFESTS", Type: Bool, Default: "false"},
+	{Name: "normalize-archives", Env: "INPUT_NORMALIZE_ARCHIVES", Type: Bool, Default: "false"},
+	{Name: "notarization-strict", Env: "INPUT_NOTARIZATION_STRICT", Type: Bool, Default: "false"},
+	{Name: "signing-required", Env: "INPUT_SIGNING_REQUIRED", Type: Bool, Default: "true"},
+	{Name: "oci-release-notes", Env: "INPUT_OCI_RELEASE_NOTES", Type: String},
+	{Name: "upload-plan-file", Env: "INPUT_UPLOAD_PLAN_FILE", Type: String},
+	{Name: "oci-layout", Env: "INPUT_OCI_LAYOUT", Type: String},
+	{Name: "handoff-file", Env: "INPUT_HANDOFF_FILE", Type: String},
+	{Name: "handoff-directory", Env: "INPUT_HANDOFF_DIRECTORY", Type: String},
+	{Name: "oci-annotations", Env: "INPUT_OCI_ANNOTATIONS", Type: JSON},
+	{Name: "oci-created-source", Env: "INPUT_OCI_CREATED_SOURCE", Type: String, Default: "now"},
+	{Name: "oci-pending-tag", Env: "INPUT_OCI_PENDING_TAG", Type: Bool, Default: "false"},
+	{Name: "promote-latest", Env: "INPUT_PROMOTE_LATEST", Type: Bool, Default: "false"},
+	{Name: "copy-source", Env: "INPUT_COPY_SOURCE", Type: String},
+	{Name: "copy-source-username", Env: "INPUT_COPY_SOURCE_USERNAME", Type: String},
+	{Name: "copy-source-password", Env: "INPUT_COPY_SOURCE_PASSWORD", Type: String, Secret: true},
+	{Name: "copy-digest", Env: "INPUT_COPY_DIGEST", Type: String},
+	{Name: "resign-digest", Env: "INPUT_RESIGN_DIGEST", Type: String},
+	{Name: "verify-results", Env: "INPUT_VERIFY_RESULTS", Type: String},
+	{Name: "inventory-file", Env: "INPUT_INVENTORY_FILE", Type: String},
+	{Name: "inventory-agent-types", Env: "INPUT_INVENTORY_AGENT_TYPES", Type: String},
+	{Name: "eol-before", Env: "INPUT_EOL_BEFORE", Type: String},
+	{Name: "retention-days", Env: "INPUT_RETENTION_DAYS", Type: Int, Default: "30"},
+	{Name: "retry-budget", Env: "INPUT_RETRY_BUDGET", Type: Duration, Default: "10m"},
+	{Name: "heartbeat-interval", Env: "INPUT_HEARTBEAT_INTERVAL", Type: Duration, Default: "60s"},
+	{Name: "release-lock-ttl", Env: "INPUT_RELEASE_LOCK_TTL", Type: Duration, Default: "30m"},
+	{Name: "metrics-address", Env: "INPUT_METRICS_ADDRESS", Type: String},
+	{Name: "metrics-file", Env: "INPUT_METRICS_FILE", Type: String},
+	{Name: "payload-memory-limit", Env: "INPUT_PAYLOAD_MEMORY_LIMIT", Type: Int, Default: "0"},
+	{Name: "binaries", Env: "INPUT_BINARIES", Type: JSON},
+	{Name: "decryption-key", Env: "INPUT_DECRYPTION_KEY", Type: String, Secret: true},
+	{Name: "github-token", Env: "INPUT_GITHUB_TOKEN", Type: String, Secret: true},
+	{Name: "newrelic-token", Env: "NEWRELIC_TOKEN", Type: String, Required: true, Secret: true},
+	{Name: "apm-control-nr-license-key", Env: "APM_CONTROL_NR_LICENSE_KEY", Type: String, Secret: true},
+	{Name: "GITHUB_WORKSPACE", Env: "GITHUB_WORKSPACE", Type: String, Required: true},
+	{Name: "GITHUB_REPOSITORY", Env: "GITHUB_REPOSITORY", Type: String},
+	{Name: "GITHUB_EVENT_NAME", Env: "GITHUB_EVENT_NAME", Type: String},
+	{Name: "GITHUB_EVENT_PATH", Env: "GITHUB_EVENT_PATH", Type: String},
+	{Name: "GITHUB_SHA", Env: "GITHUB_SHA", Type: String},
+	{Name: "GITHUB_ACTOR", Env: "GITHUB_ACTOR", Type: String},
+	{Name: "GITHUB_RUN_ID", Env: "GITHUB_RUN_ID", Type: String},
+	{Name: "GITHUB_RUN_ATTEMPT", Env: "GITHUB_RUN_ATTEMPT", Type: String},
+	{Name: "GITHUB_JOB", Env: "GITHUB_JOB", Type: String},
+	{Name: "GITHUB_WORKFLOW", Env: "GITHUB_WORKFLOW", Type: String},
+	{Name: "GITHUB_REF", Env: "GITHUB_REF", Type: String},
+	{Name: "GITHUB_WORKFLOW_REF", Env: "GITHUB_WORKFLOW_REF", Type: String},
+	{Name: "GITHUB_OUTPUT", Env: "GITHUB_OUTPUT", Type: String},
+	{Name: "GITHUB_STEP_SUMMARY", Env: "GITHUB_STEP_SUMMARY", Type: String},
+	{Name: "RUNNER_TEMP", Env: "RUNNER_TEMP", Type: String},
+	{Name: "GITHUB_ACTIONS", Env: "GITHUB_ACTIONS", Type: Bool, Default: "false"},
+	{Name: "GITHUB_API_URL", Env: "GITHUB_API_URL", Type: String, Default: "https://api.github.com"},
+	{Name: "GITHUB_SERVER_URL", Env: "GITHUB_SERVER_URL", Type: String, Default: "https://github.com"},
+	{Name: "METADATA_SERVICE_URL", Env: "METADATA_SERVICE_URL", Type: String},
+	{Name: "SIGNING_SERVICE_URL", Env: "SIGNING_SERVICE_URL", Type: String},
+}
+
+// Lookup returns the declared input with the given name
+func Lookup(name string) (Input, bool) {
+	for _, input := range Inputs {
+		if input.Name == name {
+			return input, true
+		}
+	}
+	return Input{}, false
+}
+
+// mustLookup returns the declared input with the given name, panicking on undeclared names
+// Getters are only called with names declared above, so a miss is a programming error
+func mustLookup(name string) Input {
+	input, ok := Lookup(name)
+	if !ok {
+		panic(fmt.Sprintf("input %q is not declared", name))
+	}
+	return input
+}
+
+// flagValues and fileValues hold inputs set by command line flags and a config file when run outside GitHub Actions
+var (
+	flagValues = map[string]string{}
+	fileValues = map[string]string{}
+)
+
+// SetFlag sets an input from a command line flag, taking precedence over the environment
+func SetFlag(name, value string) error {
+	if _, ok := Lookup(name); !ok {
+		return fmt.Errorf("unknown input %q", name)
+	}
+	flagValues[name] = value
+	return nil
+}
+
+// Reset clears inputs set by command line flags and config files
+func Reset() {
+	flagValues = map[string]string{}
+	fileValues = map[string]string{}
+}
+
+// resolve returns the trimmed value of an input and where it came from
+// Precedence is command line flag, environment variable, deprecated aliases, config file and then the default
+// An empty source means the default was used
+func (i Input) resolve() (value, source string) {
+	if value := strings.TrimSpace(flagValues[i.Name]); value != "" {
+		return value, "command line"
+	}
+	if value := strings.TrimSpace(os.Getenv(i.Env)); value != "" {
+		return value, i.Env
+	}
+	for _, alias := range i.Aliases {
+		if value := strings.TrimSpace(os.Getenv(alias)); value != "" {
+			logging.Warnf(context.Background(), "%s is deprecated - use %s instead", alias, i.Env)
+			return value, alias
+		}
+	}
+	if value := strings.TrimSpace(fileValues[i.Name]); value != "" {
+		return value, "config file"
+	}
+	return i.Default, ""
+}
+
+// Raw returns the trimmed value of an input (see resolve for precedence)
+// The bool result reports whether the value was explicitly set
+func (i Input) Raw() (string, bool) {
+	value, source := i.resolve()
+	return value, source != ""
+}
+
+// GetString returns a string input
+// Secrets read from the environment are returned untrimmed since whitespace may be significant
+func GetString(name string) string {
+	input := mustLookup(name)
+	value, source := input.resolve()
+	if input.Secret && source == input.Env {
+		return os.Getenv(input.Env)
+	}
+	return value
+}
+
+// GetBool returns a boolean input, accepting true/false, yes/no, on/off and 1/0 in any case
+// Unparseable values fall back to the default with a warning
+func GetBool(name string) bool {
+	input := mustLookup(name)
+	value, set := input.Raw()
+	parsed, err := parseBool(value)
+	if err != nil && set {
+		logging.Warnf(context.Background(), "Invalid boolean %q for %s - using default %q", value, input.Name, input.Default)
+		parsed, _ = parseBool(input.Default)
+	}
+	return parsed
+}
+
+// GetInt returns an integer input
+func GetInt(name string) (int, error) {
+	input := mustLookup(name)
+	value, _ := input.Raw()
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer %q for %s", value, input.Name)
+	}
+	return parsed, nil
+}
+
+// GetDuration returns a duration input such as "30s" or "5m"; a bare number is taken as seconds
+func GetDuration(name string) (time.Duration, error) {
+	input := mustLookup(name)
+	value, _ := input.Raw()
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q for %s", value, input.Name)
+	}
+	return parsed, nil
+}
+
+// GetJSON unmarshals a JSON input into v; unset inputs leave v unchanged
+func GetJSON(name string, v any) error {
+	input := mustLookup(name)
+	value, _ := input.Raw()
+	if value == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		return fmt.Errorf("invalid JSON for %s: %w", input.Name, err)
+	}
+	return nil
+}
+
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "", "false", "no", "off", "0":
+		return false, nil
+	case "true", "yes", "on", "1":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", value)
+}
+
+// checkType reports whether a set value parses as the input's declared type
+func checkType(input Input, value string) error {
+	var err error
+	switch input.Type {
+	case Bool:
+		_, err = parseBool(value)
+	case Int:
+		_, err = GetInt(input.Name)
+	case Duration:
+		_, err = GetDuration(input.Name)
+	case JSON:
+		var v any
+		err = GetJSON(input.Name, &v)
+	}
+	return err
+}
+
+// Summary returns one line per input with its effective value and where it came from
+// Secrets are redacted, unset optional inputs are omitted and values that don't parse are flagged
+func Summary() []string {
+	var lines []string
+	for _, input := range Inputs {
+		value, source := input.resolve()
+		if value == "" {
+			if input.Required {
+				lines = append(lines, fmt.Sprintf("%s: <not set> (required)", input.Name))
+			}
+			continue
+		}
+		invalid := source != "" && checkType(input, value) != nil
+		if source == "" {
+			source = "default"
+		}
+		if input.Secret {
+			value = "***"
+		}
+		line := fmt.Sprintf("%s: %s (from %s)", input.Name, value, source)
+		if invalid {
+			line += fmt.Sprintf(" [invalid %s]", input.Type)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// LogSummary prints the effective configuration inside a log group
+func LogSummary(ctx context.Context) {
+	logging.Log(ctx, "group", "Effective configuration")
+	defer logging.Log(ctx, "endgroup", "")
+
+	for _, line := range Summary() {
+		// Plain output so each line shows inside the group rather than as an annotation
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,298 @@
+package inputs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withInputs replaces the declared inputs for the duration of a test
+func withInputs(t *testing.T, declared ...Input) {
+	t.Helper()
+	original := Inputs
+	Inputs = declared
+	t.Cleanup(func() { Inputs = original })
+}
+
+func TestGetString(t *testing.T) {
+	withInputs(t,
+		Input{Name: "plain", Env: "INPUT_PLAIN", Type: String, Default: "fallback"},
+		Input{Name: "secret", Env: "INPUT_SECRET", Type: String, Secret: true},
+	)
+
+	t.Setenv("INPUT_PLAIN", "")
+	assert.Equal(t, "fallback", GetString("plain"))
+
+	t.Setenv("INPUT_PLAIN", "  value  ")
+	assert.Equal(t, "value", GetString("plain"))
+
+	t.Setenv("INPUT_SECRET", " pass word ")
+	assert.Equal(t, " pass word ", GetString("secret"))
+}
+
+func TestGetString_UndeclaredPanics(t *testing.T) {
+	withInputs(t)
+	assert.Panics(t, func() { GetString("missing") })
+}
+
+func TestGetString_DeprecatedAlias(t *testing.T) {
+	withInputs(t, Input{Name: "new", Env: "INPUT_NEW", Type: String, Aliases: []string{"INPUT_OLD"}})
+	t.Setenv("INPUT_NEW", "")
+	t.Setenv("INPUT_OLD", "legacy")
+
+	getStdout, _ := testutil.CaptureOutput(t)
+
+	// method under test
+	value := GetString("new")
+
+	assert.Equal(t, "legacy", value)
+	assert.Contains(t, getStdout(), "INPUT_OLD is deprecated - use INPUT_NEW instead")
+
+	t.Setenv("INPUT_NEW", "current")
+	assert.Equal(t, "current", GetString("new"))
+}
+
+func TestGetBool(t *testing.T) {
+	withInputs(t,
+		Input{Name: "flag", Env: "INPUT_FLAG", Type: Bool, Default: "false"},
+		Input{Name: "on-by-default", Env: "INPUT_ON", Type: Bool, Default: "true"},
+	)
+
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{"", false},
+		{"true", true},
+		{"TRUE", true},
+		{" yes ", true},
+		{"on", true},
+		{"1", true},
+		{"false", false},
+		{"No", false},
+		{"off", false},
+		{"0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("INPUT_FLAG", tt.value)
+			assert.Equal(t, tt.expected, GetBool("flag"))
+		})
+	}
+
+	t.Run("invalid value falls back to default", func(t *testing.T) {
+		t.Setenv("INPUT_ON", "maybe")
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		assert.True(t, GetBool("on-by-default"))
+		assert.Contains(t, getStdout(), `Invalid boolean "maybe" for on-by-default`)
+	})
+}
+
+func TestGetInt(t *testing.T) {
+	withInputs(t, Input{Name: "count", Env: "INPUT_COUNT", Type: Int, Default: "3"})
+
+	t.Setenv("INPUT_COUNT", "")
+	value, err := GetInt("count")
+	require.NoError(t, err)
+	assert.Equal(t, 3, value)
+
+	t.Setenv("INPUT_COUNT", "12")
+	value, err = GetInt("count")
+	require.NoError(t, err)
+	assert.Equal(t, 12, value)
+
+	t.Setenv("INPUT_COUNT", "twelve")
+	_, err = GetInt("count")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid integer "twelve" for count`)
+}
+
+func TestGetDuration(t *testing.T) {
+	withInputs(t, Input{Name: "timeout", Env: "INPUT_TIMEOUT", Type: Duration})
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+		errMsg   string
+	}{
+		{value: "", expected: 0},
+		{value: "90", expected: 90 * time.Second},
+		{value: "5m", expected: 5 * time.Minute},
+		{value: "1h30m", expected: 90 * time.Minute},
+		{value: "soon", errMsg: `invalid duration "soon" for timeout`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv("INPUT_TIMEOUT", tt.value)
+			value, err := GetDuration("timeout")
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, value)
+		})
+	}
+}
+
+func TestGetJSON(t *testing.T) {
+	withInputs(t, Input{Name: "tags", Env: "INPUT_TAGS", Type: JSON})
+
+	t.Setenv("INPUT_TAGS", `{"a": "1"}`)
+	var tags map[string]string
+	require.NoError(t, GetJSON("tags", &tags))
+	assert.Equal(t, map[string]string{"a": "1"}, tags)
+
+	t.Setenv("INPUT_TAGS", "")
+	var unset map[string]string
+	require.NoError(t, GetJSON("tags", &unset))
+	assert.Nil(t, unset)
+
+	t.Setenv("INPUT_TAGS", "not json")
+	err := GetJSON("tags", &tags)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid JSON for tags")
+}
+
+func TestSummary(t *testing.T) {
+	withInputs(t,
+		Input{Name: "agent-type", Env: "INPUT_AGENT_TYPE", Type: String},
+		Input{Name: "config-directory", Env: "INPUT_CONFIG_DIRECTORY", Type: String, Default: ".fleetControl"},
+		Input{Name: "dry-run", Env: "INPUT_DRY_RUN", Type: Bool},
+		Input{Name: "binaries", Env: "INPUT_BINARIES", Type: JSON},
+		Input{Name: "oci-password", Env: "INPUT_OCI_PASSWORD", Type: String, Secret: true},
+		Input{Name: "newrelic-token", Env: "NEWRELIC_TOKEN", Type: String, Required: true, Secret: true},
+		Input{Name: "display-name", Env: "INPUT_DISPLAY_NAME", Type: String},
+	)
+	t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+	t.Setenv("INPUT_CONFIG_DIRECTORY", "")
+	t.Setenv("INPUT_DRY_RUN", "sometimes")
+	t.Setenv("INPUT_BINARIES", "[")
+	t.Setenv("INPUT_OCI_PASSWORD", "hunter2")
+	t.Setenv("NEWRELIC_TOKEN", "")
+	t.Setenv("INPUT_DISPLAY_NAME", "")
+
+	// method under test
+	lines := Summary()
+
+	assert.Equal(t, []string{
+		"agent-type: NRJavaAgent (from INPUT_AGENT_TYPE)",
+		"config-directory: .fleetControl (from default)",
+		"dry-run: sometimes (from INPUT_DRY_RUN) [invalid bool]",
+		"binaries: [ (from INPUT_BINARIES) [invalid json]",
+		"oci-password: *** (from INPUT_OCI_PASSWORD)",
+		"newrelic-token: <not set> (required)",
+	}, lines)
+}
+
+func TestLogSummary(t *testing.T) {
+	t.Setenv("NEWRELIC_TOKEN", "secret-token")
+	t.Setenv("INPUT_OCI_PASSWORD", "secret-password")
+	t.Setenv("INPUT_AGENT_TYPE", "NRJavaAgent")
+
+	getStdout, _ := testutil.CaptureOutput(t)
+
+	// method under test
+	LogSummary(context.Background())
+
+	output := getStdout()
+	assert.Contains(t, output, "::group::Effective configuration")
+	assert.Contains(t, output, "agent-type: NRJavaAgent (from INPUT_AGENT_TYPE)")
+	assert.Contains(t, output, "newrelic-token: *** (from NEWRELIC_TOKEN)")
+	assert.Contains(t, output, "::endgroup::")
+	assert.False(t, strings.Contains(output, "secret-token") || strings.Contains(output, "secret-password"))
+}
+
+func TestInputsAreUnique(t *testing.T) {
+	names := map[string]bool{}
+	envs := map[string]bool{}
+	for _, input := range Inputs {
+		assert.False(t, names[input.Name], "duplicate input name %s", input.Name)
+		assert.False(t, envs[input.Env], "duplicate input env %s", input.Env)
+		names[input.Name] = true
+		envs[input.Env] = true
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	withInputs(t, Input{Name: "version", Env: "INPUT_VERSION", Type: String, Default: "default"})
+	t.Cleanup(Reset)
+
+	configFile := filepath.Join(t.TempDir(), "inputs.yml")
+	require.NoError(t, os.WriteFile(configFile, []byte("version: 1.10\n"), 0644))
+	require.NoError(t, LoadFile(configFile))
+
+	t.Setenv("INPUT_VERSION", "")
+	assert.Equal(t, "1.10", GetString("version"), "config file over default")
+
+	t.Setenv("INPUT_VERSION", "2.0.0")
+	assert.Equal(t, "2.0.0", GetString("version"), "environment over config file")
+
+	require.NoError(t, SetFlag("version", "3.0.0"))
+	assert.Equal(t, "3.0.0", GetString("version"), "flag over environment")
+	assert.Equal(t, []string{"version: 3.0.0 (from command line)"}, Summary())
+
+	assert.Error(t, SetFlag("unknown", "value"))
+}
+
+func TestLoadFile(t *testing.T) {
+	withInputs(t,
+		Input{Name: "binaries", Env: "INPUT_BINARIES", Type: JSON},
+		Input{Name: "dry-run", Env: "INPUT_DRY_RUN", Type: Bool},
+		Input{Name: "display-name", Env: "INPUT_DISPLAY_NAME", Type: String},
+	)
+	t.Cleanup(Reset)
+	t.Setenv("INPUT_BINARIES", "")
+	t.Setenv("INPUT_DRY_RUN", "")
+	t.Setenv("INPUT_DISPLAY_NAME", "")
+
+	configFile := filepath.Join(t.TempDir(), "inputs.yml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+binaries:
+  - name: agent
+    path: ./dist/agent.tar.gz
+dry-run: yes
+display-name: ~
+`), 0644))
+
+	// method under test
+	require.NoError(t, LoadFile(configFile))
+
+	var binaries []map[string]string
+	require.NoError(t, GetJSON("binaries", &binaries))
+	assert.Equal(t, []map[string]string{{"name": "agent", "path": "./dist/agent.tar.gz"}}, binaries)
+	assert.True(t, GetBool("dry-run"))
+	assert.Equal(t, "", GetString("display-name"))
+}
+
+func TestLoadFile_Errors(t *testing.T) {
+	withInputs(t, Input{Name: "version", Env: "INPUT_VERSION", Type: String})
+	t.Cleanup(Reset)
+	dir := t.TempDir()
+
+	err := LoadFile(filepath.Join(dir, "missing.yml"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read config file")
+
+	invalid := filepath.Join(dir, "invalid.yml")
+	require.NoError(t, os.WriteFile(invalid, []byte("- not a map\n"), 0644))
+	err = LoadFile(invalid)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse config file")
+
+	unknown := filepath.Join(dir, "unknown.yml")
+	require.NoError(t, os.WriteFile(unknown, []byte("versoin: 1.0.0\n"), 0644))
+	err = LoadFile(unknown)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown input "versoin"`)
+}
@@ -0,0 +1,137 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+)
+
+// Lister is the subset of the instrumentation client used to build an inventory
+type Lister interface {
+	ListAgentTypes(ctx context.Context) ([]string, error)
+	ListReleases(ctx context.Context, agentType string) ([]models.ReleaseSummary, error)
+}
+
+// Entry is one registered agent version in the inventory
+type Entry struct {
+	AgentType   string `json:"agentType"`
+	Version     string `json:"version"`
+	ReleaseDate string `json:"releaseDate,omitempty"`
+	EOL         string `json:"eol,omitempty"`
+}
+
+// Filter selects the entries of an inventory
+// Empty AgentTypes selects every registered agent type; a zero EOLBefore selects every version
+type Filter struct {
+	AgentTypes []string
+	EOLBefore  time.Time
+}
+
+// Matches reports whether the filter selects the entry
+// With EOLBefore set, versions without an EOL date, or with one that isn't a valid date, are left out
+func (f Filter) Matches(entry Entry) bool {
+	if len(f.AgentTypes) > 0 && !slices.Contains(f.AgentTypes, entry.AgentType) {
+		return false
+	}
+	if f.EOLBefore.IsZero() {
+		return true
+	}
+	eol, err := models.ParseDate(entry.EOL)
+	return err == nil && eol.Before(f.EOLBefore)
+}
+
+// Collect lists the versions of every agent type the filter selects, sorted by agent type, with the versions of
+// each in the order the service lists them
+// Agent types the filter names are listed without looking up the registered ones
+func Collect(ctx context.Context, lister Lister, filter Filter) ([]Entry, error) {
+	agentTypes := filter.AgentTypes
+	if len(agentTypes) == 0 {
+		var err error
+		if agentTypes, err = lister.ListAgentTypes(ctx); err != nil {
+			return nil, fmt.Errorf("failed to list agent types: %w", err)
+		}
+	}
+
+	entries := []Entry{}
+	for _, agentType := range agentTypes {
+		releases, err := lister.ListReleases(ctx, agentType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the releases of %s: %w", agentType, err)
+		}
+		logging.Debugf(ctx, "%s has %d releases", agentType, len(releases))
+		for _, release := range releases {
+			entry := Entry{AgentType: agentType, Version: release.Version, ReleaseDate: release.ReleaseDate, EOL: release.EOL}
+			if filter.Matches(entry) {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].AgentType < entries[j].AgentType })
+	return entries, nil
+}
+
+// Formats the inventory is written in, by file extension
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// FormatOf returns the format of an inventory file by its extension
+func FormatOf(path string) (string, error) {
+	switch format := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."); format {
+	case FormatCSV, FormatJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%s must have a .%s or .%s extension", path, FormatCSV, FormatJSON)
+	}
+}
+
+// Write writes the entries to path as CSV or JSON by its extension, creating its directory
+func Write(path string, entries []Entry) error {
+	format, err := FormatOf(path)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	if format == FormatCSV {
+		data, err = encodeCSV(entries)
+	} else {
+		data, err = json.MarshalIndent(entries, "", "  ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode inventory: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create inventory directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// encodeCSV renders the entries as CSV with a header row
+func encodeCSV(entries []Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	rows := [][]string{{"agentType", "version", "releaseDate", "eol"}}
+	for _, entry := range entries {
+		rows = append(rows, []string{entry.AgentType, entry.Version, entry.ReleaseDate, entry.EOL})
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
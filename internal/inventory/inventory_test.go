@@ -0,0 +1,131 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"agent-metadata-action/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLister struct {
+	agentTypes []string
+	releases   map[string][]models.ReleaseSummary
+	listed     []string
+}
+
+func (f *fakeLister) ListAgentTypes(ctx context.Context) ([]string, error) {
+	if f.agentTypes == nil {
+		return nil, errors.New("catalog unavailable")
+	}
+	return f.agentTypes, nil
+}
+
+func (f *fakeLister) ListReleases(ctx context.Context, agentType string) ([]models.ReleaseSummary, error) {
+	f.listed = append(f.listed, agentType)
+	return f.releases[agentType], nil
+}
+
+func newFakeLister() *fakeLister {
+	return &fakeLister{
+		agentTypes: []string{"NRNodeAgent", "NRJavaAgent"},
+		releases: map[string][]models.ReleaseSummary{
+			"NRJavaAgent": {
+				{Version: "8.0.0", ReleaseDate: "2024-01-01", EOL: "2026-01-01"},
+				{Version: "9.0.0", ReleaseDate: "2026-01-01"},
+			},
+			"NRNodeAgent": {
+				{Version: "12.0.0", ReleaseDate: "2025-01-01", EOL: "2027-01-01"},
+			},
+		},
+	}
+}
+
+func TestCollect(t *testing.T) {
+	t.Run("every registered agent type", func(t *testing.T) {
+		// method under test
+		entries, err := Collect(context.Background(), newFakeLister(), Filter{})
+
+		require.NoError(t, err)
+		assert.Equal(t, []Entry{
+			{AgentType: "NRJavaAgent", Version: "8.0.0", ReleaseDate: "2024-01-01", EOL: "2026-01-01"},
+			{AgentType: "NRJavaAgent", Version: "9.0.0", ReleaseDate: "2026-01-01"},
+			{AgentType: "NRNodeAgent", Version: "12.0.0", ReleaseDate: "2025-01-01", EOL: "2027-01-01"},
+		}, entries)
+	})
+
+	t.Run("named agent types", func(t *testing.T) {
+		lister := newFakeLister()
+		lister.agentTypes = nil
+
+		// method under test
+		entries, err := Collect(context.Background(), lister, Filter{AgentTypes: []string{"NRNodeAgent"}})
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"NRNodeAgent"}, lister.listed)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "12.0.0", entries[0].Version)
+	})
+
+	t.Run("eol before", func(t *testing.T) {
+		// method under test
+		entries, err := Collect(context.Background(), newFakeLister(), Filter{EOLBefore: time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)})
+
+		require.NoError(t, err)
+		assert.Equal(t, []Entry{{AgentType: "NRJavaAgent", Version: "8.0.0", ReleaseDate: "2024-01-01", EOL: "2026-01-01"}}, entries)
+	})
+
+	t.Run("catalog error", func(t *testing.T) {
+		lister := newFakeLister()
+		lister.agentTypes = nil
+
+		// method under test
+		_, err := Collect(context.Background(), lister, Filter{})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to list agent types: catalog unavailable")
+	})
+}
+
+func TestWrite(t *testing.T) {
+	entries := []Entry{
+		{AgentType: "NRJavaAgent", Version: "8.0.0", ReleaseDate: "2024-01-01", EOL: "2026-01-01"},
+		{AgentType: "NRJavaAgent", Version: "9.0.0"},
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "reports", "inventory.csv")
+
+		// method under test
+		require.NoError(t, Write(path, entries))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "agentType,version,releaseDate,eol\nNRJavaAgent,8.0.0,2024-01-01,2026-01-01\nNRJavaAgent,9.0.0,,\n", string(data))
+	})
+
+	t.Run("json", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "inventory.json")
+
+		// method under test
+		require.NoError(t, Write(path, entries))
+
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.JSONEq(t, `[{"agentType":"NRJavaAgent","version":"8.0.0","releaseDate":"2024-01-01","eol":"2026-01-01"},{"agentType":"NRJavaAgent","version":"9.0.0"}]`, string(data))
+	})
+
+	t.Run("unknown extension", func(t *testing.T) {
+		// method under test
+		err := Write(filepath.Join(t.TempDir(), "inventory.txt"), entries)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must have a .csv or .json extension")
+	})
+}
@@ -0,0 +1,134 @@
+package journal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"agent-metadata-action/internal/logging"
+)
+
+// Operations a release completes, as journaled
+const (
+	OperationManifest  = "manifest"  // a manifest pushed to the registry
+	OperationIndex     = "index"     // a manifest index pushed to the registry and tagged
+	OperationSignature = "signature" // signing of a manifest index requested from the signing service
+	OperationMetadata  = "metadata"  // agent metadata stored by the instrumentation service
+)
+
+// Entry is an operation a run completed, with what undoing it needs
+type Entry struct {
+	Operation string    `json:"operation"`
+	Time      time.Time `json:"time"`
+	AgentType string    `json:"agentType,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	Registry  string    `json:"registry,omitempty"`
+	Tag       string    `json:"tag,omitempty"`
+	Digest    string    `json:"digest,omitempty"`
+	Name      string    `json:"name,omitempty"` // artifact name of a manifest
+	ID        string    `json:"id,omitempty"`   // submission or signature ID the service returned
+}
+
+// Journal appends the operations of a run to a file as each completes, so the file lists them even if the run is
+// killed midway
+type Journal struct {
+	mu   sync.Mutex
+	path string
+}
+
+// Create starts the journal of a run at path, creating its directory and replacing an earlier journal
+func Create(path string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	return &Journal{path: path}, nil
+}
+
+// Append writes entry to the end of the journal as a line of JSON, syncing it to disk
+func (j *Journal) Append(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal entry: %w", err)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	file, err := os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Read reads the entries of a journal written by Append, in the order the operations completed
+// A last line cut short, as by a run killed while writing it, is ignored
+func Read(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	var pending error
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if pending != nil {
+			return nil, pending
+		}
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			pending = fmt.Errorf("invalid journal %s: line %d: %w", path, line, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+type journalKey struct{}
+
+// WithJournal returns a context carrying the journal
+func WithJournal(ctx context.Context, journal *Journal) context.Context {
+	return context.WithValue(ctx, journalKey{}, journal)
+}
+
+// FromContext returns the journal in the context, or nil
+func FromContext(ctx context.Context) *Journal {
+	journal, _ := ctx.Value(journalKey{}).(*Journal)
+	return journal
+}
+
+// Record appends entry, stamped with the current time, to the journal in the context
+// No-op if the context has no journal; a failed write only warns, since the operation itself succeeded
+func Record(ctx context.Context, entry Entry) {
+	journal := FromContext(ctx)
+	if journal == nil {
+		return
+	}
+	entry.Time = time.Now().UTC()
+	if err := journal.Append(entry); err != nil {
+		logging.Warnf(ctx, "Failed to journal the %s operation: %v - a rollback won't undo it", entry.Operation, err)
+	}
+}
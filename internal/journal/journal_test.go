@@ -0,0 +1,79 @@
+package journal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".release", "journal.jsonl")
+	journal, err := Create(path)
+	require.NoError(t, err)
+	ctx := WithJournal(context.Background(), journal)
+
+	// method under test
+	Record(ctx, Entry{Operation: OperationManifest, Registry: "docker.io/newrelic/agents", Digest: "sha256:linux", Name: "linux-amd64"})
+	Record(ctx, Entry{Operation: OperationIndex, Registry: "docker.io/newrelic/agents", Tag: "1.2.3", Digest: "sha256:index"})
+
+	entries, err := Read(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, OperationManifest, entries[0].Operation)
+	assert.Equal(t, "linux-amd64", entries[0].Name)
+	assert.False(t, entries[0].Time.IsZero())
+	assert.Equal(t, "1.2.3", entries[1].Tag)
+
+	t.Run("create replaces an earlier journal", func(t *testing.T) {
+		_, err := Create(path)
+		require.NoError(t, err)
+
+		entries, err := Read(path)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestRecordWithoutJournal(t *testing.T) {
+	ctx := context.Background()
+
+	// method under test - no-op without a journal in the context
+	Record(ctx, Entry{Operation: OperationMetadata, AgentType: "dotnet-agent", Version: "1.2.3"})
+
+	assert.Nil(t, FromContext(ctx))
+}
+
+func TestRead(t *testing.T) {
+	t.Run("ignores a last line cut short", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "journal.jsonl")
+		require.NoError(t, os.WriteFile(path, []byte("{\"operation\":\"manifest\",\"digest\":\"sha256:linux\"}\n{\"operation\":\"ind"), 0644))
+
+		// method under test
+		entries, err := Read(path)
+
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "sha256:linux", entries[0].Digest)
+	})
+
+	t.Run("rejects an invalid line before the last", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "journal.jsonl")
+		require.NoError(t, os.WriteFile(path, []byte("not json\n{\"operation\":\"manifest\"}\n"), 0644))
+
+		// method under test
+		_, err := Read(path)
+
+		assert.ErrorContains(t, err, "line 1")
+	})
+
+	t.Run("missing journal", func(t *testing.T) {
+		// method under test
+		_, err := Read(filepath.Join(t.TempDir(), "journal.jsonl"))
+
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
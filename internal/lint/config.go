@@ -0,0 +1,107 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the content of lint.yml in the config directory of an agent repository
+//
+//	rules:
+//	  description-length:
+//	    severity: error
+//	    max: 200
+//	  property-title:
+//	    severity: off
+type Config struct {
+	Rules map[string]RuleConfig `yaml:"rules"`
+}
+
+// RuleConfig overrides the severity of a rule and sets its options
+type RuleConfig struct {
+	Severity Severity `yaml:"severity"`
+	Options  Options  `yaml:",inline"`
+}
+
+// Options are the rule-specific settings of a rule in lint.yml
+type Options map[string]interface{}
+
+// LoadConfig reads a lint.yml file
+// A missing file gives the default configuration, with every rule at its default severity
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return cfg, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := cfg.validate(Rules); err != nil {
+		return cfg, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Severity returns the effective severity of a rule
+func (c Config) Severity(rule Rule) Severity {
+	if override := c.Rules[rule.ID].Severity; override != "" {
+		return override
+	}
+	return rule.Severity
+}
+
+// validate rejects unknown rule IDs and severities, so a typo doesn't silently leave a rule at its default
+func (c Config) validate(rules []Rule) error {
+	known := map[string]bool{}
+	for _, rule := range rules {
+		known[rule.ID] = true
+	}
+	for id, rule := range c.Rules {
+		if !known[id] {
+			return fmt.Errorf("unknown lint rule %q", id)
+		}
+		switch rule.Severity {
+		case "", SeverityError, SeverityWarning, SeverityNote, SeverityOff:
+		default:
+			return fmt.Errorf("invalid severity %q for lint rule %s: must be error, warning, note or off", rule.Severity, id)
+		}
+	}
+	return nil
+}
+
+// Int returns an integer option, or def if it is not set
+func (o Options) Int(name string, def int) (int, error) {
+	value, ok := o[name]
+	if !ok {
+		return def, nil
+	}
+	n, ok := value.(int)
+	if !ok {
+		return 0, fmt.Errorf("option %s must be an integer, got %v", name, value)
+	}
+	return n, nil
+}
+
+// Pattern returns a regular expression option, or def if it is not set
+func (o Options) Pattern(name string, def *regexp.Regexp) (*regexp.Regexp, error) {
+	value, ok := o[name]
+	if !ok {
+		return def, nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("option %s must be a regular expression string, got %v", name, value)
+	}
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return nil, fmt.Errorf("option %s is not a valid regular expression: %w", name, err)
+	}
+	return re, nil
+}
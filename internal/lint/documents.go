@@ -0,0 +1,105 @@
+package lint
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"agent-metadata-action/internal/config"
+	"agent-metadata-action/internal/decrypt"
+	"agent-metadata-action/internal/fileutil"
+	"agent-metadata-action/internal/github"
+	"agent-metadata-action/internal/logging"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load parses the configuration definitions file and the local schemas its definitions reference
+// configDir and definitionsFile are relative to the workspace; schema paths are relative to configDir, with
+// placeholders expanded like when the metadata is loaded
+// Schemas referenced from other repositories are linted in their own repository, and schemas that can't be read
+// or parsed are skipped since loading the metadata reports them, as are encrypted schemas
+func Load(ctx context.Context, workspace, configDir, definitionsFile string, placeholders config.Placeholders) ([]Document, error) {
+	root, err := parseFile(filepath.Join(workspace, definitionsFile))
+	if err != nil {
+		return nil, err
+	}
+	docs := []Document{{Path: filepath.ToSlash(definitionsFile), Kind: KindDefinitions, Root: root}}
+
+	seen := map[string]bool{}
+	for _, definition := range definitionNodes(root) {
+		schema := mappingValue(definition, "schema")
+		if schema == nil || schema.Kind != yaml.ScalarNode || schema.Value == "" {
+			continue
+		}
+		schemaPath := placeholders.Expand(schema.Value)
+		if _, isRemote, _ := github.ParseContentRef(schemaPath); isRemote {
+			continue
+		}
+
+		path := filepath.Join(configDir, fileutil.NormalizePath(schemaPath))
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		fullPath := filepath.Join(workspace, path)
+		if within, err := fileutil.IsWithin(workspace, fullPath); err != nil || !within {
+			continue
+		}
+		schemaRoot, err := parseFile(fullPath)
+		if err != nil {
+			logging.Debugf(ctx, "Not linting schema %s: %v", path, err)
+			continue
+		}
+		docs = append(docs, Document{Path: filepath.ToSlash(path), Kind: KindSchema, Root: schemaRoot})
+	}
+	return docs, nil
+}
+
+// parseFile parses a YAML or JSON file into its top-level node
+func parseFile(path string) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	// Findings in an encrypted schema couldn't point at the lines they apply to
+	if format := decrypt.Detect(path, data); format != decrypt.None {
+		return nil, fmt.Errorf("%s is encrypted with %s", path, format)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	return doc.Content[0], nil
+}
+
+// definitionNodes returns the items of the first top-level list of a definitions file, like the loader reads it
+func definitionNodes(root *yaml.Node) []*yaml.Node {
+	if root == nil || root.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 1; i < len(root.Content); i += 2 {
+		if value := root.Content[i]; value.Kind == yaml.SequenceNode {
+			return value.Content
+		}
+	}
+	return nil
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity is how a finding affects the run
+type Severity string
+
+const (
+	SeverityError   Severity = "error"   // fails the run
+	SeverityWarning Severity = "warning" // reported without failing
+	SeverityNote    Severity = "note"    // informational
+	SeverityOff     Severity = "off"     // rule disabled
+)
+
+// Kind is the type of file a document was parsed from
+type Kind string
+
+const (
+	KindDefinitions Kind = "definitions" // configurationDefinitions.yml
+	KindSchema      Kind = "schema"      // a JSON schema referenced by a configuration definition
+)
+
+// Document is a parsed file the rules check
+type Document struct {
+	Path string     // relative to the workspace, used in findings
+	Kind Kind       // what the file holds
+	Root *yaml.Node // the top-level value, with line and column positions
+}
+
+// Problem is something a rule found at a node of a document
+type Problem struct {
+	Node    *yaml.Node
+	Message string
+}
+
+// Rule is a lint check over parsed documents
+// Check is called for every document and returns what it found; options are the rule's settings from lint.yml
+type Rule struct {
+	ID          string
+	Description string
+	Severity    Severity // used unless lint.yml overrides it
+	Check       func(doc Document, options Options) ([]Problem, error)
+}
+
+// Rules are the rules Run applies; add a Rule here to extend the linter
+var Rules = []Rule{
+	descriptionLengthRule,
+	propertyTitleRule,
+	enumNamingRule,
+	topLevelAdditionalPropertiesRule,
+}
+
+// Finding is a problem reported by a rule at its configured severity
+type Finding struct {
+	RuleID   string
+	Severity Severity
+	Path     string
+	Line     int
+	Column   int
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s [%s]", f.Path, f.Line, f.Column, f.Message, f.RuleID)
+}
+
+// Run applies every enabled rule to the documents and returns the findings ordered by file and position
+// Invalid rule options in cfg are returned as an error
+func Run(docs []Document, cfg Config) ([]Finding, error) {
+	if err := cfg.validate(Rules); err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, rule := range Rules {
+		severity := cfg.Severity(rule)
+		if severity == SeverityOff {
+			continue
+		}
+		options := cfg.Rules[rule.ID].Options
+		for _, doc := range docs {
+			problems, err := rule.Check(doc, options)
+			if err != nil {
+				return nil, fmt.Errorf("lint rule %s: %w", rule.ID, err)
+			}
+			for _, problem := range problems {
+				finding := Finding{RuleID: rule.ID, Severity: severity, Path: doc.Path, Message: problem.Message}
+				if problem.Node != nil {
+					finding.Line, finding.Column = problem.Node.Line, problem.Node.Column
+				}
+				findings = append(findings, finding)
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Path != findings[j].Path {
+			return findings[i].Path < findings[j].Path
+		}
+		if findings[i].Line != findings[j].Line {
+			return findings[i].Line < findings[j].Line
+		}
+		return findings[i].Column < findings[j].Column
+	})
+	return findings, nil
+}
+
+// Count returns the number of findings with the given severity
+func Count(findings []Finding, severity Severity) int {
+	count := 0
+	for _, finding := range findings {
+		if finding.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
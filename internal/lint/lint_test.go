@@ -0,0 +1,224 @@
+package lint
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/config"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `{
+  "type": "object",
+  "additionalProperties": true,
+  "properties": {
+    "log_level": {
+      "title": "Log level",
+      "type": "string",
+      "enum": ["debug", "INFO", 3]
+    },
+    "labels": {
+      "type": "object",
+      "description": "` + "%s" + `",
+      "additionalProperties": { "type": "string" }
+    }
+  }
+}
+`
+
+// writeWorkspace creates a config directory with one configuration definition and its schema
+func writeWorkspace(t *testing.T, description string) string {
+	t.Helper()
+	workspace := t.TempDir()
+	configDir := filepath.Join(workspace, ".fleetControl")
+	require.NoError(t, os.MkdirAll(filepath.Join(configDir, "schemas"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "configurationDefinitions.yml"), []byte(`configurationDefinitions:
+  - platform: ALL
+    description: agent configuration
+    type: agent-config
+    version: 1.0.0
+    schema: ./schemas/config.json
+  - platform: LINUX
+    type: agent-config
+    version: 1.0.0
+    schema: ./schemas/config.json
+  - platform: KUBERNETES
+    type: agent-config
+    version: 1.0.0
+    schema: newrelic/shared-schemas:agent.json@v1
+`), 0644))
+	schema := strings.Replace(testSchema, "%s", description, 1)
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "schemas", "config.json"), []byte(schema), 0644))
+	return workspace
+}
+
+func load(t *testing.T, workspace string) []Document {
+	t.Helper()
+	docs, err := Load(context.Background(), workspace, ".fleetControl", ".fleetControl/configurationDefinitions.yml", config.Placeholders{})
+	require.NoError(t, err)
+	return docs
+}
+
+func TestLoad(t *testing.T) {
+	docs := load(t, writeWorkspace(t, "labels"))
+
+	// The shared local schema is loaded once and the remote one is skipped
+	require.Len(t, docs, 2)
+	assert.Equal(t, ".fleetControl/configurationDefinitions.yml", docs[0].Path)
+	assert.Equal(t, KindDefinitions, docs[0].Kind)
+	assert.Equal(t, ".fleetControl/schemas/config.json", docs[1].Path)
+	assert.Equal(t, KindSchema, docs[1].Kind)
+}
+
+func TestLoad_SkipsUnreadableSchemas(t *testing.T) {
+	workspace := writeWorkspace(t, "labels")
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, ".fleetControl", "schemas", "config.json"), []byte("{"), 0644))
+	testutil.CaptureOutput(t)
+
+	docs := load(t, workspace)
+
+	require.Len(t, docs, 1)
+	assert.Equal(t, KindDefinitions, docs[0].Kind)
+}
+
+func TestLoad_SkipsEncryptedSchemas(t *testing.T) {
+	workspace := writeWorkspace(t, "labels")
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, ".fleetControl", "schemas", "config.json"),
+		[]byte(`{"type": "ENC[AES256_GCM,data:abc=]", "sops": {"mac": "ENC[AES256_GCM,data:def=]"}}`), 0644))
+	testutil.CaptureOutput(t)
+
+	docs := load(t, workspace)
+
+	require.Len(t, docs, 1)
+	assert.Equal(t, KindDefinitions, docs[0].Kind)
+}
+
+func TestLoad_ExpandsPlaceholders(t *testing.T) {
+	workspace := t.TempDir()
+	configDir := filepath.Join(workspace, ".fleetControl")
+	require.NoError(t, os.MkdirAll(filepath.Join(configDir, "schemas", "2.0.0"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "schemas", "2.0.0", "config.json"), []byte(`{"type": "object"}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "configurationDefinitions.yml"), []byte(`configurationDefinitions:
+  - type: agent-config
+    schema: ./schemas/${VERSION}/config.json
+`), 0644))
+
+	docs, err := Load(context.Background(), workspace, ".fleetControl", ".fleetControl/configurationDefinitions.yml", config.Placeholders{Version: "2.0.0"})
+
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	assert.Equal(t, ".fleetControl/schemas/2.0.0/config.json", docs[1].Path)
+}
+
+func TestLoad_MissingDefinitions(t *testing.T) {
+	_, err := Load(context.Background(), t.TempDir(), ".fleetControl", ".fleetControl/configurationDefinitions.yml", config.Placeholders{})
+	require.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	docs := load(t, writeWorkspace(t, strings.Repeat("x", 30)))
+	cfg := Config{Rules: map[string]RuleConfig{
+		"description-length":              {Options: Options{"max": 20}},
+		"top-level-additional-properties": {Severity: SeverityError},
+	}}
+
+	// method under test
+	findings, err := Run(docs, cfg)
+
+	require.NoError(t, err)
+	var got []string
+	for _, finding := range findings {
+		got = append(got, finding.String())
+	}
+	assert.Equal(t, []string{
+		`.fleetControl/schemas/config.json:3:27: #: additionalProperties: true accepts any top-level key - list the supported properties instead [top-level-additional-properties]`,
+		`.fleetControl/schemas/config.json:8:25: #/properties/log_level: enum value "INFO" does not match ^[a-z][a-z0-9_]*$ [enum-naming]`,
+		`.fleetControl/schemas/config.json:10:5: #/properties/labels: property "labels" has no title [property-title]`,
+		`.fleetControl/schemas/config.json:12:22: #/properties/labels: description is 30 characters, longer than 20 [description-length]`,
+	}, got)
+	assert.Equal(t, 1, Count(findings, SeverityError))
+	assert.Equal(t, 3, Count(findings, SeverityWarning))
+}
+
+func TestRun_DisabledRules(t *testing.T) {
+	docs := load(t, writeWorkspace(t, "labels"))
+	cfg := Config{Rules: map[string]RuleConfig{}}
+	for _, rule := range Rules {
+		cfg.Rules[rule.ID] = RuleConfig{Severity: SeverityOff}
+	}
+
+	// method under test
+	findings, err := Run(docs, cfg)
+
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestRun_InvalidOptions(t *testing.T) {
+	docs := load(t, writeWorkspace(t, "labels"))
+
+	tests := []struct {
+		name        string
+		cfg         Config
+		expectedErr string
+	}{
+		{name: "non-integer max", cfg: Config{Rules: map[string]RuleConfig{"description-length": {Options: Options{"max": "long"}}}},
+			expectedErr: "lint rule description-length: option max must be an integer"},
+		{name: "invalid pattern", cfg: Config{Rules: map[string]RuleConfig{"enum-naming": {Options: Options{"pattern": "("}}}},
+			expectedErr: "lint rule enum-naming: option pattern is not a valid regular expression"},
+		{name: "unknown rule", cfg: Config{Rules: map[string]RuleConfig{"no-such-rule": {}}},
+			expectedErr: `unknown lint rule "no-such-rule"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			_, err := Run(docs, tt.cfg)
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expectedErr)
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "lint.yml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	t.Run("missing file uses defaults", func(t *testing.T) {
+		cfg, err := LoadConfig(filepath.Join(dir, "missing.yml"))
+		require.NoError(t, err)
+		assert.Equal(t, SeverityWarning, cfg.Severity(propertyTitleRule))
+	})
+
+	t.Run("severities and options", func(t *testing.T) {
+		cfg, err := LoadConfig(write("rules:\n  description-length:\n    severity: error\n    max: 200\n  property-title:\n    severity: off\n"))
+		require.NoError(t, err)
+		assert.Equal(t, SeverityError, cfg.Severity(descriptionLengthRule))
+		assert.Equal(t, SeverityOff, cfg.Severity(propertyTitleRule))
+		assert.Equal(t, SeverityWarning, cfg.Severity(enumNamingRule))
+		assert.Equal(t, Options{"max": 200}, cfg.Rules["description-length"].Options)
+	})
+
+	t.Run("invalid severity", func(t *testing.T) {
+		_, err := LoadConfig(write("rules:\n  property-title:\n    severity: fatal\n"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid severity "fatal" for lint rule property-title`)
+	})
+
+	t.Run("unknown rule", func(t *testing.T) {
+		_, err := LoadConfig(write("rules:\n  property-titles: {}\n"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown lint rule "property-titles"`)
+	})
+}
@@ -0,0 +1,185 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultMaxDescriptionLength keeps descriptions short enough to read in the UI
+const defaultMaxDescriptionLength = 500
+
+// defaultEnumPattern is the naming convention for enum values: lower snake case
+var defaultEnumPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+var descriptionLengthRule = Rule{
+	ID:          "description-length",
+	Description: "Descriptions of configuration definitions and schema properties must not exceed the maximum length (option max, default 500 characters)",
+	Severity:    SeverityWarning,
+	Check: func(doc Document, options Options) ([]Problem, error) {
+		max, err := options.Int("max", defaultMaxDescriptionLength)
+		if err != nil {
+			return nil, err
+		}
+		check := func(node *yaml.Node, at string) *Problem {
+			description := mappingValue(node, "description")
+			if description == nil || description.Kind != yaml.ScalarNode {
+				return nil
+			}
+			if length := utf8.RuneCountInString(description.Value); length > max {
+				return &Problem{Node: description, Message: fmt.Sprintf("%s: description is %d characters, longer than %d", at, length, max)}
+			}
+			return nil
+		}
+
+		var problems []Problem
+		switch doc.Kind {
+		case KindDefinitions:
+			for i, definition := range definitionNodes(doc.Root) {
+				if problem := check(definition, fmt.Sprintf("configuration definition %d", i)); problem != nil {
+					problems = append(problems, *problem)
+				}
+			}
+		case KindSchema:
+			walkSchemas(doc.Root, "#", func(schema *yaml.Node, pointer string) {
+				if problem := check(schema, pointer); problem != nil {
+					problems = append(problems, *problem)
+				}
+			})
+		}
+		return problems, nil
+	},
+}
+
+var propertyTitleRule = Rule{
+	ID:          "property-title",
+	Description: "Every schema property must have a title",
+	Severity:    SeverityWarning,
+	Check: func(doc Document, options Options) ([]Problem, error) {
+		if doc.Kind != KindSchema {
+			return nil, nil
+		}
+		var problems []Problem
+		walkSchemas(doc.Root, "#", func(schema *yaml.Node, pointer string) {
+			properties := mappingValue(schema, "properties")
+			if properties == nil || properties.Kind != yaml.MappingNode {
+				return
+			}
+			for i := 0; i+1 < len(properties.Content); i += 2 {
+				name, property := properties.Content[i], properties.Content[i+1]
+				if property.Kind != yaml.MappingNode {
+					continue
+				}
+				if title := mappingValue(property, "title"); title == nil || strings.TrimSpace(title.Value) == "" {
+					problems = append(problems, Problem{Node: name, Message: fmt.Sprintf("%s: property %q has no title", joinPointer(pointer, "properties", name.Value), name.Value)})
+				}
+			}
+		})
+		return problems, nil
+	},
+}
+
+var enumNamingRule = Rule{
+	ID:          "enum-naming",
+	Description: "String enum values must follow the naming convention (option pattern, default lower snake case)",
+	Severity:    SeverityWarning,
+	Check: func(doc Document, options Options) ([]Problem, error) {
+		pattern, err := options.Pattern("pattern", defaultEnumPattern)
+		if err != nil {
+			return nil, err
+		}
+		if doc.Kind != KindSchema {
+			return nil, nil
+		}
+		var problems []Problem
+		walkSchemas(doc.Root, "#", func(schema *yaml.Node, pointer string) {
+			enum := mappingValue(schema, "enum")
+			if enum == nil || enum.Kind != yaml.SequenceNode {
+				return
+			}
+			for _, value := range enum.Content {
+				// Only strings have a naming convention
+				if value.Kind != yaml.ScalarNode || value.Tag != "!!str" {
+					continue
+				}
+				if !pattern.MatchString(value.Value) {
+					problems = append(problems, Problem{Node: value, Message: fmt.Sprintf("%s: enum value %q does not match %s", pointer, value.Value, pattern)})
+				}
+			}
+		})
+		return problems, nil
+	},
+}
+
+var topLevelAdditionalPropertiesRule = Rule{
+	ID:          "top-level-additional-properties",
+	Description: "The top level of a schema must not allow free-form configuration with additionalProperties: true",
+	Severity:    SeverityWarning,
+	Check: func(doc Document, options Options) ([]Problem, error) {
+		if doc.Kind != KindSchema {
+			return nil, nil
+		}
+		additional := mappingValue(doc.Root, "additionalProperties")
+		if additional == nil || additional.Kind != yaml.ScalarNode || additional.Tag != "!!bool" || additional.Value != "true" {
+			return nil, nil
+		}
+		return []Problem{{Node: additional, Message: "#: additionalProperties: true accepts any top-level key - list the supported properties instead"}}, nil
+	},
+}
+
+// subschemaKeywords hold a single subschema, or a list of them for items
+var subschemaKeywords = []string{"items", "additionalProperties", "additionalItems", "contains", "propertyNames",
+	"unevaluatedProperties", "unevaluatedItems", "not", "if", "then", "else"}
+
+// subschemaListKeywords hold a list of subschemas
+var subschemaListKeywords = []string{"allOf", "anyOf", "oneOf", "prefixItems"}
+
+// subschemaMapKeywords hold subschemas by name
+var subschemaMapKeywords = []string{"properties", "patternProperties", "$defs", "definitions", "dependentSchemas"}
+
+// walkSchemas calls fn for schema and every subschema nested in it, with its JSON pointer
+func walkSchemas(schema *yaml.Node, pointer string, fn func(schema *yaml.Node, pointer string)) {
+	if schema == nil || schema.Kind != yaml.MappingNode {
+		return
+	}
+	fn(schema, pointer)
+
+	for _, keyword := range subschemaKeywords {
+		value := mappingValue(schema, keyword)
+		if value == nil {
+			continue
+		}
+		if value.Kind == yaml.SequenceNode {
+			for i, item := range value.Content {
+				walkSchemas(item, joinPointer(pointer, keyword, fmt.Sprint(i)), fn)
+			}
+			continue
+		}
+		walkSchemas(value, joinPointer(pointer, keyword), fn)
+	}
+	for _, keyword := range subschemaListKeywords {
+		if value := mappingValue(schema, keyword); value != nil && value.Kind == yaml.SequenceNode {
+			for i, item := range value.Content {
+				walkSchemas(item, joinPointer(pointer, keyword, fmt.Sprint(i)), fn)
+			}
+		}
+	}
+	for _, keyword := range subschemaMapKeywords {
+		if value := mappingValue(schema, keyword); value != nil && value.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(value.Content); i += 2 {
+				walkSchemas(value.Content[i+1], joinPointer(pointer, keyword, value.Content[i].Value), fn)
+			}
+		}
+	}
+}
+
+// joinPointer appends tokens to a JSON pointer, escaping "~" and "/"
+func joinPointer(pointer string, tokens ...string) string {
+	for _, token := range tokens {
+		pointer += "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+	}
+	return pointer
+}
@@ -1,28 +1,71 @@
 package loader
 
 import (
+	"agent-metadata-action/internal/agenttype"
 	"agent-metadata-action/internal/config"
+	"agent-metadata-action/internal/decrypt"
+	"agent-metadata-action/internal/fileutil"
+	"agent-metadata-action/internal/github"
 	"agent-metadata-action/internal/logging"
 	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/resources"
+	"agent-metadata-action/internal/validation"
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
-// ReadConfigurationDefinitions reads and parses the configurationDefinitions file
-func ReadConfigurationDefinitions(ctx context.Context, workspacePath string) ([]models.ConfigurationDefinition, error) {
-	fullPath := filepath.Join(workspacePath, config.GetConfigurationDefinitionsFilepath())
+// Fields a definition may have; other keys are rejected as likely typos unless they start with models.ExtensionPrefix
+var (
+	configurationDefinitionFields = []string{"platform", "description", models.LocalizedDescriptionField, "type", "version", "format", "schema", models.DeprecatedField, models.SupersededByField, models.ChangelogField}
+	agentControlDefinitionFields  = []string{"platform", "supportFromAgent", "supportFromAgentControl", "content"}
+)
 
-	definitions, err := readDefinitionsFile(fullPath)
+// ReadConfigurationDefinitions reads and parses the configurationDefinitions file, expanding placeholders in its values
+func ReadConfigurationDefinitions(ctx context.Context, workspacePath string, placeholders config.Placeholders) ([]models.ConfigurationDefinition, error) {
+	path := config.GetConfigurationDefinitionsFilepath()
+	definitions, err := readDefinitionsFile(ctx, workspacePath, path, configurationDefinitionFields, placeholders)
 	if err != nil {
 		return nil, err
 	}
 
+	var errs validation.Errors
+	for i, definition := range definitions {
+		if err := models.ValidateLocalizedDescriptions(definition); err != nil {
+			errs.Add(path, 0, fmt.Sprintf("configurationDefinitions[%d].%s", i, models.LocalizedDescriptionField), err)
+		}
+	}
+	configs := make([]models.ConfigurationDefinition, len(definitions))
+	for i, definition := range definitions {
+		configs[i] = definition
+	}
+	if err := models.ValidateDeprecations(configs); err != nil {
+		errs.Add(path, 0, "", err)
+	}
+	if err := models.ValidateChangelogs(configs); err != nil {
+		errs.Add(path, 0, "", err)
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	// A changelog written as one entry is sent as a list, like one written as several
+	for i, definition := range configs {
+		if entries := models.Changelog(definition); entries != nil {
+			definitions[i][models.ChangelogField] = entries
+		}
+	}
+
+	// Remote schemas referenced by several definitions are fetched once per run
+	remoteSchemas := map[string]string{}
+
 	for i := range definitions {
 		// Skip if no schema path is provided
 		if definitions[i]["schema"] == nil || definitions[i]["schema"] == "" {
@@ -33,16 +76,20 @@ func ReadConfigurationDefinitions(ctx context.Context, workspacePath string) ([]
 		if !ok {
 			// Drop the field so the server doesn't reject the whole request over a malformed type.
 			logging.Warn(ctx, "schema field is not a string - dropping it")
+			github.AddAnnotation(ctx, github.AnnotationWarning, config.GetConfigurationDefinitionsFilepath(),
+				"Invalid schema field", fmt.Sprintf("configuration definition %d: schema field is not a string", i))
 			delete(definitions[i], "schema")
 			continue
 		}
 
 		// @todo at some point, we may want to do this concurrently if there are any agents with a large number of files
-		encoded, err := loadAndEncodeFile(workspacePath, schemaPath, "schema")
+		encoded, err := loadAndEncodeSchema(ctx, workspacePath, schemaPath, remoteSchemas)
 		if err != nil {
 			// Drop the field rather than leaving the path string in place — the server would
 			// otherwise try to base64-decode the path and reject the whole bundled request.
 			logging.Warnf(ctx, "failed to load schema at schema path %s: %v -- dropping schema field", schemaPath, err)
+			github.AddAnnotation(ctx, github.AnnotationWarning, config.GetConfigurationDefinitionsFilepath(),
+				"Schema not loaded", fmt.Sprintf("failed to load schema %s: %v", schemaPath, err))
 			delete(definitions[i], "schema")
 			continue
 		}
@@ -58,14 +105,96 @@ func ReadConfigurationDefinitions(ctx context.Context, workspacePath string) ([]
 	return result, nil
 }
 
-// ReadAgentControlDefinitions reads and parses the agentControlDefinitions file
-func ReadAgentControlDefinitions(ctx context.Context, workspacePath string) ([]models.AgentControlDefinition, error) {
-	fullPath := filepath.Join(workspacePath, config.GetAgentControlDefinitionsFilepath())
+// ReadAgentControlDefinitions reads and parses the agentControlDefinitions file and the per-platform
+// agentControlDefinitions.<platform>.yml files next to it, in that order, expanding placeholders in their values
+func ReadAgentControlDefinitions(ctx context.Context, workspacePath string, placeholders config.Placeholders) ([]models.AgentControlDefinition, error) {
+	paths, err := agentControlDefinitionsFiles(workspacePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []models.AgentControlDefinition
+	for _, path := range paths {
+		definitions, err := readAgentControlDefinitionsFile(ctx, workspacePath, path, placeholders)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, definitions...)
+	}
+	return result, nil
+}
+
+// agentControlDefinitionsFiles returns the agent control definitions files in the config directory: the
+// agentControlDefinitions file if it exists, then the per-platform files sorted by name
+// Without any of them, the agentControlDefinitions file is returned so reading it reports it is missing
+func agentControlDefinitionsFiles(workspacePath string) ([]string, error) {
+	root := config.GetRootFolderForAgentRepo()
+	var paths []string
+	if _, err := os.Stat(filepath.Join(workspacePath, config.GetAgentControlDefinitionsFilepath())); err == nil {
+		paths = append(paths, config.GetAgentControlDefinitionsFilepath())
+	}
+
+	matches, err := filepath.Glob(filepath.Join(workspacePath, root, agentControlPlatformFilePattern))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list agent control definitions files: %w", err)
+	}
+	sort.Strings(matches)
+	for _, match := range matches {
+		paths = append(paths, filepath.Join(root, filepath.Base(match)))
+	}
+
+	if len(paths) == 0 {
+		return []string{config.GetAgentControlDefinitionsFilepath()}, nil
+	}
+	return paths, nil
+}
+
+// agentControlPlatformFilePattern matches the per-platform agent control definitions files
+const agentControlPlatformFilePattern = "agentControlDefinitions.*.yml"
+
+// platformFromFilename returns the platform of a per-platform agent control definitions file, upper-cased like
+// the platform field, or "" for the agentControlDefinitions file
+func platformFromFilename(path string) string {
+	matched, err := filepath.Match(agentControlPlatformFilePattern, filepath.Base(path))
+	if err != nil || !matched {
+		return ""
+	}
+	return strings.ToUpper(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "agentControlDefinitions."), ".yml"))
+}
+
+// readAgentControlDefinitionsFile reads one agent control definitions file
+// The platform of the file, from its filename or its top-level platform field, is set on every definition that
+// doesn't have one; definitions with a different platform or invalid minimum versions are rejected
+func readAgentControlDefinitionsFile(ctx context.Context, workspacePath, path string, placeholders config.Placeholders) ([]models.AgentControlDefinition, error) {
+	definitions, err := readDefinitionsFile(ctx, workspacePath, path, agentControlDefinitionFields, placeholders)
+	if err != nil {
+		return nil, err
+	}
 
-	definitions, err := readDefinitionsFile(fullPath)
+	platform, err := agentControlFilePlatform(workspacePath, path)
 	if err != nil {
 		return nil, err
 	}
+	var errs validation.Errors
+	for i, definition := range definitions {
+		if err := models.ValidateAgentControlVersions(definition); err != nil {
+			errs.Add(path, 0, fmt.Sprintf("agentControlDefinitions[%d]", i), err)
+		}
+		if platform == "" {
+			continue
+		}
+		value, ok := definition["platform"]
+		if !ok || value == nil || value == "" {
+			definitions[i]["platform"] = platform
+			continue
+		}
+		if value != platform {
+			errs.Addf(path, 0, fmt.Sprintf("agentControlDefinitions[%d].platform", i), "platform %v does not match the %s platform of the file", value, platform)
+		}
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
 
 	// Load and encode content files
 	for i := range definitions {
@@ -78,16 +207,20 @@ func ReadAgentControlDefinitions(ctx context.Context, workspacePath string) ([]m
 		if !ok {
 			// Drop the field so the server doesn't reject the whole request over a malformed type.
 			logging.Warn(ctx, "content field is not a string - dropping it")
+			github.AddAnnotation(ctx, github.AnnotationWarning, path,
+				"Invalid content field", fmt.Sprintf("agent control definition %d: content field is not a string", i))
 			delete(definitions[i], "content")
 			continue
 		}
 
 		// @todo at some point, we may want to do this concurrently if there are any agents with a large number of files
-		encoded, err := loadAndEncodeFile(workspacePath, contentPath, "content")
+		encoded, err := loadAndEncodeFile(ctx, workspacePath, contentPath, "content")
 		if err != nil {
 			// Drop the field rather than leaving the path string in place — the server would
 			// otherwise try to base64-decode the path and reject the whole bundled request.
 			logging.Warnf(ctx, "failed to load content at path %s: %v -- dropping content field", contentPath, err)
+			github.AddAnnotation(ctx, github.AnnotationWarning, path,
+				"Content not loaded", fmt.Sprintf("failed to load content %s: %v", contentPath, err))
 			delete(definitions[i], "content")
 			continue
 		}
@@ -103,6 +236,28 @@ func ReadAgentControlDefinitions(ctx context.Context, workspacePath string) ([]m
 	return result, nil
 }
 
+// agentControlFilePlatform returns the platform every definition in an agent control definitions file is for,
+// from its filename or its top-level platform field, or "" if the file is for several platforms
+func agentControlFilePlatform(workspacePath, path string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(workspacePath, path))
+	if err != nil {
+		return "", fmt.Errorf("failed to read file at %s: %w", path, err)
+	}
+	var file models.AgentControlFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return "", fmt.Errorf("failed to parse YAML: %w", err)
+	}
+
+	fromFilename := platformFromFilename(path)
+	switch {
+	case file.Platform == "":
+		return fromFilename, nil
+	case fromFilename != "" && file.Platform != fromFilename:
+		return "", fmt.Errorf("%s: platform %s does not match the %s platform of the filename", path, file.Platform, fromFilename)
+	}
+	return file.Platform, nil
+}
+
 // ReadAgentDefinition reads the optional agentDefinition.yml file.
 // Returns nil, nil if the file does not exist (the file is optional).
 func ReadAgentDefinition(ctx context.Context, workspacePath string) (*models.AgentDefinition, error) {
@@ -126,8 +281,12 @@ func ReadAgentDefinition(ctx context.Context, workspacePath string) (*models.Age
 
 // readDefinitionsFile reads a YAML file and extracts the first array it finds at the top level.
 // This is a generic function that works for both configurationDefinitions and agentControlDefinitions files.
-// It returns the array of definitions as []map[string]interface{}.
-func readDefinitionsFile(fullPath string) ([]map[string]interface{}, error) {
+// It returns the array of definitions as []map[string]interface{}, with ${VERSION} and ${AGENT_TYPE}
+// placeholders in their string values expanded from placeholders.
+// Definitions may only have knownFields and x- extension fields; every other field is annotated.
+// Malformed items and unknown fields are all returned together as validation.Errors.
+func readDefinitionsFile(ctx context.Context, workspacePath, path string, knownFields []string, placeholders config.Placeholders) ([]map[string]interface{}, error) {
+	fullPath := filepath.Join(workspacePath, path)
 	data, err := os.ReadFile(fullPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file at %s: %w", fullPath, err)
@@ -143,17 +302,22 @@ func readDefinitionsFile(fullPath string) ([]map[string]interface{}, error) {
 	for key, value := range fileContent {
 		if arr, ok := value.([]interface{}); ok {
 			// Convert []interface{} to []map[string]interface{}
+			if len(arr) == 0 {
+				return nil, fmt.Errorf("%s cannot be empty", key)
+			}
+
+			var errs validation.Errors
 			definitions := make([]map[string]interface{}, 0, len(arr))
 			for i, item := range arr {
 				if def, ok := item.(map[string]interface{}); ok {
-					definitions = append(definitions, def)
+					definitions = append(definitions, expandPlaceholders(def, placeholders).(map[string]interface{}))
 				} else {
-					return nil, fmt.Errorf("item %d in %s is not a map", i, key)
+					errs.Addf(path, 0, fmt.Sprintf("%s[%d]", key, i), "item %d in %s is not a map", i, key)
 				}
 			}
-
-			if len(definitions) == 0 {
-				return nil, fmt.Errorf("%s cannot be empty", key)
+			checkUnknownFields(ctx, path, data, key, knownFields, &errs)
+			if err := errs.Err(); err != nil {
+				return nil, err
 			}
 
 			return definitions, nil
@@ -163,28 +327,145 @@ func readDefinitionsFile(fullPath string) ([]map[string]interface{}, error) {
 	return nil, fmt.Errorf("no array found in YAML file")
 }
 
-// loadAndEncodeFile reads a file (schema, agent control, etc.) and returns its base64-encoded content.
+// checkUnknownFields annotates every field of the definitions under key that isn't known or an extension field,
+// suggesting the known field it is likely a typo of, and adds each to errs
+func checkUnknownFields(ctx context.Context, path string, data []byte, key string, knownFields []string, errs *validation.Errors) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return
+	}
+	root := doc.Content[0]
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != key {
+			continue
+		}
+		for index, definition := range root.Content[i+1].Content {
+			for j := 0; j+1 < len(definition.Content); j += 2 {
+				field := definition.Content[j]
+				if slices.Contains(knownFields, field.Value) || strings.HasPrefix(field.Value, models.ExtensionPrefix) {
+					continue
+				}
+				message := fmt.Sprintf("unknown field %q", field.Value)
+				if suggestion := agenttype.Suggest(field.Value, knownFields); suggestion != "" {
+					message += fmt.Sprintf(" - did you mean %q?", suggestion)
+				} else {
+					message += fmt.Sprintf(" - known fields are %s; prefix custom fields with %s", strings.Join(knownFields, ", "), models.ExtensionPrefix)
+				}
+				github.RecordAnnotation(ctx, github.Annotation{
+					Path:            path,
+					StartLine:       field.Line,
+					StartColumn:     field.Column,
+					AnnotationLevel: github.AnnotationFailure,
+					Title:           "Unknown field",
+					Message:         fmt.Sprintf("%s[%d]: %s", key, index, message),
+				})
+				errs.Add(path, field.Line, fmt.Sprintf("%s[%d]", key, index), errors.New(message))
+			}
+		}
+	}
+}
+
+// expandPlaceholders expands placeholders in every string value nested in a parsed YAML value; keys are unchanged
+func expandPlaceholders(value interface{}, placeholders config.Placeholders) interface{} {
+	switch v := value.(type) {
+	case string:
+		return placeholders.Expand(v)
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = expandPlaceholders(item, placeholders)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = expandPlaceholders(item, placeholders)
+		}
+	}
+	return value
+}
+
+// fetchRemoteContentFunc is a variable that holds the function to fetch a file from another repository
+// This allows tests to override the implementation
+var fetchRemoteContentFunc = func(ctx context.Context, ref github.ContentRef) ([]byte, error) {
+	return github.GetClient().GetFileContent(ctx, ref, github.MaxRemoteContentSize)
+}
+
+// RemoteSchemaRepos returns the repositories the configuration definitions fetch remote schemas from, sorted
+// A definitions file that can't be read or parsed gives none; loading the definitions reports the problem
+func RemoteSchemaRepos(workspacePath string, placeholders config.Placeholders) []string {
+	data, err := os.ReadFile(filepath.Join(workspacePath, config.GetConfigurationDefinitionsFilepath()))
+	if err != nil {
+		return nil
+	}
+	var file models.ConfigFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil
+	}
+
+	var repos []string
+	for _, definition := range file.Configs {
+		schemaPath, ok := definition["schema"].(string)
+		if !ok {
+			continue
+		}
+		if ref, isRemote, err := github.ParseContentRef(placeholders.Expand(schemaPath)); isRemote && err == nil && !slices.Contains(repos, ref.Repo) {
+			repos = append(repos, ref.Repo)
+		}
+	}
+	sort.Strings(repos)
+	return repos
+}
+
+// loadAndEncodeSchema loads a schema from a local path relative to the config directory, or from
+// another repository when the schema is an "owner/repo:path@ref" reference, and base64-encodes it.
+// Remote results are memoized in cache so shared schemas are only fetched once.
+func loadAndEncodeSchema(ctx context.Context, workspacePath, schemaPath string, cache map[string]string) (string, error) {
+	ref, isRemote, err := github.ParseContentRef(schemaPath)
+	if err != nil {
+		return "", err
+	}
+	if !isRemote {
+		return loadAndEncodeFile(ctx, workspacePath, schemaPath, "schema")
+	}
+
+	if encoded, ok := cache[ref.String()]; ok {
+		logging.Debugf(ctx, "Using cached remote schema %s", ref)
+		return encoded, nil
+	}
+
+	logging.Debugf(ctx, "Fetching remote schema %s", ref)
+	data, err := fetchRemoteContentFunc(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	data, err = decrypt.File(ctx, ref.String(), data)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	resources.AddPayload(ctx, int64(len(encoded)))
+	cache[ref.String()] = encoded
+	return encoded, nil
+}
+
+// loadAndEncodeFile reads a file (schema, agent control, etc.) and returns its base64-encoded content,
+// decrypting it first if it is encrypted with age or SOPS.
 // contentFieldName is the field in the definition map (e.g., "schema", "content") where the file path is found
-func loadAndEncodeFile(workspacePath string, contentPath string, filePathField string) (string, error) {
+func loadAndEncodeFile(ctx context.Context, workspacePath string, contentPath string, filePathField string) (string, error) {
 	if contentPath == "" {
 		return "", nil
 	}
 
 	// Content paths are relative to the .fleetControl directory; the resolved path
 	// must stay within the workspace so we can't read arbitrary files on the runner.
-	fullPath := filepath.Join(workspacePath, config.GetRootFolderForAgentRepo(), contentPath)
+	fullPath := filepath.Join(workspacePath, config.GetRootFolderForAgentRepo(), fileutil.NormalizePath(contentPath))
 
-	resolvedPath, err := filepath.Abs(fullPath)
+	within, err := fileutil.IsWithin(workspacePath, fullPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to resolve %s path: %w", filePathField, err)
 	}
-
-	resolvedWorkspace, err := filepath.Abs(workspacePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to resolve workspace path %s: %w", workspacePath, err)
-	}
-
-	if !strings.HasPrefix(resolvedPath, resolvedWorkspace+string(filepath.Separator)) && resolvedPath != resolvedWorkspace {
+	if !within {
+		resolvedWorkspace, _ := filepath.Abs(workspacePath)
 		return "", fmt.Errorf("invalid %s path: must be within workspace: %s", filePathField, resolvedWorkspace)
 	}
 
@@ -197,6 +478,12 @@ func loadAndEncodeFile(workspacePath string, contentPath string, filePathField s
 		return "", fmt.Errorf("%s file at %s is empty", filePathField, fullPath)
 	}
 
+	data, err = decrypt.File(ctx, fullPath, data)
+	if err != nil {
+		return "", err
+	}
+
 	encoded := base64.StdEncoding.EncodeToString(data)
+	resources.AddPayload(ctx, int64(len(encoded)))
 	return encoded, nil
 }
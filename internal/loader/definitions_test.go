@@ -2,12 +2,14 @@ package loader
 
 import (
 	"agent-metadata-action/internal/config"
+	"agent-metadata-action/internal/github"
 	"agent-metadata-action/internal/testutil"
 	"context"
 	"encoding/base64"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -42,7 +44,7 @@ func TestReadConfigurationDefinitions_Success(t *testing.T) {
 	require.NoError(t, err)
 
 	// Test reading the config
-	configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir)
+	configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
 	require.NoError(t, err)
 	assert.Len(t, configs, 1)
 	assert.Equal(t, "linux", configs[0]["platform"])
@@ -89,7 +91,7 @@ func TestReadAgentControlDefinitions_Success(t *testing.T) {
 	require.NoError(t, err)
 
 	// Test reading the agent control definitions
-	agentControls, err := ReadAgentControlDefinitions(context.Background(), tmpDir)
+	agentControls, err := ReadAgentControlDefinitions(context.Background(), tmpDir, config.Placeholders{})
 	require.NoError(t, err)
 	assert.Len(t, agentControls, 1)
 	assert.Equal(t, "KUBERNETES", agentControls[0]["platform"])
@@ -151,7 +153,7 @@ func TestReadConfigurationDefinitions_ErrorCases(t *testing.T) {
 			tt.setupFunc(t, tmpDir)
 
 			// method under test
-			configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir)
+			configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
 
 			require.Error(t, err)
 			assert.Nil(t, configs)
@@ -205,7 +207,7 @@ func TestReadAgentControlDefinitions_ErrorCases(t *testing.T) {
 			tt.setupFunc(t, tmpDir)
 
 			// method under test
-			agentControls, err := ReadAgentControlDefinitions(context.Background(), tmpDir)
+			agentControls, err := ReadAgentControlDefinitions(context.Background(), tmpDir, config.Placeholders{})
 
 			require.Error(t, err)
 			assert.Nil(t, agentControls)
@@ -266,7 +268,7 @@ func TestReadConfigurationDefinitions_SchemaLoadingWarnings(t *testing.T) {
 			getStdout, _ := testutil.CaptureOutput(t)
 
 			// method under test - should not fail if schema can't be loaded
-			configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir)
+			configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
 
 			outputStr := getStdout()
 
@@ -327,7 +329,7 @@ func TestReadConfigurationDefinitions_MultipleConfigs(t *testing.T) {
 	require.NoError(t, err)
 
 	// Test reading the configs
-	configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir)
+	configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
 	require.NoError(t, err)
 	assert.Len(t, configs, 3)
 
@@ -368,7 +370,7 @@ func TestReadConfigurationDefinitions_ValidationIntegration(t *testing.T) {
 	err = os.WriteFile(configFile, []byte(yamlContent), 0644)
 	require.NoError(t, err)
 
-	configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir)
+	configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
 	require.NoError(t, err)
 	assert.Len(t, configs, 1)
 	// Schema is nil when not provided
@@ -418,7 +420,7 @@ func TestReadConfigurationDefinitions_DirectoryTraversal(t *testing.T) {
 			getStdout, _ := testutil.CaptureOutput(t)
 
 			// Test reading the config - should not fail if schema can't be loaded
-			configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir)
+			configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
 
 			outputStr := getStdout()
 
@@ -444,7 +446,7 @@ func TestReadConfigurationDefinitions_EmptyArray(t *testing.T) {
 	require.NoError(t, err)
 
 	// Test reading the config - should error
-	configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir)
+	configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
 	assert.Error(t, err)
 	assert.Nil(t, configs)
 	assert.Contains(t, err.Error(), "configurationDefinitions cannot be empty")
@@ -468,10 +470,11 @@ func TestReadDefinitionsFile_ItemNotMap(t *testing.T) {
 	require.NoError(t, err)
 
 	// Test reading the config - should error
-	configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir)
+	configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
 	require.Error(t, err)
 	assert.Nil(t, configs)
-	assert.Contains(t, err.Error(), "is not a map")
+	assert.Contains(t, err.Error(), "configurationDefinitions[1]: item 1 in configurationDefinitions is not a map")
+	assert.Contains(t, err.Error(), "configurationDefinitions[2]: item 2 in configurationDefinitions is not a map")
 }
 
 func TestReadDefinitionsFile_NoArrayFound(t *testing.T) {
@@ -491,7 +494,7 @@ description: This file has no arrays`
 	require.NoError(t, err)
 
 	// Test reading the config - should error with "no array found"
-	configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir)
+	configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
 	require.Error(t, err)
 	assert.Nil(t, configs)
 	assert.Contains(t, err.Error(), "no array found in YAML file")
@@ -555,7 +558,7 @@ func TestReadAgentControlDefinitions_ContentLoadingWarnings(t *testing.T) {
 			getStdout, _ := testutil.CaptureOutput(t)
 
 			// method under test - should not fail if content can't be loaded
-			agentControls, err := ReadAgentControlDefinitions(context.Background(), tmpDir)
+			agentControls, err := ReadAgentControlDefinitions(context.Background(), tmpDir, config.Placeholders{})
 
 			outputStr := getStdout()
 
@@ -610,7 +613,7 @@ func TestReadAgentControlDefinitions_MultipleDefinitions(t *testing.T) {
 	require.NoError(t, err)
 
 	// Test reading the agent control definitions
-	agentControls, err := ReadAgentControlDefinitions(context.Background(), tmpDir)
+	agentControls, err := ReadAgentControlDefinitions(context.Background(), tmpDir, config.Placeholders{})
 	require.NoError(t, err)
 	assert.Len(t, agentControls, 3)
 
@@ -630,6 +633,97 @@ func TestReadAgentControlDefinitions_MultipleDefinitions(t *testing.T) {
 	assert.Equal(t, expectedEncoded3, agentControls[2]["content"])
 }
 
+func TestReadAgentControlDefinitions_PlatformFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, config.GetRootFolderForAgentRepo())
+	require.NoError(t, os.MkdirAll(configDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "k8s-control.yml"), []byte("k8s: data"), 0644))
+	files := map[string]string{
+		config.GetAgentControlDefinitionsFilename(): "agentControlDefinitions:\n  - platform: HOST\n    supportFromAgent: 1.0.0\n",
+		"agentControlDefinitions.kubernetes.yml":    "agentControlDefinitions:\n  - supportFromAgent: 1.1.0\n    content: ./k8s-control.yml\n  - platform: KUBERNETES\n    supportFromAgent: 1.2.0\n",
+		"agentControlDefinitions.linux.yml":         "platform: LINUX\nagentControlDefinitions:\n  - supportFromAgent: 1.3.0\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(configDir, name), []byte(content), 0644))
+	}
+
+	// method under test
+	agentControls, err := ReadAgentControlDefinitions(context.Background(), tmpDir, config.Placeholders{})
+
+	require.NoError(t, err)
+	require.Len(t, agentControls, 4)
+	assert.Equal(t, "HOST", agentControls[0]["platform"])
+	assert.Equal(t, "KUBERNETES", agentControls[1]["platform"])
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("k8s: data")), agentControls[1]["content"])
+	assert.Equal(t, "KUBERNETES", agentControls[2]["platform"])
+	assert.Equal(t, "LINUX", agentControls[3]["platform"])
+	assert.Equal(t, "1.3.0", agentControls[3]["supportFromAgent"])
+}
+
+func TestReadAgentControlDefinitions_OnlyPlatformFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, config.GetRootFolderForAgentRepo())
+	require.NoError(t, os.MkdirAll(configDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "agentControlDefinitions.host.yml"), []byte("agentControlDefinitions:\n  - supportFromAgent: 1.0.0\n"), 0644))
+
+	// method under test
+	agentControls, err := ReadAgentControlDefinitions(context.Background(), tmpDir, config.Placeholders{})
+
+	require.NoError(t, err)
+	require.Len(t, agentControls, 1)
+	assert.Equal(t, "HOST", agentControls[0]["platform"])
+}
+
+func TestReadAgentControlDefinitions_InvalidDefinitions(t *testing.T) {
+	tests := []struct {
+		name           string
+		filename       string
+		content        string
+		expectedErrMsg string
+	}{
+		{
+			name:           "definition platform differs from the filename",
+			filename:       "agentControlDefinitions.host.yml",
+			content:        "agentControlDefinitions:\n  - platform: KUBERNETES\n",
+			expectedErrMsg: "agentControlDefinitions[0].platform: platform KUBERNETES does not match the HOST platform of the file",
+		},
+		{
+			name:           "definition platform differs from the platform field",
+			filename:       config.GetAgentControlDefinitionsFilename(),
+			content:        "platform: HOST\nagentControlDefinitions:\n  - supportFromAgent: 1.0.0\n  - platform: LINUX\n",
+			expectedErrMsg: "agentControlDefinitions[1].platform: platform LINUX does not match the HOST platform of the file",
+		},
+		{
+			name:           "invalid minimum Agent Control version",
+			filename:       config.GetAgentControlDefinitionsFilename(),
+			content:        "agentControlDefinitions:\n  - platform: HOST\n    supportFromAgentControl: 1.0\n",
+			expectedErrMsg: "agentControlDefinitions[0].supportFromAgentControl: must be a version string such as 1.2.0, got 1 - quote it in YAML",
+		},
+		{
+			name:           "platform field differs from the filename",
+			filename:       "agentControlDefinitions.host.yml",
+			content:        "platform: LINUX\nagentControlDefinitions:\n  - supportFromAgent: 1.0.0\n",
+			expectedErrMsg: "platform LINUX does not match the HOST platform of the filename",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configDir := filepath.Join(tmpDir, config.GetRootFolderForAgentRepo())
+			require.NoError(t, os.MkdirAll(configDir, 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(configDir, tt.filename), []byte(tt.content), 0644))
+
+			// method under test
+			agentControls, err := ReadAgentControlDefinitions(context.Background(), tmpDir, config.Placeholders{})
+
+			require.Error(t, err)
+			assert.Nil(t, agentControls)
+			assert.Contains(t, err.Error(), tt.expectedErrMsg)
+		})
+	}
+}
+
 func TestReadConfigurationDefinitions_InvalidFieldTypes(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -707,7 +801,7 @@ func TestReadConfigurationDefinitions_InvalidFieldTypes(t *testing.T) {
 			getStdout, _ := testutil.CaptureOutput(t)
 
 			// method under test
-			configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir)
+			configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
 
 			outputStr := getStdout()
 
@@ -791,7 +885,7 @@ func TestReadAgentControlDefinitions_InvalidFieldTypes(t *testing.T) {
 			getStdout, _ := testutil.CaptureOutput(t)
 
 			// method under test
-			agentControls, err := ReadAgentControlDefinitions(context.Background(), tmpDir)
+			agentControls, err := ReadAgentControlDefinitions(context.Background(), tmpDir, config.Placeholders{})
 
 			outputStr := getStdout()
 
@@ -848,7 +942,7 @@ func TestLoadAndEncodeFile_PathValidation(t *testing.T) {
 			require.NoError(t, os.WriteFile(configFile, []byte(testYAML), 0644))
 
 			// method under test
-			configs, err := ReadConfigurationDefinitions(context.Background(), workspace)
+			configs, err := ReadConfigurationDefinitions(context.Background(), workspace, config.Placeholders{})
 
 			outputStr := getStdout()
 
@@ -885,7 +979,7 @@ func TestLoadAndEncodeFile_OutsideFleetControl(t *testing.T) {
     schema: ../src/Configuration.xsd`
 	require.NoError(t, os.WriteFile(configFile, []byte(testYAML), 0644))
 
-	configs, err := ReadConfigurationDefinitions(context.Background(), workspace)
+	configs, err := ReadConfigurationDefinitions(context.Background(), workspace, config.Placeholders{})
 	require.NoError(t, err)
 	require.Len(t, configs, 1)
 
@@ -945,7 +1039,7 @@ func TestReadConfigurationDefinitions_DropsBrokenSchemaField(t *testing.T) {
 			configFile := filepath.Join(configDir, config.GetConfigurationDefinitionsFilename())
 			require.NoError(t, os.WriteFile(configFile, []byte(tt.yamlContent), 0644))
 
-			configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir)
+			configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
 			require.NoError(t, err)
 			require.Len(t, configs, 1)
 
@@ -991,7 +1085,7 @@ func TestReadAgentControlDefinitions_DropsBrokenContentField(t *testing.T) {
 			agentControlFile := filepath.Join(configDir, config.GetAgentControlDefinitionsFilename())
 			require.NoError(t, os.WriteFile(agentControlFile, []byte(tt.yamlContent), 0644))
 
-			defs, err := ReadAgentControlDefinitions(context.Background(), tmpDir)
+			defs, err := ReadAgentControlDefinitions(context.Background(), tmpDir, config.Placeholders{})
 			require.NoError(t, err)
 			require.Len(t, defs, 1)
 
@@ -1089,3 +1183,291 @@ func TestReadAgentDefinition_InvalidYAML(t *testing.T) {
 	assert.Nil(t, result)
 	assert.Contains(t, err.Error(), "failed to parse agentDefinition.yml")
 }
+
+func TestReadConfigurationDefinitions_RemoteSchema(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, config.GetRootFolderForAgentRepo())
+	require.NoError(t, os.MkdirAll(configDir, 0755))
+
+	testYAML := `configurationDefinitions:
+  - type: first
+    schema: newrelic/fleet-schemas:schemas/shared.json@v1.0.0
+  - type: second
+    schema: newrelic/fleet-schemas:schemas/shared.json@v1.0.0
+  - type: missing
+    schema: newrelic/fleet-schemas:schemas/missing.json@v1.0.0
+  - type: traversal
+    schema: newrelic/fleet-schemas:../secret.json@v1.0.0`
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, config.GetConfigurationDefinitionsFilename()), []byte(testYAML), 0644))
+
+	schemaContent := `{"type": "object"}`
+	fetches := 0
+	originalFetch := fetchRemoteContentFunc
+	fetchRemoteContentFunc = func(ctx context.Context, ref github.ContentRef) ([]byte, error) {
+		fetches++
+		assert.Equal(t, "newrelic/fleet-schemas", ref.Repo)
+		assert.Equal(t, "v1.0.0", ref.Ref)
+		if ref.Path == "schemas/shared.json" {
+			return []byte(schemaContent), nil
+		}
+		return nil, fmt.Errorf("not found")
+	}
+	defer func() { fetchRemoteContentFunc = originalFetch }()
+
+	getStdout, _ := testutil.CaptureOutput(t)
+	configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
+	stdout := getStdout()
+
+	require.NoError(t, err)
+	require.Len(t, configs, 4)
+
+	expectedEncoded := base64.StdEncoding.EncodeToString([]byte(schemaContent))
+	assert.Equal(t, expectedEncoded, configs[0]["schema"])
+	assert.Equal(t, expectedEncoded, configs[1]["schema"])
+	assert.NotContains(t, configs[2], "schema")
+	assert.NotContains(t, configs[3], "schema")
+
+	// Shared schema fetched once, missing schema fetched once, traversal never fetched
+	assert.Equal(t, 2, fetches)
+	assert.Contains(t, stdout, "Using cached remote schema")
+	assert.Contains(t, stdout, "directory traversal")
+}
+
+func TestRemoteSchemaRepos(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, config.GetRootFolderForAgentRepo())
+	require.NoError(t, os.MkdirAll(configDir, 0755))
+
+	t.Run("missing file", func(t *testing.T) {
+		// method under test
+		assert.Nil(t, RemoteSchemaRepos(tmpDir, config.Placeholders{Version: "2.0.0"}))
+	})
+
+	t.Run("remote schemas", func(t *testing.T) {
+		testYAML := `configurationDefinitions:
+  - type: first
+    schema: newrelic/fleet-schemas:schemas/shared.json@v1.0.0
+  - type: second
+    schema: newrelic/agent-schemas:java/config.json@v${version}
+  - type: third
+    schema: newrelic/fleet-schemas:schemas/other.json@main
+  - type: local
+    schema: ./schemas/config.json
+  - type: traversal
+    schema: newrelic/private:../secret.json@v1.0.0`
+		require.NoError(t, os.WriteFile(filepath.Join(configDir, config.GetConfigurationDefinitionsFilename()), []byte(testYAML), 0644))
+
+		// method under test
+		repos := RemoteSchemaRepos(tmpDir, config.Placeholders{Version: "2.0.0"})
+
+		assert.Equal(t, []string{"newrelic/agent-schemas", "newrelic/fleet-schemas"}, repos)
+	})
+}
+
+func TestReadConfigurationDefinitions_Placeholders(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, config.GetRootFolderForAgentRepo())
+	require.NoError(t, os.MkdirAll(filepath.Join(configDir, "schemas", "2.0.0"), 0755))
+	schemaContent := `{"type": "object"}`
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "schemas", "2.0.0", "config.json"), []byte(schemaContent), 0644))
+
+	testYAML := `configurationDefinitions:
+  - platform: ALL
+    description: ${AGENT_TYPE} ${VERSION} configuration
+    type: agent-config
+    version: ${VERSION}
+    schema: ./schemas/${VERSION}/config.json
+    x-labels:
+      - since-${VERSION}
+      - ${UNKNOWN}`
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, config.GetConfigurationDefinitionsFilename()), []byte(testYAML), 0644))
+
+	// method under test
+	configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{Version: "2.0.0", AgentType: "NRJavaAgent"})
+
+	require.NoError(t, err)
+	require.Len(t, configs, 1)
+	assert.Equal(t, "NRJavaAgent 2.0.0 configuration", configs[0]["description"])
+	assert.Equal(t, "2.0.0", configs[0]["version"])
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte(schemaContent)), configs[0]["schema"])
+	assert.Equal(t, []interface{}{"since-2.0.0", "${UNKNOWN}"}, configs[0]["x-labels"])
+}
+
+func TestReadConfigurationDefinitions_EncryptedSchemaWithoutKey(t *testing.T) {
+	t.Setenv("INPUT_DECRYPTION_KEY", "")
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, config.GetRootFolderForAgentRepo())
+	require.NoError(t, os.MkdirAll(filepath.Join(configDir, "schemas"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "schemas", "config.json.age"), []byte("age-encryption.org/v1\n-> X25519 abc\n"), 0644))
+	testYAML := `configurationDefinitions:
+  - type: agent-config
+    schema: ./schemas/config.json.age`
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, config.GetConfigurationDefinitionsFilename()), []byte(testYAML), 0644))
+	getStdout, _ := testutil.CaptureOutput(t)
+	annotations := github.NewAnnotationCollector()
+	ctx := github.WithAnnotationCollector(context.Background(), annotations)
+
+	// method under test
+	configs, err := ReadConfigurationDefinitions(ctx, tmpDir, config.Placeholders{})
+
+	require.NoError(t, err)
+	require.Len(t, configs, 1)
+	assert.NotContains(t, configs[0], "schema")
+	assert.Contains(t, getStdout(), "decryption-key input is not set")
+	require.Len(t, annotations.Annotations(), 1)
+	assert.Equal(t, "Schema not loaded", annotations.Annotations()[0].Title)
+	assert.Contains(t, annotations.Annotations()[0].Message, "is encrypted with age")
+}
+
+func TestReadConfigurationDefinitions_UnknownFields(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, config.GetRootFolderForAgentRepo())
+	require.NoError(t, os.MkdirAll(configDir, 0755))
+	testYAML := `configurationDefinitions:
+  - platform: ALL
+    descripton: Agent configuration
+    type: agent-config
+    x-owner: java-team
+  - platform: LINUX
+    type: agent-config
+    priority: 1`
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, config.GetConfigurationDefinitionsFilename()), []byte(testYAML), 0644))
+	annotations := github.NewAnnotationCollector()
+	ctx := github.WithAnnotationCollector(context.Background(), annotations)
+
+	// method under test
+	configs, err := ReadConfigurationDefinitions(ctx, tmpDir, config.Placeholders{})
+
+	require.Error(t, err)
+	assert.Nil(t, configs)
+	assert.Contains(t, err.Error(), `configurationDefinitions.yml:3: configurationDefinitions[0]: unknown field "descripton" - did you mean "description"?`)
+	assert.Contains(t, err.Error(), `configurationDefinitions.yml:8: configurationDefinitions[1]: unknown field "priority" - known fields are`)
+	assert.NotContains(t, err.Error(), "x-owner")
+
+	require.Len(t, annotations.Annotations(), 2)
+	annotation := annotations.Annotations()[0]
+	assert.Equal(t, config.GetConfigurationDefinitionsFilepath(), annotation.Path)
+	assert.Equal(t, 3, annotation.StartLine)
+	assert.Equal(t, 5, annotation.StartColumn)
+	assert.Equal(t, "Unknown field", annotation.Title)
+}
+
+func TestReadAgentControlDefinitions_UnknownFields(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, config.GetRootFolderForAgentRepo())
+	require.NoError(t, os.MkdirAll(configDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "agent-control.yml"), []byte("agent: config"), 0644))
+	testYAML := `agentControlDefinitions:
+  - platform: KUBERNETESCLUSTER
+    supportFromAgent: 1.0.0
+    suportFromAgentControl: 1.0.0
+    x-notes: reviewed
+    content: ./agent-control.yml`
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, config.GetAgentControlDefinitionsFilename()), []byte(testYAML), 0644))
+
+	// method under test
+	definitions, err := ReadAgentControlDefinitions(context.Background(), tmpDir, config.Placeholders{})
+
+	require.Error(t, err)
+	assert.Nil(t, definitions)
+	assert.Contains(t, err.Error(), `agentControlDefinitions[0]: unknown field "suportFromAgentControl" - did you mean "supportFromAgentControl"?`)
+}
+
+func TestReadConfigurationDefinitions_LocalizedDescriptions(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, config.GetRootFolderForAgentRepo())
+	require.NoError(t, os.MkdirAll(configDir, 0755))
+	testYAML := `configurationDefinitions:
+  - platform: ALL
+    description: Agent configuration
+    description_i18n:
+      de: Agentenkonfiguration
+      ja: エージェントの設定
+    type: agent-config
+  - platform: LINUX
+    description: Linux configuration
+    description_i18n:
+      en-GB: Linux configuration
+    type: agent-config`
+	path := filepath.Join(configDir, config.GetConfigurationDefinitionsFilename())
+	require.NoError(t, os.WriteFile(path, []byte(testYAML), 0644))
+
+	// method under test
+	configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
+
+	require.Error(t, err)
+	assert.Nil(t, configs)
+	assert.Contains(t, err.Error(), "configurationDefinitions.yml: configurationDefinitions[1].description_i18n.en-GB: unsupported locale")
+
+	require.NoError(t, os.WriteFile(path, []byte(strings.SplitN(testYAML, "  - platform: LINUX", 2)[0]), 0644))
+	configs, err = ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
+	require.NoError(t, err)
+	require.Len(t, configs, 1)
+	assert.Equal(t, map[string]interface{}{"de": "Agentenkonfiguration", "ja": "エージェントの設定"}, configs[0]["description_i18n"])
+}
+
+func TestReadConfigurationDefinitions_Deprecations(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, config.GetRootFolderForAgentRepo())
+	require.NoError(t, os.MkdirAll(configDir, 0755))
+	testYAML := `configurationDefinitions:
+  - platform: HOST
+    type: agent-config
+  - platform: HOST
+    type: legacy-config
+    deprecated: true
+    supersededBy: agent-config
+  - platform: HOST
+    type: old-config
+    supersededBy: agent-config`
+	path := filepath.Join(configDir, config.GetConfigurationDefinitionsFilename())
+	require.NoError(t, os.WriteFile(path, []byte(testYAML), 0644))
+
+	// method under test
+	configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
+
+	require.Error(t, err)
+	assert.Nil(t, configs)
+	assert.Contains(t, err.Error(), "configurationDefinitions.yml: configurationDefinitions[2].supersededBy: is only allowed on definitions with deprecated: true")
+
+	require.NoError(t, os.WriteFile(path, []byte(strings.SplitN(testYAML, "  - platform: HOST\n    type: old-config", 2)[0]), 0644))
+	configs, err = ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
+	require.NoError(t, err)
+	require.Len(t, configs, 2)
+	assert.Equal(t, true, configs[1]["deprecated"])
+	assert.Equal(t, "agent-config", configs[1]["supersededBy"])
+}
+
+func TestReadConfigurationDefinitions_Changelogs(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, config.GetRootFolderForAgentRepo())
+	require.NoError(t, os.MkdirAll(configDir, 0755))
+	testYAML := `configurationDefinitions:
+  - platform: HOST
+    type: agent-config
+    changelog: Added proxy settings
+  - platform: HOST
+    type: logging
+    changelog:
+      - Added log_level
+      - Removed log_file
+  - platform: HOST
+    type: old-config
+    changelog: [42]`
+	path := filepath.Join(configDir, config.GetConfigurationDefinitionsFilename())
+	require.NoError(t, os.WriteFile(path, []byte(testYAML), 0644))
+
+	// method under test
+	configs, err := ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
+
+	require.Error(t, err)
+	assert.Nil(t, configs)
+	assert.Contains(t, err.Error(), "configurationDefinitions.yml: configurationDefinitions[2].changelog: must be a non-empty entry or a list of them")
+
+	require.NoError(t, os.WriteFile(path, []byte(strings.SplitN(testYAML, "  - platform: HOST\n    type: old-config", 2)[0]), 0644))
+	configs, err = ReadConfigurationDefinitions(context.Background(), tmpDir, config.Placeholders{})
+	require.NoError(t, err)
+	require.Len(t, configs, 2)
+	assert.Equal(t, []string{"Added proxy settings"}, configs[0]["changelog"])
+	assert.Equal(t, []string{"Added log_level", "Removed log_file"}, configs[1]["changelog"])
+}
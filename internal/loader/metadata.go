@@ -6,6 +6,7 @@ import (
 	"agent-metadata-action/internal/logging"
 	"agent-metadata-action/internal/models"
 	"agent-metadata-action/internal/parser"
+	"agent-metadata-action/internal/sanitize"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -20,6 +21,9 @@ func LoadMetadataForAgents(version string) models.Metadata {
 	if displayName := config.GetDisplayName(); displayName != "" {
 		m["displayName"] = displayName
 	}
+	if releaseDate := config.GetReleaseDate(); releaseDate != "" {
+		m["releaseDate"] = releaseDate
+	}
 	return m
 }
 
@@ -36,6 +40,7 @@ func ParseTags(tagsJSON string) (map[string]string, error) {
 }
 
 type MetadataForDocs struct {
+	SourceFile            string
 	AgentType             string
 	AgentMetadataFromDocs models.Metadata
 }
@@ -43,48 +48,17 @@ type MetadataForDocs struct {
 // LoadMetadataForDocs loads metadata from changed MDX files in a PR
 // Loads as many files as it can and warns on issues with certain files
 func LoadMetadataForDocs(ctx context.Context) ([]MetadataForDocs, error) {
-	filesProcessed := 0
-
 	// Get changed MDX files (for PR context)
 	changedFilepaths, err := github.GetChangedMDXFiles()
 	if err != nil {
 		return nil, fmt.Errorf("could not get changed files -- %s", err)
 	} else if len(changedFilepaths) > 0 {
-		var metadataForDocs []MetadataForDocs
-		for _, filepath := range changedFilepaths {
-			frontMatter, err := parser.ParseMDXFile(filepath)
-			if err != nil {
-				logging.Warnf(ctx, "Failed to parse MDX file %s %s - skipping", filepath, err)
-				continue
-			}
-
-			if frontMatter["version"] == "" {
-				logging.Warnf(ctx, "Version is required in metadata for file %s - skipping", filepath)
-				continue
-			}
-
-			if frontMatter["subject"] == nil || frontMatter["subject"] == "" {
-				logging.Warnf(ctx, "Subject (to derive agent type) is required in metadata for file %s - skipping", filepath)
-				continue
-			}
-			agentType := parser.SubjectToAgentTypeMapping[parser.Subject(frontMatter["subject"].(string))]
-
-			// Convert frontMatter directly to Metadata (both are maps)
-			metadata := models.Metadata(frontMatter)
-
-			metadataForDocs = append(metadataForDocs, MetadataForDocs{
-				AgentType:             agentType,
-				AgentMetadataFromDocs: metadata,
-			})
-
-			filesProcessed++
-		}
-
-		if filesProcessed == 0 {
+		metadataForDocs := metadataFromMDXFiles(ctx, changedFilepaths)
+		if len(metadataForDocs) == 0 {
 			return nil, fmt.Errorf("unable to load metadata for any of the %d changed MDX files", len(changedFilepaths))
 		}
 
-		logging.Noticef(ctx, "Loaded metadata for %d out of %d changed MDX files", filesProcessed, len(changedFilepaths))
+		logging.Noticef(ctx, "Loaded metadata for %d out of %d changed MDX files", len(metadataForDocs), len(changedFilepaths))
 
 		return metadataForDocs, nil
 	}
@@ -92,3 +66,70 @@ func LoadMetadataForDocs(ctx context.Context) ([]MetadataForDocs, error) {
 	logging.Debug(ctx, "no changed files detected in the PR context")
 	return nil, nil
 }
+
+// LoadAllMetadataForDocs loads metadata from every release note in the workspace, regardless of what changed
+// Loads as many files as it can and warns on issues with certain files
+func LoadAllMetadataForDocs(ctx context.Context) ([]MetadataForDocs, error) {
+	filepaths, err := github.GetAllReleaseNoteFiles(config.GetWorkspace())
+	if err != nil {
+		return nil, fmt.Errorf("could not list release notes -- %s", err)
+	}
+
+	metadataForDocs := metadataFromMDXFiles(ctx, filepaths)
+	logging.Noticef(ctx, "Loaded metadata for %d out of %d release notes", len(metadataForDocs), len(filepaths))
+
+	return metadataForDocs, nil
+}
+
+// metadataFromMDXFiles parses the frontmatter of each MDX file, skipping (and annotating) invalid files
+// and sanitizing the free text of the rest
+func metadataFromMDXFiles(ctx context.Context, filepaths []string) []MetadataForDocs {
+	workspace := config.GetWorkspace()
+	// Free text is cleaned before it is printed, exported or sent; invalid lengths are rejected by runFlow
+	maxLength, _ := config.GetMaxTextLength()
+
+	var metadataForDocs []MetadataForDocs
+	for _, filepath := range filepaths {
+		frontMatter, err := parser.ParseMDXFile(filepath)
+		if err != nil {
+			logging.Warnf(ctx, "Failed to parse MDX file %s %s - skipping", filepath, err)
+			github.AddAnnotation(ctx, github.AnnotationFailure, github.RelativeToWorkspace(workspace, filepath),
+				"Invalid MDX file", err.Error())
+			continue
+		}
+
+		if frontMatter["version"] == "" {
+			logging.Warnf(ctx, "Version is required in metadata for file %s - skipping", filepath)
+			github.AddAnnotation(ctx, github.AnnotationFailure, github.RelativeToWorkspace(workspace, filepath),
+				"Missing version", "version is required in frontmatter")
+			continue
+		}
+
+		if frontMatter["subject"] == nil || frontMatter["subject"] == "" {
+			logging.Warnf(ctx, "Subject (to derive agent type) is required in metadata for file %s - skipping", filepath)
+			github.AddAnnotation(ctx, github.AnnotationFailure, github.RelativeToWorkspace(workspace, filepath),
+				"Missing subject", "subject is required in frontmatter to derive the agent type")
+			continue
+		}
+		agentType := parser.SubjectToAgentTypeMapping[parser.Subject(frontMatter["subject"].(string))]
+
+		if err := models.ValidateEOL(models.Metadata(frontMatter)); err != nil {
+			logging.Warnf(ctx, "Invalid eol in metadata for file %s: %v - skipping", filepath, err)
+			github.AddAnnotation(ctx, github.AnnotationFailure, github.RelativeToWorkspace(workspace, filepath),
+				"Invalid eol", err.Error())
+			continue
+		}
+
+		// Convert frontMatter directly to Metadata (both are maps)
+		metadata := models.Metadata(frontMatter)
+		sanitize.Report(ctx, github.RelativeToWorkspace(workspace, filepath), sanitize.Metadata(metadata, maxLength))
+
+		metadataForDocs = append(metadataForDocs, MetadataForDocs{
+			SourceFile:            filepath,
+			AgentType:             agentType,
+			AgentMetadataFromDocs: metadata,
+		})
+	}
+
+	return metadataForDocs
+}
@@ -91,6 +91,14 @@ func TestLoadMetadataForAgents_DisplayName(t *testing.T) {
 	}
 }
 
+func TestLoadMetadataForAgents_ReleaseDate(t *testing.T) {
+	t.Setenv("INPUT_RELEASE_DATE", "")
+	assert.NotContains(t, LoadMetadataForAgents("1.2.3"), "releaseDate")
+
+	t.Setenv("INPUT_RELEASE_DATE", "2025-01-15")
+	assert.Equal(t, "2025-01-15", LoadMetadataForAgents("1.2.3")["releaseDate"])
+}
+
 func TestParseTags(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -314,6 +322,54 @@ features:
 			expectedInErr: "unable to load metadata for any",
 			expectedInLog: "Subject (to derive agent type) is required",
 		},
+		{
+			name: "invalid eol",
+			setupFunc: func(t *testing.T) (string, []string) {
+				tmpWorkspace := t.TempDir()
+				releaseNotesDir := filepath.Join(tmpWorkspace, "src/content/docs/release-notes/agent-release-notes")
+				require.NoError(t, os.MkdirAll(releaseNotesDir, 0755))
+
+				mdxContent := `---
+subject: Java agent
+releaseDate: '2024-01-15'
+version: 1.2.3
+eol: '2024-13-45'
+---
+
+# Test Release Notes
+`
+				mdxFile := filepath.Join(releaseNotesDir, "test-agent.mdx")
+				require.NoError(t, os.WriteFile(mdxFile, []byte(mdxContent), 0644))
+				return tmpWorkspace, []string{mdxFile}
+			},
+			expectError:   true,
+			expectedInErr: "unable to load metadata for any",
+			expectedInLog: `invalid eol: "2024-13-45" is not a valid date`,
+		},
+		{
+			name: "eol before release date",
+			setupFunc: func(t *testing.T) (string, []string) {
+				tmpWorkspace := t.TempDir()
+				releaseNotesDir := filepath.Join(tmpWorkspace, "src/content/docs/release-notes/agent-release-notes")
+				require.NoError(t, os.MkdirAll(releaseNotesDir, 0755))
+
+				mdxContent := `---
+subject: Java agent
+releaseDate: 2024-01-15
+version: 1.2.3
+eol: 2023-12-31
+---
+
+# Test Release Notes
+`
+				mdxFile := filepath.Join(releaseNotesDir, "test-agent.mdx")
+				require.NoError(t, os.WriteFile(mdxFile, []byte(mdxContent), 0644))
+				return tmpWorkspace, []string{mdxFile}
+			},
+			expectError:   true,
+			expectedInErr: "unable to load metadata for any",
+			expectedInLog: "eol 2023-12-31 is before the releaseDate 2024-01-15",
+		},
 		{
 			name: "empty subject",
 			setupFunc: func(t *testing.T) (string, []string) {
@@ -428,3 +484,53 @@ func TestLoadMetadataForDocs_NoChangedFiles(t *testing.T) {
 	assert.Nil(t, metadata)
 	assert.Contains(t, stdout, "no changed files detected")
 }
+
+func TestLoadAllMetadataForDocs(t *testing.T) {
+	workspace := t.TempDir()
+	mdxDir := filepath.Join(workspace, "src/content/docs/release-notes/agent-release-notes/java-release-notes")
+	require.NoError(t, os.MkdirAll(mdxDir, 0755))
+
+	validFile := filepath.Join(mdxDir, "java-agent-130.mdx")
+	require.NoError(t, os.WriteFile(validFile, []byte("---\nsubject: Java agent\nversion: 1.3.0\n---\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(mdxDir, "java-agent-131.mdx"), []byte("no frontmatter"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(mdxDir, "index.mdx"), []byte("---\nsubject: Java agent\n---\n"), 0644))
+
+	t.Setenv("GITHUB_WORKSPACE", workspace)
+	getStdout, _ := testutil.CaptureOutput(t)
+
+	// method under test
+	metadata, err := LoadAllMetadataForDocs(context.Background())
+
+	stdout := getStdout()
+
+	require.NoError(t, err)
+	require.Len(t, metadata, 1)
+	assert.Equal(t, validFile, metadata[0].SourceFile)
+	assert.Equal(t, "NRJavaAgent", metadata[0].AgentType)
+	assert.Contains(t, stdout, "Loaded metadata for 1 out of 2 release notes")
+}
+
+func TestLoadAllMetadataForDocs_SanitizesText(t *testing.T) {
+	workspace := t.TempDir()
+	mdxDir := filepath.Join(workspace, "src/content/docs/release-notes/agent-release-notes/java-release-notes")
+	require.NoError(t, os.MkdirAll(mdxDir, 0755))
+	frontmatter := "---\nsubject: Java agent\nversion: 1.3.0\nfeatures:\n  - \"Faster \\e[31mstartup\\e[0m\"\n  - Plain feature\nbugs:\n  - \"Fixed a crash\\r\\non shutdown\"\n---\n"
+	require.NoError(t, os.WriteFile(filepath.Join(mdxDir, "java-agent-130.mdx"), []byte(frontmatter), 0644))
+
+	t.Setenv("GITHUB_WORKSPACE", workspace)
+	getStdout, _ := testutil.CaptureOutput(t)
+	annotations := github.NewAnnotationCollector()
+	ctx := github.WithAnnotationCollector(context.Background(), annotations)
+
+	// method under test
+	metadata, err := LoadAllMetadataForDocs(ctx)
+
+	require.NoError(t, err)
+	require.Len(t, metadata, 1)
+	assert.Equal(t, []interface{}{"Faster startup", "Plain feature"}, metadata[0].AgentMetadataFromDocs["features"])
+	assert.Equal(t, []interface{}{"Fixed a crash\non shutdown"}, metadata[0].AgentMetadataFromDocs["bugs"])
+	assert.Contains(t, getStdout(), "Sanitized features[0] contained ANSI escape sequences")
+	require.Len(t, annotations.Annotations(), 1)
+	assert.Equal(t, "Text sanitized", annotations.Annotations()[0].Title)
+	assert.Equal(t, "src/content/docs/release-notes/agent-release-notes/java-release-notes/java-agent-130.mdx", annotations.Annotations()[0].Path)
+}
@@ -3,13 +3,46 @@ package logging
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/newrelic/go-agent/v3/newrelic"
 )
 
+// Sink receives log messages in place of the console and New Relic
+// Used by callers embedding the action's packages, which don't want GitHub Actions workflow commands
+type Sink func(level, message string)
+
+// sinkKey is the context key for a Sink
+type sinkKey struct{}
+
+// WithSink returns a context whose log messages are sent to sink instead of the console and New Relic
+func WithSink(ctx context.Context, sink Sink) context.Context {
+	return context.WithValue(ctx, sinkKey{}, sink)
+}
+
+// noGroupsKey is the context key marking contexts that don't open log groups
+type noGroupsKey struct{}
+
+// WithoutGroups returns a context that drops "group" and "endgroup" messages, for work running concurrently with
+// other work whose groups would interleave with its own (GitHub Actions groups can't nest)
+func WithoutGroups(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noGroupsKey{}, true)
+}
+
 // Log logs to both console (GitHub Actions format) and New Relic
 // Extracts the New Relic transaction from context if available
+// Contexts from WithSink send the message to their sink instead; contexts from WithoutGroups drop group markers
 func Log(ctx context.Context, level, message string) {
+	if ctx != nil {
+		if (level == "group" || level == "endgroup") && ctx.Value(noGroupsKey{}) != nil {
+			return
+		}
+		if sink, ok := ctx.Value(sinkKey{}).(Sink); ok {
+			sink(level, message)
+			return
+		}
+	}
+
 	// Get trace ID from New Relic transaction for correlation
 	traceID := getTraceID(ctx)
 
@@ -31,6 +64,53 @@ func Log(ctx context.Context, level, message string) {
 	}
 }
 
+// Annotate logs message as a GitHub Actions notice, warning or error annotation (level "notice", "warning" or
+// "error") on file, with title, which GitHub lists in the Annotations panel of the workflow run
+// file is relative to the repository root; annotations without a file or title leave the property out
+// Contexts from WithSink send the message to their sink instead
+func Annotate(ctx context.Context, level, file, title, message string) {
+	if ctx != nil {
+		if sink, ok := ctx.Value(sinkKey{}).(Sink); ok {
+			sink(level, message)
+			return
+		}
+	}
+
+	var properties []string
+	if file != "" {
+		properties = append(properties, "file="+escapeProperty(file))
+	}
+	if title != "" {
+		properties = append(properties, "title="+escapeProperty(title))
+	}
+	formattedMessage := message
+	if traceID := getTraceID(ctx); traceID != "" {
+		formattedMessage = fmt.Sprintf("[trace=%s] %s", traceID, message)
+	}
+	command := level
+	if len(properties) > 0 {
+		command += " " + strings.Join(properties, ",")
+	}
+	fmt.Printf("::%s::%s\n", command, escapeData(formattedMessage))
+
+	if txn := newrelic.FromContext(ctx); txn != nil {
+		txn.RecordLog(newrelic.LogData{
+			Message:  message,
+			Severity: level,
+		})
+	}
+}
+
+// escapeData escapes the message of a workflow command, which ends at the line break
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property of a workflow command, which also ends at a comma or colon
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
 // getTraceID extracts the trace ID from the New Relic transaction in the context
 func getTraceID(ctx context.Context) string {
 	if txn := newrelic.FromContext(ctx); txn != nil {
@@ -211,3 +211,68 @@ func TestNoticeErrorWithCategory_WithNilError(t *testing.T) {
 	// No assertions needed - just verify no panic
 	t.Log("NoticeErrorWithCategory with nil error should be no-op")
 }
+
+func TestLog_WithSink(t *testing.T) {
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	var received []string
+	ctx := WithSink(context.Background(), func(level, message string) {
+		received = append(received, level+": "+message)
+	})
+	Noticef(ctx, "Uploaded %d artifacts", 2)
+	Warn(ctx, "Slow response")
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+
+	if buf.Len() != 0 {
+		t.Errorf("Expected no console output, got %q", buf.String())
+	}
+	expected := []string{"notice: Uploaded 2 artifacts", "warn: Slow response"}
+	if strings.Join(received, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %q, got %q", expected, received)
+	}
+}
+
+func TestLog_WithoutGroups(t *testing.T) {
+	var received []string
+	ctx := WithoutGroups(WithSink(context.Background(), func(level, message string) {
+		received = append(received, level+": "+message)
+	}))
+	Log(ctx, "group", "Sending metadata")
+	Notice(ctx, "Sent")
+	Log(ctx, "endgroup", "")
+
+	expected := []string{"notice: Sent"}
+	if strings.Join(received, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected %q, got %q", expected, received)
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	// Capture stdout
+	old := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	Annotate(context.Background(), "error", "dist/agent,linux.tar.gz", "Upload failed: linux", "50% done\nDENIED")
+	Annotate(context.Background(), "notice", "", "", "No properties")
+
+	w.Close()
+	os.Stdout = old
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	output := buf.String()
+
+	expected := "::error file=dist/agent%2Clinux.tar.gz,title=Upload failed%3A linux::50%25 done%0ADENIED\n" +
+		"::notice::No properties\n"
+	if output != expected {
+		t.Errorf("Expected %q, got %q", expected, output)
+	}
+}
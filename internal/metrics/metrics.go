@@ -0,0 +1,188 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"agent-metadata-action/internal/logging"
+)
+
+// Counter is a Prometheus counter of a run
+type Counter int
+
+// Counters of a run
+const (
+	ItemsProcessed Counter = iota // Versions reconciled or backfilled, whether or not they failed
+	ItemsFailed                   // Versions that failed to reconcile or backfill
+	Retries                       // Retried attempts of requests to any service
+	UploadedBytes                 // Bytes of binaries uploaded to the OCI registry
+	counterCount
+)
+
+var counters = [counterCount]struct{ name, help string }{
+	ItemsProcessed: {"agent_metadata_items_processed_total", "Agent versions reconciled or backfilled, including failures."},
+	ItemsFailed:    {"agent_metadata_items_failed_total", "Agent versions that failed to reconcile or backfill."},
+	Retries:        {"agent_metadata_retries_total", "Retried attempts of requests to the instrumentation, signing and other services."},
+	UploadedBytes:  {"agent_metadata_uploaded_bytes_total", "Bytes of binaries uploaded to the OCI registry."},
+}
+
+// Registry holds the counters of a run, labelled with its mode; safe for concurrent use
+type Registry struct {
+	mode   string
+	values [counterCount]atomic.Int64
+}
+
+// NewRegistry returns a registry with every counter at zero
+func NewRegistry(mode string) *Registry {
+	return &Registry{mode: mode}
+}
+
+// Add increases a counter by n
+func (r *Registry) Add(counter Counter, n int64) {
+	r.values[counter].Add(n)
+}
+
+// Value returns the current value of a counter
+func (r *Registry) Value(counter Counter) int64 {
+	return r.values[counter].Load()
+}
+
+// WriteTo writes every counter in the Prometheus text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	labels := ""
+	if r.mode != "" {
+		labels = fmt.Sprintf(`{mode=%q}`, r.mode)
+	}
+	for counter, c := range counters {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n%s%s %d\n", c.name, c.help, c.name, c.name, labels, r.values[counter].Load())
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+type registryKey struct{}
+
+// WithRegistry returns a context carrying the registry
+func WithRegistry(ctx context.Context, registry *Registry) context.Context {
+	return context.WithValue(ctx, registryKey{}, registry)
+}
+
+// FromContext returns the registry in the context, or nil
+func FromContext(ctx context.Context) *Registry {
+	registry, _ := ctx.Value(registryKey{}).(*Registry)
+	return registry
+}
+
+// Add increases a counter of the registry in the context by n; a no-op without one
+func Add(ctx context.Context, counter Counter, n int64) {
+	if registry := FromContext(ctx); registry != nil {
+		registry.Add(counter, n)
+	}
+}
+
+// Handler serves the registry at /metrics in the Prometheus text exposition format
+func Handler(registry *Registry) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		registry.WriteTo(w)
+	})
+	return mux
+}
+
+// Serve serves the registry at /metrics on address, which must be a loopback address such as 127.0.0.1:9464 so
+// the counters are only exposed to the runner
+// The returned function shuts the server down
+func Serve(ctx context.Context, address string, registry *Registry) (func(), error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metrics address %q: %w", address, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("invalid metrics address %q: must be a loopback address", address)
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	server := &http.Server{Handler: Handler(registry), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Warnf(ctx, "Metrics endpoint stopped: %v", err)
+		}
+	}()
+	logging.Noticef(ctx, "Serving metrics at http://%s/metrics", listener.Addr())
+
+	return func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}, nil
+}
+
+// WriteFile writes the registry to path for a textfile collector such as node_exporter's, through a temporary file
+// renamed into place so a collector never reads a partial file
+func WriteFile(path string, registry *Registry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create metrics directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := registry.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
+
+// WriteFilePeriodically writes the registry to path every interval until the returned function is called, which
+// writes it a final time
+func WriteFilePeriodically(ctx context.Context, path string, interval time.Duration, registry *Registry) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := WriteFile(path, registry); err != nil {
+					logging.Warnf(ctx, "Unable to update the metrics file: %v", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		if err := WriteFile(path, registry); err != nil {
+			logging.Warnf(ctx, "Unable to write the metrics file: %v", err)
+		}
+	}
+}
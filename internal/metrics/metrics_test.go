@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	registry := NewRegistry("backfill")
+	ctx := WithRegistry(context.Background(), registry)
+	Add(ctx, ItemsProcessed, 3)
+	Add(ctx, ItemsFailed, 1)
+	Add(ctx, UploadedBytes, 2048)
+	Add(context.Background(), Retries, 5) // No registry in the context - ignored
+
+	var b strings.Builder
+
+	// method under test
+	_, err := registry.WriteTo(&b)
+
+	require.NoError(t, err)
+	assert.Contains(t, b.String(), "# TYPE agent_metadata_items_processed_total counter\nagent_metadata_items_processed_total{mode=\"backfill\"} 3\n")
+	assert.Contains(t, b.String(), "agent_metadata_items_failed_total{mode=\"backfill\"} 1\n")
+	assert.Contains(t, b.String(), "agent_metadata_retries_total{mode=\"backfill\"} 0\n")
+	assert.Contains(t, b.String(), "agent_metadata_uploaded_bytes_total{mode=\"backfill\"} 2048\n")
+}
+
+func TestRegistry_WriteTo_NoMode(t *testing.T) {
+	var b strings.Builder
+
+	// method under test
+	_, err := NewRegistry("").WriteTo(&b)
+
+	require.NoError(t, err)
+	assert.Contains(t, b.String(), "agent_metadata_items_processed_total 0\n")
+}
+
+func TestHandler(t *testing.T) {
+	registry := NewRegistry("reconcile")
+	server := httptest.NewServer(Handler(registry))
+	defer server.Close()
+	registry.Add(ItemsProcessed, 7)
+
+	// method under test
+	resp, err := http.Get(server.URL + "/metrics")
+
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "agent_metadata_items_processed_total{mode=\"reconcile\"} 7\n")
+}
+
+func TestServe(t *testing.T) {
+	testutil.CaptureOutput(t)
+
+	t.Run("serves on a loopback address", func(t *testing.T) {
+		// method under test
+		stop, err := Serve(context.Background(), "127.0.0.1:0", NewRegistry(""))
+
+		require.NoError(t, err)
+		stop()
+	})
+
+	t.Run("rejects non-loopback addresses", func(t *testing.T) {
+		for _, address := range []string{"0.0.0.0:9464", "10.0.0.1:9464", ":9464", "example.com:9464", "9464"} {
+			// method under test
+			_, err := Serve(context.Background(), address, NewRegistry(""))
+
+			require.Error(t, err, address)
+			assert.Contains(t, err.Error(), "invalid metrics address")
+		}
+	})
+}
+
+func TestWriteFilePeriodically(t *testing.T) {
+	testutil.CaptureOutput(t)
+	registry := NewRegistry("")
+	path := filepath.Join(t.TempDir(), "textfile", "agent_metadata.prom")
+
+	// method under test
+	stop := WriteFilePeriodically(context.Background(), path, 10*time.Millisecond, registry)
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+	registry.Add(Retries, 2)
+	stop()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "agent_metadata_retries_total 2\n", "The final counts are written on stop")
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "No temporary files are left behind")
+}
@@ -0,0 +1,99 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Fault injects failures into requests matching a method and path prefix
+// Each matching request takes the next entry of Statuses; 0 serves the request normally
+// Once Statuses is exhausted the fault stops matching, unless Repeat starts it over
+// Latency delays every matching request, including ones served normally
+type Fault struct {
+	Method   string        // HTTP method to match, any if empty
+	Path     string        // path prefix to match, e.g. /v1/signing; any if empty
+	Statuses []int         // status codes to respond with, in order
+	Latency  time.Duration // delay before responding
+	Repeat   bool          // cycle through Statuses indefinitely
+}
+
+// faultState tracks how many requests a fault has matched
+type faultState struct {
+	Fault
+	calls int
+}
+
+// AddFault injects a fault; faults are checked in the order they were added and the first active match applies
+func (s *Server) AddFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &faultState{Fault: f})
+}
+
+// nextFault returns the latency and status (0 to serve normally) to apply to a request
+func (s *Server) nextFault(r *http.Request) (time.Duration, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, f := range s.faults {
+		if f.Method != "" && !strings.EqualFold(f.Method, r.Method) {
+			continue
+		}
+		if !strings.HasPrefix(r.URL.Path, f.Path) {
+			continue
+		}
+		if len(f.Statuses) == 0 {
+			return f.Latency, 0
+		}
+		if f.calls >= len(f.Statuses) && !f.Repeat {
+			continue
+		}
+		status := f.Statuses[f.calls%len(f.Statuses)]
+		f.calls++
+		return f.Latency, status
+	}
+	return 0, 0
+}
+
+// faultJSON is the JSON form of a Fault, with latency as a duration string such as "1.5s"
+type faultJSON struct {
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Statuses []int  `json:"statuses"`
+	Latency  string `json:"latency"`
+	Repeat   bool   `json:"repeat"`
+}
+
+// ParseFaults parses a JSON array of faults, e.g.
+// [{"method": "POST", "path": "/v1/agents", "statuses": [503, 503, 0]}, {"path": "/v1/signing", "latency": "2s"}]
+func ParseFaults(data []byte) ([]Fault, error) {
+	var raw []faultJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse faults: %w", err)
+	}
+
+	faults := make([]Fault, 0, len(raw))
+	for i, r := range raw {
+		f := Fault{Method: r.Method, Path: r.Path, Statuses: r.Statuses, Repeat: r.Repeat}
+		if r.Latency != "" {
+			latency, err := time.ParseDuration(r.Latency)
+			if err != nil {
+				return nil, fmt.Errorf("fault %d: invalid latency %q", i, r.Latency)
+			}
+			f.Latency = latency
+		}
+		for _, status := range r.Statuses {
+			if status != 0 && (status < 100 || status > 599) {
+				return nil, fmt.Errorf("fault %d: invalid status %d", i, status)
+			}
+		}
+		if r.Repeat && len(r.Statuses) == 0 {
+			return nil, fmt.Errorf("fault %d: repeat requires statuses", i)
+		}
+		faults = append(faults, f)
+	}
+	return faults, nil
+}
@@ -0,0 +1,75 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"agent-metadata-action/internal/models"
+)
+
+// lockKey identifies the release lock of an agent version
+func lockKey(agentType, version string) string {
+	return agentType + "/" + version
+}
+
+func (s *Server) lockRelease(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	var req struct {
+		Owner      string `json:"owner"`
+		TTLSeconds int    `json:"ttlSeconds"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || req.Owner == "" || req.TTLSeconds <= 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "owner and a positive ttlSeconds are required"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := lockKey(r.PathValue("agentType"), r.PathValue("version"))
+	// A lapsed lock is free to take; its owner renews one it still holds
+	if held, ok := s.locks[key]; ok && held.Owner != req.Owner && time.Now().Before(held.ExpiresAt) {
+		writeJSON(w, http.StatusConflict, held)
+		return
+	}
+	lock := models.ReleaseLock{Owner: req.Owner, ExpiresAt: time.Now().Add(time.Duration(req.TTLSeconds) * time.Second).UTC()}
+	s.locks[key] = lock
+	writeJSON(w, http.StatusOK, lock)
+}
+
+func (s *Server) unlockRelease(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := lockKey(r.PathValue("agentType"), r.PathValue("version"))
+	held, ok := s.locks[key]
+	if !ok || !time.Now().Before(held.ExpiresAt) {
+		delete(s.locks, key)
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no lock held"})
+		return
+	}
+	if held.Owner != r.URL.Query().Get("owner") {
+		writeJSON(w, http.StatusConflict, held)
+		return
+	}
+	delete(s.locks, key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetLock holds the release lock of an agent version as if a run had taken it, e.g. to test concurrent releases
+func (s *Server) SetLock(agentType, version string, lock models.ReleaseLock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locks[lockKey(agentType, version)] = lock
+}
+
+// Lock returns the release lock held on an agent version, lapsed or not
+func (s *Server) Lock(agentType, version string) (models.ReleaseLock, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lock, ok := s.locks[lockKey(agentType, version)]
+	return lock, ok
+}
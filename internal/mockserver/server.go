@@ -0,0 +1,474 @@
+package mockserver
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"agent-metadata-action/internal/agenttype"
+	"agent-metadata-action/internal/models"
+)
+
+// Request records a request received by the server
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+	Status int
+}
+
+// Server is an in-memory instrumentation metadata and signing service for integration tests and local runs
+// Failures can be injected with AddFault
+type Server struct {
+	mu       sync.Mutex
+	mux      *http.ServeMux
+	token    string
+	metadata map[string]map[string]json.RawMessage
+	signed   []models.SigningRequest
+	uploads  map[string]*upload
+	uploadN  int
+	locks    map[string]models.ReleaseLock
+	requests []Request
+	faults   []*faultState
+	sleep    func(time.Duration)
+
+	// PayloadVersions are the metadata payload versions reported by GET /v1/capabilities and accepted on submission
+	// Set to nil to emulate a service without the capabilities endpoint that only accepts v1
+	PayloadVersions []string
+
+	// Features are the payload features reported by GET /v1/capabilities alongside the payload versions
+	Features []string
+
+	// AgentTypes are the registered agent types reported by GET /v1/agents
+	// Set to nil to emulate a service without the catalog endpoint
+	AgentTypes []string
+
+	// MaxBodySize, if set, rejects request bodies larger than this many bytes with 413 like the service's payload limit
+	MaxBodySize int
+
+	// OnRequest, if set, is called after each request is served
+	OnRequest func(Request)
+}
+
+// New creates a server that requires the given bearer token, or accepts any token if empty
+func New(token string) *Server {
+	s := &Server{
+		mux:      http.NewServeMux(),
+		token:    token,
+		metadata: map[string]map[string]json.RawMessage{},
+		uploads:  map[string]*upload{},
+		locks:    map[string]models.ReleaseLock{},
+		sleep:    time.Sleep,
+
+		PayloadVersions: append([]string(nil), models.PayloadVersions...),
+		Features:        append([]string(nil), models.PayloadFeatures...),
+		AgentTypes:      append([]string(nil), agenttype.Known...),
+	}
+	s.mux.HandleFunc("GET /v1/health", s.health)
+	s.mux.HandleFunc("GET /v1/capabilities", s.capabilities)
+	s.mux.HandleFunc("POST /v1/agents/{agentType}/versions/{version}", s.putMetadata)
+	s.mux.HandleFunc("PATCH /v1/agents/{agentType}/versions/{version}", s.patchMetadata)
+	s.mux.HandleFunc("GET /v1/agents/{agentType}/versions/{version}", s.getMetadata)
+	s.mux.HandleFunc("DELETE /v1/agents/{agentType}/versions/{version}", s.deleteMetadata)
+	s.mux.HandleFunc("PUT /v1/agents/{agentType}/versions/{version}/lock", s.lockRelease)
+	s.mux.HandleFunc("DELETE /v1/agents/{agentType}/versions/{version}/lock", s.unlockRelease)
+	s.mux.HandleFunc("POST /v1/agents/{agentType}/versions/{version}/uploads", s.startUpload)
+	s.mux.HandleFunc("PATCH /v1/agents/{agentType}/versions/{version}/uploads/{uploadID}", s.appendUpload)
+	s.mux.HandleFunc("POST /v1/agents/{agentType}/versions/{version}/uploads/{uploadID}/commit", s.commitUpload)
+	s.mux.HandleFunc("DELETE /v1/agents/{agentType}/versions/{version}/uploads/{uploadID}", s.abortUpload)
+	s.mux.HandleFunc("GET /v1/agents", s.listAgentTypes)
+	s.mux.HandleFunc("GET /v1/agents/{agentType}/versions", s.listVersions)
+	s.mux.HandleFunc("GET /v1/agents/{agentType}/releases", s.listReleases)
+	s.mux.HandleFunc("POST /v1/signing/{clientID}/sign", s.sign)
+	return s
+}
+
+// ServeHTTP records the request, applies any matching fault and otherwise serves the emulated API
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(strings.NewReader(string(body)))
+
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	defer func() {
+		req := Request{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body, Status: rec.status}
+		s.mu.Lock()
+		s.requests = append(s.requests, req)
+		s.mu.Unlock()
+		if s.OnRequest != nil {
+			s.OnRequest(req)
+		}
+	}()
+
+	if latency, status := s.nextFault(r); status != 0 || latency > 0 {
+		s.sleep(latency)
+		if status != 0 {
+			writeJSON(rec, status, map[string]string{"error": fmt.Sprintf("injected fault: %d %s", status, http.StatusText(status))})
+			return
+		}
+	}
+
+	// Health checks are unauthenticated
+	if s.token != "" && r.URL.Path != "/v1/health" && r.Header.Get("Authorization") != "Bearer "+s.token {
+		writeJSON(rec, http.StatusUnauthorized, map[string]string{"error": "invalid or missing bearer token"})
+		return
+	}
+
+	if s.MaxBodySize > 0 && len(body) > s.MaxBodySize {
+		writeJSON(rec, http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("request body of %d bytes exceeds the %d byte limit", len(body), s.MaxBodySize)})
+		return
+	}
+
+	s.mux.ServeHTTP(rec, r)
+}
+
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) capabilities(w http.ResponseWriter, r *http.Request) {
+	if s.PayloadVersions == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	features := s.Features
+	if features == nil {
+		features = []string{}
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"payloadVersions": s.PayloadVersions, "features": features})
+}
+
+// payloadVersion returns the payload version requested with the Accept-Version header, v1 if absent
+func (s *Server) payloadVersion(r *http.Request) (string, error) {
+	version := r.Header.Get("Accept-Version")
+	if version == "" {
+		version = models.PayloadV1
+	}
+	supported := s.PayloadVersions
+	if supported == nil {
+		supported = []string{models.PayloadV1}
+	}
+	for _, v := range supported {
+		if v == version {
+			return version, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported payload version %q", version)
+}
+
+func (s *Server) putMetadata(w http.ResponseWriter, r *http.Request) {
+	payloadVersion, err := s.payloadVersion(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	metadata, ok := decodeMetadata(w, r, payloadVersion)
+	if !ok {
+		return
+	}
+
+	agentType, version := r.PathValue("agentType"), r.PathValue("version")
+	if err := s.SetMetadata(agentType, version, metadata); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.SubmissionResponse{ID: agentType + "@" + version, AgentType: agentType, Version: version})
+}
+
+// decodeMetadata decodes a metadata submission, writing the error response and returning false if it is invalid
+func decodeMetadata(w http.ResponseWriter, r *http.Request, payloadVersion string) (*models.AgentMetadata, bool) {
+	body, err := readBody(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return nil, false
+	}
+	metadata, err := models.DecodePayload(payloadVersion, body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid metadata: %v", err)})
+		return nil, false
+	}
+
+	return metadata, checkVersion(w, r, metadata)
+}
+
+// checkVersion checks the submitted metadata is for the version in the path, writing the error response and
+// returning false if it isn't
+func checkVersion(w http.ResponseWriter, r *http.Request, metadata *models.AgentMetadata) bool {
+	version := r.PathValue("version")
+	if submitted, ok := metadata.Metadata["version"].(string); ok && submitted != version {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"message": "metadata is invalid",
+			"errors": []map[string]string{
+				{"field": "metadata.version", "message": fmt.Sprintf("%q does not match the version in the path %q", submitted, version)},
+			},
+		})
+		return false
+	}
+	return true
+}
+
+// patchMetadata applies an incremental submission to the stored metadata of a version (see
+// models.ApplyDefinitionChanges); versions that haven't been submitted in full are not found
+func (s *Server) patchMetadata(w http.ResponseWriter, r *http.Request) {
+	payloadVersion, err := s.payloadVersion(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	body, err := readBody(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	submitted, removed, err := models.DecodeIncrementalPayload(payloadVersion, body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid metadata: %v", err)})
+		return
+	}
+	if !checkVersion(w, r, submitted) {
+		return
+	}
+
+	agentType, version := r.PathValue("agentType"), r.PathValue("version")
+	stored, ok := s.Metadata(agentType, version)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "metadata not found"})
+		return
+	}
+	current, err := models.DecodePayload(models.PayloadV1, stored)
+	if err == nil {
+		current, err = models.ApplyDefinitionChanges(current, submitted, removed)
+	}
+	if err == nil {
+		err = s.SetMetadata(agentType, version, current)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.SubmissionResponse{ID: agentType + "@" + version, AgentType: agentType, Version: version})
+}
+
+// readBody reads a request body, decompressing it if it is gzip-encoded
+func readBody(r *http.Request) ([]byte, error) {
+	var reader io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %v", err)
+		}
+		reader = gz
+	}
+	return io.ReadAll(reader)
+}
+
+func (s *Server) getMetadata(w http.ResponseWriter, r *http.Request) {
+	payloadVersion, err := s.payloadVersion(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	stored, ok := s.Metadata(r.PathValue("agentType"), r.PathValue("version"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "metadata not found"})
+		return
+	}
+
+	// Stored bodies are v1, so they are re-encoded in other requested versions, keeping empty fields as null and
+	// using the advertised features
+	if payloadVersion != models.PayloadV1 {
+		var metadata *models.AgentMetadata
+		metadata, err = models.DecodePayload(models.PayloadV1, stored)
+		if err == nil {
+			stored, err = models.EncodePayload(payloadVersion, metadata, models.EmptyAsNull, s.Features)
+		}
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(stored)
+}
+
+func (s *Server) deleteMetadata(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	agentType, version := r.PathValue("agentType"), r.PathValue("version")
+	if _, ok := s.metadata[agentType][version]; !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "metadata not found"})
+		return
+	}
+	delete(s.metadata[agentType], version)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listAgentTypes(w http.ResponseWriter, r *http.Request) {
+	if s.AgentTypes == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"agentTypes": s.AgentTypes})
+}
+
+func (s *Server) listVersions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	stored, ok := s.metadata[r.PathValue("agentType")]
+	versions := make([]string, 0, len(stored))
+	for version := range stored {
+		versions = append(versions, version)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "agent type not found"})
+		return
+	}
+	sort.Strings(versions)
+	writeJSON(w, http.StatusOK, map[string][]string{"versions": versions})
+}
+
+// listReleases lists the stored versions of an agent type with their dates, a page at a time
+// The cursor is the offset of the next page, and pageSize defaults to 100
+func (s *Server) listReleases(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	stored, ok := s.metadata[r.PathValue("agentType")]
+	releases := make([]models.ReleaseSummary, 0, len(stored))
+	for version, body := range stored {
+		release := models.ReleaseSummary{Version: version}
+		if metadata, err := models.DecodePayload(models.PayloadV1, body); err == nil {
+			release.ReleaseDate, _ = metadata.Metadata["releaseDate"].(string)
+			release.EOL, _ = metadata.Metadata["eol"].(string)
+		}
+		releases = append(releases, release)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "agent type not found"})
+		return
+	}
+	sort.Slice(releases, func(i, j int) bool { return releases[i].Version < releases[j].Version })
+
+	offset, size := 0, 100
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		var err error
+		if offset, err = strconv.Atoi(cursor); err != nil || offset < 0 || offset > len(releases) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid cursor"})
+			return
+		}
+	}
+	if pageSize := r.URL.Query().Get("pageSize"); pageSize != "" {
+		var err error
+		if size, err = strconv.Atoi(pageSize); err != nil || size < 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid pageSize"})
+			return
+		}
+	}
+	end := min(offset+size, len(releases))
+	response := map[string]any{"releases": releases[offset:end]}
+	if end < len(releases) {
+		response["nextCursor"] = strconv.Itoa(end)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) sign(w http.ResponseWriter, r *http.Request) {
+	var req models.SigningRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid signing request: %v", err)})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	s.signed = append(s.signed, req)
+	id := fmt.Sprintf("signature-%d", len(s.signed))
+	s.mu.Unlock()
+
+	success := true
+	writeJSON(w, http.StatusOK, models.SigningResponse{Success: &success, ID: id, Digest: req.Digest})
+}
+
+// SetMetadata stores metadata as if it had been submitted, e.g. to seed reconciliation tests
+func (s *Server) SetMetadata(agentType, version string, metadata *models.AgentMetadata) error {
+	body, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.metadata[agentType] == nil {
+		s.metadata[agentType] = map[string]json.RawMessage{}
+	}
+	s.metadata[agentType][version] = body
+	return nil
+}
+
+// Metadata returns the metadata stored for an agent version as a v1 body, whichever version it was submitted in
+func (s *Server) Metadata(agentType, version string) (json.RawMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, ok := s.metadata[agentType][version]
+	return body, ok
+}
+
+// Signed returns the signing requests the server accepted
+func (s *Server) Signed() []models.SigningRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.SigningRequest(nil), s.signed...)
+}
+
+// Requests returns every request the server received, including rejected ones
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Reset clears stored metadata, pending uploads, release locks, signing requests, recorded requests and faults
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metadata = map[string]map[string]json.RawMessage{}
+	s.uploads = map[string]*upload{}
+	s.locks = map[string]models.ReleaseLock{}
+	s.signed = nil
+	s.requests = nil
+	s.faults = nil
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,492 @@
+package mockserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"agent-metadata-action/internal/client"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/sign"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, token string) (*Server, *httptest.Server) {
+	t.Helper()
+	server := New(token)
+	server.sleep = func(time.Duration) {}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	return server, ts
+}
+
+func do(t *testing.T, method, url, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer test-token")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(data)
+}
+
+func TestServer_InstrumentationClientRoundTrip(t *testing.T) {
+	server, ts := newTestServer(t, "test-token")
+	c := client.NewInstrumentationClient(ts.URL, "test-token")
+	ctx := context.Background()
+	testutil.CaptureOutput(t)
+
+	metadata := &models.AgentMetadata{Metadata: map[string]interface{}{"version": "1.2.3"}}
+	require.NoError(t, c.SendMetadata(ctx, "NRJavaAgent", "1.2.3", metadata))
+	require.NoError(t, server.SetMetadata("NRJavaAgent", "1.0.0", metadata))
+
+	got, err := c.GetMetadata(ctx, "NRJavaAgent", "1.2.3")
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3", got.Metadata["version"])
+
+	missing, err := c.GetMetadata(ctx, "NRJavaAgent", "9.9.9")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	versions, err := c.ListVersions(ctx, "NRJavaAgent")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.0.0", "1.2.3"}, versions)
+
+	versions, err = c.ListVersions(ctx, "NRNodeAgent")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+
+	_, stored := server.Metadata("NRJavaAgent", "1.2.3")
+	assert.True(t, stored)
+
+	deleted, err := c.DeleteMetadata(ctx, "NRJavaAgent", "1.2.3")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	_, stored = server.Metadata("NRJavaAgent", "1.2.3")
+	assert.False(t, stored)
+
+	deleted, err = c.DeleteMetadata(ctx, "NRJavaAgent", "1.2.3")
+	require.NoError(t, err)
+	assert.False(t, deleted)
+}
+
+func TestServer_CompressedSubmission(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	c := client.NewInstrumentationClient(ts.URL, "any-token")
+	c.SetCompressionThreshold(1)
+	testutil.CaptureOutput(t)
+
+	require.NoError(t, c.SendMetadata(context.Background(), "NRJavaAgent", "1.2.3", &models.AgentMetadata{
+		Metadata: models.Metadata{"version": "1.2.3"},
+	}))
+
+	stored, ok := server.Metadata("NRJavaAgent", "1.2.3")
+	require.True(t, ok)
+	assert.Contains(t, string(stored), `"version":"1.2.3"`)
+	requests := server.Requests()
+	assert.Equal(t, "gzip", requests[len(requests)-1].Header.Get("Content-Encoding"))
+}
+
+func largeMetadata(version string, definitions int) *models.AgentMetadata {
+	metadata := &models.AgentMetadata{Metadata: models.Metadata{"version": version}}
+	for i := 0; i < definitions; i++ {
+		metadata.ConfigurationDefinitions = append(metadata.ConfigurationDefinitions, models.ConfigurationDefinition{
+			"platform": "ALL",
+			"type":     fmt.Sprintf("type-%d", i),
+			"version":  "1.0.0",
+			"schema":   strings.Repeat("a", 400),
+		})
+	}
+	return metadata
+}
+
+func TestServer_ChunkedSubmission(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	server.MaxBodySize = 1024
+	c := client.NewInstrumentationClient(ts.URL, "any-token")
+	c.SetMaxPayloadSize(1024)
+	testutil.CaptureOutput(t)
+
+	response, err := c.SubmitMetadata(context.Background(), "NRJavaAgent", "1.2.3", largeMetadata("1.2.3", 5))
+	require.NoError(t, err)
+	assert.Equal(t, "NRJavaAgent@1.2.3", response.ID)
+
+	stored, ok := server.Metadata("NRJavaAgent", "1.2.3")
+	require.True(t, ok)
+	got, err := models.DecodePayload(models.PayloadV1, stored)
+	require.NoError(t, err)
+	require.Len(t, got.ConfigurationDefinitions, 5)
+	for i, definition := range got.ConfigurationDefinitions {
+		assert.Equal(t, fmt.Sprintf("type-%d", i), definition["type"])
+	}
+
+	var methods []string
+	for _, req := range server.Requests() {
+		assert.LessOrEqual(t, len(req.Body), 1024)
+		methods = append(methods, req.Method)
+	}
+	assert.Equal(t, []string{"POST", "PATCH", "PATCH", "PATCH", "POST"}, methods)
+	assert.Zero(t, server.PendingUploads())
+}
+
+func TestServer_IncrementalSubmission(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	c := client.NewInstrumentationClient(ts.URL, "any-token")
+	ctx := context.Background()
+	testutil.CaptureOutput(t)
+	metadata := largeMetadata("1.2.3", 3)
+	removed := models.RemovedDefinitions{ConfigurationDefinitions: []models.ConfigurationDefinition{{"platform": "ALL", "type": "type-1", "version": "1.0.0"}}}
+	changed := &models.AgentMetadata{
+		Metadata:                 models.Metadata{"version": "1.2.3", "features": []interface{}{"incremental"}},
+		ConfigurationDefinitions: []models.ConfigurationDefinition{{"platform": "ALL", "type": "type-3", "version": "1.0.0"}},
+	}
+
+	_, err := c.SubmitIncremental(ctx, "NRJavaAgent", "1.2.3", changed, removed)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 404")
+
+	require.NoError(t, c.SendMetadata(ctx, "NRJavaAgent", "1.2.3", metadata))
+	response, err := c.SubmitIncremental(ctx, "NRJavaAgent", "1.2.3", changed, removed)
+	require.NoError(t, err)
+	assert.Equal(t, "NRJavaAgent@1.2.3", response.ID)
+
+	got, err := c.GetMetadata(ctx, "NRJavaAgent", "1.2.3")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"incremental"}, got.Metadata["features"])
+	var types []interface{}
+	for _, definition := range got.ConfigurationDefinitions {
+		types = append(types, definition["type"])
+	}
+	assert.Equal(t, []interface{}{"type-0", "type-2", "type-3"}, types)
+	requests := server.Requests()
+	last := requests[len(requests)-2]
+	assert.Equal(t, "PATCH", last.Method)
+	assert.NotEmpty(t, last.Header.Get("Idempotency-Key"))
+	assert.Contains(t, string(last.Body), `"removedDefinitions"`)
+}
+
+func TestServer_ReleaseLock(t *testing.T) {
+	server, ts := newTestServer(t, "test-token")
+	c := client.NewInstrumentationClient(ts.URL, "test-token")
+	ctx := context.Background()
+	testutil.CaptureOutput(t)
+
+	lock, err := c.LockRelease(ctx, "NRJavaAgent", "1.2.3", "newrelic/java-agent run 1", 30*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "newrelic/java-agent run 1", lock.Owner)
+	assert.WithinDuration(t, time.Now().Add(30*time.Minute), lock.ExpiresAt, time.Minute)
+
+	renewed, err := c.LockRelease(ctx, "NRJavaAgent", "1.2.3", "newrelic/java-agent run 1", time.Hour)
+	require.NoError(t, err, "The owner renews its own lock")
+	assert.True(t, renewed.ExpiresAt.After(lock.ExpiresAt))
+
+	_, err = c.LockRelease(ctx, "NRJavaAgent", "1.2.3", "newrelic/java-agent run 2", 30*time.Minute)
+	var locked *client.ReleaseLockedError
+	require.ErrorAs(t, err, &locked)
+	assert.Equal(t, "newrelic/java-agent run 1", locked.Lock.Owner)
+	assert.Contains(t, err.Error(), "release of NRJavaAgent version 1.2.3 already in progress by newrelic/java-agent run 1")
+
+	_, err = c.LockRelease(ctx, "NRJavaAgent", "1.2.4", "newrelic/java-agent run 2", 30*time.Minute)
+	require.NoError(t, err, "Other versions are locked separately")
+
+	assert.ErrorAs(t, c.UnlockRelease(ctx, "NRJavaAgent", "1.2.3", "newrelic/java-agent run 2"), &locked, "Only the owner releases the lock")
+	require.NoError(t, c.UnlockRelease(ctx, "NRJavaAgent", "1.2.3", "newrelic/java-agent run 1"))
+	_, held := server.Lock("NRJavaAgent", "1.2.3")
+	assert.False(t, held)
+	require.NoError(t, c.UnlockRelease(ctx, "NRJavaAgent", "1.2.3", "newrelic/java-agent run 1"), "Releasing a lock no longer held is fine")
+
+	server.SetLock("NRJavaAgent", "1.2.5", models.ReleaseLock{Owner: "newrelic/java-agent run 1", ExpiresAt: time.Now().Add(-time.Minute)})
+	lock, err = c.LockRelease(ctx, "NRJavaAgent", "1.2.5", "newrelic/java-agent run 2", 30*time.Minute)
+	require.NoError(t, err, "A lapsed lock is free to take")
+	assert.Equal(t, "newrelic/java-agent run 2", lock.Owner)
+}
+
+func TestServer_ChunkedSubmissionRollback(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	server.AddFault(Fault{Method: http.MethodPatch, Statuses: []int{0, http.StatusBadRequest}})
+	c := client.NewInstrumentationClient(ts.URL, "any-token")
+	c.SetMaxPayloadSize(1024)
+	testutil.CaptureOutput(t)
+
+	_, err := c.SubmitMetadata(context.Background(), "NRJavaAgent", "1.2.3", largeMetadata("1.2.3", 5))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to send configuration definitions part 2 of 3")
+
+	_, ok := server.Metadata("NRJavaAgent", "1.2.3")
+	assert.False(t, ok, "a failed chunked submission must not store the version")
+	assert.Zero(t, server.PendingUploads())
+	requests := server.Requests()
+	assert.Equal(t, http.MethodDelete, requests[len(requests)-1].Method)
+}
+
+func TestServer_PayloadTooLarge(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	server.MaxBodySize = 1024
+	c := client.NewInstrumentationClient(ts.URL, "any-token")
+	testutil.CaptureOutput(t)
+
+	_, err := c.SubmitMetadata(context.Background(), "NRJavaAgent", "1.2.3", largeMetadata("1.2.3", 5))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "set max-payload-size")
+	assert.Len(t, server.Requests(), 1, "413 is not retried")
+}
+
+func TestServer_Health(t *testing.T) {
+	_, ts := newTestServer(t, "test-token")
+
+	resp, err := http.Get(ts.URL + "/v1/health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "health checks don't need a token")
+}
+
+func TestServer_AgentTypes(t *testing.T) {
+	server, ts := newTestServer(t, "test-token")
+
+	status, body := do(t, http.MethodGet, ts.URL+"/v1/agents", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"NRJavaAgent"`)
+
+	server.AgentTypes = nil
+	status, _ = do(t, http.MethodGet, ts.URL+"/v1/agents", "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestServer_PayloadVersions(t *testing.T) {
+	server, ts := newTestServer(t, "test-token")
+	ctx := context.Background()
+	testutil.CaptureOutput(t)
+
+	status, body := do(t, http.MethodGet, ts.URL+"/v1/capabilities", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"payloadVersions": ["v1", "v2", "v3"], "features": ["localizedDescriptions", "agentControlExtensions", "compressedSchemas"]}`, body)
+
+	c := client.NewInstrumentationClient(ts.URL, "test-token")
+	c.SetPayloadVersion(client.PayloadVersionAuto)
+	metadata := &models.AgentMetadata{
+		ConfigurationDefinitions: []models.ConfigurationDefinition{{"platform": "ALL"}},
+		Metadata:                 models.Metadata{"version": "1.2.3"},
+	}
+	response, err := c.SubmitMetadata(ctx, "NRJavaAgent", "1.2.3", metadata)
+	require.NoError(t, err)
+	assert.Equal(t, "NRJavaAgent@1.2.3", response.ID)
+	assert.Equal(t, models.PayloadV3, c.PayloadVersion(ctx))
+
+	// Stored metadata is v1 whichever version was submitted
+	stored, ok := server.Metadata("NRJavaAgent", "1.2.3")
+	require.True(t, ok)
+	assert.Contains(t, string(stored), `"configurationDefinitions"`)
+
+	got, err := c.GetMetadata(ctx, "NRJavaAgent", "1.2.3")
+	require.NoError(t, err)
+	assert.Equal(t, metadata.ConfigurationDefinitions, got.ConfigurationDefinitions)
+
+	// A service without capabilities only accepts v1
+	server.PayloadVersions = nil
+	status, _ = do(t, http.MethodGet, ts.URL+"/v1/capabilities", "")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/agents/NRJavaAgent/versions/1.2.3", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Accept-Version", models.PayloadV2)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestServer_Signing(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	c := sign.NewClient(ts.URL, "any-token")
+	testutil.CaptureOutput(t)
+
+	req := &models.SigningRequest{Registry: "docker.io", Repository: "newrelic/agents", Tag: "1.2.3", Digest: "sha256:" + strings.Repeat("a", 64)}
+	response, err := c.Sign(context.Background(), "java-agent", req)
+	require.NoError(t, err)
+	assert.Equal(t, "signature-1", response.ID)
+
+	assert.Equal(t, []models.SigningRequest{*req}, server.Signed())
+
+	status, body := do(t, "POST", ts.URL+"/v1/signing/java-agent/sign", `{"registry": "docker.io"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "is required")
+}
+
+func TestServer_Authorization(t *testing.T) {
+	_, ts := newTestServer(t, "other-token")
+
+	status, body := do(t, "GET", ts.URL+"/v1/agents/NRJavaAgent/versions", "")
+
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Contains(t, body, "invalid or missing bearer token")
+}
+
+func TestServer_InvalidMetadata(t *testing.T) {
+	_, ts := newTestServer(t, "")
+
+	status, body := do(t, "POST", ts.URL+"/v1/agents/NRJavaAgent/versions/1.2.3", "not json")
+
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "invalid metadata")
+}
+
+func TestServer_MismatchedVersion(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	c := client.NewInstrumentationClient(ts.URL, "any-token")
+	testutil.CaptureOutput(t)
+
+	err := c.SendMetadata(context.Background(), "NRJavaAgent", "1.2.3", &models.AgentMetadata{
+		Metadata: models.Metadata{"version": "1.2.4"},
+	})
+
+	var validationErr *client.ValidationError
+	require.True(t, errors.As(err, &validationErr), "expected a ValidationError, got %v", err)
+	require.Len(t, validationErr.Details, 1)
+	assert.Equal(t, "metadata.version", validationErr.Details[0].Field)
+	_, stored := server.Metadata("NRJavaAgent", "1.2.3")
+	assert.False(t, stored)
+}
+
+func TestServer_Faults(t *testing.T) {
+	tests := []struct {
+		name     string
+		fault    Fault
+		method   string
+		path     string
+		expected []int
+	}{
+		{
+			name:     "flaky sequence then healthy",
+			fault:    Fault{Method: "GET", Path: "/v1/agents", Statuses: []int{503, 429, 0}},
+			method:   "GET",
+			path:     "/v1/agents/NRJavaAgent/versions",
+			expected: []int{503, 429, 404, 404},
+		},
+		{
+			name:     "repeating failure",
+			fault:    Fault{Path: "/v1/agents", Statuses: []int{500}, Repeat: true},
+			method:   "GET",
+			path:     "/v1/agents/NRJavaAgent/versions",
+			expected: []int{500, 500, 500},
+		},
+		{
+			name:     "non matching method",
+			fault:    Fault{Method: "POST", Statuses: []int{500}, Repeat: true},
+			method:   "GET",
+			path:     "/v1/agents/NRJavaAgent/versions",
+			expected: []int{404},
+		},
+		{
+			name:     "non matching path",
+			fault:    Fault{Path: "/v1/signing", Statuses: []int{500}, Repeat: true},
+			method:   "GET",
+			path:     "/v1/agents/NRJavaAgent/versions",
+			expected: []int{404},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, ts := newTestServer(t, "")
+			server.AddFault(tt.fault)
+
+			var statuses []int
+			for range tt.expected {
+				status, _ := do(t, tt.method, ts.URL+tt.path, "")
+				statuses = append(statuses, status)
+			}
+
+			assert.Equal(t, tt.expected, statuses)
+			requests := server.Requests()
+			require.Len(t, requests, len(tt.expected))
+			assert.Equal(t, tt.expected[0], requests[0].Status)
+		})
+	}
+}
+
+func TestServer_Latency(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	var slept time.Duration
+	server.sleep = func(d time.Duration) { slept += d }
+	server.AddFault(Fault{Path: "/v1/signing", Latency: 3 * time.Second})
+
+	status, _ := do(t, "POST", ts.URL+"/v1/signing/java-agent/sign", `{}`)
+
+	assert.Equal(t, http.StatusBadRequest, status, "latency alone still serves the request")
+	assert.Equal(t, 3*time.Second, slept)
+}
+
+func TestServer_Reset(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	server.AddFault(Fault{Statuses: []int{500}, Repeat: true})
+	require.NoError(t, server.SetMetadata("NRJavaAgent", "1.0.0", &models.AgentMetadata{}))
+	do(t, "GET", ts.URL+"/v1/agents/NRJavaAgent/versions", "")
+
+	server.Reset()
+
+	assert.Empty(t, server.Requests())
+	status, _ := do(t, "GET", ts.URL+"/v1/agents/NRJavaAgent/versions", "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestParseFaults(t *testing.T) {
+	faults, err := ParseFaults([]byte(`[
+		{"method": "POST", "path": "/v1/agents", "statuses": [503, 0]},
+		{"path": "/v1/signing", "latency": "1.5s", "statuses": [500], "repeat": true}
+	]`))
+	require.NoError(t, err)
+	assert.Equal(t, []Fault{
+		{Method: "POST", Path: "/v1/agents", Statuses: []int{503, 0}},
+		{Path: "/v1/signing", Statuses: []int{500}, Latency: 1500 * time.Millisecond, Repeat: true},
+	}, faults)
+
+	for input, expectedErr := range map[string]string{
+		`{}`:                     "failed to parse faults",
+		`[{"latency": "soon"}]`:  `fault 0: invalid latency "soon"`,
+		`[{"statuses": [42]}]`:   "fault 0: invalid status 42",
+		`[{}, {"repeat": true}]`: "fault 1: repeat requires statuses",
+	} {
+		_, err := ParseFaults([]byte(input))
+		require.Error(t, err, input)
+		assert.Contains(t, err.Error(), expectedErr)
+	}
+}
+
+func TestServer_ListReleases(t *testing.T) {
+	server, ts := newTestServer(t, "")
+	c := client.NewInstrumentationClient(ts.URL, "")
+	testutil.CaptureOutput(t)
+	for i := 0; i < 150; i++ {
+		version := fmt.Sprintf("1.%03d.0", i)
+		metadata := &models.AgentMetadata{Metadata: map[string]interface{}{"version": version, "releaseDate": "2026-01-01"}}
+		if i == 0 {
+			metadata.Metadata["eol"] = "2027-01-01"
+		}
+		require.NoError(t, server.SetMetadata("NRJavaAgent", version, metadata))
+	}
+
+	// method under test
+	releases, err := c.ListReleases(context.Background(), "NRJavaAgent")
+
+	require.NoError(t, err)
+	require.Len(t, releases, 150, "Both pages are listed")
+	assert.Equal(t, models.ReleaseSummary{Version: "1.000.0", ReleaseDate: "2026-01-01", EOL: "2027-01-01"}, releases[0])
+	assert.Equal(t, "1.149.0", releases[149].Version)
+
+	status, body := do(t, "GET", ts.URL+"/v1/agents/NRJavaAgent/releases?pageSize=2&cursor=148", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotContains(t, body, "nextCursor")
+}
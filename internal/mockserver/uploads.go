@@ -0,0 +1,109 @@
+package mockserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"agent-metadata-action/internal/models"
+)
+
+// upload is a chunked metadata submission that has been started but not yet committed or aborted
+type upload struct {
+	agentType string
+	version   string
+	metadata  *models.AgentMetadata
+}
+
+func (s *Server) startUpload(w http.ResponseWriter, r *http.Request) {
+	payloadVersion, err := s.payloadVersion(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	metadata, ok := decodeMetadata(w, r, payloadVersion)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	s.uploadN++
+	id := fmt.Sprintf("upload-%d", s.uploadN)
+	s.uploads[id] = &upload{agentType: r.PathValue("agentType"), version: r.PathValue("version"), metadata: metadata}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, map[string]string{"uploadId": id})
+}
+
+func (s *Server) appendUpload(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	var batch struct {
+		ConfigurationDefinitions []models.ConfigurationDefinition `json:"configurationDefinitions"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid configuration definitions: %v", err)})
+		return
+	}
+
+	models.RestoreConfigurationDefinitions(batch.ConfigurationDefinitions)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending, ok := s.upload(r)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "upload not found"})
+		return
+	}
+	pending.metadata.ConfigurationDefinitions = append(pending.metadata.ConfigurationDefinitions, batch.ConfigurationDefinitions...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) commitUpload(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	pending, ok := s.upload(r)
+	if ok {
+		delete(s.uploads, r.PathValue("uploadID"))
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "upload not found"})
+		return
+	}
+
+	if err := s.SetMetadata(pending.agentType, pending.version, pending.metadata); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, models.SubmissionResponse{ID: pending.agentType + "@" + pending.version, AgentType: pending.agentType, Version: pending.version})
+}
+
+func (s *Server) abortUpload(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.upload(r); !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "upload not found"})
+		return
+	}
+	delete(s.uploads, r.PathValue("uploadID"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// upload returns the pending upload named by the request path; callers must hold s.mu
+func (s *Server) upload(r *http.Request) (*upload, bool) {
+	pending, ok := s.uploads[r.PathValue("uploadID")]
+	if !ok || pending.agentType != r.PathValue("agentType") || pending.version != r.PathValue("version") {
+		return nil, false
+	}
+	return pending, true
+}
+
+// PendingUploads returns the number of chunked uploads that have been started but not committed or aborted
+func (s *Server) PendingUploads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.uploads)
+}
@@ -1,18 +1,120 @@
 package models
 
 import (
+	"agent-metadata-action/internal/fileutil"
+	"agent-metadata-action/internal/validation"
 	"fmt"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 )
 
+// Remote artifact sources that are downloaded before being pushed to the registry
+const (
+	SourceS3    = "s3"
+	SourceGCS   = "gs"
+	SourceHTTPS = "https"
+)
+
+// FormatTarZstd is the format of zstd-compressed tarballs, which are smaller to pull than tar+gzip for large agents
+// but whose layer media type not every registry accepts
+const FormatTarZstd = "tar+zstd"
+
+// FormatJar is the format of Java agent jars, zip archives whose manifest is checked before upload
+const FormatJar = "jar"
+
+// FormatMSI is the format of Windows installer packages, uploaded as they are rather than as an archive of files
+const FormatMSI = "msi"
+
+// DefaultVersionAttribute is the jar manifest attribute checked against the agent version
+const DefaultVersionAttribute = "Implementation-Version"
+
+// Artifact kinds: files uploaded as single-layer manifests, and already-built container images added to the index
+const (
+	KindFile  = "file"
+	KindImage = "image"
+)
+
+var sha256Pattern = regexp.MustCompile(`^[a-fA-F0-9]{64}$`)
+
+var manifestDigestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// annotationKeyPattern matches annotation keys in reverse domain notation, e.g. com.example.team or
+// org.opencontainers.image.source
+var annotationKeyPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*(\.[a-zA-Z0-9]+([_-][a-zA-Z0-9]+)*)+$`)
+
+// reservedAnnotations are the annotation keys, and namespaces ending in a dot, the action sets itself, so vendor
+// annotations can't override them
+var reservedAnnotations = []string{
+	"org.opencontainers.image.title",
+	"org.opencontainers.image.version",
+	"org.opencontainers.image.created",
+	"com.newrelic.artifact.",
+	"com.newrelic.retention.",
+	"com.newrelic.build.",
+}
+
+var imageReferencePattern = regexp.MustCompile(`^[^\s@/]+(/[^\s@/]+)+@sha256:[a-f0-9]{64}$`)
+
 type ArtifactDefinition struct {
 	Name   string `json:"name"`
 	Path   string `json:"path"`
 	OS     string `json:"os"`
 	Arch   string `json:"arch"`
 	Format string `json:"format"`
+	SHA256 string `json:"sha256,omitempty"` // Expected hex digest of the artifact, verified before upload; required for https:// paths
+	Digest string `json:"digest,omitempty"` // Digest of a manifest already pushed to the registry, used instead of path
+	Kind   string `json:"kind,omitempty"`   // KindFile (the default) or KindImage
+	Image  string `json:"image,omitempty"`  // Container image of a KindImage artifact, as <registry>/<repository>@<digest>
+
+	RequireAuthenticode bool `json:"requireAuthenticode,omitempty"` // Fail unless every .exe, .dll and .msi in the archive, or the msi package itself, is Authenticode signed
+	CheckNotarization   bool `json:"checkNotarization,omitempty"`   // Check the Mach-O binaries in the archive are codesigned and notarized
+
+	VersionAttribute string `json:"versionAttribute,omitempty"` // Jar manifest attribute holding the agent version; DefaultVersionAttribute if empty
+
+	LegalFiles   []string `json:"-"` // Archive entries of the required legal files, set once the archive is checked
+	Notarization string   `json:"-"` // Notarization status of the Mach-O binaries, set once the archive is checked
+}
+
+// IsReference reports whether the artifact references an already-pushed manifest rather than a file to upload
+func (a *ArtifactDefinition) IsReference() bool {
+	return a.Digest != "" || a.IsImage()
+}
+
+// IsImage reports whether the artifact is a container image rather than a file
+func (a *ArtifactDefinition) IsImage() bool {
+	return strings.EqualFold(a.Kind, KindImage)
+}
+
+// ImageRepository returns the repository of an image artifact, e.g. docker.io/newrelic/infrastructure
+func (a *ArtifactDefinition) ImageRepository() string {
+	repository, _, _ := strings.Cut(a.Image, "@")
+	return repository
+}
+
+// ImageDigest returns the digest of an image artifact
+func (a *ArtifactDefinition) ImageDigest() string {
+	_, imageDigest, _ := strings.Cut(a.Image, "@")
+	return imageDigest
+}
+
+// RemoteSource returns the scheme of a remote artifact path (SourceS3, SourceGCS or SourceHTTPS), or "" for local paths
+func (a *ArtifactDefinition) RemoteSource() string {
+	scheme, _, found := strings.Cut(a.Path, "://")
+	if !found {
+		return ""
+	}
+	switch strings.ToLower(scheme) {
+	case SourceS3:
+		return SourceS3
+	case SourceGCS:
+		return SourceGCS
+	case SourceHTTPS:
+		return SourceHTTPS
+	}
+	return ""
 }
 
 func (a *ArtifactDefinition) Validate() error {
@@ -25,10 +127,41 @@ func (a *ArtifactDefinition) Validate() error {
 		return fmt.Errorf("invalid artifact name '%s': must contain only alphanumeric characters, hyphens, and underscores", a.Name)
 	}
 
-	if a.Path == "" {
+	if a.Kind != "" && !strings.EqualFold(a.Kind, KindFile) && !a.IsImage() {
+		return fmt.Errorf("invalid kind '%s' for artifact '%s': must be '%s' or '%s'", a.Kind, a.Name, KindFile, KindImage)
+	}
+
+	switch {
+	case a.IsImage():
+		if a.Path != "" || a.Digest != "" {
+			return fmt.Errorf("image artifact '%s' is given by image, not path or digest", a.Name)
+		}
+		if !imageReferencePattern.MatchString(a.Image) {
+			return fmt.Errorf("invalid image '%s' for artifact '%s': must be <registry>/<repository>@sha256:<64 hexadecimal characters>", a.Image, a.Name)
+		}
+	case a.Image != "":
+		return fmt.Errorf("image is only supported for artifacts of kind '%s', which artifact '%s' isn't", KindImage, a.Name)
+	case a.IsReference():
+		if a.Path != "" {
+			return fmt.Errorf("artifact '%s' must set either path or digest, not both", a.Name)
+		}
+		if !manifestDigestPattern.MatchString(a.Digest) {
+			return fmt.Errorf("invalid digest '%s' for artifact '%s': must be sha256:<64 hexadecimal characters>", a.Digest, a.Name)
+		}
+	case a.Path == "":
 		return fmt.Errorf("path is required for artifact '%s'", a.Name)
 	}
 
+	if strings.Contains(a.Path, "://") {
+		if err := a.validateRemotePath(); err != nil {
+			return err
+		}
+	}
+
+	if a.SHA256 != "" && !sha256Pattern.MatchString(a.SHA256) {
+		return fmt.Errorf("invalid sha256 for artifact '%s': must be 64 hexadecimal characters", a.Name)
+	}
+
 	if a.OS == "" {
 		return fmt.Errorf("os is required for artifact '%s'", a.Name)
 	}
@@ -51,14 +184,63 @@ func (a *ArtifactDefinition) Validate() error {
 		}
 	}
 
+	if a.RequireAuthenticode {
+		if a.IsReference() {
+			return fmt.Errorf("requireAuthenticode isn't supported for artifact '%s': referenced manifests aren't checked", a.Name)
+		}
+		if !strings.EqualFold(a.OS, "windows") {
+			return fmt.Errorf("requireAuthenticode is only supported for windows artifacts, but artifact '%s' has os '%s'", a.Name, a.OS)
+		}
+	}
+
+	if a.CheckNotarization {
+		if a.IsReference() {
+			return fmt.Errorf("checkNotarization isn't supported for artifact '%s': referenced manifests aren't checked", a.Name)
+		}
+		if !strings.EqualFold(a.OS, "darwin") {
+			return fmt.Errorf("checkNotarization is only supported for darwin artifacts, but artifact '%s' has os '%s'", a.Name, a.OS)
+		}
+	}
+
+	// Referenced manifests already carry their content, so there is no archive format to describe
+	if a.Format == "" && a.IsReference() {
+		return nil
+	}
+
 	if a.Format == "" {
 		return fmt.Errorf("format is required for artifact '%s'", a.Name)
 	}
 
-	if !strings.EqualFold(a.Format, "tar") && !strings.EqualFold(a.Format, "tar+gzip") && !strings.EqualFold(a.Format, "zip") {
-		return fmt.Errorf("invalid format '%s' for artifact '%s': must be 'tar', 'tar+gzip', or 'zip'", a.Format, a.Name)
+	if !strings.EqualFold(a.Format, "tar") && !strings.EqualFold(a.Format, "tar+gzip") && !strings.EqualFold(a.Format, FormatTarZstd) && !strings.EqualFold(a.Format, "zip") && !strings.EqualFold(a.Format, FormatJar) && !a.IsMSI() {
+		return fmt.Errorf("invalid format '%s' for artifact '%s': must be 'tar', 'tar+gzip', '%s', 'zip', '%s', or '%s'", a.Format, a.Name, FormatTarZstd, FormatJar, FormatMSI)
+	}
+
+	if a.IsMSI() && !strings.EqualFold(a.OS, "windows") {
+		return fmt.Errorf("format %s is only supported for windows artifacts, but artifact '%s' has os '%s'", FormatMSI, a.Name, a.OS)
 	}
 
+	if a.VersionAttribute != "" && !a.IsJar() {
+		return fmt.Errorf("versionAttribute is only supported for %s artifacts, but artifact '%s' has format '%s'", FormatJar, a.Name, a.Format)
+	}
+
+	return nil
+}
+
+// validateRemotePath checks a remote path has a supported scheme and names a file
+func (a *ArtifactDefinition) validateRemotePath() error {
+	source := a.RemoteSource()
+	if source == "" {
+		return fmt.Errorf("invalid path '%s' for artifact '%s': remote paths must use %s://, %s:// or %s://", a.Path, a.Name, SourceS3, SourceGCS, SourceHTTPS)
+	}
+	_, location, _ := strings.Cut(a.Path, "://")
+	host, key, _ := strings.Cut(location, "/")
+	if host == "" || key == "" || strings.HasSuffix(key, "/") {
+		return fmt.Errorf("invalid path '%s' for artifact '%s': must reference a file as <scheme>://<host or bucket>/<path>", a.Path, a.Name)
+	}
+	// Downloads from a URL have no stored checksum to verify against, so the content must be pinned
+	if source == SourceHTTPS && a.SHA256 == "" {
+		return fmt.Errorf("sha256 is required for artifact '%s' with an %s:// path", a.Name, SourceHTTPS)
+	}
 	return nil
 }
 
@@ -66,6 +248,34 @@ func (a *ArtifactDefinition) GetMediaType() string {
 	return fmt.Sprintf("application/vnd.newrelic.agent.content.v1.%s", a.Format)
 }
 
+// IsZstd reports whether the artifact is a zstd-compressed tarball
+func (a *ArtifactDefinition) IsZstd() bool {
+	return strings.EqualFold(a.Format, FormatTarZstd)
+}
+
+// IsJar reports whether the artifact is a Java agent jar
+func (a *ArtifactDefinition) IsJar() bool {
+	return strings.EqualFold(a.Format, FormatJar)
+}
+
+// IsMSI reports whether the artifact is a Windows installer package rather than an archive
+func (a *ArtifactDefinition) IsMSI() bool {
+	return strings.EqualFold(a.Format, FormatMSI)
+}
+
+// GetVersionAttribute returns the jar manifest attribute checked against the agent version
+func (a *ArtifactDefinition) GetVersionAttribute() string {
+	if a.VersionAttribute == "" {
+		return DefaultVersionAttribute
+	}
+	return a.VersionAttribute
+}
+
+// IsZipFormat reports whether archives of format are zip archives, as jars are
+func IsZipFormat(format string) bool {
+	return strings.EqualFold(format, "zip") || strings.EqualFold(format, FormatJar)
+}
+
 func (a *ArtifactDefinition) GetArtifactType() string {
 	return "application/vnd.newrelic.agent.v1"
 }
@@ -75,7 +285,10 @@ func (a *ArtifactDefinition) GetPlatformString() string {
 }
 
 func (a *ArtifactDefinition) GetFilename() string {
-	return filepath.Base(a.Path)
+	if a.RemoteSource() != "" {
+		return path.Base(a.Path)
+	}
+	return filepath.Base(fileutil.NormalizePath(a.Path))
 }
 
 type OCIConfig struct {
@@ -83,10 +296,63 @@ type OCIConfig struct {
 	Username  string               // Registry username
 	Password  string               // Registry password or token
 	Artifacts []ArtifactDefinition // Array of artifact definitions
+
+	// Connection relaxations for ephemeral registries, e.g. in-cluster registries of e2e pipelines
+	PlainHTTP             bool // use HTTP rather than HTTPS, as is done for localhost registries anyway
+	InsecureSkipTLSVerify bool // accept any TLS certificate, e.g. a self-signed one
+
+	PendingTag bool // push the manifest index under <version>-pending, for promotion with mode: promote
+
+	Layout string // OCI image layout directory, relative to the workspace, also written to; the only destination without Registry
+
+	MaxBlobSize int64 // largest blob in bytes the registry accepts; larger artifacts are split into several layers
+
+	// Malware scanning of each binary before upload; a detection blocks the push
+	ScanCommand string // scanner CLI, e.g. clamscan, run with the binary path
+	ScanURL     string // scanning service each binary is posted to
+	ScanToken   string // Bearer token for ScanURL
+
+	RequiredLegalFiles []string // legal files every archive must contain, e.g. LICENSE
+
+	NotarizationStrict bool // block uploads of darwin binaries checked for notarization that aren't notarized
+
+	ContentManifests bool // attach the file list of each archive as a referrer of its manifest
+
+	NormalizeArchives bool // re-package archives with fixed timestamps, order and owners for reproducible digests
+
+	ReleaseNotes string // MDX release note or changelog, relative to the workspace, attached as a referrer of the index
+
+	PlanFile string // file, relative to the workspace, the upload plan is written to as JSON
+
+	Annotations map[string]string // vendor annotations added to every layer, manifest and manifest index pushed
+
+	CreatedSource string // where the creation time of manifests and the index comes from, CreatedSourceNow by default
+
+	MergeIndex bool // add the binaries to the manifest index already pushed for the version, as mode: merge does
+
+	HandoffFile string // file, relative to the workspace, the uploaded binaries are handed off in instead of pushing an index
 }
 
+// Creation time sources: the time of the upload, or the committer date of the checked out commit, which gives the
+// same digests when the same commit is uploaded again
+const (
+	CreatedSourceNow    = "now"
+	CreatedSourceCommit = "commit"
+)
+
+// IsReproducible reports whether manifests and the index are stamped with the commit rather than the run, so
+// uploading the same commit again pushes the same digests
+func (o *OCIConfig) IsReproducible() bool {
+	return o.CreatedSource == CreatedSourceCommit
+}
+
+// MinBlobSizeLimit is the smallest oci-max-blob-size accepted, so a typo doesn't split an artifact into thousands of
+// layers
+const MinBlobSizeLimit = 1 << 20
+
+// IsEnabled reports whether binaries are uploaded, to a registry, an OCI layout directory or both
 func (o *OCIConfig) IsEnabled() bool {
-	return o.Registry != ""
+	return o.Registry != "" || o.Layout != ""
 }
 
 func (o *OCIConfig) Validate() error {
@@ -102,19 +368,81 @@ func (o *OCIConfig) Validate() error {
 		return fmt.Errorf("binaries input is required when oci-registry is set")
 	}
 
+	if err := o.ValidateConnection(); err != nil {
+		return err
+	}
+
+	if o.Layout != "" && !fileutil.IsLocal(o.Layout) {
+		return fmt.Errorf("invalid oci-layout %s: must be relative to the repository root without directory traversal", o.Layout)
+	}
+
+	if o.PlanFile != "" && !fileutil.IsLocal(o.PlanFile) {
+		return fmt.Errorf("invalid upload-plan-file %s: must be relative to the repository root without directory traversal", o.PlanFile)
+	}
+
+	if o.CreatedSource != "" && o.CreatedSource != CreatedSourceNow && o.CreatedSource != CreatedSourceCommit {
+		return fmt.Errorf("invalid oci-created-source %q: must be %s or %s", o.CreatedSource, CreatedSourceNow, CreatedSourceCommit)
+	}
+
+	if o.MaxBlobSize != 0 && o.MaxBlobSize < MinBlobSizeLimit {
+		return fmt.Errorf("oci-max-blob-size must be 0 (no limit) or at least %d bytes, got %d", MinBlobSizeLimit, o.MaxBlobSize)
+	}
+
+	// Every invalid artifact is reported so the binaries input can be fixed in one pass
+	var errs validation.Errors
 	for i, artifact := range o.Artifacts {
 		if err := artifact.Validate(); err != nil {
-			return fmt.Errorf("artifact %d validation failed: %w", i, err)
+			errs.Add("", 0, fmt.Sprintf("binaries[%d]", i), err)
 		}
 	}
+	errs.Append(o.ValidateUniqueNames())
+	errs.Append(ValidateAnnotations(o.Annotations))
 
-	if err := o.ValidateUniqueNames(); err != nil {
-		return err
-	}
+	return errs.Err()
+}
 
+// ValidateConnection checks the inputs that control how the registry is reached
+func (o *OCIConfig) ValidateConnection() error {
+	if o.PlainHTTP && o.InsecureSkipTLSVerify {
+		return fmt.Errorf("oci-plain-http and oci-insecure-skip-tls-verify can't both be set: plain HTTP doesn't use TLS")
+	}
 	return nil
 }
 
+// IsReservedAnnotation reports whether key is an annotation the action sets itself, which vendor annotations can't
+// override
+func IsReservedAnnotation(key string) bool {
+	for _, reserved := range reservedAnnotations {
+		if key == reserved || (strings.HasSuffix(reserved, ".") && strings.HasPrefix(key, reserved)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateAnnotations checks vendor annotation keys use reverse domain notation and aren't reserved by the action,
+// reporting every invalid key
+func ValidateAnnotations(annotations map[string]string) error {
+	keys := make([]string, 0, len(annotations))
+	for key := range annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs validation.Errors
+	for _, key := range keys {
+		field := fmt.Sprintf("oci-annotations[%q]", key)
+		if !annotationKeyPattern.MatchString(key) {
+			errs.Addf("", 0, field, "annotation keys must use reverse domain notation, e.g. com.example.team")
+			continue
+		}
+		if IsReservedAnnotation(key) {
+			errs.Addf("", 0, field, "%s is set by the action and can't be overridden", key)
+		}
+	}
+	return errs.Err()
+}
+
 func (o *OCIConfig) ValidateUniqueNames() error {
 	seen := make(map[string]bool)
 	for _, artifact := range o.Artifacts {
@@ -127,16 +455,24 @@ func (o *OCIConfig) ValidateUniqueNames() error {
 }
 
 type ArtifactUploadResult struct {
-	Name         string
-	Path         string
-	OS           string
-	Arch         string
-	Format       string
-	Digest       string
-	Size         int64
-	Tag          string
-	Uploaded     bool
-	Error        string
-	Signed       bool
-	SigningError string
+	Name            string
+	Path            string
+	OS              string
+	Arch            string
+	Format          string
+	Digest          string
+	LayerDigest     string // Digest of the artifact file the upload pushed, whole even if it is split into layers
+	Size            int64
+	MediaType       string // Manifest media type; empty means the OCI image manifest created by the upload
+	Tag             string
+	Uploaded        bool
+	Referenced      bool   // The manifest was already in the registry and was only added to the index
+	ArtifactType    string // Artifact type of a referenced manifest in the index, e.g. of one merged from an earlier index
+	LegalFiles      []string
+	Notarization    string // Notarization status of the Mach-O binaries, if checked
+	ContentManifest string // Digest of the content manifest referrer, if attached
+	Error           string
+	ErrorCode       string // error codes and HTTP status the registry answered a failed upload with, e.g. DENIED (HTTP 403)
+	Signed          bool
+	SigningError    string
 }
@@ -3,7 +3,10 @@ package models
 import (
 	"testing"
 
+	"agent-metadata-action/internal/validation"
+
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestArtifactDefinition_Validate(t *testing.T) {
@@ -125,6 +128,296 @@ func TestArtifactDefinition_Validate(t *testing.T) {
 			expectError: true,
 			errorMsg:    "invalid format",
 		},
+		{
+			name: "valid tar+zstd artifact",
+			artifact: ArtifactDefinition{
+				Name:   "linux-amd64",
+				Path:   "./dist/agent.tar.zst",
+				OS:     "linux",
+				Arch:   "amd64",
+				Format: "tar+zstd",
+			},
+			expectError: false,
+		},
+		{
+			name: "valid s3 artifact with sha256",
+			artifact: ArtifactDefinition{
+				Name:   "linux-amd64",
+				Path:   "s3://release-bucket/builds/agent.tar.gz",
+				OS:     "linux",
+				Arch:   "amd64",
+				Format: "tar+gzip",
+				SHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			},
+			expectError: false,
+		},
+		{
+			name: "valid gcs artifact",
+			artifact: ArtifactDefinition{
+				Name:   "linux-amd64",
+				Path:   "gs://release-bucket/agent.tar.gz",
+				OS:     "linux",
+				Arch:   "amd64",
+				Format: "tar+gzip",
+			},
+			expectError: false,
+		},
+		{
+			name: "unsupported remote scheme",
+			artifact: ArtifactDefinition{
+				Name:   "linux-amd64",
+				Path:   "ftp://example.com/agent.tar.gz",
+				OS:     "linux",
+				Arch:   "amd64",
+				Format: "tar+gzip",
+			},
+			expectError: true,
+			errorMsg:    "remote paths must use",
+		},
+		{
+			name: "remote path without object key",
+			artifact: ArtifactDefinition{
+				Name:   "linux-amd64",
+				Path:   "s3://release-bucket/",
+				OS:     "linux",
+				Arch:   "amd64",
+				Format: "tar+gzip",
+			},
+			expectError: true,
+			errorMsg:    "must reference a file",
+		},
+		{
+			name: "valid https artifact",
+			artifact: ArtifactDefinition{
+				Name:   "linux-amd64",
+				Path:   "https://download.newrelic.com/agent/agent.tar.gz",
+				OS:     "linux",
+				Arch:   "amd64",
+				Format: "tar+gzip",
+				SHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			},
+			expectError: false,
+		},
+		{
+			name: "https artifact without sha256",
+			artifact: ArtifactDefinition{
+				Name:   "linux-amd64",
+				Path:   "https://download.newrelic.com/agent/agent.tar.gz",
+				OS:     "linux",
+				Arch:   "amd64",
+				Format: "tar+gzip",
+			},
+			expectError: true,
+			errorMsg:    "sha256 is required",
+		},
+		{
+			name: "plain http artifact",
+			artifact: ArtifactDefinition{
+				Name:   "linux-amd64",
+				Path:   "http://download.newrelic.com/agent/agent.tar.gz",
+				OS:     "linux",
+				Arch:   "amd64",
+				Format: "tar+gzip",
+				SHA256: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			},
+			expectError: true,
+			errorMsg:    "remote paths must use",
+		},
+		{
+			name: "windows artifact requiring authenticode",
+			artifact: ArtifactDefinition{
+				Name:                "windows-amd64",
+				Path:                "./dist/agent.zip",
+				OS:                  "windows",
+				Arch:                "amd64",
+				Format:              "zip",
+				RequireAuthenticode: true,
+			},
+			expectError: false,
+		},
+		{
+			name: "linux artifact requiring authenticode",
+			artifact: ArtifactDefinition{
+				Name:                "linux-amd64",
+				Path:                "./dist/agent.tar.gz",
+				OS:                  "linux",
+				Arch:                "amd64",
+				Format:              "tar+gzip",
+				RequireAuthenticode: true,
+			},
+			expectError: true,
+			errorMsg:    "requireAuthenticode is only supported for windows artifacts",
+		},
+		{
+			name: "windows msi package",
+			artifact: ArtifactDefinition{
+				Name:                "windows-amd64-msi",
+				Path:                "./dist/agent.msi",
+				OS:                  "windows",
+				Arch:                "amd64",
+				Format:              "msi",
+				RequireAuthenticode: true,
+			},
+			expectError: false,
+		},
+		{
+			name: "linux msi package",
+			artifact: ArtifactDefinition{
+				Name:   "linux-amd64",
+				Path:   "./dist/agent.msi",
+				OS:     "linux",
+				Arch:   "amd64",
+				Format: "msi",
+			},
+			expectError: true,
+			errorMsg:    "format msi is only supported for windows artifacts",
+		},
+		{
+			name: "windows artifact checking notarization",
+			artifact: ArtifactDefinition{
+				Name:              "windows-amd64",
+				Path:              "./dist/agent.zip",
+				OS:                "windows",
+				Arch:              "amd64",
+				Format:            "zip",
+				CheckNotarization: true,
+			},
+			expectError: true,
+			errorMsg:    "checkNotarization is only supported for darwin artifacts",
+		},
+		{
+			name: "jar artifact with version attribute",
+			artifact: ArtifactDefinition{
+				Name:             "java-agent",
+				Path:             "./newrelic.jar",
+				OS:               "any",
+				Arch:             "any",
+				Format:           "jar",
+				VersionAttribute: "Agent-Version",
+			},
+			expectError: false,
+		},
+		{
+			name: "zip artifact with version attribute",
+			artifact: ArtifactDefinition{
+				Name:             "java-agent",
+				Path:             "./newrelic.zip",
+				OS:               "any",
+				Arch:             "any",
+				Format:           "zip",
+				VersionAttribute: "Agent-Version",
+			},
+			expectError: true,
+			errorMsg:    "versionAttribute is only supported for jar artifacts",
+		},
+		{
+			name: "valid pre-pushed manifest reference",
+			artifact: ArtifactDefinition{
+				Name:   "image-amd64",
+				Digest: "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+				OS:     "linux",
+				Arch:   "amd64",
+			},
+			expectError: false,
+		},
+		{
+			name: "reference with path",
+			artifact: ArtifactDefinition{
+				Name:   "image-amd64",
+				Path:   "./dist/agent.tar.gz",
+				Digest: "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+				OS:     "linux",
+				Arch:   "amd64",
+			},
+			expectError: true,
+			errorMsg:    "either path or digest",
+		},
+		{
+			name: "invalid reference digest",
+			artifact: ArtifactDefinition{
+				Name:   "image-amd64",
+				Digest: "sha256:abc",
+				OS:     "linux",
+				Arch:   "amd64",
+			},
+			expectError: true,
+			errorMsg:    "invalid digest",
+		},
+		{
+			name: "valid container image",
+			artifact: ArtifactDefinition{
+				Name:  "container",
+				Kind:  "image",
+				Image: "docker.io/newrelic/infrastructure@sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+				OS:    "linux",
+				Arch:  "any",
+			},
+			expectError: false,
+		},
+		{
+			name: "image without digest",
+			artifact: ArtifactDefinition{
+				Name:  "container",
+				Kind:  "image",
+				Image: "docker.io/newrelic/infrastructure:1.2.3",
+				OS:    "linux",
+				Arch:  "any",
+			},
+			expectError: true,
+			errorMsg:    "invalid image",
+		},
+		{
+			name: "image with path",
+			artifact: ArtifactDefinition{
+				Name:  "container",
+				Kind:  "image",
+				Path:  "./dist/agent.tar.gz",
+				Image: "docker.io/newrelic/infrastructure@sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+				OS:    "linux",
+				Arch:  "any",
+			},
+			expectError: true,
+			errorMsg:    "not path or digest",
+		},
+		{
+			name: "image on a file artifact",
+			artifact: ArtifactDefinition{
+				Name:   "linux-amd64",
+				Path:   "./dist/agent.tar.gz",
+				Image:  "docker.io/newrelic/infrastructure@sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+				OS:     "linux",
+				Arch:   "amd64",
+				Format: "tar+gzip",
+			},
+			expectError: true,
+			errorMsg:    "image is only supported for artifacts of kind 'image'",
+		},
+		{
+			name: "invalid kind",
+			artifact: ArtifactDefinition{
+				Name:   "linux-amd64",
+				Kind:   "chart",
+				Path:   "./dist/agent.tar.gz",
+				OS:     "linux",
+				Arch:   "amd64",
+				Format: "tar+gzip",
+			},
+			expectError: true,
+			errorMsg:    "invalid kind 'chart'",
+		},
+		{
+			name: "invalid sha256",
+			artifact: ArtifactDefinition{
+				Name:   "linux-amd64",
+				Path:   "s3://release-bucket/agent.tar.gz",
+				OS:     "linux",
+				Arch:   "amd64",
+				Format: "tar+gzip",
+				SHA256: "abc123",
+			},
+			expectError: true,
+			errorMsg:    "invalid sha256",
+		},
 	}
 
 	for _, tt := range tests {
@@ -192,6 +485,7 @@ func TestArtifactDefinition_GetMediaType(t *testing.T) {
 	}{
 		{"tar", "application/vnd.newrelic.agent.content.v1.tar"},
 		{"tar+gzip", "application/vnd.newrelic.agent.content.v1.tar+gzip"},
+		{"tar+zstd", "application/vnd.newrelic.agent.content.v1.tar+zstd"},
 		{"zip", "application/vnd.newrelic.agent.content.v1.zip"},
 	}
 
@@ -235,6 +529,8 @@ func TestArtifactDefinition_GetFilename(t *testing.T) {
 		{"./dist/agent.tar.gz", "agent.tar.gz"},
 		{"/absolute/path/to/file.zip", "file.zip"},
 		{"simple.tar.gz", "simple.tar.gz"},
+		{"s3://bucket/builds/agent.tar.gz", "agent.tar.gz"},
+		{"https://download.newrelic.com/agent/agent.zip", "agent.zip"},
 	}
 
 	for _, tt := range tests {
@@ -244,3 +540,142 @@ func TestArtifactDefinition_GetFilename(t *testing.T) {
 		})
 	}
 }
+
+func TestOCIConfig_Validate_ReportsEveryArtifact(t *testing.T) {
+	config := OCIConfig{
+		Registry: "docker.io/newrelic/agents",
+		Artifacts: []ArtifactDefinition{
+			{Name: "linux", Path: "./dist/linux.tar.gz"},
+			{Name: "bad name", Path: "./dist/windows.zip", OS: "windows"},
+			{Name: "linux", Path: "./dist/other.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip"},
+		},
+	}
+
+	// method under test
+	err := config.Validate()
+
+	var errs validation.Errors
+	require.ErrorAs(t, err, &errs)
+	require.Len(t, errs, 3)
+	assert.Contains(t, errs[0].Error(), "binaries[0]: os is required for artifact 'linux'")
+	assert.Contains(t, errs[1].Error(), "binaries[1]: invalid artifact name 'bad name'")
+	assert.Contains(t, errs[2].Error(), "duplicate artifact name: 'linux'")
+}
+
+func TestOCIConfig_Validate_MaxBlobSize(t *testing.T) {
+	config := OCIConfig{
+		Registry:  "docker.io/newrelic/agents",
+		Artifacts: []ArtifactDefinition{{Name: "linux", Path: "./dist/linux.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip"}},
+	}
+
+	for _, size := range []int64{0, MinBlobSizeLimit, 5 << 30} {
+		config.MaxBlobSize = size
+		assert.NoError(t, config.Validate(), size)
+	}
+
+	config.MaxBlobSize = 1024
+
+	// method under test
+	err := config.Validate()
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "oci-max-blob-size must be 0 (no limit) or at least 1048576 bytes, got 1024")
+}
+
+func TestOCIConfig_Validate_PlanFile(t *testing.T) {
+	config := OCIConfig{
+		Registry:  "docker.io/newrelic/agents",
+		Artifacts: []ArtifactDefinition{{Name: "linux", Path: "./dist/linux.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip"}},
+		PlanFile:  "out/plan.json",
+	}
+	require.NoError(t, config.Validate())
+
+	for _, planFile := range []string{"../plan.json", "/tmp/plan.json"} {
+		config.PlanFile = planFile
+
+		// method under test
+		err := config.Validate()
+
+		require.Error(t, err, planFile)
+		assert.Contains(t, err.Error(), "invalid upload-plan-file "+planFile)
+	}
+}
+
+func TestOCIConfig_Validate_Layout(t *testing.T) {
+	config := OCIConfig{
+		Artifacts: []ArtifactDefinition{{Name: "linux", Path: "./dist/linux.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip"}},
+		Layout:    "out/oci-layout",
+	}
+	assert.True(t, config.IsEnabled(), "An OCI layout alone enables the upload")
+	require.NoError(t, config.Validate())
+
+	for _, layout := range []string{"../oci-layout", "/tmp/oci-layout"} {
+		config.Layout = layout
+
+		// method under test
+		err := config.Validate()
+
+		require.Error(t, err, layout)
+		assert.Contains(t, err.Error(), "invalid oci-layout "+layout)
+	}
+
+	config.Layout = ""
+	config.Artifacts = nil
+	assert.False(t, config.IsEnabled())
+}
+
+func TestValidateAnnotations(t *testing.T) {
+	require.NoError(t, ValidateAnnotations(nil))
+	require.NoError(t, ValidateAnnotations(map[string]string{
+		"org.opencontainers.image.source":   "https://github.com/newrelic/newrelic-java-agent",
+		"org.opencontainers.image.licenses": "Apache-2.0",
+		"com.newrelic.team":                 "apm-java",
+		"com.example.buildUrl":              "https://ci.example.com/builds/42",
+	}))
+
+	// method under test
+	err := ValidateAnnotations(map[string]string{
+		"team":                             "apm-java",
+		"Com.Example.team":                 "apm-java",
+		"com.example..team":                "apm-java",
+		"org.opencontainers.image.version": "9.9.9",
+		"com.newrelic.retention.class":     "release",
+		"com.newrelic.build.sha":           "abc",
+	})
+
+	require.Error(t, err)
+	assert.Equal(t, `6 problems found:
+  - oci-annotations["Com.Example.team"]: annotation keys must use reverse domain notation, e.g. com.example.team
+  - oci-annotations["com.example..team"]: annotation keys must use reverse domain notation, e.g. com.example.team
+  - oci-annotations["com.newrelic.build.sha"]: com.newrelic.build.sha is set by the action and can't be overridden
+  - oci-annotations["com.newrelic.retention.class"]: com.newrelic.retention.class is set by the action and can't be overridden
+  - oci-annotations["org.opencontainers.image.version"]: org.opencontainers.image.version is set by the action and can't be overridden
+  - oci-annotations["team"]: annotation keys must use reverse domain notation, e.g. com.example.team`, err.Error())
+}
+
+func TestOCIConfig_Validate_CreatedSource(t *testing.T) {
+	config := OCIConfig{
+		Registry:  "docker.io/newrelic/agents",
+		Artifacts: []ArtifactDefinition{{Name: "linux", Path: "./dist/linux.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip"}},
+	}
+	for _, source := range []string{"", CreatedSourceNow, CreatedSourceCommit} {
+		config.CreatedSource = source
+		require.NoError(t, config.Validate(), source)
+	}
+	assert.True(t, config.IsReproducible())
+
+	config.CreatedSource = "tag"
+
+	// method under test
+	err := config.Validate()
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid oci-created-source "tag": must be now or commit`)
+}
+
+func TestIsReservedAnnotation(t *testing.T) {
+	assert.True(t, IsReservedAnnotation("org.opencontainers.image.created"))
+	assert.True(t, IsReservedAnnotation("com.newrelic.artifact.type"))
+	assert.False(t, IsReservedAnnotation("org.opencontainers.image.source"))
+	assert.False(t, IsReservedAnnotation("com.newrelic.team"))
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+
+	"agent-metadata-action/internal/canonical"
+)
+
+// Canonical returns a copy of the metadata with its definitions in a canonical order
+// The service treats definitions as sets, so the order they were listed in the config files doesn't change their
+// meaning: configuration definitions are ordered by platform, type and version, agent control definitions by platform
+// and supported versions, and any ties by their canonical JSON
+func (m *AgentMetadata) Canonical() (*AgentMetadata, error) {
+	sorted := *m
+	var err error
+	if sorted.ConfigurationDefinitions, err = sortDefinitions(m.ConfigurationDefinitions, ConfigurationDefinitionKey...); err != nil {
+		return nil, fmt.Errorf("failed to order configuration definitions: %w", err)
+	}
+	if sorted.AgentControlDefinitions, err = sortDefinitions(m.AgentControlDefinitions, AgentControlDefinitionKey...); err != nil {
+		return nil, fmt.Errorf("failed to order agent control definitions: %w", err)
+	}
+	return &sorted, nil
+}
+
+// sortDefinitions returns a sorted copy of definitions, compared field by field and then by canonical JSON
+// A nil slice stays nil so the payload encodes the same way
+func sortDefinitions[T ~map[string]interface{}](definitions []T, fields ...string) ([]T, error) {
+	if definitions == nil {
+		return nil, nil
+	}
+	type keyed struct {
+		definition T
+		fields     []string
+		encoded    string
+	}
+	items := make([]keyed, len(definitions))
+	for i, definition := range definitions {
+		encoded, err := canonical.Marshal(definition)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = keyed{definition: definition, encoded: string(encoded)}
+		for _, field := range fields {
+			value := ""
+			if v, ok := definition[field]; ok && v != nil {
+				value = fmt.Sprint(v)
+			}
+			items[i].fields = append(items[i].fields, value)
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		for k := range fields {
+			if items[i].fields[k] != items[j].fields[k] {
+				return items[i].fields[k] < items[j].fields[k]
+			}
+		}
+		return items[i].encoded < items[j].encoded
+	})
+
+	sorted := make([]T, len(items))
+	for i, item := range items {
+		sorted[i] = item.definition
+	}
+	return sorted, nil
+}
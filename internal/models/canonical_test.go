@@ -0,0 +1,66 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentMetadata_Canonical(t *testing.T) {
+	metadata := &AgentMetadata{
+		ConfigurationDefinitions: []ConfigurationDefinition{
+			{"platform": "LINUX", "type": "agent-config", "version": "1.0.0"},
+			{"platform": "ALL", "type": "agent-config", "version": "2.0.0", "schema": "b"},
+			{"platform": "ALL", "type": "agent-config", "version": "2.0.0", "schema": "a"},
+			{"platform": "ALL", "type": "agent-config", "version": "1.0.0"},
+		},
+		Metadata: Metadata{"version": "1.2.3", "features": []interface{}{"z", "a"}},
+		AgentControlDefinitions: []AgentControlDefinition{
+			{"platform": "KUBERNETES"},
+			{"platform": "HOST", "supportFromAgent": "1.0.0"},
+		},
+	}
+
+	// method under test
+	sorted, err := metadata.Canonical()
+
+	require.NoError(t, err)
+	assert.Equal(t, []ConfigurationDefinition{
+		{"platform": "ALL", "type": "agent-config", "version": "1.0.0"},
+		{"platform": "ALL", "type": "agent-config", "version": "2.0.0", "schema": "a"},
+		{"platform": "ALL", "type": "agent-config", "version": "2.0.0", "schema": "b"},
+		{"platform": "LINUX", "type": "agent-config", "version": "1.0.0"},
+	}, sorted.ConfigurationDefinitions)
+	assert.Equal(t, "HOST", sorted.AgentControlDefinitions[0]["platform"])
+	// Arrays inside metadata keep their order, and the original is untouched
+	assert.Equal(t, []interface{}{"z", "a"}, sorted.Metadata["features"])
+	assert.Equal(t, "LINUX", metadata.ConfigurationDefinitions[0]["platform"])
+}
+
+func TestEncodePayload_Reproducible(t *testing.T) {
+	first := &AgentMetadata{
+		ConfigurationDefinitions: []ConfigurationDefinition{
+			{"platform": "LINUX", "type": "agent-config", "version": "1.0.0"},
+			{"platform": "ALL", "type": "agent-config", "version": "1.0.0"},
+		},
+		Metadata: Metadata{"version": "1.2.3", "displayName": "Java <agent>", "tags": map[string]interface{}{"b": "2", "a": "1"}},
+	}
+	second := &AgentMetadata{
+		ConfigurationDefinitions: []ConfigurationDefinition{first.ConfigurationDefinitions[1], first.ConfigurationDefinitions[0]},
+		Metadata:                 Metadata{"tags": map[string]interface{}{"a": "1", "b": "2"}, "displayName": "Java <agent>", "version": "1.2.3"},
+	}
+
+	for _, version := range PayloadVersions {
+		t.Run(version, func(t *testing.T) {
+			// method under test
+			a, err := EncodePayload(version, first, EmptyOmit, nil)
+			require.NoError(t, err)
+			b, err := EncodePayload(version, second, EmptyOmit, nil)
+			require.NoError(t, err)
+
+			assert.Equal(t, string(a), string(b))
+			assert.Contains(t, string(a), `"displayName":"Java <agent>"`)
+		})
+	}
+}
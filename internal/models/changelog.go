@@ -0,0 +1,56 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"agent-metadata-action/internal/validation"
+)
+
+// ChangelogField lists what changed in a configuration definition in this version, for Fleet Control to show
+// It may be written as one entry or a list of them, and is sent as a list
+const ChangelogField = "changelog"
+
+// Changelog returns the changelog entries of a configuration definition, or nil if it has none or they are invalid
+func Changelog(definition ConfigurationDefinition) []string {
+	switch value := definition[ChangelogField].(type) {
+	case string:
+		if strings.TrimSpace(value) == "" {
+			return nil
+		}
+		return []string{strings.TrimSpace(value)}
+	case []string:
+		return value
+	case []interface{}:
+		if len(value) == 0 {
+			return nil
+		}
+		entries := make([]string, 0, len(value))
+		for _, entry := range value {
+			text, ok := entry.(string)
+			if !ok || strings.TrimSpace(text) == "" {
+				return nil
+			}
+			entries = append(entries, strings.TrimSpace(text))
+		}
+		return entries
+	}
+	return nil
+}
+
+// ValidateChangelogs checks that the changelog of every configuration definition that has one is a non-empty entry
+// or a list of them
+// Failures are returned as validation.Errors with fields such as configurationDefinitions[0].changelog
+func ValidateChangelogs(definitions []ConfigurationDefinition) error {
+	var errs validation.Errors
+	for i, definition := range definitions {
+		value, ok := definition[ChangelogField]
+		if !ok {
+			continue
+		}
+		if len(Changelog(definition)) == 0 {
+			errs.Addf("", 0, fmt.Sprintf("configurationDefinitions[%d].%s", i, ChangelogField), "must be a non-empty entry or a list of them, got %v", value)
+		}
+	}
+	return errs.Err()
+}
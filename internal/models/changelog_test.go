@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangelog(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition ConfigurationDefinition
+		expected   []string
+	}{
+		{name: "none", definition: ConfigurationDefinition{"type": "agent-config"}},
+		{name: "one entry", definition: ConfigurationDefinition{ChangelogField: " Added proxy settings \n"}, expected: []string{"Added proxy settings"}},
+		{name: "list", definition: ConfigurationDefinition{ChangelogField: []interface{}{"Added proxy settings", "Removed log_file"}}, expected: []string{"Added proxy settings", "Removed log_file"}},
+		{name: "invalid entry", definition: ConfigurationDefinition{ChangelogField: []interface{}{"Added proxy settings", 42}}},
+		{name: "empty list", definition: ConfigurationDefinition{ChangelogField: []interface{}{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			assert.Equal(t, tt.expected, Changelog(tt.definition))
+		})
+	}
+}
+
+func TestValidateChangelogs(t *testing.T) {
+	definitions := []ConfigurationDefinition{
+		{"type": "agent-config", ChangelogField: []interface{}{"Added proxy settings"}},
+		{"type": "logging"},
+		{"type": "legacy-config", ChangelogField: ""},
+		{"type": "old-logging", ChangelogField: []interface{}{"Removed log_file", false}},
+	}
+
+	// method under test
+	err := ValidateChangelogs(definitions)
+
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "configurationDefinitions[0]")
+	assert.Contains(t, err.Error(), "configurationDefinitions[2].changelog: must be a non-empty entry or a list of them")
+	assert.Contains(t, err.Error(), "configurationDefinitions[3].changelog: must be a non-empty entry or a list of them")
+}
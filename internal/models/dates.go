@@ -0,0 +1,48 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// DateLayout is the ISO 8601 calendar date format of date metadata fields such as eol and releaseDate
+const DateLayout = "2006-01-02"
+
+// ParseDate parses a date metadata value
+// Unquoted YAML dates are decoded as time.Time and are accepted as they are
+func ParseDate(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		parsed, err := time.Parse(DateLayout, v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q is not a valid date: must be YYYY-MM-DD", v)
+		}
+		return parsed, nil
+	}
+	return time.Time{}, fmt.Errorf("%v is not a valid date: must be YYYY-MM-DD", value)
+}
+
+// ValidateEOL checks the eol of release note metadata is a valid date that isn't before the releaseDate
+// Metadata without an eol is valid; an invalid releaseDate is left for the service to report
+func ValidateEOL(m Metadata) error {
+	if !m.IsSet("eol") {
+		return nil
+	}
+	eol, err := ParseDate(m["eol"])
+	if err != nil {
+		return fmt.Errorf("invalid eol: %w", err)
+	}
+	if !m.IsSet("releaseDate") {
+		return nil
+	}
+	released, err := ParseDate(m["releaseDate"])
+	if err != nil {
+		return nil
+	}
+	if eol.Before(released) {
+		return fmt.Errorf("eol %s is before the releaseDate %s", eol.Format(DateLayout), released.Format(DateLayout))
+	}
+	return nil
+}
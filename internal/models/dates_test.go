@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDate(t *testing.T) {
+	parsed, err := ParseDate("2025-12-31")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC), parsed)
+
+	// Unquoted YAML dates are already decoded
+	parsed, err = ParseDate(time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, "2026-01-15", parsed.Format(DateLayout))
+
+	_, err = ParseDate("2024-13-45")
+	assert.EqualError(t, err, `"2024-13-45" is not a valid date: must be YYYY-MM-DD`)
+	_, err = ParseDate("12/31/2025")
+	assert.Error(t, err)
+	_, err = ParseDate(2025)
+	assert.EqualError(t, err, "2025 is not a valid date: must be YYYY-MM-DD")
+}
+
+func TestValidateEOL(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata Metadata
+		wantErr  string
+	}{
+		{name: "no eol", metadata: Metadata{"version": "1.2.3"}},
+		{name: "empty eol", metadata: Metadata{"eol": ""}},
+		{name: "valid eol", metadata: Metadata{"eol": "2026-12-31", "releaseDate": "2025-01-15"}},
+		{name: "eol on the release date", metadata: Metadata{"eol": "2025-01-15", "releaseDate": "2025-01-15"}},
+		{name: "eol without a release date", metadata: Metadata{"eol": "2020-01-01"}},
+		{name: "invalid release date is left to the service", metadata: Metadata{"eol": "2026-12-31", "releaseDate": "soon"}},
+		{name: "invalid eol", metadata: Metadata{"eol": "2024-13-45"}, wantErr: `invalid eol: "2024-13-45" is not a valid date: must be YYYY-MM-DD`},
+		{name: "eol before the release date", metadata: Metadata{"eol": "2024-12-31", "releaseDate": time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)}, wantErr: "eol 2024-12-31 is before the releaseDate 2025-01-15"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			err := ValidateEOL(tt.metadata)
+
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"fmt"
+
+	"agent-metadata-action/internal/validation"
+)
+
+// Fields that retire a configuration definition type: deprecated: true marks it as deprecated, and supersededBy
+// optionally names the type that replaces it
+const (
+	DeprecatedField   = "deprecated"
+	SupersededByField = "supersededBy"
+)
+
+// IsDeprecated reports whether a configuration definition is marked as deprecated
+func IsDeprecated(definition ConfigurationDefinition) bool {
+	deprecated, _ := definition[DeprecatedField].(bool)
+	return deprecated
+}
+
+// ValidateDeprecations checks the deprecation fields of configuration definitions: deprecated must be a boolean,
+// and supersededBy is only allowed on deprecated definitions and must name the type of another definition that
+// isn't deprecated itself
+// Failures are returned as validation.Errors with fields such as configurationDefinitions[0].supersededBy
+func ValidateDeprecations(definitions []ConfigurationDefinition) error {
+	current := map[interface{}]bool{}
+	for _, definition := range definitions {
+		if !IsDeprecated(definition) {
+			current[definition["type"]] = true
+		}
+	}
+
+	var errs validation.Errors
+	for i, definition := range definitions {
+		if value, ok := definition[DeprecatedField]; ok {
+			if _, isBool := value.(bool); !isBool {
+				errs.Addf("", 0, fmt.Sprintf("configurationDefinitions[%d].%s", i, DeprecatedField), "must be true or false, got %v", value)
+			}
+		}
+
+		value, ok := definition[SupersededByField]
+		if !ok {
+			continue
+		}
+		field := fmt.Sprintf("configurationDefinitions[%d].%s", i, SupersededByField)
+		supersededBy, isString := value.(string)
+		switch {
+		case !isString || supersededBy == "":
+			errs.Addf("", 0, field, "must be the type of the definition that replaces this one, got %v", value)
+		case !IsDeprecated(definition):
+			errs.Addf("", 0, field, "is only allowed on definitions with %s: true", DeprecatedField)
+		case supersededBy == definition["type"]:
+			errs.Addf("", 0, field, "type %s cannot supersede itself", supersededBy)
+		case !current[supersededBy]:
+			errs.Addf("", 0, field, "type %s is not the type of a configuration definition that isn't deprecated", supersededBy)
+		}
+	}
+	return errs.Err()
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDeprecations(t *testing.T) {
+	tests := []struct {
+		name        string
+		definitions []ConfigurationDefinition
+		errors      []string
+	}{
+		{name: "no deprecations", definitions: []ConfigurationDefinition{{"type": "agent-config"}, {"type": "logging", DeprecatedField: false}}},
+		{
+			name: "deprecated and superseded",
+			definitions: []ConfigurationDefinition{
+				{"type": "agent-config"},
+				{"type": "legacy-config", DeprecatedField: true, SupersededByField: "agent-config"},
+				{"type": "old-logging", DeprecatedField: true},
+			},
+		},
+		{
+			name: "every problem is reported",
+			definitions: []ConfigurationDefinition{
+				{"type": "agent-config", DeprecatedField: "yes"},
+				{"type": "legacy-config", SupersededByField: "agent-config"},
+				{"type": "old-logging", DeprecatedField: true, SupersededByField: "old-logging"},
+				{"type": "older-logging", DeprecatedField: true, SupersededByField: "old-logging"},
+				{"type": "ancient-logging", DeprecatedField: true, SupersededByField: 42},
+			},
+			errors: []string{
+				"configurationDefinitions[0].deprecated: must be true or false, got yes",
+				"configurationDefinitions[1].supersededBy: is only allowed on definitions with deprecated: true",
+				"configurationDefinitions[2].supersededBy: type old-logging cannot supersede itself",
+				"configurationDefinitions[3].supersededBy: type old-logging is not the type of a configuration definition that isn't deprecated",
+				"configurationDefinitions[4].supersededBy: must be the type of the definition that replaces this one, got 42",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			err := ValidateDeprecations(tt.definitions)
+
+			if len(tt.errors) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, expected := range tt.errors {
+				assert.Contains(t, err.Error(), expected)
+			}
+		})
+	}
+}
+
+func TestIsDeprecated(t *testing.T) {
+	assert.True(t, IsDeprecated(ConfigurationDefinition{DeprecatedField: true}))
+	assert.False(t, IsDeprecated(ConfigurationDefinition{DeprecatedField: "true"}))
+	assert.False(t, IsDeprecated(ConfigurationDefinition{}))
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// EmptyFieldPolicy decides how empty optional fields are sent, since the service may take an empty value
+// as "clear what was published before"
+type EmptyFieldPolicy string
+
+const (
+	// EmptyAsNull sends empty fields and definition lists as null
+	EmptyAsNull EmptyFieldPolicy = "send-empty-as-null"
+	// EmptyOmit leaves empty fields and definition lists out of the payload, keeping the service's values
+	EmptyOmit EmptyFieldPolicy = "omit"
+	// EmptyAsArray sends empty lists and missing definition lists as [] and leaves other empty fields out
+	EmptyAsArray EmptyFieldPolicy = "empty-array"
+)
+
+// EmptyFieldPolicies lists the supported policies
+var EmptyFieldPolicies = []EmptyFieldPolicy{EmptyAsNull, EmptyOmit, EmptyAsArray}
+
+// ParseEmptyFieldPolicy returns the policy named by value; an empty value is EmptyOmit
+func ParseEmptyFieldPolicy(value string) (EmptyFieldPolicy, error) {
+	if value == "" {
+		return EmptyOmit, nil
+	}
+	for _, policy := range EmptyFieldPolicies {
+		if EmptyFieldPolicy(value) == policy {
+			return policy, nil
+		}
+	}
+	names := make([]string, len(EmptyFieldPolicies))
+	for i, policy := range EmptyFieldPolicies {
+		names[i] = string(policy)
+	}
+	return "", fmt.Errorf("invalid empty field policy %q: must be one of %s", value, strings.Join(names, ", "))
+}
+
+// Metadata returns a copy of metadata with the policy applied to its empty fields
+// version is required and always kept as is, so a missing version is still reported
+func (p EmptyFieldPolicy) Metadata(metadata Metadata) Metadata {
+	if metadata == nil {
+		return nil
+	}
+	applied := make(Metadata, len(metadata))
+	for key, value := range metadata {
+		if key == "version" || !isEmpty(value) {
+			applied[key] = value
+			continue
+		}
+		switch p {
+		case EmptyAsNull:
+			applied[key] = nil
+		case EmptyAsArray:
+			if isList(value) {
+				applied[key] = []interface{}{}
+			}
+		}
+		// EmptyOmit (and EmptyAsArray for non-lists) drops the field
+	}
+	return applied
+}
+
+// list returns how a definition list is encoded: nil leaves it out (with omitempty), a pointer to a nil slice is
+// null and a pointer to an empty slice is []
+func list[T any](p EmptyFieldPolicy, items []T) *[]T {
+	if len(items) > 0 {
+		return &items
+	}
+	switch p {
+	case EmptyAsNull:
+		return new([]T)
+	case EmptyAsArray:
+		return &[]T{}
+	}
+	return nil
+}
+
+// IsSet reports whether a metadata field has a non-empty value
+func (m Metadata) IsSet(field string) bool {
+	return !isEmpty(m[field])
+}
+
+// isEmpty reports whether a metadata value is nil, an empty string, list or map
+func isEmpty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// isList reports whether a metadata value is a list
+func isList(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	kind := reflect.ValueOf(value).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"strings"
+)
+
+// Payload features a service can advertise in its capabilities alongside its payload versions
+// Fields that need a feature are only sent to services that advertise it, so they can be rolled out incrementally
+// without breaking older service deployments
+const (
+	// FeatureLocalizedDescriptions sends description_i18n as localizedDescriptions, in payload v3
+	FeatureLocalizedDescriptions = "localizedDescriptions"
+	// FeatureAgentControlExtensions sends the x- extension fields of agent control definitions
+	FeatureAgentControlExtensions = "agentControlExtensions"
+	// FeatureCompressedSchemas sends configuration definition schemas gzip-compressed, marked with schemaEncoding
+	FeatureCompressedSchemas = "compressedSchemas"
+)
+
+// PayloadFeatures lists the payload features this build can use
+var PayloadFeatures = []string{FeatureLocalizedDescriptions, FeatureAgentControlExtensions, FeatureCompressedSchemas}
+
+// Features is the set of payload features a service supports
+type Features []string
+
+// Has reports whether feature is supported
+func (f Features) Has(feature string) bool {
+	for _, supported := range f {
+		if supported == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaEncodingField marks a configuration definition whose schema is compressed, with the compression used
+const schemaEncodingField = "schemaEncoding"
+
+// GatedFields returns the features the metadata has fields for that would be left out without them
+// Compressed schemas only shrink the payload, so they are never reported
+func (m *AgentMetadata) GatedFields() []string {
+	var features []string
+	if m.HasLocalizedDescriptions() {
+		features = append(features, FeatureLocalizedDescriptions)
+	}
+	for _, definition := range m.AgentControlDefinitions {
+		if hasExtensionFields(definition) {
+			features = append(features, FeatureAgentControlExtensions)
+			break
+		}
+	}
+	return features
+}
+
+// PayloadConfigurationDefinitions returns configuration definitions as sent in a payload version to a service with
+// features, without changing them: translations are moved or left out (see localize) and schemas are compressed
+// when the service supports it and it makes them smaller
+func PayloadConfigurationDefinitions(version string, features Features, definitions []ConfigurationDefinition) []ConfigurationDefinition {
+	definitions = localizeAll(version, features, definitions)
+	if !features.Has(FeatureCompressedSchemas) {
+		return definitions
+	}
+	compressed := make([]ConfigurationDefinition, len(definitions))
+	for i, definition := range definitions {
+		compressed[i] = compressSchema(definition)
+	}
+	return compressed
+}
+
+// RestoreConfigurationDefinitions reverses PayloadConfigurationDefinitions for decoded definitions, changing them
+// in place
+func RestoreConfigurationDefinitions(definitions []ConfigurationDefinition) {
+	for _, definition := range definitions {
+		delocalize(definition)
+		decompressSchema(definition)
+	}
+}
+
+// compressSchema returns a copy of definition with its schema gzip-compressed, or definition itself if it has no
+// schema or compression doesn't make it smaller
+func compressSchema(definition ConfigurationDefinition) ConfigurationDefinition {
+	encoded, ok := definition["schema"].(string)
+	if !ok || encoded == "" {
+		return definition
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return definition
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(decoded); err != nil || gz.Close() != nil {
+		return definition
+	}
+	recoded := base64.StdEncoding.EncodeToString(buf.Bytes())
+	if len(recoded) >= len(encoded) {
+		return definition
+	}
+	compressed := make(ConfigurationDefinition, len(definition)+1)
+	for key, value := range definition {
+		compressed[key] = value
+	}
+	compressed["schema"] = recoded
+	compressed[schemaEncodingField] = "gzip"
+	return compressed
+}
+
+// decompressSchema reverses compressSchema for a decoded payload, changing definition in place
+func decompressSchema(definition ConfigurationDefinition) {
+	if definition[schemaEncodingField] != "gzip" {
+		return
+	}
+	encoded, _ := definition["schema"].(string)
+	compressed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return
+	}
+	decoded, err := io.ReadAll(io.LimitReader(gz, MaxContentSize+1))
+	if err != nil {
+		return
+	}
+	definition["schema"] = base64.StdEncoding.EncodeToString(decoded)
+	delete(definition, schemaEncodingField)
+}
+
+// payloadAgentControlDefinitions returns agent control definitions as sent to a service with features, leaving out
+// their x- extension fields unless it supports them, without changing them
+func payloadAgentControlDefinitions(features Features, definitions []AgentControlDefinition) []AgentControlDefinition {
+	if definitions == nil || features.Has(FeatureAgentControlExtensions) {
+		return definitions
+	}
+	stripped := make([]AgentControlDefinition, len(definitions))
+	for i, definition := range definitions {
+		if !hasExtensionFields(definition) {
+			stripped[i] = definition
+			continue
+		}
+		stripped[i] = make(AgentControlDefinition, len(definition))
+		for key, value := range definition {
+			if !strings.HasPrefix(key, ExtensionPrefix) {
+				stripped[i][key] = value
+			}
+		}
+	}
+	return stripped
+}
+
+// ExtensionPrefix marks custom definition fields, which the service doesn't validate
+const ExtensionPrefix = "x-"
+
+func hasExtensionFields[T ~map[string]interface{}](definition T) bool {
+	for key := range definition {
+		if strings.HasPrefix(key, ExtensionPrefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadConfigurationDefinitions_CompressedSchemas(t *testing.T) {
+	large := base64.StdEncoding.EncodeToString([]byte(`{"properties": {` + strings.Repeat(`"setting": {"type": "string"}, `, 200) + `}}`))
+	small := base64.StdEncoding.EncodeToString([]byte(`{}`))
+	definitions := []ConfigurationDefinition{
+		{"platform": "ALL", "schema": large},
+		{"platform": "HOST", "schema": small},
+		{"platform": "KUBERNETESCLUSTER"},
+	}
+
+	// method under test
+	payload := PayloadConfigurationDefinitions(PayloadV2, Features{FeatureCompressedSchemas}, definitions)
+
+	require.Len(t, payload, 3)
+	assert.Equal(t, "gzip", payload[0]["schemaEncoding"])
+	assert.Less(t, len(payload[0]["schema"].(string)), len(large))
+	assert.Equal(t, definitions[1], payload[1], "schemas that compression doesn't shrink are sent as is")
+	assert.Equal(t, definitions[2], payload[2])
+	assert.Equal(t, large, definitions[0]["schema"], "the definitions themselves are left unchanged")
+
+	assert.Equal(t, definitions, PayloadConfigurationDefinitions(PayloadV2, nil, definitions), "without the feature schemas are sent as is")
+
+	data, err := EncodePayload(PayloadV1, &AgentMetadata{ConfigurationDefinitions: definitions, Metadata: Metadata{"version": "1.2.3"}}, EmptyOmit, Features{FeatureCompressedSchemas})
+	require.NoError(t, err)
+	decoded, err := DecodePayload(PayloadV1, data)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, definitions, decoded.ConfigurationDefinitions)
+}
+
+func TestEncodePayload_AgentControlExtensions(t *testing.T) {
+	metadata := &AgentMetadata{
+		Metadata:                Metadata{"version": "1.2.3"},
+		AgentControlDefinitions: []AgentControlDefinition{{"platform": "KUBERNETES", "x-chart": "agent-control"}},
+	}
+	assert.Equal(t, []string{FeatureAgentControlExtensions}, metadata.GatedFields())
+
+	// method under test
+	without, err := EncodePayload(PayloadV1, metadata, EmptyOmit, nil)
+	require.NoError(t, err)
+	with, err := EncodePayload(PayloadV1, metadata, EmptyOmit, Features{FeatureAgentControlExtensions})
+	require.NoError(t, err)
+
+	assert.Equal(t, `{"agentControlDefinitions":[{"platform":"KUBERNETES"}],"metadata":{"version":"1.2.3"}}`, string(without))
+	assert.Equal(t, `{"agentControlDefinitions":[{"platform":"KUBERNETES","x-chart":"agent-control"}],"metadata":{"version":"1.2.3"}}`, string(with))
+	assert.Contains(t, metadata.AgentControlDefinitions[0], "x-chart")
+}
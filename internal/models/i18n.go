@@ -0,0 +1,115 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"agent-metadata-action/internal/validation"
+)
+
+// LocalizedDescriptionField holds translations of the description of a configuration definition or the metadata,
+// keyed by locale,
+// such as description_i18n: {de: ..., ja: ...}
+const LocalizedDescriptionField = "description_i18n"
+
+// localizedDescriptionsV3 is the field translations are sent in from payload v3
+const localizedDescriptionsV3 = "localizedDescriptions"
+
+// SupportedLocales lists the locales descriptions can be translated into
+var SupportedLocales = []string{"de", "es", "fr", "ja", "ko", "pt-BR", "zh-CN"}
+
+// ValidateLocalizedDescriptions checks the translations of a description, if any: they must be a map of supported
+// locales to non-empty text, next to a description to fall back to
+func ValidateLocalizedDescriptions[T ~map[string]interface{}](fields T) error {
+	value, ok := fields[LocalizedDescriptionField]
+	if !ok {
+		return nil
+	}
+	translations, ok := value.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("must be a map of locale to description, got %T", value)
+	}
+
+	var errs validation.Errors
+	if description, _ := fields["description"].(string); description == "" {
+		errs.Addf("", 0, "", "requires a description to fall back to for other locales")
+	}
+	locales := make([]string, 0, len(translations))
+	for locale := range translations {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	for _, locale := range locales {
+		if !isSupportedLocale(locale) {
+			errs.Addf("", 0, locale, "unsupported locale: must be one of %s", strings.Join(SupportedLocales, ", "))
+			continue
+		}
+		if text, ok := translations[locale].(string); !ok || strings.TrimSpace(text) == "" {
+			errs.Addf("", 0, locale, "must be a non-empty string")
+		}
+	}
+	return errs.Err()
+}
+
+func isSupportedLocale(locale string) bool {
+	for _, supported := range SupportedLocales {
+		if locale == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// HasLocalizedDescriptions reports whether the metadata or any configuration definition has translated descriptions
+func (m *AgentMetadata) HasLocalizedDescriptions() bool {
+	if _, ok := m.Metadata[LocalizedDescriptionField]; ok {
+		return true
+	}
+	for _, definition := range m.ConfigurationDefinitions {
+		if _, ok := definition[LocalizedDescriptionField]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// localize returns fields as sent in a payload version to a service with features: v3 carries translations as
+// localizedDescriptions for services that advertise FeatureLocalizedDescriptions, and otherwise they are left out
+// fields is copied rather than changed
+func localize[T ~map[string]interface{}](version string, features Features, fields T) T {
+	value, ok := fields[LocalizedDescriptionField]
+	if !ok {
+		return fields
+	}
+	localized := make(T, len(fields))
+	for key, v := range fields {
+		if key != LocalizedDescriptionField {
+			localized[key] = v
+		}
+	}
+	if version == PayloadV3 && features.Has(FeatureLocalizedDescriptions) {
+		localized[localizedDescriptionsV3] = value
+	}
+	return localized
+}
+
+// localizeAll applies localize to each of a list of definitions
+func localizeAll[T ~map[string]interface{}](version string, features Features, definitions []T) []T {
+	if definitions == nil {
+		return nil
+	}
+	localized := make([]T, len(definitions))
+	for i, definition := range definitions {
+		localized[i] = localize(version, features, definition)
+	}
+	return localized
+}
+
+// delocalize reverses localize for a decoded v3 payload, changing fields in place
+func delocalize[T ~map[string]interface{}](fields T) {
+	if value, ok := fields[localizedDescriptionsV3]; ok {
+		delete(fields, localizedDescriptionsV3)
+		fields[LocalizedDescriptionField] = value
+	}
+}
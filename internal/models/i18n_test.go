@@ -0,0 +1,97 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateLocalizedDescriptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields ConfigurationDefinition
+		errors []string
+	}{
+		{name: "no translations", fields: ConfigurationDefinition{"description": "Agent configuration"}},
+		{
+			name:   "supported locales",
+			fields: ConfigurationDefinition{"description": "Agent configuration", "description_i18n": map[string]interface{}{"de": "Agentenkonfiguration", "pt-BR": "Configuração do agente"}},
+		},
+		{
+			name:   "not a map",
+			fields: ConfigurationDefinition{"description": "Agent configuration", "description_i18n": "Agentenkonfiguration"},
+			errors: []string{"must be a map of locale to description, got string"},
+		},
+		{
+			name:   "every problem is reported",
+			fields: ConfigurationDefinition{"description_i18n": map[string]interface{}{"de": "", "ja": 42, "klingon": "tlhIngan"}},
+			errors: []string{
+				"requires a description to fall back to for other locales",
+				"de: must be a non-empty string",
+				"ja: must be a non-empty string",
+				"klingon: unsupported locale: must be one of de, es, fr, ja, ko, pt-BR, zh-CN",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			err := ValidateLocalizedDescriptions(tt.fields)
+
+			if len(tt.errors) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, expected := range tt.errors {
+				assert.Contains(t, err.Error(), expected)
+			}
+		})
+	}
+}
+
+func TestEncodePayload_LocalizedDescriptions(t *testing.T) {
+	translations := map[string]interface{}{"de": "Agentenkonfiguration"}
+	metadata := &AgentMetadata{
+		ConfigurationDefinitions: []ConfigurationDefinition{{"platform": "ALL", "description": "Agent configuration", "description_i18n": translations}},
+		Metadata:                 Metadata{"version": "1.2.3"},
+	}
+	require.True(t, metadata.HasLocalizedDescriptions())
+
+	expected := map[string]string{
+		PayloadV1: `{"configurationDefinitions":[{"description":"Agent configuration","platform":"ALL"}],"metadata":{"version":"1.2.3"}}`,
+		PayloadV2: `{"definitions":{"configuration":[{"description":"Agent configuration","platform":"ALL"}]},"metadata":{"version":"1.2.3"},"schemaVersion":"v2"}`,
+		PayloadV3: `{"definitions":{"configuration":[{"description":"Agent configuration","localizedDescriptions":{"de":"Agentenkonfiguration"},"platform":"ALL"}]},"metadata":{"version":"1.2.3"},"schemaVersion":"v3"}`,
+	}
+	for _, version := range PayloadVersions {
+		t.Run(version, func(t *testing.T) {
+			// method under test
+			data, err := EncodePayload(version, metadata, EmptyOmit, Features{FeatureLocalizedDescriptions})
+
+			require.NoError(t, err)
+			assert.Equal(t, expected[version], string(data))
+		})
+	}
+	// The metadata itself is left unchanged
+	assert.Equal(t, translations, metadata.ConfigurationDefinitions[0]["description_i18n"])
+
+	decoded, err := DecodePayload(PayloadV3, []byte(expected[PayloadV3]))
+	require.NoError(t, err)
+	assert.Equal(t, metadata.ConfigurationDefinitions, decoded.ConfigurationDefinitions)
+}
+
+func TestAgentMetadata_Validate_LocalizedDescriptions(t *testing.T) {
+	metadata := &AgentMetadata{
+		ConfigurationDefinitions: []ConfigurationDefinition{{"description": "Agent configuration", "description_i18n": map[string]interface{}{"xx": "?"}}},
+		Metadata:                 Metadata{"version": "1.2.3", "description_i18n": map[string]interface{}{"fr": "Notes de version"}},
+	}
+
+	// method under test
+	err := metadata.Validate()
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "configurationDefinitions[0].description_i18n.xx: unsupported locale")
+	assert.Contains(t, err.Error(), "metadata.description_i18n: requires a description to fall back to")
+}
@@ -0,0 +1,240 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"agent-metadata-action/internal/canonical"
+)
+
+// RemovedDefinitionsField is the field of an incremental submission body listing the definitions to remove
+const RemovedDefinitionsField = "removedDefinitions"
+
+// Fields that identify a definition across submissions of a version; they are also the fields definitions are
+// ordered by (see Canonical)
+var (
+	ConfigurationDefinitionKey = []string{"platform", "type", "version"}
+	AgentControlDefinitionKey  = []string{"platform", "supportFromAgent", "supportFromAgentControl"}
+)
+
+// RemovedDefinitions identifies the definitions an incremental submission removes by their key fields
+type RemovedDefinitions struct {
+	ConfigurationDefinitions []ConfigurationDefinition `json:"configurationDefinitions,omitempty"`
+	AgentControlDefinitions  []AgentControlDefinition  `json:"agentControlDefinitions,omitempty"`
+}
+
+// IsEmpty reports whether no definitions are removed
+func (r RemovedDefinitions) IsEmpty() bool {
+	return len(r.ConfigurationDefinitions) == 0 && len(r.AgentControlDefinitions) == 0
+}
+
+// DefinitionChanges is how the definitions of metadata differ from a previous submission of the same version
+type DefinitionChanges struct {
+	ConfigurationDefinitions []int // indices of the added and changed configuration definitions
+	AgentControlDefinitions  []int // indices of the added and changed agent control definitions
+	Removed                  RemovedDefinitions
+}
+
+// Count returns the number of added, changed and removed definitions
+func (c DefinitionChanges) Count() int {
+	return len(c.ConfigurationDefinitions) + len(c.AgentControlDefinitions) +
+		len(c.Removed.ConfigurationDefinitions) + len(c.Removed.AgentControlDefinitions)
+}
+
+// Select returns a copy of metadata with only the added and changed definitions, as sent in an incremental submission
+// metadata must be the metadata the changes were found for, or have its definitions in the same order
+func (c DefinitionChanges) Select(metadata *AgentMetadata) *AgentMetadata {
+	selected := *metadata
+	selected.ConfigurationDefinitions = make([]ConfigurationDefinition, 0, len(c.ConfigurationDefinitions))
+	for _, i := range c.ConfigurationDefinitions {
+		selected.ConfigurationDefinitions = append(selected.ConfigurationDefinitions, metadata.ConfigurationDefinitions[i])
+	}
+	selected.AgentControlDefinitions = make([]AgentControlDefinition, 0, len(c.AgentControlDefinitions))
+	for _, i := range c.AgentControlDefinitions {
+		selected.AgentControlDefinitions = append(selected.AgentControlDefinitions, metadata.AgentControlDefinitions[i])
+	}
+	return &selected
+}
+
+// DiffDefinitions compares the definitions of current against previous, matching them by their key fields
+// Definitions are compared by their canonical JSON, so both must be in the same form, e.g. both with encoded schemas
+// Definitions that share a key can't be matched and are returned as an error
+func DiffDefinitions(previous, current *AgentMetadata) (DefinitionChanges, error) {
+	var changes DefinitionChanges
+	var err error
+	changes.ConfigurationDefinitions, changes.Removed.ConfigurationDefinitions, err = diffDefinitions(
+		previous.ConfigurationDefinitions, current.ConfigurationDefinitions, ConfigurationDefinitionKey)
+	if err != nil {
+		return changes, fmt.Errorf("configuration definitions: %w", err)
+	}
+	changes.AgentControlDefinitions, changes.Removed.AgentControlDefinitions, err = diffDefinitions(
+		previous.AgentControlDefinitions, current.AgentControlDefinitions, AgentControlDefinitionKey)
+	if err != nil {
+		return changes, fmt.Errorf("agent control definitions: %w", err)
+	}
+	return changes, nil
+}
+
+// ApplyDefinitionChanges returns stored with the definitions of an incremental submission applied: its metadata
+// fields replace the stored ones, its definitions replace the stored definitions with the same key or are added, and
+// removed definitions are dropped
+func ApplyDefinitionChanges(stored, submitted *AgentMetadata, removed RemovedDefinitions) (*AgentMetadata, error) {
+	applied := *submitted
+	var err error
+	if applied.ConfigurationDefinitions, err = applyDefinitions(stored.ConfigurationDefinitions, submitted.ConfigurationDefinitions, removed.ConfigurationDefinitions, ConfigurationDefinitionKey); err != nil {
+		return nil, fmt.Errorf("configuration definitions: %w", err)
+	}
+	if applied.AgentControlDefinitions, err = applyDefinitions(stored.AgentControlDefinitions, submitted.AgentControlDefinitions, removed.AgentControlDefinitions, AgentControlDefinitionKey); err != nil {
+		return nil, fmt.Errorf("agent control definitions: %w", err)
+	}
+	return &applied, nil
+}
+
+// EncodeIncrementalPayload marshals an incremental submission: metadata with only the added and changed definitions,
+// encoded like EncodePayload, and the removed definitions under RemovedDefinitionsField
+func EncodeIncrementalPayload(version string, metadata *AgentMetadata, removed RemovedDefinitions, emptyFields EmptyFieldPolicy, features Features) ([]byte, error) {
+	body, err := EncodePayload(version, metadata, emptyFields, features)
+	if err != nil || removed.IsEmpty() {
+		return body, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	fields[RemovedDefinitionsField] = removed
+	return canonical.Marshal(fields)
+}
+
+// DecodeIncrementalPayload unmarshals an incremental submission body of the given payload version
+func DecodeIncrementalPayload(version string, data []byte) (*AgentMetadata, RemovedDefinitions, error) {
+	var removed struct {
+		Removed RemovedDefinitions `json:"removedDefinitions"`
+	}
+	metadata, err := DecodePayload(version, data)
+	if err != nil {
+		return nil, removed.Removed, err
+	}
+	if err := json.Unmarshal(data, &removed); err != nil {
+		return nil, removed.Removed, err
+	}
+	return metadata, removed.Removed, nil
+}
+
+// diffDefinitions returns the indices of the definitions in current that are new or differ from previous, and the
+// key fields of the definitions in previous that are no longer in current
+func diffDefinitions[T ~map[string]interface{}](previous, current []T, key []string) ([]int, []T, error) {
+	previousByKey, err := definitionsByKey(previous, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	currentByKey, err := definitionsByKey(current, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var changed []int
+	for i, definition := range current {
+		k, _ := definitionKey(definition, key)
+		encoded, err := canonical.Marshal(definition)
+		if err != nil {
+			return nil, nil, err
+		}
+		if before, ok := previousByKey[k]; !ok || before != string(encoded) {
+			changed = append(changed, i)
+		}
+	}
+
+	var removed []T
+	for _, definition := range previous {
+		k, _ := definitionKey(definition, key)
+		if _, ok := currentByKey[k]; !ok {
+			removed = append(removed, keyFields(definition, key))
+		}
+	}
+	return changed, removed, nil
+}
+
+// applyDefinitions replaces the stored definitions with the submitted ones that share their key, appends the other
+// submitted definitions and drops the removed ones
+func applyDefinitions[T ~map[string]interface{}](stored, submitted, removed []T, key []string) ([]T, error) {
+	replacements := map[string]T{}
+	for _, definition := range submitted {
+		k, err := definitionKey(definition, key)
+		if err != nil {
+			return nil, err
+		}
+		replacements[k] = definition
+	}
+	dropped := map[string]bool{}
+	for _, definition := range removed {
+		k, err := definitionKey(definition, key)
+		if err != nil {
+			return nil, err
+		}
+		dropped[k] = true
+	}
+
+	var applied []T
+	for _, definition := range stored {
+		k, err := definitionKey(definition, key)
+		if err != nil {
+			return nil, err
+		}
+		if dropped[k] {
+			continue
+		}
+		if replacement, ok := replacements[k]; ok {
+			applied = append(applied, replacement)
+			delete(replacements, k)
+			continue
+		}
+		applied = append(applied, definition)
+	}
+	for _, definition := range submitted {
+		k, _ := definitionKey(definition, key)
+		if _, ok := replacements[k]; ok {
+			applied = append(applied, definition)
+		}
+	}
+	return applied, nil
+}
+
+// definitionsByKey maps the key of each definition to its canonical JSON, rejecting definitions that share a key
+func definitionsByKey[T ~map[string]interface{}](definitions []T, key []string) (map[string]string, error) {
+	byKey := make(map[string]string, len(definitions))
+	for _, definition := range definitions {
+		k, err := definitionKey(definition, key)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := byKey[k]; ok {
+			return nil, fmt.Errorf("several definitions have the key %s", k)
+		}
+		encoded, err := canonical.Marshal(definition)
+		if err != nil {
+			return nil, err
+		}
+		byKey[k] = string(encoded)
+	}
+	return byKey, nil
+}
+
+// definitionKey returns the canonical JSON of the key fields of a definition
+func definitionKey[T ~map[string]interface{}](definition T, key []string) (string, error) {
+	encoded, err := canonical.Marshal(keyFields(definition, key))
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// keyFields returns the key fields a definition sets
+func keyFields[T ~map[string]interface{}](definition T, key []string) T {
+	fields := make(T, len(key))
+	for _, field := range key {
+		if value, ok := definition[field]; ok && value != nil {
+			fields[field] = value
+		}
+	}
+	return fields
+}
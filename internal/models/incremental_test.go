@@ -0,0 +1,120 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffDefinitions(t *testing.T) {
+	previous := &AgentMetadata{
+		ConfigurationDefinitions: []ConfigurationDefinition{
+			{"platform": "HOST", "type": "agent-config", "version": "1.0.0", "schema": "c2NoZW1h"},
+			{"platform": "HOST", "type": "logging", "version": "1.0.0", "schema": "bG9ncw=="},
+			{"platform": "HOST", "type": "legacy", "version": "1.0.0", "description": "Legacy"},
+		},
+		AgentControlDefinitions: []AgentControlDefinition{{"platform": "KUBERNETES", "supportFromAgent": "1.0.0", "content": "Y29udGVudA=="}},
+	}
+	current := &AgentMetadata{
+		Metadata: Metadata{"version": "1.2.3"},
+		ConfigurationDefinitions: []ConfigurationDefinition{
+			{"platform": "HOST", "type": "logging", "version": "1.0.0", "schema": "bG9nczI="},
+			{"type": "agent-config", "version": "1.0.0", "schema": "c2NoZW1h", "platform": "HOST"},
+			{"platform": "HOST", "type": "tracing", "version": "1.0.0"},
+		},
+		AgentControlDefinitions: []AgentControlDefinition{{"platform": "KUBERNETES", "supportFromAgent": "1.0.0", "content": "Y29udGVudA=="}},
+	}
+
+	// method under test
+	changes, err := DiffDefinitions(previous, current)
+
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 2}, changes.ConfigurationDefinitions)
+	assert.Empty(t, changes.AgentControlDefinitions)
+	assert.Equal(t, RemovedDefinitions{
+		ConfigurationDefinitions: []ConfigurationDefinition{{"platform": "HOST", "type": "legacy", "version": "1.0.0"}},
+	}, changes.Removed)
+	assert.Equal(t, 3, changes.Count())
+
+	selected := changes.Select(current)
+	assert.Equal(t, []ConfigurationDefinition{current.ConfigurationDefinitions[0], current.ConfigurationDefinitions[2]}, selected.ConfigurationDefinitions)
+	assert.Empty(t, selected.AgentControlDefinitions)
+	assert.Equal(t, current.Metadata, selected.Metadata)
+	assert.Len(t, current.ConfigurationDefinitions, 3)
+}
+
+func TestDiffDefinitions_DuplicateKeys(t *testing.T) {
+	current := &AgentMetadata{ConfigurationDefinitions: []ConfigurationDefinition{
+		{"platform": "HOST", "type": "agent-config", "description": "First"},
+		{"platform": "HOST", "type": "agent-config", "description": "Second"},
+	}}
+
+	// method under test
+	_, err := DiffDefinitions(&AgentMetadata{}, current)
+
+	require.Error(t, err)
+	assert.Equal(t, `configuration definitions: several definitions have the key {"platform":"HOST","type":"agent-config"}`, err.Error())
+}
+
+func TestApplyDefinitionChanges(t *testing.T) {
+	stored := &AgentMetadata{
+		Metadata: Metadata{"version": "1.2.3", "features": []interface{}{"old"}},
+		ConfigurationDefinitions: []ConfigurationDefinition{
+			{"platform": "HOST", "type": "agent-config", "version": "1.0.0", "schema": "b2xk"},
+			{"platform": "HOST", "type": "legacy", "version": "1.0.0"},
+			{"platform": "HOST", "type": "logging", "version": "1.0.0"},
+		},
+		AgentControlDefinitions: []AgentControlDefinition{{"platform": "HOST", "supportFromAgent": "1.0.0"}},
+	}
+	submitted := &AgentMetadata{
+		Metadata: Metadata{"version": "1.2.3", "features": []interface{}{"new"}},
+		ConfigurationDefinitions: []ConfigurationDefinition{
+			{"platform": "HOST", "type": "tracing", "version": "1.0.0"},
+			{"platform": "HOST", "type": "agent-config", "version": "1.0.0", "schema": "bmV3"},
+		},
+	}
+	removed := RemovedDefinitions{ConfigurationDefinitions: []ConfigurationDefinition{{"platform": "HOST", "type": "legacy", "version": "1.0.0"}}}
+
+	// method under test
+	applied, err := ApplyDefinitionChanges(stored, submitted, removed)
+
+	require.NoError(t, err)
+	assert.Equal(t, submitted.Metadata, applied.Metadata)
+	assert.Equal(t, []ConfigurationDefinition{
+		{"platform": "HOST", "type": "agent-config", "version": "1.0.0", "schema": "bmV3"},
+		{"platform": "HOST", "type": "logging", "version": "1.0.0"},
+		{"platform": "HOST", "type": "tracing", "version": "1.0.0"},
+	}, applied.ConfigurationDefinitions)
+	assert.Equal(t, stored.AgentControlDefinitions, applied.AgentControlDefinitions)
+}
+
+func TestIncrementalPayload(t *testing.T) {
+	metadata := &AgentMetadata{
+		Metadata:                 Metadata{"version": "1.2.3"},
+		ConfigurationDefinitions: []ConfigurationDefinition{{"platform": "HOST", "type": "tracing"}},
+	}
+	removed := RemovedDefinitions{AgentControlDefinitions: []AgentControlDefinition{{"platform": "KUBERNETES", "supportFromAgent": "1.0.0"}}}
+
+	for _, version := range PayloadVersions {
+		t.Run(version, func(t *testing.T) {
+			// method under test
+			body, err := EncodeIncrementalPayload(version, metadata, removed, EmptyOmit, nil)
+			require.NoError(t, err)
+			assert.Contains(t, string(body), `"removedDefinitions":{"agentControlDefinitions":[{"platform":"KUBERNETES","supportFromAgent":"1.0.0"}]}`)
+
+			decoded, decodedRemoved, err := DecodeIncrementalPayload(version, body)
+			require.NoError(t, err)
+			assert.Equal(t, metadata.ConfigurationDefinitions, decoded.ConfigurationDefinitions)
+			assert.Equal(t, removed, decodedRemoved)
+		})
+	}
+
+	t.Run("nothing removed", func(t *testing.T) {
+		body, err := EncodeIncrementalPayload(PayloadV1, metadata, RemovedDefinitions{}, EmptyOmit, nil)
+		require.NoError(t, err)
+		full, err := EncodePayload(PayloadV1, metadata, EmptyOmit, nil)
+		require.NoError(t, err)
+		assert.Equal(t, full, body)
+	})
+}
@@ -37,6 +37,8 @@ type ConfigFile struct {
 }
 
 // AgentControlFile represents the YAML file structure containing multiple agent control definitions
+// Platform optionally sets the platform of every definition in the file that doesn't set its own
 type AgentControlFile struct {
+	Platform      string                   `yaml:"platform"`
 	AgentControls []AgentControlDefinition `yaml:"agentControlDefinitions"`
 }
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"agent-metadata-action/internal/canonical"
+)
+
+// Payload versions of the instrumentation metadata service, oldest first
+const (
+	// PayloadV1 is the original flat AgentMetadata body
+	PayloadV1 = "v1"
+	// PayloadV2 groups definitions under "definitions" and declares its schemaVersion
+	PayloadV2 = "v2"
+	// PayloadV3 is the v2 layout with translated descriptions (see LocalizedDescriptionField) as localizedDescriptions
+	PayloadV3 = "v3"
+)
+
+// PayloadVersions lists the payload versions this build can produce, oldest first
+var PayloadVersions = []string{PayloadV1, PayloadV2, PayloadV3}
+
+// IsPayloadVersion reports whether version is a payload version this build can produce
+func IsPayloadVersion(version string) bool {
+	for _, v := range PayloadVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// agentMetadataV1 is the v1 payload body as encoded; definition lists are pointers so an EmptyFieldPolicy can send
+// them as omitted, null or []
+type agentMetadataV1 struct {
+	ConfigurationDefinitions *[]ConfigurationDefinition `json:"configurationDefinitions,omitempty"`
+	Metadata                 Metadata                   `json:"metadata"`
+	AgentControlDefinitions  *[]AgentControlDefinition  `json:"agentControlDefinitions,omitempty"`
+	Bindings                 []interface{}              `json:"bindings,omitempty"`
+	BreakingChange           *string                    `json:"breakingChange,omitempty"`
+}
+
+// agentMetadataV2 is the v2 and v3 payload body
+type agentMetadataV2 struct {
+	SchemaVersion  string         `json:"schemaVersion"`
+	Metadata       Metadata       `json:"metadata"`
+	Definitions    *definitionsV2 `json:"definitions,omitempty"`
+	Bindings       []interface{}  `json:"bindings,omitempty"`
+	BreakingChange *string        `json:"breakingChange,omitempty"`
+}
+
+type definitionsV2 struct {
+	Configuration *[]ConfigurationDefinition `json:"configuration,omitempty"`
+	AgentControl  *[]AgentControlDefinition  `json:"agentControl,omitempty"`
+}
+
+// EncodePayload marshals metadata as the canonical body of the given payload version
+// Definitions are put in canonical order (see AgentMetadata.Canonical) and the JSON is canonical, so the same metadata
+// always gives byte-identical bodies, payload hashes and idempotency keys
+// Empty metadata fields and definition lists are sent as emptyFields says; an empty policy is EmptyOmit
+// Fields that need a payload feature are only sent if features has it (see PayloadFeatures)
+func EncodePayload(version string, metadata *AgentMetadata, emptyFields EmptyFieldPolicy, features Features) ([]byte, error) {
+	if !IsPayloadVersion(version) {
+		return nil, fmt.Errorf("unsupported payload version %q", version)
+	}
+	metadata, err := metadata.Canonical()
+	if err != nil {
+		return nil, err
+	}
+	if emptyFields == "" {
+		emptyFields = EmptyOmit
+	}
+	fields := localize(version, features, emptyFields.Metadata(metadata.Metadata))
+	configuration := list(emptyFields, PayloadConfigurationDefinitions(version, features, metadata.ConfigurationDefinitions))
+	agentControl := list(emptyFields, payloadAgentControlDefinitions(features, metadata.AgentControlDefinitions))
+
+	switch version {
+	case PayloadV1:
+		return canonical.Marshal(agentMetadataV1{
+			ConfigurationDefinitions: configuration,
+			Metadata:                 fields,
+			AgentControlDefinitions:  agentControl,
+			Bindings:                 metadata.Bindings,
+			BreakingChange:           metadata.BreakingChange,
+		})
+	case PayloadV2, PayloadV3:
+		body := agentMetadataV2{
+			SchemaVersion:  version,
+			Metadata:       fields,
+			Bindings:       metadata.Bindings,
+			BreakingChange: metadata.BreakingChange,
+		}
+		if configuration != nil || agentControl != nil {
+			body.Definitions = &definitionsV2{Configuration: configuration, AgentControl: agentControl}
+		}
+		return canonical.Marshal(body)
+	}
+	return nil, fmt.Errorf("unsupported payload version %q", version)
+}
+
+// DecodePayload unmarshals a body of the given payload version
+func DecodePayload(version string, data []byte) (*AgentMetadata, error) {
+	switch version {
+	case PayloadV1:
+		var metadata AgentMetadata
+		if err := json.Unmarshal(data, &metadata); err != nil {
+			return nil, err
+		}
+		RestoreConfigurationDefinitions(metadata.ConfigurationDefinitions)
+		return &metadata, nil
+	case PayloadV2, PayloadV3:
+		var payload agentMetadataV2
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return nil, err
+		}
+		if payload.SchemaVersion != version {
+			return nil, fmt.Errorf("expected schemaVersion %s, got %q", version, payload.SchemaVersion)
+		}
+		metadata := &AgentMetadata{
+			Metadata:       payload.Metadata,
+			Bindings:       payload.Bindings,
+			BreakingChange: payload.BreakingChange,
+		}
+		if payload.Definitions != nil {
+			if payload.Definitions.Configuration != nil {
+				metadata.ConfigurationDefinitions = *payload.Definitions.Configuration
+			}
+			if payload.Definitions.AgentControl != nil {
+				metadata.AgentControlDefinitions = *payload.Definitions.AgentControl
+			}
+		}
+		delocalize(metadata.Metadata)
+		RestoreConfigurationDefinitions(metadata.ConfigurationDefinitions)
+		return metadata, nil
+	}
+	return nil, fmt.Errorf("unsupported payload version %q", version)
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecodePayload_RoundTrip(t *testing.T) {
+	breaking := "removed setting"
+	metadata := &AgentMetadata{
+		ConfigurationDefinitions: []ConfigurationDefinition{{"platform": "ALL", "type": "agent-config", "version": "1.0.0"}},
+		Metadata:                 Metadata{"version": "1.2.3"},
+		AgentControlDefinitions:  []AgentControlDefinition{{"platform": "KUBERNETES"}},
+		BreakingChange:           &breaking,
+	}
+
+	for _, version := range PayloadVersions {
+		t.Run(version, func(t *testing.T) {
+			// method under test
+			data, err := EncodePayload(version, metadata, EmptyOmit, nil)
+			require.NoError(t, err)
+
+			decoded, err := DecodePayload(version, data)
+			require.NoError(t, err)
+			assert.Equal(t, metadata, decoded)
+		})
+	}
+}
+
+func TestEncodePayload_V2Layout(t *testing.T) {
+	data, err := EncodePayload(PayloadV2, &AgentMetadata{
+		ConfigurationDefinitions: []ConfigurationDefinition{{"platform": "ALL"}},
+		Metadata:                 Metadata{"version": "1.2.3"},
+	}, EmptyOmit, nil)
+	require.NoError(t, err)
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(data, &body))
+	assert.Equal(t, "v2", body["schemaVersion"])
+	assert.Contains(t, body["definitions"], "configuration")
+	assert.NotContains(t, body, "configurationDefinitions")
+}
+
+func TestDecodePayload_Errors(t *testing.T) {
+	_, err := DecodePayload(PayloadV2, []byte(`{"metadata": {"version": "1.2.3"}}`))
+	assert.EqualError(t, err, `expected schemaVersion v2, got ""`)
+
+	_, err = DecodePayload(PayloadV3, []byte(`{"metadata": {"version": "1.2.3"}, "schemaVersion": "v2"}`))
+	assert.EqualError(t, err, `expected schemaVersion v3, got "v2"`)
+
+	_, err = DecodePayload("v9", []byte(`{}`))
+	assert.EqualError(t, err, `unsupported payload version "v9"`)
+
+	_, err = EncodePayload("v9", &AgentMetadata{}, EmptyOmit, nil)
+	assert.EqualError(t, err, `unsupported payload version "v9"`)
+
+	assert.True(t, IsPayloadVersion("v2"))
+	assert.False(t, IsPayloadVersion("auto"))
+}
+
+func TestEncodePayload_EmptyFields(t *testing.T) {
+	// Release note metadata carries no definitions and often leaves optional fields empty
+	metadata := &AgentMetadata{
+		Metadata: Metadata{"version": "1.2.3", "eol": "", "features": []interface{}{}, "bugs": nil, "security": []interface{}{"CVE-1"}},
+	}
+
+	tests := []struct {
+		policy   EmptyFieldPolicy
+		expected map[string]string
+	}{
+		{
+			policy: EmptyOmit,
+			expected: map[string]string{
+				PayloadV1: `{"metadata":{"security":["CVE-1"],"version":"1.2.3"}}`,
+				PayloadV2: `{"metadata":{"security":["CVE-1"],"version":"1.2.3"},"schemaVersion":"v2"}`,
+				PayloadV3: `{"metadata":{"security":["CVE-1"],"version":"1.2.3"},"schemaVersion":"v3"}`,
+			},
+		},
+		{
+			policy: "",
+			expected: map[string]string{
+				PayloadV1: `{"metadata":{"security":["CVE-1"],"version":"1.2.3"}}`,
+				PayloadV2: `{"metadata":{"security":["CVE-1"],"version":"1.2.3"},"schemaVersion":"v2"}`,
+				PayloadV3: `{"metadata":{"security":["CVE-1"],"version":"1.2.3"},"schemaVersion":"v3"}`,
+			},
+		},
+		{
+			policy: EmptyAsNull,
+			expected: map[string]string{
+				PayloadV1: `{"agentControlDefinitions":null,"configurationDefinitions":null,"metadata":{"bugs":null,"eol":null,"features":null,"security":["CVE-1"],"version":"1.2.3"}}`,
+				PayloadV2: `{"definitions":{"agentControl":null,"configuration":null},"metadata":{"bugs":null,"eol":null,"features":null,"security":["CVE-1"],"version":"1.2.3"},"schemaVersion":"v2"}`,
+				PayloadV3: `{"definitions":{"agentControl":null,"configuration":null},"metadata":{"bugs":null,"eol":null,"features":null,"security":["CVE-1"],"version":"1.2.3"},"schemaVersion":"v3"}`,
+			},
+		},
+		{
+			policy: EmptyAsArray,
+			expected: map[string]string{
+				PayloadV1: `{"agentControlDefinitions":[],"configurationDefinitions":[],"metadata":{"features":[],"security":["CVE-1"],"version":"1.2.3"}}`,
+				PayloadV2: `{"definitions":{"agentControl":[],"configuration":[]},"metadata":{"features":[],"security":["CVE-1"],"version":"1.2.3"},"schemaVersion":"v2"}`,
+				PayloadV3: `{"definitions":{"agentControl":[],"configuration":[]},"metadata":{"features":[],"security":["CVE-1"],"version":"1.2.3"},"schemaVersion":"v3"}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, version := range PayloadVersions {
+			t.Run(string(tt.policy)+"/"+version, func(t *testing.T) {
+				// method under test
+				data, err := EncodePayload(version, metadata, tt.policy, nil)
+
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected[version], string(data))
+			})
+		}
+	}
+	// The metadata itself is left unchanged
+	assert.Equal(t, "", metadata.Metadata["eol"])
+}
+
+func TestEncodePayload_EmptyFieldsKeepVersion(t *testing.T) {
+	data, err := EncodePayload(PayloadV1, &AgentMetadata{Metadata: Metadata{"version": ""}}, EmptyOmit, nil)
+	require.NoError(t, err)
+	assert.Equal(t, `{"metadata":{"version":""}}`, string(data))
+}
+
+func TestParseEmptyFieldPolicy(t *testing.T) {
+	policy, err := ParseEmptyFieldPolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, EmptyOmit, policy)
+
+	policy, err = ParseEmptyFieldPolicy("send-empty-as-null")
+	require.NoError(t, err)
+	assert.Equal(t, EmptyAsNull, policy)
+
+	_, err = ParseEmptyFieldPolicy("null")
+	assert.EqualError(t, err, `invalid empty field policy "null": must be one of send-empty-as-null, omit, empty-array`)
+}
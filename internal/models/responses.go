@@ -0,0 +1,86 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SubmissionResponse is the body the instrumentation service returns when it stores metadata
+// Fields are optional since older deployments return an empty body or only a status message
+type SubmissionResponse struct {
+	ID        string `json:"id,omitempty"`
+	AgentType string `json:"agentType,omitempty"`
+	Version   string `json:"version,omitempty"`
+}
+
+// ParseSubmissionResponse parses a 2xx submission response body
+// Returns an error if the body isn't a JSON object of the expected shape or describes a different agent version
+func ParseSubmissionResponse(body []byte, agentType, version string) (SubmissionResponse, error) {
+	var response SubmissionResponse
+	if err := decodeResponse(body, &response); err != nil {
+		return response, err
+	}
+	if response.AgentType != "" && response.AgentType != agentType {
+		return response, fmt.Errorf("service registered agent type %q but %q was submitted", response.AgentType, agentType)
+	}
+	if response.Version != "" && response.Version != version {
+		return response, fmt.Errorf("service registered version %q but %q was submitted", response.Version, version)
+	}
+	return response, nil
+}
+
+// ReleaseLock is the lock the instrumentation service holds on releasing an agent version for one run at a time
+// It lapses at ExpiresAt unless its owner renews it, so a run that dies holding it doesn't block later releases
+type ReleaseLock struct {
+	Owner     string    `json:"owner"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// SigningResponse is the body the signing service returns when it signs an artifact
+// Fields are optional since older deployments return an empty body or only a success flag
+type SigningResponse struct {
+	Success *bool  `json:"success,omitempty"`
+	ID      string `json:"id,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+}
+
+// ParseSigningResponse parses a 2xx signing response body
+// Returns an error if the body isn't a JSON object of the expected shape, reports failure or names a different digest
+func ParseSigningResponse(body []byte, digest string) (SigningResponse, error) {
+	var response SigningResponse
+	if err := decodeResponse(body, &response); err != nil {
+		return response, err
+	}
+	if response.Success != nil && !*response.Success {
+		return response, fmt.Errorf("service reported the artifact was not signed")
+	}
+	if response.Digest != "" && response.Digest != digest {
+		return response, fmt.Errorf("service signed digest %q but %q was requested", response.Digest, digest)
+	}
+	return response, nil
+}
+
+// decodeResponse unmarshals a JSON object response body into v; an empty body leaves v unchanged
+func decodeResponse(body []byte, v any) error {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil
+	}
+	if body[0] != '{' {
+		return fmt.Errorf("invalid response body: expected a JSON object")
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid response body: %w", err)
+	}
+	return nil
+}
+
+// ReleaseSummary is a version the instrumentation service has metadata for, as listed with its dates
+// Dates are YYYY-MM-DD, and empty if the metadata doesn't set them
+type ReleaseSummary struct {
+	Version     string `json:"version"`
+	ReleaseDate string `json:"releaseDate,omitempty"`
+	EOL         string `json:"eol,omitempty"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSubmissionResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected SubmissionResponse
+		errMsg   string
+	}{
+		{name: "empty body", body: ""},
+		{name: "status message only", body: `{"success": true, "message": "Created"}`},
+		{
+			name:     "echoes the submission",
+			body:     `{"id": "12345", "agentType": "NRJavaAgent", "version": "1.2.3"}`,
+			expected: SubmissionResponse{ID: "12345", AgentType: "NRJavaAgent", Version: "1.2.3"},
+		},
+		{name: "wrong version echoed", body: `{"agentType": "NRJavaAgent", "version": "1.2.4"}`, errMsg: `service registered version "1.2.4" but "1.2.3" was submitted`},
+		{name: "wrong agent type echoed", body: `{"agentType": "NRNodeAgent"}`, errMsg: `service registered agent type "NRNodeAgent"`},
+		{name: "ID of the wrong type", body: `{"id": 12345}`, errMsg: "invalid response body"},
+		{name: "not an object", body: `["1.2.3"]`, errMsg: "expected a JSON object"},
+		{name: "not JSON", body: `OK`, errMsg: "expected a JSON object"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			response, err := ParseSubmissionResponse([]byte(tt.body), "NRJavaAgent", "1.2.3")
+
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, response)
+		})
+	}
+}
+
+func TestParseSigningResponse(t *testing.T) {
+	digest := "sha256:abc123"
+
+	t.Run("empty body", func(t *testing.T) {
+		_, err := ParseSigningResponse(nil, digest)
+		require.NoError(t, err)
+	})
+
+	t.Run("echoes the signature", func(t *testing.T) {
+		response, err := ParseSigningResponse([]byte(`{"success": true, "id": "sig-1", "digest": "sha256:abc123"}`), digest)
+		require.NoError(t, err)
+		assert.Equal(t, "sig-1", response.ID)
+	})
+
+	t.Run("reports failure", func(t *testing.T) {
+		_, err := ParseSigningResponse([]byte(`{"success": false}`), digest)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not signed")
+	})
+
+	t.Run("wrong digest echoed", func(t *testing.T) {
+		_, err := ParseSigningResponse([]byte(`{"digest": "sha256:def456"}`), digest)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `service signed digest "sha256:def456"`)
+	})
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+
+	"agent-metadata-action/internal/validation"
+)
+
+// MaxContentSize is the largest decoded schema or agent control content accepted in a submission,
+// the same limit applied to schemas fetched from other repositories
+const MaxContentSize = 1 << 20 // 1 MiB
+
+// versionPattern matches the semantic versions of the supportFromAgent and supportFromAgentControl fields
+var versionPattern = regexp.MustCompile(`^v?\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// Validate checks that every configuration definition schema and agent control content is non-empty base64
+// that decodes to at most MaxContentSize bytes, catching content a loader left unencoded or corrupted
+// Definitions without a schema or content are valid; every problem found is returned
+// Translated descriptions of the metadata and configuration definitions are checked too (see ValidateLocalizedDescriptions),
+// as are the deprecation fields and changelogs of configuration definitions (see ValidateDeprecations and
+// ValidateChangelogs) and the minimum versions of agent control definitions (see ValidateAgentControlVersions)
+func (m *AgentMetadata) Validate() error {
+	var errs validation.Errors
+	for i, definition := range m.ConfigurationDefinitions {
+		if err := validateContent(definition, "schema"); err != nil {
+			errs.Add("", 0, fmt.Sprintf("configurationDefinitions[%d].schema", i), err)
+		}
+		if err := ValidateLocalizedDescriptions(definition); err != nil {
+			errs.Add("", 0, fmt.Sprintf("configurationDefinitions[%d].%s", i, LocalizedDescriptionField), err)
+		}
+	}
+	errs.Append(ValidateDeprecations(m.ConfigurationDefinitions))
+	errs.Append(ValidateChangelogs(m.ConfigurationDefinitions))
+	for i, definition := range m.AgentControlDefinitions {
+		if err := validateContent(definition, "content"); err != nil {
+			errs.Add("", 0, fmt.Sprintf("agentControlDefinitions[%d].content", i), err)
+		}
+		if err := ValidateAgentControlVersions(definition); err != nil {
+			errs.Add("", 0, fmt.Sprintf("agentControlDefinitions[%d]", i), err)
+		}
+	}
+	if err := ValidateLocalizedDescriptions(m.Metadata); err != nil {
+		errs.Add("", 0, "metadata."+LocalizedDescriptionField, err)
+	}
+	return errs.Err()
+}
+
+// validateContent checks the base64 content in field of a definition, if present
+func validateContent[T ~map[string]interface{}](definition T, field string) error {
+	value, ok := definition[field]
+	if !ok || value == nil {
+		return nil
+	}
+	encoded, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("must be a base64 string, got %T", value)
+	}
+	if encoded == "" {
+		return fmt.Errorf("is empty")
+	}
+	decoded, err := base64.StdEncoding.Strict().DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("is not valid base64: %w", err)
+	}
+	if len(decoded) > MaxContentSize {
+		return fmt.Errorf("decodes to %d bytes, more than the %d byte content limit", len(decoded), MaxContentSize)
+	}
+	return nil
+}
+
+// ValidateAgentControlVersions checks the minimum agent version (supportFromAgent) and minimum Agent Control version
+// (supportFromAgentControl) of an agent control definition are semantic versions, so Fleet Control can compare them
+// to the versions running on a host or cluster; both are optional
+func ValidateAgentControlVersions(definition AgentControlDefinition) error {
+	var errs validation.Errors
+	for _, field := range []string{"supportFromAgent", "supportFromAgentControl"} {
+		value, ok := definition[field]
+		if !ok || value == nil {
+			continue
+		}
+		version, ok := value.(string)
+		if !ok {
+			errs.Addf("", 0, field, "must be a version string such as 1.2.0, got %v - quote it in YAML", value)
+			continue
+		}
+		if !versionPattern.MatchString(version) {
+			errs.Addf("", 0, field, "%q is not a semantic version such as 1.2.0", version)
+		}
+	}
+	return errs.Err()
+}
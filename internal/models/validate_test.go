@@ -0,0 +1,79 @@
+package models
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentMetadata_Validate(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(`{"type": "object"}`))
+	tooLarge := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("x", MaxContentSize+1)))
+
+	tests := []struct {
+		name     string
+		metadata AgentMetadata
+		errors   []string
+	}{
+		{
+			name: "valid content",
+			metadata: AgentMetadata{
+				ConfigurationDefinitions: []ConfigurationDefinition{{"type": "agent-config", "schema": encoded}, {"type": "no-schema"}},
+				AgentControlDefinitions:  []AgentControlDefinition{{"platform": "KUBERNETES", "content": encoded, "supportFromAgent": "v1.2.0", "supportFromAgentControl": "1.0.0-rc.1"}},
+			},
+		},
+		{
+			name:     "no definitions",
+			metadata: AgentMetadata{},
+		},
+		{
+			name: "every problem is reported",
+			metadata: AgentMetadata{
+				ConfigurationDefinitions: []ConfigurationDefinition{
+					{"schema": "./schemas/config.json"},
+					{"schema": ""},
+					{"schema": 42},
+					{"schema": encoded[:len(encoded)-1]},
+					{"schema": tooLarge},
+				},
+				AgentControlDefinitions: []AgentControlDefinition{
+					{"content": "key: value"},
+					{"supportFromAgent": "latest", "supportFromAgentControl": 1.1},
+				},
+			},
+			errors: []string{
+				"configurationDefinitions[0].schema: is not valid base64",
+				"configurationDefinitions[1].schema: is empty",
+				"configurationDefinitions[2].schema: must be a base64 string, got int",
+				"configurationDefinitions[3].schema: is not valid base64: illegal base64 data",
+				"configurationDefinitions[4].schema: decodes to 1048577 bytes, more than the 1048576 byte content limit",
+				"agentControlDefinitions[0].content: is not valid base64",
+				`agentControlDefinitions[1].supportFromAgent: "latest" is not a semantic version such as 1.2.0`,
+				"agentControlDefinitions[1].supportFromAgentControl: must be a version string such as 1.2.0, got 1.1 - quote it in YAML",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			err := tt.metadata.Validate()
+
+			if len(tt.errors) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			var errs validation.Errors
+			require.ErrorAs(t, err, &errs)
+			require.Len(t, errs, len(tt.errors))
+			for i, expected := range tt.errors {
+				assert.Contains(t, errs[i].Error(), expected)
+			}
+		})
+	}
+}
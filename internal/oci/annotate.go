@@ -0,0 +1,79 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"agent-metadata-action/internal/config"
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+// RegistryErrorCode returns the error codes and HTTP status the registry answered a failed request with, e.g.
+// "DENIED (HTTP 403)", or an empty string for errors that didn't come from the registry
+func RegistryErrorCode(err error) string {
+	var response *errcode.ErrorResponse
+	if !errors.As(err, &response) {
+		return ""
+	}
+	codes := make([]string, 0, len(response.Errors))
+	for _, registryErr := range response.Errors {
+		codes = append(codes, registryErr.Code)
+	}
+	if len(codes) == 0 {
+		return fmt.Sprintf("HTTP %d", response.StatusCode)
+	}
+	return fmt.Sprintf("%s (HTTP %d)", strings.Join(codes, ", "), response.StatusCode)
+}
+
+// ManifestURL returns the registry API URL of the manifest with digest in registry
+func ManifestURL(registry, manifestDigest string, plainHTTP bool) string {
+	host, repository, _ := strings.Cut(registry, "/")
+	scheme := "https"
+	if plainHTTP {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, host, repository, manifestDigest)
+}
+
+// annotationFile returns the file an artifact's annotation is shown on: its configured path when it is a file in the
+// repository, or else the running workflow file, where the binaries input is set
+func annotationFile(path string) string {
+	if path == "" || strings.Contains(path, "://") {
+		return config.GetWorkflowFile()
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// annotateUploadResult shows how an artifact's upload went in the Annotations panel of the workflow run: the
+// manifest and a link to it in the registry, or without a registry that it's in the OCI layout, when it was pushed or
+// referenced, or the error and the registry's error code when it failed
+// path is the artifact's configured path
+func annotateUploadResult(ctx context.Context, registry string, plainHTTP bool, path string, result models.ArtifactUploadResult) {
+	file := annotationFile(path)
+	location := ManifestURL(registry, result.Digest, plainHTTP)
+	if registry == "" {
+		location = "in the OCI layout"
+	}
+	switch {
+	case result.Uploaded && result.Referenced:
+		logging.Annotate(ctx, "notice", file, "Referenced "+result.Name,
+			fmt.Sprintf("Referenced %s: %s (os: %s, arch: %s, media type: %s, manifest size: %d bytes) %s",
+				result.Name, result.Digest, result.OS, result.Arch, result.MediaType, result.Size, location))
+	case result.Uploaded:
+		logging.Annotate(ctx, "notice", file, "Uploaded "+result.Name,
+			fmt.Sprintf("Uploaded %s: %s (os: %s, arch: %s, digest: %s, manifest size: %d bytes) %s",
+				result.Name, result.Path, result.OS, result.Arch, result.Digest, result.Size, location))
+	default:
+		message := fmt.Sprintf("Failed to upload %s (%s): %s", result.Name, result.Path, result.Error)
+		if result.ErrorCode != "" {
+			message = fmt.Sprintf("Failed to upload %s (%s), registry answered %s: %s", result.Name, result.Path, result.ErrorCode, result.Error)
+		}
+		logging.Annotate(ctx, "error", file, "Upload failed: "+result.Name, message)
+	}
+}
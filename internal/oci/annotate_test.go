@@ -0,0 +1,87 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+func TestRegistryErrorCode(t *testing.T) {
+	denied := &errcode.ErrorResponse{
+		Method:     http.MethodPut,
+		StatusCode: http.StatusForbidden,
+		Errors:     errcode.Errors{{Code: errcode.ErrorCodeDenied, Message: "requested access to the resource is denied"}},
+	}
+
+	assert.Equal(t, "DENIED (HTTP 403)", RegistryErrorCode(fmt.Errorf("failed OCI artifact upload after 3 attempts: %w", denied)))
+	assert.Equal(t, "HTTP 502", RegistryErrorCode(&errcode.ErrorResponse{StatusCode: http.StatusBadGateway}))
+	assert.Empty(t, RegistryErrorCode(errors.New("connection refused")))
+}
+
+func TestManifestURL(t *testing.T) {
+	assert.Equal(t, "https://ghcr.io/v2/newrelic/agents/manifests/sha256:abc", ManifestURL("ghcr.io/newrelic/agents", "sha256:abc", false))
+	assert.Equal(t, "http://localhost:5000/v2/agents/manifests/sha256:abc", ManifestURL("localhost:5000/agents", "sha256:abc", true))
+}
+
+func TestAnnotateUploadResult(t *testing.T) {
+	t.Setenv("GITHUB_WORKFLOW_REF", "newrelic/java-agent/.github/workflows/release.yml@refs/heads/main")
+
+	t.Run("uploaded", func(t *testing.T) {
+		getStdout, _ := testutil.CaptureOutput(t)
+		result := models.ArtifactUploadResult{Name: "linux", Path: "./dist/agent.tar.gz", OS: "linux", Arch: "amd64", Digest: "sha256:abc", Size: 512, Uploaded: true}
+
+		// method under test
+		annotateUploadResult(context.Background(), "ghcr.io/newrelic/agents", false, "./dist/agent.tar.gz", result)
+
+		assert.Equal(t, "::notice file=dist/agent.tar.gz,title=Uploaded linux::Uploaded linux: ./dist/agent.tar.gz "+
+			"(os: linux, arch: amd64, digest: sha256:abc, manifest size: 512 bytes) https://ghcr.io/v2/newrelic/agents/manifests/sha256:abc\n", getStdout())
+	})
+
+	t.Run("failed with a registry error code", func(t *testing.T) {
+		getStdout, _ := testutil.CaptureOutput(t)
+		result := models.ArtifactUploadResult{Name: "linux", Path: "s3://bucket/agent.tar.gz", Error: "denied", ErrorCode: "DENIED (HTTP 403)"}
+
+		// method under test
+		annotateUploadResult(context.Background(), "ghcr.io/newrelic/agents", false, "s3://bucket/agent.tar.gz", result)
+
+		assert.Equal(t, "::error file=.github/workflows/release.yml,title=Upload failed%3A linux::Failed to upload linux (s3://bucket/agent.tar.gz), "+
+			"registry answered DENIED (HTTP 403): denied\n", getStdout())
+	})
+
+	t.Run("referenced manifest", func(t *testing.T) {
+		getStdout, _ := testutil.CaptureOutput(t)
+		result := models.ArtifactUploadResult{Name: "image", Path: "sha256:def", Digest: "sha256:def", MediaType: "application/vnd.oci.image.manifest.v1+json", Uploaded: true, Referenced: true}
+
+		// method under test
+		annotateUploadResult(context.Background(), "localhost:5000/agents", true, "", result)
+
+		stdout := getStdout()
+		assert.Contains(t, stdout, "::notice file=.github/workflows/release.yml,title=Referenced image::Referenced image: sha256:def")
+		assert.Contains(t, stdout, "http://localhost:5000/v2/agents/manifests/sha256:def")
+	})
+}
+
+func TestUploadArtifacts_RegistryErrorCode(t *testing.T) {
+	config := &models.OCIConfig{Artifacts: []models.ArtifactDefinition{{Name: "linux", Path: "./agent.tar.gz", OS: "linux", Arch: "amd64"}}}
+	mock := &mockClient{uploadFunc: func(ctx context.Context, artifact *models.ArtifactDefinition, artifactPath, version string) (string, int64, error) {
+		return "", 0, fmt.Errorf("failed to push artifact to registry: %w", &errcode.ErrorResponse{
+			StatusCode: http.StatusUnauthorized,
+			Errors:     errcode.Errors{{Code: errcode.ErrorCodeUnauthorized}},
+		})
+	}}
+
+	// method under test
+	results := UploadArtifacts(context.Background(), mock, config, "/workspace", "1.2.3")
+
+	assert.Len(t, results, 1)
+	assert.False(t, results[0].Uploaded)
+	assert.Equal(t, "UNAUTHORIZED (HTTP 401)", results[0].ErrorCode)
+}
@@ -1,20 +1,101 @@
 package oci
 
 import (
+	"agent-metadata-action/internal/config"
 	"agent-metadata-action/internal/models"
+	"strings"
 	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
+// LegalFilesAnnotation lists the archive entries of the required legal files found in a binary, comma separated
+const LegalFilesAnnotation = "com.newrelic.artifact.legal-files"
+
+// NotarizationAnnotation is the notarization status of the Mach-O binaries of a darwin binary, if checked
+const NotarizationAnnotation = "com.newrelic.artifact.notarization"
+
+// annotationBuilder builds the annotations of a layer, manifest or index, leaving out values that aren't known
+type annotationBuilder map[string]string
+
+func (b annotationBuilder) set(key, value string) annotationBuilder {
+	if value != "" {
+		b[key] = value
+	}
+	return b
+}
+
 func CreateLayerAnnotations(artifact *models.ArtifactDefinition, version string) map[string]string {
-	return map[string]string{
-		"org.opencontainers.image.title":   artifact.GetFilename(),
-		"org.opencontainers.image.version": version,
-		"com.newrelic.artifact.type":       "binary",
+	return annotationBuilder{}.
+		set(ocispec.AnnotationTitle, artifact.GetFilename()).
+		set(ocispec.AnnotationVersion, version).
+		set("com.newrelic.artifact.type", "binary").
+		set(LegalFilesAnnotation, strings.Join(artifact.LegalFiles, ",")).
+		set(NotarizationAnnotation, artifact.Notarization)
+}
+
+// releaseAnnotations returns the annotations shared by the manifests and the index of version, created at created
+// The retention class tells registry cleanup, such as cleanup mode, how long they should be kept
+func releaseAnnotations(version string, created time.Time) annotationBuilder {
+	return annotationBuilder{}.
+		set(ocispec.AnnotationCreated, created.UTC().Format(time.RFC3339)).
+		set(RetentionAnnotation, RetentionClass(version))
+}
+
+// CreateManifestAnnotations returns the annotations of the manifest of an artifact of version, created at created
+func CreateManifestAnnotations(version string, created time.Time) map[string]string {
+	return releaseAnnotations(version, created)
+}
+
+// CreateIndexAnnotations returns the annotations of the manifest index of version, created at created
+// The index also names the version and, when running in a repository, the commit and repository it was built from
+// and where its documentation is
+func CreateIndexAnnotations(version string, created time.Time) map[string]string {
+	repositoryURL := config.GetRepositoryURL()
+	var documentation string
+	if repositoryURL != "" {
+		documentation = repositoryURL + "#readme"
 	}
+	return releaseAnnotations(version, created).
+		set(ocispec.AnnotationVersion, version).
+		set(ocispec.AnnotationRevision, config.GetSHA()).
+		set(ocispec.AnnotationSource, repositoryURL).
+		set(ocispec.AnnotationDocumentation, documentation)
+}
+
+// Annotations tracing manifests and the index back to the workflow run that pushed them
+const (
+	BuildRepositoryAnnotation = "com.newrelic.build.repository"  // owner/repo the workflow ran in
+	BuildRunIDAnnotation      = "com.newrelic.build.run-id"      // workflow run ID
+	BuildRunAttemptAnnotation = "com.newrelic.build.run-attempt" // attempt of the run, incremented by re-runs
+	BuildWorkflowAnnotation   = "com.newrelic.build.workflow"    // workflow name
+	BuildRefAnnotation        = "com.newrelic.build.ref"         // ref that triggered the workflow, e.g. refs/tags/v1.2.3
+	BuildSHAAnnotation        = "com.newrelic.build.sha"         // commit the workflow ran on
+	BuildActorAnnotation      = "com.newrelic.build.actor"       // user that triggered the workflow
+)
+
+// CreateBuildAnnotations returns the build annotations of the GitHub context the action runs in
+// Values GitHub doesn't set, e.g. when running locally, are left out
+func CreateBuildAnnotations() map[string]string {
+	return annotationBuilder{}.
+		set(BuildRepositoryAnnotation, config.GetRepo()).
+		set(BuildRunIDAnnotation, config.GetRunID()).
+		set(BuildRunAttemptAnnotation, config.GetRunAttempt()).
+		set(BuildWorkflowAnnotation, config.GetWorkflowName()).
+		set(BuildRefAnnotation, config.GetRef()).
+		set(BuildSHAAnnotation, config.GetSHA()).
+		set(BuildActorAnnotation, config.GetActor())
 }
 
-func CreateManifestAnnotations() map[string]string {
-	return map[string]string{
-		"org.opencontainers.image.created": time.Now().UTC().Format(time.RFC3339),
+// addVendorAnnotations adds the vendor annotations to annotations, returning them
+// Vendor annotations replace the defaults the action derives, such as the source of the index, but never the keys
+// the action reserves
+func addVendorAnnotations(annotations, vendor map[string]string) map[string]string {
+	for key, value := range vendor {
+		if _, ok := annotations[key]; ok && models.IsReservedAnnotation(key) {
+			continue
+		}
+		annotations[key] = value
 	}
+	return annotations
 }
@@ -3,6 +3,7 @@ package oci
 import (
 	"agent-metadata-action/internal/models"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -25,10 +26,89 @@ func TestCreateLayerAnnotations(t *testing.T) {
 }
 
 func TestCreateManifestAnnotations(t *testing.T) {
-	annotations := CreateManifestAnnotations()
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	annotations := CreateManifestAnnotations("1.2.3", created)
 
-	// Per specification, only the creation timestamp is included at manifest level
-	assert.Len(t, annotations, 1, "Manifest should only have one annotation")
+	// Per specification, only the creation timestamp and retention class are included at manifest level
+	assert.Len(t, annotations, 2, "Manifest should only have two annotations")
 	assert.Contains(t, annotations, "org.opencontainers.image.created")
-	assert.NotEmpty(t, annotations["org.opencontainers.image.created"])
+	assert.Equal(t, "2024-05-01T10:00:00Z", annotations["org.opencontainers.image.created"])
+	assert.Equal(t, "release", annotations["com.newrelic.retention.class"])
+
+	assert.Equal(t, "prerelease", CreateManifestAnnotations("1.2.3-beta.1", created)["com.newrelic.retention.class"])
+}
+
+func TestCreateIndexAnnotations(t *testing.T) {
+	t.Setenv("GITHUB_REPOSITORY", "")
+	t.Setenv("GITHUB_SHA", "")
+	annotations := CreateIndexAnnotations("1.2.3-rc1", time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+
+	assert.Equal(t, map[string]string{
+		"org.opencontainers.image.version": "1.2.3-rc1",
+		"org.opencontainers.image.created": "2024-05-01T10:00:00Z",
+		"com.newrelic.retention.class":     "prerelease",
+	}, annotations, "Unknown repository details are left out")
+
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/newrelic-java-agent")
+	t.Setenv("GITHUB_SHA", "0123456789abcdef0123456789abcdef01234567")
+	annotations = CreateIndexAnnotations("1.2.3", time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+
+	assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", annotations["org.opencontainers.image.revision"])
+	assert.Equal(t, "https://github.com/newrelic/newrelic-java-agent", annotations["org.opencontainers.image.source"])
+	assert.Equal(t, "https://github.com/newrelic/newrelic-java-agent#readme", annotations["org.opencontainers.image.documentation"])
+	assert.Equal(t, "2024-05-01T10:00:00Z", annotations["org.opencontainers.image.created"])
+	assert.NotContains(t, CreateManifestAnnotations("1.2.3", time.Now()), "org.opencontainers.image.source", "Only the index names the source")
+}
+
+func TestAnnotationBuilder(t *testing.T) {
+	// method under test
+	annotations := annotationBuilder{}.set("com.example.team", "apm-java").set("com.example.owner", "")
+
+	assert.Equal(t, annotationBuilder{"com.example.team": "apm-java"}, annotations)
+}
+
+func TestCreateBuildAnnotations(t *testing.T) {
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/newrelic-java-agent")
+	t.Setenv("GITHUB_RUN_ID", "123456")
+	t.Setenv("GITHUB_RUN_ATTEMPT", "2")
+	t.Setenv("GITHUB_WORKFLOW", "Release")
+	t.Setenv("GITHUB_REF", "refs/tags/v1.2.3")
+	t.Setenv("GITHUB_SHA", "0123456789abcdef0123456789abcdef01234567")
+	t.Setenv("GITHUB_ACTOR", "octocat")
+
+	// method under test
+	annotations := CreateBuildAnnotations()
+
+	assert.Equal(t, map[string]string{
+		"com.newrelic.build.repository":  "newrelic/newrelic-java-agent",
+		"com.newrelic.build.run-id":      "123456",
+		"com.newrelic.build.run-attempt": "2",
+		"com.newrelic.build.workflow":    "Release",
+		"com.newrelic.build.ref":         "refs/tags/v1.2.3",
+		"com.newrelic.build.sha":         "0123456789abcdef0123456789abcdef01234567",
+		"com.newrelic.build.actor":       "octocat",
+	}, annotations)
+
+	// Outside GitHub Actions, only what is set is annotated
+	t.Setenv("GITHUB_RUN_ID", "")
+	t.Setenv("GITHUB_RUN_ATTEMPT", "")
+	assert.NotContains(t, CreateBuildAnnotations(), BuildRunIDAnnotation)
+	assert.Len(t, CreateBuildAnnotations(), 5)
+}
+
+func TestAddVendorAnnotations(t *testing.T) {
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/newrelic-java-agent")
+	vendor := map[string]string{
+		"org.opencontainers.image.licenses": "Apache-2.0",
+		"org.opencontainers.image.version":  "9.9.9",
+		"org.opencontainers.image.source":   "https://example.com/agent.git",
+	}
+
+	// method under test
+	annotations := addVendorAnnotations(CreateIndexAnnotations("1.2.3", time.Now()), vendor)
+
+	assert.Equal(t, "Apache-2.0", annotations["org.opencontainers.image.licenses"])
+	assert.Equal(t, "1.2.3", annotations["org.opencontainers.image.version"], "Reserved annotations aren't replaced")
+	assert.Equal(t, "https://example.com/agent.git", annotations["org.opencontainers.image.source"], "Derived annotations are")
+	assert.Nil(t, addVendorAnnotations(nil, nil))
 }
@@ -0,0 +1,172 @@
+package oci
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"agent-metadata-action/internal/models"
+)
+
+// zstdReaderFunc opens the decompressed content of a zstd file with the zstd CLI, which is preinstalled on
+// GitHub-hosted runners
+// This allows tests to override the implementation
+var zstdReaderFunc = func(ctx context.Context, path string) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, "zstd", "-dc", path)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("the zstd command is not installed on the runner")
+		}
+		return nil, err
+	}
+	return &commandReader{ReadCloser: stdout, cmd: cmd}, nil
+}
+
+// commandReader reads the stdout of a command, waiting for it on Close
+type commandReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (r *commandReader) Close() error {
+	r.ReadCloser.Close()
+	// The command may be stopped by the closed pipe when the output isn't read to the end
+	r.cmd.Wait()
+	return nil
+}
+
+// archiveEntryFunc is called for each file in an archive, in archive order, with a reader of its content
+type archiveEntryFunc func(name string, size int64, content io.Reader) error
+
+// walkArchive calls fn for each regular file in the archive at archivePath, streaming the archive without
+// extracting it
+// Directories, links and other special entries are skipped
+func walkArchive(ctx context.Context, archivePath, format string, fn archiveEntryFunc) error {
+	if models.IsZipFormat(format) {
+		return walkZip(archivePath, fn)
+	}
+	return walkTar(ctx, archivePath, format, func(header *tar.Header, content io.Reader) error {
+		if header.Typeflag != tar.TypeReg {
+			return nil
+		}
+		return fn(header.Name, header.Size, content)
+	})
+}
+
+// walkTar calls fn for each entry of the tar archive at archivePath, compressed as format says, in archive order
+func walkTar(ctx context.Context, archivePath, format string, fn func(header *tar.Header, content io.Reader) error) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	switch strings.ToLower(format) {
+	case "tar":
+	case "tar+gzip":
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("not a gzip archive: %w", err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case models.FormatTarZstd:
+		zstdReader, err := zstdReaderFunc(ctx, archivePath)
+		if err != nil {
+			return err
+		}
+		defer zstdReader.Close()
+		reader = zstdReader
+	default:
+		return fmt.Errorf("unsupported archive format %q", format)
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar archive: %w", err)
+		}
+		if err := fn(header, tarReader); err != nil {
+			return err
+		}
+	}
+}
+
+func walkZip(archivePath string, fn archiveEntryFunc) error {
+	zipReader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to read zip archive: %w", err)
+	}
+	defer zipReader.Close()
+
+	for _, file := range zipReader.File {
+		if !file.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		if err := walkZipFile(file, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkZipFile calls fn with the content of a file in a zip archive, which is only decompressed if fn reads it
+func walkZipFile(file *zip.File, fn archiveEntryFunc) error {
+	content := &lazyReader{open: file.Open}
+	defer content.Close()
+	return fn(file.Name, int64(file.UncompressedSize64), content)
+}
+
+// lazyReader opens its underlying reader on the first Read
+type lazyReader struct {
+	open   func() (io.ReadCloser, error)
+	reader io.ReadCloser
+}
+
+func (r *lazyReader) Read(p []byte) (int, error) {
+	if r.reader == nil {
+		reader, err := r.open()
+		if err != nil {
+			return 0, err
+		}
+		r.reader = reader
+	}
+	return r.reader.Read(p)
+}
+
+func (r *lazyReader) Close() error {
+	if r.reader == nil {
+		return nil
+	}
+	return r.reader.Close()
+}
+
+// ListArchiveEntries returns the paths of the files in the archive at archivePath, in archive order
+// Only the tar headers or zip central directory are read; nothing is extracted
+func ListArchiveEntries(ctx context.Context, archivePath, format string) ([]string, error) {
+	var entries []string
+	err := walkArchive(ctx, archivePath, format, func(name string, size int64, content io.Reader) error {
+		entries = append(entries, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
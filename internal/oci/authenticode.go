@@ -0,0 +1,212 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	"agent-metadata-action/internal/github"
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/validation"
+)
+
+// winCertTypePKCSSignedData is the WIN_CERTIFICATE type of an Authenticode signature
+const winCertTypePKCSSignedData = 0x0002
+
+// cfbSignature starts every compound file, the container format of MSI packages
+var cfbSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// msiSignatureStream is the name, in UTF-16LE, of the stream holding the Authenticode signature of an MSI package
+var msiSignatureStream = utf16LE("\x05DigitalSignature")
+
+// IsWindowsBinary reports whether an archive entry is a Windows executable, library or installer, whose Authenticode
+// signature is checked
+func IsWindowsBinary(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".exe", ".dll", ".msi":
+		return true
+	}
+	return false
+}
+
+// HasAuthenticodeSignature reports whether the Windows binary name, read from r, carries an Authenticode signature
+// PE files (.exe, .dll) are signed when the security directory of their optional header points at a PKCS#7
+// certificate; MSI packages when their compound file has a \x05DigitalSignature stream
+// Only the presence of a signature is checked; it isn't verified, which Windows does at install time
+func HasAuthenticodeSignature(r io.ReaderAt, name string) (bool, error) {
+	if strings.EqualFold(path.Ext(name), ".msi") {
+		return hasMSISignature(r)
+	}
+	return hasPESignature(r)
+}
+
+func hasPESignature(r io.ReaderAt) (bool, error) {
+	file, err := pe.NewFile(r)
+	if err != nil {
+		return false, fmt.Errorf("not a PE file: %w", err)
+	}
+	defer file.Close()
+
+	var dirs [16]pe.DataDirectory
+	var dirCount uint32
+	switch header := file.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		dirs, dirCount = header.DataDirectory, header.NumberOfRvaAndSizes
+	case *pe.OptionalHeader64:
+		dirs, dirCount = header.DataDirectory, header.NumberOfRvaAndSizes
+	default:
+		return false, fmt.Errorf("PE file has no optional header")
+	}
+	if dirCount <= pe.IMAGE_DIRECTORY_ENTRY_SECURITY {
+		return false, nil
+	}
+	// The security directory holds a file offset, not a virtual address, to the WIN_CERTIFICATE structure
+	security := dirs[pe.IMAGE_DIRECTORY_ENTRY_SECURITY]
+	if security.VirtualAddress == 0 || security.Size < 8 {
+		return false, nil
+	}
+	certificate := make([]byte, 8)
+	if _, err := r.ReadAt(certificate, int64(security.VirtualAddress)); err != nil {
+		return false, fmt.Errorf("failed to read the certificate table: %w", err)
+	}
+	length := binary.LittleEndian.Uint32(certificate[0:4])
+	certType := binary.LittleEndian.Uint16(certificate[6:8])
+	return length > 8 && certType == winCertTypePKCSSignedData, nil
+}
+
+// hasMSISignature looks for the signature stream among the directory entries of the compound file
+// Directory entries are 128 bytes, aligned on 128 bytes, and start with their UTF-16LE name followed by its length in
+// bytes and the entry type, 2 for a stream; the FAT chain of the directory isn't followed
+func hasMSISignature(r io.ReaderAt) (bool, error) {
+	header := make([]byte, len(cfbSignature))
+	if _, err := r.ReadAt(header, 0); err != nil || !bytes.Equal(header, cfbSignature) {
+		return false, fmt.Errorf("not an MSI package")
+	}
+
+	entry := make([]byte, 128)
+	for offset := int64(512); ; offset += 128 {
+		if _, err := r.ReadAt(entry, offset); err != nil {
+			if err == io.EOF {
+				return false, nil
+			}
+			return false, err
+		}
+		nameLength := int(binary.LittleEndian.Uint16(entry[64:66]))
+		if nameLength == len(msiSignatureStream)+2 && entry[66] == 2 && bytes.Equal(entry[:len(msiSignatureStream)], msiSignatureStream) {
+			return true, nil
+		}
+	}
+}
+
+func utf16LE(s string) []byte {
+	var b []byte
+	for _, unit := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, unit)
+	}
+	return b
+}
+
+// CheckAuthenticode checks every Windows binary in the archives of artifacts with RequireAuthenticode set, found
+// relative to workspacePath, is Authenticode signed
+// Each binary is spooled to a temporary file to read its headers; returns every artifact with unsigned binaries
+// together as validation.Errors
+func CheckAuthenticode(ctx context.Context, workspacePath string, artifacts []models.ArtifactDefinition) error {
+	var errs validation.Errors
+	for i, artifact := range artifacts {
+		if !artifact.RequireAuthenticode || artifact.IsReference() {
+			continue
+		}
+		archivePath, err := ResolveArtifactPath(workspacePath, artifact.Path)
+		if err != nil {
+			return err
+		}
+
+		var checked int
+		var unsigned []string
+		if artifact.IsMSI() {
+			checked, unsigned, err = checkMSIAuthenticode(archivePath)
+		} else {
+			checked, unsigned, err = checkArchiveAuthenticode(ctx, archivePath, artifact.Format)
+		}
+		if err != nil {
+			errs.Add("", 0, fmt.Sprintf("binaries[%d]", i), fmt.Errorf("failed to check the Authenticode signatures of artifact '%s': %w", artifact.Name, err))
+			continue
+		}
+		if len(unsigned) > 0 {
+			err := fmt.Errorf("artifact '%s' has Windows binaries without an Authenticode signature: %s", artifact.Name, strings.Join(unsigned, ", "))
+			github.AddWorkflowAnnotation(ctx, github.AnnotationFailure, "Unsigned Windows binaries", err.Error())
+			errs.Add("", 0, fmt.Sprintf("binaries[%d]", i), err)
+			continue
+		}
+		if checked == 0 {
+			logging.Warnf(ctx, "Artifact '%s' requires Authenticode signatures but contains no .exe, .dll or .msi files", artifact.Name)
+			continue
+		}
+		logging.Debugf(ctx, "Artifact '%s' has Authenticode signatures on its %d Windows binaries", artifact.Name, checked)
+	}
+	return errs.Err()
+}
+
+// checkMSIAuthenticode checks the MSI package at packagePath itself, returning it as unsigned if it has no signature
+func checkMSIAuthenticode(packagePath string) (int, []string, error) {
+	file, err := os.Open(packagePath)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+
+	signed, err := hasMSISignature(file)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !signed {
+		return 1, []string{filepath.Base(packagePath)}, nil
+	}
+	return 1, nil, nil
+}
+
+// checkArchiveAuthenticode returns the number of Windows binaries in the archive and those without a signature
+func checkArchiveAuthenticode(ctx context.Context, archivePath, format string) (int, []string, error) {
+	spoolDir, err := os.MkdirTemp("", "agent-authenticode-")
+	if err != nil {
+		return 0, nil, err
+	}
+	defer os.RemoveAll(spoolDir)
+
+	checked := 0
+	var unsigned []string
+	err = walkArchive(ctx, archivePath, format, func(name string, size int64, content io.Reader) error {
+		if !IsWindowsBinary(name) {
+			return nil
+		}
+		checked++
+		spoolPath := filepath.Join(spoolDir, "binary")
+		if err := writeFile(spoolPath, content); err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		file, err := os.Open(spoolPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		signed, err := HasAuthenticodeSignature(file, name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if !signed {
+			unsigned = append(unsigned, name)
+		}
+		return nil
+	})
+	return checked, unsigned, err
+}
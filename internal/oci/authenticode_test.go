@@ -0,0 +1,149 @@
+package oci
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"debug/pe"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/testutil"
+	"agent-metadata-action/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildPE returns a PE32+ file with no sections, with a WIN_CERTIFICATE of certType after its headers when signed
+func buildPE(t *testing.T, signed bool, certType uint16) []byte {
+	var buf bytes.Buffer
+	dosHeader := make([]byte, 0x40)
+	copy(dosHeader, "MZ")
+	binary.LittleEndian.PutUint32(dosHeader[0x3c:], 0x40)
+	buf.Write(dosHeader)
+	buf.WriteString("PE\x00\x00")
+
+	optionalHeader := pe.OptionalHeader64{Magic: 0x20b, NumberOfRvaAndSizes: 16}
+	fileHeader := pe.FileHeader{Machine: pe.IMAGE_FILE_MACHINE_AMD64, SizeOfOptionalHeader: uint16(binary.Size(optionalHeader))}
+	certOffset := uint32(buf.Len() + binary.Size(fileHeader) + binary.Size(optionalHeader))
+	if signed {
+		optionalHeader.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_SECURITY] = pe.DataDirectory{VirtualAddress: certOffset, Size: 16}
+	}
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, fileHeader))
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, optionalHeader))
+	if signed {
+		require.NoError(t, binary.Write(&buf, binary.LittleEndian, []uint32{16}))
+		require.NoError(t, binary.Write(&buf, binary.LittleEndian, []uint16{0x0200, certType}))
+		buf.Write(make([]byte, 8))
+	}
+	return buf.Bytes()
+}
+
+// buildMSI returns a compound file whose first directory sector holds streams named names
+func buildMSI(names ...string) []byte {
+	file := make([]byte, 512+4*128)
+	copy(file, cfbSignature)
+	for i, name := range names {
+		entry := file[512+i*128:]
+		encoded := utf16LE(name)
+		copy(entry, encoded)
+		binary.LittleEndian.PutUint16(entry[64:], uint16(len(encoded)+2))
+		entry[66] = 2
+	}
+	return file
+}
+
+func TestHasAuthenticodeSignature(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  []byte
+		expected bool
+		errorMsg string
+	}{
+		{name: "signed exe", file: "agent.exe", content: buildPE(t, true, winCertTypePKCSSignedData), expected: true},
+		{name: "unsigned dll", file: "agent.dll", content: buildPE(t, false, 0)},
+		{name: "non-PKCS certificate", file: "agent.exe", content: buildPE(t, true, 0x0001)},
+		{name: "signed msi", file: "agent.MSI", content: buildMSI("\x05SummaryInformation", "\x05DigitalSignature"), expected: true},
+		{name: "unsigned msi", file: "agent.msi", content: buildMSI("\x05SummaryInformation")},
+		{name: "not a PE file", file: "agent.exe", content: []byte("#!/bin/sh"), errorMsg: "not a PE file"},
+		{name: "not an MSI package", file: "agent.msi", content: buildPE(t, true, winCertTypePKCSSignedData), errorMsg: "not an MSI package"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			signed, err := HasAuthenticodeSignature(bytes.NewReader(tt.content), tt.file)
+
+			if tt.errorMsg != "" {
+				assert.ErrorContains(t, err, tt.errorMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, signed)
+		})
+	}
+}
+
+func TestCheckAuthenticode(t *testing.T) {
+	writeZip := func(t *testing.T, path string, files map[string][]byte) {
+		var buf bytes.Buffer
+		zipWriter := zip.NewWriter(&buf)
+		for name, content := range files {
+			w, err := zipWriter.Create(name)
+			require.NoError(t, err)
+			w.Write(content)
+		}
+		require.NoError(t, zipWriter.Close())
+		require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+	}
+
+	workspace := t.TempDir()
+	writeZip(t, filepath.Join(workspace, "signed.zip"), map[string][]byte{
+		"newrelic/agent.exe":    buildPE(t, true, winCertTypePKCSSignedData),
+		"newrelic/lib/core.DLL": buildPE(t, true, winCertTypePKCSSignedData),
+		"newrelic/README.txt":   []byte("not a binary"),
+	})
+	writeZip(t, filepath.Join(workspace, "unsigned.zip"), map[string][]byte{
+		"newrelic/agent.exe":  buildPE(t, true, winCertTypePKCSSignedData),
+		"newrelic/plugin.dll": buildPE(t, false, 0),
+	})
+	writeZip(t, filepath.Join(workspace, "corrupt.zip"), map[string][]byte{"newrelic/agent.exe": []byte("truncated")})
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "signed.msi"), buildMSI("\x05SummaryInformation", "\x05DigitalSignature"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "unsigned.msi"), buildMSI("\x05SummaryInformation"), 0o644))
+
+	t.Run("signed binaries", func(t *testing.T) {
+		artifacts := []models.ArtifactDefinition{
+			{Name: "windows", Path: "./signed.zip", Format: "zip", OS: "windows", RequireAuthenticode: true},
+			{Name: "windows-unchecked", Path: "./unsigned.zip", Format: "zip", OS: "windows"},
+			{Name: "windows-msi", Path: "./signed.msi", Format: "msi", OS: "windows", RequireAuthenticode: true},
+		}
+
+		// method under test
+		assert.NoError(t, CheckAuthenticode(context.Background(), workspace, artifacts))
+	})
+
+	t.Run("reports every artifact with unsigned binaries", func(t *testing.T) {
+		artifacts := []models.ArtifactDefinition{
+			{Name: "windows", Path: "./signed.zip", Format: "zip", OS: "windows", RequireAuthenticode: true},
+			{Name: "windows-plugin", Path: "./unsigned.zip", Format: "zip", OS: "windows", RequireAuthenticode: true},
+			{Name: "windows-corrupt", Path: "./corrupt.zip", Format: "zip", OS: "windows", RequireAuthenticode: true},
+			{Name: "windows-msi", Path: "./unsigned.msi", Format: "msi", OS: "windows", RequireAuthenticode: true},
+		}
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := CheckAuthenticode(context.Background(), workspace, artifacts)
+
+		var errs validation.Errors
+		require.ErrorAs(t, err, &errs)
+		require.Len(t, errs, 3)
+		assert.Contains(t, errs[0].Error(), "binaries[1]: artifact 'windows-plugin' has Windows binaries without an Authenticode signature: newrelic/plugin.dll")
+		assert.Contains(t, errs[1].Error(), "binaries[2]: failed to check the Authenticode signatures of artifact 'windows-corrupt': newrelic/agent.exe: not a PE file")
+		assert.Contains(t, errs[2].Error(), "binaries[3]: artifact 'windows-msi' has Windows binaries without an Authenticode signature: unsigned.msi")
+	})
+}
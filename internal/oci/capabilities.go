@@ -0,0 +1,191 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"agent-metadata-action/internal/logging"
+
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// zeroDigest is the subject the referrers API is probed with; no manifest has it
+const zeroDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000000"
+
+// emptyIndex is the image index pushed to check the registry accepts indexes
+// It has no manifests, so every probe pushes the same untagged index and at most one is left behind
+var emptyIndex = []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`)
+
+// Capabilities are the registry features an upload relies on
+// The distribution API doesn't advertise a maximum blob size, so a registry that rejects large blobs is still only
+// found out by the upload itself
+type Capabilities struct {
+	Referrers      bool  // OCI 1.1 referrers API; without it referrers are listed through sha256-<digest> tags
+	Index          bool  // accepts OCI image indexes, which the version tag points to
+	BlobUpload     bool  // accepts blob uploads to the repository
+	ChunkMinLength int64 // smallest chunk of a chunked blob upload, from OCI-Chunk-Min-Length; 0 if not advertised
+}
+
+// ProbeCapabilities checks what the registry supports before anything is pushed, so an upload fails up front
+// instead of after pushing every binary when, for example, the multi-platform index is rejected
+// The repository is set to list referrers through the tag schema when the referrers API is missing
+// An empty, untagged index is left in the repository by the index probe; registries garbage collect it
+// Targets without a distribution API to probe, such as in-memory registries, are taken to support referrers and blob
+// uploads
+func (c *Client) ProbeCapabilities(ctx context.Context) (Capabilities, error) {
+	capabilities := Capabilities{Referrers: true, BlobUpload: true}
+	var problems []string
+
+	if repo, ok := c.repo.(*remote.Repository); ok {
+		referrers, err := probeReferrers(ctx, repo)
+		if err != nil {
+			return capabilities, fmt.Errorf("unable to probe the referrers API of %s: %w", c.registry, err)
+		}
+		capabilities.Referrers = referrers
+		if err := repo.SetReferrersCapability(referrers); err != nil {
+			return capabilities, fmt.Errorf("unable to configure referrers for %s: %w", c.registry, err)
+		}
+
+		chunkMinLength, err := probeBlobUpload(ctx, repo)
+		if err != nil {
+			capabilities.BlobUpload = false
+			problems = append(problems, fmt.Sprintf("blob uploads (%v)", err))
+		} else {
+			capabilities.ChunkMinLength = chunkMinLength
+		}
+	}
+
+	indexDesc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageIndex,
+		Digest:    digest.FromBytes(emptyIndex),
+		Size:      int64(len(emptyIndex)),
+	}
+	if err := c.repo.Push(ctx, indexDesc, bytes.NewReader(emptyIndex)); err != nil {
+		problems = append(problems, fmt.Sprintf("OCI image indexes (%v)", err))
+	} else {
+		capabilities.Index = true
+	}
+
+	logging.Debugf(ctx, "Registry %s capabilities: referrers=%v, index=%v, blobUpload=%v, chunkMinLength=%d",
+		c.registry, capabilities.Referrers, capabilities.Index, capabilities.BlobUpload, capabilities.ChunkMinLength)
+	if len(problems) > 0 {
+		return capabilities, fmt.Errorf("registry %s doesn't support %s", c.registry, strings.Join(problems, "; "))
+	}
+	return capabilities, nil
+}
+
+// ProbeLayerMediaType checks the registry accepts manifests with layers of mediaType, such as the zstd tarballs of
+// tar+zstd artifacts, which registries validating layer media types against a list of known ones reject
+// An untagged manifest whose only layer is the empty JSON blob is pushed; registries garbage collect it
+func (c *Client) ProbeLayerMediaType(ctx context.Context, mediaType string) error {
+	if err := c.repo.Push(ctx, ocispec.DescriptorEmptyJSON, bytes.NewReader(ocispec.DescriptorEmptyJSON.Data)); err != nil {
+		return fmt.Errorf("failed to push the empty JSON blob: %w", err)
+	}
+
+	layer := ocispec.DescriptorEmptyJSON
+	layer.MediaType = mediaType
+	layer.Data = nil
+	config := ocispec.DescriptorEmptyJSON
+	config.Data = nil
+	manifest, err := json.Marshal(ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{layer},
+	})
+	if err != nil {
+		return err
+	}
+	manifestDesc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    digest.FromBytes(manifest),
+		Size:      int64(len(manifest)),
+	}
+	if err := c.repo.Push(ctx, manifestDesc, bytes.NewReader(manifest)); err != nil {
+		return fmt.Errorf("registry %s doesn't accept %s layers: %w", c.registry, mediaType, err)
+	}
+	return nil
+}
+
+// probeReferrers reports whether the registry implements the OCI 1.1 referrers API
+// GET /v2/<repository>/referrers/<digest>, which answers with an image index when supported and 404 otherwise
+func probeReferrers(ctx context.Context, repo *remote.Repository) (bool, error) {
+	ref := repo.Reference
+	ref.Reference = zeroDigest
+	ctx = auth.AppendRepositoryScope(ctx, ref, auth.ActionPull)
+
+	resp, err := do(ctx, repo, http.MethodGet, repositoryURL(repo)+"/referrers/"+zeroDigest)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Header.Get("Content-Type") == ocispec.MediaTypeImageIndex, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, responseError(resp)
+	}
+}
+
+// probeBlobUpload starts a blob upload session and cancels it, returning the minimum chunk length the registry
+// advertises for chunked uploads
+// POST /v2/<repository>/blobs/uploads/, then DELETE of the session location
+func probeBlobUpload(ctx context.Context, repo *remote.Repository) (int64, error) {
+	ctx = auth.AppendRepositoryScope(ctx, repo.Reference, auth.ActionPull, auth.ActionPush)
+
+	resp, err := do(ctx, repo, http.MethodPost, repositoryURL(repo)+"/blobs/uploads/")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return 0, responseError(resp)
+	}
+
+	// Abandoned sessions expire, so a failed cancel is harmless
+	if location, err := resp.Location(); err == nil {
+		if cancelResp, err := do(ctx, repo, http.MethodDelete, location.String()); err == nil {
+			cancelResp.Body.Close()
+		}
+	}
+
+	chunkMinLength, _ := strconv.ParseInt(resp.Header.Get("OCI-Chunk-Min-Length"), 10, 64)
+	return chunkMinLength, nil
+}
+
+// repositoryURL returns the base URL of the repository API, <scheme>://<registry>/v2/<repository>
+func repositoryURL(repo *remote.Repository) string {
+	scheme := "https"
+	if repo.PlainHTTP {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s", scheme, repo.Reference.Host(), repo.Reference.Repository)
+}
+
+// do sends a request without a body through the authenticating client of the repository
+func do(ctx context.Context, repo *remote.Repository, method, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return repo.Client.Do(req)
+}
+
+// responseError describes an unexpected registry response, including the start of its error body
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s %s returned status %d: %s", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, strings.TrimSpace(string(body)))
+}
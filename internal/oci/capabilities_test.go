@@ -0,0 +1,162 @@
+package oci
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+// fakeRegistry serves the endpoints probed for capabilities, answering as configured
+type fakeRegistry struct {
+	referrers   bool
+	uploads     bool
+	indexes     bool
+	layerTypes  bool // accepts image manifests whatever their layer media types
+	cancelled   bool
+	pushedIndex bool
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v2/agents/referrers/"):
+		if !f.referrers {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", ocispec.MediaTypeImageIndex)
+		w.Write([]byte(`{"schemaVersion":2,"manifests":[]}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/v2/agents/blobs/uploads/":
+		if !f.uploads {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":[{"code":"DENIED","message":"requested access to the resource is denied"}]}`))
+			return
+		}
+		w.Header().Set("Location", "/v2/agents/blobs/uploads/session-1")
+		w.Header().Set("OCI-Chunk-Min-Length", "5242880")
+		w.WriteHeader(http.StatusAccepted)
+	case r.Method == http.MethodDelete && r.URL.Path == "/v2/agents/blobs/uploads/session-1":
+		f.cancelled = true
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut && r.URL.Path == "/v2/agents/blobs/uploads/session-1":
+		w.Header().Set("Docker-Content-Digest", r.URL.Query().Get("digest"))
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/v2/agents/manifests/") && r.Header.Get("Content-Type") == ocispec.MediaTypeImageManifest:
+		if !f.layerTypes {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"code":"MANIFEST_INVALID","message":"unknown layer media type"}]}`))
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", strings.TrimPrefix(r.URL.Path, "/v2/agents/manifests/"))
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/v2/agents/manifests/"):
+		if !f.indexes || r.Header.Get("Content-Type") != ocispec.MediaTypeImageIndex {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"code":"MANIFEST_INVALID","message":"manifest invalid"}]}`))
+			return
+		}
+		f.pushedIndex = true
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(emptyIndex).String())
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestProbeCapabilities(t *testing.T) {
+	registry := &fakeRegistry{referrers: true, uploads: true, indexes: true}
+	server := httptest.NewServer(registry)
+	defer server.Close()
+
+	client, err := NewClient(context.Background(), strings.TrimPrefix(server.URL, "http://")+"/agents", "", "", Connection{})
+	require.NoError(t, err)
+
+	// method under test
+	capabilities, err := client.ProbeCapabilities(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, Capabilities{Referrers: true, Index: true, BlobUpload: true, ChunkMinLength: 5242880}, capabilities)
+	assert.True(t, registry.cancelled, "the probe upload session should be cancelled")
+	assert.True(t, registry.pushedIndex)
+}
+
+func TestProbeCapabilities_NoReferrersAPI(t *testing.T) {
+	server := httptest.NewServer(&fakeRegistry{uploads: true, indexes: true})
+	defer server.Close()
+
+	client, err := NewClient(context.Background(), strings.TrimPrefix(server.URL, "http://")+"/agents", "", "", Connection{})
+	require.NoError(t, err)
+
+	// method under test
+	capabilities, err := client.ProbeCapabilities(context.Background())
+
+	// Referrers fall back to the tag schema rather than failing the upload
+	require.NoError(t, err)
+	assert.False(t, capabilities.Referrers)
+	assert.Error(t, client.repo.(*remote.Repository).SetReferrersCapability(true), "the repository should be set to use the tag schema")
+}
+
+func TestProbeCapabilities_Missing(t *testing.T) {
+	server := httptest.NewServer(&fakeRegistry{referrers: true})
+	defer server.Close()
+
+	registry := strings.TrimPrefix(server.URL, "http://") + "/agents"
+	client, err := NewClient(context.Background(), registry, "", "", Connection{})
+	require.NoError(t, err)
+
+	// method under test
+	capabilities, err := client.ProbeCapabilities(context.Background())
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "registry "+registry+" doesn't support blob uploads (POST /v2/agents/blobs/uploads/ returned status 403")
+	assert.Contains(t, err.Error(), "; OCI image indexes (")
+	assert.False(t, capabilities.BlobUpload)
+	assert.False(t, capabilities.Index)
+}
+
+func TestProbeLayerMediaType(t *testing.T) {
+	const zstdMediaType = "application/vnd.newrelic.agent.content.v1.tar+zstd"
+
+	t.Run("accepted", func(t *testing.T) {
+		server := httptest.NewServer(&fakeRegistry{uploads: true, layerTypes: true})
+		defer server.Close()
+		client, err := NewClient(context.Background(), strings.TrimPrefix(server.URL, "http://")+"/agents", "", "", Connection{})
+		require.NoError(t, err)
+
+		// method under test
+		err = client.ProbeLayerMediaType(context.Background(), zstdMediaType)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		server := httptest.NewServer(&fakeRegistry{uploads: true})
+		defer server.Close()
+		registry := strings.TrimPrefix(server.URL, "http://") + "/agents"
+		client, err := NewClient(context.Background(), registry, "", "", Connection{})
+		require.NoError(t, err)
+
+		// method under test
+		err = client.ProbeLayerMediaType(context.Background(), zstdMediaType)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "registry "+registry+" doesn't accept "+zstdMediaType+" layers")
+	})
+}
+
+func TestProbeCapabilities_NoDistributionAPI(t *testing.T) {
+	client := &Client{repo: newMemoryRegistry(), registry: "ghcr.io/newrelic/agents"}
+
+	// method under test
+	capabilities, err := client.ProbeCapabilities(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, Capabilities{Referrers: true, Index: true, BlobUpload: true}, capabilities)
+}
@@ -1,84 +1,184 @@
 package oci
 
 import (
+	"agent-metadata-action/internal/github"
 	"agent-metadata-action/internal/logging"
 	"agent-metadata-action/internal/models"
 	"agent-metadata-action/internal/retry"
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"os"
+	"net/http"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"oras.land/oras-go/v2"
-	"oras.land/oras-go/v2/content/file"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry"
 	"oras.land/oras-go/v2/registry/remote"
 	"oras.land/oras-go/v2/registry/remote/auth"
 )
 
+// Docker media types produced by builders such as docker/build-push-action
+const (
+	dockerManifestMediaType     = "application/vnd.docker.distribution.manifest.v2+json"
+	dockerManifestListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// RegistryTarget is the repository a Client pushes to, tags in and reads from
+// It's a remote repository in a run; being an oras.Target, oras.Copy copies to and from it
+type RegistryTarget interface {
+	oras.Target // Push, Exists, Fetch, Resolve and Tag
+	registry.ReferencePusher
+	registry.ReferrerLister
+	registry.TagLister
+	content.Deleter
+}
+
 type Client struct {
-	repo     *remote.Repository
-	registry string
+	repo        RegistryTarget
+	registry    string
+	maxBlobSize int64 // artifacts larger than this are uploaded in parts; 0 for no limit
+
+	// How the client was created, to reach other repositories of the same registry, e.g. for image imports
+	username string
+	password string
+	conn     Connection
+
+	created time.Time // stamped on manifests and the index, so they match the upload plan; zero for now
+
+	annotations map[string]string // vendor annotations added to every layer, manifest and index pushed
+	build       map[string]string // build annotations of the workflow run, added to manifests and the index
+
+	// Artifacts hashed and blobs pushed in this run, so identical artifacts are read and pushed once
+	mu     sync.Mutex
+	hashed map[string]hashedArtifact
+	pushed map[digest.Digest]bool
+}
+
+// Connection relaxes how the registry is reached, for ephemeral registries without a trusted certificate such as
+// the in-cluster registries of e2e pipelines
+type Connection struct {
+	PlainHTTP             bool // talk HTTP instead of HTTPS; always done for localhost registries
+	InsecureSkipTLSVerify bool // accept any certificate the registry presents
 }
 
-func NewClient(ctx context.Context, registry, username, password string) (*Client, error) {
+// ConnectionFor returns the connection the OCI configuration asks for
+func ConnectionFor(ociConfig *models.OCIConfig) Connection {
+	return Connection{PlainHTTP: ociConfig.PlainHTTP, InsecureSkipTLSVerify: ociConfig.InsecureSkipTLSVerify}
+}
+
+// IsLocalRegistry reports whether registry runs on the runner itself, which is talked to over plain HTTP
+func IsLocalRegistry(registry string) bool {
+	return strings.HasPrefix(registry, "localhost:") || strings.HasPrefix(registry, "127.0.0.1:")
+}
+
+// newClientFunc is a variable that holds the function the handlers create their clients with
+// This allows tests to back the clients with an in-memory registry
+var newClientFunc = NewClient
+
+func NewClient(ctx context.Context, registry, username, password string, conn Connection) (*Client, error) {
 	repo, err := remote.NewRepository(registry)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OCI repository: %w", err)
 	}
 
-	// Extract registry host for auth (e.g., "docker.io" from "docker.io/user/repo")
-	registryHost := strings.Split(registry, "/")[0]
-	if registryHost == "" {
-		registryHost = "docker.io"
-	}
-
-	repo.Client = &auth.Client{
-		Credential: auth.StaticCredential(registryHost, auth.Credential{
+	authClient := &auth.Client{
+		Credential: auth.StaticCredential(registryHost(registry), auth.Credential{
 			Username: username,
 			Password: password,
 		}),
 	}
-
-	if strings.HasPrefix(registry, "localhost:") || strings.HasPrefix(registry, "127.0.0.1:") {
-		repo.PlainHTTP = true
+	if conn.InsecureSkipTLSVerify {
+		authClient.Client = insecureHTTPClient()
 	}
+	repo.Client = authClient
 
-	logging.Debugf(ctx, "OCI client configured: registry=%s, plainHTTP=%v", registry, repo.PlainHTTP)
+	repo.PlainHTTP = conn.PlainHTTP || IsLocalRegistry(registry)
+
+	logging.Debugf(ctx, "OCI client configured: registry=%s, plainHTTP=%v, insecureSkipTLSVerify=%v", registry, repo.PlainHTTP, conn.InsecureSkipTLSVerify)
 
 	return &Client{
 		repo:     repo,
 		registry: registry,
+		username: username,
+		password: password,
+		conn:     conn,
 	}, nil
 }
 
-func (c *Client) UploadArtifact(ctx context.Context, artifact *models.ArtifactDefinition, artifactPath, version string) (string, int64, error) {
-	tempDir, err := os.MkdirTemp("", "oras-upload-*")
-	if err != nil {
-		return "", 0, retry.NewNonRetryableError(fmt.Errorf("failed to create temp directory: %w", err))
+// registryHost returns the host of a repository, e.g. "docker.io" from "docker.io/user/repo"
+func registryHost(repository string) string {
+	host := strings.Split(repository, "/")[0]
+	if host == "" {
+		return "docker.io"
 	}
-	defer os.RemoveAll(tempDir)
+	return host
+}
 
-	fs, err := file.New(tempDir)
-	if err != nil {
-		return "", 0, retry.NewNonRetryableError(fmt.Errorf("failed to create file store: %w", err))
+// insecureHTTPClient returns an HTTP client that doesn't verify TLS certificates
+// The default transport is cloned so proxy settings and timeouts still apply
+func insecureHTTPClient() *http.Client {
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if defaultTransport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport = defaultTransport.Clone()
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.InsecureSkipVerify = true // #nosec G402 -- opted into with oci-insecure-skip-tls-verify
+	return &http.Client{Transport: transport}
+}
+
+// WarnInsecureConnection warns, in the log and on the workflow, that the registry is reached without TLS or without
+// verifying its certificate, so a relaxation meant for a test pipeline isn't left in a release workflow unnoticed
+func WarnInsecureConnection(ctx context.Context, registry string, conn Connection) {
+	if conn.PlainHTTP {
+		message := fmt.Sprintf("oci-plain-http is set: binaries are uploaded to %s over plain HTTP, unencrypted and with the registry credentials in the clear. Only use it for ephemeral test registries.", registry)
+		logging.Warn(ctx, message)
+		github.AddWorkflowAnnotation(ctx, github.AnnotationWarning, "OCI registry over plain HTTP", message)
+	}
+	if conn.InsecureSkipTLSVerify {
+		message := fmt.Sprintf("oci-insecure-skip-tls-verify is set: the TLS certificate of %s isn't verified, so binaries could be uploaded to an impersonating registry. Only use it for ephemeral test registries.", registry)
+		logging.Warn(ctx, message)
+		github.AddWorkflowAnnotation(ctx, github.AnnotationWarning, "OCI registry certificate not verified", message)
 	}
-	defer fs.Close()
+}
 
-	layerAnnotations := CreateLayerAnnotations(artifact, version)
+// packArtifact builds the manifest of an artifact of version locally, without reaching the registry, returning the
+// store to push it from, its descriptor and its layers
+// Layers are pushed straight from the artifact file, so uploading needs no copy of it on disk
+func (c *Client) packArtifact(ctx context.Context, artifact *models.ArtifactDefinition, artifactPath, version string) (*sourceStore, ocispec.Descriptor, []ocispec.Descriptor, error) {
+	fs := newSourceStore()
 
-	layerDesc, err := fs.Add(ctx, artifact.Name, artifact.GetMediaType(), artifactPath)
+	sections, artifactDigest, err := c.artifactSections(artifactPath)
 	if err != nil {
-		return "", 0, retry.NewNonRetryableError(fmt.Errorf("failed to add file to store: %w", err))
+		return nil, ocispec.Descriptor{}, nil, fmt.Errorf("failed to digest artifact: %w", err)
 	}
 
-	layerDesc.Annotations = layerAnnotations
+	var layers []ocispec.Descriptor
+	if len(sections) > 1 {
+		// Registries capping blob size reject the artifact as one layer, so it is pushed in ordered parts
+		layers = addArtifactParts(fs, artifact, sections, artifactDigest, version)
+		if err := ValidatePartAnnotations(layers); err != nil {
+			return nil, ocispec.Descriptor{}, nil, fmt.Errorf("invalid part annotations for %s: %w", artifact.Name, err)
+		}
+	} else {
+		layerDesc := fs.addSection(sections[0], artifact.GetMediaType())
+		layerDesc.Annotations = CreateLayerAnnotations(artifact, version)
+		layers = []ocispec.Descriptor{layerDesc}
+	}
+	for _, layer := range layers {
+		addVendorAnnotations(layer.Annotations, c.annotations)
+	}
 
-	manifestAnnotations := CreateManifestAnnotations()
+	manifestAnnotations := c.addAnnotations(CreateManifestAnnotations(version, c.createdAt()))
 
 	// Create config with platform information for multi-arch support
 	config := map[string]string{
@@ -87,7 +187,7 @@ func (c *Client) UploadArtifact(ctx context.Context, artifact *models.ArtifactDe
 	}
 	configBytes, err := json.Marshal(config)
 	if err != nil {
-		return "", 0, retry.NewNonRetryableError(fmt.Errorf("failed to marshal config: %w", err))
+		return nil, ocispec.Descriptor{}, nil, fmt.Errorf("failed to marshal config: %w", err)
 	}
 
 	configDesc := ocispec.Descriptor{
@@ -97,25 +197,58 @@ func (c *Client) UploadArtifact(ctx context.Context, artifact *models.ArtifactDe
 	}
 
 	if err = fs.Push(ctx, configDesc, bytes.NewReader(configBytes)); err != nil {
-		return "", 0, retry.NewNonRetryableError(fmt.Errorf("failed to push config: %w", err))
+		return nil, ocispec.Descriptor{}, nil, fmt.Errorf("failed to push config: %w", err)
 	}
 
 	artifactType := artifact.GetArtifactType()
 	packOpts := oras.PackManifestOptions{
 		ConfigDescriptor:    &configDesc,
-		Layers:              []ocispec.Descriptor{layerDesc},
+		Layers:              layers,
 		ManifestAnnotations: manifestAnnotations,
 	}
 
 	manifestDesc, err := oras.PackManifest(ctx, fs, oras.PackManifestVersion1_1, artifactType, packOpts)
 	if err != nil {
-		return "", 0, retry.NewNonRetryableError(fmt.Errorf("failed to pack manifest: %w", err))
+		return nil, ocispec.Descriptor{}, nil, fmt.Errorf("failed to pack manifest: %w", err)
+	}
+	return fs, manifestDesc, layers, nil
+}
+
+// addAnnotations adds the build and vendor annotations to the annotations of a manifest or index, returning them
+func (c *Client) addAnnotations(annotations map[string]string) map[string]string {
+	return addVendorAnnotations(addVendorAnnotations(annotations, c.build), c.annotations)
+}
+
+// createdAt returns the creation time stamped on manifests: the time the upload was planned, or now without a plan
+func (c *Client) createdAt() time.Time {
+	if c.created.IsZero() {
+		return time.Now()
+	}
+	return c.created
+}
+
+// layersSize returns the total size of layers
+func layersSize(layers []ocispec.Descriptor) int64 {
+	var size int64
+	for _, layer := range layers {
+		size += layer.Size
+	}
+	return size
+}
+
+func (c *Client) UploadArtifact(ctx context.Context, artifact *models.ArtifactDefinition, artifactPath, version string) (string, int64, error) {
+	fs, manifestDesc, layers, err := c.packArtifact(ctx, artifact, artifactPath, version)
+	if err != nil {
+		return "", 0, retry.NewNonRetryableError(err)
+	}
+	if len(layers) > 1 {
+		logging.Noticef(ctx, "Splitting %s (%d bytes) into %d layers of at most %d bytes", artifact.Name, layersSize(layers), len(layers), c.maxBlobSize)
 	}
 
-	// Tag manifest in file store with a temporary tag so it can be referenced during copy
+	// Tag manifest in the source store with a temporary tag so it can be referenced during copy
 	tempTag := "temp-manifest"
 	if err = fs.Tag(ctx, manifestDesc, tempTag); err != nil {
-		return "", 0, retry.NewNonRetryableError(fmt.Errorf("failed to tag manifest in file store: %w", err))
+		return "", 0, retry.NewNonRetryableError(fmt.Errorf("failed to tag manifest in source store: %w", err))
 	}
 
 	logging.Debugf(ctx, "Pushing artifact %s to registry by digest (digest: %s)", artifact.Name, manifestDesc.Digest.String())
@@ -128,7 +261,11 @@ func (c *Client) UploadArtifact(ctx context.Context, artifact *models.ArtifactDe
 	}
 
 	// Copy manifest and blobs to remote registry by digest
+	// Layers already pushed for an identical artifact are skipped
 	copyOpts := oras.CopyOptions{}
+	copyOpts.FindSuccessors = c.unpushedSuccessors
+	copyOpts.PostCopy = c.markPushed
+	copyOpts.OnCopySkipped = c.markPushed
 	digestRef := manifestDesc.Digest.String()
 
 	err = retry.Do(ctx, retryConfig, func() error {
@@ -149,18 +286,58 @@ func (c *Client) UploadArtifact(ctx context.Context, artifact *models.ArtifactDe
 	return manifestDesc.Digest.String(), manifestDesc.Size, nil
 }
 
-func (c *Client) CreateManifestIndex(ctx context.Context, uploadResults []models.ArtifactUploadResult, version string) (string, error) {
-	// Create manifest descriptors for each uploaded artifact
-	manifests := make([]ocispec.Descriptor, 0, len(uploadResults))
+// CreateManifestIndex pushes the multi-platform index of the uploaded artifacts of version under tag
+func (c *Client) CreateManifestIndex(ctx context.Context, uploadResults []models.ArtifactUploadResult, version, tag string) (string, error) {
+	indexDesc, indexBytes, err := c.buildIndex(ctx, uploadResults, version)
+	if err != nil {
+		return "", err
+	}
 
+	logging.Debugf(ctx, "Pushing manifest index to %s with tag %s (size: %d bytes)",
+		c.registry, tag, len(indexBytes))
+	logging.Debugf(ctx, "Attempting to push reference: %s", tag)
+
+	err = c.repo.PushReference(ctx, indexDesc, bytes.NewReader(indexBytes), tag)
+	if err != nil {
+		return "", fmt.Errorf("failed to push manifest index to %s:%s - %w",
+			c.registry, tag, err)
+	}
+
+	logging.Debugf(ctx, "Successfully pushed reference: %s", tag)
+	logging.Debug(ctx, "Manifest index push completed successfully")
+
+	return indexDesc.Digest.String(), nil
+}
+
+// buildIndex builds the multi-platform index of the uploaded artifacts of version locally, returning its descriptor
+// and content
+func (c *Client) buildIndex(ctx context.Context, uploadResults []models.ArtifactUploadResult, version string) (ocispec.Descriptor, []byte, error) {
+	// Manifests are listed by os, arch and name rather than in upload order, so the same artifacts always give the
+	// same index; annotations are marshaled with sorted keys
+	sorted := make([]models.ArtifactUploadResult, 0, len(uploadResults))
 	for _, result := range uploadResults {
-		if !result.Uploaded {
-			continue
+		if result.Uploaded {
+			sorted = append(sorted, result)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].OS != sorted[j].OS {
+			return sorted[i].OS < sorted[j].OS
 		}
+		if sorted[i].Arch != sorted[j].Arch {
+			return sorted[i].Arch < sorted[j].Arch
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	// Create manifest descriptors for each uploaded artifact
+	manifests := make([]ocispec.Descriptor, 0, len(sorted))
+
+	for _, result := range sorted {
 
 		digest, err := parseDigest(result.Digest)
 		if err != nil {
-			return "", fmt.Errorf("invalid digest for %s: %w", result.Name, err)
+			return ocispec.Descriptor{}, nil, fmt.Errorf("invalid digest for %s: %w", result.Name, err)
 		}
 
 		platform := &ocispec.Platform{
@@ -175,26 +352,33 @@ func (c *Client) CreateManifestIndex(ctx context.Context, uploadResults []models
 			Platform:     platform,
 			ArtifactType: "application/vnd.newrelic.agent.v1",
 		}
+		if result.Referenced {
+			// Pre-pushed manifests (e.g. container images) keep their own media type and artifact type
+			manifest.MediaType = result.MediaType
+			manifest.ArtifactType = result.ArtifactType
+		}
+		if isIndexMediaType(manifest.MediaType) {
+			// Multi-platform images are nested indexes whose own entries carry the platforms
+			manifest.Platform = nil
+		}
 
 		manifests = append(manifests, manifest)
 	}
 
 	if len(manifests) == 0 {
-		return "", fmt.Errorf("no manifests to include in index")
+		return ocispec.Descriptor{}, nil, fmt.Errorf("no manifests to include in index")
 	}
 
 	index := ocispec.Index{
-		MediaType: ocispec.MediaTypeImageIndex,
-		Manifests: manifests,
-		Annotations: map[string]string{
-			"org.opencontainers.image.version": version,
-		},
+		MediaType:   ocispec.MediaTypeImageIndex,
+		Manifests:   manifests,
+		Annotations: c.addAnnotations(CreateIndexAnnotations(version, c.createdAt())),
 	}
 	index.SchemaVersion = 2
 
 	indexBytes, err := json.Marshal(index)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal index: %w", err)
+		return ocispec.Descriptor{}, nil, fmt.Errorf("failed to marshal index: %w", err)
 	}
 
 	indexDesc := ocispec.Descriptor{
@@ -202,22 +386,30 @@ func (c *Client) CreateManifestIndex(ctx context.Context, uploadResults []models
 		Digest:    digest.FromBytes(indexBytes),
 		Size:      int64(len(indexBytes)),
 	}
-
-	logging.Debugf(ctx, "Pushing manifest index to %s with tag %s (size: %d bytes)",
-		c.registry, version, len(indexBytes))
 	logging.Debugf(ctx, "Index contains %d manifests", len(manifests))
-	logging.Debugf(ctx, "Attempting to push reference: %s", version)
+	return indexDesc, indexBytes, nil
+}
 
-	err = c.repo.PushReference(ctx, indexDesc, bytes.NewReader(indexBytes), version)
+// ResolveManifest looks up a manifest already in the repository by digest and returns its media type and size
+// Indexes are rejected since each entry of the agent index must be a single-platform manifest
+func (c *Client) ResolveManifest(ctx context.Context, manifestDigest string) (string, int64, error) {
+	desc, err := c.repo.Resolve(ctx, manifestDigest)
 	if err != nil {
-		return "", fmt.Errorf("failed to push manifest index to %s:%s - %w",
-			c.registry, version, err)
+		return "", 0, fmt.Errorf("failed to resolve %s in %s: %w", manifestDigest, c.registry, err)
 	}
 
-	logging.Debugf(ctx, "Successfully pushed reference: %s", version)
-	logging.Debug(ctx, "Manifest index push completed successfully")
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageManifest, dockerManifestMediaType:
+		return desc.MediaType, desc.Size, nil
+	case ocispec.MediaTypeImageIndex, dockerManifestListMediaType:
+		return "", 0, fmt.Errorf("%s is a multi-platform index; reference the single-platform manifest digest instead", manifestDigest)
+	default:
+		return "", 0, fmt.Errorf("%s has unsupported media type %s", manifestDigest, desc.MediaType)
+	}
+}
 
-	return indexDesc.Digest.String(), nil
+func isIndexMediaType(mediaType string) bool {
+	return mediaType == ocispec.MediaTypeImageIndex || mediaType == dockerManifestListMediaType
 }
 
 func parseDigest(digestStr string) (digest.Digest, error) {
@@ -2,10 +2,15 @@ package oci
 
 import (
 	"context"
+	"net/http"
 	"testing"
 
+	"agent-metadata-action/internal/testutil"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
 )
 
 func TestNewClient_Success(t *testing.T) {
@@ -14,6 +19,7 @@ func TestNewClient_Success(t *testing.T) {
 		registry    string
 		username    string
 		password    string
+		conn        Connection
 		expectPlain bool
 	}{
 		{
@@ -44,21 +50,58 @@ func TestNewClient_Success(t *testing.T) {
 			password:    "",
 			expectPlain: true,
 		},
+		{
+			name:        "in-cluster registry with oci-plain-http",
+			registry:    "registry.e2e.svc:5000/test",
+			conn:        Connection{PlainHTTP: true},
+			expectPlain: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			client, err := NewClient(context.Background(), tt.registry, tt.username, tt.password)
+			client, err := NewClient(context.Background(), tt.registry, tt.username, tt.password, tt.conn)
 
 			require.NoError(t, err)
 			assert.NotNil(t, client)
 			assert.Equal(t, tt.registry, client.registry)
 			assert.NotNil(t, client.repo)
-			assert.Equal(t, tt.expectPlain, client.repo.PlainHTTP)
+			assert.Equal(t, tt.expectPlain, client.repo.(*remote.Repository).PlainHTTP)
 		})
 	}
 }
 
+func TestNewClient_InsecureSkipTLSVerify(t *testing.T) {
+	client, err := NewClient(context.Background(), "registry.e2e.svc/test", "user", "pass", Connection{InsecureSkipTLSVerify: true})
+	require.NoError(t, err)
+
+	authClient, ok := client.repo.(*remote.Repository).Client.(*auth.Client)
+	require.True(t, ok)
+	require.NotNil(t, authClient.Client)
+	transport, ok := authClient.Client.Transport.(*http.Transport)
+	require.True(t, ok)
+	assert.True(t, transport.TLSClientConfig.InsecureSkipVerify)
+	assert.False(t, client.repo.(*remote.Repository).PlainHTTP)
+
+	// The default transport is left verifying certificates
+	secure, err := NewClient(context.Background(), "registry.e2e.svc/test", "user", "pass", Connection{})
+	require.NoError(t, err)
+	assert.Nil(t, secure.repo.(*remote.Repository).Client.(*auth.Client).Client)
+}
+
+func TestWarnInsecureConnection(t *testing.T) {
+	getStdout, _ := testutil.CaptureOutput(t)
+
+	// method under test
+	WarnInsecureConnection(context.Background(), "registry.example.com/agents", Connection{})
+	WarnInsecureConnection(context.Background(), "registry.e2e.svc/test", Connection{PlainHTTP: true, InsecureSkipTLSVerify: true})
+
+	output := getStdout()
+	assert.NotContains(t, output, "registry.example.com")
+	assert.Contains(t, output, "oci-plain-http is set: binaries are uploaded to registry.e2e.svc/test over plain HTTP")
+	assert.Contains(t, output, "oci-insecure-skip-tls-verify is set: the TLS certificate of registry.e2e.svc/test isn't verified")
+}
+
 func TestNewClient_InvalidRegistry(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -76,7 +119,7 @@ func TestNewClient_InvalidRegistry(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			client, err := NewClient(context.Background(), tt.registry, "user", "pass")
+			client, err := NewClient(context.Background(), tt.registry, "user", "pass", Connection{})
 
 			assert.Error(t, err)
 			assert.Nil(t, client)
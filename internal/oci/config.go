@@ -14,12 +14,42 @@ func LoadConfig() (models.OCIConfig, error) {
 	username := config.GetOCIUsername()
 	password := config.GetOCIPassword()
 	binariesJSON := config.GetBinaries()
+	plainHTTP := config.GetOCIPlainHTTP()
+	insecureSkipTLSVerify := config.GetOCIInsecureSkipTLSVerify()
+	pendingTag := config.GetOCIPendingTag()
+	maxBlobSize, err := config.GetOCIMaxBlobSize()
+	if err != nil {
+		return models.OCIConfig{}, err
+	}
+	annotations, err := config.GetOCIAnnotations()
+	if err != nil {
+		return models.OCIConfig{}, err
+	}
 
 	config := models.OCIConfig{
 		Registry:  strings.TrimSpace(registry),
 		Username:  strings.TrimSpace(username),
 		Password:  password,
 		Artifacts: []models.ArtifactDefinition{},
+
+		PlainHTTP:             plainHTTP,
+		InsecureSkipTLSVerify: insecureSkipTLSVerify,
+		PendingTag:            pendingTag,
+		Layout:                config.GetOCILayout(),
+		MaxBlobSize:           int64(maxBlobSize),
+
+		ScanCommand: config.GetScanCommand(),
+		ScanURL:     config.GetScanURL(),
+		ScanToken:   config.GetScanToken(),
+
+		RequiredLegalFiles: config.GetRequiredLegalFiles(),
+		ContentManifests:   config.GetContentManifests(),
+		NormalizeArchives:  config.GetNormalizeArchives(),
+		NotarizationStrict: config.GetNotarizationStrict(),
+		ReleaseNotes:       config.GetOCIReleaseNotes(),
+		PlanFile:           config.GetUploadPlanFile(),
+		Annotations:        annotations,
+		CreatedSource:      config.GetOCICreatedSource(),
 	}
 
 	if binariesJSON != "" {
@@ -34,3 +64,67 @@ func LoadConfig() (models.OCIConfig, error) {
 
 	return config, nil
 }
+
+// LoadRegistryConfig loads the registry inputs without the binaries, for flows that only work with what is already
+// in the registry
+func LoadRegistryConfig() (models.OCIConfig, error) {
+	registry := config.GetOCIRegistry()
+	username := config.GetOCIUsername()
+	password := config.GetOCIPassword()
+	plainHTTP := config.GetOCIPlainHTTP()
+	insecureSkipTLSVerify := config.GetOCIInsecureSkipTLSVerify()
+
+	config := models.OCIConfig{
+		Registry:              strings.TrimSpace(registry),
+		Username:              strings.TrimSpace(username),
+		Password:              password,
+		PlainHTTP:             plainHTTP,
+		InsecureSkipTLSVerify: insecureSkipTLSVerify,
+	}
+	if config.Registry == "" {
+		return config, fmt.Errorf("oci-registry is required")
+	}
+	return config, config.ValidateConnection()
+}
+
+// LoadCopySourceConfig loads the registry copy mode copies from
+// The source is reached over HTTPS with verified certificates, or plain HTTP for localhost registries
+func LoadCopySourceConfig() (models.OCIConfig, error) {
+	registry := config.GetCopySource()
+	username := config.GetCopySourceUsername()
+	password := config.GetCopySourcePassword()
+
+	config := models.OCIConfig{
+		Registry: strings.TrimSpace(registry),
+		Username: strings.TrimSpace(username),
+		Password: password,
+	}
+	if config.Registry == "" {
+		return config, fmt.Errorf("copy-source is required")
+	}
+	return config, nil
+}
+
+// LoadAssembleConfig loads the registry modes assemble and import push the manifest index to, with the inputs that
+// shape the index: its tag, annotations and creation time
+func LoadAssembleConfig() (models.OCIConfig, error) {
+	ociConfig, err := LoadRegistryConfig()
+	if err != nil {
+		return ociConfig, err
+	}
+	annotations, err := config.GetOCIAnnotations()
+	if err != nil {
+		return ociConfig, err
+	}
+	ociConfig.PendingTag = config.GetOCIPendingTag()
+	ociConfig.Annotations = annotations
+	ociConfig.CreatedSource = config.GetOCICreatedSource()
+
+	if err := models.ValidateAnnotations(ociConfig.Annotations); err != nil {
+		return ociConfig, err
+	}
+	if ociConfig.CreatedSource != "" && ociConfig.CreatedSource != models.CreatedSourceNow && ociConfig.CreatedSource != models.CreatedSourceCommit {
+		return ociConfig, fmt.Errorf("invalid oci-created-source %q: must be %s or %s", ociConfig.CreatedSource, models.CreatedSourceNow, models.CreatedSourceCommit)
+	}
+	return ociConfig, nil
+}
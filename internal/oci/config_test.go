@@ -271,10 +271,82 @@ func TestLoadConfig_NoCredentials(t *testing.T) {
 	assert.Len(t, config.Artifacts, 1)
 }
 
+func TestLoadConfig_ConnectionInputs(t *testing.T) {
+	os.Setenv("INPUT_OCI_REGISTRY", "registry.e2e.svc:5000/agents")
+	os.Setenv("INPUT_BINARIES", `[{"name": "test-binary", "path": "/path/to/binary", "os": "linux", "arch": "amd64", "format": "tar"}]`)
+	defer cleanupEnv()
+
+	config, err := LoadConfig()
+	require.NoError(t, err)
+	assert.False(t, config.PlainHTTP)
+	assert.False(t, config.InsecureSkipTLSVerify)
+
+	os.Setenv("INPUT_OCI_PLAIN_HTTP", "true")
+	config, err = LoadConfig()
+	require.NoError(t, err)
+	assert.True(t, config.PlainHTTP)
+
+	// Plain HTTP has no certificate to skip verifying
+	os.Setenv("INPUT_OCI_INSECURE_SKIP_TLS_VERIFY", "true")
+	_, err = LoadConfig()
+	assert.ErrorContains(t, err, "oci-plain-http and oci-insecure-skip-tls-verify can't both be set")
+
+	os.Unsetenv("INPUT_OCI_PLAIN_HTTP")
+	config, err = LoadConfig()
+	require.NoError(t, err)
+	assert.True(t, config.InsecureSkipTLSVerify)
+}
+
+func TestLoadConfig_Annotations(t *testing.T) {
+	os.Setenv("INPUT_OCI_REGISTRY", "docker.io/newrelic/agents")
+	os.Setenv("INPUT_BINARIES", `[{"name": "test-binary", "path": "/path/to/binary", "os": "linux", "arch": "amd64", "format": "tar"}]`)
+	os.Setenv("INPUT_OCI_ANNOTATIONS", `{"org.opencontainers.image.authors": "apm-java@newrelic.com", "com.newrelic.team": "apm-java"}`)
+	defer cleanupEnv()
+
+	config, err := LoadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"org.opencontainers.image.authors": "apm-java@newrelic.com", "com.newrelic.team": "apm-java"}, config.Annotations)
+
+	os.Setenv("INPUT_OCI_ANNOTATIONS", `{"com.newrelic.artifact.type": "library"}`)
+	_, err = LoadConfig()
+	assert.ErrorContains(t, err, "com.newrelic.artifact.type is set by the action and can't be overridden")
+
+	os.Setenv("INPUT_OCI_ANNOTATIONS", `["team"]`)
+	_, err = LoadConfig()
+	assert.ErrorContains(t, err, "invalid JSON for oci-annotations")
+}
+
+func TestLoadRegistryConfig(t *testing.T) {
+	os.Setenv("INPUT_OCI_REGISTRY", " ghcr.io/newrelic/agents ")
+	os.Setenv("INPUT_OCI_USERNAME", "user")
+	os.Setenv("INPUT_OCI_PASSWORD", "pass")
+	defer cleanupEnv()
+
+	// Binaries aren't needed to work with what is already in the registry
+	config, err := LoadRegistryConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "ghcr.io/newrelic/agents", config.Registry)
+	assert.Equal(t, "user", config.Username)
+	assert.Empty(t, config.Artifacts)
+
+	os.Setenv("INPUT_OCI_PLAIN_HTTP", "true")
+	os.Setenv("INPUT_OCI_INSECURE_SKIP_TLS_VERIFY", "true")
+	_, err = LoadRegistryConfig()
+	assert.ErrorContains(t, err, "can't both be set")
+
+	os.Setenv("INPUT_OCI_REGISTRY", "")
+	_, err = LoadRegistryConfig()
+	assert.ErrorContains(t, err, "oci-registry is required")
+}
+
 // cleanupEnv clears all OCI-related environment variables
 func cleanupEnv() {
 	os.Unsetenv("INPUT_OCI_REGISTRY")
 	os.Unsetenv("INPUT_OCI_USERNAME")
 	os.Unsetenv("INPUT_OCI_PASSWORD")
+	os.Unsetenv("INPUT_OCI_PLAIN_HTTP")
+	os.Unsetenv("INPUT_OCI_INSECURE_SKIP_TLS_VERIFY")
+	os.Unsetenv("INPUT_OCI_PENDING_TAG")
+	os.Unsetenv("INPUT_OCI_ANNOTATIONS")
 	os.Unsetenv("INPUT_BINARIES")
 }
@@ -0,0 +1,129 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+)
+
+// Content manifests list the files inside an uploaded archive, so consumers can check what a binary ships without
+// pulling it
+const (
+	ContentManifestArtifactType = "application/vnd.newrelic.agent.contents.v1"
+	ContentManifestMediaType    = "application/vnd.newrelic.agent.contents.v1+json"
+)
+
+// ContentManifest is the file list of an archive, attached as a referrer of its artifact manifest
+type ContentManifest struct {
+	SchemaVersion int           `json:"schemaVersion"`
+	Artifact      string        `json:"artifact"`
+	Format        string        `json:"format"`
+	Files         []ContentFile `json:"files"`
+}
+
+// ContentFile is a file inside an archive, with the SHA-256 of its uncompressed content
+type ContentFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// BuildContentManifest hashes every file of the archive at archivePath, returning them sorted by path so the
+// manifest doesn't depend on the archive order
+func BuildContentManifest(ctx context.Context, artifact *models.ArtifactDefinition, archivePath string) (*ContentManifest, error) {
+	manifest := &ContentManifest{SchemaVersion: 1, Artifact: artifact.Name, Format: artifact.Format, Files: []ContentFile{}}
+	err := walkArchive(ctx, archivePath, artifact.Format, func(name string, size int64, content io.Reader) error {
+		hash := sha256.New()
+		written, err := io.Copy(hash, content)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		manifest.Files = append(manifest.Files, ContentFile{Path: name, Size: written, SHA256: hex.EncodeToString(hash.Sum(nil))})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i].Path < manifest.Files[j].Path })
+	return manifest, nil
+}
+
+// PushContentManifest pushes contents as the layer of a manifest referring to the artifact manifest subject, and
+// returns the digest of the referrer
+// Registries without the referrers API list it through the sha256-<digest> tag of the subject
+func (c *Client) PushContentManifest(ctx context.Context, subject ocispec.Descriptor, contents *ContentManifest) (string, error) {
+	contentBytes, err := json.Marshal(contents)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal content manifest: %w", err)
+	}
+	layer := ocispec.Descriptor{
+		MediaType:   ContentManifestMediaType,
+		Digest:      digest.FromBytes(contentBytes),
+		Size:        int64(len(contentBytes)),
+		Annotations: map[string]string{ocispec.AnnotationTitle: contents.Artifact + ".contents.json"},
+	}
+	if err := c.repo.Push(ctx, layer, bytes.NewReader(contentBytes)); err != nil {
+		return "", fmt.Errorf("failed to push content manifest of %s: %w", contents.Artifact, err)
+	}
+
+	packOpts := oras.PackManifestOptions{
+		Subject: &subject,
+		Layers:  []ocispec.Descriptor{layer},
+	}
+	manifestDesc, err := oras.PackManifest(ctx, c.repo, oras.PackManifestVersion1_1, ContentManifestArtifactType, packOpts)
+	if err != nil {
+		return "", fmt.Errorf("failed to push content manifest referrer of %s: %w", contents.Artifact, err)
+	}
+	return manifestDesc.Digest.String(), nil
+}
+
+// AttachContentManifests pushes the content manifest of every uploaded archive, found relative to workspacePath,
+// as a referrer of its manifest and sets ContentManifest of its upload result to the referrer digest
+// Referenced manifests and failed uploads are skipped
+func AttachContentManifests(ctx context.Context, client *Client, workspacePath string, artifacts []models.ArtifactDefinition, uploadResults []models.ArtifactUploadResult) error {
+	byName := make(map[string]*models.ArtifactDefinition, len(artifacts))
+	for i := range artifacts {
+		byName[artifacts[i].Name] = &artifacts[i]
+	}
+
+	for i := range uploadResults {
+		result := &uploadResults[i]
+		artifact, ok := byName[result.Name]
+		if !ok || !result.Uploaded || result.Referenced || artifact.IsReference() || artifact.IsMSI() {
+			continue
+		}
+		archivePath, err := ResolveArtifactPath(workspacePath, artifact.Path)
+		if err != nil {
+			return err
+		}
+		contents, err := BuildContentManifest(ctx, artifact, archivePath)
+		if err != nil {
+			return fmt.Errorf("failed to list the contents of artifact '%s': %w", artifact.Name, err)
+		}
+
+		subjectDigest, err := parseDigest(result.Digest)
+		if err != nil {
+			return fmt.Errorf("invalid digest for %s: %w", result.Name, err)
+		}
+		subject := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: subjectDigest, Size: result.Size}
+		referrer, err := client.PushContentManifest(ctx, subject, contents)
+		if err != nil {
+			return err
+		}
+		result.ContentManifest = referrer
+		logging.Debugf(ctx, "Attached content manifest of %s (%d files): %s", artifact.Name, len(contents.Files), referrer)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sha256 of "text", the content of every file written by writeArchive
+const textSHA256 = "982d9e3eb996f559e633f4d194def3761d909f5a3b647d1a851fead67c32c9d1"
+
+func TestBuildContentManifest(t *testing.T) {
+	for _, format := range []string{"tar", "tar+gzip", "zip"} {
+		t.Run(format, func(t *testing.T) {
+			artifact := &models.ArtifactDefinition{Name: "linux", Format: format}
+			path := writeArchive(t, t.TempDir(), "agent", format, "newrelic/lib/agent.jar", "newrelic/LICENSE")
+
+			// method under test
+			contents, err := BuildContentManifest(context.Background(), artifact, path)
+
+			require.NoError(t, err)
+			assert.Equal(t, &ContentManifest{
+				SchemaVersion: 1,
+				Artifact:      "linux",
+				Format:        format,
+				Files: []ContentFile{
+					{Path: "newrelic/LICENSE", Size: 4, SHA256: textSHA256},
+					{Path: "newrelic/lib/agent.jar", Size: 4, SHA256: textSHA256},
+				},
+			}, contents, "files are sorted by path and directories aren't listed")
+		})
+	}
+
+	t.Run("unreadable archive", func(t *testing.T) {
+		path := writeArchive(t, t.TempDir(), "agent", "tar", "LICENSE")
+
+		// method under test
+		_, err := BuildContentManifest(context.Background(), &models.ArtifactDefinition{Name: "linux", Format: "zip"}, path)
+
+		assert.ErrorContains(t, err, "failed to read zip archive")
+	})
+}
+
+func TestAttachContentManifests(t *testing.T) {
+	registry := newManifestRegistry()
+	server := httptest.NewServer(registry)
+	t.Cleanup(server.Close)
+	client, err := NewClient(context.Background(), strings.TrimPrefix(server.URL, "http://")+"/agents", "", "", Connection{})
+	require.NoError(t, err)
+
+	workspace := t.TempDir()
+	writeArchive(t, workspace, "linux.tar.gz", "tar+gzip", "newrelic/LICENSE")
+	subject := registry.add("subject", ocispec.MediaTypeImageManifest, []byte(`{"schemaVersion":2}`))
+	artifacts := []models.ArtifactDefinition{
+		{Name: "linux", Path: "./linux.tar.gz", Format: "tar+gzip"},
+		{Name: "image", Digest: subject},
+	}
+	uploadResults := []models.ArtifactUploadResult{
+		{Name: "linux", Digest: subject, Size: int64(len(`{"schemaVersion":2}`)), Uploaded: true},
+		{Name: "image", Digest: subject, Uploaded: true, Referenced: true},
+	}
+
+	// method under test
+	err = AttachContentManifests(context.Background(), client, workspace, artifacts, uploadResults)
+
+	require.NoError(t, err)
+	require.NotEmpty(t, uploadResults[0].ContentManifest)
+	assert.Empty(t, uploadResults[1].ContentManifest, "referenced manifests have no content manifest")
+
+	var referrer ocispec.Manifest
+	require.NoError(t, json.Unmarshal(registry.manifests[uploadResults[0].ContentManifest], &referrer))
+	assert.Equal(t, ContentManifestArtifactType, referrer.ArtifactType)
+	require.NotNil(t, referrer.Subject)
+	assert.Equal(t, subject, referrer.Subject.Digest.String())
+	require.Len(t, referrer.Layers, 1)
+	assert.Equal(t, ContentManifestMediaType, referrer.Layers[0].MediaType)
+
+	var contents ContentManifest
+	require.NoError(t, json.Unmarshal(registry.blobs[referrer.Layers[0].Digest.String()], &contents))
+	assert.Equal(t, []ContentFile{{Path: "newrelic/LICENSE", Size: 4, SHA256: textSHA256}}, contents.Files)
+}
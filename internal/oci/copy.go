@@ -0,0 +1,112 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+	"agent-metadata-action/internal/retry"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+)
+
+// ResolveSignedIndex returns the manifest index reference (a tag or digest) points to, checking it is signed
+func (c *Client) ResolveSignedIndex(ctx context.Context, reference string) (ocispec.Descriptor, error) {
+	desc, err := c.repo.Resolve(ctx, reference)
+	if err != nil {
+		return desc, fmt.Errorf("failed to resolve %s in %s: %w", reference, c.registry, err)
+	}
+	if desc.MediaType != ocispec.MediaTypeImageIndex {
+		return desc, fmt.Errorf("%s in %s has media type %s rather than being the manifest index of a release", reference, c.registry, desc.MediaType)
+	}
+
+	signed, err := c.IsSigned(ctx, desc)
+	if err != nil {
+		return desc, fmt.Errorf("failed to look up the signature of %s in %s: %w", reference, c.registry, err)
+	}
+	if !signed {
+		return desc, fmt.Errorf("%s in %s (%s) isn't signed: only signed indexes are copied", reference, c.registry, desc.Digest)
+	}
+	return desc, nil
+}
+
+// CopyIndex copies the manifest index and everything it lists, the platform manifests and their blobs, from the
+// repository of source by digest
+// The content is copied byte for byte, so digests and annotations are unchanged; signatures and other referrers are
+// left behind since they name the source location
+func (c *Client) CopyIndex(ctx context.Context, source *Client, desc ocispec.Descriptor) error {
+	return c.copyFrom(ctx, source, desc, "OCI index copy")
+}
+
+// copyFrom copies the manifest or index desc describes, and everything it references, from the repository of source
+// by digest
+func (c *Client) copyFrom(ctx context.Context, source *Client, desc ocispec.Descriptor, operation string) error {
+	retryConfig := retry.Config{
+		MaxAttempts: 3,
+		BaseDelay:   2 * time.Second,
+		Operation:   operation,
+	}
+	digestRef := desc.Digest.String()
+	return retry.Do(ctx, retryConfig, func() error {
+		copyCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+		defer cancel()
+
+		if _, err := oras.Copy(copyCtx, source.repo, digestRef, c.repo, digestRef, oras.CopyOptions{}); err != nil {
+			return fmt.Errorf("failed to copy %s from %s to %s: %w", digestRef, source.registry, c.registry, err)
+		}
+		return nil
+	})
+}
+
+// HandleCopy copies the signed manifest index reference points to in the source repository to the destination
+// repository and tags it with version, returning its digest
+// The copy is unsigned until signed for the destination; dry runs only check the source index can be copied
+func HandleCopy(ctx context.Context, sourceConfig, destinationConfig *models.OCIConfig, reference, version string, dryRun bool) (string, error) {
+	source, err := newClientFunc(ctx, sourceConfig.Registry, sourceConfig.Username, sourceConfig.Password, Connection{})
+	if err != nil {
+		return "", fmt.Errorf("failed to create OCI client for %s: %w", sourceConfig.Registry, err)
+	}
+	desc, err := source.ResolveSignedIndex(ctx, reference)
+	if err != nil {
+		return "", err
+	}
+	indexDigest := desc.Digest.String()
+	logging.Noticef(ctx, "Manifest index %s in %s (%s) is signed", reference, sourceConfig.Registry, indexDigest)
+
+	if dryRun {
+		logging.Noticef(ctx, "Dry run - not copying %s to %s:%s", indexDigest, destinationConfig.Registry, version)
+		return indexDigest, nil
+	}
+
+	conn := ConnectionFor(destinationConfig)
+	WarnInsecureConnection(ctx, destinationConfig.Registry, conn)
+	results.RecordRegistry(ctx, results.Registry{
+		URL:                   destinationConfig.Registry,
+		PlainHTTP:             conn.PlainHTTP || IsLocalRegistry(destinationConfig.Registry),
+		InsecureSkipTLSVerify: conn.InsecureSkipTLSVerify,
+	})
+	destination, err := newClientFunc(ctx, destinationConfig.Registry, destinationConfig.Username, destinationConfig.Password, conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to create OCI client for %s: %w", destinationConfig.Registry, err)
+	}
+	capabilities, err := destination.ProbeCapabilities(ctx)
+	if err != nil {
+		return "", fmt.Errorf("registry capability check failed: %w", err)
+	}
+	results.RecordRegistryCapabilities(ctx, capabilities.Referrers, capabilities.ChunkMinLength)
+
+	logging.Noticef(ctx, "Copying %s from %s to %s...", indexDigest, sourceConfig.Registry, destinationConfig.Registry)
+	if err := destination.CopyIndex(ctx, source, desc); err != nil {
+		return "", err
+	}
+	if err := destination.TagIndex(ctx, desc, version); err != nil {
+		return "", err
+	}
+	logging.Noticef(ctx, "Copied %s to %s:%s", indexDigest, destinationConfig.Registry, version)
+	results.RecordCopy(ctx, destinationConfig.Registry, version, indexDigest, sourceConfig.Registry)
+	return indexDigest, nil
+}
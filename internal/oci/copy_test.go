@@ -0,0 +1,101 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleCopy(t *testing.T) {
+	setup := func(t *testing.T) (source, destination *manifestRegistry, sourceConfig, destinationConfig *models.OCIConfig, indexDigest string) {
+		source, destination = newManifestRegistry(), newManifestRegistry()
+		sourceServer, destinationServer := httptest.NewServer(source), httptest.NewServer(destination)
+		t.Cleanup(sourceServer.Close)
+		t.Cleanup(destinationServer.Close)
+
+		// A release with a single platform: the index, its manifest, and the manifest's config and layer
+		configDesc := source.addBlob("application/vnd.newrelic.agent.config.v1+json", []byte(`{"architecture":"amd64","os":"linux"}`))
+		layerDesc := source.addBlob("application/vnd.newrelic.agent.content.v1.tar+gzip", []byte("agent binary"))
+		manifest, err := json.Marshal(ocispec.Manifest{
+			MediaType: ocispec.MediaTypeImageManifest, Config: configDesc, Layers: []ocispec.Descriptor{layerDesc},
+			Annotations: map[string]string{"org.opencontainers.image.version": "1.2.3"},
+		})
+		require.NoError(t, err)
+		manifestDigest := source.add("linux", ocispec.MediaTypeImageManifest, manifest)
+		index, err := json.Marshal(ocispec.Index{
+			MediaType: ocispec.MediaTypeImageIndex,
+			Manifests: []ocispec.Descriptor{{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.Digest(manifestDigest), Size: int64(len(manifest))}},
+		})
+		require.NoError(t, err)
+		indexDigest = source.add("1.2.3", ocispec.MediaTypeImageIndex, index)
+
+		sourceConfig = &models.OCIConfig{Registry: strings.TrimPrefix(sourceServer.URL, "http://") + "/agents"}
+		destinationConfig = &models.OCIConfig{Registry: strings.TrimPrefix(destinationServer.URL, "http://") + "/agents"}
+		return source, destination, sourceConfig, destinationConfig, indexDigest
+	}
+
+	t.Run("copies a signed index with everything it lists", func(t *testing.T) {
+		source, destination, sourceConfig, destinationConfig, indexDigest := setup(t)
+		source.referrers[indexDigest] = true
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+
+		// method under test
+		copied, err := HandleCopy(ctx, sourceConfig, destinationConfig, "1.2.3", "1.2.3", false)
+
+		require.NoError(t, err)
+		assert.Equal(t, indexDigest, copied)
+		assert.Equal(t, indexDigest, destination.tags["1.2.3"])
+		for d, content := range source.manifests {
+			assert.Equal(t, content, destination.manifests[d], "manifests are copied byte for byte")
+		}
+		for d, content := range source.blobs {
+			assert.Equal(t, content, destination.blobs[d])
+		}
+		assert.Equal(t, &results.Index{Registry: destinationConfig.Registry, Tag: "1.2.3", Digest: indexDigest, CopiedFrom: sourceConfig.Registry}, recorder.Results().Index)
+	})
+
+	t.Run("copies by digest", func(t *testing.T) {
+		source, destination, sourceConfig, destinationConfig, indexDigest := setup(t)
+		source.referrers[indexDigest] = true
+
+		// method under test
+		_, err := HandleCopy(context.Background(), sourceConfig, destinationConfig, indexDigest, "1.2.3", false)
+
+		require.NoError(t, err)
+		assert.Equal(t, indexDigest, destination.tags["1.2.3"])
+	})
+
+	t.Run("unsigned index", func(t *testing.T) {
+		_, destination, sourceConfig, destinationConfig, _ := setup(t)
+
+		// method under test
+		_, err := HandleCopy(context.Background(), sourceConfig, destinationConfig, "1.2.3", "1.2.3", false)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "isn't signed: only signed indexes are copied")
+		assert.Empty(t, destination.tags)
+	})
+
+	t.Run("dry run only checks", func(t *testing.T) {
+		source, destination, sourceConfig, destinationConfig, indexDigest := setup(t)
+		source.referrers[indexDigest] = true
+
+		// method under test
+		copied, err := HandleCopy(context.Background(), sourceConfig, destinationConfig, "1.2.3", "1.2.3", true)
+
+		require.NoError(t, err)
+		assert.Equal(t, indexDigest, copied)
+		assert.Empty(t, destination.manifests)
+	})
+}
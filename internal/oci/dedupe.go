@@ -0,0 +1,143 @@
+package oci
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+)
+
+// ArtifactHasher is implemented by uploaders that can hash artifacts ahead of uploading them, so byte-identical
+// artifacts are found before anything is pushed
+type ArtifactHasher interface {
+	HashArtifact(ctx context.Context, artifactPath string) (digest.Digest, error)
+}
+
+// hashedArtifact is the sections an artifact file is pushed as, valid while its size and modification time hold
+type hashedArtifact struct {
+	size     int64
+	modTime  time.Time
+	sections []fileSection
+	digest   digest.Digest
+}
+
+// HashArtifact returns the digest of the artifact at path, keeping its sections for the upload
+func (c *Client) HashArtifact(ctx context.Context, artifactPath string) (digest.Digest, error) {
+	_, artifactDigest, err := c.artifactSections(artifactPath)
+	return artifactDigest, err
+}
+
+// LayerDigest returns the digest of the artifact file at artifactPath in the workspace, as recorded as the layer
+// digest of its upload
+func LayerDigest(workspacePath, artifactPath string) (string, error) {
+	fullPath, err := ResolveArtifactPath(workspacePath, artifactPath)
+	if err != nil {
+		return "", err
+	}
+	_, artifactDigest, err := digestSections(fullPath, 0)
+	if err != nil {
+		return "", err
+	}
+	return artifactDigest.String(), nil
+}
+
+// artifactSections returns the sections the artifact at path is pushed as, in parts when it exceeds the blob size
+// limit, and the digest of the whole artifact
+// The file is read once per run, unless it changes in between
+func (c *Client) artifactSections(path string) ([]fileSection, digest.Digest, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c.mu.Lock()
+	hashed, ok := c.hashed[path]
+	c.mu.Unlock()
+	if ok && hashed.size == info.Size() && hashed.modTime.Equal(info.ModTime()) {
+		return hashed.sections, hashed.digest, nil
+	}
+
+	var maxSize int64
+	if c.maxBlobSize > 0 && info.Size() > c.maxBlobSize {
+		maxSize = c.maxBlobSize
+	}
+	sections, artifactDigest, err := digestSections(path, maxSize)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hashed == nil {
+		c.hashed = make(map[string]hashedArtifact)
+	}
+	c.hashed[path] = hashedArtifact{size: info.Size(), modTime: info.ModTime(), sections: sections, digest: artifactDigest}
+	return sections, artifactDigest, nil
+}
+
+// markPushed records that desc is in the registry, so later artifacts sharing it don't push it again
+func (c *Client) markPushed(_ context.Context, desc ocispec.Descriptor) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pushed == nil {
+		c.pushed = make(map[digest.Digest]bool)
+	}
+	c.pushed[desc.Digest] = true
+	return nil
+}
+
+// unpushedSuccessors returns the content desc references, less the layers this client already pushed
+// Copying skips those layers altogether, rather than asking the registry whether each exists
+func (c *Client) unpushedSuccessors(ctx context.Context, fetcher content.Fetcher, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+	successors, err := content.Successors(ctx, fetcher, desc)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	unpushed := successors[:0]
+	for _, successor := range successors {
+		if !c.pushed[successor.Digest] {
+			unpushed = append(unpushed, successor)
+		}
+	}
+	return unpushed, nil
+}
+
+// reportIdenticalArtifacts hashes the artifacts to upload and notices those with byte-identical content, e.g. an
+// any/any alias of a platform binary, whose blob is then pushed once and referenced by each of their manifests
+// Artifacts that can't be hashed are left to the upload to report
+func reportIdenticalArtifacts(ctx context.Context, hasher ArtifactHasher, artifacts []models.ArtifactDefinition, workspacePath string) {
+	var order []digest.Digest
+	names := make(map[digest.Digest][]string)
+	for _, artifact := range artifacts {
+		if artifact.IsReference() {
+			continue
+		}
+		fullPath, err := ResolveArtifactPath(workspacePath, artifact.Path)
+		if err != nil {
+			continue
+		}
+		artifactDigest, err := hasher.HashArtifact(ctx, fullPath)
+		if err != nil {
+			continue
+		}
+		if _, ok := names[artifactDigest]; !ok {
+			order = append(order, artifactDigest)
+		}
+		names[artifactDigest] = append(names[artifactDigest], artifact.Name)
+	}
+	for _, artifactDigest := range order {
+		if len(names[artifactDigest]) > 1 {
+			logging.Noticef(ctx, "Artifacts %s have identical content (%s): it is pushed once and referenced by each of their manifests",
+				strings.Join(names[artifactDigest], ", "), artifactDigest)
+		}
+	}
+}
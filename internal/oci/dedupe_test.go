@@ -0,0 +1,139 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hasherClient is a mock uploader that also hashes artifacts
+type hasherClient struct {
+	mockClient
+	digests map[string]digest.Digest // by path
+}
+
+func (h *hasherClient) HashArtifact(_ context.Context, artifactPath string) (digest.Digest, error) {
+	return h.digests[artifactPath], nil
+}
+
+func TestUploadArtifact_IdenticalArtifacts(t *testing.T) {
+	registry := newManifestRegistry()
+	var mu sync.Mutex
+	requests := map[string]int{} // by method and digest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if d := r.URL.Query().Get("digest"); d != "" {
+			requests[r.Method+" "+d]++
+		} else if d, ok := strings.CutPrefix(r.URL.Path, "/v2/agents/blobs/"); ok {
+			requests[r.Method+" "+d]++
+		}
+		mu.Unlock()
+		registry.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	testutil.CaptureOutput(t)
+
+	client, err := NewClient(context.Background(), strings.TrimPrefix(server.URL, "http://")+"/agents", "", "", Connection{})
+	require.NoError(t, err)
+
+	content := bytes.Repeat([]byte{0x1f, 0x8b, 0x08, 0x00}, 1024)
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "linux.tar.gz"), content, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "any.tar.gz"), content, 0o644))
+	layerDigest := digest.FromBytes(content).String()
+
+	// method under test
+	linux, _, err := client.UploadArtifact(context.Background(), &models.ArtifactDefinition{Name: "linux", OS: "linux", Arch: "amd64", Format: "tar+gzip"}, filepath.Join(workspace, "linux.tar.gz"), "1.2.3")
+	require.NoError(t, err)
+	anyPlatform, _, err := client.UploadArtifact(context.Background(), &models.ArtifactDefinition{Name: "any", OS: "any", Arch: "any", Format: "tar+gzip"}, filepath.Join(workspace, "any.tar.gz"), "1.2.3")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, linux, anyPlatform, "Each artifact gets its own manifest")
+	for _, manifestDigest := range []string{linux, anyPlatform} {
+		var manifest ocispec.Manifest
+		require.NoError(t, json.Unmarshal(registry.manifests[manifestDigest], &manifest))
+		require.Len(t, manifest.Layers, 1)
+		assert.Equal(t, layerDigest, manifest.Layers[0].Digest.String())
+	}
+	assert.Equal(t, 1, requests[http.MethodPut+" "+layerDigest], "The shared layer is pushed once")
+	assert.Equal(t, 1, requests[http.MethodHead+" "+layerDigest], "The second artifact doesn't look the shared layer up")
+}
+
+func TestClient_ArtifactSections(t *testing.T) {
+	client := &Client{}
+	path := filepath.Join(t.TempDir(), "agent.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("first"), 0o644))
+
+	// method under test
+	_, first, err := client.artifactSections(path)
+	require.NoError(t, err)
+	_, cached, err := client.artifactSections(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte("second!"), 0o644))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	_, changed, err := client.artifactSections(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, digest.FromString("first"), first)
+	assert.Equal(t, first, cached)
+	assert.Equal(t, digest.FromString("second!"), changed, "A changed file is hashed again")
+}
+
+func TestUploadArtifacts_ReportsIdenticalArtifacts(t *testing.T) {
+	getStdout, _ := testutil.CaptureOutput(t)
+	shared := digest.FromString("agent")
+	client := &hasherClient{
+		mockClient: mockClient{uploadFunc: func(ctx context.Context, artifact *models.ArtifactDefinition, artifactPath, version string) (string, int64, error) {
+			return "sha256:abc123", 10, nil
+		}},
+		digests: map[string]digest.Digest{
+			"/workspace/linux.tar.gz":   shared,
+			"/workspace/any.tar.gz":     shared,
+			"/workspace/windows.tar.gz": digest.FromString("windows"),
+		},
+	}
+	config := &models.OCIConfig{Artifacts: []models.ArtifactDefinition{
+		{Name: "linux", Path: "./linux.tar.gz", OS: "linux", Arch: "amd64"},
+		{Name: "windows", Path: "./windows.tar.gz", OS: "windows", Arch: "amd64"},
+		{Name: "any", Path: "./any.tar.gz", OS: "any", Arch: "any"},
+	}}
+
+	// method under test
+	results := UploadArtifacts(context.Background(), client, config, "/workspace", "1.2.3")
+
+	assert.Len(t, results, 3)
+	assert.Equal(t, shared.String(), results[0].LayerDigest, "The layer digest is recorded for resuming")
+	stdout := getStdout()
+	assert.Contains(t, stdout, "Artifacts linux, any have identical content ("+shared.String()+")")
+	assert.NotContains(t, stdout, "windows, ")
+}
+
+func TestLayerDigest(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "agent.tar.gz"), []byte("agent"), 0o644))
+
+	// method under test
+	layerDigest, err := LayerDigest(workspace, "./agent.tar.gz")
+
+	require.NoError(t, err)
+	assert.Equal(t, digest.FromString("agent").String(), layerDigest)
+
+	_, err = LayerDigest(workspace, "./missing.tar.gz")
+	assert.Error(t, err)
+}
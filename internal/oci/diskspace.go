@@ -0,0 +1,39 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/resources"
+)
+
+// CheckUploadDiskSpace fails early if the temp directory can't hold the normalized copies of the artifacts to upload
+// Uploads are pushed straight from the artifact files, so without normalize nothing is written there
+func CheckUploadDiskSpace(ctx context.Context, workspace string, artifacts []models.ArtifactDefinition, normalize bool) error {
+	if !normalize {
+		return nil
+	}
+	var total int64
+	count := 0
+	for i := range artifacts {
+		if artifacts[i].IsReference() {
+			continue
+		}
+		fullPath, err := ResolveArtifactPath(workspace, artifacts[i].Path)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			return fmt.Errorf("failed to stat artifact %s: %w", artifacts[i].Name, err)
+		}
+		total += info.Size()
+		count++
+	}
+	if count == 0 {
+		return nil
+	}
+	return resources.CheckDiskSpace(ctx, os.TempDir(), total, fmt.Sprintf("normalized copies of %d binaries", count))
+}
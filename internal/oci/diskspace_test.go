@@ -0,0 +1,46 @@
+package oci
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckUploadDiskSpace(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "agent.tar.gz"), []byte("binary"), 0644))
+
+	t.Run("room for the artifacts", func(t *testing.T) {
+		artifacts := []models.ArtifactDefinition{{Name: "linux-amd64", Path: "agent.tar.gz"}}
+
+		// method under test
+		err := CheckUploadDiskSpace(context.Background(), workspace, artifacts, true)
+
+		require.NoError(t, err)
+	})
+
+	t.Run("missing artifact", func(t *testing.T) {
+		artifacts := []models.ArtifactDefinition{{Name: "linux-arm64", Path: "missing.tar.gz"}}
+
+		// method under test
+		err := CheckUploadDiskSpace(context.Background(), workspace, artifacts, true)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to stat artifact linux-arm64")
+	})
+
+	t.Run("nothing written without normalizing", func(t *testing.T) {
+		artifacts := []models.ArtifactDefinition{{Name: "linux-arm64", Path: "missing.tar.gz"}}
+
+		// method under test
+		err := CheckUploadDiskSpace(context.Background(), workspace, artifacts, false)
+
+		require.NoError(t, err)
+	})
+}
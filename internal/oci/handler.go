@@ -3,39 +3,122 @@ package oci
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
+	"agent-metadata-action/internal/github"
+	"agent-metadata-action/internal/journal"
 	"agent-metadata-action/internal/logging"
 	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+	"agent-metadata-action/internal/scan"
 )
 
 func HandleUploads(ctx context.Context, ociConfig *models.OCIConfig, workspace, version string) (string, error) {
 	logging.Notice(ctx, "OCI upload enabled, starting binary uploads...")
 
-	if err := ValidateAllArtifacts(ctx, workspace, ociConfig); err != nil {
-		logging.NoticeErrorWithCategory(ctx, err, "oci.validation", map[string]interface{}{
-			"error.operation": "validate_artifacts",
-			"oci.registry":    ociConfig.Registry,
-			"artifact.count":  len(ociConfig.Artifacts),
+	prepared, err := prepareUploads(ctx, ociConfig, workspace, version)
+	if err != nil {
+		return "", err
+	}
+	defer prepared.cleanup()
+	ociConfig = prepared.config
+	originalPaths := prepared.originalPaths
+	releaseNotes := prepared.releaseNotes
+
+	conn := ConnectionFor(ociConfig)
+	var client *Client
+	if ociConfig.Registry == "" {
+		// Written to the OCI layout alone, which has no capabilities to probe
+		client, err = NewLayoutClient(ctx, filepath.Join(workspace, ociConfig.Layout))
+		if err != nil {
+			return "", err
+		}
+	} else {
+		WarnInsecureConnection(ctx, ociConfig.Registry, conn)
+		results.RecordRegistry(ctx, results.Registry{
+			URL:                   ociConfig.Registry,
+			PlainHTTP:             conn.PlainHTTP || IsLocalRegistry(ociConfig.Registry),
+			InsecureSkipTLSVerify: conn.InsecureSkipTLSVerify,
 		})
-		return "", fmt.Errorf("binary validation failed: %w", err)
+
+		client, err = newClientFunc(ctx, ociConfig.Registry, ociConfig.Username, ociConfig.Password, conn)
+		if err != nil {
+			logging.NoticeErrorWithCategory(ctx, err, "oci.client", map[string]interface{}{
+				"error.operation": "create_oci_client",
+				"oci.registry":    ociConfig.Registry,
+			})
+			return "", fmt.Errorf("failed to create OCI client: %w", err)
+		}
+	}
+	if err := configureClient(ctx, client, ociConfig, workspace); err != nil {
+		return "", err
 	}
 
-	client, err := NewClient(ctx, ociConfig.Registry, ociConfig.Username, ociConfig.Password)
+	// Planned before anything is pushed, so the pushed digests can be checked against the plan
+	plan, err := planUpload(ctx, client, prepared, workspace, version)
 	if err != nil {
-		logging.NoticeErrorWithCategory(ctx, err, "oci.client", map[string]interface{}{
-			"error.operation": "create_oci_client",
-			"oci.registry":    ociConfig.Registry,
-		})
-		return "", fmt.Errorf("failed to create OCI client: %w", err)
+		return "", err
+	}
+
+	if ociConfig.Registry != "" {
+		capabilities, err := client.ProbeCapabilities(ctx)
+		if err != nil {
+			logging.NoticeErrorWithCategory(ctx, err, "oci.capabilities", map[string]interface{}{
+				"error.operation": "probe_registry_capabilities",
+				"oci.registry":    ociConfig.Registry,
+			})
+			return "", fmt.Errorf("registry capability check failed: %w", err)
+		}
+		if !capabilities.Referrers {
+			logging.Noticef(ctx, "%s has no OCI 1.1 referrers API; referrers such as signatures are listed through sha256-<digest> tags instead", ociConfig.Registry)
+		}
+		results.RecordRegistryCapabilities(ctx, capabilities.Referrers, capabilities.ChunkMinLength)
+
+		if err := probeLayerMediaTypes(ctx, client, ociConfig); err != nil {
+			logging.NoticeErrorWithCategory(ctx, err, "oci.capabilities", map[string]interface{}{
+				"error.operation": "probe_layer_media_types",
+				"oci.registry":    ociConfig.Registry,
+			})
+			return "", fmt.Errorf("registry capability check failed: %w", err)
+		}
 	}
 
 	uploadResults := UploadArtifacts(ctx, client, ociConfig, workspace, version)
 
+	// Report artifacts by the path the user configured rather than the staging location
+	for i := range uploadResults {
+		uploadResults[i].Path = originalPaths[uploadResults[i].Name]
+	}
+	// Journaled before any failure is reported, so a rollback deletes the manifests a failed run left behind
+	if ociConfig.Registry != "" {
+		for _, result := range uploadResults {
+			if result.Uploaded {
+				journal.Record(ctx, journal.Entry{Operation: journal.OperationManifest, Version: version, Registry: ociConfig.Registry, Digest: result.Digest, Name: result.Name})
+			}
+		}
+	}
+
+	// Artifacts given as digests were pushed by an earlier step and only need adding to the index
+	uploadResults = append(uploadResults, ReferenceArtifacts(ctx, client, ociConfig)...)
+	uploadResults = append(uploadResults, ReferenceImages(ctx, client, ociConfig)...)
+
+	// Attached before recording so the referrer digests are in the results file, failing only after upload errors
+	// are reported
+	var contentErr error
+	if ociConfig.ContentManifests {
+		contentErr = AttachContentManifests(ctx, client, workspace, ociConfig.Artifacts, uploadResults)
+	}
+	results.RecordArtifacts(ctx, uploadResults)
+
+	// Every artifact is annotated before failing, so the Annotations panel shows all of them
+	plainHTTP := conn.PlainHTTP || IsLocalRegistry(ociConfig.Registry)
 	for _, result := range uploadResults {
-		if result.Uploaded {
-			logging.Noticef(ctx, "Uploaded %s: %s (os: %s, arch: %s, digest: %s, manifest size: %d bytes)",
-				result.Name, result.Path, result.OS, result.Arch, result.Digest, result.Size)
-		} else {
+		annotateUploadResult(ctx, ociConfig.Registry, plainHTTP, originalPaths[result.Name], result)
+	}
+	for _, result := range uploadResults {
+		if !result.Uploaded {
 			artifactErr := fmt.Errorf("upload failed: %s", result.Error)
 			logging.NoticeErrorWithCategory(ctx, artifactErr, "oci.artifact.upload", map[string]interface{}{
 				"error.operation": "upload_artifact",
@@ -44,26 +127,347 @@ func HandleUploads(ctx context.Context, ociConfig *models.OCIConfig, workspace,
 				"artifact.os":     result.OS,
 				"artifact.arch":   result.Arch,
 				"oci.registry":    ociConfig.Registry,
+				"oci.error_code":  result.ErrorCode,
 			})
-			logging.Errorf(ctx, "Failed to upload %s (%s): %s",
-				result.Name, result.Path, result.Error)
 			return "", fmt.Errorf("artifact upload failed for %s: %s", result.Name, result.Error)
 		}
 	}
 
-	logging.Notice(ctx, "All binaries uploaded successfully")
+	if err := plan.CheckArtifacts(uploadResults); err != nil {
+		logging.NoticeErrorWithCategory(ctx, err, "oci.plan", map[string]interface{}{
+			"error.operation": "check_upload_plan",
+			"oci.registry":    ociConfig.Registry,
+		})
+		return "", err
+	}
+
+	if contentErr != nil {
+		logging.NoticeErrorWithCategory(ctx, contentErr, "oci.content", map[string]interface{}{
+			"error.operation": "attach_content_manifests",
+			"oci.registry":    ociConfig.Registry,
+		})
+		return "", fmt.Errorf("failed to attach content manifests: %w", contentErr)
+	}
+
+	logging.Notice(ctx, "All artifacts are in the registry")
+
+	if ociConfig.HandoffFile != "" {
+		// The index is assembled by another job from the handoffs of every job of the build matrix
+		if err := WriteHandoff(filepath.Join(workspace, ociConfig.HandoffFile), ociConfig.Registry, version, uploadResults); err != nil {
+			return "", err
+		}
+		logging.Noticef(ctx, "Handed off %d artifacts in %s for mode: assemble", len(uploadResults), ociConfig.HandoffFile)
+		return "", nil
+	}
 
 	// Create manifest index to tag uploaded artifacts with version
 	logging.Notice(ctx, "Creating multi-platform manifest index...")
-	indexDigest, err := client.CreateManifestIndex(ctx, uploadResults, version)
+	tag := IndexTag(ociConfig, version)
+	indexEntries := uploadResults
+	if ociConfig.MergeIndex {
+		indexEntries, err = client.MergeIndex(ctx, uploadResults, tag)
+		if err != nil {
+			logging.NoticeErrorWithCategory(ctx, err, "oci.manifest", map[string]interface{}{
+				"error.operation": "merge_manifest_index",
+				"oci.registry":    ociConfig.Registry,
+			})
+			return "", fmt.Errorf("failed to merge into the manifest index: %w", err)
+		}
+		// The manifests kept from the index are recorded as referenced, so the results cover the whole release
+		results.RecordArtifacts(ctx, indexEntries[:len(indexEntries)-len(uploadResults)])
+	}
+	indexDigest, err := client.CreateManifestIndex(ctx, indexEntries, version, tag)
 	if err != nil {
 		logging.NoticeErrorWithCategory(ctx, err, "oci.manifest", map[string]interface{}{
 			"error.operation": "create_manifest_index",
 			"oci.registry":    ociConfig.Registry,
-			"manifest.count":  len(uploadResults),
+			"manifest.count":  len(indexEntries),
 		})
 		return "", fmt.Errorf("failed to create manifest index: %w", err)
 	}
-	logging.Noticef(ctx, "Created manifest index with tag '%s' (digest: %s)", version, indexDigest)
+	logging.Noticef(ctx, "Created manifest index with tag '%s' (digest: %s)", tag, indexDigest)
+	if ociConfig.Registry != "" {
+		journal.Record(ctx, journal.Entry{Operation: journal.OperationIndex, Version: version, Registry: ociConfig.Registry, Tag: tag, Digest: indexDigest})
+	}
+	if err := plan.CheckIndex(indexDigest); err != nil {
+		logging.NoticeErrorWithCategory(ctx, err, "oci.plan", map[string]interface{}{
+			"error.operation": "check_upload_plan",
+			"oci.registry":    ociConfig.Registry,
+		})
+		return "", err
+	}
+	if ociConfig.PendingTag {
+		logging.Noticef(ctx, "The index is pending: run the action with mode: promote and version: %s to tag it '%s'", version, version)
+	}
+	results.RecordIndex(ctx, ociConfig.Registry, tag, indexDigest)
+	if ociConfig.Layout != "" {
+		if ociConfig.Registry != "" {
+			if err := client.ExportLayout(ctx, tag, filepath.Join(workspace, ociConfig.Layout)); err != nil {
+				return "", fmt.Errorf("failed to write the OCI layout: %w", err)
+			}
+		}
+		logging.Noticef(ctx, "Wrote the manifest index with tag '%s' to the OCI layout %s", tag, ociConfig.Layout)
+		results.RecordLayout(ctx, ociConfig.Layout)
+	}
+
+	if releaseNotes != nil {
+		notesDigest, err := client.PushReleaseNotes(ctx, indexDigest, version, releaseNotes)
+		if err != nil {
+			logging.NoticeErrorWithCategory(ctx, err, "oci.manifest", map[string]interface{}{
+				"error.operation": "push_release_notes",
+				"oci.registry":    ociConfig.Registry,
+			})
+			return "", fmt.Errorf("failed to attach release notes: %w", err)
+		}
+		logging.Noticef(ctx, "Attached the release notes of %s to the manifest index (digest: %s)", version, notesDigest)
+		results.RecordReleaseNotes(ctx, notesDigest)
+	}
 	return indexDigest, nil
 }
+
+// preparedUpload is the binaries of an upload once staged, checked, normalized and scanned, ready to push
+type preparedUpload struct {
+	config        *models.OCIConfig
+	originalPaths map[string]string // configured path of each artifact by name, before staging
+	releaseNotes  []byte
+	tempDirs      []string
+}
+
+// cleanup removes the staged and normalized binaries
+func (p *preparedUpload) cleanup() {
+	for _, dir := range p.tempDirs {
+		os.RemoveAll(dir)
+	}
+}
+
+// prepareUploads stages, checks, normalizes and scans the binaries of ociConfig for version, failing before
+// anything is pushed; the caller cleans up the prepared upload
+func prepareUploads(ctx context.Context, ociConfig *models.OCIConfig, workspace, version string) (_ *preparedUpload, err error) {
+	prepared := &preparedUpload{}
+	defer func() {
+		if err != nil {
+			prepared.cleanup()
+		}
+	}()
+
+	scanners, err := scan.New(ociConfig.ScanCommand, ociConfig.ScanURL, ociConfig.ScanToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read up front so a missing changelog section fails the run before anything is pushed
+	if ociConfig.ReleaseNotes != "" {
+		prepared.releaseNotes, err = LoadReleaseNotes(workspace, ociConfig.ReleaseNotes, version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load release notes: %w", err)
+		}
+	}
+
+	// Download artifacts staged in object storage so the rest of the flow only deals with local files
+	stagingDir, err := os.MkdirTemp("", "agent-artifacts-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create artifact staging directory: %w", err)
+	}
+	prepared.tempDirs = append(prepared.tempDirs, stagingDir)
+
+	stagedArtifacts, err := StageRemoteArtifacts(ctx, ociConfig.Artifacts, stagingDir)
+	if err != nil {
+		logging.NoticeErrorWithCategory(ctx, err, "oci.download", map[string]interface{}{
+			"error.operation": "stage_remote_artifacts",
+			"oci.registry":    ociConfig.Registry,
+			"artifact.count":  len(ociConfig.Artifacts),
+		})
+		return nil, fmt.Errorf("artifact download failed: %w", err)
+	}
+	prepared.originalPaths = make(map[string]string, len(ociConfig.Artifacts))
+	for _, artifact := range ociConfig.Artifacts {
+		prepared.originalPaths[artifact.Name] = artifact.Path
+	}
+	stagedConfig := *ociConfig
+	stagedConfig.Artifacts = stagedArtifacts
+	ociConfig = &stagedConfig
+
+	if err := ValidateAllArtifacts(ctx, workspace, ociConfig); err != nil {
+		logging.NoticeErrorWithCategory(ctx, err, "oci.validation", map[string]interface{}{
+			"error.operation": "validate_artifacts",
+			"oci.registry":    ociConfig.Registry,
+			"artifact.count":  len(ociConfig.Artifacts),
+		})
+		return nil, fmt.Errorf("binary validation failed: %w", err)
+	}
+
+	if err := CheckUploadDiskSpace(ctx, workspace, ociConfig.Artifacts, ociConfig.NormalizeArchives); err != nil {
+		logging.NoticeErrorWithCategory(ctx, err, "oci.validation", map[string]interface{}{
+			"error.operation": "check_disk_space",
+			"oci.registry":    ociConfig.Registry,
+			"artifact.count":  len(ociConfig.Artifacts),
+		})
+		return nil, err
+	}
+
+	if ociConfig.NormalizeArchives {
+		normalizedDir, err := os.MkdirTemp("", "agent-normalized-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create artifact normalization directory: %w", err)
+		}
+		prepared.tempDirs = append(prepared.tempDirs, normalizedDir)
+
+		// Normalized before the checks and scans so they see the archives that are uploaded
+		normalizedArtifacts, err := NormalizeArtifacts(ctx, workspace, ociConfig.Artifacts, normalizedDir)
+		if err != nil {
+			logging.NoticeErrorWithCategory(ctx, err, "oci.validation", map[string]interface{}{
+				"error.operation": "normalize_artifacts",
+				"oci.registry":    ociConfig.Registry,
+				"artifact.count":  len(ociConfig.Artifacts),
+			})
+			return nil, fmt.Errorf("binary normalization failed: %w", err)
+		}
+		ociConfig.Artifacts = normalizedArtifacts
+	}
+
+	if err := ValidateJavaAgents(ctx, workspace, ociConfig.Artifacts, version); err != nil {
+		logging.NoticeErrorWithCategory(ctx, err, "oci.validation", map[string]interface{}{
+			"error.operation": "validate_java_agents",
+			"oci.registry":    ociConfig.Registry,
+			"artifact.count":  len(ociConfig.Artifacts),
+		})
+		return nil, fmt.Errorf("java agent validation failed: %w", err)
+	}
+
+	if err := CheckLegalFiles(ctx, workspace, ociConfig.RequiredLegalFiles, ociConfig.Artifacts); err != nil {
+		logging.NoticeErrorWithCategory(ctx, err, "oci.validation", map[string]interface{}{
+			"error.operation": "check_legal_files",
+			"oci.registry":    ociConfig.Registry,
+			"artifact.count":  len(ociConfig.Artifacts),
+		})
+		return nil, fmt.Errorf("legal file check failed: %w", err)
+	}
+
+	if err := CheckAuthenticode(ctx, workspace, ociConfig.Artifacts); err != nil {
+		logging.NoticeErrorWithCategory(ctx, err, "oci.validation", map[string]interface{}{
+			"error.operation": "check_authenticode",
+			"oci.registry":    ociConfig.Registry,
+			"artifact.count":  len(ociConfig.Artifacts),
+		})
+		return nil, fmt.Errorf("authenticode check failed: %w", err)
+	}
+
+	if err := CheckNotarization(ctx, workspace, ociConfig.Artifacts, ociConfig.NotarizationStrict); err != nil {
+		logging.NoticeErrorWithCategory(ctx, err, "oci.validation", map[string]interface{}{
+			"error.operation": "check_notarization",
+			"oci.registry":    ociConfig.Registry,
+			"artifact.count":  len(ociConfig.Artifacts),
+		})
+		return nil, fmt.Errorf("notarization check failed: %w", err)
+	}
+
+	// Scanned after staging, so binaries downloaded from object storage are scanned too
+	if err := ScanArtifacts(ctx, scanners, workspace, ociConfig.Artifacts); err != nil {
+		logging.NoticeErrorWithCategory(ctx, err, "oci.scan", map[string]interface{}{
+			"error.operation": "scan_artifacts",
+			"oci.registry":    ociConfig.Registry,
+			"artifact.count":  len(ociConfig.Artifacts),
+		})
+		return nil, fmt.Errorf("binary scan failed: %w", err)
+	}
+
+	prepared.config = ociConfig
+	return prepared, nil
+}
+
+// HandlePlan stages, checks, normalizes and scans the binaries of ociConfig for version like HandleUploads, then plans
+// their upload without pushing anything or reaching the registry
+func HandlePlan(ctx context.Context, ociConfig *models.OCIConfig, workspace, version string) (Plan, error) {
+	prepared, err := prepareUploads(ctx, ociConfig, workspace, version)
+	if err != nil {
+		return Plan{}, err
+	}
+	defer prepared.cleanup()
+
+	// Planning doesn't reach the registry, so uploads to an OCI layout alone are planned without opening it
+	client := &Client{}
+	if ociConfig.Registry != "" {
+		client, err = newClientFunc(ctx, ociConfig.Registry, ociConfig.Username, ociConfig.Password, ConnectionFor(ociConfig))
+		if err != nil {
+			return Plan{}, fmt.Errorf("failed to create OCI client: %w", err)
+		}
+	}
+	if err := configureClient(ctx, client, ociConfig, workspace); err != nil {
+		return Plan{}, err
+	}
+	return planUpload(ctx, client, prepared, workspace, version)
+}
+
+// configureClient applies the upload settings of ociConfig to client
+// Reproducible uploads are stamped with the date of the commit in workspace and only annotated with the build context
+// the commit determines, so uploading the same commit again pushes the same digests
+func configureClient(ctx context.Context, client *Client, ociConfig *models.OCIConfig, workspace string) error {
+	client.maxBlobSize = ociConfig.MaxBlobSize
+	client.annotations = ociConfig.Annotations
+	client.build = CreateBuildAnnotations()
+	if !ociConfig.IsReproducible() {
+		return nil
+	}
+
+	created, err := github.CommitDateFunc(ctx, workspace)
+	if err != nil {
+		return fmt.Errorf("failed to read the commit date for oci-created-source %s: %w", ociConfig.CreatedSource, err)
+	}
+	client.created = created.UTC()
+	for key := range client.build {
+		if key != BuildRepositoryAnnotation && key != BuildSHAAnnotation {
+			delete(client.build, key)
+		}
+	}
+	logging.Debugf(ctx, "Stamping manifests with the commit date %s", client.created.Format(time.RFC3339))
+	return nil
+}
+
+// planUpload plans the upload of the prepared binaries with client, reporting artifacts by their configured paths,
+// and writes the plan to the upload-plan-file if one is set
+func planUpload(ctx context.Context, client *Client, prepared *preparedUpload, workspace, version string) (Plan, error) {
+	plan, err := client.Plan(ctx, prepared.config, workspace, version)
+	if err != nil {
+		logging.NoticeErrorWithCategory(ctx, err, "oci.plan", map[string]interface{}{
+			"error.operation": "plan_upload",
+			"oci.registry":    prepared.config.Registry,
+			"artifact.count":  len(prepared.config.Artifacts),
+		})
+		return Plan{}, fmt.Errorf("upload planning failed: %w", err)
+	}
+	for i := range plan.Artifacts {
+		if !plan.Artifacts[i].Referenced {
+			plan.Artifacts[i].Path = prepared.originalPaths[plan.Artifacts[i].Name]
+		}
+	}
+	if plan.Index != nil {
+		logging.Noticef(ctx, "Planned the upload of %d binaries as manifest index %s", len(plan.Artifacts), plan.Index.Digest)
+	} else {
+		logging.Noticef(ctx, "Planned the upload of %d binaries; the index digest is only known once the pre-pushed manifests are resolved", len(plan.Artifacts))
+	}
+
+	if prepared.config.PlanFile != "" {
+		if err := WritePlan(filepath.Join(workspace, prepared.config.PlanFile), plan); err != nil {
+			return Plan{}, err
+		}
+		logging.Noticef(ctx, "Wrote the upload plan to %s", prepared.config.PlanFile)
+	}
+	return plan, nil
+}
+
+// probeLayerMediaTypes checks the registry accepts the layer media type of every zstd artifact before anything is
+// uploaded, suggesting tar+gzip for registries that don't
+func probeLayerMediaTypes(ctx context.Context, client *Client, ociConfig *models.OCIConfig) error {
+	probed := map[string]bool{}
+	for _, artifact := range ociConfig.Artifacts {
+		if artifact.IsReference() || !artifact.IsZstd() || probed[artifact.GetMediaType()] {
+			continue
+		}
+		probed[artifact.GetMediaType()] = true
+		if err := client.ProbeLayerMediaType(ctx, artifact.GetMediaType()); err != nil {
+			return fmt.Errorf("%w; upload artifact '%s' as tar+gzip instead", err, artifact.Name)
+		}
+		logging.Debugf(ctx, "Registry %s accepts %s layers", ociConfig.Registry, artifact.GetMediaType())
+	}
+	return nil
+}
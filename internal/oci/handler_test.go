@@ -2,10 +2,17 @@ package oci
 
 import (
 	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+	"agent-metadata-action/internal/testutil"
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHasFailures(t *testing.T) {
@@ -89,3 +96,150 @@ func TestHandleUploads_ValidationFailure(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "binary validation failed")
 }
+
+func TestHandleUploads(t *testing.T) {
+	testutil.CaptureOutput(t)
+	const registry = "ghcr.io/newrelic/agents"
+	setup := func(t *testing.T) (map[string]*memoryRegistry, string) {
+		workspace := t.TempDir()
+		writeArchive(t, workspace, "agent-linux-amd64.tar.gz", "tar+gzip", "newrelic/agent")
+		writeArchive(t, workspace, "agent-linux-arm64.tar.gz", "tar+gzip", "newrelic/agent", "newrelic/arm64")
+		writeArchive(t, workspace, "agent-windows-amd64.zip", "zip", "newrelic/agent.exe")
+		return useMemoryRegistries(t), workspace
+	}
+	linux := models.ArtifactDefinition{Name: "linux-amd64", Path: "./agent-linux-amd64.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip"}
+	arm := models.ArtifactDefinition{Name: "linux-arm64", Path: "./agent-linux-arm64.tar.gz", OS: "linux", Arch: "arm64", Format: "tar+gzip"}
+	windows := models.ArtifactDefinition{Name: "windows-amd64", Path: "./agent-windows-amd64.zip", OS: "windows", Arch: "amd64", Format: "zip"}
+
+	t.Run("pushes every platform and tags the index with the version", func(t *testing.T) {
+		registries, workspace := setup(t)
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+		ociConfig := &models.OCIConfig{Registry: registry, Artifacts: []models.ArtifactDefinition{linux, arm, windows}}
+
+		// method under test
+		indexDigest, err := HandleUploads(ctx, ociConfig, workspace, "1.2.3")
+
+		require.NoError(t, err)
+		index := registries[registry].index(t, "1.2.3")
+		assert.Equal(t, "1.2.3", index.Annotations[ocispec.AnnotationVersion])
+		require.Len(t, index.Manifests, 3)
+		for i, artifact := range []models.ArtifactDefinition{linux, arm, windows} {
+			manifestDesc := index.Manifests[i]
+			assert.Equal(t, &ocispec.Platform{OS: artifact.OS, Architecture: artifact.Arch}, manifestDesc.Platform)
+			assert.Equal(t, "application/vnd.newrelic.agent.v1", manifestDesc.ArtifactType)
+			manifest := registries[registry].manifest(t, manifestDesc.Digest)
+			require.Len(t, manifest.Layers, 1)
+			assert.Equal(t, artifact.GetFilename(), manifest.Layers[0].Annotations[ocispec.AnnotationTitle])
+			assert.Equal(t, "application/vnd.newrelic.agent.content.v1."+artifact.Format, manifest.Layers[0].MediaType)
+		}
+
+		recorded := recorder.Results()
+		assert.Equal(t, &results.Index{Registry: registry, Tag: "1.2.3", Digest: indexDigest}, recorded.Index)
+		assert.Len(t, recorded.Artifacts, 3)
+	})
+
+	t.Run("pending tag", func(t *testing.T) {
+		registries, workspace := setup(t)
+		ociConfig := &models.OCIConfig{Registry: registry, Artifacts: []models.ArtifactDefinition{linux}, PendingTag: true}
+
+		// method under test
+		indexDigest, err := HandleUploads(context.Background(), ociConfig, workspace, "1.2.3")
+
+		require.NoError(t, err)
+		assert.Equal(t, indexDigest, registries[registry].tags["1.2.3-pending"].String())
+		assert.NotContains(t, registries[registry].tags, "1.2.3", "Promotion tags the version")
+	})
+
+	t.Run("merges into the index of the version", func(t *testing.T) {
+		registries, workspace := setup(t)
+		_, err := HandleUploads(context.Background(), &models.OCIConfig{Registry: registry, Artifacts: []models.ArtifactDefinition{linux, windows}}, workspace, "1.2.3")
+		require.NoError(t, err)
+		released := registries[registry].index(t, "1.2.3")
+
+		// method under test
+		_, err = HandleUploads(context.Background(), &models.OCIConfig{Registry: registry, Artifacts: []models.ArtifactDefinition{arm}, MergeIndex: true}, workspace, "1.2.3")
+
+		require.NoError(t, err)
+		merged := registries[registry].index(t, "1.2.3")
+		require.Len(t, merged.Manifests, 3)
+		assert.Equal(t, released.Manifests[0].Digest, merged.Manifests[0].Digest)
+		assert.Equal(t, "arm64", merged.Manifests[1].Platform.Architecture)
+		assert.Equal(t, released.Manifests[1].Digest, merged.Manifests[2].Digest)
+	})
+
+	t.Run("hands off the manifests without an index", func(t *testing.T) {
+		registries, workspace := setup(t)
+		ociConfig := &models.OCIConfig{Registry: registry, Artifacts: []models.ArtifactDefinition{linux}, HandoffFile: "handoff/linux-amd64.json"}
+
+		// method under test
+		indexDigest, err := HandleUploads(context.Background(), ociConfig, workspace, "1.2.3")
+
+		require.NoError(t, err)
+		assert.Empty(t, indexDigest)
+		assert.Empty(t, registries[registry].tags, "The assemble job pushes the index")
+		handoffs, err := ReadHandoffs(filepath.Join(workspace, "handoff"))
+		require.NoError(t, err)
+		require.Len(t, handoffs, 1)
+		require.Len(t, handoffs[0].Artifacts, 1)
+		assert.Contains(t, registries[registry].descs, digestOf(t, handoffs[0].Artifacts[0].Digest))
+	})
+
+	t.Run("writes to an OCI layout alone", func(t *testing.T) {
+		registries, workspace := setup(t)
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+		ociConfig := &models.OCIConfig{Layout: "oci-layout", Artifacts: []models.ArtifactDefinition{linux, windows}}
+
+		// method under test
+		indexDigest, err := HandleUploads(ctx, ociConfig, workspace, "1.2.3")
+
+		require.NoError(t, err)
+		assert.Empty(t, registries, "No registry is reached")
+		layout, err := NewLayoutClient(context.Background(), filepath.Join(workspace, "oci-layout"))
+		require.NoError(t, err)
+		target, err := layout.ResolveIndex(context.Background(), "1.2.3")
+		require.NoError(t, err)
+		assert.Equal(t, indexDigest, target.Index.Digest.String())
+		assert.Len(t, target.Manifests, 2)
+
+		recorded := recorder.Results()
+		assert.Nil(t, recorded.Registry)
+		assert.Equal(t, &results.Index{Tag: "1.2.3", Digest: indexDigest, Layout: "oci-layout"}, recorded.Index)
+	})
+
+	t.Run("writes to an OCI layout in addition to the registry", func(t *testing.T) {
+		registries, workspace := setup(t)
+		ociConfig := &models.OCIConfig{Registry: registry, Layout: "oci-layout", Artifacts: []models.ArtifactDefinition{linux}, PendingTag: true}
+
+		// method under test
+		indexDigest, err := HandleUploads(context.Background(), ociConfig, workspace, "1.2.3")
+
+		require.NoError(t, err)
+		assert.Equal(t, indexDigest, registries[registry].tags["1.2.3-pending"].String())
+		layout, err := NewLayoutClient(context.Background(), filepath.Join(workspace, "oci-layout"))
+		require.NoError(t, err)
+		target, err := layout.ResolveIndex(context.Background(), "1.2.3-pending")
+		require.NoError(t, err)
+		assert.Equal(t, indexDigest, target.Index.Digest.String())
+	})
+
+	t.Run("missing binary pushes nothing", func(t *testing.T) {
+		registries, workspace := setup(t)
+		require.NoError(t, os.Remove(filepath.Join(workspace, "agent-linux-amd64.tar.gz")))
+		ociConfig := &models.OCIConfig{Registry: registry, Artifacts: []models.ArtifactDefinition{linux}}
+
+		// method under test
+		_, err := HandleUploads(context.Background(), ociConfig, workspace, "1.2.3")
+
+		assert.ErrorContains(t, err, "binary validation failed")
+		assert.Empty(t, registries)
+	})
+}
+
+// digestOf parses a digest for looking it up in a memory registry
+func digestOf(t *testing.T, s string) digest.Digest {
+	d, err := digest.Parse(s)
+	require.NoError(t, err)
+	return d
+}
@@ -0,0 +1,214 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"agent-metadata-action/internal/journal"
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+	"agent-metadata-action/internal/validation"
+)
+
+// HandoffSchemaVersion is the version of the handoff file format
+const HandoffSchemaVersion = 1
+
+// Handoff describes the artifacts one job of a build matrix uploaded for a version, written with mode: collect for a
+// final job to list in the manifest index with mode: assemble
+type Handoff struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	Registry      string            `json:"registry"`
+	Version       string            `json:"version"`
+	Artifacts     []HandoffArtifact `json:"artifacts"`
+
+	path string // file the handoff was read from, relative to the handoff directory
+}
+
+// HandoffArtifact is the manifest of an uploaded artifact, or of a manifest or image referenced by digest, in a handoff
+type HandoffArtifact struct {
+	Name       string `json:"name"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+	Digest     string `json:"digest"`
+	Size       int64  `json:"size"`
+	MediaType  string `json:"mediaType,omitempty"`  // empty for the manifests of uploaded binaries
+	Referenced bool   `json:"referenced,omitempty"` // already in the registry before the upload
+}
+
+// WriteHandoff writes the artifacts uploaded to registry for version as a handoff file at path, creating its directory
+func WriteHandoff(path, registry, version string, uploadResults []models.ArtifactUploadResult) error {
+	handoff := Handoff{SchemaVersion: HandoffSchemaVersion, Registry: registry, Version: version}
+	for _, result := range uploadResults {
+		handoff.Artifacts = append(handoff.Artifacts, HandoffArtifact{
+			Name:       result.Name,
+			OS:         result.OS,
+			Arch:       result.Arch,
+			Digest:     result.Digest,
+			Size:       result.Size,
+			MediaType:  result.MediaType,
+			Referenced: result.Referenced,
+		})
+	}
+	data, err := json.MarshalIndent(handoff, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal handoff: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create handoff directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// ReadHandoffs reads every .json file below dir as a handoff, in path order
+// actions/download-artifact puts each workflow artifact in a directory of its own, so subdirectories are read too
+func ReadHandoffs(dir string) ([]Handoff, error) {
+	var handoffs []Handoff
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || filepath.Ext(path) != ".json" {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		relative, _ := filepath.Rel(dir, path)
+		var handoff Handoff
+		if err := json.Unmarshal(data, &handoff); err != nil {
+			return fmt.Errorf("failed to parse handoff %s: %w", relative, err)
+		}
+		if handoff.SchemaVersion != HandoffSchemaVersion {
+			return fmt.Errorf("handoff %s has schemaVersion %d, expected %d", relative, handoff.SchemaVersion, HandoffSchemaVersion)
+		}
+		handoff.path = relative
+		handoffs = append(handoffs, handoff)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(handoffs, func(i, j int) bool { return handoffs[i].path < handoffs[j].path })
+	return handoffs, nil
+}
+
+// assembleEntries returns the artifacts of the handoffs as the entries of the manifest index of version in registry
+// Every handoff for another registry or version and every artifact name or platform handed off twice is reported
+func assembleEntries(handoffs []Handoff, registry, version string) ([]models.ArtifactUploadResult, error) {
+	var errs validation.Errors
+	names := map[string]string{}
+	platforms := map[string]string{}
+	var entries []models.ArtifactUploadResult
+	for _, handoff := range handoffs {
+		if handoff.Registry != registry || handoff.Version != version {
+			errs.Addf(handoff.path, 0, "", "is for %s version %s rather than %s version %s", handoff.Registry, handoff.Version, registry, version)
+			continue
+		}
+		for i, artifact := range handoff.Artifacts {
+			field := fmt.Sprintf("artifacts[%d]", i)
+			if _, err := parseDigest(artifact.Digest); err != nil {
+				errs.Add(handoff.path, 0, field, err)
+				continue
+			}
+			if other, ok := names[artifact.Name]; ok {
+				errs.Addf(handoff.path, 0, field, "%s is also handed off by %s", artifact.Name, other)
+				continue
+			}
+			names[artifact.Name] = handoff.path
+			// Nested image indexes carry the platforms of their own entries, so only manifests are listed per platform
+			if !isIndexMediaType(artifact.MediaType) {
+				platform := artifact.OS + "/" + artifact.Arch
+				if other, ok := platforms[platform]; ok {
+					errs.Addf(handoff.path, 0, field, "%s is a second %s artifact, after one from %s", artifact.Name, platform, other)
+					continue
+				}
+				platforms[platform] = handoff.path
+			}
+			entries = append(entries, models.ArtifactUploadResult{
+				Name:       artifact.Name,
+				OS:         artifact.OS,
+				Arch:       artifact.Arch,
+				Digest:     artifact.Digest,
+				Size:       artifact.Size,
+				MediaType:  artifact.MediaType,
+				Uploaded:   true,
+				Referenced: artifact.Referenced,
+			})
+		}
+	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// HandleAssemble pushes the manifest index of version listing the artifacts handed off in handoffDir, relative to the
+// workspace, by the jobs of a build matrix, once each is found in the registry
+// Dry runs only read and check the handoffs
+func HandleAssemble(ctx context.Context, ociConfig *models.OCIConfig, workspace, handoffDir, version string, dryRun bool) (string, error) {
+	handoffs, err := ReadHandoffs(filepath.Join(workspace, handoffDir))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the handoffs in %s: %w", handoffDir, err)
+	}
+	if len(handoffs) == 0 {
+		return "", fmt.Errorf("no handoff files in %s: download the handoffs of the collect jobs there first", handoffDir)
+	}
+	entries, err := assembleEntries(handoffs, ociConfig.Registry, version)
+	if err != nil {
+		return "", err
+	}
+	logging.Noticef(ctx, "Read %d artifacts from %d handoff files in %s", len(entries), len(handoffs), handoffDir)
+	if dryRun {
+		logging.Noticef(ctx, "Dry run - not assembling the manifest index of %s", version)
+		return "", nil
+	}
+
+	conn := ConnectionFor(ociConfig)
+	WarnInsecureConnection(ctx, ociConfig.Registry, conn)
+	results.RecordRegistry(ctx, results.Registry{
+		URL:                   ociConfig.Registry,
+		PlainHTTP:             conn.PlainHTTP || IsLocalRegistry(ociConfig.Registry),
+		InsecureSkipTLSVerify: conn.InsecureSkipTLSVerify,
+	})
+	client, err := newClientFunc(ctx, ociConfig.Registry, ociConfig.Username, ociConfig.Password, conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to create OCI client: %w", err)
+	}
+	if err := configureClient(ctx, client, ociConfig, workspace); err != nil {
+		return "", err
+	}
+
+	// A handoff from a failed or re-run job may name a manifest that was never pushed
+	for _, entry := range entries {
+		desc, err := client.repo.Resolve(ctx, entry.Digest)
+		if err != nil {
+			return "", fmt.Errorf("%s (%s) isn't in %s: %w", entry.Name, entry.Digest, ociConfig.Registry, err)
+		}
+		if desc.Size != entry.Size {
+			return "", fmt.Errorf("%s (%s) is %d bytes in %s rather than %d as handed off", entry.Name, entry.Digest, desc.Size, ociConfig.Registry, entry.Size)
+		}
+	}
+	results.RecordArtifacts(ctx, entries)
+
+	tag := IndexTag(ociConfig, version)
+	indexDigest, err := client.CreateManifestIndex(ctx, entries, version, tag)
+	if err != nil {
+		logging.NoticeErrorWithCategory(ctx, err, "oci.manifest", map[string]interface{}{
+			"error.operation": "assemble_manifest_index",
+			"oci.registry":    ociConfig.Registry,
+			"manifest.count":  len(entries),
+		})
+		return "", fmt.Errorf("failed to create manifest index: %w", err)
+	}
+	logging.Noticef(ctx, "Assembled the manifest index of %d artifacts with tag '%s' (digest: %s)", len(entries), tag, indexDigest)
+	journal.Record(ctx, journal.Entry{Operation: journal.OperationIndex, Version: version, Registry: ociConfig.Registry, Tag: tag, Digest: indexDigest})
+	results.RecordIndex(ctx, ociConfig.Registry, tag, indexDigest)
+	return indexDigest, nil
+}
@@ -0,0 +1,201 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+	"agent-metadata-action/internal/testutil"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteHandoff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "handoff", "linux-amd64.json")
+	linux := "sha256:" + strings.Repeat("1", 64)
+
+	// method under test
+	err := WriteHandoff(path, "docker.io/newrelic/agents", "1.2.3", []models.ArtifactUploadResult{
+		{Name: "linux-amd64", Path: "./dist/agent.tar.gz", OS: "linux", Arch: "amd64", Digest: linux, Size: 100, Uploaded: true},
+	})
+
+	require.NoError(t, err)
+	handoffs, err := ReadHandoffs(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, handoffs, 1)
+	assert.Equal(t, "docker.io/newrelic/agents", handoffs[0].Registry)
+	assert.Equal(t, "1.2.3", handoffs[0].Version)
+	assert.Equal(t, []HandoffArtifact{{Name: "linux-amd64", OS: "linux", Arch: "amd64", Digest: linux, Size: 100}}, handoffs[0].Artifacts)
+}
+
+func TestReadHandoffs(t *testing.T) {
+	t.Run("reads subdirectories in path order", func(t *testing.T) {
+		dir := t.TempDir()
+		writeHandoffFile(t, filepath.Join(dir, "windows", "handoff.json"), Handoff{SchemaVersion: HandoffSchemaVersion, Version: "windows"})
+		writeHandoffFile(t, filepath.Join(dir, "linux", "handoff.json"), Handoff{SchemaVersion: HandoffSchemaVersion, Version: "linux"})
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a handoff"), 0644))
+
+		// method under test
+		handoffs, err := ReadHandoffs(dir)
+
+		require.NoError(t, err)
+		require.Len(t, handoffs, 2)
+		assert.Equal(t, "linux", handoffs[0].Version)
+		assert.Equal(t, "windows", handoffs[1].Version)
+	})
+
+	t.Run("rejects other schema versions", func(t *testing.T) {
+		dir := t.TempDir()
+		writeHandoffFile(t, filepath.Join(dir, "handoff.json"), Handoff{SchemaVersion: 2})
+
+		// method under test
+		_, err := ReadHandoffs(dir)
+
+		assert.ErrorContains(t, err, "handoff handoff.json has schemaVersion 2, expected 1")
+	})
+
+	t.Run("rejects invalid JSON", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "handoff.json"), []byte("{"), 0644))
+
+		// method under test
+		_, err := ReadHandoffs(dir)
+
+		assert.ErrorContains(t, err, "failed to parse handoff handoff.json")
+	})
+}
+
+func TestAssembleEntries(t *testing.T) {
+	linux := "sha256:" + strings.Repeat("1", 64)
+	windows := "sha256:" + strings.Repeat("2", 64)
+	handoff := func(path, version string, artifacts ...HandoffArtifact) Handoff {
+		return Handoff{SchemaVersion: HandoffSchemaVersion, Registry: "docker.io/newrelic/agents", Version: version, Artifacts: artifacts, path: path}
+	}
+
+	t.Run("lists the artifacts of every handoff", func(t *testing.T) {
+		// method under test
+		entries, err := assembleEntries([]Handoff{
+			handoff("linux.json", "1.2.3", HandoffArtifact{Name: "linux-amd64", OS: "linux", Arch: "amd64", Digest: linux, Size: 100}),
+			handoff("windows.json", "1.2.3", HandoffArtifact{Name: "windows-amd64", OS: "windows", Arch: "amd64", Digest: windows, Size: 200}),
+		}, "docker.io/newrelic/agents", "1.2.3")
+
+		require.NoError(t, err)
+		assert.Equal(t, []models.ArtifactUploadResult{
+			{Name: "linux-amd64", OS: "linux", Arch: "amd64", Digest: linux, Size: 100, Uploaded: true},
+			{Name: "windows-amd64", OS: "windows", Arch: "amd64", Digest: windows, Size: 200, Uploaded: true},
+		}, entries)
+	})
+
+	t.Run("reports every conflict", func(t *testing.T) {
+		// method under test
+		_, err := assembleEntries([]Handoff{
+			handoff("linux.json", "1.2.3", HandoffArtifact{Name: "linux-amd64", OS: "linux", Arch: "amd64", Digest: linux, Size: 100}),
+			handoff("rerun.json", "1.2.3",
+				HandoffArtifact{Name: "linux-amd64", OS: "linux", Arch: "arm64", Digest: linux, Size: 100},
+				HandoffArtifact{Name: "linux", OS: "linux", Arch: "amd64", Digest: windows, Size: 200},
+				HandoffArtifact{Name: "broken", OS: "linux", Arch: "s390x", Digest: "sha256:nope"}),
+			handoff("old.json", "1.2.2", HandoffArtifact{Name: "windows-amd64", OS: "windows", Arch: "amd64", Digest: windows, Size: 200}),
+		}, "docker.io/newrelic/agents", "1.2.3")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "4 problems found")
+		assert.Contains(t, err.Error(), "rerun.json: artifacts[0]: linux-amd64 is also handed off by linux.json")
+		assert.Contains(t, err.Error(), "rerun.json: artifacts[1]: linux is a second linux/amd64 artifact, after one from linux.json")
+		assert.Contains(t, err.Error(), "rerun.json: artifacts[2]: ")
+		assert.Contains(t, err.Error(), "old.json: is for docker.io/newrelic/agents version 1.2.2 rather than docker.io/newrelic/agents version 1.2.3")
+	})
+}
+
+func TestHandleAssemble(t *testing.T) {
+	testutil.CaptureOutput(t)
+	setup := func(t *testing.T) (*manifestRegistry, *models.OCIConfig, string) {
+		registry := newManifestRegistry()
+		server := httptest.NewServer(registry)
+		t.Cleanup(server.Close)
+		return registry, &models.OCIConfig{Registry: strings.TrimPrefix(server.URL, "http://") + "/agents"}, t.TempDir()
+	}
+	handOff := func(t *testing.T, workspace, name string, ociConfig *models.OCIConfig, artifacts ...HandoffArtifact) {
+		writeHandoffFile(t, filepath.Join(workspace, "handoffs", name, "handoff.json"),
+			Handoff{SchemaVersion: HandoffSchemaVersion, Registry: ociConfig.Registry, Version: "1.2.3", Artifacts: artifacts})
+	}
+	linuxManifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","layers":[]}`)
+	windowsManifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","annotations":{"os":"windows"},"layers":[]}`)
+
+	t.Run("pushes the index of every handoff", func(t *testing.T) {
+		registry, ociConfig, workspace := setup(t)
+		linux := registry.add("linux", ocispec.MediaTypeImageManifest, linuxManifest)
+		windows := registry.add("windows", ocispec.MediaTypeImageManifest, windowsManifest)
+		handOff(t, workspace, "linux", ociConfig, HandoffArtifact{Name: "linux-amd64", OS: "linux", Arch: "amd64", Digest: linux, Size: int64(len(linuxManifest))})
+		handOff(t, workspace, "windows", ociConfig, HandoffArtifact{Name: "windows-amd64", OS: "windows", Arch: "amd64", Digest: windows, Size: int64(len(windowsManifest))})
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+
+		// method under test
+		indexDigest, err := HandleAssemble(ctx, ociConfig, workspace, "handoffs", "1.2.3", false)
+
+		require.NoError(t, err)
+		assert.Equal(t, indexDigest, registry.tags["1.2.3"])
+		var pushed ocispec.Index
+		require.NoError(t, json.Unmarshal(registry.manifests[indexDigest], &pushed))
+		require.Len(t, pushed.Manifests, 2)
+		assert.Equal(t, linux, pushed.Manifests[0].Digest.String())
+		assert.Equal(t, &ocispec.Platform{OS: "linux", Architecture: "amd64"}, pushed.Manifests[0].Platform)
+		assert.Equal(t, windows, pushed.Manifests[1].Digest.String())
+
+		recorded := recorder.Results()
+		assert.Equal(t, &results.Index{Registry: ociConfig.Registry, Tag: "1.2.3", Digest: indexDigest}, recorded.Index)
+		assert.Len(t, recorded.Artifacts, 2)
+	})
+
+	t.Run("dry run pushes nothing", func(t *testing.T) {
+		registry, ociConfig, workspace := setup(t)
+		handOff(t, workspace, "linux", ociConfig, HandoffArtifact{Name: "linux-amd64", OS: "linux", Arch: "amd64", Digest: "sha256:" + strings.Repeat("1", 64), Size: 100})
+
+		// method under test
+		indexDigest, err := HandleAssemble(context.Background(), ociConfig, workspace, "handoffs", "1.2.3", true)
+
+		require.NoError(t, err)
+		assert.Empty(t, indexDigest)
+		assert.Empty(t, registry.tags)
+	})
+
+	t.Run("handed off manifest missing from the registry", func(t *testing.T) {
+		registry, ociConfig, workspace := setup(t)
+		missing := "sha256:" + strings.Repeat("1", 64)
+		handOff(t, workspace, "linux", ociConfig, HandoffArtifact{Name: "linux-amd64", OS: "linux", Arch: "amd64", Digest: missing, Size: 100})
+
+		// method under test
+		_, err := HandleAssemble(context.Background(), ociConfig, workspace, "handoffs", "1.2.3", false)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "linux-amd64 ("+missing+") isn't in "+ociConfig.Registry)
+		assert.Empty(t, registry.tags)
+	})
+
+	t.Run("no handoffs", func(t *testing.T) {
+		_, ociConfig, workspace := setup(t)
+		require.NoError(t, os.MkdirAll(filepath.Join(workspace, "handoffs"), 0755))
+
+		// method under test
+		_, err := HandleAssemble(context.Background(), ociConfig, workspace, "handoffs", "1.2.3", false)
+
+		assert.ErrorContains(t, err, "no handoff files in handoffs")
+	})
+}
+
+// writeHandoffFile writes handoff as JSON to path, creating its directory
+func writeHandoffFile(t *testing.T, path string, handoff Handoff) {
+	t.Helper()
+	data, err := json.Marshal(handoff)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, data, 0644))
+}
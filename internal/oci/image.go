@@ -0,0 +1,90 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ImageImporter brings already-built container images into the repository of the agent index
+type ImageImporter interface {
+	ImportImage(ctx context.Context, repository, imageDigest string) (mediaType string, size int64, err error)
+}
+
+// ReferenceImages adds the container images of image artifacts to the manifest index without uploading any file
+// An index can only list manifests of its own repository, so images built elsewhere are copied into it by digest
+// first. Artifacts that aren't images are skipped.
+func ReferenceImages(ctx context.Context, importer ImageImporter, config *models.OCIConfig) []models.ArtifactUploadResult {
+	results := make([]models.ArtifactUploadResult, 0)
+
+	for _, artifact := range config.Artifacts {
+		if !artifact.IsImage() {
+			continue
+		}
+
+		result := models.ArtifactUploadResult{
+			Name:       artifact.Name,
+			Path:       artifact.Image,
+			OS:         artifact.OS,
+			Arch:       artifact.Arch,
+			Format:     artifact.Format,
+			Referenced: true,
+		}
+
+		mediaType, size, err := importer.ImportImage(ctx, artifact.ImageRepository(), artifact.ImageDigest())
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Digest = artifact.ImageDigest()
+			result.Size = size
+			result.MediaType = mediaType
+			result.Uploaded = true
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// ImportImage resolves the image imageDigest in repository, copying it into the repository of c unless it's already
+// there, and returns its media type and size
+// Single-platform manifests and multi-platform indexes are both accepted. Images on the registry host of c are
+// pulled with its credentials and connection, images elsewhere anonymously.
+func (c *Client) ImportImage(ctx context.Context, repository, imageDigest string) (string, int64, error) {
+	source := c
+	if repository != c.registry {
+		var username, password string
+		var conn Connection
+		if registryHost(repository) == registryHost(c.registry) {
+			username, password, conn = c.username, c.password, c.conn
+		}
+		var err error
+		source, err = newClientFunc(ctx, repository, username, password, conn)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to create OCI client for %s: %w", repository, err)
+		}
+	}
+
+	desc, err := source.repo.Resolve(ctx, imageDigest)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to resolve %s@%s: %w", repository, imageDigest, err)
+	}
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageManifest, dockerManifestMediaType, ocispec.MediaTypeImageIndex, dockerManifestListMediaType:
+	default:
+		return "", 0, fmt.Errorf("%s@%s has media type %s rather than being a container image", repository, imageDigest, desc.MediaType)
+	}
+
+	if source != c {
+		logging.Debugf(ctx, "Copying image %s@%s into %s", repository, imageDigest, c.registry)
+		if err := c.copyFrom(ctx, source, desc, "OCI image import"); err != nil {
+			return "", 0, err
+		}
+	}
+	return desc.MediaType, desc.Size, nil
+}
@@ -0,0 +1,176 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockImporter is a mock implementation of ImageImporter for testing
+type mockImporter struct {
+	importFunc func(ctx context.Context, repository, imageDigest string) (string, int64, error)
+}
+
+func (m *mockImporter) ImportImage(ctx context.Context, repository, imageDigest string) (string, int64, error) {
+	return m.importFunc(ctx, repository, imageDigest)
+}
+
+func TestReferenceImages(t *testing.T) {
+	config := &models.OCIConfig{
+		Artifacts: []models.ArtifactDefinition{
+			{Name: "local", Path: "./dist/agent.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip"},
+			{Name: "image-amd64", Digest: testManifestDigest, OS: "linux", Arch: "amd64"},
+			{Name: "container", Kind: models.KindImage, Image: "docker.io/newrelic/infrastructure@" + testManifestDigest, OS: "linux", Arch: "any"},
+		},
+	}
+
+	importer := &mockImporter{importFunc: func(ctx context.Context, repository, imageDigest string) (string, int64, error) {
+		assert.Equal(t, "docker.io/newrelic/infrastructure", repository)
+		assert.Equal(t, testManifestDigest, imageDigest)
+		return ocispec.MediaTypeImageIndex, 1024, nil
+	}}
+
+	// method under test
+	results := ReferenceImages(context.Background(), importer, config)
+
+	require.Len(t, results, 1)
+	assert.Equal(t, "container", results[0].Name)
+	assert.Equal(t, "docker.io/newrelic/infrastructure@"+testManifestDigest, results[0].Path)
+	assert.Equal(t, testManifestDigest, results[0].Digest)
+	assert.Equal(t, ocispec.MediaTypeImageIndex, results[0].MediaType)
+	assert.Equal(t, int64(1024), results[0].Size)
+	assert.True(t, results[0].Referenced)
+	assert.True(t, results[0].Uploaded)
+	assert.Empty(t, ReferenceArtifacts(context.Background(), &mockResolver{resolveFunc: func(ctx context.Context, manifestDigest string) (string, int64, error) {
+		return ocispec.MediaTypeImageManifest, 512, nil
+	}}, &models.OCIConfig{Artifacts: config.Artifacts[2:]}), "images aren't resolved as digest references")
+}
+
+func TestReferenceImages_ImportError(t *testing.T) {
+	config := &models.OCIConfig{
+		Artifacts: []models.ArtifactDefinition{
+			{Name: "container", Kind: models.KindImage, Image: "docker.io/newrelic/infrastructure@" + testManifestDigest, OS: "linux", Arch: "any"},
+		},
+	}
+
+	importer := &mockImporter{importFunc: func(ctx context.Context, repository, imageDigest string) (string, int64, error) {
+		return "", 0, errors.New("not found")
+	}}
+
+	// method under test
+	results := ReferenceImages(context.Background(), importer, config)
+
+	require.Len(t, results, 1)
+	assert.False(t, results[0].Uploaded)
+	assert.Equal(t, "not found", results[0].Error)
+	assert.True(t, HasFailures(results))
+}
+
+func TestImportImage(t *testing.T) {
+	setup := func(t *testing.T) (source, destination *manifestRegistry, sourceRepository string, client *Client, imageDigest string) {
+		source, destination = newManifestRegistry(), newManifestRegistry()
+		sourceServer, destinationServer := httptest.NewServer(source), httptest.NewServer(destination)
+		t.Cleanup(sourceServer.Close)
+		t.Cleanup(destinationServer.Close)
+
+		// A multi-platform image with a single platform: the index, its manifest, and the manifest's config and layer
+		configDesc := source.addBlob(ocispec.MediaTypeImageConfig, []byte(`{"architecture":"amd64","os":"linux"}`))
+		layerDesc := source.addBlob(ocispec.MediaTypeImageLayerGzip, []byte("image layer"))
+		manifest, err := json.Marshal(ocispec.Manifest{MediaType: ocispec.MediaTypeImageManifest, Config: configDesc, Layers: []ocispec.Descriptor{layerDesc}})
+		require.NoError(t, err)
+		manifestDigest := source.add(digest.FromBytes(manifest).String(), ocispec.MediaTypeImageManifest, manifest)
+		index, err := json.Marshal(ocispec.Index{
+			MediaType: ocispec.MediaTypeImageIndex,
+			Manifests: []ocispec.Descriptor{{
+				MediaType: ocispec.MediaTypeImageManifest, Digest: digest.Digest(manifestDigest), Size: int64(len(manifest)),
+				Platform: &ocispec.Platform{OS: "linux", Architecture: "amd64"},
+			}},
+		})
+		require.NoError(t, err)
+		imageDigest = source.add(digest.FromBytes(index).String(), ocispec.MediaTypeImageIndex, index)
+
+		sourceRepository = strings.TrimPrefix(sourceServer.URL, "http://") + "/agents"
+		client, err = NewClient(context.Background(), strings.TrimPrefix(destinationServer.URL, "http://")+"/agents", "", "", Connection{})
+		require.NoError(t, err)
+		return source, destination, sourceRepository, client, imageDigest
+	}
+
+	t.Run("copies an image from another repository", func(t *testing.T) {
+		source, destination, sourceRepository, client, imageDigest := setup(t)
+
+		// method under test
+		mediaType, size, err := client.ImportImage(context.Background(), sourceRepository, imageDigest)
+
+		require.NoError(t, err)
+		assert.Equal(t, ocispec.MediaTypeImageIndex, mediaType)
+		assert.Equal(t, int64(len(source.manifests[imageDigest])), size)
+		for d, content := range source.manifests {
+			assert.Equal(t, content, destination.manifests[d], "manifests are copied byte for byte")
+		}
+		for d, content := range source.blobs {
+			assert.Equal(t, content, destination.blobs[d])
+		}
+	})
+
+	t.Run("resolves an image already in the repository", func(t *testing.T) {
+		source, _, sourceRepository, _, imageDigest := setup(t)
+		client, err := NewClient(context.Background(), sourceRepository, "", "", Connection{})
+		require.NoError(t, err)
+		manifestCount := len(source.manifests)
+
+		// method under test
+		mediaType, _, err := client.ImportImage(context.Background(), sourceRepository, imageDigest)
+
+		require.NoError(t, err)
+		assert.Equal(t, ocispec.MediaTypeImageIndex, mediaType)
+		assert.Len(t, source.manifests, manifestCount)
+	})
+
+	t.Run("missing image", func(t *testing.T) {
+		_, destination, sourceRepository, client, _ := setup(t)
+
+		// method under test
+		_, _, err := client.ImportImage(context.Background(), sourceRepository, testManifestDigest)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to resolve")
+		assert.Empty(t, destination.manifests)
+	})
+}
+
+func TestCreateManifestIndex_NestedImageIndex(t *testing.T) {
+	registry := newManifestRegistry()
+	server := httptest.NewServer(registry)
+	t.Cleanup(server.Close)
+	client, err := NewClient(context.Background(), strings.TrimPrefix(server.URL, "http://")+"/agents", "", "", Connection{})
+	require.NoError(t, err)
+
+	uploadResults := []models.ArtifactUploadResult{
+		{Name: "container", OS: "linux", Arch: "any", Digest: "sha256:" + strings.Repeat("b", 64), Size: 1024, MediaType: ocispec.MediaTypeImageIndex, Uploaded: true, Referenced: true},
+		{Name: "linux-amd64", OS: "linux", Arch: "amd64", Digest: testManifestDigest, Size: 512, Uploaded: true},
+	}
+
+	// method under test
+	indexDigest, err := client.CreateManifestIndex(context.Background(), uploadResults, "1.2.3", "1.2.3")
+
+	require.NoError(t, err)
+	var index ocispec.Index
+	require.NoError(t, json.Unmarshal(registry.manifests[indexDigest], &index))
+	require.Len(t, index.Manifests, 2)
+	// Listed by os and arch rather than in upload order
+	assert.Equal(t, &ocispec.Platform{OS: "linux", Architecture: "amd64"}, index.Manifests[0].Platform)
+	assert.Equal(t, "application/vnd.newrelic.agent.v1", index.Manifests[0].ArtifactType)
+	assert.Equal(t, ocispec.MediaTypeImageIndex, index.Manifests[1].MediaType)
+	assert.Nil(t, index.Manifests[1].Platform, "nested indexes carry the platforms of their own entries")
+	assert.Empty(t, index.Manifests[1].ArtifactType)
+}
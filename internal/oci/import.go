@@ -0,0 +1,95 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"agent-metadata-action/internal/journal"
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// OpenLayout returns a client reading the OCI image layout directory dir, which must already be a layout, e.g. one a
+// build system prepared
+func OpenLayout(ctx context.Context, dir string) (*Client, error) {
+	if _, err := os.Stat(filepath.Join(dir, ocispec.ImageLayoutFile)); err != nil {
+		return nil, fmt.Errorf("%s isn't an OCI image layout: %w", dir, err)
+	}
+	return NewLayoutClient(ctx, dir)
+}
+
+// HandleImport pushes the manifest index tagged version in the OCI image layout directory layoutDir, relative to the
+// workspace, to the registry, returning the digest of the index pushed
+// The manifests it lists are copied byte for byte with their blobs, so their digests hold, and the index listing them
+// is built again with the tag, annotations and creation time of ociConfig; dry runs only read the layout
+func HandleImport(ctx context.Context, ociConfig *models.OCIConfig, workspace, layoutDir, version string, dryRun bool) (string, error) {
+	layout, err := OpenLayout(ctx, filepath.Join(workspace, layoutDir))
+	if err != nil {
+		return "", err
+	}
+	source, err := layout.ResolveIndex(ctx, version)
+	if errors.Is(err, errdef.ErrNotFound) {
+		return "", fmt.Errorf("no manifest index tagged %s in the OCI layout %s", version, layoutDir)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(source.Manifests) == 0 {
+		return "", fmt.Errorf("the manifest index tagged %s in the OCI layout %s lists no manifests", version, layoutDir)
+	}
+	entries := make([]models.ArtifactUploadResult, 0, len(source.Manifests))
+	for _, manifest := range source.Manifests {
+		entries = append(entries, indexedManifest(manifest))
+	}
+	logging.Noticef(ctx, "Manifest index %s in the OCI layout %s (%s) lists %d manifests", version, layoutDir, source.Index.Digest, len(entries))
+	if dryRun {
+		logging.Noticef(ctx, "Dry run - not importing %s to %s", version, ociConfig.Registry)
+		return "", nil
+	}
+
+	conn := ConnectionFor(ociConfig)
+	WarnInsecureConnection(ctx, ociConfig.Registry, conn)
+	results.RecordRegistry(ctx, results.Registry{
+		URL:                   ociConfig.Registry,
+		PlainHTTP:             conn.PlainHTTP || IsLocalRegistry(ociConfig.Registry),
+		InsecureSkipTLSVerify: conn.InsecureSkipTLSVerify,
+	})
+	client, err := newClientFunc(ctx, ociConfig.Registry, ociConfig.Username, ociConfig.Password, conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to create OCI client: %w", err)
+	}
+	if err := configureClient(ctx, client, ociConfig, workspace); err != nil {
+		return "", err
+	}
+
+	for i, manifest := range source.Manifests {
+		logging.Noticef(ctx, "Pushing %s (%s) from the OCI layout...", entries[i].Name, manifest.Digest)
+		if err := client.copyFrom(ctx, layout, manifest, "OCI layout import"); err != nil {
+			return "", err
+		}
+		journal.Record(ctx, journal.Entry{Operation: journal.OperationManifest, Version: version, Registry: ociConfig.Registry, Digest: manifest.Digest.String(), Name: entries[i].Name})
+	}
+	results.RecordArtifacts(ctx, entries)
+
+	tag := IndexTag(ociConfig, version)
+	indexDigest, err := client.CreateManifestIndex(ctx, entries, version, tag)
+	if err != nil {
+		logging.NoticeErrorWithCategory(ctx, err, "oci.manifest", map[string]interface{}{
+			"error.operation": "import_manifest_index",
+			"oci.registry":    ociConfig.Registry,
+			"manifest.count":  len(entries),
+		})
+		return "", fmt.Errorf("failed to create manifest index: %w", err)
+	}
+	logging.Noticef(ctx, "Imported the manifest index of %d artifacts with tag '%s' (digest: %s)", len(entries), tag, indexDigest)
+	journal.Record(ctx, journal.Entry{Operation: journal.OperationIndex, Version: version, Registry: ociConfig.Registry, Tag: tag, Digest: indexDigest})
+	results.RecordIndex(ctx, ociConfig.Registry, tag, indexDigest)
+	return indexDigest, nil
+}
@@ -0,0 +1,123 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeBuildLayout writes the OCI layout a build system would prepare under workspace/layout: a manifest with one
+// layer, listed for linux/amd64 by an index tagged 1.2.3 with the build's own annotations
+func writeBuildLayout(t *testing.T, workspace string) (ocispec.Descriptor, ocispec.Descriptor) {
+	t.Helper()
+	ctx := context.Background()
+	layout, err := NewLayoutClient(ctx, filepath.Join(workspace, "layout"))
+	require.NoError(t, err)
+
+	binary := []byte("agent binary")
+	layer := ocispec.Descriptor{MediaType: "application/octet-stream", Digest: digest.FromBytes(binary), Size: int64(len(binary))}
+	require.NoError(t, layout.repo.Push(ctx, layer, bytes.NewReader(binary)))
+	require.NoError(t, layout.repo.Push(ctx, ocispec.DescriptorEmptyJSON, bytes.NewReader(ocispec.DescriptorEmptyJSON.Data)))
+	manifest, err := json.Marshal(ocispec.Manifest{
+		Versioned:    ocispec.Index{}.Versioned,
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.newrelic.agent.v1",
+		Config:       ocispec.DescriptorEmptyJSON,
+		Layers:       []ocispec.Descriptor{layer},
+	})
+	require.NoError(t, err)
+	manifestDesc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.FromBytes(manifest), Size: int64(len(manifest))}
+	require.NoError(t, layout.repo.Push(ctx, manifestDesc, bytes.NewReader(manifest)))
+
+	listed := manifestDesc
+	listed.ArtifactType = "application/vnd.newrelic.agent.v1"
+	listed.Platform = &ocispec.Platform{OS: "linux", Architecture: "amd64"}
+	index, err := json.Marshal(ocispec.Index{
+		MediaType:   ocispec.MediaTypeImageIndex,
+		Manifests:   []ocispec.Descriptor{listed},
+		Annotations: map[string]string{"org.opencontainers.image.vendor": "Build System"},
+	})
+	require.NoError(t, err)
+	indexDesc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageIndex, Digest: digest.FromBytes(index), Size: int64(len(index))}
+	require.NoError(t, layout.repo.PushReference(ctx, indexDesc, bytes.NewReader(index), "1.2.3"))
+	return manifestDesc, indexDesc
+}
+
+func TestHandleImport(t *testing.T) {
+	testutil.CaptureOutput(t)
+
+	t.Run("pushes the layout and annotates its index again", func(t *testing.T) {
+		registries := useMemoryRegistries(t)
+		workspace := t.TempDir()
+		manifestDesc, layoutIndex := writeBuildLayout(t, workspace)
+		ociConfig := &models.OCIConfig{Registry: "ghcr.io/newrelic/agents", Annotations: map[string]string{"com.newrelic.team": "agents"}}
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+
+		// method under test
+		indexDigest, err := HandleImport(ctx, ociConfig, workspace, "layout", "1.2.3", false)
+
+		require.NoError(t, err)
+		registry := registries[ociConfig.Registry]
+		index := registry.index(t, "1.2.3")
+		assert.NotEqual(t, layoutIndex.Digest.String(), indexDigest, "The index is built again with the action's annotations")
+		assert.Equal(t, "1.2.3", index.Annotations[ocispec.AnnotationVersion])
+		assert.NotContains(t, index.Annotations, ocispec.AnnotationVendor, "The build's own annotations are replaced")
+		assert.Equal(t, "agents", index.Annotations["com.newrelic.team"])
+		require.Len(t, index.Manifests, 1)
+		assert.Equal(t, manifestDesc.Digest, index.Manifests[0].Digest, "Manifests are pushed byte for byte")
+		assert.Equal(t, "application/vnd.newrelic.agent.v1", index.Manifests[0].ArtifactType)
+		assert.Equal(t, &ocispec.Platform{OS: "linux", Architecture: "amd64"}, index.Manifests[0].Platform)
+		assert.Len(t, registry.manifest(t, manifestDesc.Digest).Layers, 1)
+
+		recorded := recorder.Results()
+		assert.Equal(t, &results.Index{Registry: ociConfig.Registry, Tag: "1.2.3", Digest: indexDigest}, recorded.Index)
+		require.Len(t, recorded.Artifacts, 1)
+		assert.Equal(t, "linux-amd64", recorded.Artifacts[0].Name)
+	})
+
+	t.Run("dry run pushes nothing", func(t *testing.T) {
+		registries := useMemoryRegistries(t)
+		workspace := t.TempDir()
+		writeBuildLayout(t, workspace)
+
+		// method under test
+		indexDigest, err := HandleImport(context.Background(), &models.OCIConfig{Registry: "ghcr.io/newrelic/agents"}, workspace, "layout", "1.2.3", true)
+
+		require.NoError(t, err)
+		assert.Empty(t, indexDigest)
+		assert.Empty(t, registries)
+	})
+
+	t.Run("version not in the layout", func(t *testing.T) {
+		useMemoryRegistries(t)
+		workspace := t.TempDir()
+		writeBuildLayout(t, workspace)
+
+		// method under test
+		_, err := HandleImport(context.Background(), &models.OCIConfig{Registry: "ghcr.io/newrelic/agents"}, workspace, "layout", "1.2.4", false)
+
+		assert.EqualError(t, err, "no manifest index tagged 1.2.4 in the OCI layout layout")
+	})
+
+	t.Run("directory isn't a layout", func(t *testing.T) {
+		workspace := t.TempDir()
+
+		// method under test
+		_, err := HandleImport(context.Background(), &models.OCIConfig{Registry: "ghcr.io/newrelic/agents"}, workspace, "layout", "1.2.3", false)
+
+		assert.ErrorContains(t, err, "isn't an OCI image layout")
+		assert.NoDirExists(t, filepath.Join(workspace, "layout"), "Nothing is created")
+	})
+}
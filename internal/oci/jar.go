@@ -0,0 +1,158 @@
+package oci
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"agent-metadata-action/internal/github"
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/validation"
+)
+
+// JarManifestPath is the entry of the manifest in a jar
+const JarManifestPath = "META-INF/MANIFEST.MF"
+
+// Manifest attributes naming the entry points of a Java agent: the premain class for -javaagent and the agentmain
+// class for dynamic attach
+const (
+	PremainClassAttribute = "Premain-Class"
+	AgentClassAttribute   = "Agent-Class"
+)
+
+// ParseJarManifest returns the attributes of the main section of a jar manifest
+// Lines may end with CRLF, LF or CR, and continuation lines start with a single space; the main section ends at the
+// first blank line
+func ParseJarManifest(r io.Reader) (map[string]string, error) {
+	attributes := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanManifestLines)
+	last := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, " ") {
+			if last == "" {
+				return nil, fmt.Errorf("continuation line without an attribute: %q", line)
+			}
+			attributes[last] += line[1:]
+			continue
+		}
+		name, value, found := strings.Cut(line, ": ")
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid manifest line: %q", line)
+		}
+		attributes[name] = value
+		last = name
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return attributes, nil
+}
+
+// scanManifestLines splits a manifest into lines ending with CRLF, LF or CR
+func scanManifestLines(data []byte, atEOF bool) (int, []byte, error) {
+	for i, b := range data {
+		switch b {
+		case '\n':
+			return i + 1, data[:i], nil
+		case '\r':
+			if i+1 < len(data) {
+				if data[i+1] == '\n' {
+					return i + 2, data[:i], nil
+				}
+				return i + 1, data[:i], nil
+			}
+			if atEOF {
+				return i + 1, data[:i], nil
+			}
+			// Need the next byte to tell CR from CRLF
+			return 0, nil, nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// ValidateJavaAgents checks every jar artifact, found relative to workspacePath, is a Java agent of version: its
+// manifest names a Premain-Class or Agent-Class that the jar contains, and its version attribute is version
+// Returns every invalid jar together as validation.Errors
+func ValidateJavaAgents(ctx context.Context, workspacePath string, artifacts []models.ArtifactDefinition, version string) error {
+	var errs validation.Errors
+	for i, artifact := range artifacts {
+		if !artifact.IsJar() || artifact.IsReference() {
+			continue
+		}
+		jarPath, err := ResolveArtifactPath(workspacePath, artifact.Path)
+		if err != nil {
+			return err
+		}
+		if err := validateJavaAgent(jarPath, artifact.GetVersionAttribute(), version); err != nil {
+			err = fmt.Errorf("artifact '%s' isn't a valid Java agent jar: %w", artifact.Name, err)
+			github.AddWorkflowAnnotation(ctx, github.AnnotationFailure, "Invalid Java agent jar", err.Error())
+			errs.Add("", 0, fmt.Sprintf("binaries[%d]", i), err)
+			continue
+		}
+		logging.Debugf(ctx, "Artifact '%s' is a Java agent jar of version %s", artifact.Name, version)
+	}
+	return errs.Err()
+}
+
+func validateJavaAgent(jarPath, versionAttribute, version string) error {
+	jar, err := zip.OpenReader(jarPath)
+	if err != nil {
+		return fmt.Errorf("failed to read jar: %w", err)
+	}
+	defer jar.Close()
+
+	manifestFile, err := jar.Open(JarManifestPath)
+	if err != nil {
+		return fmt.Errorf("no %s", JarManifestPath)
+	}
+	attributes, err := ParseJarManifest(manifestFile)
+	manifestFile.Close()
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", JarManifestPath, err)
+	}
+
+	var problems []string
+	entryPoints := 0
+	for _, attribute := range []string{PremainClassAttribute, AgentClassAttribute} {
+		class := strings.TrimSpace(attributes[attribute])
+		if class == "" {
+			continue
+		}
+		entryPoints++
+		classPath := strings.ReplaceAll(class, ".", "/") + ".class"
+		if _, err := fs.Stat(jar, classPath); err != nil {
+			problems = append(problems, fmt.Sprintf("%s %s isn't in the jar (no %s)", attribute, class, classPath))
+		}
+	}
+	if entryPoints == 0 {
+		problems = append(problems, fmt.Sprintf("the manifest has no %s or %s", PremainClassAttribute, AgentClassAttribute))
+	}
+
+	switch actual := strings.TrimSpace(attributes[versionAttribute]); actual {
+	case version:
+	case "":
+		problems = append(problems, fmt.Sprintf("the manifest has no %s, expected %s", versionAttribute, version))
+	default:
+		problems = append(problems, fmt.Sprintf("the manifest %s is %s, expected %s", versionAttribute, actual, version))
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package oci
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/testutil"
+	"agent-metadata-action/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeJar writes a jar with the manifest, unless empty, and the class files
+func writeJar(t *testing.T, path, manifest string, classes ...string) {
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+	if manifest != "" {
+		w, err := zipWriter.Create(JarManifestPath)
+		require.NoError(t, err)
+		w.Write([]byte(manifest))
+	}
+	for _, class := range classes {
+		w, err := zipWriter.Create(class)
+		require.NoError(t, err)
+		w.Write([]byte{0xca, 0xfe, 0xba, 0xbe})
+	}
+	require.NoError(t, zipWriter.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+}
+
+func TestParseJarManifest(t *testing.T) {
+	manifest := "Manifest-Version: 1.0\r\nPremain-Class: com.newrelic.bootstrap.Boots\r\n trapAgent\r\nImplementation-Version: 8.10.0\r\n\r\nName: com/newrelic/\r\nSealed: true\r\n"
+
+	// method under test
+	attributes, err := ParseJarManifest(strings.NewReader(manifest))
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"Manifest-Version":       "1.0",
+		"Premain-Class":          "com.newrelic.bootstrap.BootstrapAgent",
+		"Implementation-Version": "8.10.0",
+	}, attributes, "continuation lines are joined and per-entry sections are ignored")
+
+	attributes, err = ParseJarManifest(strings.NewReader("Manifest-Version: 1.0\rAgent-Class: Agent"))
+	require.NoError(t, err)
+	assert.Equal(t, "Agent", attributes["Agent-Class"], "CR line endings and a missing final newline are accepted")
+
+	_, err = ParseJarManifest(strings.NewReader("Manifest-Version 1.0\n"))
+	assert.ErrorContains(t, err, "invalid manifest line")
+}
+
+func TestValidateJavaAgents(t *testing.T) {
+	workspace := t.TempDir()
+	agentClass := "com/newrelic/bootstrap/BootstrapAgent.class"
+	writeJar(t, filepath.Join(workspace, "newrelic.jar"),
+		"Manifest-Version: 1.0\nPremain-Class: com.newrelic.bootstrap.BootstrapAgent\nAgent-Class: com.newrelic.bootstrap.BootstrapAgent\nImplementation-Version: 8.10.0\nAgent-Version: v8.10.0\n", agentClass)
+	writeJar(t, filepath.Join(workspace, "old.jar"),
+		"Manifest-Version: 1.0\nPremain-Class: com.newrelic.bootstrap.BootstrapAgent\nAgent-Class: com.newrelic.Missing\nImplementation-Version: 8.9.0\n", agentClass)
+	writeJar(t, filepath.Join(workspace, "library.jar"), "Manifest-Version: 1.0\n", "com/newrelic/api/NewRelic.class")
+	writeJar(t, filepath.Join(workspace, "unmanifested.jar"), "", agentClass)
+
+	jar := func(name string) models.ArtifactDefinition {
+		return models.ArtifactDefinition{Name: strings.TrimSuffix(name, ".jar"), Path: "./" + name, Format: "jar", OS: "any", Arch: "any"}
+	}
+
+	t.Run("valid agent jar", func(t *testing.T) {
+		customAttribute := jar("newrelic.jar")
+		customAttribute.VersionAttribute = "Agent-Version"
+		artifacts := []models.ArtifactDefinition{jar("newrelic.jar"), {Name: "linux", Path: "./old.jar", Format: "zip"}}
+
+		// method under test
+		require.NoError(t, ValidateJavaAgents(context.Background(), workspace, artifacts, "8.10.0"))
+
+		err := ValidateJavaAgents(context.Background(), workspace, []models.ArtifactDefinition{customAttribute}, "v8.10.0")
+		assert.NoError(t, err, "the version is read from versionAttribute")
+	})
+
+	t.Run("reports every invalid jar", func(t *testing.T) {
+		artifacts := []models.ArtifactDefinition{jar("newrelic.jar"), jar("old.jar"), jar("library.jar"), jar("unmanifested.jar")}
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := ValidateJavaAgents(context.Background(), workspace, artifacts, "8.10.0")
+
+		var errs validation.Errors
+		require.ErrorAs(t, err, &errs)
+		require.Len(t, errs, 3)
+		assert.Contains(t, errs[0].Error(), "binaries[1]: artifact 'old' isn't a valid Java agent jar: Agent-Class com.newrelic.Missing isn't in the jar (no com/newrelic/Missing.class); the manifest Implementation-Version is 8.9.0, expected 8.10.0")
+		assert.Contains(t, errs[1].Error(), "binaries[2]: artifact 'library' isn't a valid Java agent jar: the manifest has no Premain-Class or Agent-Class; the manifest has no Implementation-Version, expected 8.10.0")
+		assert.Contains(t, errs[2].Error(), "binaries[3]: artifact 'unmanifested' isn't a valid Java agent jar: no META-INF/MANIFEST.MF")
+	})
+}
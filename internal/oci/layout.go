@@ -0,0 +1,76 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"agent-metadata-action/internal/logging"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	ocilayout "oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
+)
+
+// layoutTarget is an OCI image layout directory as a RegistryTarget, so uploads can be written to disk for
+// air-gapped consumers to import with oras or skopeo
+type layoutTarget struct {
+	*ocilayout.Store
+}
+
+// NewLayoutClient returns a client writing to the OCI image layout directory dir, creating it if needed
+// Content already in the layout is kept, so several versions can be written to the same directory
+func NewLayoutClient(ctx context.Context, dir string) (*Client, error) {
+	store, err := ocilayout.NewWithContext(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open OCI layout %s: %w", dir, err)
+	}
+	logging.Debugf(ctx, "OCI client configured: layout=%s", dir)
+	return &Client{repo: layoutTarget{Store: store}, registry: LayoutReference(dir)}, nil
+}
+
+// LayoutReference is how an OCI layout directory is named in messages and the results file, as oras and skopeo
+// name it
+func LayoutReference(dir string) string {
+	return "oci:" + dir
+}
+
+// Push ignores content already in the layout, as registries do, so pushing it again in a later run doesn't fail
+func (l layoutTarget) Push(ctx context.Context, expected ocispec.Descriptor, content io.Reader) error {
+	if err := l.Store.Push(ctx, expected, content); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		return err
+	}
+	return nil
+}
+
+func (l layoutTarget) PushReference(ctx context.Context, expected ocispec.Descriptor, content io.Reader, reference string) error {
+	if err := l.Push(ctx, expected, content); err != nil {
+		return err
+	}
+	return l.Tag(ctx, expected, reference)
+}
+
+// Referrers lists the manifests in the layout whose subject is desc
+func (l layoutTarget) Referrers(ctx context.Context, desc ocispec.Descriptor, artifactType string, fn func(referrers []ocispec.Descriptor) error) error {
+	referrers, err := registry.Referrers(ctx, l.Store, desc, artifactType)
+	if err != nil || len(referrers) == 0 {
+		return err
+	}
+	return fn(referrers)
+}
+
+// ExportLayout copies the manifest index tagged tag, and everything it lists, from the repository of c to the OCI
+// image layout directory dir under the same tag
+func (c *Client) ExportLayout(ctx context.Context, tag, dir string) error {
+	layout, err := NewLayoutClient(ctx, dir)
+	if err != nil {
+		return err
+	}
+	if _, err := oras.Copy(ctx, c.repo, tag, layout.repo, tag, oras.DefaultCopyOptions); err != nil {
+		return fmt.Errorf("failed to copy %s:%s to %s: %w", c.registry, tag, layout.registry, err)
+	}
+	return nil
+}
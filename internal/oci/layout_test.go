@@ -0,0 +1,89 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLayoutClient(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "layout")
+
+	// method under test
+	client, err := NewLayoutClient(context.Background(), dir)
+
+	require.NoError(t, err)
+	assert.Equal(t, "oci:"+dir, client.registry)
+	layoutFile, err := os.ReadFile(filepath.Join(dir, ocispec.ImageLayoutFile))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"imageLayoutVersion":"1.0.0"}`, string(layoutFile))
+}
+
+func TestLayoutTarget(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	client, err := NewLayoutClient(ctx, dir)
+	require.NoError(t, err)
+
+	index := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`)
+	indexDesc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageIndex, Digest: digest.FromBytes(index), Size: int64(len(index))}
+	require.NoError(t, client.repo.PushReference(ctx, indexDesc, bytes.NewReader(index), "1.2.3"))
+	require.NoError(t, client.repo.Push(ctx, indexDesc, bytes.NewReader(index)), "Pushing content again is a no-op")
+
+	resolved, err := client.repo.Resolve(ctx, "1.2.3")
+	require.NoError(t, err)
+	assert.Equal(t, indexDesc.Digest, resolved.Digest)
+
+	signature := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","artifactType":"application/vnd.dev.cosign.artifact.sig.v1+json",` +
+		`"config":{"mediaType":"application/vnd.oci.empty.v1+json","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2},"layers":[],` +
+		`"subject":{"mediaType":"application/vnd.oci.image.index.v1+json","digest":"` + indexDesc.Digest.String() + `","size":` + strconv.FormatInt(indexDesc.Size, 10) + `}}`)
+	require.NoError(t, client.repo.Push(ctx, ocispec.DescriptorEmptyJSON, bytes.NewReader(ocispec.DescriptorEmptyJSON.Data)))
+	require.NoError(t, client.repo.Push(ctx, ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.FromBytes(signature), Size: int64(len(signature))}, bytes.NewReader(signature)))
+
+	// method under test
+	signed, err := client.IsSigned(ctx, indexDesc)
+
+	require.NoError(t, err)
+	assert.True(t, signed, "Referrers are found through the subjects of the manifests in the layout")
+}
+
+func TestClient_ExportLayout(t *testing.T) {
+	ctx := context.Background()
+	registry := newMemoryRegistry()
+	client := &Client{repo: registry, registry: "ghcr.io/newrelic/agents"}
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":` +
+		`{"mediaType":"application/vnd.oci.empty.v1+json","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2},"layers":[]}`)
+	manifestDesc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.FromBytes(manifest), Size: int64(len(manifest))}
+	require.NoError(t, registry.Push(ctx, ocispec.DescriptorEmptyJSON, bytes.NewReader(ocispec.DescriptorEmptyJSON.Data)))
+	require.NoError(t, registry.Push(ctx, manifestDesc, bytes.NewReader(manifest)))
+	index, err := json.Marshal(ocispec.Index{MediaType: ocispec.MediaTypeImageIndex, Manifests: []ocispec.Descriptor{manifestDesc}})
+	require.NoError(t, err)
+	indexDesc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageIndex, Digest: digest.FromBytes(index), Size: int64(len(index))}
+	require.NoError(t, registry.PushReference(ctx, indexDesc, bytes.NewReader(index), "1.2.3"))
+	dir := t.TempDir()
+
+	// method under test
+	err = client.ExportLayout(ctx, "1.2.3", dir)
+
+	require.NoError(t, err)
+	layout, err := NewLayoutClient(ctx, dir)
+	require.NoError(t, err)
+	resolved, err := layout.repo.Resolve(ctx, "1.2.3")
+	require.NoError(t, err)
+	assert.Equal(t, indexDesc.Digest, resolved.Digest)
+	for _, d := range []digest.Digest{manifestDesc.Digest, ocispec.DescriptorEmptyJSON.Digest} {
+		assert.FileExists(t, filepath.Join(dir, "blobs", "sha256", d.Encoded()))
+	}
+
+	err = client.ExportLayout(ctx, "1.2.4", dir)
+	assert.ErrorContains(t, err, "failed to copy ghcr.io/newrelic/agents:1.2.4 to oci:"+dir)
+}
@@ -0,0 +1,78 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"agent-metadata-action/internal/github"
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/validation"
+)
+
+// FindLegalFile returns the archive entry that is the legal file required, or "" if there is none
+// Entries match by file name, case-insensitively and with or without an extension (LICENSE, LICENSE.txt,
+// license.md); the one closest to the archive root wins
+func FindLegalFile(entries []string, required string) string {
+	found := ""
+	for _, entry := range entries {
+		base := path.Base(entry)
+		if !strings.EqualFold(base, required) && !strings.EqualFold(strings.TrimSuffix(base, path.Ext(base)), required) {
+			continue
+		}
+		if found == "" || strings.Count(entry, "/") < strings.Count(found, "/") {
+			found = entry
+		}
+	}
+	return found
+}
+
+// CheckLegalFiles checks every archive to upload, found relative to workspacePath, contains each required legal file,
+// setting LegalFiles of the artifacts to the entries found, which are annotated on their layers
+// Returns every artifact missing a file together as validation.Errors; referenced artifacts aren't checked
+func CheckLegalFiles(ctx context.Context, workspacePath string, required []string, artifacts []models.ArtifactDefinition) error {
+	if len(required) == 0 {
+		return nil
+	}
+
+	var errs validation.Errors
+	for i := range artifacts {
+		artifact := &artifacts[i]
+		if artifact.IsReference() {
+			continue
+		}
+		if artifact.IsMSI() {
+			logging.Warnf(ctx, "Not checking artifact '%s' for legal files: the files of an MSI package can't be listed", artifact.Name)
+			continue
+		}
+		archivePath, err := ResolveArtifactPath(workspacePath, artifact.Path)
+		if err != nil {
+			return err
+		}
+		entries, err := ListArchiveEntries(ctx, archivePath, artifact.Format)
+		if err != nil {
+			errs.Add("", 0, fmt.Sprintf("binaries[%d]", i), fmt.Errorf("failed to list the entries of artifact '%s': %w", artifact.Name, err))
+			continue
+		}
+
+		artifact.LegalFiles = nil
+		var missing []string
+		for _, name := range required {
+			if entry := FindLegalFile(entries, name); entry != "" {
+				artifact.LegalFiles = append(artifact.LegalFiles, entry)
+			} else {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			err := fmt.Errorf("artifact '%s' is missing required legal files: %s", artifact.Name, strings.Join(missing, ", "))
+			github.AddWorkflowAnnotation(ctx, github.AnnotationFailure, "Missing legal files", err.Error())
+			errs.Add("", 0, fmt.Sprintf("binaries[%d]", i), err)
+			continue
+		}
+		logging.Debugf(ctx, "Artifact '%s' contains legal files %s", artifact.Name, strings.Join(artifact.LegalFiles, ", "))
+	}
+	return errs.Err()
+}
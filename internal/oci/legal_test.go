@@ -0,0 +1,154 @@
+package oci
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/testutil"
+	"agent-metadata-action/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTar returns a tar archive of files, each containing "text", after a newrelic/ directory entry
+func writeTar(t *testing.T, files ...string) []byte {
+	var buf bytes.Buffer
+	tarWriter := tar.NewWriter(&buf)
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: "newrelic/", Typeflag: tar.TypeDir, Mode: 0o755}))
+	for _, name := range files {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: 4}))
+		_, err := tarWriter.Write([]byte("text"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	return buf.Bytes()
+}
+
+func writeArchive(t *testing.T, dir, name, format string, files ...string) string {
+	path := filepath.Join(dir, name)
+	var content []byte
+	switch format {
+	case "tar", models.FormatTarZstd:
+		content = writeTar(t, files...)
+	case "tar+gzip":
+		var buf bytes.Buffer
+		gzipWriter := gzip.NewWriter(&buf)
+		_, err := gzipWriter.Write(writeTar(t, files...))
+		require.NoError(t, err)
+		require.NoError(t, gzipWriter.Close())
+		content = buf.Bytes()
+	case "zip":
+		var buf bytes.Buffer
+		zipWriter := zip.NewWriter(&buf)
+		_, err := zipWriter.Create("newrelic/")
+		require.NoError(t, err)
+		for _, file := range files {
+			w, err := zipWriter.Create(file)
+			require.NoError(t, err)
+			w.Write([]byte("text"))
+		}
+		require.NoError(t, zipWriter.Close())
+		content = buf.Bytes()
+	}
+	require.NoError(t, os.WriteFile(path, content, 0o644))
+	return path
+}
+
+func TestListArchiveEntries(t *testing.T) {
+	// zstd archives are decompressed by the zstd CLI; the test archive is a plain tar
+	originalZstd := zstdReaderFunc
+	zstdReaderFunc = func(ctx context.Context, path string) (io.ReadCloser, error) {
+		return os.Open(path)
+	}
+	defer func() { zstdReaderFunc = originalZstd }()
+
+	for _, format := range []string{"tar", "tar+gzip", "TAR+GZIP", models.FormatTarZstd, "zip"} {
+		t.Run(format, func(t *testing.T) {
+			path := writeArchive(t, t.TempDir(), "agent", strings.ToLower(format), "newrelic/LICENSE", "newrelic/agent.jar")
+
+			// method under test
+			entries, err := ListArchiveEntries(context.Background(), path, format)
+
+			require.NoError(t, err)
+			assert.Equal(t, []string{"newrelic/LICENSE", "newrelic/agent.jar"}, entries, "directories aren't listed")
+		})
+	}
+
+	t.Run("not a gzip archive", func(t *testing.T) {
+		path := writeArchive(t, t.TempDir(), "agent", "tar", "LICENSE")
+
+		// method under test
+		_, err := ListArchiveEntries(context.Background(), path, "tar+gzip")
+
+		assert.ErrorContains(t, err, "not a gzip archive")
+	})
+}
+
+func TestFindLegalFile(t *testing.T) {
+	entries := []string{"newrelic/lib/vendor/LICENSE", "newrelic/LICENSE.txt", "newrelic/THIRD_PARTY_NOTICES.md", "newrelic/LICENSES/other"}
+
+	assert.Equal(t, "newrelic/LICENSE.txt", FindLegalFile(entries, "LICENSE"), "the entry closest to the root wins")
+	assert.Equal(t, "newrelic/THIRD_PARTY_NOTICES.md", FindLegalFile(entries, "third_party_notices"))
+	assert.Equal(t, "newrelic/THIRD_PARTY_NOTICES.md", FindLegalFile(entries, "THIRD_PARTY_NOTICES.md"))
+	assert.Empty(t, FindLegalFile(entries, "NOTICE"))
+}
+
+func TestCheckLegalFiles(t *testing.T) {
+	workspace := t.TempDir()
+	writeArchive(t, workspace, "linux.tar.gz", "tar+gzip", "newrelic/LICENSE", "newrelic/THIRD_PARTY_NOTICES.md", "newrelic/agent.jar")
+	writeArchive(t, workspace, "windows.zip", "zip", "newrelic/LICENSE", "newrelic/agent.dll")
+	required := []string{"LICENSE", "THIRD_PARTY_NOTICES"}
+
+	t.Run("records the legal files found", func(t *testing.T) {
+		artifacts := []models.ArtifactDefinition{
+			{Name: "linux", Path: "./linux.tar.gz", Format: "tar+gzip"},
+			{Name: "image", Digest: "sha256:" + string(bytes.Repeat([]byte("a"), 64))},
+		}
+
+		// method under test
+		err := CheckLegalFiles(context.Background(), workspace, required, artifacts)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"newrelic/LICENSE", "newrelic/THIRD_PARTY_NOTICES.md"}, artifacts[0].LegalFiles)
+		assert.Empty(t, artifacts[1].LegalFiles, "referenced artifacts aren't checked")
+		assert.Equal(t, "newrelic/LICENSE,newrelic/THIRD_PARTY_NOTICES.md", CreateLayerAnnotations(&artifacts[0], "1.2.3")[LegalFilesAnnotation])
+	})
+
+	t.Run("reports every artifact missing files", func(t *testing.T) {
+		artifacts := []models.ArtifactDefinition{
+			{Name: "linux", Path: "./linux.tar.gz", Format: "tar+gzip"},
+			{Name: "windows", Path: "./windows.zip", Format: "zip"},
+			{Name: "broken", Path: "./windows.zip", Format: "tar"},
+		}
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := CheckLegalFiles(context.Background(), workspace, required, artifacts)
+
+		var errs validation.Errors
+		require.ErrorAs(t, err, &errs)
+		require.Len(t, errs, 2)
+		assert.Contains(t, errs[0].Error(), "binaries[1]: artifact 'windows' is missing required legal files: THIRD_PARTY_NOTICES")
+		assert.Contains(t, errs[1].Error(), "binaries[2]: failed to list the entries of artifact 'broken'")
+	})
+
+	t.Run("no required files", func(t *testing.T) {
+		artifacts := []models.ArtifactDefinition{{Name: "windows", Path: "./windows.zip", Format: "zip"}}
+
+		// method under test
+		require.NoError(t, CheckLegalFiles(context.Background(), workspace, nil, artifacts))
+
+		assert.Empty(t, artifacts[0].LegalFiles)
+		assert.NotContains(t, CreateLayerAnnotations(&artifacts[0], "1.2.3"), LegalFilesAnnotation)
+	})
+}
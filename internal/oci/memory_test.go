@@ -0,0 +1,217 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// memoryRegistry is an in-memory RegistryTarget, so clients and handlers can be tested without a registry
+// Manifests pushed with a subject are listed as its referrers, as by a registry with the OCI 1.1 referrers API
+type memoryRegistry struct {
+	mu        sync.Mutex
+	blobs     map[digest.Digest][]byte
+	descs     map[digest.Digest]ocispec.Descriptor
+	tags      map[string]digest.Digest
+	referrers map[digest.Digest][]ocispec.Descriptor // by subject
+}
+
+var _ RegistryTarget = (*memoryRegistry)(nil)
+
+func newMemoryRegistry() *memoryRegistry {
+	return &memoryRegistry{
+		blobs:     map[digest.Digest][]byte{},
+		descs:     map[digest.Digest]ocispec.Descriptor{},
+		tags:      map[string]digest.Digest{},
+		referrers: map[digest.Digest][]ocispec.Descriptor{},
+	}
+}
+
+// useMemoryRegistries backs the clients the handlers create with in-memory registries for the rest of the test, one
+// per repository, and returns them by repository
+func useMemoryRegistries(t *testing.T) map[string]*memoryRegistry {
+	registries := map[string]*memoryRegistry{}
+	original := newClientFunc
+	newClientFunc = func(ctx context.Context, registry, username, password string, conn Connection) (*Client, error) {
+		if _, ok := registries[registry]; !ok {
+			registries[registry] = newMemoryRegistry()
+		}
+		return &Client{repo: registries[registry], registry: registry, username: username, password: password, conn: conn}, nil
+	}
+	t.Cleanup(func() { newClientFunc = original })
+	return registries
+}
+
+func (m *memoryRegistry) Fetch(_ context.Context, target ocispec.Descriptor) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	content, ok := m.blobs[target.Digest]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", target.Digest, errdef.ErrNotFound)
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (m *memoryRegistry) Exists(_ context.Context, target ocispec.Descriptor) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.blobs[target.Digest]
+	return ok, nil
+}
+
+func (m *memoryRegistry) Push(_ context.Context, expected ocispec.Descriptor, r io.Reader) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if int64(len(content)) != expected.Size || digest.FromBytes(content) != expected.Digest {
+		return fmt.Errorf("%s: content doesn't match its descriptor: %w", expected.Digest, errdef.ErrInvalidDigest)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.blobs[expected.Digest]; ok {
+		return nil
+	}
+	desc := ocispec.Descriptor{MediaType: expected.MediaType, Digest: expected.Digest, Size: expected.Size}
+	m.blobs[desc.Digest] = content
+	m.descs[desc.Digest] = desc
+
+	if desc.MediaType == ocispec.MediaTypeImageManifest || desc.MediaType == ocispec.MediaTypeImageIndex {
+		var manifest struct {
+			ArtifactType string              `json:"artifactType"`
+			Config       *ocispec.Descriptor `json:"config"`
+			Subject      *ocispec.Descriptor `json:"subject"`
+			Annotations  map[string]string   `json:"annotations"`
+		}
+		if err := json.Unmarshal(content, &manifest); err == nil && manifest.Subject != nil {
+			referrer := desc
+			referrer.ArtifactType = manifest.ArtifactType
+			if referrer.ArtifactType == "" && manifest.Config != nil {
+				referrer.ArtifactType = manifest.Config.MediaType
+			}
+			referrer.Annotations = manifest.Annotations
+			m.referrers[manifest.Subject.Digest] = append(m.referrers[manifest.Subject.Digest], referrer)
+		}
+	}
+	return nil
+}
+
+func (m *memoryRegistry) Resolve(_ context.Context, reference string) (ocispec.Descriptor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.tags[reference]
+	if !ok {
+		d = digest.Digest(reference)
+	}
+	desc, ok := m.descs[d]
+	if !ok {
+		return ocispec.Descriptor{}, fmt.Errorf("%s: %w", reference, errdef.ErrNotFound)
+	}
+	return desc, nil
+}
+
+func (m *memoryRegistry) Tag(_ context.Context, desc ocispec.Descriptor, reference string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.descs[desc.Digest]; !ok {
+		return fmt.Errorf("%s: %w", desc.Digest, errdef.ErrNotFound)
+	}
+	// Referencing content by its digest doesn't tag it, as in a registry
+	if _, err := digest.Parse(reference); err != nil {
+		m.tags[reference] = desc.Digest
+	}
+	return nil
+}
+
+func (m *memoryRegistry) PushReference(ctx context.Context, expected ocispec.Descriptor, content io.Reader, reference string) error {
+	if err := m.Push(ctx, expected, content); err != nil {
+		return err
+	}
+	return m.Tag(ctx, expected, reference)
+}
+
+func (m *memoryRegistry) Referrers(_ context.Context, desc ocispec.Descriptor, artifactType string, fn func(referrers []ocispec.Descriptor) error) error {
+	m.mu.Lock()
+	var referrers []ocispec.Descriptor
+	for _, referrer := range m.referrers[desc.Digest] {
+		if _, ok := m.descs[referrer.Digest]; ok && (artifactType == "" || referrer.ArtifactType == artifactType) {
+			referrers = append(referrers, referrer)
+		}
+	}
+	m.mu.Unlock()
+	if len(referrers) == 0 {
+		return nil
+	}
+	return fn(referrers)
+}
+
+func (m *memoryRegistry) Tags(_ context.Context, last string, fn func(tags []string) error) error {
+	m.mu.Lock()
+	var tags []string
+	for tag := range m.tags {
+		if tag > last {
+			tags = append(tags, tag)
+		}
+	}
+	m.mu.Unlock()
+	sort.Strings(tags)
+	if len(tags) == 0 {
+		return nil
+	}
+	return fn(tags)
+}
+
+// Delete removes the content and every tag pointing to it
+func (m *memoryRegistry) Delete(_ context.Context, target ocispec.Descriptor) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.descs[target.Digest]; !ok {
+		return fmt.Errorf("%s: %w", target.Digest, errdef.ErrNotFound)
+	}
+	delete(m.blobs, target.Digest)
+	delete(m.descs, target.Digest)
+	for tag, d := range m.tags {
+		if d == target.Digest {
+			delete(m.tags, tag)
+		}
+	}
+	return nil
+}
+
+// index returns the manifest index tag points to
+func (m *memoryRegistry) index(t *testing.T, tag string) ocispec.Index {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.tags[tag]
+	if !ok {
+		t.Fatalf("no manifest tagged %s", tag)
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(m.blobs[d], &index); err != nil {
+		t.Fatalf("failed to parse %s: %v", tag, err)
+	}
+	return index
+}
+
+// manifest returns the manifest with digest d
+func (m *memoryRegistry) manifest(t *testing.T, d digest.Digest) ocispec.Manifest {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(m.blobs[d], &manifest); err != nil {
+		t.Fatalf("failed to parse %s: %v", d, err)
+	}
+	return manifest
+}
@@ -0,0 +1,70 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// MergeIndex returns the manifests of the index for the uploaded artifacts: those the index tagged tag already
+// lists, as referenced manifests, with the uploaded artifacts added
+// An uploaded artifact replaces the manifest listed for its platform, so a platform built again is updated rather
+// than listed twice
+func (c *Client) MergeIndex(ctx context.Context, uploadResults []models.ArtifactUploadResult, tag string) ([]models.ArtifactUploadResult, error) {
+	existing, err := c.ResolveIndex(ctx, tag)
+	if errors.Is(err, errdef.ErrNotFound) {
+		return nil, fmt.Errorf("no manifest index tagged %s in %s to merge into: upload the other platforms first", tag, c.registry)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	platforms := make(map[string]string, len(uploadResults))
+	for _, result := range uploadResults {
+		if result.Uploaded {
+			platforms[result.OS+"/"+result.Arch] = result.Digest
+		}
+	}
+
+	merged := make([]models.ArtifactUploadResult, 0, len(existing.Manifests)+len(uploadResults))
+	for _, manifest := range existing.Manifests {
+		result := indexedManifest(manifest)
+		if manifest.Platform != nil {
+			if replacement, ok := platforms[result.OS+"/"+result.Arch]; ok {
+				if replacement != result.Digest {
+					logging.Noticef(ctx, "Replacing the %s/%s manifest %s of %s:%s with %s", result.OS, result.Arch, result.Digest, c.registry, tag, replacement)
+				}
+				continue
+			}
+		}
+		merged = append(merged, result)
+	}
+	logging.Noticef(ctx, "Merging %d artifacts into the %d manifests kept from %s:%s (%s)", len(uploadResults), len(merged), c.registry, tag, existing.Index.Digest)
+	return append(merged, uploadResults...), nil
+}
+
+// indexedManifest is a manifest listed by another index as an entry of a new index, keeping its media type, artifact
+// type and platform
+func indexedManifest(manifest ocispec.Descriptor) models.ArtifactUploadResult {
+	result := models.ArtifactUploadResult{
+		Name:         manifest.Digest.String(),
+		Digest:       manifest.Digest.String(),
+		Size:         manifest.Size,
+		MediaType:    manifest.MediaType,
+		ArtifactType: manifest.ArtifactType,
+		Uploaded:     true,
+		Referenced:   true,
+	}
+	if manifest.Platform != nil {
+		result.Name = manifest.Platform.OS + "-" + manifest.Platform.Architecture
+		result.OS = manifest.Platform.OS
+		result.Arch = manifest.Platform.Architecture
+	}
+	return result
+}
@@ -0,0 +1,83 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/testutil"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_MergeIndex(t *testing.T) {
+	testutil.CaptureOutput(t)
+	linux := "sha256:" + strings.Repeat("1", 64)
+	windows := "sha256:" + strings.Repeat("2", 64)
+	container := "sha256:" + strings.Repeat("3", 64)
+	index := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[` +
+		`{"mediaType":"application/vnd.oci.image.manifest.v1+json","artifactType":"application/vnd.newrelic.agent.v1","digest":"` + linux + `","size":100,"platform":{"os":"linux","architecture":"amd64"}},` +
+		`{"mediaType":"application/vnd.oci.image.manifest.v1+json","artifactType":"application/vnd.newrelic.agent.v1","digest":"` + windows + `","size":200,"platform":{"os":"windows","architecture":"amd64"}},` +
+		`{"mediaType":"application/vnd.oci.image.index.v1+json","digest":"` + container + `","size":300}]}`)
+	setup := func(t *testing.T) (*manifestRegistry, *Client) {
+		registry := newManifestRegistry()
+		server := httptest.NewServer(registry)
+		t.Cleanup(server.Close)
+		client, err := NewClient(context.Background(), strings.TrimPrefix(server.URL, "http://")+"/agents", "", "", Connection{})
+		require.NoError(t, err)
+		return registry, client
+	}
+
+	t.Run("adds new platforms and replaces rebuilt ones", func(t *testing.T) {
+		registry, client := setup(t)
+		registry.add("1.2.3", ocispec.MediaTypeImageIndex, index)
+		rebuilt := "sha256:" + strings.Repeat("4", 64)
+		arm := "sha256:" + strings.Repeat("5", 64)
+		uploadResults := []models.ArtifactUploadResult{
+			{Name: "windows", OS: "windows", Arch: "amd64", Digest: rebuilt, Size: 210, Uploaded: true},
+			{Name: "windows-arm64", OS: "windows", Arch: "arm64", Digest: arm, Size: 220, Uploaded: true},
+		}
+
+		// method under test
+		merged, err := client.MergeIndex(context.Background(), uploadResults, "1.2.3")
+
+		require.NoError(t, err)
+		require.Len(t, merged, 4)
+		assert.Equal(t, models.ArtifactUploadResult{
+			Name: "linux-amd64", OS: "linux", Arch: "amd64", Digest: linux, Size: 100, MediaType: ocispec.MediaTypeImageManifest,
+			ArtifactType: "application/vnd.newrelic.agent.v1", Uploaded: true, Referenced: true,
+		}, merged[0])
+		assert.Equal(t, container, merged[1].Digest)
+		assert.Equal(t, uploadResults, merged[2:])
+
+		indexDigest, err := client.CreateManifestIndex(context.Background(), merged, "1.2.3", "1.2.3")
+		require.NoError(t, err)
+		var pushed ocispec.Index
+		require.NoError(t, json.Unmarshal(registry.manifests[indexDigest], &pushed))
+		require.Len(t, pushed.Manifests, 4)
+		for _, manifest := range pushed.Manifests {
+			assert.NotEqual(t, windows, manifest.Digest.String(), "The rebuilt platform is replaced")
+			if manifest.Digest.String() == linux {
+				assert.Equal(t, "application/vnd.newrelic.agent.v1", manifest.ArtifactType, "Kept manifests keep their artifact type")
+			}
+			if manifest.Digest.String() == container {
+				assert.Nil(t, manifest.Platform)
+			}
+		}
+	})
+
+	t.Run("no index to merge into", func(t *testing.T) {
+		_, client := setup(t)
+
+		// method under test
+		_, err := client.MergeIndex(context.Background(), nil, "1.2.3")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no manifest index tagged 1.2.3")
+	})
+}
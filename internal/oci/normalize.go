@@ -0,0 +1,278 @@
+package oci
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+)
+
+// normalizedModTime is the modification time of every tar entry of a normalized archive
+var normalizedModTime = time.Unix(0, 0).UTC()
+
+// normalizedZipDate is the MS-DOS date of every zip entry of a normalized archive, 1980-01-01, the earliest zip
+// timestamp
+const normalizedZipDate = 1<<5 | 1
+
+// zstdWriterFunc compresses what is written to it into dest with the zstd CLI, which is preinstalled on GitHub-hosted
+// runners; Close waits for the compression to finish
+// This allows tests to override the implementation
+var zstdWriterFunc = func(ctx context.Context, dest io.Writer) (io.WriteCloser, error) {
+	cmd := exec.CommandContext(ctx, "zstd", "-q", "-c")
+	cmd.Stdout = dest
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("the zstd command is not installed on the runner")
+		}
+		return nil, err
+	}
+	return &commandWriter{WriteCloser: stdin, cmd: cmd}, nil
+}
+
+// commandWriter writes to the stdin of a command, waiting for it on Close
+type commandWriter struct {
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+func (w *commandWriter) Close() error {
+	w.WriteCloser.Close()
+	return w.cmd.Wait()
+}
+
+// NormalizeArtifacts re-packages every local archive, found relative to workspacePath, into normalizedDir so
+// rebuilding the same files yields the same digest, and returns a copy of the artifacts with Path pointing at the
+// normalized archives
+// Referenced artifacts are returned unchanged
+func NormalizeArtifacts(ctx context.Context, workspacePath string, artifacts []models.ArtifactDefinition, normalizedDir string) ([]models.ArtifactDefinition, error) {
+	normalized := make([]models.ArtifactDefinition, len(artifacts))
+	copy(normalized, artifacts)
+
+	for i, artifact := range artifacts {
+		// MSI packages aren't archives this can re-package, so they are uploaded as built
+		if artifact.IsReference() || artifact.IsMSI() {
+			continue
+		}
+		src, err := ResolveArtifactPath(workspacePath, artifact.Path)
+		if err != nil {
+			return nil, err
+		}
+		dest := filepath.Join(normalizedDir, artifact.Name, artifact.GetFilename())
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory for the normalized artifact '%s': %w", artifact.Name, err)
+		}
+		if err := NormalizeArchive(ctx, src, dest, artifact.Format); err != nil {
+			return nil, fmt.Errorf("failed to normalize artifact '%s': %w", artifact.Name, err)
+		}
+		logging.Debugf(ctx, "Normalized artifact '%s' into %s", artifact.Name, dest)
+		normalized[i].Path = dest
+	}
+
+	return normalized, nil
+}
+
+// NormalizeArchive writes the archive at src to dest with its entries sorted by name, every timestamp fixed, owners
+// set to uid/gid 0 with no user or group names, and permissions reduced to 0755 for directories and executables
+// and 0644 for other files
+// Extended attributes and other per-build metadata are dropped; file contents are unchanged
+func NormalizeArchive(ctx context.Context, src, dest, format string) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	switch strings.ToLower(format) {
+	case "zip", models.FormatJar:
+		err = normalizeZip(src, out)
+	case "tar":
+		err = normalizeTar(ctx, src, format, out)
+	case "tar+gzip":
+		// The gzip header has no name or modification time, so it doesn't vary either
+		gzipWriter := gzip.NewWriter(out)
+		if err = normalizeTar(ctx, src, format, gzipWriter); err == nil {
+			err = gzipWriter.Close()
+		}
+	case models.FormatTarZstd:
+		var zstdWriter io.WriteCloser
+		if zstdWriter, err = zstdWriterFunc(ctx, out); err == nil {
+			err = normalizeTar(ctx, src, format, zstdWriter)
+			if closeErr := zstdWriter.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	default:
+		err = fmt.Errorf("unsupported archive format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// tarEntry is an entry of a tar archive being normalized, with the file holding its content if it's a regular file
+type tarEntry struct {
+	header      *tar.Header
+	contentPath string
+}
+
+// normalizeTar writes the tar archive at src, compressed as format says, to w as a normalized tar archive
+// The content of regular files is spooled to a temporary directory so the entries can be written in name order
+func normalizeTar(ctx context.Context, src, format string, w io.Writer) error {
+	spoolDir, err := os.MkdirTemp("", "agent-normalize-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(spoolDir)
+
+	var entries []tarEntry
+	err = walkTar(ctx, src, format, func(header *tar.Header, content io.Reader) error {
+		entry := tarEntry{header: header}
+		if header.Typeflag == tar.TypeReg {
+			entry.contentPath = filepath.Join(spoolDir, strconv.Itoa(len(entries)))
+			if err := writeFile(entry.contentPath, content); err != nil {
+				return err
+			}
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Stable, so when a name repeats the entry that wins on extraction is still written last
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].header.Name < entries[j].header.Name })
+
+	tarWriter := tar.NewWriter(w)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Typeflag: entry.header.Typeflag,
+			Name:     entry.header.Name,
+			Linkname: entry.header.Linkname,
+			Size:     entry.header.Size,
+			Mode:     normalizedMode(entry.header.FileInfo().Mode()),
+			ModTime:  normalizedModTime,
+			Devmajor: entry.header.Devmajor,
+			Devminor: entry.header.Devminor,
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to write %s: %w", header.Name, err)
+		}
+		if entry.contentPath != "" {
+			if err := copyFile(tarWriter, entry.contentPath); err != nil {
+				return fmt.Errorf("failed to write %s: %w", header.Name, err)
+			}
+		}
+	}
+	return tarWriter.Close()
+}
+
+// normalizeZip writes the zip archive at src to w as a normalized zip archive
+// Extra fields, which hold extended timestamps and Unix owners, and comments are dropped
+func normalizeZip(src string, w io.Writer) error {
+	zipReader, err := zip.OpenReader(src)
+	if err != nil {
+		return fmt.Errorf("failed to read zip archive: %w", err)
+	}
+	defer zipReader.Close()
+
+	files := make([]*zip.File, len(zipReader.File))
+	copy(files, zipReader.File)
+	// The jar manifest stays first, where java.util.jar.JarInputStream expects it
+	sort.SliceStable(files, func(i, j int) bool {
+		if first, second := jarManifestRank(files[i].Name), jarManifestRank(files[j].Name); first != second {
+			return first < second
+		}
+		return files[i].Name < files[j].Name
+	})
+
+	zipWriter := zip.NewWriter(w)
+	for _, file := range files {
+		header := &zip.FileHeader{
+			Name:         file.Name,
+			Method:       file.Method,
+			ModifiedDate: normalizedZipDate,
+		}
+		header.SetMode(os.FileMode(normalizedMode(file.Mode())) | file.Mode().Type())
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.Name, err)
+		}
+		if file.Mode().IsDir() {
+			continue
+		}
+		content, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file.Name, err)
+		}
+		_, err = io.Copy(writer, content)
+		content.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.Name, err)
+		}
+	}
+	return zipWriter.Close()
+}
+
+// jarManifestRank sorts the META-INF/ directory and then the jar manifest before every other entry
+func jarManifestRank(name string) int {
+	switch name {
+	case "META-INF/":
+		return 0
+	case JarManifestPath:
+		return 1
+	}
+	return 2
+}
+
+// normalizedMode returns the permissions of a normalized entry: 0755 for directories and executables, 0777 for
+// symlinks and 0644 for anything else
+func normalizedMode(mode os.FileMode) int64 {
+	switch {
+	case mode&os.ModeSymlink != 0:
+		return 0o777
+	case mode.IsDir(), mode&0o111 != 0:
+		return 0o755
+	default:
+		return 0o644
+	}
+}
+
+func writeFile(path string, content io.Reader) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func copyFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
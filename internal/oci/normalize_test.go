@@ -0,0 +1,185 @@
+package oci
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"agent-metadata-action/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildArchive writes an archive of the same files as every other build, with build-specific order, timestamps,
+// owners and permissions
+func buildArchive(t *testing.T, path, format string, build int) {
+	modTime := time.Date(2024, 1, build, 12, 0, 0, 0, time.UTC)
+	names := []string{"newrelic/", "newrelic/bin/agent", "newrelic/LICENSE"}
+	if build%2 == 0 {
+		names = []string{"newrelic/LICENSE", "newrelic/", "newrelic/bin/agent"}
+	}
+	modes := map[string]int64{"newrelic/": 0o775, "newrelic/bin/agent": 0o750 + int64(build%2)*0o25, "newrelic/LICENSE": 0o640 + int64(build%2)*0o4}
+
+	var buf bytes.Buffer
+	if format == "zip" {
+		zipWriter := zip.NewWriter(&buf)
+		for _, name := range names {
+			header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime}
+			header.SetMode(os.FileMode(modes[name]))
+			if name == "newrelic/" {
+				header.SetMode(os.ModeDir | os.FileMode(modes[name]))
+			}
+			w, err := zipWriter.CreateHeader(header)
+			require.NoError(t, err)
+			if name != "newrelic/" {
+				w.Write([]byte(name))
+			}
+		}
+		require.NoError(t, zipWriter.Close())
+	} else {
+		var out io.Writer = &buf
+		var gzipWriter *gzip.Writer
+		if format == "tar+gzip" {
+			gzipWriter = gzip.NewWriter(&buf)
+			gzipWriter.ModTime = modTime
+			out = gzipWriter
+		}
+		tarWriter := tar.NewWriter(out)
+		for _, name := range names {
+			header := &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: modes[name], Size: int64(len(name)), ModTime: modTime,
+				Uid: 1000 + build, Gid: 1000 + build, Uname: "runner", Gname: "docker", Format: tar.FormatPAX}
+			if name == "newrelic/" {
+				header.Typeflag = tar.TypeDir
+				header.Size = 0
+			}
+			require.NoError(t, tarWriter.WriteHeader(header))
+			if header.Size > 0 {
+				tarWriter.Write([]byte(name))
+			}
+		}
+		require.NoError(t, tarWriter.Close())
+		if gzipWriter != nil {
+			require.NoError(t, gzipWriter.Close())
+		}
+	}
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+}
+
+func TestNormalizeArchive(t *testing.T) {
+	// zstd is compressed and decompressed by the zstd CLI; the test archives are plain tars
+	originalReader, originalWriter := zstdReaderFunc, zstdWriterFunc
+	zstdReaderFunc = func(ctx context.Context, path string) (io.ReadCloser, error) {
+		return os.Open(path)
+	}
+	zstdWriterFunc = func(ctx context.Context, dest io.Writer) (io.WriteCloser, error) {
+		return nopWriteCloser{dest}, nil
+	}
+	defer func() { zstdReaderFunc, zstdWriterFunc = originalReader, originalWriter }()
+
+	for _, format := range []string{"tar", "tar+gzip", models.FormatTarZstd, "zip"} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			var normalized [][]byte
+			for build := 1; build <= 2; build++ {
+				src := filepath.Join(dir, "agent")
+				dest := filepath.Join(dir, "normalized")
+				buildFormat := format
+				if format == models.FormatTarZstd {
+					buildFormat = "tar"
+				}
+				buildArchive(t, src, buildFormat, build)
+
+				// method under test
+				require.NoError(t, NormalizeArchive(context.Background(), src, dest, format))
+
+				content, err := os.ReadFile(dest)
+				require.NoError(t, err)
+				normalized = append(normalized, content)
+			}
+
+			assert.Equal(t, normalized[0], normalized[1], "rebuilds normalize to the same bytes")
+			entries, err := ListArchiveEntries(context.Background(), filepath.Join(dir, "normalized"), format)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"newrelic/LICENSE", "newrelic/bin/agent"}, entries, "entries are sorted by name")
+		})
+	}
+
+	t.Run("tar headers", func(t *testing.T) {
+		dir := t.TempDir()
+		buildArchive(t, filepath.Join(dir, "agent.tar"), "tar", 1)
+
+		// method under test
+		require.NoError(t, NormalizeArchive(context.Background(), filepath.Join(dir, "agent.tar"), filepath.Join(dir, "normalized.tar"), "tar"))
+
+		headers := map[string]*tar.Header{}
+		require.NoError(t, walkTar(context.Background(), filepath.Join(dir, "normalized.tar"), "tar", func(header *tar.Header, content io.Reader) error {
+			headers[header.Name] = header
+			return nil
+		}))
+		for name, mode := range map[string]int64{"newrelic/": 0o755, "newrelic/bin/agent": 0o755, "newrelic/LICENSE": 0o644} {
+			header := headers[name]
+			require.NotNil(t, header, name)
+			assert.Equal(t, mode, header.Mode, name)
+			assert.Zero(t, header.Uid, name)
+			assert.Zero(t, header.Gid, name)
+			assert.Empty(t, header.Uname, name)
+			assert.Empty(t, header.Gname, name)
+			assert.True(t, header.ModTime.Equal(time.Unix(0, 0)), name)
+		}
+	})
+
+	t.Run("jar manifest first", func(t *testing.T) {
+		dir := t.TempDir()
+		writeJar(t, filepath.Join(dir, "agent.jar"), "Manifest-Version: 1.0\n", "LICENSE", "com/newrelic/Agent.class")
+
+		// method under test
+		require.NoError(t, NormalizeArchive(context.Background(), filepath.Join(dir, "agent.jar"), filepath.Join(dir, "normalized.jar"), models.FormatJar))
+
+		entries, err := ListArchiveEntries(context.Background(), filepath.Join(dir, "normalized.jar"), models.FormatJar)
+		require.NoError(t, err)
+		assert.Equal(t, []string{JarManifestPath, "LICENSE", "com/newrelic/Agent.class"}, entries)
+	})
+
+	t.Run("unreadable archive", func(t *testing.T) {
+		dir := t.TempDir()
+		buildArchive(t, filepath.Join(dir, "agent.tar"), "tar", 1)
+
+		// method under test
+		err := NormalizeArchive(context.Background(), filepath.Join(dir, "agent.tar"), filepath.Join(dir, "normalized.zip"), "zip")
+
+		assert.ErrorContains(t, err, "failed to read zip archive")
+	})
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestNormalizeArtifacts(t *testing.T) {
+	workspace := t.TempDir()
+	buildArchive(t, filepath.Join(workspace, "linux.tar.gz"), "tar+gzip", 1)
+	artifacts := []models.ArtifactDefinition{
+		{Name: "linux", Path: "./linux.tar.gz", Format: "tar+gzip"},
+		{Name: "image", Digest: "sha256:" + string(bytes.Repeat([]byte("a"), 64))},
+	}
+	normalizedDir := t.TempDir()
+
+	// method under test
+	normalized, err := NormalizeArtifacts(context.Background(), workspace, artifacts, normalizedDir)
+
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(normalizedDir, "linux", "linux.tar.gz"), normalized[0].Path)
+	assert.FileExists(t, normalized[0].Path)
+	assert.Equal(t, "./linux.tar.gz", artifacts[0].Path, "the given artifacts are left unchanged")
+	assert.Equal(t, artifacts[1], normalized[1], "referenced artifacts aren't normalized")
+}
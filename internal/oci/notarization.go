@@ -0,0 +1,372 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1" // #nosec G505 -- cdhashes of SHA-1 code directories are SHA-1 by definition
+	"crypto/sha256"
+	"debug/macho"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"agent-metadata-action/internal/github"
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/validation"
+)
+
+// Notarization statuses of the Mach-O binaries in a darwin archive, from best to worst
+const (
+	NotarizationNotarized    = "notarized"     // every binary is signed and Apple has issued a notarization ticket for it
+	NotarizationUnknown      = "unknown"       // every binary is signed, but the notarization of some couldn't be looked up
+	NotarizationNotNotarized = "not-notarized" // some signed binary has no notarization ticket
+	NotarizationUnsigned     = "unsigned"      // some binary has no signature, or only an ad-hoc one
+)
+
+// notarizationRank orders the statuses, so the status of an archive is the worst of its binaries
+var notarizationRank = map[string]int{NotarizationNotarized: 0, NotarizationUnknown: 1, NotarizationNotNotarized: 2, NotarizationUnsigned: 3}
+
+// notarizationLookupURL is the public Apple service Gatekeeper fetches notarization tickets from
+// This allows tests to override the service
+var notarizationLookupURL = "https://api.apple-cloudkit.com/database/1/com.apple.gk.ticket-delivery/production/public/records/lookup"
+
+var notarizationHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// Code signature structures, from the xnu cs_blobs.h header
+const (
+	lcCodeSignature         = 0x1d // load command pointing at the code signature in __LINKEDIT
+	csMagicEmbedded         = 0xfade0cc0
+	csMagicCodeDirectory    = 0xfade0c02
+	csSlotCodeDirectory     = 0
+	csSlotAlternateFirst    = 0x1000
+	csSlotAlternateLast     = 0x1004
+	csAdhoc                 = 0x2
+	csHashTypeSHA1          = 1
+	csHashTypeSHA256        = 2
+	maxCodeSignatureSize    = 16 << 20
+	codeDirectoryHeaderSize = 38
+)
+
+// codeSignature is the signature of a Mach-O slice, identified by the cdhash of its code directory
+type codeSignature struct {
+	adhoc    bool
+	hashType uint8
+	cdhash   string
+}
+
+// IsMachO reports whether header, the first 8 bytes of a file, starts a thin or universal Mach-O binary
+// Universal binaries share their magic with Java class files, which are told apart by the architecture count
+func IsMachO(header []byte) bool {
+	if len(header) < 8 {
+		return false
+	}
+	switch binary.BigEndian.Uint32(header) {
+	case macho.Magic32, macho.Magic64, 0xcefaedfe, 0xcffaedfe:
+		return true
+	case macho.MagicFat, 0xcafebabf:
+		archCount := binary.BigEndian.Uint32(header[4:])
+		return archCount > 0 && archCount < 20
+	}
+	return false
+}
+
+// readCodeSignatures returns the signature of every slice of the Mach-O binary read from r, nil for unsigned slices
+func readCodeSignatures(r io.ReaderAt) ([]*codeSignature, error) {
+	fat, err := macho.NewFatFile(r)
+	if err == nil {
+		defer fat.Close()
+		signatures := make([]*codeSignature, 0, len(fat.Arches))
+		for _, arch := range fat.Arches {
+			signature, err := readCodeSignature(arch.File, io.NewSectionReader(r, int64(arch.Offset), int64(arch.Size)))
+			if err != nil {
+				return nil, fmt.Errorf("%s slice: %w", arch.Cpu, err)
+			}
+			signatures = append(signatures, signature)
+		}
+		return signatures, nil
+	}
+	if !errors.Is(err, macho.ErrNotFat) {
+		return nil, fmt.Errorf("not a Mach-O file: %w", err)
+	}
+
+	file, err := macho.NewFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a Mach-O file: %w", err)
+	}
+	defer file.Close()
+	signature, err := readCodeSignature(file, r)
+	if err != nil {
+		return nil, err
+	}
+	return []*codeSignature{signature}, nil
+}
+
+// readCodeSignature finds the code directory of the slice file, read from r, and computes its cdhash
+// The SHA-256 code directory is preferred over the SHA-1 one older signatures carry alone
+func readCodeSignature(file *macho.File, r io.ReaderAt) (*codeSignature, error) {
+	var dataOffset, dataSize uint32
+	for _, load := range file.Loads {
+		raw := load.Raw()
+		if len(raw) >= 16 && file.ByteOrder.Uint32(raw) == lcCodeSignature {
+			dataOffset, dataSize = file.ByteOrder.Uint32(raw[8:]), file.ByteOrder.Uint32(raw[12:])
+		}
+	}
+	if dataSize == 0 {
+		return nil, nil
+	}
+	if dataSize > maxCodeSignatureSize {
+		return nil, fmt.Errorf("code signature of %d bytes is too large", dataSize)
+	}
+
+	blob := make([]byte, dataSize)
+	if _, err := r.ReadAt(blob, int64(dataOffset)); err != nil {
+		return nil, fmt.Errorf("failed to read the code signature: %w", err)
+	}
+	if len(blob) < 12 || binary.BigEndian.Uint32(blob) != csMagicEmbedded {
+		return nil, fmt.Errorf("invalid code signature")
+	}
+
+	var best *codeSignature
+	count := binary.BigEndian.Uint32(blob[8:])
+	for i := uint32(0); i < count; i++ {
+		index := 12 + int(i)*8
+		if index+8 > len(blob) {
+			return nil, fmt.Errorf("invalid code signature")
+		}
+		slot := binary.BigEndian.Uint32(blob[index:])
+		if slot != csSlotCodeDirectory && (slot < csSlotAlternateFirst || slot > csSlotAlternateLast) {
+			continue
+		}
+		signature, err := parseCodeDirectory(blob, int(binary.BigEndian.Uint32(blob[index+4:])))
+		if err != nil {
+			return nil, err
+		}
+		if signature != nil && (best == nil || signature.hashType == csHashTypeSHA256) {
+			best = signature
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("code signature has no supported code directory")
+	}
+	return best, nil
+}
+
+// parseCodeDirectory returns the signature described by the code directory at offset of the signature blob, or nil
+// if its hash type isn't supported
+func parseCodeDirectory(blob []byte, offset int) (*codeSignature, error) {
+	if offset < 0 || offset+codeDirectoryHeaderSize > len(blob) || binary.BigEndian.Uint32(blob[offset:]) != csMagicCodeDirectory {
+		return nil, fmt.Errorf("invalid code directory")
+	}
+	length := int(binary.BigEndian.Uint32(blob[offset+4:]))
+	if length < codeDirectoryHeaderSize || offset+length > len(blob) {
+		return nil, fmt.Errorf("invalid code directory")
+	}
+	directory := blob[offset : offset+length]
+	signature := &codeSignature{
+		adhoc:    binary.BigEndian.Uint32(directory[12:])&csAdhoc != 0,
+		hashType: directory[37],
+	}
+
+	// The cdhash is the hash of the code directory with its own algorithm, truncated to 20 bytes
+	switch signature.hashType {
+	case csHashTypeSHA1:
+		sum := sha1.Sum(directory) // #nosec G401 -- see import
+		signature.cdhash = hex.EncodeToString(sum[:20])
+	case csHashTypeSHA256:
+		sum := sha256.Sum256(directory)
+		signature.cdhash = hex.EncodeToString(sum[:20])
+	default:
+		return nil, nil
+	}
+	return signature, nil
+}
+
+// lookupNotarization reports whether Apple has issued a notarization ticket for the signature
+func lookupNotarization(ctx context.Context, signature *codeSignature) (bool, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"records": []map[string]string{{"recordName": fmt.Sprintf("2/%d/%s", signature.hashType, signature.cdhash)}},
+	})
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, notarizationLookupURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := notarizationHTTPClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return false, fmt.Errorf("notarization service returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var lookup struct {
+		Records []struct {
+			ServerErrorCode string                     `json:"serverErrorCode"`
+			Fields          map[string]json.RawMessage `json:"fields"`
+		} `json:"records"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&lookup); err != nil {
+		return false, fmt.Errorf("invalid notarization service response: %w", err)
+	}
+	if len(lookup.Records) == 0 {
+		return false, fmt.Errorf("invalid notarization service response: no records")
+	}
+	record := lookup.Records[0]
+	switch record.ServerErrorCode {
+	case "":
+		_, ok := record.Fields["signedTicket"]
+		return ok, nil
+	case "NOT_FOUND":
+		return false, nil
+	default:
+		return false, fmt.Errorf("notarization service returned %s", record.ServerErrorCode)
+	}
+}
+
+// machOBinary is a Mach-O file found in an archive, with the signatures of its slices
+type machOBinary struct {
+	name       string
+	signatures []*codeSignature
+}
+
+// checkArchiveNotarization returns the notarization status of the Mach-O binaries of the archive, with a problem per
+// binary that isn't notarized, or "" if there are none
+// Every binary is looked up by cdhash, including those of .app bundles with a stapled ticket
+func checkArchiveNotarization(ctx context.Context, archivePath, format string) (string, []string, error) {
+	spoolDir, err := os.MkdirTemp("", "agent-notarization-")
+	if err != nil {
+		return "", nil, err
+	}
+	defer os.RemoveAll(spoolDir)
+
+	var binaries []machOBinary
+	err = walkArchive(ctx, archivePath, format, func(name string, size int64, content io.Reader) error {
+		header := make([]byte, 8)
+		n, err := io.ReadFull(content, header)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		if !IsMachO(header[:n]) {
+			return nil
+		}
+
+		spoolPath := filepath.Join(spoolDir, "binary")
+		if err := writeFile(spoolPath, io.MultiReader(bytes.NewReader(header[:n]), content)); err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		file, err := os.Open(spoolPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		signatures, err := readCodeSignatures(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		binaries = append(binaries, machOBinary{name: name, signatures: signatures})
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if len(binaries) == 0 {
+		return "", nil, nil
+	}
+
+	status := NotarizationNotarized
+	var problems []string
+	worsen := func(to string) {
+		if notarizationRank[to] > notarizationRank[status] {
+			status = to
+		}
+	}
+	for _, machO := range binaries {
+		problem := ""
+		for _, signature := range machO.signatures {
+			if signature == nil || signature.adhoc {
+				problem = "unsigned"
+				break
+			}
+		}
+		if problem != "" {
+			worsen(NotarizationUnsigned)
+			problems = append(problems, fmt.Sprintf("%s (%s)", machO.name, problem))
+			continue
+		}
+		for _, signature := range machO.signatures {
+			notarized, err := lookupNotarization(ctx, signature)
+			if err != nil {
+				worsen(NotarizationUnknown)
+				problem = fmt.Sprintf("notarization lookup failed: %v", err)
+				break
+			}
+			if !notarized {
+				worsen(NotarizationNotNotarized)
+				problem = "not notarized"
+				break
+			}
+		}
+		if problem != "" {
+			problems = append(problems, fmt.Sprintf("%s (%s)", machO.name, problem))
+		}
+	}
+	return status, problems, nil
+}
+
+// CheckNotarization checks the Mach-O binaries in the archives of artifacts with CheckNotarization set, found
+// relative to workspacePath, have a signature that isn't ad-hoc and are notarized, setting Notarization of the
+// artifacts to their status
+// The signing certificate isn't checked: Apple only notarizes binaries signed with a Developer ID
+// Binaries that aren't are warned about, or with strict returned for every artifact together as validation.Errors
+func CheckNotarization(ctx context.Context, workspacePath string, artifacts []models.ArtifactDefinition, strict bool) error {
+	var errs validation.Errors
+	for i := range artifacts {
+		artifact := &artifacts[i]
+		if !artifact.CheckNotarization || artifact.IsReference() {
+			continue
+		}
+		archivePath, err := ResolveArtifactPath(workspacePath, artifact.Path)
+		if err != nil {
+			return err
+		}
+
+		status, problems, err := checkArchiveNotarization(ctx, archivePath, artifact.Format)
+		if err != nil {
+			errs.Add("", 0, fmt.Sprintf("binaries[%d]", i), fmt.Errorf("failed to check the notarization of artifact '%s': %w", artifact.Name, err))
+			continue
+		}
+		artifact.Notarization = status
+		switch status {
+		case "":
+			logging.Warnf(ctx, "Artifact '%s' is checked for notarization but contains no Mach-O binaries", artifact.Name)
+			continue
+		case NotarizationNotarized:
+			logging.Debugf(ctx, "Artifact '%s' is notarized", artifact.Name)
+			continue
+		}
+
+		err = fmt.Errorf("artifact '%s' is %s: %s", artifact.Name, status, strings.Join(problems, ", "))
+		if strict {
+			github.AddWorkflowAnnotation(ctx, github.AnnotationFailure, "macOS binaries not notarized", err.Error())
+			errs.Add("", 0, fmt.Sprintf("binaries[%d]", i), err)
+			continue
+		}
+		logging.Warn(ctx, err.Error())
+		github.AddWorkflowAnnotation(ctx, github.AnnotationWarning, "macOS binaries not notarized", err.Error())
+	}
+	return errs.Err()
+}
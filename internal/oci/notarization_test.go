@@ -0,0 +1,232 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/testutil"
+	"agent-metadata-action/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildMachO returns a 64-bit Mach-O executable, with a code signature whose code directory has identifier id
+// unless unsigned, and its cdhash
+func buildMachO(t *testing.T, id string, adhoc, unsigned bool) ([]byte, string) {
+	directory := make([]byte, codeDirectoryHeaderSize+2)
+	binary.BigEndian.PutUint32(directory[0:], csMagicCodeDirectory)
+	binary.BigEndian.PutUint32(directory[8:], 0x20400)
+	if adhoc {
+		binary.BigEndian.PutUint32(directory[12:], csAdhoc)
+	}
+	directory[36] = 32
+	directory[37] = csHashTypeSHA256
+	directory = append(directory, id...)
+	binary.BigEndian.PutUint32(directory[4:], uint32(len(directory)))
+	sum := sha256.Sum256(directory)
+
+	signature := make([]byte, 20)
+	binary.BigEndian.PutUint32(signature[0:], csMagicEmbedded)
+	binary.BigEndian.PutUint32(signature[4:], uint32(20+len(directory)))
+	binary.BigEndian.PutUint32(signature[8:], 1)
+	binary.BigEndian.PutUint32(signature[12:], csSlotCodeDirectory)
+	binary.BigEndian.PutUint32(signature[16:], 20)
+	signature = append(signature, directory...)
+
+	var buf bytes.Buffer
+	commands := []uint32{lcCodeSignature, 16, 48, uint32(len(signature))}
+	if unsigned {
+		commands = []uint32{0x26, 16, 0, 0} // LC_FUNCTION_STARTS
+	}
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, []uint32{0xfeedfacf, 0x01000007, 3, 2, 1, 16, 0, 0}))
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, commands))
+	buf.Write(signature)
+	return buf.Bytes(), hex.EncodeToString(sum[:20])
+}
+
+// buildFatMachO returns a universal binary of slices
+func buildFatMachO(t *testing.T, slices ...[]byte) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, binary.Write(&buf, binary.BigEndian, []uint32{0xcafebabe, uint32(len(slices))}))
+	offset := uint32(0x1000)
+	for i, slice := range slices {
+		require.NoError(t, binary.Write(&buf, binary.BigEndian, []uint32{0x01000007 + uint32(i)*5, 3, offset, uint32(len(slice)), 12}))
+		offset += 0x1000
+	}
+	for i, slice := range slices {
+		buf.Write(make([]byte, 0x1000*(i+1)-buf.Len()))
+		buf.Write(slice)
+	}
+	return buf.Bytes()
+}
+
+// notarizationService serves notarization tickets for the cdhashes notarized, failing every lookup when broken
+func notarizationService(t *testing.T, notarized map[string]bool, broken bool) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if broken {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var lookup struct {
+			Records []struct {
+				RecordName string `json:"recordName"`
+			} `json:"records"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&lookup))
+		name := lookup.Records[0].RecordName
+		if notarized[strings.TrimPrefix(name, "2/2/")] {
+			w.Write([]byte(`{"records":[{"recordName":"` + name + `","fields":{"signedTicket":{"type":"BYTES","value":"czhjaA=="}}}]}`))
+			return
+		}
+		w.Write([]byte(`{"records":[{"recordName":"` + name + `","serverErrorCode":"NOT_FOUND","reason":"Record not found"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	original := notarizationLookupURL
+	notarizationLookupURL = server.URL
+	t.Cleanup(func() { notarizationLookupURL = original })
+}
+
+func TestIsMachO(t *testing.T) {
+	thin, _ := buildMachO(t, "agent", false, false)
+	assert.True(t, IsMachO(thin[:8]))
+	assert.True(t, IsMachO(buildFatMachO(t, thin)[:8]))
+	assert.False(t, IsMachO([]byte{0xca, 0xfe, 0xba, 0xbe, 0x00, 0x00, 0x00, 0x34}), "Java class files aren't Mach-O")
+	assert.False(t, IsMachO([]byte("#!/bin/sh\n")))
+	assert.False(t, IsMachO([]byte{0xcf, 0xfa}))
+}
+
+func TestReadCodeSignatures(t *testing.T) {
+	signed, cdhash := buildMachO(t, "agent", false, false)
+	adhoc, _ := buildMachO(t, "agent", true, false)
+	unsigned, _ := buildMachO(t, "agent", false, true)
+
+	signatures, err := readCodeSignatures(bytes.NewReader(signed))
+	require.NoError(t, err)
+	assert.Equal(t, []*codeSignature{{hashType: csHashTypeSHA256, cdhash: cdhash}}, signatures)
+
+	signatures, err = readCodeSignatures(bytes.NewReader(adhoc))
+	require.NoError(t, err)
+	require.Len(t, signatures, 1)
+	assert.True(t, signatures[0].adhoc)
+
+	signatures, err = readCodeSignatures(bytes.NewReader(buildFatMachO(t, signed, unsigned)))
+	require.NoError(t, err)
+	assert.Equal(t, []*codeSignature{{hashType: csHashTypeSHA256, cdhash: cdhash}, nil}, signatures, "each slice has its own signature")
+
+	_, err = readCodeSignatures(bytes.NewReader([]byte("#!/bin/sh\n")))
+	assert.ErrorContains(t, err, "not a Mach-O file")
+}
+
+func TestCheckNotarization(t *testing.T) {
+	notarizedBinary, notarizedHash := buildMachO(t, "com.newrelic.agent", false, false)
+	otherBinary, _ := buildMachO(t, "com.newrelic.helper", false, false)
+	adhocBinary, _ := buildMachO(t, "com.newrelic.helper", true, false)
+
+	writeTarGz := func(t *testing.T, path string, files map[string][]byte) {
+		var buf bytes.Buffer
+		gzipWriter := gzip.NewWriter(&buf)
+		tarWriter := tar.NewWriter(gzipWriter)
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o755, Size: int64(len(files[name]))}))
+			tarWriter.Write(files[name])
+		}
+		require.NoError(t, tarWriter.Close())
+		require.NoError(t, gzipWriter.Close())
+		require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+	}
+
+	workspace := t.TempDir()
+	writeTarGz(t, filepath.Join(workspace, "notarized.tar.gz"), map[string][]byte{
+		"newrelic/bin/agent":   notarizedBinary,
+		"newrelic/LICENSE":     []byte("license"),
+		"newrelic/lib/a.class": {0xca, 0xfe, 0xba, 0xbe, 0x00, 0x00, 0x00, 0x34},
+	})
+	writeTarGz(t, filepath.Join(workspace, "bundle.tar.gz"), map[string][]byte{
+		"Agent.app/Contents/MacOS/helper":  otherBinary,
+		"Agent.app/Contents/CodeResources": []byte("ticket"),
+	})
+	writeTarGz(t, filepath.Join(workspace, "mixed.tar.gz"), map[string][]byte{
+		"newrelic/bin/agent":  notarizedBinary,
+		"newrelic/bin/helper": otherBinary,
+		"newrelic/bin/adhoc":  adhocBinary,
+	})
+	writeTarGz(t, filepath.Join(workspace, "scripts.tar.gz"), map[string][]byte{"newrelic/install.sh": []byte("#!/bin/sh\n")})
+
+	artifact := func(name string) models.ArtifactDefinition {
+		return models.ArtifactDefinition{Name: strings.TrimSuffix(name, ".tar.gz"), Path: "./" + name, Format: "tar+gzip", OS: "darwin", CheckNotarization: true}
+	}
+
+	t.Run("notarized binaries", func(t *testing.T) {
+		notarizationService(t, map[string]bool{notarizedHash: true}, false)
+		artifacts := []models.ArtifactDefinition{artifact("notarized.tar.gz"), artifact("scripts.tar.gz")}
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := CheckNotarization(context.Background(), workspace, artifacts, true)
+
+		require.NoError(t, err)
+		assert.Equal(t, NotarizationNotarized, artifacts[0].Notarization)
+		assert.Empty(t, artifacts[1].Notarization, "archives without Mach-O binaries have no status")
+		assert.Equal(t, NotarizationNotarized, CreateLayerAnnotations(&artifacts[0], "1.2.3")[NotarizationAnnotation])
+	})
+
+	t.Run("binaries of a bundle with a CodeResources file are looked up too", func(t *testing.T) {
+		notarizationService(t, map[string]bool{notarizedHash: true}, false)
+		artifacts := []models.ArtifactDefinition{artifact("bundle.tar.gz")}
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := CheckNotarization(context.Background(), workspace, artifacts, true)
+
+		assert.ErrorContains(t, err, "artifact 'bundle' is not-notarized: Agent.app/Contents/MacOS/helper (not notarized)")
+		assert.Equal(t, NotarizationNotNotarized, artifacts[0].Notarization)
+	})
+
+	t.Run("strict mode blocks binaries that aren't notarized", func(t *testing.T) {
+		notarizationService(t, map[string]bool{notarizedHash: true}, false)
+		artifacts := []models.ArtifactDefinition{artifact("notarized.tar.gz"), artifact("mixed.tar.gz")}
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := CheckNotarization(context.Background(), workspace, artifacts, true)
+
+		var errs validation.Errors
+		require.ErrorAs(t, err, &errs)
+		require.Len(t, errs, 1)
+		assert.Contains(t, errs[0].Error(), "binaries[1]: artifact 'mixed' is unsigned: newrelic/bin/adhoc (unsigned), newrelic/bin/helper (not notarized)")
+		assert.Equal(t, NotarizationUnsigned, artifacts[1].Notarization)
+	})
+
+	t.Run("warns without strict mode", func(t *testing.T) {
+		notarizationService(t, nil, true)
+		artifacts := []models.ArtifactDefinition{artifact("notarized.tar.gz")}
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := CheckNotarization(context.Background(), workspace, artifacts, false)
+
+		require.NoError(t, err)
+		assert.Equal(t, NotarizationUnknown, artifacts[0].Notarization)
+		assert.Contains(t, getStdout(), "artifact 'notarized' is unknown: newrelic/bin/agent (notarization lookup failed: notarization service returned status 503")
+	})
+}
@@ -0,0 +1,143 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"agent-metadata-action/internal/models"
+)
+
+// Plan is what an upload pushes to the registry, worked out locally before anything is pushed: the manifest and
+// layers of every binary and the manifest index listing them
+// Applying the plan pushes exactly these digests, so it can be reviewed before a release and checked against the
+// registry afterwards
+type Plan struct {
+	Registry  string            `json:"registry"`
+	Tag       string            `json:"tag"`
+	Version   string            `json:"version"`
+	Created   time.Time         `json:"created"` // creation time stamped on the manifests and the index
+	Artifacts []PlannedArtifact `json:"artifacts"`
+	Index     *PlannedContent   `json:"index,omitempty"` // omitted when it lists manifests only the registry knows the size of
+}
+
+// PlannedArtifact is the manifest of a binary, or of a manifest or image already in a registry, in an upload plan
+type PlannedArtifact struct {
+	Name       string           `json:"name"`
+	Path       string           `json:"path,omitempty"`
+	OS         string           `json:"os"`
+	Arch       string           `json:"arch"`
+	Manifest   PlannedContent   `json:"manifest"`
+	Layers     []PlannedContent `json:"layers,omitempty"`
+	Referenced bool             `json:"referenced,omitempty"` // already pushed, so only its digest is known
+}
+
+// PlannedContent is a manifest or blob an upload pushes
+type PlannedContent struct {
+	MediaType string `json:"mediaType,omitempty"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size,omitempty"`
+}
+
+// Plan works out the manifests and index the upload of the binaries of ociConfig for version pushes, without
+// reaching the registry
+// The client stamps its manifests with the plan's creation time from then on, so applying the plan with it pushes
+// the planned digests
+func (c *Client) Plan(ctx context.Context, ociConfig *models.OCIConfig, workspace, version string) (Plan, error) {
+	if c.created.IsZero() {
+		// Annotations keep whole seconds, so the planned time is what ends up in them
+		c.created = time.Now().UTC().Truncate(time.Second)
+	}
+	plan := Plan{Registry: ociConfig.Registry, Tag: IndexTag(ociConfig, version), Version: version, Created: c.created}
+
+	// The index of a merge lists manifests only the registry knows, and a handoff pushes none
+	complete := !ociConfig.MergeIndex && ociConfig.HandoffFile == ""
+	indexEntries := make([]models.ArtifactUploadResult, 0, len(ociConfig.Artifacts))
+	for i := range ociConfig.Artifacts {
+		artifact := &ociConfig.Artifacts[i]
+		planned := PlannedArtifact{Name: artifact.Name, Path: artifact.Path, OS: artifact.OS, Arch: artifact.Arch}
+		if artifact.IsReference() {
+			planned.Path = ""
+			planned.Referenced = true
+			planned.Manifest.Digest = artifact.Digest
+			if artifact.IsImage() {
+				planned.Manifest.Digest = artifact.ImageDigest()
+			}
+			plan.Artifacts = append(plan.Artifacts, planned)
+			complete = false
+			continue
+		}
+
+		fullPath, err := ResolveArtifactPath(workspace, artifact.Path)
+		if err != nil {
+			return Plan{}, err
+		}
+		_, manifestDesc, layers, err := c.packArtifact(ctx, artifact, fullPath, version)
+		if err != nil {
+			return Plan{}, fmt.Errorf("failed to plan the upload of %s: %w", artifact.Name, err)
+		}
+		planned.Manifest = PlannedContent{MediaType: manifestDesc.MediaType, Digest: manifestDesc.Digest.String(), Size: manifestDesc.Size}
+		for _, layer := range layers {
+			planned.Layers = append(planned.Layers, PlannedContent{MediaType: layer.MediaType, Digest: layer.Digest.String(), Size: layer.Size})
+		}
+		plan.Artifacts = append(plan.Artifacts, planned)
+		indexEntries = append(indexEntries, models.ArtifactUploadResult{
+			Name: artifact.Name, OS: artifact.OS, Arch: artifact.Arch,
+			Digest: planned.Manifest.Digest, Size: manifestDesc.Size, Uploaded: true,
+		})
+	}
+
+	if complete {
+		indexDesc, _, err := c.buildIndex(ctx, indexEntries, version)
+		if err != nil {
+			return Plan{}, fmt.Errorf("failed to plan the manifest index: %w", err)
+		}
+		plan.Index = &PlannedContent{MediaType: indexDesc.MediaType, Digest: indexDesc.Digest.String(), Size: indexDesc.Size}
+	}
+	return plan, nil
+}
+
+// CheckArtifacts fails if an uploaded binary was pushed as another manifest than planned, e.g. because its file
+// changed after planning
+func (p Plan) CheckArtifacts(uploadResults []models.ArtifactUploadResult) error {
+	planned := make(map[string]PlannedArtifact, len(p.Artifacts))
+	for _, artifact := range p.Artifacts {
+		planned[artifact.Name] = artifact
+	}
+	for _, result := range uploadResults {
+		artifact, ok := planned[result.Name]
+		if !ok || artifact.Referenced || !result.Uploaded || result.Referenced {
+			continue
+		}
+		if result.Digest != artifact.Manifest.Digest {
+			return fmt.Errorf("%s was pushed as %s rather than %s as planned - did its file change during the run?", result.Name, result.Digest, artifact.Manifest.Digest)
+		}
+	}
+	return nil
+}
+
+// CheckIndex fails if the manifest index was pushed with another digest than planned
+func (p Plan) CheckIndex(indexDigest string) error {
+	if p.Index != nil && p.Index.Digest != indexDigest {
+		return fmt.Errorf("the manifest index was pushed as %s rather than %s as planned", indexDigest, p.Index.Digest)
+	}
+	return nil
+}
+
+// WritePlan writes plan as JSON to path, creating its directory
+func WritePlan(path string, plan Plan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal upload plan: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create upload plan directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agent-metadata-action/internal/github"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Plan(t *testing.T) {
+	registry := newManifestRegistry()
+	server := httptest.NewServer(registry)
+	defer server.Close()
+	testutil.CaptureOutput(t)
+
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "linux.tar.gz"), []byte("linux agent"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "windows.zip"), []byte("windows agent"), 0o644))
+	ociConfig := &models.OCIConfig{
+		Registry: strings.TrimPrefix(server.URL, "http://") + "/agents",
+		Artifacts: []models.ArtifactDefinition{
+			{Name: "linux", Path: "./linux.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip"},
+			{Name: "windows", Path: "./windows.zip", OS: "windows", Arch: "amd64", Format: "zip"},
+		},
+	}
+
+	t.Run("the upload pushes the planned digests", func(t *testing.T) {
+		client, err := NewClient(context.Background(), ociConfig.Registry, "", "", Connection{})
+		require.NoError(t, err)
+
+		// method under test
+		plan, err := client.Plan(context.Background(), ociConfig, workspace, "1.2.3")
+
+		require.NoError(t, err)
+		assert.Equal(t, "1.2.3", plan.Tag)
+		require.Len(t, plan.Artifacts, 2)
+		assert.Equal(t, digest.FromString("linux agent").String(), plan.Artifacts[0].Layers[0].Digest)
+		require.NotNil(t, plan.Index)
+		assert.Empty(t, registry.manifests, "Planning pushes nothing")
+
+		uploadResults := UploadArtifacts(context.Background(), client, ociConfig, workspace, "1.2.3")
+		require.NoError(t, plan.CheckArtifacts(uploadResults))
+		assert.Equal(t, plan.Artifacts[0].Manifest.Digest, uploadResults[0].Digest)
+		assert.Equal(t, plan.Artifacts[1].Manifest.Digest, uploadResults[1].Digest)
+		indexDigest, err := client.CreateManifestIndex(context.Background(), uploadResults, "1.2.3", "1.2.3")
+		require.NoError(t, err)
+		assert.Equal(t, plan.Index.Digest, indexDigest)
+		assert.NoError(t, plan.CheckIndex(indexDigest))
+	})
+
+	t.Run("pre-pushed manifests leave the index out", func(t *testing.T) {
+		client, err := NewClient(context.Background(), ociConfig.Registry, "", "", Connection{})
+		require.NoError(t, err)
+		referenced := *ociConfig
+		manifestDigest := "sha256:" + strings.Repeat("a", 64)
+		referenced.Artifacts = append(referenced.Artifacts[:1:1], models.ArtifactDefinition{Name: "image", Digest: manifestDigest, OS: "linux", Arch: "arm64"})
+
+		// method under test
+		plan, err := client.Plan(context.Background(), &referenced, workspace, "1.2.3")
+
+		require.NoError(t, err)
+		require.Len(t, plan.Artifacts, 2)
+		assert.True(t, plan.Artifacts[1].Referenced)
+		assert.Equal(t, manifestDigest, plan.Artifacts[1].Manifest.Digest)
+		assert.Nil(t, plan.Index)
+	})
+}
+
+func TestClient_Plan_Annotations(t *testing.T) {
+	registry := newManifestRegistry()
+	server := httptest.NewServer(registry)
+	defer server.Close()
+	testutil.CaptureOutput(t)
+
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "linux.tar.gz"), []byte("linux agent"), 0o644))
+	ociConfig := &models.OCIConfig{
+		Registry:  strings.TrimPrefix(server.URL, "http://") + "/agents",
+		Artifacts: []models.ArtifactDefinition{{Name: "linux", Path: "./linux.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip"}},
+	}
+	client, err := NewClient(context.Background(), ociConfig.Registry, "", "", Connection{})
+	require.NoError(t, err)
+	plain, err := client.Plan(context.Background(), ociConfig, workspace, "1.2.3")
+	require.NoError(t, err)
+	client.annotations = map[string]string{"com.newrelic.team": "apm-java"}
+	client.build = map[string]string{BuildRunIDAnnotation: "123456"}
+
+	// method under test
+	plan, err := client.Plan(context.Background(), ociConfig, workspace, "1.2.3")
+	require.NoError(t, err)
+
+	uploadResults := UploadArtifacts(context.Background(), client, ociConfig, workspace, "1.2.3")
+	require.NoError(t, plan.CheckArtifacts(uploadResults))
+	indexDigest, err := client.CreateManifestIndex(context.Background(), uploadResults, "1.2.3", "1.2.3")
+	require.NoError(t, err)
+	require.NoError(t, plan.CheckIndex(indexDigest))
+	assert.NotEqual(t, plain.Artifacts[0].Manifest.Digest, plan.Artifacts[0].Manifest.Digest, "The annotations change the manifest")
+
+	var manifest ocispec.Manifest
+	require.NoError(t, json.Unmarshal(registry.manifests[uploadResults[0].Digest], &manifest))
+	assert.Equal(t, "apm-java", manifest.Annotations["com.newrelic.team"])
+	assert.Equal(t, "apm-java", manifest.Layers[0].Annotations["com.newrelic.team"])
+	assert.Equal(t, "binary", manifest.Layers[0].Annotations["com.newrelic.artifact.type"])
+	assert.Equal(t, "123456", manifest.Annotations[BuildRunIDAnnotation])
+	assert.NotContains(t, manifest.Layers[0].Annotations, BuildRunIDAnnotation, "Build annotations are on manifests only")
+	var index ocispec.Index
+	require.NoError(t, json.Unmarshal(registry.manifests[indexDigest], &index))
+	assert.Equal(t, "apm-java", index.Annotations["com.newrelic.team"])
+	assert.Equal(t, "123456", index.Annotations[BuildRunIDAnnotation])
+}
+
+func TestPlan_Check(t *testing.T) {
+	plan := Plan{
+		Artifacts: []PlannedArtifact{{Name: "linux", Manifest: PlannedContent{Digest: "sha256:planned"}}},
+		Index:     &PlannedContent{Digest: "sha256:index"},
+	}
+
+	t.Run("artifact pushed as planned", func(t *testing.T) {
+		// method under test
+		err := plan.CheckArtifacts([]models.ArtifactUploadResult{{Name: "linux", Digest: "sha256:planned", Uploaded: true}})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("artifact pushed differently", func(t *testing.T) {
+		// method under test
+		err := plan.CheckArtifacts([]models.ArtifactUploadResult{{Name: "linux", Digest: "sha256:other", Uploaded: true}})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "linux was pushed as sha256:other rather than sha256:planned as planned")
+	})
+
+	t.Run("index pushed differently", func(t *testing.T) {
+		// method under test
+		err := plan.CheckIndex("sha256:other")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the manifest index was pushed as sha256:other rather than sha256:index as planned")
+	})
+
+	t.Run("no planned index", func(t *testing.T) {
+		// method under test
+		err := Plan{}.CheckIndex("sha256:other")
+
+		assert.NoError(t, err)
+	})
+}
+
+func TestHandlePlan(t *testing.T) {
+	getStdout, _ := testutil.CaptureOutput(t)
+	workspace := t.TempDir()
+	writeArchive(t, workspace, "agent.tar.gz", "tar+gzip", "newrelic/agent")
+	ociConfig := &models.OCIConfig{
+		Registry: "registry.invalid/agents",
+		Artifacts: []models.ArtifactDefinition{
+			{Name: "linux", Path: "./agent.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip"},
+		},
+		PlanFile: "out/plan.json",
+	}
+
+	// method under test
+	plan, err := HandlePlan(context.Background(), ociConfig, workspace, "1.2.3")
+
+	require.NoError(t, err)
+	require.NotNil(t, plan.Index)
+	assert.Contains(t, getStdout(), "Planned the upload of 1 binaries as manifest index "+plan.Index.Digest)
+
+	data, err := os.ReadFile(filepath.Join(workspace, "out", "plan.json"))
+	require.NoError(t, err)
+	var written Plan
+	require.NoError(t, json.Unmarshal(data, &written))
+	assert.Equal(t, plan.Index.Digest, written.Index.Digest)
+	assert.Equal(t, "./agent.tar.gz", written.Artifacts[0].Path)
+	assert.Equal(t, "registry.invalid/agents", written.Registry)
+}
+
+func TestHandlePlan_Reproducible(t *testing.T) {
+	testutil.CaptureOutput(t)
+	commitDate := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	originalCommitDate := github.CommitDateFunc
+	github.CommitDateFunc = func(ctx context.Context, workspace string) (time.Time, error) { return commitDate, nil }
+	t.Cleanup(func() { github.CommitDateFunc = originalCommitDate })
+	t.Setenv("GITHUB_REPOSITORY", "newrelic/newrelic-java-agent")
+	t.Setenv("GITHUB_SHA", "0123456789abcdef0123456789abcdef01234567")
+
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "linux.tar.gz"), []byte("linux agent"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "windows.zip"), []byte("windows agent"), 0o644))
+	linux := models.ArtifactDefinition{Name: "linux", Path: "./linux.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip"}
+	windows := models.ArtifactDefinition{Name: "windows", Path: "./windows.zip", OS: "windows", Arch: "amd64", Format: "zip"}
+	planRun := func(runID string, artifacts ...models.ArtifactDefinition) Plan {
+		t.Setenv("GITHUB_RUN_ID", runID)
+		ociConfig := &models.OCIConfig{Registry: "registry.invalid/agents", Artifacts: artifacts, CreatedSource: models.CreatedSourceCommit}
+
+		// method under test
+		plan, err := HandlePlan(context.Background(), ociConfig, workspace, "1.2.3")
+
+		require.NoError(t, err)
+		require.NotNil(t, plan.Index)
+		return plan
+	}
+
+	first := planRun("1", linux, windows)
+	again := planRun("2", windows, linux)
+
+	assert.Equal(t, commitDate.UTC(), first.Created)
+	assert.Equal(t, first.Index.Digest, again.Index.Digest, "Another run of the commit, listing the binaries in another order, gives the same index")
+	assert.Equal(t, first.Artifacts[0].Manifest.Digest, again.Artifacts[1].Manifest.Digest)
+
+	t.Run("run-specific build annotations are left out", func(t *testing.T) {
+		client, err := NewClient(context.Background(), "registry.invalid/agents", "", "", Connection{})
+		require.NoError(t, err)
+
+		require.NoError(t, configureClient(context.Background(), client, &models.OCIConfig{CreatedSource: models.CreatedSourceCommit}, workspace))
+
+		assert.Equal(t, map[string]string{
+			BuildRepositoryAnnotation: "newrelic/newrelic-java-agent",
+			BuildSHAAnnotation:        "0123456789abcdef0123456789abcdef01234567",
+		}, client.build)
+	})
+}
@@ -0,0 +1,125 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// PendingTagSuffix is appended to the version for the tag of a manifest index awaiting promotion
+const PendingTagSuffix = "-pending"
+
+// cosignSignatureSuffix completes the sha256-<hex> tag cosign stores signatures under when it doesn't attach them
+// as referrers
+const cosignSignatureSuffix = ".sig"
+
+// IndexTag returns the tag the manifest index of version is pushed under
+func IndexTag(ociConfig *models.OCIConfig, version string) string {
+	if ociConfig.PendingTag {
+		return version + PendingTagSuffix
+	}
+	return version
+}
+
+// ResolvePending returns the manifest index pending promotion to version
+// Fails unless the pending tag points to an image index that is signed
+func (c *Client) ResolvePending(ctx context.Context, version string) (ocispec.Descriptor, error) {
+	tag := version + PendingTagSuffix
+	desc, err := c.repo.Resolve(ctx, tag)
+	if err != nil {
+		return desc, fmt.Errorf("failed to resolve %s:%s: %w", c.registry, tag, err)
+	}
+	if desc.MediaType != ocispec.MediaTypeImageIndex {
+		return desc, fmt.Errorf("%s:%s has media type %s rather than being the manifest index of a release", c.registry, tag, desc.MediaType)
+	}
+
+	signed, err := c.IsSigned(ctx, desc)
+	if err != nil {
+		return desc, fmt.Errorf("failed to look up the signature of %s:%s: %w", c.registry, tag, err)
+	}
+	if !signed {
+		return desc, fmt.Errorf("%s:%s (%s) isn't signed: only signed indexes are promoted", c.registry, tag, desc.Digest)
+	}
+	return desc, nil
+}
+
+// IsSigned reports whether a signature refers to the manifest, either as a referrer (through the referrers API or
+// its tag schema fallback) or under the sha256-<hex>.sig tag cosign uses
+// Any referrer counts as a signature, since the signing service is the only thing that attaches them
+func (c *Client) IsSigned(ctx context.Context, desc ocispec.Descriptor) (bool, error) {
+	referrers := 0
+	err := c.repo.Referrers(ctx, desc, "", func(page []ocispec.Descriptor) error {
+		referrers += len(page)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if referrers > 0 {
+		return true, nil
+	}
+
+	_, err = c.repo.Resolve(ctx, strings.Replace(desc.Digest.String(), ":", "-", 1)+cosignSignatureSuffix)
+	if errors.Is(err, errdef.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// TagIndex tags the manifest index with tag, replacing whatever the tag pointed to
+func (c *Client) TagIndex(ctx context.Context, desc ocispec.Descriptor, tag string) error {
+	if err := c.repo.Tag(ctx, desc, tag); err != nil {
+		return fmt.Errorf("failed to tag %s as %s:%s: %w", desc.Digest, c.registry, tag, err)
+	}
+	return nil
+}
+
+// HandlePromotion moves the signed manifest index pending for version to the version tag, then to extraTags such as
+// latest, and returns its digest
+// Dry runs only check the index can be promoted
+// The pending tag is left in place, since not every registry supports deleting tags
+func HandlePromotion(ctx context.Context, ociConfig *models.OCIConfig, version string, extraTags []string, dryRun bool) (string, error) {
+	conn := ConnectionFor(ociConfig)
+	WarnInsecureConnection(ctx, ociConfig.Registry, conn)
+	results.RecordRegistry(ctx, results.Registry{
+		URL:                   ociConfig.Registry,
+		PlainHTTP:             conn.PlainHTTP || IsLocalRegistry(ociConfig.Registry),
+		InsecureSkipTLSVerify: conn.InsecureSkipTLSVerify,
+	})
+
+	client, err := newClientFunc(ctx, ociConfig.Registry, ociConfig.Username, ociConfig.Password, conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to create OCI client: %w", err)
+	}
+
+	desc, err := client.ResolvePending(ctx, version)
+	if err != nil {
+		return "", err
+	}
+	pendingTag := version + PendingTagSuffix
+	indexDigest := desc.Digest.String()
+	logging.Noticef(ctx, "Manifest index %s:%s (%s) is signed", ociConfig.Registry, pendingTag, indexDigest)
+
+	tags := append([]string{version}, extraTags...)
+	if dryRun {
+		logging.Noticef(ctx, "Dry run - not tagging %s as %s", indexDigest, strings.Join(tags, ", "))
+		return indexDigest, nil
+	}
+
+	for _, tag := range tags {
+		if err := client.TagIndex(ctx, desc, tag); err != nil {
+			return "", err
+		}
+		logging.Noticef(ctx, "Promoted %s to %s:%s", indexDigest, ociConfig.Registry, tag)
+	}
+	results.RecordPromotion(ctx, ociConfig.Registry, pendingTag, tags, indexDigest)
+	return indexDigest, nil
+}
@@ -0,0 +1,242 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// manifestRegistry serves the manifests and blobs of a single repository, agents, by tag and digest
+type manifestRegistry struct {
+	manifests map[string][]byte // by digest
+	types     map[string]string // media type by digest
+	tags      map[string]string // digest by tag
+	referrers map[string]bool   // digests with a signature referrer
+	blobs     map[string][]byte // by digest
+}
+
+func newManifestRegistry() *manifestRegistry {
+	return &manifestRegistry{manifests: map[string][]byte{}, types: map[string]string{}, tags: map[string]string{}, referrers: map[string]bool{}, blobs: map[string][]byte{}}
+}
+
+// addBlob stores a blob and returns its descriptor
+func (m *manifestRegistry) addBlob(mediaType string, content []byte) ocispec.Descriptor {
+	d := digest.FromBytes(content)
+	m.blobs[d.String()] = content
+	return ocispec.Descriptor{MediaType: mediaType, Digest: d, Size: int64(len(content))}
+}
+
+// add stores a manifest under tag and returns its digest
+func (m *manifestRegistry) add(tag, mediaType string, content []byte) string {
+	d := digest.FromBytes(content).String()
+	m.manifests[d] = content
+	m.types[d] = mediaType
+	m.tags[tag] = d
+	return d
+}
+
+func (m *manifestRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if ref, ok := strings.CutPrefix(r.URL.Path, "/v2/agents/referrers/"); ok {
+		w.Header().Set("Content-Type", ocispec.MediaTypeImageIndex)
+		index := `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`
+		if m.referrers[ref] {
+			index = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","artifactType":"application/vnd.cncf.notary.signature","digest":"sha256:` + strings.Repeat("a", 64) + `","size":10}]}`
+		}
+		w.Write([]byte(index))
+		return
+	}
+
+	if r.Method == http.MethodPost && r.URL.Path == "/v2/agents/blobs/uploads/" {
+		w.Header().Set("Location", "/v2/agents/blobs/uploads/session")
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if r.URL.Path == "/v2/agents/blobs/uploads/session" {
+		if r.Method == http.MethodPut {
+			content, _ := io.ReadAll(r.Body)
+			m.blobs[r.URL.Query().Get("digest")] = content
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if d, ok := strings.CutPrefix(r.URL.Path, "/v2/agents/blobs/"); ok {
+		content, ok := m.blobs[d]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Docker-Content-Digest", d)
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if r.Method == http.MethodGet {
+			w.Write(content)
+		}
+		return
+	}
+
+	if r.URL.Path == "/v2/agents/tags/list" {
+		tags := []string{}
+		for tag := range m.tags {
+			if !strings.HasPrefix(tag, "sha256:") {
+				tags = append(tags, tag)
+			}
+		}
+		sort.Strings(tags)
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": "agents", "tags": tags})
+		return
+	}
+
+	ref, ok := strings.CutPrefix(r.URL.Path, "/v2/agents/manifests/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		d := ref
+		if tagged, ok := m.tags[ref]; ok {
+			d = tagged
+		}
+		content, ok := m.manifests[d]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", m.types[d])
+		w.Header().Set("Docker-Content-Digest", d)
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		if r.Method == http.MethodGet {
+			w.Write(content)
+		}
+	case http.MethodPut:
+		content, _ := io.ReadAll(r.Body)
+		d := m.add(ref, r.Header.Get("Content-Type"), content)
+		w.Header().Set("Docker-Content-Digest", d)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if _, ok := m.manifests[ref]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(m.manifests, ref)
+		for tag, d := range m.tags {
+			if d == ref {
+				delete(m.tags, tag)
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestHandlePromotion(t *testing.T) {
+	index := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`)
+
+	setup := func(t *testing.T) (*manifestRegistry, *models.OCIConfig) {
+		registry := newManifestRegistry()
+		server := httptest.NewServer(registry)
+		t.Cleanup(server.Close)
+		return registry, &models.OCIConfig{Registry: strings.TrimPrefix(server.URL, "http://") + "/agents"}
+	}
+
+	t.Run("promotes a signed index", func(t *testing.T) {
+		registry, ociConfig := setup(t)
+		indexDigest := registry.add("1.2.3-pending", ocispec.MediaTypeImageIndex, index)
+		registry.referrers[indexDigest] = true
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+
+		// method under test
+		promoted, err := HandlePromotion(ctx, ociConfig, "1.2.3", []string{"latest"}, false)
+
+		require.NoError(t, err)
+		assert.Equal(t, indexDigest, promoted)
+		assert.Equal(t, indexDigest, registry.tags["1.2.3"])
+		assert.Equal(t, indexDigest, registry.tags["latest"])
+		assert.Equal(t, indexDigest, registry.tags["1.2.3-pending"], "the pending tag is left in place")
+		assert.Equal(t, &results.Index{
+			Registry: ociConfig.Registry, Tag: "1.2.3", Digest: indexDigest, Signed: true,
+			PromotedFrom: "1.2.3-pending", Tags: []string{"1.2.3", "latest"},
+		}, recorder.Results().Index)
+	})
+
+	t.Run("accepts cosign signature tags", func(t *testing.T) {
+		registry, ociConfig := setup(t)
+		indexDigest := registry.add("1.2.3-pending", ocispec.MediaTypeImageIndex, index)
+		registry.add(strings.Replace(indexDigest, ":", "-", 1)+".sig", ocispec.MediaTypeImageManifest, []byte(`{"schemaVersion":2}`))
+
+		// method under test
+		_, err := HandlePromotion(context.Background(), ociConfig, "1.2.3", nil, false)
+
+		require.NoError(t, err)
+		assert.Equal(t, indexDigest, registry.tags["1.2.3"])
+	})
+
+	t.Run("dry run only checks", func(t *testing.T) {
+		registry, ociConfig := setup(t)
+		indexDigest := registry.add("1.2.3-pending", ocispec.MediaTypeImageIndex, index)
+		registry.referrers[indexDigest] = true
+
+		// method under test
+		promoted, err := HandlePromotion(context.Background(), ociConfig, "1.2.3", nil, true)
+
+		require.NoError(t, err)
+		assert.Equal(t, indexDigest, promoted)
+		assert.NotContains(t, registry.tags, "1.2.3")
+	})
+
+	t.Run("unsigned index", func(t *testing.T) {
+		registry, ociConfig := setup(t)
+		registry.add("1.2.3-pending", ocispec.MediaTypeImageIndex, index)
+
+		// method under test
+		_, err := HandlePromotion(context.Background(), ociConfig, "1.2.3", nil, false)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "isn't signed: only signed indexes are promoted")
+		assert.NotContains(t, registry.tags, "1.2.3")
+	})
+
+	t.Run("not an index", func(t *testing.T) {
+		registry, ociConfig := setup(t)
+		registry.add("1.2.3-pending", ocispec.MediaTypeImageManifest, []byte(`{"schemaVersion":2}`))
+
+		// method under test
+		_, err := HandlePromotion(context.Background(), ociConfig, "1.2.3", nil, false)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "rather than being the manifest index of a release")
+	})
+
+	t.Run("nothing pending", func(t *testing.T) {
+		_, ociConfig := setup(t)
+
+		// method under test
+		_, err := HandlePromotion(context.Background(), ociConfig, "1.2.3", nil, false)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to resolve "+ociConfig.Registry+":1.2.3-pending")
+	})
+}
+
+func TestIndexTag(t *testing.T) {
+	assert.Equal(t, "1.2.3", IndexTag(&models.OCIConfig{}, "1.2.3"))
+	assert.Equal(t, "1.2.3-pending", IndexTag(&models.OCIConfig{PendingTag: true}, "1.2.3"))
+}
@@ -0,0 +1,48 @@
+package oci
+
+import (
+	"context"
+
+	"agent-metadata-action/internal/models"
+)
+
+// ManifestResolver looks up manifests that are already in the registry
+type ManifestResolver interface {
+	ResolveManifest(ctx context.Context, manifestDigest string) (mediaType string, size int64, err error)
+}
+
+// ReferenceArtifacts resolves artifacts that reference already-pushed manifests so they can be added to the
+// manifest index without uploading anything. Artifacts with a path and container images are skipped.
+func ReferenceArtifacts(ctx context.Context, resolver ManifestResolver, config *models.OCIConfig) []models.ArtifactUploadResult {
+	results := make([]models.ArtifactUploadResult, 0)
+
+	for _, artifact := range config.Artifacts {
+		if !artifact.IsReference() || artifact.IsImage() {
+			continue
+		}
+
+		result := models.ArtifactUploadResult{
+			Name:       artifact.Name,
+			Path:       artifact.Digest,
+			OS:         artifact.OS,
+			Arch:       artifact.Arch,
+			Format:     artifact.Format,
+			Referenced: true,
+		}
+
+		mediaType, size, err := resolver.ResolveManifest(ctx, artifact.Digest)
+		if err != nil {
+			result.Error = err.Error()
+			result.ErrorCode = RegistryErrorCode(err)
+		} else {
+			result.Digest = artifact.Digest
+			result.Size = size
+			result.MediaType = mediaType
+			result.Uploaded = true
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
@@ -0,0 +1,84 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifestDigest = "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// mockResolver is a mock implementation of ManifestResolver for testing
+type mockResolver struct {
+	resolveFunc func(ctx context.Context, manifestDigest string) (string, int64, error)
+}
+
+func (m *mockResolver) ResolveManifest(ctx context.Context, manifestDigest string) (string, int64, error) {
+	return m.resolveFunc(ctx, manifestDigest)
+}
+
+func TestReferenceArtifacts(t *testing.T) {
+	config := &models.OCIConfig{
+		Artifacts: []models.ArtifactDefinition{
+			{Name: "local", Path: "./dist/agent.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip"},
+			{Name: "image-amd64", Digest: testManifestDigest, OS: "linux", Arch: "amd64"},
+		},
+	}
+
+	resolver := &mockResolver{resolveFunc: func(ctx context.Context, manifestDigest string) (string, int64, error) {
+		assert.Equal(t, testManifestDigest, manifestDigest)
+		return "application/vnd.oci.image.manifest.v1+json", 512, nil
+	}}
+
+	// method under test
+	results := ReferenceArtifacts(context.Background(), resolver, config)
+
+	require.Len(t, results, 1)
+	assert.Equal(t, "image-amd64", results[0].Name)
+	assert.Equal(t, testManifestDigest, results[0].Digest)
+	assert.Equal(t, "application/vnd.oci.image.manifest.v1+json", results[0].MediaType)
+	assert.Equal(t, int64(512), results[0].Size)
+	assert.True(t, results[0].Referenced)
+	assert.True(t, results[0].Uploaded)
+	assert.False(t, HasFailures(results))
+}
+
+func TestReferenceArtifacts_ResolveError(t *testing.T) {
+	config := &models.OCIConfig{
+		Artifacts: []models.ArtifactDefinition{
+			{Name: "image-amd64", Digest: testManifestDigest, OS: "linux", Arch: "amd64"},
+		},
+	}
+
+	resolver := &mockResolver{resolveFunc: func(ctx context.Context, manifestDigest string) (string, int64, error) {
+		return "", 0, errors.New("not found")
+	}}
+
+	// method under test
+	results := ReferenceArtifacts(context.Background(), resolver, config)
+
+	require.Len(t, results, 1)
+	assert.False(t, results[0].Uploaded)
+	assert.Equal(t, "not found", results[0].Error)
+	assert.True(t, HasFailures(results))
+}
+
+func TestUploadArtifacts_SkipsReferences(t *testing.T) {
+	config := &models.OCIConfig{
+		Artifacts: []models.ArtifactDefinition{
+			{Name: "image-amd64", Digest: testManifestDigest, OS: "linux", Arch: "amd64"},
+		},
+	}
+
+	mock := &mockClient{}
+
+	// method under test
+	results := UploadArtifacts(context.Background(), mock, config, "/workspace", "1.0.0")
+
+	assert.Empty(t, results)
+}
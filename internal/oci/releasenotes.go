@@ -0,0 +1,145 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+)
+
+// Release notes are attached as a referrer of the manifest index, so fleet tooling can show the changelog of a
+// version straight from the registry
+const (
+	ReleaseNotesArtifactType = "application/vnd.newrelic.agent.release-notes.v1"
+	ReleaseNotesMediaType    = "text/markdown"
+)
+
+// LoadReleaseNotes returns the markdown release notes of version from the file at notesPath, relative to
+// workspacePath
+// MDX release notes are returned without their frontmatter; from other files, e.g. CHANGELOG.md, the section whose
+// heading names version is returned
+func LoadReleaseNotes(workspacePath, notesPath, version string) ([]byte, error) {
+	fullPath, err := ResolveArtifactPath(workspacePath, notesPath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read release notes: %w", err)
+	}
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+
+	var notes string
+	if strings.EqualFold(filepath.Ext(fullPath), ".mdx") {
+		notes, err = stripFrontmatter(string(content))
+	} else {
+		notes, err = ExtractChangelogSection(string(content), version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", notesPath, err)
+	}
+	notes = strings.TrimSpace(notes)
+	if notes == "" {
+		return nil, fmt.Errorf("%s: the release notes of %s are empty", notesPath, version)
+	}
+	return []byte(notes + "\n"), nil
+}
+
+// stripFrontmatter returns the body of an MDX file after its --- delimited YAML frontmatter
+func stripFrontmatter(content string) (string, error) {
+	if !strings.HasPrefix(content, "---\n") {
+		return "", fmt.Errorf("MDX file does not start with frontmatter delimiter")
+	}
+	end := strings.Index(content[4:], "\n---")
+	if end == -1 {
+		return "", fmt.Errorf("MDX file missing closing frontmatter delimiter")
+	}
+	body := content[4+end+len("\n---"):]
+	if newline := strings.IndexByte(body, '\n'); newline != -1 {
+		return body[newline+1:], nil
+	}
+	return "", nil
+}
+
+// ExtractChangelogSection returns the body of the changelog section of version: the lines after the first heading
+// naming version, such as "## [1.2.3] - 2024-05-01" or "## v1.2.3", up to the next heading of the same or a higher
+// level
+// Lines in fenced code blocks are never taken for headings
+func ExtractChangelogSection(changelog, version string) (string, error) {
+	versionPattern := regexp.MustCompile(`(^|[^\w.-])v?` + regexp.QuoteMeta(version) + `($|[^\w.-])`)
+
+	var section []string
+	level := 0
+	inFence := false
+	for _, line := range strings.Split(changelog, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if headingLevel, text := markdownHeading(line); headingLevel > 0 && !inFence {
+			if level > 0 && headingLevel <= level {
+				break
+			}
+			if level == 0 && versionPattern.MatchString(text) {
+				level = headingLevel
+				continue
+			}
+		}
+		if level > 0 {
+			section = append(section, line)
+		}
+	}
+	if level == 0 {
+		return "", fmt.Errorf("no heading for version %s", version)
+	}
+	return strings.Join(section, "\n"), nil
+}
+
+// markdownHeading returns the level and text of an ATX heading, or 0 if line isn't one
+func markdownHeading(line string) (int, string) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ') {
+		return 0, ""
+	}
+	return level, strings.TrimSpace(line[level:])
+}
+
+// PushReleaseNotes pushes notes as the layer of a manifest annotated with version and referring to the manifest
+// index indexDigest, and returns the digest of the referrer
+// Registries without the referrers API list it through the sha256-<digest> tag of the index
+func (c *Client) PushReleaseNotes(ctx context.Context, indexDigest, version string, notes []byte) (string, error) {
+	subject, err := c.repo.Resolve(ctx, indexDigest)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s in %s: %w", indexDigest, c.registry, err)
+	}
+
+	layer := ocispec.Descriptor{
+		MediaType:   ReleaseNotesMediaType,
+		Digest:      digest.FromBytes(notes),
+		Size:        int64(len(notes)),
+		Annotations: map[string]string{ocispec.AnnotationTitle: "RELEASE_NOTES.md"},
+	}
+	if err := c.repo.Push(ctx, layer, bytes.NewReader(notes)); err != nil {
+		return "", fmt.Errorf("failed to push release notes: %w", err)
+	}
+
+	packOpts := oras.PackManifestOptions{
+		Subject:             &subject,
+		Layers:              []ocispec.Descriptor{layer},
+		ManifestAnnotations: map[string]string{ocispec.AnnotationVersion: version},
+	}
+	manifestDesc, err := oras.PackManifest(ctx, c.repo, oras.PackManifestVersion1_1, ReleaseNotesArtifactType, packOpts)
+	if err != nil {
+		return "", fmt.Errorf("failed to push release notes referrer: %w", err)
+	}
+	return manifestDesc.Digest.String(), nil
+}
@@ -0,0 +1,150 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testChangelog = `# Changelog
+
+## [1.2.4] - 2024-06-01
+
+### Fixed
+- Newer fix
+
+## [1.2.3] - 2024-05-01
+
+### Added
+- Support for Java 21
+
+` + "```sh" + `
+# not a heading
+java -javaagent:newrelic.jar
+` + "```" + `
+
+## [1.2.30] - 2024-04-01
+
+- Older release
+`
+
+func TestExtractChangelogSection(t *testing.T) {
+	tests := []struct {
+		name      string
+		changelog string
+		version   string
+		expected  string
+		errorMsg  string
+	}{
+		{
+			name:      "keeps subsections and fenced code",
+			changelog: testChangelog,
+			version:   "1.2.3",
+			expected:  "\n### Added\n- Support for Java 21\n\n```sh\n# not a heading\njava -javaagent:newrelic.jar\n```\n",
+		},
+		{
+			name:      "doesn't match longer versions",
+			changelog: testChangelog,
+			version:   "1.2.30",
+			expected:  "\n- Older release\n",
+		},
+		{
+			name:      "v-prefixed heading",
+			changelog: "## v2.0.0\n- Breaking change\n## v1.0.0\n- First release\n",
+			version:   "2.0.0",
+			expected:  "- Breaking change",
+		},
+		{
+			name:      "missing version",
+			changelog: testChangelog,
+			version:   "1.2",
+			errorMsg:  "no heading for version 1.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			section, err := ExtractChangelogSection(tt.changelog, tt.version)
+
+			if tt.errorMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, section)
+		})
+	}
+}
+
+func TestLoadReleaseNotes(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "CHANGELOG.md"), []byte(strings.ReplaceAll(testChangelog, "\n", "\r\n")), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "java-agent-1.2.3.mdx"), []byte("---\nversion: 1.2.3\n---\n\n## Notes\n\n- Support for Java 21\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(workspace, "empty.mdx"), []byte("---\nversion: 1.2.3\n---\n"), 0644))
+
+	t.Run("changelog section", func(t *testing.T) {
+		// method under test
+		notes, err := LoadReleaseNotes(workspace, "./CHANGELOG.md", "1.2.4")
+
+		require.NoError(t, err)
+		assert.Equal(t, "### Fixed\n- Newer fix\n", string(notes))
+	})
+
+	t.Run("mdx without frontmatter", func(t *testing.T) {
+		// method under test
+		notes, err := LoadReleaseNotes(workspace, "java-agent-1.2.3.mdx", "1.2.3")
+
+		require.NoError(t, err)
+		assert.Equal(t, "## Notes\n\n- Support for Java 21\n", string(notes))
+	})
+
+	t.Run("empty notes", func(t *testing.T) {
+		// method under test
+		_, err := LoadReleaseNotes(workspace, "empty.mdx", "1.2.3")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the release notes of 1.2.3 are empty")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		// method under test
+		_, err := LoadReleaseNotes(workspace, "RELEASE_NOTES.md", "1.2.3")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read release notes")
+	})
+}
+
+func TestPushReleaseNotes(t *testing.T) {
+	registry := newManifestRegistry()
+	server := httptest.NewServer(registry)
+	t.Cleanup(server.Close)
+	client, err := NewClient(context.Background(), strings.TrimPrefix(server.URL, "http://")+"/agents", "", "", Connection{})
+	require.NoError(t, err)
+	indexDigest := registry.add("1.2.3", ocispec.MediaTypeImageIndex, []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`))
+
+	// method under test
+	notesDigest, err := client.PushReleaseNotes(context.Background(), indexDigest, "1.2.3", []byte("- Support for Java 21\n"))
+
+	require.NoError(t, err)
+	var referrer ocispec.Manifest
+	require.NoError(t, json.Unmarshal(registry.manifests[notesDigest], &referrer))
+	assert.Equal(t, ReleaseNotesArtifactType, referrer.ArtifactType)
+	assert.Equal(t, "1.2.3", referrer.Annotations[ocispec.AnnotationVersion])
+	require.NotNil(t, referrer.Subject)
+	assert.Equal(t, indexDigest, referrer.Subject.Digest.String())
+	assert.Equal(t, ocispec.MediaTypeImageIndex, referrer.Subject.MediaType)
+	require.Len(t, referrer.Layers, 1)
+	assert.Equal(t, ReleaseNotesMediaType, referrer.Layers[0].MediaType)
+	assert.Equal(t, "- Support for Java 21\n", string(registry.blobs[referrer.Layers[0].Digest.String()]))
+}
@@ -0,0 +1,102 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+)
+
+// ResignTarget is a manifest index already in the registry to sign again, and the platform manifests it lists
+type ResignTarget struct {
+	Index     ocispec.Descriptor
+	Manifests []ocispec.Descriptor
+}
+
+// ResolveIndex returns the manifest index reference (a tag or digest) points to and the manifests it lists
+func (c *Client) ResolveIndex(ctx context.Context, reference string) (ResignTarget, error) {
+	desc, content, err := oras.FetchBytes(ctx, c.repo, reference, oras.DefaultFetchBytesOptions)
+	if err != nil {
+		return ResignTarget{}, fmt.Errorf("failed to resolve %s in %s: %w", reference, c.registry, err)
+	}
+	if desc.MediaType != ocispec.MediaTypeImageIndex {
+		return ResignTarget{}, fmt.Errorf("%s in %s has media type %s rather than being the manifest index of a release", reference, c.registry, desc.MediaType)
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(content, &index); err != nil {
+		return ResignTarget{}, fmt.Errorf("failed to parse index %s from %s: %w", desc.Digest, c.registry, err)
+	}
+	return ResignTarget{Index: desc, Manifests: index.Manifests}, nil
+}
+
+// HandleResign resolves the manifest index reference points to, tagged tag, for signing again without uploading
+// anything, and records it with the manifests it lists
+func HandleResign(ctx context.Context, ociConfig *models.OCIConfig, reference, tag string) (ResignTarget, error) {
+	conn := ConnectionFor(ociConfig)
+	WarnInsecureConnection(ctx, ociConfig.Registry, conn)
+	results.RecordRegistry(ctx, results.Registry{
+		URL:                   ociConfig.Registry,
+		PlainHTTP:             conn.PlainHTTP || IsLocalRegistry(ociConfig.Registry),
+		InsecureSkipTLSVerify: conn.InsecureSkipTLSVerify,
+	})
+
+	client, err := newClientFunc(ctx, ociConfig.Registry, ociConfig.Username, ociConfig.Password, conn)
+	if err != nil {
+		return ResignTarget{}, fmt.Errorf("failed to create OCI client: %w", err)
+	}
+	target, err := client.ResolveIndex(ctx, reference)
+	if err != nil {
+		return ResignTarget{}, err
+	}
+	logging.Noticef(ctx, "Manifest index %s in %s (%s) lists %d manifests", reference, ociConfig.Registry, target.Index.Digest, len(target.Manifests))
+
+	results.RecordIndex(ctx, ociConfig.Registry, tag, target.Index.Digest.String())
+	manifests := make([]models.ArtifactUploadResult, 0, len(target.Manifests))
+	for _, manifest := range target.Manifests {
+		result := models.ArtifactUploadResult{
+			Name:       manifest.Digest.String(),
+			Digest:     manifest.Digest.String(),
+			Size:       manifest.Size,
+			MediaType:  manifest.MediaType,
+			Referenced: true,
+		}
+		if manifest.Platform != nil {
+			result.Name = manifest.Platform.OS + "-" + manifest.Platform.Architecture
+			result.OS = manifest.Platform.OS
+			result.Arch = manifest.Platform.Architecture
+		}
+		manifests = append(manifests, result)
+	}
+	results.RecordArtifacts(ctx, manifests)
+	return target, nil
+}
+
+// Release is the manifest index of a release as found in the registry
+type Release struct {
+	ResignTarget
+	Signed bool
+}
+
+// InspectRelease returns the manifest index tagged tag in the registry, the manifests it lists and whether it is
+// signed, without changing anything
+func InspectRelease(ctx context.Context, ociConfig *models.OCIConfig, tag string) (Release, error) {
+	client, err := newClientFunc(ctx, ociConfig.Registry, ociConfig.Username, ociConfig.Password, ConnectionFor(ociConfig))
+	if err != nil {
+		return Release{}, fmt.Errorf("failed to create OCI client: %w", err)
+	}
+	target, err := client.ResolveIndex(ctx, tag)
+	if err != nil {
+		return Release{}, err
+	}
+	signed, err := client.IsSigned(ctx, target.Index)
+	if err != nil {
+		return Release{}, fmt.Errorf("failed to look up the signature of %s:%s: %w", ociConfig.Registry, tag, err)
+	}
+	return Release{ResignTarget: target, Signed: signed}, nil
+}
@@ -0,0 +1,106 @@
+package oci
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleResign(t *testing.T) {
+	setup := func(t *testing.T) (*manifestRegistry, *models.OCIConfig) {
+		registry := newManifestRegistry()
+		server := httptest.NewServer(registry)
+		t.Cleanup(server.Close)
+		return registry, &models.OCIConfig{Registry: strings.TrimPrefix(server.URL, "http://") + "/agents"}
+	}
+	linux := "sha256:" + strings.Repeat("1", 64)
+	windows := "sha256:" + strings.Repeat("2", 64)
+	index := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[` +
+		`{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"` + linux + `","size":100,"platform":{"os":"linux","architecture":"amd64"}},` +
+		`{"mediaType":"application/vnd.oci.image.manifest.v1+json","digest":"` + windows + `","size":200,"platform":{"os":"windows","architecture":"amd64"}}]}`)
+
+	t.Run("resolves the index and the manifests it lists", func(t *testing.T) {
+		registry, ociConfig := setup(t)
+		indexDigest := registry.add("1.2.3", ocispec.MediaTypeImageIndex, index)
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+
+		// method under test
+		target, err := HandleResign(ctx, ociConfig, "1.2.3", "1.2.3")
+
+		require.NoError(t, err)
+		assert.Equal(t, indexDigest, target.Index.Digest.String())
+		require.Len(t, target.Manifests, 2)
+		assert.Equal(t, linux, target.Manifests[0].Digest.String())
+		assert.Equal(t, windows, target.Manifests[1].Digest.String())
+
+		recorded := recorder.Results()
+		assert.Equal(t, &results.Index{Registry: ociConfig.Registry, Tag: "1.2.3", Digest: indexDigest}, recorded.Index)
+		require.Len(t, recorded.Artifacts, 2)
+		assert.Equal(t, results.Artifact{
+			Name: "linux-amd64", OS: "linux", Arch: "amd64", Digest: linux, Size: 100,
+			MediaType: ocispec.MediaTypeImageManifest, Referenced: true,
+		}, recorded.Artifacts[0])
+	})
+
+	t.Run("resolves an index by digest", func(t *testing.T) {
+		registry, ociConfig := setup(t)
+		indexDigest := registry.add("1.2.3", ocispec.MediaTypeImageIndex, index)
+
+		// method under test
+		target, err := HandleResign(context.Background(), ociConfig, indexDigest, "1.2.3")
+
+		require.NoError(t, err)
+		assert.Equal(t, indexDigest, target.Index.Digest.String())
+	})
+
+	t.Run("rejects a manifest", func(t *testing.T) {
+		registry, ociConfig := setup(t)
+		registry.add("1.2.3", ocispec.MediaTypeImageManifest, []byte(`{"schemaVersion":2}`))
+
+		// method under test
+		_, err := HandleResign(context.Background(), ociConfig, "1.2.3", "1.2.3")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "rather than being the manifest index of a release")
+	})
+
+	t.Run("missing tag", func(t *testing.T) {
+		_, ociConfig := setup(t)
+
+		// method under test
+		_, err := HandleResign(context.Background(), ociConfig, "1.2.3", "1.2.3")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to resolve 1.2.3")
+	})
+}
+
+func TestInspectRelease(t *testing.T) {
+	registry := newManifestRegistry()
+	server := httptest.NewServer(registry)
+	defer server.Close()
+	ociConfig := &models.OCIConfig{Registry: strings.TrimPrefix(server.URL, "http://") + "/agents"}
+	index := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`)
+	indexDigest := registry.add("1.2.3", ocispec.MediaTypeImageIndex, index)
+
+	// method under test
+	release, err := InspectRelease(context.Background(), ociConfig, "1.2.3")
+
+	require.NoError(t, err)
+	assert.Equal(t, indexDigest, release.Index.Digest.String())
+	assert.False(t, release.Signed)
+
+	registry.referrers[indexDigest] = true
+	release, err = InspectRelease(context.Background(), ociConfig, "1.2.3")
+	require.NoError(t, err)
+	assert.True(t, release.Signed)
+}
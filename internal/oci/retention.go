@@ -0,0 +1,198 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+)
+
+// RetentionAnnotation is the manifest annotation holding the retention class of a pushed version
+const RetentionAnnotation = "com.newrelic.retention.class"
+
+// Retention classes: releases are kept, prereleases are deleted by cleanup mode once they expire
+const (
+	RetentionRelease    = "release"
+	RetentionPrerelease = "prerelease"
+)
+
+// prereleasePattern matches versions with a prerelease part after the numeric core, such as 1.2.3-beta.1
+var prereleasePattern = regexp.MustCompile(`^v?\d+(\.\d+)*-`)
+
+// RetentionClass returns the retention class of version
+// Build metadata (1.2.3+build.5) doesn't make a version a prerelease
+func RetentionClass(version string) string {
+	if prereleasePattern.MatchString(version) {
+		return RetentionPrerelease
+	}
+	return RetentionRelease
+}
+
+// ExpiredIndex is a prerelease manifest index older than the retention period
+type ExpiredIndex struct {
+	Digest    string
+	Tags      []string
+	Version   string
+	Created   time.Time
+	Manifests []string // the platform manifests the index lists
+}
+
+// FindExpired returns the prerelease manifest indexes in the repository created before cutoff, oldest first, and
+// the manifests still listed by the indexes that are kept
+// Indexes without a retention class or creation time, pushed before retention was annotated, are always kept
+func (c *Client) FindExpired(ctx context.Context, cutoff time.Time) ([]ExpiredIndex, map[string]bool, error) {
+	tagsByDigest := map[string][]string{}
+	var digests []string
+	err := c.repo.Tags(ctx, "", func(tags []string) error {
+		for _, tag := range tags {
+			desc, err := c.repo.Resolve(ctx, tag)
+			if err != nil {
+				return fmt.Errorf("failed to resolve %s:%s: %w", c.registry, tag, err)
+			}
+			d := desc.Digest.String()
+			if _, seen := tagsByDigest[d]; !seen {
+				digests = append(digests, d)
+			}
+			tagsByDigest[d] = append(tagsByDigest[d], tag)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list the tags of %s: %w", c.registry, err)
+	}
+
+	var expired []ExpiredIndex
+	kept := map[string]bool{}
+	for _, d := range digests {
+		desc, content, err := oras.FetchBytes(ctx, c.repo, d, oras.DefaultFetchBytesOptions)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch %s from %s: %w", d, c.registry, err)
+		}
+		if desc.MediaType != ocispec.MediaTypeImageIndex {
+			kept[d] = true
+			continue
+		}
+		var index ocispec.Index
+		if err := json.Unmarshal(content, &index); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse index %s from %s: %w", d, c.registry, err)
+		}
+		var manifests []string
+		for _, manifest := range index.Manifests {
+			manifests = append(manifests, manifest.Digest.String())
+		}
+
+		created, err := time.Parse(time.RFC3339, index.Annotations[ocispec.AnnotationCreated])
+		if index.Annotations[RetentionAnnotation] != RetentionPrerelease || err != nil || !created.Before(cutoff) {
+			kept[d] = true
+			for _, manifest := range manifests {
+				kept[manifest] = true
+			}
+			continue
+		}
+		expired = append(expired, ExpiredIndex{
+			Digest:    d,
+			Tags:      tagsByDigest[d],
+			Version:   index.Annotations[ocispec.AnnotationVersion],
+			Created:   created,
+			Manifests: manifests,
+		})
+	}
+
+	sort.SliceStable(expired, func(i, j int) bool { return expired[i].Created.Before(expired[j].Created) })
+	return expired, kept, nil
+}
+
+// DeleteManifest deletes the manifest with digest from the repository, and with it every tag pointing to it
+// Blobs are left to the garbage collection of the registry
+func (c *Client) DeleteManifest(ctx context.Context, manifestDigest string) error {
+	d, err := digest.Parse(manifestDigest)
+	if err != nil {
+		return err
+	}
+	desc, err := c.repo.Resolve(ctx, d.String())
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s in %s: %w", manifestDigest, c.registry, err)
+	}
+	if err := c.repo.Delete(ctx, desc); err != nil {
+		return fmt.Errorf("failed to delete %s from %s: %w", manifestDigest, c.registry, err)
+	}
+	return nil
+}
+
+// HandleCleanup deletes the prerelease manifest indexes in the repository older than retention, with the platform
+// manifests no kept index lists
+// The expired indexes are always listed first; dry runs stop there
+func HandleCleanup(ctx context.Context, ociConfig *models.OCIConfig, retention time.Duration, dryRun bool) error {
+	conn := ConnectionFor(ociConfig)
+	WarnInsecureConnection(ctx, ociConfig.Registry, conn)
+	client, err := newClientFunc(ctx, ociConfig.Registry, ociConfig.Username, ociConfig.Password, conn)
+	if err != nil {
+		return fmt.Errorf("failed to create OCI client: %w", err)
+	}
+
+	cutoff := time.Now().Add(-retention)
+	expired, kept, err := client.FindExpired(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+	if len(expired) == 0 {
+		logging.Noticef(ctx, "No prerelease manifests in %s were created before %s", ociConfig.Registry, cutoff.UTC().Format(time.RFC3339))
+		return nil
+	}
+
+	logging.Log(ctx, "group", fmt.Sprintf("Expired prerelease manifests in %s (%d)", ociConfig.Registry, len(expired)))
+	for _, index := range expired {
+		logging.Noticef(ctx, "%s %s created %s, tags: %s", index.Version, index.Digest, index.Created.UTC().Format(time.RFC3339), strings.Join(index.Tags, ", "))
+	}
+	logging.Log(ctx, "endgroup", "")
+
+	if dryRun {
+		for _, index := range expired {
+			results.RecordCleanup(ctx, results.Cleanup{Version: index.Version, Digest: index.Digest, Tags: index.Tags, Created: index.Created})
+		}
+		logging.Noticef(ctx, "Dry run - not deleting %d expired prerelease manifests", len(expired))
+		return nil
+	}
+
+	var failed []string
+	for _, index := range expired {
+		entry := results.Cleanup{Version: index.Version, Digest: index.Digest, Tags: index.Tags, Created: index.Created}
+		err := client.DeleteManifest(ctx, index.Digest)
+		for _, manifest := range index.Manifests {
+			if err != nil {
+				break
+			}
+			if kept[manifest] {
+				continue
+			}
+			// Marked so a manifest listed by several expired indexes is only deleted once
+			kept[manifest] = true
+			err = client.DeleteManifest(ctx, manifest)
+		}
+		if err != nil {
+			entry.Error = err.Error()
+			failed = append(failed, index.Version)
+			logging.Errorf(ctx, "Failed to delete %s (%s): %v", index.Version, index.Digest, err)
+		} else {
+			entry.Deleted = true
+			logging.Noticef(ctx, "Deleted %s (%s)", index.Version, index.Digest)
+		}
+		results.RecordCleanup(ctx, entry)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to delete %d of %d expired prerelease manifests: %s", len(failed), len(expired), strings.Join(failed, ", "))
+	}
+	logging.Noticef(ctx, "Deleted %d expired prerelease manifests from %s", len(expired), ociConfig.Registry)
+	return nil
+}
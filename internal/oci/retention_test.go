@@ -0,0 +1,131 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetentionClass(t *testing.T) {
+	tests := map[string]string{
+		"1.2.3":         RetentionRelease,
+		"v1.2.3":        RetentionRelease,
+		"1.2.3+build.5": RetentionRelease,
+		"1.2.3-beta.1":  RetentionPrerelease,
+		"v2.0.0-rc1":    RetentionPrerelease,
+		"8.0-SNAPSHOT":  RetentionPrerelease,
+	}
+	for version, expected := range tests {
+		assert.Equal(t, expected, RetentionClass(version), version)
+	}
+}
+
+func TestHandleCleanup(t *testing.T) {
+	old := time.Now().Add(-60 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	recent := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	// addRelease pushes a manifest and an index listing it, tagged version
+	addRelease := func(t *testing.T, registry *manifestRegistry, version, created, shared string) (string, string) {
+		manifest := []byte(`{"schemaVersion":2,"annotations":{"org.opencontainers.image.version":"` + version + `"}}`)
+		manifestDigest := registry.add(version+"-linux", ocispec.MediaTypeImageManifest, manifest)
+		delete(registry.tags, version+"-linux")
+		manifests := []ocispec.Descriptor{{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.Digest(manifestDigest), Size: int64(len(manifest))}}
+		if shared != "" {
+			manifests = append(manifests, ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.Digest(shared), Size: 2})
+		}
+		index, err := json.Marshal(ocispec.Index{
+			MediaType: ocispec.MediaTypeImageIndex,
+			Manifests: manifests,
+			Annotations: map[string]string{
+				ocispec.AnnotationVersion: version,
+				ocispec.AnnotationCreated: created,
+				RetentionAnnotation:       RetentionClass(version),
+			},
+		})
+		require.NoError(t, err)
+		return registry.add(version, ocispec.MediaTypeImageIndex, index), manifestDigest
+	}
+
+	setup := func(t *testing.T) (*manifestRegistry, *models.OCIConfig) {
+		registry := newManifestRegistry()
+		server := httptest.NewServer(registry)
+		t.Cleanup(server.Close)
+		return registry, &models.OCIConfig{Registry: strings.TrimPrefix(server.URL, "http://") + "/agents"}
+	}
+
+	t.Run("deletes expired prereleases only", func(t *testing.T) {
+		registry, ociConfig := setup(t)
+		// A manifest shared by an expired prerelease and a release, e.g. a referenced container image
+		shared := registry.add("image", ocispec.MediaTypeImageManifest, []byte(`{}`))
+		expiredIndex, expiredManifest := addRelease(t, registry, "1.2.3-beta.1", old, shared)
+		registry.tags["1.2.3-beta.1-pending"] = expiredIndex
+		releaseIndex, _ := addRelease(t, registry, "1.2.2", old, shared)
+		recentIndex, _ := addRelease(t, registry, "1.2.4-rc1", recent, "")
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := HandleCleanup(ctx, ociConfig, 30*24*time.Hour, false)
+
+		require.NoError(t, err)
+		assert.NotContains(t, registry.manifests, expiredIndex)
+		assert.NotContains(t, registry.manifests, expiredManifest)
+		assert.NotContains(t, registry.tags, "1.2.3-beta.1")
+		assert.NotContains(t, registry.tags, "1.2.3-beta.1-pending")
+		assert.Contains(t, registry.manifests, shared, "manifests listed by kept indexes are kept")
+		assert.Contains(t, registry.manifests, releaseIndex, "releases are kept however old")
+		assert.Contains(t, registry.manifests, recentIndex, "prereleases are kept until they expire")
+
+		cleanup := recorder.Results().Cleanup
+		require.Len(t, cleanup, 1)
+		assert.Equal(t, "1.2.3-beta.1", cleanup[0].Version)
+		assert.ElementsMatch(t, []string{"1.2.3-beta.1", "1.2.3-beta.1-pending"}, cleanup[0].Tags)
+		assert.True(t, cleanup[0].Deleted)
+
+		output := getStdout()
+		assert.Contains(t, output, "1.2.3-beta.1 "+expiredIndex+" created ")
+		assert.Contains(t, output, "Deleted 1 expired prerelease manifests from "+ociConfig.Registry)
+	})
+
+	t.Run("dry run only lists", func(t *testing.T) {
+		registry, ociConfig := setup(t)
+		expiredIndex, _ := addRelease(t, registry, "1.2.3-beta.1", old, "")
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := HandleCleanup(ctx, ociConfig, 30*24*time.Hour, true)
+
+		require.NoError(t, err)
+		assert.Contains(t, registry.manifests, expiredIndex)
+		require.Len(t, recorder.Results().Cleanup, 1)
+		assert.False(t, recorder.Results().Cleanup[0].Deleted)
+		assert.Contains(t, getStdout(), "Dry run - not deleting 1 expired prerelease manifests")
+	})
+
+	t.Run("indexes without retention annotations are kept", func(t *testing.T) {
+		registry, ociConfig := setup(t)
+		index := registry.add("1.0.0-beta.1", ocispec.MediaTypeImageIndex, []byte(`{"schemaVersion":2,"manifests":[],"annotations":{"org.opencontainers.image.version":"1.0.0-beta.1"}}`))
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := HandleCleanup(context.Background(), ociConfig, 30*24*time.Hour, false)
+
+		require.NoError(t, err)
+		assert.Contains(t, registry.manifests, index)
+	})
+}
@@ -0,0 +1,157 @@
+package oci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"agent-metadata-action/internal/journal"
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// DeleteSignatures deletes the signatures of the manifest: its referrers and the manifest under the
+// sha256-<hex>.sig tag cosign uses, returning how many were deleted
+// Any referrer counts as a signature, as for IsSigned
+func (c *Client) DeleteSignatures(ctx context.Context, desc ocispec.Descriptor) (int, error) {
+	var signatures []ocispec.Descriptor
+	err := c.repo.Referrers(ctx, desc, "", func(page []ocispec.Descriptor) error {
+		signatures = append(signatures, page...)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list the referrers of %s in %s: %w", desc.Digest, c.registry, err)
+	}
+	tagged, err := c.repo.Resolve(ctx, strings.Replace(desc.Digest.String(), ":", "-", 1)+cosignSignatureSuffix)
+	if err == nil {
+		signatures = append(signatures, tagged)
+	} else if !errors.Is(err, errdef.ErrNotFound) {
+		return 0, fmt.Errorf("failed to resolve the cosign signature of %s in %s: %w", desc.Digest, c.registry, err)
+	}
+
+	deleted := 0
+	for _, signature := range signatures {
+		err := c.repo.Delete(ctx, signature)
+		if errors.Is(err, errdef.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete signature %s of %s from %s: %w", signature.Digest, desc.Digest, c.registry, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// HandleRollback undoes the registry operations of a failed run, as journaled, latest first: the signatures of its
+// manifest index, the index if its tag still points to it, then the manifests it pushed
+// Operations in registries other than ociConfig's are left alone, and manifests listed by an index that has since
+// taken the tag are kept; every operation is attempted and recorded before failing, and dry runs only record them
+func HandleRollback(ctx context.Context, ociConfig *models.OCIConfig, entries []journal.Entry, dryRun bool) error {
+	conn := ConnectionFor(ociConfig)
+	WarnInsecureConnection(ctx, ociConfig.Registry, conn)
+	client, err := newClientFunc(ctx, ociConfig.Registry, ociConfig.Username, ociConfig.Password, conn)
+	if err != nil {
+		return fmt.Errorf("failed to create OCI client: %w", err)
+	}
+
+	// Manifests listed by the indexes now tagged in place of the rolled back ones
+	kept := map[string]string{}
+	var failed []string
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		rollback := results.Rollback{Operation: entry.Operation, Target: entry.Registry + "@" + entry.Digest}
+		switch {
+		case entry.Registry != ociConfig.Registry:
+			rollback.Detail = fmt.Sprintf("not in oci-registry %s", ociConfig.Registry)
+		case dryRun:
+			rollback.Detail = "dry run"
+		default:
+			rollback.Undone, rollback.Detail, err = client.undo(ctx, entry, kept)
+			if err != nil {
+				rollback.Error = err.Error()
+				failed = append(failed, entry.Operation+" "+entry.Digest)
+			}
+		}
+
+		switch {
+		case rollback.Error != "":
+			logging.Errorf(ctx, "Failed to undo %s %s: %s", entry.Operation, rollback.Target, rollback.Error)
+		case rollback.Undone:
+			logging.Noticef(ctx, "Undid %s %s: %s", entry.Operation, rollback.Target, rollback.Detail)
+		default:
+			logging.Noticef(ctx, "Not undoing %s %s: %s", entry.Operation, rollback.Target, rollback.Detail)
+		}
+		results.RecordRollback(ctx, rollback)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to undo %d of %d operations in %s: %s", len(failed), len(entries), ociConfig.Registry, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// undo undoes the journaled registry operation, returning whether there was anything to undo and what was done
+func (c *Client) undo(ctx context.Context, entry journal.Entry, kept map[string]string) (bool, string, error) {
+	d, err := digest.Parse(entry.Digest)
+	if err != nil {
+		return false, "", fmt.Errorf("invalid digest %q: %w", entry.Digest, err)
+	}
+
+	switch entry.Operation {
+	case journal.OperationSignature:
+		desc, err := c.repo.Resolve(ctx, d.String())
+		if errors.Is(err, errdef.ErrNotFound) {
+			return false, "the signed index is gone", nil
+		}
+		if err != nil {
+			return false, "", fmt.Errorf("failed to resolve %s in %s: %w", d, c.registry, err)
+		}
+		deleted, err := c.DeleteSignatures(ctx, desc)
+		if err != nil {
+			return deleted > 0, "", err
+		}
+		if deleted == 0 {
+			return false, "no signature found", nil
+		}
+		return true, fmt.Sprintf("deleted %d signatures", deleted), nil
+
+	case journal.OperationIndex:
+		tagged, err := c.ResolveIndex(ctx, entry.Tag)
+		if errors.Is(err, errdef.ErrNotFound) {
+			return false, fmt.Sprintf("the tag %s is gone", entry.Tag), nil
+		}
+		if err != nil {
+			return false, "", err
+		}
+		if tagged.Index.Digest != d {
+			for _, manifest := range tagged.Manifests {
+				kept[manifest.Digest.String()] = entry.Tag
+			}
+			return false, fmt.Sprintf("the tag %s now points to %s", entry.Tag, tagged.Index.Digest), nil
+		}
+		if err := c.DeleteManifest(ctx, entry.Digest); err != nil {
+			return false, "", err
+		}
+		return true, fmt.Sprintf("deleted with the tag %s", entry.Tag), nil
+
+	case journal.OperationManifest:
+		if tag, ok := kept[entry.Digest]; ok {
+			return false, fmt.Sprintf("listed by the index tagged %s", tag), nil
+		}
+		err := c.DeleteManifest(ctx, entry.Digest)
+		if errors.Is(err, errdef.ErrNotFound) {
+			return false, "already gone", nil
+		}
+		if err != nil {
+			return false, "", err
+		}
+		return true, "deleted " + entry.Name, nil
+	}
+	return false, "", fmt.Errorf("can't undo %s operations in a registry", entry.Operation)
+}
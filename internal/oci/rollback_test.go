@@ -0,0 +1,144 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"agent-metadata-action/internal/journal"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// importJournaled imports the build layout of writeBuildLayout to registry, signs the index with a referrer and
+// returns the journal of the run
+func importJournaled(t *testing.T, registry *memoryRegistry, ociConfig *models.OCIConfig) []journal.Entry {
+	t.Helper()
+	workspace := t.TempDir()
+	writeBuildLayout(t, workspace)
+	path := filepath.Join(workspace, "journal.jsonl")
+	j, err := journal.Create(path)
+	require.NoError(t, err)
+	ctx := journal.WithJournal(context.Background(), j)
+
+	indexDigest, err := HandleImport(ctx, ociConfig, workspace, "layout", "1.2.3", false)
+	require.NoError(t, err)
+
+	signature, err := json.Marshal(ocispec.Manifest{
+		Versioned:    ocispec.Index{}.Versioned,
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.dev.cosign.artifact.sig.v1+json",
+		Config:       ocispec.DescriptorEmptyJSON,
+		Layers:       []ocispec.Descriptor{ocispec.DescriptorEmptyJSON},
+		Subject:      &ocispec.Descriptor{MediaType: ocispec.MediaTypeImageIndex, Digest: digest.Digest(indexDigest)},
+	})
+	require.NoError(t, err)
+	signatureDesc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.FromBytes(signature), Size: int64(len(signature))}
+	require.NoError(t, registry.Push(ctx, signatureDesc, bytes.NewReader(signature)))
+	journal.Record(ctx, journal.Entry{Operation: journal.OperationSignature, Registry: ociConfig.Registry, Tag: "1.2.3", Digest: indexDigest})
+
+	entries, err := journal.Read(path)
+	require.NoError(t, err)
+	return entries
+}
+
+func TestHandleRollback(t *testing.T) {
+	testutil.CaptureOutput(t)
+
+	t.Run("undoes the signature, index and manifests of the run", func(t *testing.T) {
+		registries := useMemoryRegistries(t)
+		ociConfig := &models.OCIConfig{Registry: "ghcr.io/newrelic/agents"}
+		registry := newMemoryRegistry()
+		registries[ociConfig.Registry] = registry
+		entries := importJournaled(t, registry, ociConfig)
+		require.Len(t, entries, 3)
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+
+		// method under test
+		err := HandleRollback(ctx, ociConfig, entries, false)
+
+		require.NoError(t, err)
+		for _, entry := range entries {
+			_, err := registry.Resolve(context.Background(), entry.Digest)
+			assert.ErrorIs(t, err, errdef.ErrNotFound, entry.Operation)
+		}
+		assert.Empty(t, registry.tags)
+		for _, signature := range registry.referrers[digest.Digest(entries[1].Digest)] {
+			assert.NotContains(t, registry.descs, signature.Digest, "The signature is deleted")
+		}
+		recorded := recorder.Results().Rollback
+		require.Len(t, recorded, 3)
+		assert.Equal(t, []string{journal.OperationSignature, journal.OperationIndex, journal.OperationManifest},
+			[]string{recorded[0].Operation, recorded[1].Operation, recorded[2].Operation}, "Undone latest first")
+		for _, rollback := range recorded {
+			assert.True(t, rollback.Undone, rollback.Operation)
+			assert.Empty(t, rollback.Error)
+		}
+	})
+
+	t.Run("keeps an index that has since taken the tag", func(t *testing.T) {
+		registries := useMemoryRegistries(t)
+		ociConfig := &models.OCIConfig{Registry: "ghcr.io/newrelic/agents"}
+		registry := newMemoryRegistry()
+		registries[ociConfig.Registry] = registry
+		entries := importJournaled(t, registry, ociConfig)
+		// A later run tags an index listing the same manifest
+		rerun := t.TempDir()
+		writeBuildLayout(t, rerun)
+		_, err := HandleImport(context.Background(), &models.OCIConfig{Registry: ociConfig.Registry, Annotations: map[string]string{"com.newrelic.run": "2"}}, rerun, "layout", "1.2.3", false)
+		require.NoError(t, err)
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+
+		// method under test
+		err = HandleRollback(ctx, ociConfig, entries, false)
+
+		require.NoError(t, err)
+		index := registry.index(t, "1.2.3")
+		assert.Equal(t, "2", index.Annotations["com.newrelic.run"], "The later index keeps the tag")
+		_, err = registry.Resolve(context.Background(), index.Manifests[0].Digest.String())
+		assert.NoError(t, err, "The manifest the later index lists is kept")
+		recorded := recorder.Results().Rollback
+		require.Len(t, recorded, 3)
+		assert.True(t, recorded[0].Undone, "The signature of the rolled back index is deleted")
+		assert.False(t, recorded[1].Undone)
+		assert.Contains(t, recorded[1].Detail, "now points to")
+		assert.False(t, recorded[2].Undone)
+		assert.Equal(t, "listed by the index tagged 1.2.3", recorded[2].Detail)
+	})
+
+	t.Run("dry run and other registries change nothing", func(t *testing.T) {
+		registries := useMemoryRegistries(t)
+		ociConfig := &models.OCIConfig{Registry: "ghcr.io/newrelic/agents"}
+		registry := newMemoryRegistry()
+		registries[ociConfig.Registry] = registry
+		entries := importJournaled(t, registry, ociConfig)
+		entries = append(entries, journal.Entry{Operation: journal.OperationIndex, Registry: "docker.io/newrelic/agents", Tag: "1.2.3", Digest: entries[1].Digest})
+		manifests := len(registry.descs)
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+
+		// method under test
+		err := HandleRollback(ctx, ociConfig, entries, true)
+
+		require.NoError(t, err)
+		assert.Len(t, registry.descs, manifests)
+		recorded := recorder.Results().Rollback
+		require.Len(t, recorded, 4)
+		assert.Equal(t, "not in oci-registry ghcr.io/newrelic/agents", recorded[0].Detail)
+		for _, rollback := range recorded[1:] {
+			assert.False(t, rollback.Undone)
+			assert.Equal(t, "dry run", rollback.Detail)
+		}
+	})
+}
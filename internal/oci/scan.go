@@ -0,0 +1,62 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"agent-metadata-action/internal/github"
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+	"agent-metadata-action/internal/scan"
+)
+
+// ScanArtifacts runs every scanner on every artifact to upload, found relative to workspacePath, recording each verdict
+// Fails if any scanner detects something or can't reach a verdict, so nothing unscanned is pushed; every artifact is
+// still scanned so all detections are reported together
+// Referenced artifacts are already in the registry and aren't scanned
+func ScanArtifacts(ctx context.Context, scanners []scan.Scanner, workspacePath string, artifacts []models.ArtifactDefinition) error {
+	if len(scanners) == 0 {
+		return nil
+	}
+
+	logging.Log(ctx, "group", "Scanning binaries")
+	defer logging.Log(ctx, "endgroup", "")
+
+	var blocked []string
+	for _, artifact := range artifacts {
+		if artifact.IsReference() {
+			continue
+		}
+		path, err := ResolveArtifactPath(workspacePath, artifact.Path)
+		if err != nil {
+			return err
+		}
+		for _, scanner := range scanners {
+			entry := results.Scan{Artifact: artifact.Name, Scanner: scanner.Name()}
+			result, err := scanner.Scan(ctx, path)
+			switch {
+			case err != nil:
+				entry.Error = err.Error()
+				blocked = append(blocked, fmt.Sprintf("%s (%s failed: %v)", artifact.Name, scanner.Name(), err))
+				logging.Errorf(ctx, "Scanning %s with %s failed: %v", artifact.Name, scanner.Name(), err)
+			case result.Verdict == scan.VerdictDetected:
+				entry.Verdict, entry.Detail = result.Verdict, result.Detail
+				blocked = append(blocked, fmt.Sprintf("%s (detected by %s)", artifact.Name, scanner.Name()))
+				message := fmt.Sprintf("%s detected malware in %s: %s", scanner.Name(), artifact.Name, result.Detail)
+				logging.Error(ctx, message)
+				github.AddWorkflowAnnotation(ctx, github.AnnotationFailure, "Malware detected", message)
+			default:
+				entry.Verdict = result.Verdict
+				logging.Noticef(ctx, "%s: %s by %s", artifact.Name, result.Verdict, scanner.Name())
+			}
+			results.RecordScan(ctx, entry)
+		}
+	}
+
+	if len(blocked) > 0 {
+		return fmt.Errorf("upload blocked by the scan of %s", strings.Join(blocked, ", "))
+	}
+	return nil
+}
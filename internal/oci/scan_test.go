@@ -0,0 +1,90 @@
+package oci
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/results"
+	"agent-metadata-action/internal/scan"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeScanner returns the configured verdict or error for each artifact path
+type fakeScanner struct {
+	verdicts map[string]scan.Result
+	errs     map[string]error
+	scanned  []string
+}
+
+func (f *fakeScanner) Name() string { return "fake-scanner" }
+
+func (f *fakeScanner) Scan(ctx context.Context, path string) (scan.Result, error) {
+	f.scanned = append(f.scanned, path)
+	if err := f.errs[path]; err != nil {
+		return scan.Result{}, err
+	}
+	if result, ok := f.verdicts[path]; ok {
+		return result, nil
+	}
+	return scan.Result{Verdict: scan.VerdictClean}, nil
+}
+
+func TestScanArtifacts(t *testing.T) {
+	workspace := t.TempDir()
+	artifacts := []models.ArtifactDefinition{
+		{Name: "linux", Path: "./dist/linux.tar.gz"},
+		{Name: "windows", Path: "./dist/windows.zip"},
+		{Name: "image", Digest: "sha256:" + fmt.Sprintf("%064d", 1)},
+	}
+	linuxPath := filepath.Join(workspace, "dist", "linux.tar.gz")
+	windowsPath := filepath.Join(workspace, "dist", "windows.zip")
+
+	t.Run("clean artifacts are uploaded", func(t *testing.T) {
+		scanner := &fakeScanner{}
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+		testutil.CaptureOutput(t)
+
+		// method under test
+		err := ScanArtifacts(ctx, []scan.Scanner{scanner}, workspace, artifacts)
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{linuxPath, windowsPath}, scanner.scanned, "referenced artifacts aren't scanned")
+		assert.Equal(t, []results.Scan{
+			{Artifact: "linux", Scanner: "fake-scanner", Verdict: "clean"},
+			{Artifact: "windows", Scanner: "fake-scanner", Verdict: "clean"},
+		}, recorder.Results().Scans)
+	})
+
+	t.Run("detections and scanner failures block the upload", func(t *testing.T) {
+		scanner := &fakeScanner{
+			verdicts: map[string]scan.Result{linuxPath: {Verdict: scan.VerdictDetected, Detail: "Eicar-Signature FOUND"}},
+			errs:     map[string]error{windowsPath: fmt.Errorf("database out of date")},
+		}
+		recorder := results.NewRecorder()
+		ctx := results.WithRecorder(context.Background(), recorder)
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := ScanArtifacts(ctx, []scan.Scanner{scanner}, workspace, artifacts)
+
+		require.Error(t, err)
+		assert.Equal(t, "upload blocked by the scan of linux (detected by fake-scanner), windows (fake-scanner failed: database out of date)", err.Error())
+		assert.Equal(t, []results.Scan{
+			{Artifact: "linux", Scanner: "fake-scanner", Verdict: "detected", Detail: "Eicar-Signature FOUND"},
+			{Artifact: "windows", Scanner: "fake-scanner", Error: "database out of date"},
+		}, recorder.Results().Scans)
+		assert.Contains(t, getStdout(), "fake-scanner detected malware in linux: Eicar-Signature FOUND")
+	})
+
+	t.Run("no scanners", func(t *testing.T) {
+		// method under test
+		assert.NoError(t, ScanArtifacts(context.Background(), nil, workspace, artifacts))
+	})
+}
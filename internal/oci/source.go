@@ -0,0 +1,160 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/retry"
+)
+
+// downloadHTTPClient is used for https:// artifacts; the timeout allows for large agent bundles
+var downloadHTTPClient = &http.Client{Timeout: 10 * time.Minute}
+
+// downloadObjectFunc is a variable that holds the function to download a remote object to a local file
+// This allows tests to override the implementation
+var downloadObjectFunc = downloadObject
+
+// StageRemoteArtifacts downloads artifacts stored in object storage or at a URL into stagingDir and returns a copy of
+// the artifacts with Path pointing at the downloaded files. Local artifacts are returned unchanged.
+func StageRemoteArtifacts(ctx context.Context, artifacts []models.ArtifactDefinition, stagingDir string) ([]models.ArtifactDefinition, error) {
+	staged := make([]models.ArtifactDefinition, len(artifacts))
+	copy(staged, artifacts)
+
+	for i, artifact := range artifacts {
+		source := artifact.RemoteSource()
+		if source == "" {
+			continue
+		}
+
+		dest := filepath.Join(stagingDir, artifact.Name, artifact.GetFilename())
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create staging directory for artifact '%s': %w", artifact.Name, err)
+		}
+
+		logging.Noticef(ctx, "Downloading %s for artifact '%s'...", artifact.Path, artifact.Name)
+		if err := downloadObjectFunc(ctx, source, artifact.Path, dest); err != nil {
+			return nil, fmt.Errorf("failed to download artifact '%s' from %s: %w", artifact.Name, artifact.Path, err)
+		}
+
+		if err := verifySHA256(ctx, &artifact, dest); err != nil {
+			return nil, err
+		}
+
+		staged[i].Path = dest
+	}
+
+	return staged, nil
+}
+
+// downloadObject copies an object to dest with the storage provider's CLI, which is preinstalled on
+// GitHub-hosted runners, picks up credentials configured by the provider's auth actions, and verifies
+// the transfer against the object's stored checksum
+// https:// artifacts are streamed directly to dest and must have a pinned sha256
+func downloadObject(ctx context.Context, source, url, dest string) error {
+	var cmd *exec.Cmd
+	switch source {
+	case models.SourceHTTPS:
+		return downloadURL(ctx, url, dest)
+	case models.SourceS3:
+		cmd = exec.CommandContext(ctx, "aws", "s3", "cp", "--only-show-errors", url, dest)
+	case models.SourceGCS:
+		cmd = exec.CommandContext(ctx, "gcloud", "storage", "cp", url, dest)
+	default:
+		return fmt.Errorf("unsupported artifact source %q", source)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", cmd.Args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// downloadURL streams an https:// artifact to dest, retrying server errors and rate limiting
+func downloadURL(ctx context.Context, url, dest string) error {
+	retryConfig := retry.Config{
+		MaxAttempts: 3,
+		BaseDelay:   2 * time.Second,
+		Operation:   "Artifact download",
+	}
+
+	return retry.Do(ctx, retryConfig, func() error {
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
+		}
+
+		resp, err := downloadHTTPClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("HTTP request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			err := fmt.Errorf("download failed with status %d", resp.StatusCode)
+			// Retry on: 5xx (server errors), 408 (timeout), 429 (rate limit)
+			isRetryable := resp.StatusCode >= 500 || resp.StatusCode == 408 || resp.StatusCode == 429
+			if !isRetryable {
+				return retry.NewNonRetryableError(err)
+			}
+			return err
+		}
+
+		f, err := os.Create(dest)
+		if err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("failed to create %s: %w", dest, err))
+		}
+		defer f.Close()
+
+		if _, err := io.Copy(f, resp.Body); err != nil {
+			return fmt.Errorf("failed to write download: %w", err)
+		}
+		return nil
+	})
+}
+
+// verifySHA256 checks a downloaded artifact against its sha256 field, if set
+func verifySHA256(ctx context.Context, artifact *models.ArtifactDefinition, path string) error {
+	digest, err := fileSHA256(path)
+	if err != nil {
+		return fmt.Errorf("failed to checksum artifact '%s': %w", artifact.Name, err)
+	}
+
+	if artifact.SHA256 == "" {
+		logging.Debugf(ctx, "Artifact '%s' downloaded with sha256 %s (no sha256 pinned)", artifact.Name, digest)
+		return nil
+	}
+	if !strings.EqualFold(digest, artifact.SHA256) {
+		return fmt.Errorf("checksum mismatch for artifact '%s': expected sha256 %s, got %s", artifact.Name, artifact.SHA256, digest)
+	}
+
+	logging.Debugf(ctx, "Verified sha256 of artifact '%s'", artifact.Name)
+	return nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,107 @@
+package oci
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sha256 of "agent-binary"
+const agentBinarySHA256 = "f03e279954a05b1fd253a5be7299019af3ebdf44c57e0c69eecc738601ca6d35"
+
+func mockDownload(t *testing.T, content string) *[]string {
+	t.Helper()
+	var downloaded []string
+	original := downloadObjectFunc
+	downloadObjectFunc = func(ctx context.Context, source, url, dest string) error {
+		downloaded = append(downloaded, source+" "+url)
+		return os.WriteFile(dest, []byte(content), 0644)
+	}
+	t.Cleanup(func() { downloadObjectFunc = original })
+	return &downloaded
+}
+
+func TestStageRemoteArtifacts(t *testing.T) {
+	downloaded := mockDownload(t, "agent-binary")
+
+	artifacts := []models.ArtifactDefinition{
+		{Name: "local", Path: "./dist/agent.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip"},
+		{Name: "from-s3", Path: "s3://bucket/builds/agent.tar.gz", OS: "linux", Arch: "arm64", Format: "tar+gzip", SHA256: agentBinarySHA256},
+		{Name: "from-gcs", Path: "gs://bucket/agent.zip", OS: "windows", Arch: "amd64", Format: "zip"},
+	}
+	stagingDir := t.TempDir()
+
+	// method under test
+	staged, err := StageRemoteArtifacts(context.Background(), artifacts, stagingDir)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"s3 s3://bucket/builds/agent.tar.gz", "gs gs://bucket/agent.zip"}, *downloaded)
+	assert.Equal(t, "./dist/agent.tar.gz", staged[0].Path)
+	assert.Equal(t, filepath.Join(stagingDir, "from-s3", "agent.tar.gz"), staged[1].Path)
+	assert.Equal(t, filepath.Join(stagingDir, "from-gcs", "agent.zip"), staged[2].Path)
+	assert.FileExists(t, staged[1].Path)
+
+	// The configured artifacts are left untouched
+	assert.Equal(t, "s3://bucket/builds/agent.tar.gz", artifacts[1].Path)
+}
+
+func TestStageRemoteArtifacts_ChecksumMismatch(t *testing.T) {
+	mockDownload(t, "tampered-binary")
+
+	artifacts := []models.ArtifactDefinition{
+		{Name: "from-s3", Path: "s3://bucket/agent.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip", SHA256: agentBinarySHA256},
+	}
+
+	// method under test
+	_, err := StageRemoteArtifacts(context.Background(), artifacts, t.TempDir())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum mismatch for artifact 'from-s3'")
+}
+
+func TestStageRemoteArtifacts_HTTPS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/agent/agent.tar.gz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("agent-binary"))
+	}))
+	defer server.Close()
+
+	originalClient := downloadHTTPClient
+	downloadHTTPClient = server.Client()
+	defer func() { downloadHTTPClient = originalClient }()
+
+	t.Run("downloads and verifies", func(t *testing.T) {
+		artifacts := []models.ArtifactDefinition{
+			{Name: "from-url", Path: server.URL + "/agent/agent.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip", SHA256: agentBinarySHA256},
+		}
+
+		// method under test
+		staged, err := StageRemoteArtifacts(context.Background(), artifacts, t.TempDir())
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(staged[0].Path)
+		require.NoError(t, err)
+		assert.Equal(t, "agent-binary", string(data))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		artifacts := []models.ArtifactDefinition{
+			{Name: "from-url", Path: server.URL + "/missing.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip", SHA256: agentBinarySHA256},
+		}
+
+		// method under test
+		_, err := StageRemoteArtifacts(context.Background(), artifacts, t.TempDir())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "download failed with status 404")
+	})
+}
@@ -0,0 +1,78 @@
+package oci
+
+import (
+	"fmt"
+	"strconv"
+
+	"agent-metadata-action/internal/models"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Layer annotations of an artifact split into parts, for consumers to reassemble it
+// Parts are listed in order and titled <filename>.001, <filename>.002..., so concatenating the pulled files in name
+// order restores the artifact, which can then be checked against PartDigestAnnotation
+const (
+	PartIndexAnnotation  = "com.newrelic.artifact.part.index" // 1-based position of the part
+	PartCountAnnotation  = "com.newrelic.artifact.part.count" // number of parts
+	PartDigestAnnotation = "com.newrelic.artifact.digest"     // digest of the reassembled artifact
+)
+
+// partTitle returns the file name of part index of count, zero-padded so the parts sort in order
+func partTitle(filename string, index, count int) string {
+	width := max(3, len(strconv.Itoa(count)))
+	return fmt.Sprintf("%s.%0*d", filename, width, index)
+}
+
+// addArtifactParts adds the ordered parts of the artifact to store, each a layer annotated for reassembly; the parts
+// are pushed from the artifact file, so splitting writes nothing to disk
+func addArtifactParts(store *sourceStore, artifact *models.ArtifactDefinition, parts []fileSection, artifactDigest digest.Digest, version string) []ocispec.Descriptor {
+	layers := make([]ocispec.Descriptor, 0, len(parts))
+	for i, part := range parts {
+		title := partTitle(artifact.GetFilename(), i+1, len(parts))
+		layer := store.addSection(part, artifact.GetMediaType())
+		layer.Annotations = CreateLayerAnnotations(artifact, version)
+		layer.Annotations[ocispec.AnnotationTitle] = title
+		layer.Annotations[PartIndexAnnotation] = strconv.Itoa(i + 1)
+		layer.Annotations[PartCountAnnotation] = strconv.Itoa(len(parts))
+		layer.Annotations[PartDigestAnnotation] = artifactDigest.String()
+		layers = append(layers, layer)
+	}
+	return layers
+}
+
+// ValidatePartAnnotations checks the layers of a split artifact carry the reassembly annotations consumers rely on:
+// every layer is numbered in order out of the same count, names the same artifact digest, and has a title that
+// sorts in layer order
+func ValidatePartAnnotations(layers []ocispec.Descriptor) error {
+	if len(layers) == 0 {
+		return fmt.Errorf("split artifact has no layers")
+	}
+	artifactDigest := layers[0].Annotations[PartDigestAnnotation]
+	if _, err := digest.Parse(artifactDigest); err != nil {
+		return fmt.Errorf("layer 1 has invalid %s %q: %w", PartDigestAnnotation, artifactDigest, err)
+	}
+
+	var previousTitle string
+	for i, layer := range layers {
+		if got, want := layer.Annotations[PartIndexAnnotation], strconv.Itoa(i+1); got != want {
+			return fmt.Errorf("layer %d has %s %q, want %q", i+1, PartIndexAnnotation, got, want)
+		}
+		if got, want := layer.Annotations[PartCountAnnotation], strconv.Itoa(len(layers)); got != want {
+			return fmt.Errorf("layer %d has %s %q, want %q", i+1, PartCountAnnotation, got, want)
+		}
+		if got := layer.Annotations[PartDigestAnnotation]; got != artifactDigest {
+			return fmt.Errorf("layer %d has %s %q, want %q", i+1, PartDigestAnnotation, got, artifactDigest)
+		}
+		title := layer.Annotations[ocispec.AnnotationTitle]
+		if title == "" {
+			return fmt.Errorf("layer %d has no %s", i+1, ocispec.AnnotationTitle)
+		}
+		if i > 0 && title <= previousTitle {
+			return fmt.Errorf("layer %d has %s %q, which doesn't sort after %q", i+1, ocispec.AnnotationTitle, title, previousTitle)
+		}
+		previousTitle = title
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestSections(t *testing.T) {
+	content := bytes.Repeat([]byte("agent"), 5)
+	path := filepath.Join(t.TempDir(), "agent.tar.gz")
+	require.NoError(t, os.WriteFile(path, content, 0o644))
+
+	t.Run("sections of at most the limit", func(t *testing.T) {
+		// method under test
+		sections, artifactDigest, err := digestSections(path, 10)
+
+		require.NoError(t, err)
+		require.Len(t, sections, 3)
+		var offset int64
+		for _, section := range sections {
+			assert.Equal(t, offset, section.offset)
+			assert.Equal(t, digest.FromBytes(content[section.offset:section.offset+section.size]), section.digest)
+			offset += section.size
+		}
+		assert.Equal(t, int64(10), sections[0].size)
+		assert.Equal(t, int64(5), sections[2].size)
+		assert.Equal(t, digest.FromBytes(content), artifactDigest)
+	})
+
+	t.Run("an exact multiple has no empty last section", func(t *testing.T) {
+		// method under test
+		sections, _, err := digestSections(path, 5)
+
+		require.NoError(t, err)
+		assert.Len(t, sections, 5)
+	})
+
+	t.Run("the whole file without a limit", func(t *testing.T) {
+		// method under test
+		sections, artifactDigest, err := digestSections(path, 0)
+
+		require.NoError(t, err)
+		require.Len(t, sections, 1)
+		assert.Equal(t, int64(len(content)), sections[0].size)
+		assert.Equal(t, artifactDigest, sections[0].digest)
+	})
+}
+
+func TestPartTitle(t *testing.T) {
+	assert.Equal(t, "agent.tar.gz.001", partTitle("agent.tar.gz", 1, 3))
+	assert.Equal(t, "agent.tar.gz.0042", partTitle("agent.tar.gz", 42, 1200))
+}
+
+func TestValidatePartAnnotations(t *testing.T) {
+	artifactDigest := digest.FromString("agent").String()
+	layer := func(title, index, count, artifactDigest string) ocispec.Descriptor {
+		return ocispec.Descriptor{Annotations: map[string]string{
+			ocispec.AnnotationTitle: title,
+			PartIndexAnnotation:     index,
+			PartCountAnnotation:     count,
+			PartDigestAnnotation:    artifactDigest,
+		}}
+	}
+
+	tests := []struct {
+		name     string
+		layers   []ocispec.Descriptor
+		errorMsg string
+	}{
+		{
+			name:   "consistent",
+			layers: []ocispec.Descriptor{layer("a.001", "1", "2", artifactDigest), layer("a.002", "2", "2", artifactDigest)},
+		},
+		{
+			name:     "no layers",
+			errorMsg: "split artifact has no layers",
+		},
+		{
+			name:     "out of order",
+			layers:   []ocispec.Descriptor{layer("a.002", "2", "2", artifactDigest), layer("a.001", "1", "2", artifactDigest)},
+			errorMsg: `layer 1 has com.newrelic.artifact.part.index "2", want "1"`,
+		},
+		{
+			name:     "wrong count",
+			layers:   []ocispec.Descriptor{layer("a.001", "1", "3", artifactDigest), layer("a.002", "2", "3", artifactDigest)},
+			errorMsg: `layer 1 has com.newrelic.artifact.part.count "3", want "2"`,
+		},
+		{
+			name:     "different artifact digests",
+			layers:   []ocispec.Descriptor{layer("a.001", "1", "2", artifactDigest), layer("a.002", "2", "2", digest.FromString("other").String())},
+			errorMsg: "layer 2 has com.newrelic.artifact.digest",
+		},
+		{
+			name:     "invalid artifact digest",
+			layers:   []ocispec.Descriptor{layer("a.001", "1", "1", "agent")},
+			errorMsg: `layer 1 has invalid com.newrelic.artifact.digest "agent"`,
+		},
+		{
+			name:     "titles that don't sort in order",
+			layers:   []ocispec.Descriptor{layer("a.9", "1", "2", artifactDigest), layer("a.10", "2", "2", artifactDigest)},
+			errorMsg: `layer 2 has org.opencontainers.image.title "a.10", which doesn't sort after "a.9"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			err := ValidatePartAnnotations(tt.layers)
+
+			if tt.errorMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+			}
+		})
+	}
+}
+
+func TestUploadArtifact_Split(t *testing.T) {
+	registry := newManifestRegistry()
+	server := httptest.NewServer(registry)
+	defer server.Close()
+	testutil.CaptureOutput(t)
+
+	client, err := NewClient(context.Background(), strings.TrimPrefix(server.URL, "http://")+"/agents", "", "", Connection{})
+	require.NoError(t, err)
+	client.maxBlobSize = models.MinBlobSizeLimit
+
+	content := bytes.Repeat([]byte{0x1f, 0x8b, 0x08, 0x00}, models.MinBlobSizeLimit/2)
+	path := filepath.Join(t.TempDir(), "agent.tar.gz")
+	require.NoError(t, os.WriteFile(path, content, 0o644))
+	artifact := &models.ArtifactDefinition{Name: "linux", Path: "./agent.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip"}
+
+	// method under test
+	manifestDigest, _, err := client.UploadArtifact(context.Background(), artifact, path, "1.2.3")
+
+	require.NoError(t, err)
+	var manifest ocispec.Manifest
+	require.NoError(t, json.Unmarshal(registry.manifests[manifestDigest], &manifest))
+	require.Len(t, manifest.Layers, 2)
+	require.NoError(t, ValidatePartAnnotations(manifest.Layers))
+	assert.Equal(t, "agent.tar.gz.001", manifest.Layers[0].Annotations[ocispec.AnnotationTitle])
+	assert.Equal(t, digest.FromBytes(content).String(), manifest.Layers[0].Annotations[PartDigestAnnotation])
+	assert.Equal(t, "1.2.3", manifest.Layers[1].Annotations["org.opencontainers.image.version"])
+
+	var reassembled []byte
+	for _, layer := range manifest.Layers {
+		assert.Equal(t, "application/vnd.newrelic.agent.content.v1.tar+gzip", layer.MediaType)
+		assert.LessOrEqual(t, layer.Size, int64(models.MinBlobSizeLimit))
+		reassembled = append(reassembled, registry.blobs[layer.Digest.String()]...)
+	}
+	assert.Equal(t, content, reassembled)
+}
@@ -0,0 +1,126 @@
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content/memory"
+)
+
+// fileSection is a run of bytes of a file on disk, pushed as a blob straight from the file
+type fileSection struct {
+	path   string
+	offset int64
+	size   int64
+	digest digest.Digest
+}
+
+// digestSections reads the file at path once, returning the digests of its consecutive sections of at most maxSize
+// bytes, or of a single section with maxSize 0, and the digest of the whole file
+// Nothing is written to disk; the sections are pushed from the file itself
+func digestSections(path string, maxSize int64) ([]fileSection, digest.Digest, error) {
+	source, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer source.Close()
+
+	whole := sha256.New()
+	reader := io.TeeReader(source, whole)
+	var sections []fileSection
+	var offset int64
+	for {
+		part := sha256.New()
+		var written int64
+		if maxSize > 0 {
+			written, err = io.CopyN(part, reader, maxSize)
+		} else {
+			written, err = io.Copy(part, reader)
+		}
+		if err != nil && err != io.EOF {
+			return nil, "", err
+		}
+		if written == 0 && len(sections) > 0 {
+			break
+		}
+		sections = append(sections, fileSection{
+			path:   path,
+			offset: offset,
+			size:   written,
+			digest: digest.NewDigest(digest.SHA256, part),
+		})
+		offset += written
+		if maxSize == 0 || written < maxSize {
+			break
+		}
+	}
+	return sections, digest.NewDigest(digest.SHA256, whole), nil
+}
+
+// sourceStore is the source an artifact is copied to the registry from: the config and manifest are held in memory
+// and layers are read from sections of the artifact file, so uploading doesn't copy the artifact to the temp directory
+type sourceStore struct {
+	*memory.Store
+
+	mu       sync.RWMutex
+	sections map[digest.Digest]fileSection
+}
+
+func newSourceStore() *sourceStore {
+	return &sourceStore{Store: memory.New(), sections: make(map[digest.Digest]fileSection)}
+}
+
+// addSection adds a section of a file to the store, returning its descriptor
+func (s *sourceStore) addSection(section fileSection, mediaType string) ocispec.Descriptor {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sections[section.digest] = section
+	return ocispec.Descriptor{MediaType: mediaType, Digest: section.digest, Size: section.size}
+}
+
+func (s *sourceStore) section(target ocispec.Descriptor) (fileSection, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	section, ok := s.sections[target.Digest]
+	return section, ok
+}
+
+// Fetch reads file sections from disk on every call, so a retried push reads them again from the start
+func (s *sourceStore) Fetch(ctx context.Context, target ocispec.Descriptor) (io.ReadCloser, error) {
+	section, ok := s.section(target)
+	if !ok {
+		return s.Store.Fetch(ctx, target)
+	}
+	if target.Size != section.size {
+		return nil, fmt.Errorf("%s: size %d doesn't match the %d bytes of %s", target.Digest, target.Size, section.size, section.path)
+	}
+	source, err := os.Open(section.path)
+	if err != nil {
+		return nil, err
+	}
+	return &sectionReadCloser{Reader: io.NewSectionReader(source, section.offset, section.size), file: source}, nil
+}
+
+// Exists reports whether target is in memory or a file section
+func (s *sourceStore) Exists(ctx context.Context, target ocispec.Descriptor) (bool, error) {
+	if _, ok := s.section(target); ok {
+		return true, nil
+	}
+	return s.Store.Exists(ctx, target)
+}
+
+// sectionReadCloser reads a section of a file, closing the file when done
+type sectionReadCloser struct {
+	io.Reader
+	file *os.File
+}
+
+func (r *sectionReadCloser) Close() error {
+	return r.file.Close()
+}
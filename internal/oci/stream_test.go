@@ -0,0 +1,111 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceStore(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	path := filepath.Join(t.TempDir(), "agent.tar.gz")
+	require.NoError(t, os.WriteFile(path, content, 0o644))
+	sections, _, err := digestSections(path, 8)
+	require.NoError(t, err)
+
+	store := newSourceStore()
+	desc := store.addSection(sections[1], "application/gzip")
+	config := []byte("{}")
+	configDesc := ocispec.Descriptor{MediaType: "application/json", Digest: digest.FromBytes(config), Size: int64(len(config))}
+	require.NoError(t, store.Push(context.Background(), configDesc, bytes.NewReader(config)))
+
+	t.Run("reads a section from the file", func(t *testing.T) {
+		// method under test
+		reader, err := store.Fetch(context.Background(), desc)
+
+		require.NoError(t, err)
+		defer reader.Close()
+		read, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("89abcdef"), read)
+		assert.Equal(t, digest.FromBytes(read), desc.Digest)
+	})
+
+	t.Run("reads other content from memory", func(t *testing.T) {
+		// method under test
+		reader, err := store.Fetch(context.Background(), configDesc)
+
+		require.NoError(t, err)
+		defer reader.Close()
+		read, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, config, read)
+	})
+
+	t.Run("exists", func(t *testing.T) {
+		// method under test
+		sectionExists, err := store.Exists(context.Background(), desc)
+		require.NoError(t, err)
+		missingExists, err := store.Exists(context.Background(), ocispec.Descriptor{Digest: digest.FromString("missing")})
+		require.NoError(t, err)
+
+		assert.True(t, sectionExists)
+		assert.False(t, missingExists)
+	})
+
+	t.Run("rejects a descriptor of another size", func(t *testing.T) {
+		wrongSize := desc
+		wrongSize.Size = 3
+
+		// method under test
+		_, err := store.Fetch(context.Background(), wrongSize)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "doesn't match the 8 bytes")
+	})
+}
+
+func TestUploadArtifact_InPlace(t *testing.T) {
+	registry := newManifestRegistry()
+	server := httptest.NewServer(registry)
+	defer server.Close()
+	testutil.CaptureOutput(t)
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	client, err := NewClient(context.Background(), strings.TrimPrefix(server.URL, "http://")+"/agents", "", "", Connection{})
+	require.NoError(t, err)
+
+	content := bytes.Repeat([]byte{0x1f, 0x8b, 0x08, 0x00}, 1024)
+	path := filepath.Join(t.TempDir(), "agent.tar.gz")
+	require.NoError(t, os.WriteFile(path, content, 0o644))
+	artifact := &models.ArtifactDefinition{Name: "linux", Path: "./agent.tar.gz", OS: "linux", Arch: "amd64", Format: "tar+gzip"}
+
+	// method under test
+	manifestDigest, _, err := client.UploadArtifact(context.Background(), artifact, path, "1.2.3")
+
+	require.NoError(t, err)
+	var manifest ocispec.Manifest
+	require.NoError(t, json.Unmarshal(registry.manifests[manifestDigest], &manifest))
+	require.Len(t, manifest.Layers, 1)
+	assert.Equal(t, digest.FromBytes(content), manifest.Layers[0].Digest)
+	assert.Equal(t, content, registry.blobs[manifest.Layers[0].Digest.String()])
+
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "Nothing is written to the temp directory")
+}
@@ -1,6 +1,7 @@
 package oci
 
 import (
+	"agent-metadata-action/internal/metrics"
 	"agent-metadata-action/internal/models"
 	"context"
 )
@@ -12,7 +13,17 @@ type ArtifactUploader interface {
 func UploadArtifacts(ctx context.Context, client ArtifactUploader, config *models.OCIConfig, workspacePath, version string) []models.ArtifactUploadResult {
 	results := make([]models.ArtifactUploadResult, 0, len(config.Artifacts))
 
+	hasher, canHash := client.(ArtifactHasher)
+	if canHash {
+		reportIdenticalArtifacts(ctx, hasher, config.Artifacts, workspacePath)
+	}
+
 	for _, artifact := range config.Artifacts {
+		// Already-pushed manifests are handled by ReferenceArtifacts
+		if artifact.IsReference() {
+			continue
+		}
+
 		result := models.ArtifactUploadResult{
 			Name:     artifact.Name,
 			Path:     artifact.Path,
@@ -20,6 +31,9 @@ func UploadArtifacts(ctx context.Context, client ArtifactUploader, config *model
 			Arch:     artifact.Arch,
 			Format:   artifact.Format,
 			Uploaded: false,
+
+			LegalFiles:   artifact.LegalFiles,
+			Notarization: artifact.Notarization,
 		}
 
 		fullPath, err := ResolveArtifactPath(workspacePath, artifact.Path)
@@ -32,10 +46,18 @@ func UploadArtifacts(ctx context.Context, client ArtifactUploader, config *model
 		digest, size, err := client.UploadArtifact(ctx, &artifact, fullPath, version)
 		if err != nil {
 			result.Error = err.Error()
+			result.ErrorCode = RegistryErrorCode(err)
 		} else {
 			result.Digest = digest
 			result.Size = size
 			result.Uploaded = true
+			metrics.Add(ctx, metrics.UploadedBytes, size)
+			// Recorded so a re-run only references the upload while the file is unchanged
+			if canHash {
+				if layerDigest, err := hasher.HashArtifact(ctx, fullPath); err == nil {
+					result.LayerDigest = layerDigest.String()
+				}
+			}
 		}
 
 		results = append(results, result)
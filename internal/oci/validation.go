@@ -1,27 +1,27 @@
 package oci
 
 import (
+	"agent-metadata-action/internal/fileutil"
+	"agent-metadata-action/internal/github"
 	"agent-metadata-action/internal/logging"
 	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/validation"
 	"context"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 )
 
 func ValidateBinaryPath(workspacePath, binaryPath string) error {
 	// Reject paths with directory traversal
-	if strings.Contains(binaryPath, "..") {
+	if fileutil.HasTraversal(binaryPath) {
 		return fmt.Errorf("invalid binary path: contains directory traversal")
 	}
 
 	// Resolve to absolute path
-	var fullPath string
-	if filepath.IsAbs(binaryPath) {
-		fullPath = binaryPath
-	} else {
-		fullPath = filepath.Join(workspacePath, binaryPath)
+	fullPath, err := ResolveArtifactPath(workspacePath, binaryPath)
+	if err != nil {
+		return err
 	}
 
 	resolvedPath, err := filepath.Abs(fullPath)
@@ -46,19 +46,32 @@ func ValidateBinaryPath(workspacePath, binaryPath string) error {
 	return nil
 }
 
+// ValidateAllArtifacts checks every local artifact, annotating each invalid one on the workflow file
+// Returns every failure together as validation.Errors
 func ValidateAllArtifacts(ctx context.Context, workspacePath string, config *models.OCIConfig) error {
-	for _, artifact := range config.Artifacts {
+	var errs validation.Errors
+	for i, artifact := range config.Artifacts {
+		if artifact.IsReference() {
+			continue
+		}
 		if err := ValidateBinaryPath(workspacePath, artifact.Path); err != nil {
-			return fmt.Errorf("validation failed for artifact '%s': %w", artifact.Name, err)
+			err = fmt.Errorf("validation failed for artifact '%s': %w", artifact.Name, err)
+			github.AddWorkflowAnnotation(ctx, github.AnnotationFailure, "Invalid artifact", err.Error())
+			errs.Add("", 0, fmt.Sprintf("binaries[%d]", i), err)
 		}
 	}
+	if err := errs.Err(); err != nil {
+		return err
+	}
 	logging.Debug(ctx, "All artifact validations passed")
 	return nil
 }
 
+// ResolveArtifactPath resolves an artifact path against the workspace, accepting '/' or '\' separators
 func ResolveArtifactPath(workspacePath, artifactPath string) (string, error) {
-	if filepath.IsAbs(artifactPath) {
-		return artifactPath, nil
+	normalized := fileutil.NormalizePath(artifactPath)
+	if filepath.IsAbs(normalized) {
+		return normalized, nil
 	}
-	return filepath.Join(workspacePath, artifactPath), nil
+	return filepath.Join(workspacePath, normalized), nil
 }
@@ -1,11 +1,17 @@
 package oci
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"agent-metadata-action/internal/github"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/testutil"
+
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestValidateBinaryPath(t *testing.T) {
@@ -49,6 +55,19 @@ func TestValidateBinaryPath(t *testing.T) {
 			expectError: true,
 			errorMsg:    "directory traversal",
 		},
+		{
+			name:        "valid relative path with windows separators",
+			workspace:   tmpDir,
+			binaryPath:  `.\test.tar.gz`,
+			expectError: false,
+		},
+		{
+			name:        "path with windows directory traversal",
+			workspace:   tmpDir,
+			binaryPath:  `..\test.tar.gz`,
+			expectError: true,
+			errorMsg:    "directory traversal",
+		},
 		{
 			name:        "file not found",
 			workspace:   tmpDir,
@@ -116,6 +135,29 @@ func TestValidateBinaryPath_Directory(t *testing.T) {
 	assert.Contains(t, err.Error(), "directory, not a file")
 }
 
+func TestValidateAllArtifacts_AnnotatesWorkflow(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "empty.tar.gz"), []byte{}, 0644))
+	t.Setenv("GITHUB_WORKFLOW_REF", "newrelic/java-agent/.github/workflows/release.yml@refs/tags/v1.2.3")
+	testutil.CaptureOutput(t)
+	annotations := github.NewAnnotationCollector()
+	ctx := github.WithAnnotationCollector(context.Background(), annotations)
+
+	// method under test
+	err := ValidateAllArtifacts(ctx, tmpDir, &models.OCIConfig{Artifacts: []models.ArtifactDefinition{
+		{Name: "empty", Path: "empty.tar.gz"},
+		{Name: "missing", Path: "missing.tar.gz"},
+	}})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "binaries[0]: validation failed for artifact 'empty'")
+	assert.Contains(t, err.Error(), "binaries[1]: validation failed for artifact 'missing'")
+	require.Len(t, annotations.Annotations(), 2)
+	assert.Equal(t, ".github/workflows/release.yml", annotations.Annotations()[0].Path)
+	assert.Equal(t, "Invalid artifact", annotations.Annotations()[0].Title)
+	assert.Contains(t, annotations.Annotations()[1].Message, "artifact 'missing'")
+}
+
 func TestResolveArtifactPath(t *testing.T) {
 	workspace := "/workspace"
 
@@ -134,6 +176,11 @@ func TestResolveArtifactPath(t *testing.T) {
 			artifactPath: "/absolute/path/agent.tar.gz",
 			expected:     "/absolute/path/agent.tar.gz",
 		},
+		{
+			name:         "windows separators",
+			artifactPath: `.\dist\agent.zip`,
+			expected:     "/workspace/dist/agent.zip",
+		},
 	}
 
 	for _, tt := range tests {
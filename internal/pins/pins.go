@@ -0,0 +1,149 @@
+package pins
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"agent-metadata-action/internal/results"
+
+	"gopkg.in/yaml.v3"
+)
+
+// header starts every pin file so readers of a GitOps repository know not to edit it by hand
+const header = "# Generated by agent-metadata-action; pins the release to the digests it was published with\n"
+
+// pathSegmentRegex limits agent types and versions to characters that are safe as a single path segment
+var pathSegmentRegex = regexp.MustCompile(`^[A-Za-z0-9._+-]+$`)
+
+// Pin is the content of a pin file: the digests an agent release was published with, for GitOps repositories to
+// deploy by digest rather than by tag
+type Pin struct {
+	AgentType string     `yaml:"agentType"`
+	Version   string     `yaml:"version"`
+	Registry  string     `yaml:"registry"`
+	Tag       string     `yaml:"tag"`
+	Index     string     `yaml:"index"` // <registry>@<digest>, the reference to deploy
+	Digest    string     `yaml:"digest"`
+	Signature *Signature `yaml:"signature,omitempty"`
+	Platforms []Platform `yaml:"platforms"`
+}
+
+// Signature references the signature of the manifest index
+type Signature struct {
+	ID string `yaml:"id"`
+}
+
+// Platform is an artifact listed in the manifest index
+type Platform struct {
+	Name   string `yaml:"name"`
+	OS     string `yaml:"os"`
+	Arch   string `yaml:"arch"`
+	Digest string `yaml:"digest"`
+}
+
+// FromResults builds the pin of an agent release from the results of its run
+// Returns an error if no manifest index was pushed
+func FromResults(agentType, version string, recorded results.Results) (*Pin, error) {
+	if recorded.Index == nil || recorded.Index.Digest == "" {
+		return nil, fmt.Errorf("no manifest index was pushed for %s version %s", agentType, version)
+	}
+
+	pin := &Pin{
+		AgentType: agentType,
+		Version:   version,
+		Registry:  recorded.Index.Registry,
+		Tag:       recorded.Index.Tag,
+		Index:     recorded.Index.Registry + "@" + recorded.Index.Digest,
+		Digest:    recorded.Index.Digest,
+		Platforms: []Platform{},
+	}
+	if recorded.Index.Signed {
+		pin.Signature = &Signature{ID: recorded.Index.SignatureID}
+	}
+	for _, artifact := range recorded.Artifacts {
+		if !artifact.Uploaded {
+			continue
+		}
+		pin.Platforms = append(pin.Platforms, Platform{Name: artifact.Name, OS: artifact.OS, Arch: artifact.Arch, Digest: artifact.Digest})
+	}
+	sort.Slice(pin.Platforms, func(i, j int) bool { return pin.Platforms[i].Name < pin.Platforms[j].Name })
+	return pin, nil
+}
+
+// Path returns the path of the pin file of an agent release, <agentType>/<version>.yaml, relative to the pins
+// directory
+func Path(agentType, version string) (string, error) {
+	if err := validatePathSegment("agent type", agentType); err != nil {
+		return "", err
+	}
+	if err := validatePathSegment("version", version); err != nil {
+		return "", err
+	}
+	return agentType + "/" + version + ".yaml", nil
+}
+
+// Marshal returns the YAML content of the pin file
+func Marshal(pin *Pin) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(pin); err != nil {
+		return nil, fmt.Errorf("failed to marshal pin: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal pin: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Write writes the pin file of the release to <dir>/<agentType>/<version>.yaml and returns its path
+func Write(dir string, pin *Pin) (string, error) {
+	relative, err := Path(pin.AgentType, pin.Version)
+	if err != nil {
+		return "", err
+	}
+	content, err := Marshal(pin)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, filepath.FromSlash(relative))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create pins directory %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// validatePathSegment rejects values that would escape or nest within the pins directory
+func validatePathSegment(name, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s is required", name)
+	}
+	if value == "." || value == ".." || !pathSegmentRegex.MatchString(value) {
+		return fmt.Errorf("invalid %s %q: must only contain letters, digits, '.', '_', '+' or '-'", name, value)
+	}
+	return nil
+}
+
+// Description returns a markdown summary of the pin for the pull request proposing it
+func Description(pin *Pin) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Pins %s %s to the digests it was published with.\n\n", pin.AgentType, pin.Version)
+	fmt.Fprintf(&b, "Manifest index: `%s`\n", pin.Index)
+	if pin.Signature != nil {
+		fmt.Fprintf(&b, "Signature: `%s`\n", pin.Signature.ID)
+	}
+	b.WriteString("\n| Artifact | Platform | Digest |\n| --- | --- | --- |\n")
+	for _, platform := range pin.Platforms {
+		fmt.Fprintf(&b, "| %s | %s/%s | `%s` |\n", platform.Name, platform.OS, platform.Arch, platform.Digest)
+	}
+	return b.String()
+}
@@ -0,0 +1,91 @@
+package pins
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent-metadata-action/internal/results"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testResults() results.Results {
+	return results.Results{
+		Artifacts: []results.Artifact{
+			{Name: "windows-amd64", OS: "windows", Arch: "amd64", Digest: "sha256:windows", Uploaded: true},
+			{Name: "linux-amd64", OS: "linux", Arch: "amd64", Digest: "sha256:linux", Uploaded: true},
+			{Name: "darwin-arm64", OS: "darwin", Arch: "arm64", Error: "upload failed"},
+		},
+		Index: &results.Index{Registry: "docker.io/newrelic/agents", Tag: "1.2.3", Digest: "sha256:index", Signed: true, SignatureID: "sig-123"},
+	}
+}
+
+func TestFromResults(t *testing.T) {
+	// method under test
+	pin, err := FromResults("NRJavaAgent", "1.2.3", testResults())
+
+	require.NoError(t, err)
+	assert.Equal(t, &Pin{
+		AgentType: "NRJavaAgent",
+		Version:   "1.2.3",
+		Registry:  "docker.io/newrelic/agents",
+		Tag:       "1.2.3",
+		Index:     "docker.io/newrelic/agents@sha256:index",
+		Digest:    "sha256:index",
+		Signature: &Signature{ID: "sig-123"},
+		Platforms: []Platform{
+			{Name: "linux-amd64", OS: "linux", Arch: "amd64", Digest: "sha256:linux"},
+			{Name: "windows-amd64", OS: "windows", Arch: "amd64", Digest: "sha256:windows"},
+		},
+	}, pin)
+}
+
+func TestFromResults_NoIndex(t *testing.T) {
+	// method under test
+	_, err := FromResults("NRJavaAgent", "1.2.3", results.Results{})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no manifest index was pushed")
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	pin, err := FromResults("NRJavaAgent", "1.2.3", testResults())
+	require.NoError(t, err)
+
+	// method under test
+	path, err := Write(dir, pin)
+
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "NRJavaAgent", "1.2.3.yaml"), path)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, header+`agentType: NRJavaAgent
+version: 1.2.3
+registry: docker.io/newrelic/agents
+tag: 1.2.3
+index: docker.io/newrelic/agents@sha256:index
+digest: sha256:index
+signature:
+  id: sig-123
+platforms:
+  - name: linux-amd64
+    os: linux
+    arch: amd64
+    digest: sha256:linux
+  - name: windows-amd64
+    os: windows
+    arch: amd64
+    digest: sha256:windows
+`, string(content))
+}
+
+func TestPath_InvalidVersion(t *testing.T) {
+	// method under test
+	_, err := Path("NRJavaAgent", "../1.2.3")
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid version")
+}
@@ -0,0 +1,198 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"agent-metadata-action/internal/models"
+)
+
+// Config is the content of policy.yml in the config directory, enabling the organizational rules a release must
+// follow before it is submitted; rules that aren't listed aren't evaluated
+//
+//	rules:
+//	  security-notes-for-cves: {}
+//	  eol-for-major-versions: {}
+//	  eol-support-window:
+//	    min-days: 180
+//	  supported-os:
+//	    os: [linux, windows]
+type Config struct {
+	Rules map[string]Options `yaml:"rules"`
+}
+
+// Options are the rule-specific settings of a rule in policy.yml
+type Options map[string]interface{}
+
+// Kind is the kind of release a policy is evaluated against
+type Kind string
+
+const (
+	KindAgent        Kind = "agent"         // an agent release from an agent repository
+	KindReleaseNotes Kind = "release-notes" // a release note from a docs repository
+)
+
+// Release is what the rules are evaluated against
+type Release struct {
+	Kind      Kind
+	AgentType string
+	Version   string
+	Metadata  models.Metadata
+	Commits   []string // messages of the commits that triggered the run
+	OS        []string // operating systems of the binaries the release ships
+}
+
+// Outcome is the result of evaluating one rule against a release
+type Outcome string
+
+const (
+	OutcomePass    Outcome = "pass"
+	OutcomeFail    Outcome = "fail"
+	OutcomeSkipped Outcome = "skipped"
+)
+
+// Result is the outcome of evaluating one rule, with a message explaining it
+type Result struct {
+	Rule    string
+	Outcome Outcome
+	Message string
+}
+
+// Rule is an organizational rule that can be enabled in policy.yml
+type Rule struct {
+	ID          string
+	Description string
+	Kinds       []Kind // kinds of release the rule applies to; others skip it
+	Check       func(release Release, options Options) (Outcome, string, error)
+}
+
+// Rules lists the built-in rules
+var Rules = []Rule{securityNotesRule, eolForMajorVersionsRule, eolSupportWindowRule, supportedOSRule}
+
+// LoadConfig reads a policy.yml file
+// A missing file gives an empty configuration, which enables no rules
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return cfg, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := cfg.validate(Rules); err != nil {
+		return cfg, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// validate rejects unknown rule IDs, so a typo doesn't silently leave a rule disabled
+func (c Config) validate(rules []Rule) error {
+	known := map[string]bool{}
+	for _, rule := range rules {
+		known[rule.ID] = true
+	}
+	for id := range c.Rules {
+		if !known[id] {
+			return fmt.Errorf("unknown policy rule %q", id)
+		}
+	}
+	return nil
+}
+
+// Evaluate checks a release against every rule enabled in the configuration, in rule ID order
+// An error means a rule's options are invalid
+func Evaluate(cfg Config, release Release) ([]Result, error) {
+	ids := make([]string, 0, len(cfg.Rules))
+	for id := range cfg.Rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var evaluated []Result
+	for _, id := range ids {
+		rule, ok := lookup(id)
+		if !ok {
+			return nil, fmt.Errorf("unknown policy rule %q", id)
+		}
+		if !rule.appliesTo(release.Kind) {
+			evaluated = append(evaluated, Result{Rule: id, Outcome: OutcomeSkipped, Message: fmt.Sprintf("does not apply to %s releases", release.Kind)})
+			continue
+		}
+		outcome, message, err := rule.Check(release, cfg.Rules[id])
+		if err != nil {
+			return nil, fmt.Errorf("policy rule %s: %w", id, err)
+		}
+		evaluated = append(evaluated, Result{Rule: id, Outcome: outcome, Message: message})
+	}
+	return evaluated, nil
+}
+
+// Count returns the number of results with the given outcome
+func Count(evaluated []Result, outcome Outcome) int {
+	n := 0
+	for _, result := range evaluated {
+		if result.Outcome == outcome {
+			n++
+		}
+	}
+	return n
+}
+
+func lookup(id string) (Rule, bool) {
+	for _, rule := range Rules {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+func (r Rule) appliesTo(kind Kind) bool {
+	for _, k := range r.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Int returns an integer option, or def if it is not set
+func (o Options) Int(name string, def int) (int, error) {
+	value, ok := o[name]
+	if !ok {
+		return def, nil
+	}
+	n, ok := value.(int)
+	if !ok {
+		return 0, fmt.Errorf("option %s must be an integer, got %v", name, value)
+	}
+	return n, nil
+}
+
+// Strings returns a list of strings option, or nil if it is not set
+func (o Options) Strings(name string) ([]string, error) {
+	value, ok := o[name]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("option %s must be a list of strings, got %v", name, value)
+	}
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("option %s must be a list of strings, got %v", name, value)
+		}
+		strs = append(strs, s)
+	}
+	return strs, nil
+}
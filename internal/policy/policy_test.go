@@ -0,0 +1,209 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"agent-metadata-action/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("missing file enables no rules", func(t *testing.T) {
+		// method under test
+		cfg, err := LoadConfig(filepath.Join(dir, "missing.yml"))
+
+		require.NoError(t, err)
+		assert.Empty(t, cfg.Rules)
+	})
+
+	t.Run("rules with and without options", func(t *testing.T) {
+		path := filepath.Join(dir, "policy.yml")
+		require.NoError(t, os.WriteFile(path, []byte("rules:\n  eol-for-major-versions:\n  supported-os:\n    os: [linux]\n"), 0644))
+
+		// method under test
+		cfg, err := LoadConfig(path)
+
+		require.NoError(t, err)
+		assert.Contains(t, cfg.Rules, "eol-for-major-versions")
+		assert.Equal(t, []interface{}{"linux"}, cfg.Rules["supported-os"]["os"])
+	})
+
+	t.Run("unknown rule", func(t *testing.T) {
+		path := filepath.Join(dir, "unknown.yml")
+		require.NoError(t, os.WriteFile(path, []byte("rules:\n  eol-for-major-version: {}\n"), 0644))
+
+		// method under test
+		_, err := LoadConfig(path)
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown policy rule "eol-for-major-version"`)
+	})
+}
+
+func TestEvaluate(t *testing.T) {
+	all := Config{Rules: map[string]Options{
+		"security-notes-for-cves": nil,
+		"eol-for-major-versions":  nil,
+		"supported-os":            {"os": []interface{}{"linux", "windows"}},
+	}}
+
+	tests := []struct {
+		name    string
+		release Release
+		want    []Result
+	}{
+		{
+			name: "release notes without CVEs for a minor version",
+			release: Release{
+				Kind:     KindReleaseNotes,
+				Version:  "8.1.0",
+				Metadata: models.Metadata{"version": "8.1.0"},
+				Commits:  []string{"Add release notes"},
+			},
+			want: []Result{
+				{Rule: "eol-for-major-versions", Outcome: OutcomePass, Message: "8.1.0 is not a major version"},
+				{Rule: "security-notes-for-cves", Outcome: OutcomePass, Message: "no CVE IDs in the commits"},
+				{Rule: "supported-os", Outcome: OutcomeSkipped, Message: "does not apply to release-notes releases"},
+			},
+		},
+		{
+			name: "release notes missing security notes and eol",
+			release: Release{
+				Kind:     KindReleaseNotes,
+				Version:  "v9.0",
+				Metadata: models.Metadata{"version": "v9.0", "security": []interface{}{}},
+				Commits:  []string{"Fix cve-2024-5678 and CVE-2024-1234", "Follow up on CVE-2024-1234"},
+			},
+			want: []Result{
+				{Rule: "eol-for-major-versions", Outcome: OutcomeFail, Message: "v9.0 is a major version but the release notes don't set eol"},
+				{Rule: "security-notes-for-cves", Outcome: OutcomeFail, Message: "commits mention CVE-2024-1234, CVE-2024-5678 but the release notes have no security notes"},
+				{Rule: "supported-os", Outcome: OutcomeSkipped, Message: "does not apply to release-notes releases"},
+			},
+		},
+		{
+			name: "release notes with security notes and eol",
+			release: Release{
+				Kind:     KindReleaseNotes,
+				Version:  "9.0.0",
+				Metadata: models.Metadata{"version": "9.0.0", "eol": "2027-01-01", "security": []interface{}{"Fixed CVE-2024-1234"}},
+				Commits:  []string{"Fix CVE-2024-1234"},
+			},
+			want: []Result{
+				{Rule: "eol-for-major-versions", Outcome: OutcomePass, Message: "9.0.0 sets eol"},
+				{Rule: "security-notes-for-cves", Outcome: OutcomePass, Message: "security notes cover CVE-2024-1234"},
+				{Rule: "supported-os", Outcome: OutcomeSkipped, Message: "does not apply to release-notes releases"},
+			},
+		},
+		{
+			name:    "agent release for a supported OS",
+			release: Release{Kind: KindAgent, Version: "9.0.0", OS: []string{"darwin", "linux"}},
+			want: []Result{
+				{Rule: "eol-for-major-versions", Outcome: OutcomeSkipped, Message: "does not apply to agent releases"},
+				{Rule: "security-notes-for-cves", Outcome: OutcomeSkipped, Message: "does not apply to agent releases"},
+				{Rule: "supported-os", Outcome: OutcomePass, Message: "ships binaries for linux"},
+			},
+		},
+		{
+			name:    "agent release without a supported OS",
+			release: Release{Kind: KindAgent, Version: "9.0.0", OS: []string{"darwin"}},
+			want: []Result{
+				{Rule: "eol-for-major-versions", Outcome: OutcomeSkipped, Message: "does not apply to agent releases"},
+				{Rule: "security-notes-for-cves", Outcome: OutcomeSkipped, Message: "does not apply to agent releases"},
+				{Rule: "supported-os", Outcome: OutcomeFail, Message: "the release ships no binaries for a supported operating system (linux, windows)"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			got, err := Evaluate(all, tt.release)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEvaluate_SupportedOSDefaults(t *testing.T) {
+	cfg := Config{Rules: map[string]Options{"supported-os": nil}}
+
+	// method under test
+	withBinaries, err := Evaluate(cfg, Release{Kind: KindAgent, OS: []string{"any"}})
+	require.NoError(t, err)
+	withoutBinaries, err := Evaluate(cfg, Release{Kind: KindAgent})
+	require.NoError(t, err)
+
+	assert.Equal(t, OutcomePass, withBinaries[0].Outcome)
+	assert.Equal(t, []Result{{Rule: "supported-os", Outcome: OutcomeFail, Message: "the release ships no binaries for any operating system"}}, withoutBinaries)
+}
+
+func TestEvaluate_EOLSupportWindow(t *testing.T) {
+	original := now
+	defer func() { now = original }()
+	now = func() time.Time { return time.Date(2026, 3, 1, 15, 30, 0, 0, time.UTC) }
+	cfg := Config{Rules: map[string]Options{"eol-support-window": {"min-days": 90}}}
+
+	tests := []struct {
+		name        string
+		metadata    models.Metadata
+		wantOutcome Outcome
+		wantMessage string
+	}{
+		{name: "no eol", metadata: models.Metadata{}, wantOutcome: OutcomePass, wantMessage: "no eol set"},
+		{name: "long enough", metadata: models.Metadata{"eol": "2026-05-30"}, wantOutcome: OutcomePass, wantMessage: "eol 2026-05-30 is 90 days away"},
+		{name: "unquoted date", metadata: models.Metadata{"eol": time.Date(2027, 3, 1, 0, 0, 0, 0, time.UTC)}, wantOutcome: OutcomePass, wantMessage: "eol 2027-03-01 is 365 days away"},
+		{name: "too short", metadata: models.Metadata{"eol": "2026-05-29"}, wantOutcome: OutcomeFail, wantMessage: "eol 2026-05-29 is 89 days away, less than the 90 day minimum support window"},
+		{name: "expired", metadata: models.Metadata{"eol": "2026-02-28"}, wantOutcome: OutcomeFail, wantMessage: "eol 2026-02-28 has already passed"},
+		{name: "invalid", metadata: models.Metadata{"eol": "2024-13-45"}, wantOutcome: OutcomeFail, wantMessage: `invalid eol: "2024-13-45" is not a valid date: must be YYYY-MM-DD`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			got, err := Evaluate(cfg, Release{Kind: KindReleaseNotes, Metadata: tt.metadata})
+
+			require.NoError(t, err)
+			assert.Equal(t, []Result{{Rule: "eol-support-window", Outcome: tt.wantOutcome, Message: tt.wantMessage}}, got)
+		})
+	}
+
+	t.Run("today is not yet passed", func(t *testing.T) {
+		got, err := Evaluate(Config{Rules: map[string]Options{"eol-support-window": nil}}, Release{Kind: KindReleaseNotes, Metadata: models.Metadata{"eol": "2026-03-01"}})
+
+		require.NoError(t, err)
+		assert.Equal(t, OutcomePass, got[0].Outcome)
+	})
+
+	t.Run("negative minimum", func(t *testing.T) {
+		_, err := Evaluate(Config{Rules: map[string]Options{"eol-support-window": {"min-days": -1}}}, Release{Kind: KindReleaseNotes})
+
+		assert.EqualError(t, err, "policy rule eol-support-window: option min-days must not be negative, got -1")
+	})
+}
+
+func TestEvaluate_InvalidOptions(t *testing.T) {
+	cfg := Config{Rules: map[string]Options{"supported-os": {"os": "linux"}}}
+
+	// method under test
+	_, err := Evaluate(cfg, Release{Kind: KindAgent, OS: []string{"linux"}})
+
+	require.Error(t, err)
+	assert.Equal(t, "policy rule supported-os: option os must be a list of strings, got linux", err.Error())
+}
+
+func TestCount(t *testing.T) {
+	evaluated := []Result{{Outcome: OutcomePass}, {Outcome: OutcomeFail}, {Outcome: OutcomePass}}
+
+	// method under test
+	assert.Equal(t, 2, Count(evaluated, OutcomePass))
+	assert.Equal(t, 1, Count(evaluated, OutcomeFail))
+	assert.Equal(t, 0, Count(evaluated, OutcomeSkipped))
+}
@@ -0,0 +1,128 @@
+package policy
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"agent-metadata-action/internal/models"
+)
+
+// now returns the current time; tests override it
+var now = time.Now
+
+// cvePattern matches CVE IDs such as CVE-2024-1234 in any case
+var cvePattern = regexp.MustCompile(`(?i)\bCVE-\d{4}-\d{4,}\b`)
+
+// majorVersionPattern matches major releases such as 2.0.0, v3.0 or 4
+var majorVersionPattern = regexp.MustCompile(`^v?\d+(\.0+){0,2}$`)
+
+var securityNotesRule = Rule{
+	ID:          "security-notes-for-cves",
+	Description: "Release notes must have security notes when the commits mention a CVE ID",
+	Kinds:       []Kind{KindReleaseNotes},
+	Check: func(release Release, options Options) (Outcome, string, error) {
+		seen := map[string]bool{}
+		var cves []string
+		for _, message := range release.Commits {
+			for _, cve := range cvePattern.FindAllString(message, -1) {
+				cve = strings.ToUpper(cve)
+				if !seen[cve] {
+					seen[cve] = true
+					cves = append(cves, cve)
+				}
+			}
+		}
+		if len(cves) == 0 {
+			return OutcomePass, "no CVE IDs in the commits", nil
+		}
+		sort.Strings(cves)
+		if !release.Metadata.IsSet("security") {
+			return OutcomeFail, fmt.Sprintf("commits mention %s but the release notes have no security notes", strings.Join(cves, ", ")), nil
+		}
+		return OutcomePass, fmt.Sprintf("security notes cover %s", strings.Join(cves, ", ")), nil
+	},
+}
+
+var eolForMajorVersionsRule = Rule{
+	ID:          "eol-for-major-versions",
+	Description: "Release notes for a major version must set an end-of-life date",
+	Kinds:       []Kind{KindReleaseNotes},
+	Check: func(release Release, options Options) (Outcome, string, error) {
+		if !majorVersionPattern.MatchString(release.Version) {
+			return OutcomePass, fmt.Sprintf("%s is not a major version", release.Version), nil
+		}
+		if !release.Metadata.IsSet("eol") {
+			return OutcomeFail, fmt.Sprintf("%s is a major version but the release notes don't set eol", release.Version), nil
+		}
+		return OutcomePass, fmt.Sprintf("%s sets eol", release.Version), nil
+	},
+}
+
+var eolSupportWindowRule = Rule{
+	ID:          "eol-support-window",
+	Description: "The eol of release notes must be at least a minimum number of days in the future (option min-days, default 0)",
+	Kinds:       []Kind{KindReleaseNotes},
+	Check: func(release Release, options Options) (Outcome, string, error) {
+		minDays, err := options.Int("min-days", 0)
+		if err != nil {
+			return "", "", err
+		}
+		if minDays < 0 {
+			return "", "", fmt.Errorf("option min-days must not be negative, got %d", minDays)
+		}
+		if !release.Metadata.IsSet("eol") {
+			return OutcomePass, "no eol set", nil
+		}
+		eol, err := models.ParseDate(release.Metadata["eol"])
+		if err != nil {
+			return OutcomeFail, fmt.Sprintf("invalid eol: %v", err), nil
+		}
+
+		today := now().UTC().Truncate(24 * time.Hour)
+		days := int(math.Floor(eol.Sub(today).Hours() / 24))
+		switch {
+		case days < 0:
+			return OutcomeFail, fmt.Sprintf("eol %s has already passed", eol.Format(models.DateLayout)), nil
+		case days < minDays:
+			return OutcomeFail, fmt.Sprintf("eol %s is %d days away, less than the %d day minimum support window", eol.Format(models.DateLayout), days, minDays), nil
+		}
+		return OutcomePass, fmt.Sprintf("eol %s is %d days away", eol.Format(models.DateLayout), days), nil
+	},
+}
+
+var supportedOSRule = Rule{
+	ID:          "supported-os",
+	Description: "Agent releases must ship binaries for at least one supported operating system (option os, default any)",
+	Kinds:       []Kind{KindAgent},
+	Check: func(release Release, options Options) (Outcome, string, error) {
+		supported, err := options.Strings("os")
+		if err != nil {
+			return "", "", err
+		}
+		for _, name := range release.OS {
+			if name == "" {
+				continue
+			}
+			if len(supported) == 0 || name == "any" || contains(supported, name) {
+				return OutcomePass, fmt.Sprintf("ships binaries for %s", name), nil
+			}
+		}
+		if len(supported) == 0 {
+			return OutcomeFail, "the release ships no binaries for any operating system", nil
+		}
+		return OutcomeFail, fmt.Sprintf("the release ships no binaries for a supported operating system (%s)", strings.Join(supported, ", ")), nil
+	},
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package preflight
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/retry"
+)
+
+// Check is an endpoint that must respond before the action does any slow work
+type Check struct {
+	Name string // Shown in errors, e.g. "instrumentation metadata service"
+	URL  string
+
+	InsecureSkipTLSVerify bool // accept any certificate, for registries set up with oci-insecure-skip-tls-verify
+	Optional              bool // only warn when unreachable, for backends of phases the run can complete without
+}
+
+// ServiceCheck returns the check of a New Relic service's health endpoint
+// GET /v1/health
+func ServiceCheck(name, baseURL string) Check {
+	return Check{Name: name, URL: strings.TrimRight(baseURL, "/") + "/v1/health"}
+}
+
+// RegistryCheck returns the check of an OCI registry's API version endpoint
+// GET /v2/
+// plainHTTP is the oci-plain-http input; certificates are verified unless InsecureSkipTLSVerify is set on the check
+func RegistryCheck(registry string, plainHTTP bool) Check {
+	host := strings.Split(registry, "/")[0]
+	scheme := "https"
+	// Matches the OCI client, which talks plain HTTP to local registries
+	if plainHTTP || strings.HasPrefix(host, "localhost:") || strings.HasPrefix(host, "127.0.0.1:") {
+		scheme = "http"
+	}
+	return Check{Name: "OCI registry " + host, URL: fmt.Sprintf("%s://%s/v2/", scheme, host)}
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// insecureHTTPClient is used for checks that don't verify certificates
+var insecureHTTPClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // #nosec G402 -- opted into with oci-insecure-skip-tls-verify
+	},
+}
+
+// Run checks every endpoint concurrently and fails if any that isn't optional is unreachable
+// Any response below 500 counts as reachable: a 401 from a registry that needs credentials, or a 404 from
+// a service without a health endpoint, still shows the backend is up
+func Run(ctx context.Context, checks []Check) error {
+	logging.Log(ctx, "group", "Pre-flight checks")
+	defer logging.Log(ctx, "endgroup", "")
+
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	var failures []string
+	for i, err := range errs {
+		if err != nil && checks[i].Optional {
+			logging.Warnf(ctx, "%s is unreachable: %v - continuing, since it is optional", checks[i].Name, err)
+			continue
+		}
+		if err != nil {
+			logging.Errorf(ctx, "%s is unreachable: %v", checks[i].Name, err)
+			failures = append(failures, fmt.Sprintf("%s (%v)", checks[i].Name, err))
+			continue
+		}
+		logging.Debugf(ctx, "%s is reachable", checks[i].Name)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("backend unreachable: %s", strings.Join(failures, "; "))
+	}
+
+	logging.Noticef(ctx, "Pre-flight checks passed for %d endpoints", len(checks))
+	return nil
+}
+
+// run checks one endpoint, retrying once to ride out a transient failure
+func run(ctx context.Context, check Check) error {
+	retryConfig := retry.Config{
+		MaxAttempts: 2,
+		BaseDelay:   1 * time.Second,
+		Operation:   "Pre-flight check",
+	}
+	return retry.Do(ctx, retryConfig, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.URL, nil)
+		if err != nil {
+			return retry.NewNonRetryableError(fmt.Errorf("invalid URL %s: %w", check.URL, err))
+		}
+		client := httpClient
+		if check.InsecureSkipTLSVerify {
+			client = insecureHTTPClient
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("GET %s returned status %d", check.URL, resp.StatusCode)
+		}
+		return nil
+	})
+}
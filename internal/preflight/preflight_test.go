@@ -0,0 +1,82 @@
+package preflight
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/health", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/", r.URL.Path)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer registry.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	t.Run("reachable endpoints pass", func(t *testing.T) {
+		getStdout, _ := testutil.CaptureOutput(t)
+
+		// method under test
+		err := Run(context.Background(), []Check{
+			ServiceCheck("instrumentation metadata service", healthy.URL),
+			{Name: "OCI registry", URL: registry.URL + "/v2/"},
+		})
+
+		require.NoError(t, err)
+		assert.Contains(t, getStdout(), "Pre-flight checks passed for 2 endpoints")
+	})
+
+	t.Run("unreachable endpoints fail", func(t *testing.T) {
+		testutil.CaptureOutput(t)
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+
+		// method under test
+		err := Run(context.Background(), []Check{
+			ServiceCheck("instrumentation metadata service", healthy.URL),
+			ServiceCheck("signing service", down.URL),
+			ServiceCheck("metadata mirror", closed.URL),
+		})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "backend unreachable: signing service (")
+		assert.Contains(t, err.Error(), "returned status 503")
+		assert.Contains(t, err.Error(), "; metadata mirror (")
+		assert.NotContains(t, err.Error(), "instrumentation metadata service")
+	})
+
+	t.Run("unreachable optional endpoints only warn", func(t *testing.T) {
+		getStdout, _ := testutil.CaptureOutput(t)
+		signing := ServiceCheck("signing service", down.URL)
+		signing.Optional = true
+
+		// method under test
+		err := Run(context.Background(), []Check{ServiceCheck("instrumentation metadata service", healthy.URL), signing})
+
+		require.NoError(t, err)
+		stdout := getStdout()
+		assert.Contains(t, stdout, "signing service is unreachable")
+		assert.Contains(t, stdout, "continuing, since it is optional")
+	})
+}
+
+func TestRegistryCheck(t *testing.T) {
+	assert.Equal(t, Check{Name: "OCI registry docker.io", URL: "https://docker.io/v2/"}, RegistryCheck("docker.io/newrelic/agents", false))
+	assert.Equal(t, "http://localhost:5000/v2/", RegistryCheck("localhost:5000/agents", false).URL)
+	assert.Equal(t, "http://registry.e2e.svc:5000/v2/", RegistryCheck("registry.e2e.svc:5000/agents", true).URL)
+}
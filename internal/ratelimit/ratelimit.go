@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"agent-metadata-action/internal/logging"
+)
+
+// Limiter is a token bucket allowing rate requests per second on average, with bursts of up to burst requests
+// Waiters are served in the order they called Wait; safe for concurrent use
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a limiter of rate requests per second that starts full, with burst tokens
+// A burst below 1 is taken as 1
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Wait blocks until a request may be sent, or ctx is done
+// The token is reserved on the call, so a cancelled wait gives it back
+func (l *Limiter) Wait(ctx context.Context) error {
+	delay := l.reserve()
+	if delay <= 0 {
+		return nil
+	}
+	logging.Debugf(ctx, "Rate limit reached - waiting %s before the next request", delay.Round(time.Millisecond))
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token, returning how long to wait until it is available
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// cancel gives back a reserved token
+func (l *Limiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens++
+}
+
+// Transport waits on a limiter before sending requests under any of its base URLs
+// Requests to other hosts are passed through unchanged
+type Transport struct {
+	Base     http.RoundTripper
+	limiter  *Limiter
+	baseURLs []string
+}
+
+// NewTransport wraps base, sharing limiter between the requests under every base URL
+func NewTransport(base http.RoundTripper, limiter *Limiter, baseURLs ...string) *Transport {
+	normalized := make([]string, 0, len(baseURLs))
+	for _, baseURL := range baseURLs {
+		if baseURL != "" {
+			normalized = append(normalized, strings.TrimRight(baseURL, "/"))
+		}
+	}
+	return &Transport{Base: base, limiter: limiter, baseURLs: normalized}
+}
+
+// RoundTrip sends the request once the limiter allows it
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.matches(req) {
+		if err := t.limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+	return t.Base.RoundTrip(req)
+}
+
+func (t *Transport) matches(req *http.Request) bool {
+	target := req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+	for _, baseURL := range t.baseURLs {
+		if path, ok := strings.CutPrefix(target, baseURL); ok && (path == "" || strings.HasPrefix(path, "/")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_Burst(t *testing.T) {
+	limiter := NewLimiter(20, 2)
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		// method under test
+		require.NoError(t, limiter.Wait(context.Background()))
+	}
+	elapsed := time.Since(start)
+
+	// The first 2 requests use the burst; the next 2 wait 50ms each
+	assert.GreaterOrEqual(t, elapsed, 90*time.Millisecond)
+	assert.Less(t, elapsed, time.Second)
+}
+
+func TestLimiter_Cancelled(t *testing.T) {
+	limiter := NewLimiter(1, 1)
+	require.NoError(t, limiter.Wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// method under test
+	err := limiter.Wait(ctx)
+
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	// The cancelled wait gave its token back, so the next one waits for a single token, not two
+	assert.Less(t, limiter.reserve(), 1100*time.Millisecond)
+}
+
+func TestTransport(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	limiter := NewLimiter(0.001, 1)
+	client := &http.Client{Transport: NewTransport(http.DefaultTransport, limiter, server.URL+"/limited/")}
+
+	get := func(ctx context.Context, path string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// method under test
+	require.NoError(t, get(context.Background(), "/limited/a"))
+	require.NoError(t, get(context.Background(), "/other"))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := get(ctx, "/limited/b")
+
+	require.Error(t, err)
+	assert.Equal(t, 2, requests, "Requests under the base URL wait for the limiter; others don't")
+}
@@ -0,0 +1,204 @@
+package reconcile
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ChangeKind is how a field differs between the repository and the service
+type ChangeKind string
+
+const (
+	ChangeAdded   ChangeKind = "added"   // set by the repository, missing from the service
+	ChangeChanged ChangeKind = "changed" // set by both, to different values
+	ChangeRemoved ChangeKind = "removed" // set by the service, missing from the repository
+)
+
+// Change is one field that differs between what the repository wants (desired) and what the service has (actual)
+// Path is the dotted JSON path of the field, e.g. "configurationDefinitions[0].format"
+type Change struct {
+	Path    string     `json:"path"`
+	Kind    ChangeKind `json:"kind"`
+	Actual  any        `json:"actual,omitempty"`
+	Desired any        `json:"desired,omitempty"`
+}
+
+// String renders the change as a diff line, e.g. "+ metadata.version: "1.2.3""
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s: %s", c.Path, compactJSON(c.Desired))
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s: %s", c.Path, compactJSON(c.Actual))
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", c.Path, compactJSON(c.Actual), compactJSON(c.Desired))
+	}
+}
+
+// Diff compares what the repository wants (desired) against what the service has (actual)
+// and returns a human-readable line per difference, e.g. "+ metadata.version: "1.2.3"".
+// The comparison is a subset check: fields the service adds that the repository doesn't set are ignored,
+// and nil desired values are treated as unset.
+func Diff(desired, actual any) ([]string, error) {
+	changes, err := compare(desired, actual, false)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, change := range changes {
+		lines = append(lines, change.String())
+	}
+	return lines, nil
+}
+
+// Compare is Diff field by field, also reporting the fields only the service sets, to find partial writes and
+// stale fields as well as drift
+func Compare(desired, actual any) ([]Change, error) {
+	return compare(desired, actual, true)
+}
+
+func compare(desired, actual any, removed bool) ([]Change, error) {
+	normalizedDesired, err := normalize(desired)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize desired metadata: %w", err)
+	}
+	normalizedActual, err := normalize(actual)
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize actual metadata: %w", err)
+	}
+
+	var changes []Change
+	diffValue("", normalizedDesired, normalizedActual, removed, &changes)
+	return changes, nil
+}
+
+// normalize round-trips a value through JSON so YAML-loaded and JSON-decoded data compare equally
+func normalize(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var normalized any
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+func diffValue(path string, desired, actual any, removed bool, changes *[]Change) {
+	switch d := desired.(type) {
+	case nil:
+		if removed && actual != nil {
+			*changes = append(*changes, changed(path, actual, desired))
+		}
+		return
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok {
+			*changes = append(*changes, changed(path, actual, desired))
+			return
+		}
+		keys := make([]string, 0, len(d))
+		for k := range d {
+			keys = append(keys, k)
+		}
+		if removed {
+			for k := range a {
+				if _, exists := d[k]; !exists {
+					keys = append(keys, k)
+				}
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			childPath := joinPath(path, k)
+			desiredValue := d[k]
+			actualValue, actualExists := a[k]
+			switch {
+			case desiredValue == nil && actualValue == nil:
+			case desiredValue == nil:
+				if removed {
+					*changes = append(*changes, Change{Path: childPath, Kind: ChangeRemoved, Actual: actualValue})
+				}
+			case !actualExists:
+				*changes = append(*changes, Change{Path: childPath, Kind: ChangeAdded, Desired: desiredValue})
+			default:
+				diffValue(childPath, desiredValue, actualValue, removed, changes)
+			}
+		}
+	case []any:
+		a, ok := actual.([]any)
+		if !ok || len(a) != len(d) {
+			*changes = append(*changes, changed(path, actual, desired))
+			return
+		}
+		for i := range d {
+			diffValue(fmt.Sprintf("%s[%d]", path, i), d[i], a[i], removed, changes)
+		}
+	default:
+		if !reflect.DeepEqual(desired, actual) {
+			*changes = append(*changes, changed(path, actual, desired))
+		}
+	}
+}
+
+func changed(path string, actual, desired any) Change {
+	return Change{Path: displayPath(path), Kind: ChangeChanged, Actual: actual, Desired: desired}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
+
+// compactJSON renders a value for diff output, truncating large values such as base64 schemas
+func compactJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	s := string(data)
+	if len(s) > 120 {
+		s = s[:120] + "..."
+	}
+	return s
+}
+
+// CompareSummary renders the changes Compare found for an agent version as markdown for the job summary
+func CompareSummary(agentType, version string, status Status, changes []Change) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Metadata diff for %s %s\n\n", agentType, version)
+	switch {
+	case status == StatusMissing:
+		b.WriteString("The instrumentation service has no metadata for this version.\n")
+		return b.String()
+	case len(changes) == 0:
+		b.WriteString("The instrumentation service matches the repository.\n")
+		return b.String()
+	}
+	b.WriteString("| Field | Change | Service | Repository |\n|-------|--------|---------|------------|\n")
+	for _, change := range changes {
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s |\n", change.Path, change.Kind, cell(change.Actual), cell(change.Desired))
+	}
+	return b.String()
+}
+
+// cell renders a value on one markdown table row
+func cell(v any) string {
+	if v == nil {
+		return ""
+	}
+	return "`" + strings.NewReplacer("|", `\|`, "`", "'").Replace(compactJSON(v)) + "`"
+}
@@ -0,0 +1,102 @@
+package reconcile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		desired  any
+		actual   any
+		expected []string
+	}{
+		{
+			name:     "equal values",
+			desired:  map[string]any{"version": "1.0.0", "count": 1},
+			actual:   map[string]any{"version": "1.0.0", "count": 1.0},
+			expected: nil,
+		},
+		{
+			name:     "fields only set by the service are ignored",
+			desired:  map[string]any{"version": "1.0.0"},
+			actual:   map[string]any{"version": "1.0.0", "createdAt": "2026-01-01"},
+			expected: nil,
+		},
+		{
+			name:     "nil desired values are ignored",
+			desired:  map[string]any{"version": "1.0.0", "eol": nil},
+			actual:   map[string]any{"version": "1.0.0"},
+			expected: nil,
+		},
+		{
+			name:     "missing field",
+			desired:  map[string]any{"version": "1.0.0", "eol": "2026-01-01"},
+			actual:   map[string]any{"version": "1.0.0"},
+			expected: []string{`+ eol: "2026-01-01"`},
+		},
+		{
+			name:     "changed nested field",
+			desired:  map[string]any{"defs": []any{map[string]any{"format": "yml"}}},
+			actual:   map[string]any{"defs": []any{map[string]any{"format": "json"}}},
+			expected: []string{`~ defs[0].format: "json" -> "yml"`},
+		},
+		{
+			name:     "changed root",
+			desired:  "a",
+			actual:   "b",
+			expected: []string{`~ (root): "b" -> "a"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := Diff(tt.desired, tt.actual)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, lines)
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	desired := map[string]any{
+		"version": "1.2.3",
+		"eol":     "2026-01-01",
+		"defs":    []any{map[string]any{"format": "yml"}},
+		"unset":   nil,
+	}
+	actual := map[string]any{
+		"version":   "1.2.3",
+		"defs":      []any{map[string]any{"format": "json"}},
+		"createdAt": "2026-01-01",
+	}
+
+	// method under test
+	changes, err := Compare(desired, actual)
+
+	require.NoError(t, err)
+	assert.Equal(t, []Change{
+		{Path: "createdAt", Kind: ChangeRemoved, Actual: "2026-01-01"},
+		{Path: "defs[0].format", Kind: ChangeChanged, Actual: "json", Desired: "yml"},
+		{Path: "eol", Kind: ChangeAdded, Desired: "2026-01-01"},
+	}, changes)
+	assert.Equal(t, `- createdAt: "2026-01-01"`, changes[0].String())
+}
+
+func TestCompareSummary(t *testing.T) {
+	changes := []Change{
+		{Path: "metadata.eol", Kind: ChangeAdded, Desired: "2026-01-01"},
+		{Path: "metadata.features", Kind: ChangeChanged, Actual: []any{"a|b"}, Desired: []any{"a"}},
+	}
+
+	// method under test
+	summary := CompareSummary("NRDotNetAgent", "1.2.3", StatusDrifted, changes)
+
+	assert.Contains(t, summary, "### Metadata diff for NRDotNetAgent 1.2.3")
+	assert.Contains(t, summary, "| `metadata.eol` | added |  | `\"2026-01-01\"` |")
+	assert.Contains(t, summary, "| `metadata.features` | changed | `[\"a\\|b\"]` | `[\"a\"]` |")
+	assert.Contains(t, CompareSummary("NRDotNetAgent", "1.2.3", StatusMissing, nil), "has no metadata for this version")
+}
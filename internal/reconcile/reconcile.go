@@ -0,0 +1,189 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/metrics"
+	"agent-metadata-action/internal/models"
+)
+
+// MetadataService is the subset of the instrumentation client used for reconciliation
+type MetadataService interface {
+	ListVersions(ctx context.Context, agentType string) ([]string, error)
+	GetMetadata(ctx context.Context, agentType string, agentVersion string) (*models.AgentMetadata, error)
+	SendMetadata(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata) error
+}
+
+// Target is metadata the repository says the instrumentation service should have
+type Target struct {
+	Source    string // file or directory the target was built from, for reporting
+	AgentType string
+	Version   string
+	Metadata  *models.AgentMetadata
+}
+
+// Status is the reconciliation state of a target
+type Status string
+
+const (
+	StatusInSync  Status = "in-sync"
+	StatusMissing Status = "missing"
+	StatusDrifted Status = "drifted"
+)
+
+// Result is the outcome of reconciling a single target
+type Result struct {
+	Target    Target
+	Status    Status
+	Diff      []string
+	Submitted bool
+	Error     string
+}
+
+// Run compares each target against the service and re-submits missing or drifted entries
+// In dry-run mode nothing is submitted; the results still describe what would change
+func Run(ctx context.Context, svc MetadataService, targets []Target, dryRun bool) []Result {
+	results := make([]Result, 0, len(targets))
+	knownVersions := map[string]map[string]bool{}
+
+	for _, target := range targets {
+		result := Result{Target: target}
+
+		versions, ok := knownVersions[target.AgentType]
+		if !ok {
+			list, err := svc.ListVersions(ctx, target.AgentType)
+			if err != nil {
+				logging.Warnf(ctx, "Unable to list versions for %s: %v - checking each version individually", target.AgentType, err)
+			} else {
+				versions = make(map[string]bool, len(list))
+				for _, v := range list {
+					versions[v] = true
+				}
+			}
+			knownVersions[target.AgentType] = versions
+		}
+
+		if versions != nil && !versions[target.Version] {
+			result.Status = StatusMissing
+		} else if err := compareTarget(ctx, svc, target, &result); err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			metrics.Add(ctx, metrics.ItemsProcessed, 1)
+			metrics.Add(ctx, metrics.ItemsFailed, 1)
+			continue
+		}
+
+		if result.Status != StatusInSync && !dryRun {
+			if err := svc.SendMetadata(ctx, target.AgentType, target.Version, target.Metadata); err != nil {
+				result.Error = fmt.Sprintf("failed to submit: %v", err)
+			} else {
+				result.Submitted = true
+			}
+		}
+
+		results = append(results, result)
+		metrics.Add(ctx, metrics.ItemsProcessed, 1)
+		if result.Error != "" {
+			metrics.Add(ctx, metrics.ItemsFailed, 1)
+		}
+	}
+
+	return results
+}
+
+// compareTarget fetches the service's copy of a target and records its status and diff
+func compareTarget(ctx context.Context, svc MetadataService, target Target, result *Result) error {
+	actual, err := svc.GetMetadata(ctx, target.AgentType, target.Version)
+	if err != nil {
+		return fmt.Errorf("failed to fetch current metadata: %w", err)
+	}
+	if actual == nil {
+		result.Status = StatusMissing
+		return nil
+	}
+
+	// Definitions are compared in canonical order so a service that stored them in another order isn't reported as drift
+	desired, err := target.Metadata.Canonical()
+	if err != nil {
+		return err
+	}
+	if actual, err = actual.Canonical(); err != nil {
+		return err
+	}
+	diff, err := Diff(desired, actual)
+	if err != nil {
+		return err
+	}
+	result.Diff = diff
+	if len(diff) == 0 {
+		result.Status = StatusInSync
+	} else {
+		result.Status = StatusDrifted
+	}
+	return nil
+}
+
+// Summary counts results by outcome
+type Summary struct {
+	InSync  int
+	Missing int
+	Drifted int
+	Failed  int
+}
+
+// Summarize counts results by outcome
+func Summarize(results []Result) Summary {
+	var summary Summary
+	for _, r := range results {
+		if r.Error != "" {
+			summary.Failed++
+			continue
+		}
+		switch r.Status {
+		case StatusInSync:
+			summary.InSync++
+		case StatusMissing:
+			summary.Missing++
+		case StatusDrifted:
+			summary.Drifted++
+		}
+	}
+	return summary
+}
+
+// Report logs each result, including the diff of drifted entries, followed by a summary
+func Report(ctx context.Context, results []Result, dryRun bool) Summary {
+	for _, r := range results {
+		label := fmt.Sprintf("%s %s (%s)", r.Target.AgentType, r.Target.Version, r.Target.Source)
+		switch {
+		case r.Error != "":
+			logging.Errorf(ctx, "Failed to reconcile %s: %s", label, r.Error)
+		case r.Status == StatusInSync:
+			logging.Debugf(ctx, "%s is in sync", label)
+		case r.Submitted:
+			logging.Noticef(ctx, "Re-submitted %s %s", r.Status, label)
+		default:
+			logging.Noticef(ctx, "Would re-submit %s %s", r.Status, label)
+		}
+
+		if len(r.Diff) > 0 {
+			logging.Log(ctx, "group", fmt.Sprintf("Diff for %s", label))
+			// Plain output so each line shows inside the group rather than as an annotation
+			for _, line := range r.Diff {
+				fmt.Println(line)
+			}
+			logging.Log(ctx, "endgroup", "")
+		}
+	}
+
+	summary := Summarize(results)
+	prefix := "Reconciliation"
+	if dryRun {
+		prefix = "Reconciliation (dry run)"
+	}
+	logging.Noticef(ctx, "%s complete: %d in sync, %d missing, %d drifted, %d failed",
+		prefix, summary.InSync, summary.Missing, summary.Drifted, summary.Failed)
+	return summary
+}
@@ -0,0 +1,137 @@
+package reconcile
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockService is an in-memory MetadataService keyed by agent type and version
+type mockService struct {
+	stored    map[string]*models.AgentMetadata
+	listErr   error
+	submitted []string
+}
+
+func (m *mockService) ListVersions(ctx context.Context, agentType string) ([]string, error) {
+	if m.listErr != nil {
+		return nil, m.listErr
+	}
+	var versions []string
+	for key := range m.stored {
+		if storedType, version, _ := strings.Cut(key, " "); storedType == agentType {
+			versions = append(versions, version)
+		}
+	}
+	return versions, nil
+}
+
+func (m *mockService) GetMetadata(ctx context.Context, agentType string, agentVersion string) (*models.AgentMetadata, error) {
+	return m.stored[agentType+" "+agentVersion], nil
+}
+
+func (m *mockService) SendMetadata(ctx context.Context, agentType string, agentVersion string, metadata *models.AgentMetadata) error {
+	m.submitted = append(m.submitted, agentType+" "+agentVersion)
+	return nil
+}
+
+func newTarget(agentType, version string, metadata models.Metadata) Target {
+	return Target{
+		Source:    "test",
+		AgentType: agentType,
+		Version:   version,
+		Metadata:  &models.AgentMetadata{Metadata: metadata},
+	}
+}
+
+func TestRun(t *testing.T) {
+	svc := &mockService{stored: map[string]*models.AgentMetadata{
+		"NRJavaAgent 1.0.0": {Metadata: models.Metadata{"version": "1.0.0", "features": []any{"a"}, "serverOnly": true}},
+		"NRJavaAgent 1.1.0": {Metadata: models.Metadata{"version": "1.1.0", "features": []any{"a"}}},
+	}}
+	targets := []Target{
+		newTarget("NRJavaAgent", "1.0.0", models.Metadata{"version": "1.0.0", "features": []any{"a"}}),
+		newTarget("NRJavaAgent", "1.1.0", models.Metadata{"version": "1.1.0", "features": []any{"a", "b"}}),
+		newTarget("NRJavaAgent", "1.2.0", models.Metadata{"version": "1.2.0"}),
+	}
+
+	t.Run("dry run reports without submitting", func(t *testing.T) {
+		svc.submitted = nil
+
+		results := Run(context.Background(), svc, targets, true)
+
+		require.Len(t, results, 3)
+		assert.Equal(t, StatusInSync, results[0].Status)
+		assert.Equal(t, StatusDrifted, results[1].Status)
+		assert.Equal(t, []string{`~ metadata.features: ["a"] -> ["a","b"]`}, results[1].Diff)
+		assert.Equal(t, StatusMissing, results[2].Status)
+		assert.Empty(t, svc.submitted)
+		assert.Equal(t, Summary{InSync: 1, Missing: 1, Drifted: 1}, Summarize(results))
+	})
+
+	t.Run("re-submits missing and drifted entries", func(t *testing.T) {
+		svc.submitted = nil
+
+		results := Run(context.Background(), svc, targets, false)
+
+		assert.Equal(t, []string{"NRJavaAgent 1.1.0", "NRJavaAgent 1.2.0"}, svc.submitted)
+		assert.False(t, results[0].Submitted)
+		assert.True(t, results[1].Submitted)
+		assert.True(t, results[2].Submitted)
+	})
+
+	t.Run("falls back to fetching each version when listing fails", func(t *testing.T) {
+		svc.submitted = nil
+		svc.listErr = assert.AnError
+		defer func() { svc.listErr = nil }()
+
+		getStdout, _ := testutil.CaptureOutput(t)
+		results := Run(context.Background(), svc, targets, true)
+
+		assert.Contains(t, getStdout(), "Unable to list versions for NRJavaAgent")
+		assert.Equal(t, StatusInSync, results[0].Status)
+		assert.Equal(t, StatusDrifted, results[1].Status)
+		assert.Equal(t, StatusMissing, results[2].Status)
+	})
+}
+
+func TestReport(t *testing.T) {
+	results := []Result{
+		{Target: newTarget("NRJavaAgent", "1.0.0", nil), Status: StatusInSync},
+		{Target: newTarget("NRJavaAgent", "1.1.0", nil), Status: StatusDrifted, Diff: []string{`+ metadata.eol: "2026-01-01"`}},
+		{Target: newTarget("NRJavaAgent", "1.2.0", nil), Status: StatusMissing, Error: "failed to submit: boom"},
+	}
+
+	getStdout, _ := testutil.CaptureOutput(t)
+	summary := Report(context.Background(), results, true)
+	output := getStdout()
+
+	assert.Equal(t, Summary{InSync: 1, Drifted: 1, Failed: 1}, summary)
+	assert.Contains(t, output, "Would re-submit drifted NRJavaAgent 1.1.0 (test)")
+	assert.Contains(t, output, `+ metadata.eol: "2026-01-01"`)
+	assert.Contains(t, output, "::error::Failed to reconcile NRJavaAgent 1.2.0 (test): failed to submit: boom")
+	assert.Contains(t, output, "Reconciliation (dry run) complete: 1 in sync, 0 missing, 1 drifted, 1 failed")
+}
+
+func TestRun_DefinitionOrderIsNotDrift(t *testing.T) {
+	linux := models.ConfigurationDefinition{"platform": "LINUX", "type": "agent-config", "version": "1.0.0"}
+	all := models.ConfigurationDefinition{"platform": "ALL", "type": "agent-config", "version": "1.0.0"}
+	svc := &mockService{stored: map[string]*models.AgentMetadata{
+		"NRJavaAgent 1.0.0": {Metadata: models.Metadata{"version": "1.0.0"}, ConfigurationDefinitions: []models.ConfigurationDefinition{all, linux}},
+	}}
+	target := newTarget("NRJavaAgent", "1.0.0", models.Metadata{"version": "1.0.0"})
+	target.Metadata.ConfigurationDefinitions = []models.ConfigurationDefinition{linux, all}
+
+	// method under test
+	results := Run(context.Background(), svc, []Target{target}, true)
+
+	require.Len(t, results, 1)
+	assert.Equal(t, StatusInSync, results[0].Status)
+	assert.Empty(t, results[0].Diff)
+}
@@ -0,0 +1,270 @@
+package rego
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"agent-metadata-action/internal/fileutil"
+	"agent-metadata-action/internal/models"
+)
+
+// Package is the Rego package policies declare their deny and warn rules in
+const Package = "agentmetadata"
+
+// Input is the document policies are evaluated against, available to them as input
+type Input struct {
+	AgentType string                `json:"agentType"`
+	Version   string                `json:"version"`
+	DryRun    bool                  `json:"dryRun"`
+	Metadata  *models.AgentMetadata `json:"metadata"`
+	Upload    *Upload               `json:"upload,omitempty"`
+}
+
+// Upload is the OCI upload plan: the binaries that will be pushed and the index tag they will be listed under
+type Upload struct {
+	Registry  string                      `json:"registry"`
+	Tag       string                      `json:"tag"`
+	Artifacts []models.ArtifactDefinition `json:"artifacts"`
+}
+
+// Violation is a deny or warn rule that matched, identified by the rule ID the policy gave it
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("[%s] %s", v.Rule, v.Message)
+}
+
+// Decision is the outcome of evaluating the policies: the deny rules that matched fail the run, warn rules don't
+type Decision struct {
+	Deny []Violation
+	Warn []Violation
+}
+
+// Sources splits the rego-policies input into policy sources, separated by commas or newlines
+func Sources(value string) []string {
+	var sources []string
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if source := strings.TrimSpace(field); source != "" {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// runFunc runs an opa command and returns its stdout
+// This allows tests to override the implementation
+var runFunc = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("the %s command is not installed on the runner", name)
+		}
+		return nil, fmt.Errorf("%s failed: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// fetchHTTPClient is used to fetch policies from https:// sources
+var fetchHTTPClient = &http.Client{Timeout: time.Minute}
+
+// fetchFunc downloads a policy source to dest
+// This allows tests to override the implementation
+var fetchFunc = func(ctx context.Context, url, dest string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := fetchHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	file, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	return nil
+}
+
+// Evaluate evaluates input against the policies in sources with the opa CLI, which must be on the PATH
+// Sources are .rego files, directories of them or bundles (.tar.gz), as paths relative to the workspace or https URLs
+// Policies declare deny and warn rules in the agentmetadata package; see parseViolations for the values they may produce
+func Evaluate(ctx context.Context, workspace string, sources []string, input Input) (Decision, error) {
+	dir, err := os.MkdirTemp("", "agent-metadata-rego-")
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to create policy directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	inputPath := filepath.Join(dir, "input.json")
+	data, err := json.Marshal(input)
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to marshal policy input: %w", err)
+	}
+	if err := os.WriteFile(inputPath, data, 0600); err != nil {
+		return Decision{}, fmt.Errorf("failed to stage policy input: %w", err)
+	}
+
+	args := []string{"eval", "--format", "json", "--input", inputPath}
+	for i, source := range sources {
+		local, err := stageSource(ctx, workspace, dir, i, source)
+		if err != nil {
+			return Decision{}, err
+		}
+		flag := "--data"
+		if strings.HasSuffix(local, ".tar.gz") {
+			flag = "--bundle"
+		}
+		args = append(args, flag, local)
+	}
+	args = append(args, "data."+Package)
+
+	output, err := runFunc(ctx, "opa", args...)
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to evaluate Rego policies: %w", err)
+	}
+	return parseOutput(output)
+}
+
+// stageSource returns the local path of a policy source, downloading https:// sources into dir
+// Local sources must be relative to the workspace without directory traversal
+func stageSource(ctx context.Context, workspace, dir string, i int, source string) (string, error) {
+	if strings.HasPrefix(source, "https://") {
+		dest := filepath.Join(dir, fmt.Sprintf("%d-%s", i, path.Base(strings.SplitN(source, "?", 2)[0])))
+		if err := fetchFunc(ctx, source, dest); err != nil {
+			return "", fmt.Errorf("failed to fetch Rego policy %s: %w", source, err)
+		}
+		return dest, nil
+	}
+	if strings.Contains(source, "://") {
+		return "", fmt.Errorf("invalid Rego policy %s: URLs must use https", source)
+	}
+	if !fileutil.IsLocal(source) {
+		return "", fmt.Errorf("invalid Rego policy %s: must be relative to the repository root without directory traversal", source)
+	}
+	local := filepath.Join(workspace, source)
+	if _, err := os.Stat(local); err != nil {
+		return "", fmt.Errorf("failed to read Rego policy %s: %w", source, err)
+	}
+	return local, nil
+}
+
+// evalOutput is the JSON opa eval writes for a query
+type evalOutput struct {
+	Result []struct {
+		Expressions []struct {
+			Value map[string]json.RawMessage `json:"value"`
+		} `json:"expressions"`
+	} `json:"result"`
+}
+
+// parseOutput extracts the deny and warn rules from the agentmetadata package document
+// An undefined package (no policy declares it) gives an empty decision
+func parseOutput(output []byte) (Decision, error) {
+	var decision Decision
+	var parsed evalOutput
+	if err := json.Unmarshal(output, &parsed); err != nil {
+		return decision, fmt.Errorf("failed to parse opa output: %w", err)
+	}
+	if len(parsed.Result) == 0 || len(parsed.Result[0].Expressions) == 0 {
+		return decision, nil
+	}
+	document := parsed.Result[0].Expressions[0].Value
+
+	var err error
+	if decision.Deny, err = parseViolations("deny", document["deny"]); err != nil {
+		return decision, err
+	}
+	if decision.Warn, err = parseViolations("warn", document["warn"]); err != nil {
+		return decision, err
+	}
+	return decision, nil
+}
+
+// parseViolations reads the values of a deny or warn rule, sorted by rule ID and message
+// Each value is either a message string, reported under the rule name, or an object with an id and a msg
+func parseViolations(rule string, raw json.RawMessage) ([]Violation, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var values []json.RawMessage
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return nil, fmt.Errorf("%s must be a set of messages: %w", rule, err)
+	}
+
+	var violations []Violation
+	for _, value := range values {
+		var message string
+		if err := json.Unmarshal(value, &message); err == nil {
+			violations = append(violations, Violation{Rule: rule, Message: message})
+			continue
+		}
+		var object struct {
+			ID  string `json:"id"`
+			Msg string `json:"msg"`
+		}
+		if err := json.Unmarshal(value, &object); err != nil || object.Msg == "" {
+			return nil, fmt.Errorf("%s values must be messages or objects with id and msg, got %s", rule, value)
+		}
+		if object.ID == "" {
+			object.ID = rule
+		}
+		violations = append(violations, Violation{Rule: object.ID, Message: object.Msg})
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Rule != violations[j].Rule {
+			return violations[i].Rule < violations[j].Rule
+		}
+		return violations[i].Message < violations[j].Message
+	})
+	return violations, nil
+}
+
+// Summary renders a decision as markdown for the job summary
+func Summary(agentType, version string, decision Decision) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Rego policies for %s %s\n\n", agentType, version)
+	if len(decision.Deny) == 0 && len(decision.Warn) == 0 {
+		b.WriteString("No deny or warn rules matched.\n")
+		return b.String()
+	}
+	b.WriteString("| Result | Rule | Message |\n|--------|------|---------|\n")
+	for _, violation := range decision.Deny {
+		fmt.Fprintf(&b, "| deny | `%s` | %s |\n", violation.Rule, escapeCell(violation.Message))
+	}
+	for _, violation := range decision.Warn {
+		fmt.Fprintf(&b, "| warn | `%s` | %s |\n", violation.Rule, escapeCell(violation.Message))
+	}
+	return b.String()
+}
+
+// escapeCell keeps a message on one markdown table row
+func escapeCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\r", " ", "\n", " ").Replace(s)
+}
@@ -0,0 +1,158 @@
+package rego
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const denyOutput = `{"result": [{"expressions": [{"value": {
+	"deny": ["metadata must have a displayName", {"id": "OCI-1", "msg": "linux/arm64 binary is required"}],
+	"warn": [{"msg": "no tags set"}]
+}, "text": "data.agentmetadata"}]}]}`
+
+// stubRun replaces the opa command, returning output and recording the arguments and staged input of each call
+func stubRun(t *testing.T, output string, err error) (*[][]string, *Input) {
+	t.Helper()
+	var calls [][]string
+	var staged Input
+	original := runFunc
+	runFunc = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		calls = append(calls, append([]string{name}, args...))
+		data, readErr := os.ReadFile(args[4])
+		require.NoError(t, readErr)
+		require.NoError(t, json.Unmarshal(data, &staged))
+		return []byte(output), err
+	}
+	t.Cleanup(func() { runFunc = original })
+	return &calls, &staged
+}
+
+func TestSources(t *testing.T) {
+	assert.Nil(t, Sources(""))
+	assert.Equal(t, []string{"policies", "https://example.com/bundle.tar.gz", "extra.rego"},
+		Sources(" policies, https://example.com/bundle.tar.gz\n\nextra.rego\n"))
+}
+
+func TestEvaluate(t *testing.T) {
+	workspace := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(workspace, "policies"), 0755))
+	input := Input{
+		AgentType: "NRJavaAgent",
+		Version:   "1.2.3",
+		Metadata:  &models.AgentMetadata{Metadata: models.Metadata{"version": "1.2.3"}},
+		Upload:    &Upload{Registry: "docker.io/newrelic/agents", Tag: "1.2.3", Artifacts: []models.ArtifactDefinition{{Name: "linux", OS: "linux", Arch: "amd64"}}},
+	}
+
+	t.Run("deny and warn rules", func(t *testing.T) {
+		calls, staged := stubRun(t, denyOutput, nil)
+		originalFetch := fetchFunc
+		defer func() { fetchFunc = originalFetch }()
+		fetchFunc = func(ctx context.Context, url, dest string) error {
+			assert.Equal(t, "https://example.com/policies/bundle.tar.gz?token=abc", url)
+			return os.WriteFile(dest, []byte("bundle"), 0600)
+		}
+
+		// method under test
+		decision, err := Evaluate(context.Background(), workspace, []string{"policies", "https://example.com/policies/bundle.tar.gz?token=abc"}, input)
+
+		require.NoError(t, err)
+		assert.Equal(t, Decision{
+			Deny: []Violation{
+				{Rule: "OCI-1", Message: "linux/arm64 binary is required"},
+				{Rule: "deny", Message: "metadata must have a displayName"},
+			},
+			Warn: []Violation{{Rule: "warn", Message: "no tags set"}},
+		}, decision)
+		require.Len(t, *calls, 1)
+		args := (*calls)[0]
+		assert.Equal(t, []string{"opa", "eval", "--format", "json", "--input"}, args[:5])
+		assert.Equal(t, []string{"--data", filepath.Join(workspace, "policies"), "--bundle"}, args[6:9])
+		assert.Equal(t, "1-bundle.tar.gz", filepath.Base(args[9]))
+		assert.Equal(t, "data.agentmetadata", args[10])
+		assert.Equal(t, input, *staged)
+	})
+
+	t.Run("undefined package", func(t *testing.T) {
+		stubRun(t, `{}`, nil)
+
+		// method under test
+		decision, err := Evaluate(context.Background(), workspace, []string{"policies"}, input)
+
+		require.NoError(t, err)
+		assert.Equal(t, Decision{}, decision)
+	})
+
+	t.Run("opa fails", func(t *testing.T) {
+		stubRun(t, "", assert.AnError)
+
+		// method under test
+		_, err := Evaluate(context.Background(), workspace, []string{"policies"}, input)
+
+		require.Error(t, err)
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+
+	t.Run("invalid rule values", func(t *testing.T) {
+		stubRun(t, `{"result": [{"expressions": [{"value": {"deny": [{"id":"OCI-1"}]}}]}]}`, nil)
+
+		// method under test
+		_, err := Evaluate(context.Background(), workspace, []string{"policies"}, input)
+
+		require.Error(t, err)
+		assert.Equal(t, `deny values must be messages or objects with id and msg, got {"id":"OCI-1"}`, err.Error())
+	})
+
+	for _, tt := range []struct {
+		source  string
+		wantErr string
+	}{
+		{source: "../policies", wantErr: "invalid Rego policy ../policies: must be relative to the repository root without directory traversal"},
+		{source: "/etc/policies", wantErr: "invalid Rego policy /etc/policies: must be relative to the repository root without directory traversal"},
+		{source: "http://example.com/policy.rego", wantErr: "invalid Rego policy http://example.com/policy.rego: URLs must use https"},
+		{source: "missing.rego", wantErr: "failed to read Rego policy missing.rego"},
+	} {
+		t.Run("invalid source "+tt.source, func(t *testing.T) {
+			calls, _ := stubRun(t, denyOutput, nil)
+
+			// method under test
+			_, err := Evaluate(context.Background(), workspace, []string{tt.source}, input)
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+			assert.Empty(t, *calls)
+		})
+	}
+}
+
+func TestRun_MissingCommand(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	_, err := runFunc(context.Background(), "opa", "eval")
+
+	require.Error(t, err)
+	assert.Equal(t, "the opa command is not installed on the runner", err.Error())
+}
+
+func TestSummary(t *testing.T) {
+	decision := Decision{
+		Deny: []Violation{{Rule: "OCI-1", Message: "linux | arm64\nbinary is required"}},
+		Warn: []Violation{{Rule: "warn", Message: "no tags set"}},
+	}
+
+	// method under test
+	summary := Summary("NRJavaAgent", "1.2.3", decision)
+
+	assert.Equal(t, "### Rego policies for NRJavaAgent 1.2.3\n\n"+
+		"| Result | Rule | Message |\n|--------|------|---------|\n"+
+		"| deny | `OCI-1` | linux \\| arm64 binary is required |\n"+
+		"| warn | `warn` | no tags set |\n", summary)
+	assert.Equal(t, "### Rego policies for NRJavaAgent 1.2.3\n\nNo deny or warn rules matched.\n", Summary("NRJavaAgent", "1.2.3", Decision{}))
+}
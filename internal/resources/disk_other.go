@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package resources
+
+// freeDiskSpace can't query free space on this platform
+func freeDiskSpace(dir string) (uint64, error) {
+	return 0, errDiskSpaceUnsupported
+}
@@ -0,0 +1,99 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"agent-metadata-action/internal/logging"
+)
+
+// errDiskSpaceUnsupported is returned by freeDiskSpace on platforms it can't query
+var errDiskSpaceUnsupported = errors.New("free disk space can't be queried on this platform")
+
+// freeDiskSpaceFunc is a variable that holds the function to look up the bytes available in a directory
+// This allows tests to override the implementation
+var freeDiskSpaceFunc = freeDiskSpace
+
+// CheckDiskSpace fails if dir doesn't have required bytes available, naming what needs them
+// Platforms where free space can't be queried are let through with a debug message
+func CheckDiskSpace(ctx context.Context, dir string, required int64, purpose string) error {
+	available, err := freeDiskSpaceFunc(dir)
+	if errors.Is(err, errDiskSpaceUnsupported) {
+		logging.Debugf(ctx, "Skipping the disk space check of %s: %v", dir, err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check the free disk space in %s: %w", dir, err)
+	}
+	if uint64(required) > available {
+		return fmt.Errorf("not enough disk space in %s for %s: %s needed, %s available - free up space on the runner or set TMPDIR to a larger volume",
+			dir, purpose, FormatBytes(uint64(required)), FormatBytes(available))
+	}
+	logging.Debugf(ctx, "%s available in %s, %s needed for %s", FormatBytes(available), dir, FormatBytes(uint64(required)), purpose)
+	return nil
+}
+
+// FormatBytes renders a byte count with a binary unit, e.g. "1.5 GiB"
+func FormatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// payloadWarnRatio is the share of the payload memory limit at which a warning is first printed
+const payloadWarnRatio = 0.8
+
+// PayloadBudget tracks the base64-encoded schemas and content a run holds in memory against a limit, warning once
+// as they approach it and once when they go over; safe for concurrent use
+type PayloadBudget struct {
+	limit    int64
+	used     atomic.Int64
+	warned   atomic.Bool
+	exceeded atomic.Bool
+}
+
+// NewPayloadBudget returns a budget of limit bytes
+func NewPayloadBudget(limit int64) *PayloadBudget {
+	return &PayloadBudget{limit: limit}
+}
+
+// Used returns the bytes added so far
+func (b *PayloadBudget) Used() int64 {
+	return b.used.Load()
+}
+
+func (b *PayloadBudget) add(ctx context.Context, n int64) {
+	used := b.used.Add(n)
+	switch {
+	case used > b.limit && b.exceeded.CompareAndSwap(false, true):
+		b.warned.Store(true)
+		logging.Warnf(ctx, "Encoded schemas and content now hold %s in memory, over the payload-memory-limit of %s - the runner may run out of memory",
+			FormatBytes(uint64(used)), FormatBytes(uint64(b.limit)))
+	case float64(used) >= payloadWarnRatio*float64(b.limit) && b.warned.CompareAndSwap(false, true):
+		logging.Warnf(ctx, "Encoded schemas and content now hold %s in memory, %.0f%% of the payload-memory-limit of %s",
+			FormatBytes(uint64(used)), 100*float64(used)/float64(b.limit), FormatBytes(uint64(b.limit)))
+	}
+}
+
+type payloadBudgetKey struct{}
+
+// WithPayloadBudget returns a context carrying the budget
+func WithPayloadBudget(ctx context.Context, budget *PayloadBudget) context.Context {
+	return context.WithValue(ctx, payloadBudgetKey{}, budget)
+}
+
+// AddPayload adds n bytes of encoded content to the budget in the context; a no-op without one
+func AddPayload(ctx context.Context, n int64) {
+	if budget, _ := ctx.Value(payloadBudgetKey{}).(*PayloadBudget); budget != nil {
+		budget.add(ctx, n)
+	}
+}
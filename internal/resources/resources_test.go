@@ -0,0 +1,109 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDiskSpace(t *testing.T) {
+	original := freeDiskSpaceFunc
+	t.Cleanup(func() { freeDiskSpaceFunc = original })
+
+	t.Run("enough space", func(t *testing.T) {
+		freeDiskSpaceFunc = func(string) (uint64, error) { return 2 << 30, nil }
+
+		// method under test
+		err := CheckDiskSpace(context.Background(), "/tmp", 1<<30, "copies of 2 binaries")
+
+		require.NoError(t, err)
+	})
+
+	t.Run("not enough space", func(t *testing.T) {
+		freeDiskSpaceFunc = func(string) (uint64, error) { return 512 << 20, nil }
+
+		// method under test
+		err := CheckDiskSpace(context.Background(), "/tmp", 3<<29, "copies of 2 binaries")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not enough disk space in /tmp for copies of 2 binaries: 1.5 GiB needed, 512.0 MiB available")
+	})
+
+	t.Run("unsupported platform", func(t *testing.T) {
+		freeDiskSpaceFunc = func(string) (uint64, error) { return 0, errDiskSpaceUnsupported }
+
+		// method under test
+		err := CheckDiskSpace(context.Background(), "/tmp", 1<<30, "copies of 2 binaries")
+
+		require.NoError(t, err)
+	})
+
+	t.Run("lookup fails", func(t *testing.T) {
+		freeDiskSpaceFunc = func(string) (uint64, error) { return 0, errors.New("no such directory") }
+
+		// method under test
+		err := CheckDiskSpace(context.Background(), "/missing", 1, "copies of 1 binaries")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to check the free disk space in /missing")
+	})
+}
+
+func TestFreeDiskSpace(t *testing.T) {
+	// method under test
+	available, err := freeDiskSpace(t.TempDir())
+
+	if errors.Is(err, errDiskSpaceUnsupported) {
+		t.Skip(err)
+	}
+	require.NoError(t, err)
+	assert.Positive(t, available)
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[uint64]string{
+		0:             "0 B",
+		1023:          "1023 B",
+		1024:          "1.0 KiB",
+		3 << 29:       "1.5 GiB",
+		5 << 40:       "5.0 TiB",
+		1<<20 + 1<<19: "1.5 MiB",
+	}
+	for n, expected := range tests {
+		// method under test
+		assert.Equal(t, expected, FormatBytes(n))
+	}
+}
+
+func TestAddPayload(t *testing.T) {
+	getStdout, _ := testutil.CaptureOutput(t)
+	budget := NewPayloadBudget(100)
+	ctx := WithPayloadBudget(context.Background(), budget)
+
+	// method under test
+	AddPayload(ctx, 50)
+	AddPayload(ctx, 30)
+	AddPayload(ctx, 10)
+	AddPayload(ctx, 20)
+	AddPayload(ctx, 20)
+
+	assert.Equal(t, int64(130), budget.Used())
+	stdout := getStdout()
+	assert.Equal(t, 1, strings.Count(stdout, "80% of the payload-memory-limit of 100 B"), stdout)
+	assert.Equal(t, 1, strings.Count(stdout, "over the payload-memory-limit of 100 B"), stdout)
+}
+
+func TestAddPayload_NoBudget(t *testing.T) {
+	getStdout, _ := testutil.CaptureOutput(t)
+
+	// method under test
+	AddPayload(context.Background(), 1<<40)
+
+	assert.Empty(t, getStdout())
+}
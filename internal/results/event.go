@@ -0,0 +1,59 @@
+package results
+
+import (
+	"strings"
+)
+
+// ReleaseEventType is the New Relic custom event type recorded at the end of each run
+const ReleaseEventType = "AgentMetadataRelease"
+
+// ReleaseEvent returns the attributes of the AgentMetadataRelease custom event for the results
+// Custom event attributes must be scalars, so artifacts are flattened into a comma-separated list of name=digest pairs
+func (r Results) ReleaseEvent() map[string]interface{} {
+	attributes := map[string]interface{}{
+		"agentType":  r.AgentType,
+		"version":    r.Version,
+		"mode":       r.Mode,
+		"dryRun":     r.DryRun,
+		"repository": r.Repository,
+		"sha":        r.SHA,
+		"actor":      r.Actor,
+		"runId":      r.RunID,
+		"outcome":    r.Outcome,
+	}
+	if r.Error != "" {
+		attributes["error"] = r.Error
+	}
+	if !r.StartedAt.IsZero() && !r.FinishedAt.IsZero() {
+		attributes["durationSeconds"] = r.FinishedAt.Sub(r.StartedAt).Seconds()
+	}
+
+	submitted, failed := 0, 0
+	for _, payload := range r.Payloads {
+		if payload.Submitted {
+			submitted++
+		}
+		if payload.Error != "" {
+			failed++
+		}
+	}
+	attributes["payloadsSubmitted"] = submitted
+	attributes["payloadsFailed"] = failed
+
+	digests := make([]string, 0, len(r.Artifacts))
+	for _, artifact := range r.Artifacts {
+		if artifact.Digest != "" {
+			digests = append(digests, artifact.Name+"="+artifact.Digest)
+		}
+	}
+	attributes["artifactCount"] = len(r.Artifacts)
+	if len(digests) > 0 {
+		attributes["artifactDigests"] = strings.Join(digests, ",")
+	}
+
+	if r.Index != nil {
+		attributes["indexDigest"] = r.Index.Digest
+		attributes["signed"] = r.Index.Signed
+	}
+	return attributes
+}
@@ -0,0 +1,553 @@
+package results
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"agent-metadata-action/internal/models"
+)
+
+// Run outcomes
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Run describes what a run was asked to do and where it ran
+type Run struct {
+	AgentType  string `json:"agentType,omitempty"`
+	Version    string `json:"version,omitempty"`
+	Mode       string `json:"mode,omitempty"`
+	DryRun     bool   `json:"dryRun"`
+	Repository string `json:"repository,omitempty"`
+	SHA        string `json:"sha,omitempty"`
+	Actor      string `json:"actor,omitempty"`
+	RunID      string `json:"runId,omitempty"`
+}
+
+// Results is the machine-readable record of a run written to the results file
+type Results struct {
+	Run
+	StartedAt   time.Time    `json:"startedAt"`
+	FinishedAt  time.Time    `json:"finishedAt"`
+	Outcome     string       `json:"outcome"`
+	Error       string       `json:"error,omitempty"`
+	Configs     *Configs     `json:"configs,omitempty"`
+	Payloads    []Payload    `json:"payloads"`
+	Artifacts   []Artifact   `json:"artifacts"`
+	Registry    *Registry    `json:"registry,omitempty"`
+	Index       *Index       `json:"index,omitempty"`
+	Policy      []Policy     `json:"policy,omitempty"`
+	Fixtures    []Fixture    `json:"fixtures,omitempty"`
+	Cleanup     []Cleanup    `json:"cleanup,omitempty"`
+	Scans       []Scan       `json:"scans,omitempty"`
+	Pins        *Pins        `json:"pins,omitempty"`
+	SchemaDocs  []string     `json:"schemaDocs,omitempty"`
+	PullRequest *PullRequest `json:"pullRequest,omitempty"`
+	Verify      []Verify     `json:"verify,omitempty"`
+	Rollback    []Rollback   `json:"rollback,omitempty"`
+}
+
+// Configs counts the definitions loaded from the config directory of an agent repository
+type Configs struct {
+	ConfigurationDefinitions int  `json:"configurationDefinitions"`
+	AgentControlDefinitions  int  `json:"agentControlDefinitions"`
+	AgentDefinition          bool `json:"agentDefinition"`
+}
+
+// Payload is the outcome of submitting metadata for one agent version
+// Submitted is false for dry runs and failures; Status is the reconciliation state in reconcile mode; Digest is the
+// SHA-256 digest of the canonical metadata, so a re-run can tell whether it submits the same metadata
+type Payload struct {
+	ID        string `json:"id,omitempty"`
+	AgentType string `json:"agentType"`
+	Version   string `json:"version"`
+	Source    string `json:"source,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Digest    string `json:"digest,omitempty"`
+	Submitted bool   `json:"submitted"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Artifact is the outcome of uploading or referencing one binary
+// Signed reports whether the manifest index listing the artifact was signed
+type Artifact struct {
+	Name            string   `json:"name"`
+	Path            string   `json:"path,omitempty"`
+	OS              string   `json:"os"`
+	Arch            string   `json:"arch"`
+	Digest          string   `json:"digest,omitempty"`
+	LayerDigest     string   `json:"layerDigest,omitempty"` // digest of the artifact file the upload pushed
+	Size            int64    `json:"size,omitempty"`
+	MediaType       string   `json:"mediaType,omitempty"`
+	Uploaded        bool     `json:"uploaded"`
+	Referenced      bool     `json:"referenced"`
+	Signed          bool     `json:"signed"`
+	SignatureID     string   `json:"signatureId,omitempty"` // Set when the manifest is signed on its own, by resign mode
+	LegalFiles      []string `json:"legalFiles,omitempty"`
+	Notarization    string   `json:"notarization,omitempty"`
+	ContentManifest string   `json:"contentManifest,omitempty"`
+	Error           string   `json:"error,omitempty"`
+	ErrorCode       string   `json:"errorCode,omitempty"` // registry error codes of a failed upload, e.g. DENIED (HTTP 403)
+}
+
+// Registry is how the OCI registry binaries were uploaded to was reached
+// PlainHTTP and InsecureSkipTLSVerify flag uploads that weren't protected by a verified TLS connection
+type Registry struct {
+	URL                   string `json:"url"`
+	PlainHTTP             bool   `json:"plainHttp"`
+	InsecureSkipTLSVerify bool   `json:"insecureSkipTlsVerify"`
+	ReferrersAPI          bool   `json:"referrersApi"`
+	ChunkMinLength        int64  `json:"chunkMinLength,omitempty"`
+}
+
+// Index is the multi-platform manifest index tagged with the agent version
+// PromotedFrom and Tags are set by promote mode: the pending tag the index was promoted from, and every tag it
+// was given; CopiedFrom by copy mode: the repository the index was copied from
+type Index struct {
+	Registry     string   `json:"registry"`
+	Tag          string   `json:"tag"`
+	Digest       string   `json:"digest"`
+	Signed       bool     `json:"signed"`
+	SignatureID  string   `json:"signatureId,omitempty"`
+	SigningError string   `json:"signingError,omitempty"`
+	PromotedFrom string   `json:"promotedFrom,omitempty"`
+	CopiedFrom   string   `json:"copiedFrom,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	ReleaseNotes string   `json:"releaseNotes,omitempty"` // Digest of the release notes referrer, if attached
+	ResignTask   string   `json:"resignTask,omitempty"`   // Resign task file written when signing was optional and failed
+	Layout       string   `json:"layout,omitempty"`       // OCI image layout directory the index was also written to
+}
+
+// Cleanup is an expired prerelease manifest index found by cleanup mode
+// Deleted is false for dry runs and failures
+type Cleanup struct {
+	Version string    `json:"version"`
+	Digest  string    `json:"digest"`
+	Tags    []string  `json:"tags"`
+	Created time.Time `json:"created"`
+	Deleted bool      `json:"deleted"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// Pins is the digest pin file written for the release
+type Pins struct {
+	Path string `json:"path"`
+}
+
+// PullRequest proposes the files generated for the release to a downstream repository
+// URL is empty when it couldn't be opened
+type PullRequest struct {
+	Repository string   `json:"repository"`
+	URL        string   `json:"url,omitempty"`
+	Files      []string `json:"files"`
+}
+
+// Scan is the verdict of a scanner on an artifact before upload
+// Verdict is clean or detected; it is empty when the scanner failed, with Error set
+type Scan struct {
+	Artifact string `json:"artifact"`
+	Scanner  string `json:"scanner"`
+	Verdict  string `json:"verdict,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Verify is the outcome of one check of verify mode against a published release
+type Verify struct {
+	Check  string `json:"check"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Rollback is the outcome of undoing one journaled operation of a failed run in rollback mode
+// Undone is false for dry runs, failures and operations there was nothing left to undo for, with Detail saying which
+type Rollback struct {
+	Operation string `json:"operation"`
+	Target    string `json:"target"`
+	Undone    bool   `json:"undone"`
+	Detail    string `json:"detail,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Policy is the outcome of evaluating one policy.yml rule against a release
+type Policy struct {
+	AgentType string `json:"agentType"`
+	Version   string `json:"version"`
+	Rule      string `json:"rule"`
+	Outcome   string `json:"outcome"`
+	Message   string `json:"message,omitempty"`
+}
+
+// Fixture is the outcome of validating a committed configuration sample against the schema of a configuration
+// definition of the release and the schema of the same definition in the previous release, if any
+type Fixture struct {
+	Path            string   `json:"path"`
+	Type            string   `json:"type"`
+	Platform        string   `json:"platform,omitempty"`
+	Outcome         string   `json:"outcome"`
+	PreviousRelease string   `json:"previousRelease,omitempty"`
+	Errors          []string `json:"errors,omitempty"`
+}
+
+// Recorder gathers phase outcomes during a run so they can be written as a single results file
+type Recorder struct {
+	mu      sync.Mutex
+	results Results
+}
+
+// NewRecorder creates a recorder for a run starting now
+func NewRecorder() *Recorder {
+	return &Recorder{results: Results{
+		StartedAt: time.Now().UTC(),
+		Payloads:  []Payload{},
+		Artifacts: []Artifact{},
+	}}
+}
+
+// SetRun records what the run was asked to do
+func (r *Recorder) SetRun(run Run) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results.Run = run
+}
+
+// Finish records the end of the run and its outcome
+func (r *Recorder) Finish(runErr error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results.FinishedAt = time.Now().UTC()
+	r.results.Outcome = OutcomeSuccess
+	r.results.Error = ""
+	if runErr != nil {
+		r.results.Outcome = OutcomeFailure
+		r.results.Error = runErr.Error()
+	}
+}
+
+// Results returns a copy of the recorded results
+func (r *Recorder) Results() Results {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	results := r.results
+	results.Payloads = append([]Payload{}, r.results.Payloads...)
+	results.Artifacts = append([]Artifact{}, r.results.Artifacts...)
+	if r.results.Policy != nil {
+		results.Policy = append([]Policy{}, r.results.Policy...)
+	}
+	if r.results.Cleanup != nil {
+		results.Cleanup = append([]Cleanup{}, r.results.Cleanup...)
+	}
+	if r.results.Scans != nil {
+		results.Scans = append([]Scan{}, r.results.Scans...)
+	}
+	if r.results.Verify != nil {
+		results.Verify = append([]Verify{}, r.results.Verify...)
+	}
+	if r.results.Rollback != nil {
+		results.Rollback = append([]Rollback{}, r.results.Rollback...)
+	}
+	if r.results.Configs != nil {
+		configs := *r.results.Configs
+		results.Configs = &configs
+	}
+	if r.results.Registry != nil {
+		registry := *r.results.Registry
+		results.Registry = &registry
+	}
+	if r.results.Pins != nil {
+		pins := *r.results.Pins
+		results.Pins = &pins
+	}
+	if r.results.PullRequest != nil {
+		pullRequest := *r.results.PullRequest
+		pullRequest.Files = append([]string(nil), r.results.PullRequest.Files...)
+		results.PullRequest = &pullRequest
+	}
+	if r.results.Index != nil {
+		index := *r.results.Index
+		index.Tags = append([]string(nil), r.results.Index.Tags...)
+		results.Index = &index
+	}
+	return results
+}
+
+// Write writes the recorded results as indented JSON to path, creating parent directories
+func (r *Recorder) Write(path string) error {
+	data, err := json.MarshalIndent(r.Results(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal results: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create results directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// Read reads a results file written by Write, such as the one of an earlier run
+func Read(path string) (Results, error) {
+	var results Results
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return results, err
+	}
+	if err := json.Unmarshal(data, &results); err != nil {
+		return results, fmt.Errorf("invalid results file %s: %w", path, err)
+	}
+	return results, nil
+}
+
+type recorderKey struct{}
+
+// WithRecorder returns a context carrying the recorder
+func WithRecorder(ctx context.Context, recorder *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, recorder)
+}
+
+// FromContext returns the recorder in the context, or nil
+func FromContext(ctx context.Context) *Recorder {
+	recorder, _ := ctx.Value(recorderKey{}).(*Recorder)
+	return recorder
+}
+
+// update applies fn to the results of the recorder in the context
+// No-op if the context has no recorder
+func update(ctx context.Context, fn func(*Results)) {
+	recorder := FromContext(ctx)
+	if recorder == nil {
+		return
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	fn(&recorder.results)
+}
+
+// RecordConfigs records the definitions loaded from the config directory
+func RecordConfigs(ctx context.Context, configs Configs) {
+	update(ctx, func(r *Results) {
+		r.Configs = &configs
+	})
+}
+
+// RecordPayload records the outcome of submitting metadata for an agent version
+func RecordPayload(ctx context.Context, payload Payload) {
+	update(ctx, func(r *Results) {
+		r.Payloads = append(r.Payloads, payload)
+	})
+}
+
+// RecordPolicy records the outcome of evaluating a policy rule against a release
+func RecordPolicy(ctx context.Context, policy Policy) {
+	update(ctx, func(r *Results) {
+		r.Policy = append(r.Policy, policy)
+	})
+}
+
+// RecordFixture records the outcome of validating a configuration sample
+func RecordFixture(ctx context.Context, fixture Fixture) {
+	update(ctx, func(r *Results) {
+		r.Fixtures = append(r.Fixtures, fixture)
+	})
+}
+
+// RecordVerify records the outcome of a verify mode check
+func RecordVerify(ctx context.Context, verify Verify) {
+	update(ctx, func(r *Results) {
+		r.Verify = append(r.Verify, verify)
+	})
+}
+
+// RecordArtifacts records the outcome of uploading or referencing each artifact
+func RecordArtifacts(ctx context.Context, uploads []models.ArtifactUploadResult) {
+	update(ctx, func(r *Results) {
+		for _, upload := range uploads {
+			r.Artifacts = append(r.Artifacts, Artifact{
+				Name:            upload.Name,
+				Path:            upload.Path,
+				OS:              upload.OS,
+				Arch:            upload.Arch,
+				Digest:          upload.Digest,
+				LayerDigest:     upload.LayerDigest,
+				Size:            upload.Size,
+				MediaType:       upload.MediaType,
+				Uploaded:        upload.Uploaded,
+				Referenced:      upload.Referenced,
+				LegalFiles:      upload.LegalFiles,
+				Notarization:    upload.Notarization,
+				ContentManifest: upload.ContentManifest,
+				Error:           upload.Error,
+				ErrorCode:       upload.ErrorCode,
+			})
+		}
+	})
+}
+
+// RecordRegistry records how the OCI registry was reached
+func RecordRegistry(ctx context.Context, registry Registry) {
+	update(ctx, func(r *Results) {
+		r.Registry = &registry
+	})
+}
+
+// RecordRegistryCapabilities records the capabilities probed on the registry before uploading
+func RecordRegistryCapabilities(ctx context.Context, referrersAPI bool, chunkMinLength int64) {
+	update(ctx, func(r *Results) {
+		if r.Registry == nil {
+			return
+		}
+		r.Registry.ReferrersAPI = referrersAPI
+		r.Registry.ChunkMinLength = chunkMinLength
+	})
+}
+
+// RecordCleanup records an expired prerelease manifest index found by cleanup mode
+func RecordCleanup(ctx context.Context, cleanup Cleanup) {
+	update(ctx, func(r *Results) {
+		r.Cleanup = append(r.Cleanup, cleanup)
+	})
+}
+
+// RecordScan records the verdict of a scanner on an artifact
+func RecordScan(ctx context.Context, scan Scan) {
+	update(ctx, func(r *Results) {
+		r.Scans = append(r.Scans, scan)
+	})
+}
+
+// RecordIndex records the manifest index created for the uploaded artifacts
+func RecordIndex(ctx context.Context, registry, tag, digest string) {
+	update(ctx, func(r *Results) {
+		r.Index = &Index{Registry: registry, Tag: tag, Digest: digest}
+	})
+}
+
+// RecordReleaseNotes records the digest of the release notes attached to the manifest index
+func RecordReleaseNotes(ctx context.Context, digest string) {
+	update(ctx, func(r *Results) {
+		if r.Index != nil {
+			r.Index.ReleaseNotes = digest
+		}
+	})
+}
+
+// RecordLayout records the OCI image layout directory the manifest index was written to
+func RecordLayout(ctx context.Context, layout string) {
+	update(ctx, func(r *Results) {
+		if r.Index != nil {
+			r.Index.Layout = layout
+		}
+	})
+}
+
+// RecordPins records the pin file written for the release
+func RecordPins(ctx context.Context, pins Pins) {
+	update(ctx, func(r *Results) {
+		r.Pins = &pins
+	})
+}
+
+// RecordSchemaDocs records the documentation files generated from the configuration schemas
+func RecordSchemaDocs(ctx context.Context, paths []string) {
+	update(ctx, func(r *Results) {
+		r.SchemaDocs = append(r.SchemaDocs, paths...)
+	})
+}
+
+// RecordPullRequest records the pull request proposing the generated files to a downstream repository
+func RecordPullRequest(ctx context.Context, pullRequest PullRequest) {
+	update(ctx, func(r *Results) {
+		r.PullRequest = &pullRequest
+	})
+}
+
+// RecordUpload records the registry, manifest index and artifacts an earlier run uploaded, whose release this run
+// continues
+func RecordUpload(ctx context.Context, earlier Results) {
+	update(ctx, func(r *Results) {
+		if earlier.Registry != nil {
+			registry := *earlier.Registry
+			r.Registry = &registry
+		}
+		if earlier.Index != nil {
+			index := *earlier.Index
+			index.Tags = append([]string(nil), earlier.Index.Tags...)
+			r.Index = &index
+		}
+		r.Artifacts = append(r.Artifacts, earlier.Artifacts...)
+	})
+}
+
+// RecordRollback records the outcome of undoing one journaled operation
+func RecordRollback(ctx context.Context, rollback Rollback) {
+	update(ctx, func(r *Results) {
+		r.Rollback = append(r.Rollback, rollback)
+	})
+}
+
+// RecordPromotion records a signed manifest index promoted from its pending tag to tags, the first being its version
+func RecordPromotion(ctx context.Context, registry, pendingTag string, tags []string, digest string) {
+	update(ctx, func(r *Results) {
+		r.Index = &Index{Registry: registry, Tag: tags[0], Digest: digest, Signed: true, PromotedFrom: pendingTag, Tags: tags}
+	})
+}
+
+// RecordCopy records a manifest index copied from another repository and tagged with its version
+// Signing is recorded separately, since the copy is signed again for its new location
+func RecordCopy(ctx context.Context, registry, tag, digest, source string) {
+	update(ctx, func(r *Results) {
+		r.Index = &Index{Registry: registry, Tag: tag, Digest: digest, CopiedFrom: source}
+	})
+}
+
+// RecordSigning records the outcome of signing the manifest index and the ID the signing service returned, if any
+// A signed index covers every artifact it lists
+func RecordSigning(ctx context.Context, signatureID string, signErr error) {
+	update(ctx, func(r *Results) {
+		if r.Index == nil {
+			return
+		}
+		if signErr != nil {
+			r.Index.SigningError = signErr.Error()
+			return
+		}
+		r.Index.Signed = true
+		r.Index.SignatureID = signatureID
+		for i := range r.Artifacts {
+			r.Artifacts[i].Signed = r.Artifacts[i].Uploaded
+		}
+	})
+}
+
+// RecordManifestSigning records the outcome of signing the manifest with digest on its own, as resign mode does
+func RecordManifestSigning(ctx context.Context, digest, signatureID string, signErr error) {
+	update(ctx, func(r *Results) {
+		for i := range r.Artifacts {
+			if r.Artifacts[i].Digest != digest {
+				continue
+			}
+			if signErr != nil {
+				r.Artifacts[i].Error = signErr.Error()
+				continue
+			}
+			r.Artifacts[i].Signed = true
+			r.Artifacts[i].SignatureID = signatureID
+		}
+	})
+}
+
+// RecordResignTask records the resign task file written for the index, relative to the workspace
+func RecordResignTask(ctx context.Context, path string) {
+	update(ctx, func(r *Results) {
+		if r.Index != nil {
+			r.Index.ResignTask = path
+		}
+	})
+}
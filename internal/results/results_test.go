@@ -0,0 +1,176 @@
+package results
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordWithoutRecorder(t *testing.T) {
+	ctx := context.Background()
+
+	// method under test - no-ops without a recorder in the context
+	RecordConfigs(ctx, Configs{ConfigurationDefinitions: 1})
+	RecordPayload(ctx, Payload{AgentType: "NRJavaAgent", Version: "1.2.3"})
+	RecordArtifacts(ctx, []models.ArtifactUploadResult{{Name: "linux"}})
+	RecordRegistry(ctx, Registry{URL: "docker.io/newrelic/agents"})
+	RecordRegistryCapabilities(ctx, true, 0)
+	RecordIndex(ctx, "docker.io/newrelic/agents", "1.2.3", "sha256:index")
+	RecordSigning(ctx, "", nil)
+	RecordPolicy(ctx, Policy{AgentType: "NRJavaAgent", Version: "1.2.3", Rule: "supported-os", Outcome: "pass"})
+
+	assert.Nil(t, FromContext(ctx))
+}
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder()
+	ctx := WithRecorder(context.Background(), recorder)
+	require.Same(t, recorder, FromContext(ctx))
+
+	recorder.SetRun(Run{AgentType: "NRJavaAgent", Version: "1.2.3", Repository: "newrelic/newrelic-java-agent", SHA: "abc123"})
+	RecordConfigs(ctx, Configs{ConfigurationDefinitions: 2, AgentControlDefinitions: 1, AgentDefinition: true})
+	RecordArtifacts(ctx, []models.ArtifactUploadResult{
+		{Name: "linux", Path: "./dist/linux.tar.gz", OS: "linux", Arch: "amd64", Digest: "sha256:linux", Size: 512, Uploaded: true, ContentManifest: "sha256:contents", Notarization: "notarized"},
+		{Name: "windows", OS: "windows", Arch: "amd64", Digest: "sha256:windows", Size: 256, Uploaded: true, Referenced: true},
+	})
+	RecordRegistry(ctx, Registry{URL: "registry.e2e.svc:5000/agents", PlainHTTP: true})
+	RecordRegistryCapabilities(ctx, true, 1024)
+	RecordIndex(ctx, "docker.io/newrelic/agents", "1.2.3", "sha256:index")
+	RecordReleaseNotes(ctx, "sha256:notes")
+	RecordSigning(ctx, "", nil)
+	RecordPolicy(ctx, Policy{AgentType: "NRJavaAgent", Version: "1.2.3", Rule: "supported-os", Outcome: "pass", Message: "ships binaries for linux"})
+	RecordPayload(ctx, Payload{AgentType: "NRJavaAgent", Version: "1.2.3", Source: ".fleetControl", Submitted: true})
+	RecordCleanup(ctx, Cleanup{Version: "1.2.2-beta.1", Digest: "sha256:old", Tags: []string{"1.2.2-beta.1"}, Deleted: true})
+	RecordScan(ctx, Scan{Artifact: "linux", Scanner: "clamscan", Verdict: "clean"})
+	RecordRollback(ctx, Rollback{Operation: "index", Target: "docker.io/newrelic/agents@sha256:old", Undone: true, Detail: "deleted with the tag 1.2.2"})
+	RecordFixture(ctx, Fixture{Path: ".fleetControl/fixtures/agent-config/minimal.yml", Type: "agent-config", Outcome: "breaks", PreviousRelease: "v1.2.2"})
+	recorder.Finish(nil)
+
+	// method under test
+	recorded := recorder.Results()
+
+	assert.Equal(t, OutcomeSuccess, recorded.Outcome)
+	assert.Empty(t, recorded.Error)
+	assert.False(t, recorded.FinishedAt.Before(recorded.StartedAt))
+	assert.Equal(t, &Configs{ConfigurationDefinitions: 2, AgentControlDefinitions: 1, AgentDefinition: true}, recorded.Configs)
+	assert.Equal(t, &Registry{URL: "registry.e2e.svc:5000/agents", PlainHTTP: true, ReferrersAPI: true, ChunkMinLength: 1024}, recorded.Registry)
+	assert.Equal(t, &Index{Registry: "docker.io/newrelic/agents", Tag: "1.2.3", Digest: "sha256:index", Signed: true, ReleaseNotes: "sha256:notes"}, recorded.Index)
+	require.Len(t, recorded.Artifacts, 2)
+	assert.True(t, recorded.Artifacts[0].Signed)
+	assert.Equal(t, "sha256:contents", recorded.Artifacts[0].ContentManifest)
+	assert.Equal(t, "notarized", recorded.Artifacts[0].Notarization)
+	assert.True(t, recorded.Artifacts[1].Referenced)
+	require.Len(t, recorded.Payloads, 1)
+	assert.True(t, recorded.Payloads[0].Submitted)
+	assert.Equal(t, []Policy{{AgentType: "NRJavaAgent", Version: "1.2.3", Rule: "supported-os", Outcome: "pass", Message: "ships binaries for linux"}}, recorded.Policy)
+	assert.Equal(t, []Cleanup{{Version: "1.2.2-beta.1", Digest: "sha256:old", Tags: []string{"1.2.2-beta.1"}, Deleted: true}}, recorded.Cleanup)
+	assert.Equal(t, []Scan{{Artifact: "linux", Scanner: "clamscan", Verdict: "clean"}}, recorded.Scans)
+	assert.Equal(t, []Rollback{{Operation: "index", Target: "docker.io/newrelic/agents@sha256:old", Undone: true, Detail: "deleted with the tag 1.2.2"}}, recorded.Rollback)
+	require.Len(t, recorded.Fixtures, 1)
+	assert.Equal(t, "breaks", recorded.Fixtures[0].Outcome)
+}
+
+func TestRecordSigning_Failure(t *testing.T) {
+	recorder := NewRecorder()
+	ctx := WithRecorder(context.Background(), recorder)
+	RecordArtifacts(ctx, []models.ArtifactUploadResult{{Name: "linux", Digest: "sha256:linux", Uploaded: true}})
+	RecordIndex(ctx, "docker.io/newrelic/agents", "1.2.3", "sha256:index")
+
+	// method under test
+	RecordSigning(ctx, "", assert.AnError)
+
+	recorded := recorder.Results()
+	assert.False(t, recorded.Index.Signed)
+	assert.Equal(t, assert.AnError.Error(), recorded.Index.SigningError)
+	assert.False(t, recorded.Artifacts[0].Signed)
+}
+
+func TestRecordUpload(t *testing.T) {
+	recorder := NewRecorder()
+	ctx := WithRecorder(context.Background(), recorder)
+	earlier := Results{
+		Registry:  &Registry{URL: "docker.io/newrelic/agents"},
+		Index:     &Index{Registry: "docker.io/newrelic/agents", Tag: "1.2.3", Digest: "sha256:index"},
+		Artifacts: []Artifact{{Name: "linux", Digest: "sha256:linux", Uploaded: true}},
+	}
+
+	// method under test
+	RecordUpload(ctx, earlier)
+	RecordSigning(ctx, "sig-1", nil)
+
+	recorded := recorder.Results()
+	assert.Equal(t, "docker.io/newrelic/agents", recorded.Registry.URL)
+	assert.Equal(t, "sha256:index", recorded.Index.Digest)
+	assert.True(t, recorded.Index.Signed)
+	assert.True(t, recorded.Artifacts[0].Signed)
+	assert.False(t, earlier.Index.Signed, "The earlier results are left as read")
+}
+
+func TestRecorder_Write(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.Finish(assert.AnError)
+	path := filepath.Join(t.TempDir(), "nested", "results.json")
+
+	// method under test
+	require.NoError(t, recorder.Write(path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var written map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &written))
+	assert.Equal(t, OutcomeFailure, written["outcome"])
+	assert.Equal(t, assert.AnError.Error(), written["error"])
+	assert.Equal(t, []interface{}{}, written["payloads"])
+	assert.Equal(t, []interface{}{}, written["artifacts"])
+	assert.NotContains(t, written, "index")
+}
+
+func TestReleaseEvent(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.SetRun(Run{
+		AgentType:  "NRJavaAgent",
+		Version:    "1.2.3",
+		Repository: "newrelic/newrelic-java-agent",
+		Actor:      "octocat",
+		RunID:      "42",
+	})
+	ctx := WithRecorder(context.Background(), recorder)
+	RecordArtifacts(ctx, []models.ArtifactUploadResult{
+		{Name: "linux", Digest: "sha256:linux", Uploaded: true},
+		{Name: "windows", Digest: "sha256:windows", Uploaded: true},
+	})
+	RecordIndex(ctx, "docker.io/newrelic/agents", "1.2.3", "sha256:index")
+	RecordSigning(ctx, "", nil)
+	RecordPayload(ctx, Payload{AgentType: "NRJavaAgent", Version: "1.2.3", Submitted: true})
+	recorder.Finish(nil)
+
+	// method under test
+	event := recorder.Results().ReleaseEvent()
+
+	assert.Equal(t, "NRJavaAgent", event["agentType"])
+	assert.Equal(t, "1.2.3", event["version"])
+	assert.Equal(t, "octocat", event["actor"])
+	assert.Equal(t, "newrelic/newrelic-java-agent", event["repository"])
+	assert.Equal(t, "42", event["runId"])
+	assert.Equal(t, OutcomeSuccess, event["outcome"])
+	assert.NotContains(t, event, "error")
+	assert.Equal(t, 1, event["payloadsSubmitted"])
+	assert.Equal(t, 2, event["artifactCount"])
+	assert.Equal(t, "linux=sha256:linux,windows=sha256:windows", event["artifactDigests"])
+	assert.Equal(t, "sha256:index", event["indexDigest"])
+	assert.Equal(t, true, event["signed"])
+	for name, value := range event {
+		switch value.(type) {
+		case string, bool, int, float64:
+		default:
+			t.Errorf("attribute %s has non-scalar type %T", name, value)
+		}
+	}
+}
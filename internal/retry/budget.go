@@ -0,0 +1,56 @@
+package retry
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Budget caps the time a run spends retrying, shared by every Do call whose context carries it
+// Waiting before a retry and running the retried attempt are charged to it; first attempts are free
+// Safe for concurrent use
+type Budget struct {
+	mu    sync.Mutex
+	total time.Duration
+	spent time.Duration
+}
+
+// NewBudget returns a budget of total retry time
+func NewBudget(total time.Duration) *Budget {
+	return &Budget{total: total}
+}
+
+// Total returns the retry time the budget started with
+func (b *Budget) Total() time.Duration {
+	return b.total
+}
+
+// Remaining returns the retry time left, never negative
+func (b *Budget) Remaining() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.spent >= b.total {
+		return 0
+	}
+	return b.total - b.spent
+}
+
+// spend charges d to the budget
+func (b *Budget) spend(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.spent += d
+}
+
+type budgetKey struct{}
+
+// WithBudget returns a context whose retries are charged to budget
+func WithBudget(ctx context.Context, budget *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, budget)
+}
+
+// BudgetFromContext returns the budget in the context, or nil if retries are unlimited
+func BudgetFromContext(ctx context.Context) *Budget {
+	budget, _ := ctx.Value(budgetKey{}).(*Budget)
+	return budget
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/metrics"
 )
 
 // NonRetryableError wraps an error that should not be retried
@@ -55,17 +56,30 @@ func DefaultConfig() Config {
 // Do executes a function with retry logic
 // The function should return an error to trigger a retry
 // Returns nil on success, or the last error if all retries fail
+// If the context carries a Budget, retries stop early once waiting for the next one would exceed it
 func Do(ctx context.Context, config Config, fn func() error) error {
 	if config.MaxAttempts < 1 {
 		config.MaxAttempts = 1
 	}
+	budget := BudgetFromContext(ctx)
 
 	var lastErr error
 	for attempt := 1; attempt <= config.MaxAttempts; attempt++ {
+		start := time.Now()
+
 		// Add delay before retry (not on first attempt)
 		if attempt > 1 {
 			delay := time.Duration(attempt-1) * config.BaseDelay
-			logging.Debugf(ctx, "Retry attempt %d/%d after %s delay...", attempt, config.MaxAttempts, delay)
+			if budget != nil {
+				remaining := budget.Remaining()
+				if delay >= remaining {
+					logging.Warnf(ctx, "%s: retry budget of %s exhausted (%s left) - not retrying", config.Operation, budget.Total(), remaining)
+					return fmt.Errorf("failed %s after %d attempts, retry budget of %s exhausted: %w", config.Operation, attempt-1, budget.Total(), lastErr)
+				}
+				logging.Debugf(ctx, "Retry attempt %d/%d after %s delay (retry budget remaining: %s)...", attempt, config.MaxAttempts, delay, remaining)
+			} else {
+				logging.Debugf(ctx, "Retry attempt %d/%d after %s delay...", attempt, config.MaxAttempts, delay)
+			}
 
 			select {
 			case <-time.After(delay):
@@ -73,10 +87,14 @@ func Do(ctx context.Context, config Config, fn func() error) error {
 			case <-ctx.Done():
 				return fmt.Errorf("retry cancelled: %w", ctx.Err())
 			}
+			metrics.Add(ctx, metrics.Retries, 1)
 		}
 
 		// Execute the function
 		lastErr = fn()
+		if attempt > 1 && budget != nil {
+			budget.spend(time.Since(start))
+		}
 		if lastErr == nil {
 			// Success!
 			if attempt > 1 {
@@ -6,6 +6,8 @@ import (
 	"testing"
 	"time"
 
+	"agent-metadata-action/internal/metrics"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -266,3 +268,86 @@ func TestDo_NonRetryableError_SecondAttempt(t *testing.T) {
 	assert.True(t, IsNonRetryable(err))
 	assert.Contains(t, err.Error(), "permanent error")
 }
+
+func TestDo_BudgetExhausted(t *testing.T) {
+	budget := NewBudget(50 * time.Millisecond)
+	ctx := WithBudget(context.Background(), budget)
+	config := Config{
+		MaxAttempts: 5,
+		BaseDelay:   20 * time.Millisecond,
+		Operation:   "test operation",
+	}
+
+	callCount := 0
+	fn := func() error {
+		callCount++
+		return errors.New("failure")
+	}
+
+	err := Do(ctx, config, fn)
+
+	require.Error(t, err)
+	// 20ms before attempt 2 fits the budget; 40ms before attempt 3 doesn't fit the ~30ms left
+	assert.Equal(t, 2, callCount, "Should stop retrying once the budget can't cover the next delay")
+	assert.Contains(t, err.Error(), "failed test operation after 2 attempts, retry budget of 50ms exhausted")
+	assert.Contains(t, err.Error(), "failure")
+	assert.Less(t, budget.Remaining(), 40*time.Millisecond)
+}
+
+func TestDo_BudgetSharedAcrossCalls(t *testing.T) {
+	budget := NewBudget(30 * time.Millisecond)
+	ctx := WithBudget(context.Background(), budget)
+	config := Config{
+		MaxAttempts: 2,
+		BaseDelay:   20 * time.Millisecond,
+		Operation:   "test operation",
+	}
+
+	callCount := 0
+	failOnce := func() error {
+		callCount++
+		if callCount%2 == 1 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	}
+
+	require.NoError(t, Do(ctx, config, failOnce))
+	err := Do(ctx, config, failOnce)
+
+	require.Error(t, err)
+	assert.Equal(t, 3, callCount, "The second call should not retry with the budget spent by the first")
+	assert.Contains(t, err.Error(), "retry budget of 30ms exhausted")
+}
+
+func TestDo_FirstAttemptsAreFree(t *testing.T) {
+	budget := NewBudget(time.Second)
+	ctx := WithBudget(context.Background(), budget)
+
+	err := Do(ctx, DefaultConfig(), func() error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, budget.Remaining())
+}
+
+func TestDo_CountsRetries(t *testing.T) {
+	registry := metrics.NewRegistry("")
+	ctx := metrics.WithRegistry(context.Background(), registry)
+	config := Config{MaxAttempts: 3, BaseDelay: time.Millisecond, Operation: "test operation"}
+	callCount := 0
+
+	// method under test
+	err := Do(ctx, config, func() error {
+		callCount++
+		if callCount < 3 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), registry.Value(metrics.Retries), "The first attempt isn't a retry")
+}
@@ -0,0 +1,161 @@
+// Package sanitize cleans the free text of metadata so malformed content can't break the fleet UIs that show it
+// or inject terminal escape sequences into workflow logs
+package sanitize
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"agent-metadata-action/internal/github"
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+)
+
+// ansiEscape matches CSI sequences (colors, cursor movement), OSC sequences (titles, hyperlinks) and
+// two-character escapes
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-?]*[ -/]*[@-~]|\x1b\\][^\x07\x1b]*(?:\x07|\x1b\\\\)|\x1b[@-Z\\\\-_]")
+
+// Fields holding base64 content rather than text, which are never altered
+var encodedFields = map[string]bool{"schema": true, "content": true}
+
+// Change describes how the value of a field was altered
+type Change struct {
+	Field   string
+	Reasons []string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s %s", c.Field, strings.Join(c.Reasons, ", "))
+}
+
+// String returns s as valid UTF-8 with ANSI escape sequences, control characters other than newline and tab,
+// and bidirectional overrides removed, \r\n and \r line endings normalized to \n, and truncated to maxLength
+// characters (no limit when maxLength is 0)
+// The reasons describe each alteration; normalized line endings alone aren't reported
+func String(s string, maxLength int) (string, []string) {
+	var reasons []string
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, string(utf8.RuneError))
+		reasons = append(reasons, "was not valid UTF-8")
+	}
+	if ansiEscape.MatchString(s) {
+		s = ansiEscape.ReplaceAllString(s, "")
+		reasons = append(reasons, "contained ANSI escape sequences")
+	}
+	s = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(s)
+
+	removed := 0
+	s = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || isBidiControl(r) {
+			removed++
+			return -1
+		}
+		return r
+	}, s)
+	if removed > 0 {
+		reasons = append(reasons, fmt.Sprintf("contained %d control character(s)", removed))
+	}
+
+	if maxLength > 0 && utf8.RuneCountInString(s) > maxLength {
+		runes := []rune(s)
+		s = string(runes[:maxLength-1]) + "…"
+		reasons = append(reasons, fmt.Sprintf("was truncated from %d to %d characters", len(runes), maxLength))
+	}
+	return s, reasons
+}
+
+// isBidiControl reports whether r is a bidirectional embedding, override or isolate, which can make text
+// display differently from what it contains
+func isBidiControl(r rune) bool {
+	return (r >= '\u202A' && r <= '\u202E') || (r >= '\u2066' && r <= '\u2069')
+}
+
+// Value sanitizes every string in a decoded YAML or JSON value, recursing into maps and lists
+// field names the value in the returned changes
+func Value(field string, value interface{}, maxLength int) (interface{}, []Change) {
+	switch v := value.(type) {
+	case string:
+		sanitized, reasons := String(v, maxLength)
+		if len(reasons) == 0 {
+			return sanitized, nil
+		}
+		return sanitized, []Change{{Field: field, Reasons: reasons}}
+	case map[string]interface{}:
+		return v, sanitizeMap(field+".", v, maxLength)
+	case map[string]string:
+		var changes []Change
+		for _, key := range sortedKeys(v) {
+			sanitized, reasons := String(v[key], maxLength)
+			v[key] = sanitized
+			if len(reasons) > 0 {
+				changes = append(changes, Change{Field: field + "." + key, Reasons: reasons})
+			}
+		}
+		return v, changes
+	case []interface{}:
+		var changes []Change
+		for i, item := range v {
+			var itemChanges []Change
+			v[i], itemChanges = Value(fmt.Sprintf("%s[%d]", field, i), item, maxLength)
+			changes = append(changes, itemChanges...)
+		}
+		return v, changes
+	}
+	return value, nil
+}
+
+// sanitizeMap sanitizes the values of m in place, in key order, skipping encoded fields
+func sanitizeMap(prefix string, m map[string]interface{}, maxLength int) []Change {
+	var changes []Change
+	for _, key := range sortedKeys(m) {
+		if encodedFields[key] {
+			continue
+		}
+		var fieldChanges []Change
+		m[key], fieldChanges = Value(prefix+key, m[key], maxLength)
+		changes = append(changes, fieldChanges...)
+	}
+	return changes
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Metadata sanitizes metadata fields in place
+func Metadata(metadata models.Metadata, maxLength int) []Change {
+	return sanitizeMap("", metadata, maxLength)
+}
+
+// Definitions sanitizes every definition field in place except the encoded schema and content
+// name is the definition list's field, such as configurationDefinitions
+func Definitions[T ~map[string]interface{}](name string, definitions []T, maxLength int) []Change {
+	var changes []Change
+	for i, definition := range definitions {
+		changes = append(changes, sanitizeMap(fmt.Sprintf("%s[%d].", name, i), definition, maxLength)...)
+	}
+	return changes
+}
+
+// Report warns about each change, annotating path when it is set
+func Report(ctx context.Context, path string, changes []Change) {
+	for _, change := range changes {
+		logging.Warnf(ctx, "Sanitized %s", change)
+		if path != "" {
+			github.AddAnnotation(ctx, github.AnnotationWarning, path, "Text sanitized", change.String())
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package sanitize
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/github"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		maxLength int
+		expected  string
+		reasons   []string
+	}{
+		{name: "clean text", value: "Adds support for Java 21\n\tand more", expected: "Adds support for Java 21\n\tand more"},
+		{name: "line endings normalized silently", value: "first\r\nsecond\rthird", expected: "first\nsecond\nthird"},
+		{name: "color codes", value: "\x1b[1;31mred\x1b[0m text", expected: "red text", reasons: []string{"contained ANSI escape sequences"}},
+		{name: "hyperlink", value: "\x1b]8;;https://example.com\x07link\x1b]8;;\x07", expected: "link", reasons: []string{"contained ANSI escape sequences"}},
+		{name: "control characters", value: "null\x00 bell\x07 del\x7f", expected: "null bell del", reasons: []string{"contained 3 control character(s)"}},
+		{name: "bidirectional override", value: "invoice\u202efdp.exe", expected: "invoicefdp.exe", reasons: []string{"contained 1 control character(s)"}},
+		{name: "invalid UTF-8", value: "caf\xe9", expected: "caf\uFFFD", reasons: []string{"was not valid UTF-8"}},
+		{name: "truncated by characters", value: "héllo wörld", maxLength: 5, expected: "héll…", reasons: []string{"was truncated from 11 to 5 characters"}},
+		{name: "within limit", value: "héllo", maxLength: 5, expected: "héllo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			sanitized, reasons := String(tt.value, tt.maxLength)
+
+			assert.Equal(t, tt.expected, sanitized)
+			assert.Equal(t, tt.reasons, reasons)
+		})
+	}
+}
+
+func TestDefinitions_SkipsEncodedFields(t *testing.T) {
+	schema := strings.Repeat("e30=", 10)
+	definitions := []models.ConfigurationDefinition{
+		{"platform": "ALL", "description": "Agent\x1b[2J configuration", "schema": schema},
+		{"platform": "HOST", "x-notes": []interface{}{"ok", "bell\x07"}},
+	}
+
+	// method under test
+	changes := Definitions("configurationDefinitions", definitions, 10)
+
+	assert.Equal(t, "Agent con…", definitions[0]["description"])
+	assert.Equal(t, schema, definitions[0]["schema"])
+	assert.Equal(t, []interface{}{"ok", "bell"}, definitions[1]["x-notes"])
+	require.Len(t, changes, 2)
+	assert.Equal(t, "configurationDefinitions[0].description contained ANSI escape sequences, was truncated from 19 to 10 characters", changes[0].String())
+	assert.Equal(t, "configurationDefinitions[1].x-notes[1]", changes[1].Field)
+}
+
+func TestMetadata(t *testing.T) {
+	metadata := models.Metadata{
+		"version":     "1.2.3",
+		"displayName": "Java\x1b[0m",
+		"tags":        map[string]string{"team": "apm\x00"},
+		"security":    map[string]interface{}{"cves": []interface{}{"CVE-2024-0001\r\n"}},
+		"releaseDate": 20240101,
+	}
+
+	// method under test
+	changes := Metadata(metadata, 0)
+
+	assert.Equal(t, "Java", metadata["displayName"])
+	assert.Equal(t, map[string]string{"team": "apm"}, metadata["tags"])
+	assert.Equal(t, []interface{}{"CVE-2024-0001\n"}, metadata["security"].(map[string]interface{})["cves"])
+	assert.Equal(t, 20240101, metadata["releaseDate"])
+	require.Len(t, changes, 2)
+	assert.Equal(t, "displayName", changes[0].Field)
+	assert.Equal(t, "tags.team", changes[1].Field)
+}
+
+func TestReport(t *testing.T) {
+	getStdout, _ := testutil.CaptureOutput(t)
+	annotations := github.NewAnnotationCollector()
+	ctx := github.WithAnnotationCollector(context.Background(), annotations)
+	changes := []Change{{Field: "description", Reasons: []string{"contained 1 control character(s)"}}}
+
+	// method under test
+	Report(ctx, ".fleetControl/configurationDefinitions.yml", changes)
+	Report(ctx, "", changes)
+
+	assert.Equal(t, 2, strings.Count(getStdout(), "::warn::Sanitized description contained 1 control character(s)"))
+	require.Len(t, annotations.Annotations(), 1)
+	assert.Equal(t, github.AnnotationWarning, annotations.Annotations()[0].AnnotationLevel)
+	assert.Equal(t, ".fleetControl/configurationDefinitions.yml", annotations.Annotations()[0].Path)
+}
@@ -0,0 +1,163 @@
+package sarif
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Version is the SARIF version written, the one code scanning accepts
+const Version = "2.1.0"
+
+// Result levels
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelNote    = "note"
+	LevelNone    = "none"
+)
+
+// Rule describes a kind of finding
+type Rule struct {
+	ID          string
+	Description string
+	Level       string // default level of the rule's results
+}
+
+// Result is a single finding at a file and, if known, a line
+// Path is relative to the repository root
+type Result struct {
+	RuleID  string
+	Level   string
+	Path    string
+	Line    int
+	Column  int
+	Message string
+}
+
+// SARIF 2.1.0 log, reduced to what code scanning reads
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// Marshal returns a SARIF log of the results, produced by this action
+// Results of rules that aren't listed are added with their rule ID as the description
+func Marshal(rules []Rule, results []Result) ([]byte, error) {
+	driver := sarifDriver{
+		Name:           "agent-metadata-action",
+		InformationURI: "https://github.com/newrelic/agent-metadata-action",
+		Rules:          []sarifRule{},
+	}
+	ruleIndex := map[string]int{}
+	addRule := func(rule Rule) {
+		if _, ok := ruleIndex[rule.ID]; ok {
+			return
+		}
+		ruleIndex[rule.ID] = len(driver.Rules)
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:                   rule.ID,
+			ShortDescription:     sarifMessage{Text: rule.Description},
+			DefaultConfiguration: sarifConfiguration{Level: rule.Level},
+		})
+	}
+	for _, rule := range rules {
+		addRule(rule)
+	}
+
+	converted := make([]sarifResult, 0, len(results))
+	for _, result := range results {
+		addRule(Rule{ID: result.RuleID, Description: result.RuleID, Level: result.Level})
+		location := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(result.Path), URIBaseID: "%SRCROOT%"}}
+		if result.Line > 0 {
+			location.Region = &sarifRegion{StartLine: result.Line, StartColumn: result.Column}
+		}
+		converted = append(converted, sarifResult{
+			RuleID:    result.RuleID,
+			RuleIndex: ruleIndex[result.RuleID],
+			Level:     result.Level,
+			Message:   sarifMessage{Text: result.Message},
+			Locations: []sarifLocation{{PhysicalLocation: location}},
+		})
+	}
+
+	return json.MarshalIndent(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: Version,
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: converted}},
+	}, "", "  ")
+}
+
+// Write writes a SARIF log of the results to path, creating parent directories
+func Write(path string, rules []Rule, results []Result) error {
+	data, err := Marshal(rules, results)
+	if err != nil {
+		return fmt.Errorf("failed to marshal SARIF: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create SARIF directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package sarif
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "findings.sarif")
+	rules := []Rule{
+		{ID: "lint/enum-naming", Description: "Enum values follow the naming convention", Level: LevelWarning},
+		{ID: "lint/property-title", Description: "Properties have titles", Level: LevelNone},
+	}
+	results := []Result{
+		{RuleID: "lint/enum-naming", Level: LevelError, Path: ".fleetControl/schemas/config.json", Line: 9, Column: 28, Message: "bad enum"},
+		{RuleID: "missing-version", Level: LevelError, Path: "src/content/docs/release-notes/java.mdx", Message: "version is required"},
+	}
+
+	// method under test
+	require.NoError(t, Write(path, rules, results))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var log sarifLog
+	require.NoError(t, json.Unmarshal(data, &log))
+
+	assert.Equal(t, Version, log.Version)
+	require.Len(t, log.Runs, 1)
+	run := log.Runs[0]
+	// Rules without a description are added from their results
+	require.Len(t, run.Tool.Driver.Rules, 3)
+	assert.Equal(t, "missing-version", run.Tool.Driver.Rules[2].ID)
+	assert.Equal(t, LevelNone, run.Tool.Driver.Rules[1].DefaultConfiguration.Level)
+
+	require.Len(t, run.Results, 2)
+	assert.Equal(t, 0, run.Results[0].RuleIndex)
+	assert.Equal(t, 2, run.Results[1].RuleIndex)
+	location := run.Results[0].Locations[0].PhysicalLocation
+	assert.Equal(t, ".fleetControl/schemas/config.json", location.ArtifactLocation.URI)
+	assert.Equal(t, "%SRCROOT%", location.ArtifactLocation.URIBaseID)
+	assert.Equal(t, &sarifRegion{StartLine: 9, StartColumn: 28}, location.Region)
+	assert.Nil(t, run.Results[1].Locations[0].PhysicalLocation.Region)
+}
+
+func TestMarshal_NoResults(t *testing.T) {
+	data, err := Marshal(nil, nil)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"results": []`)
+}
@@ -0,0 +1,190 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Verdicts a scanner reaches on an artifact
+const (
+	VerdictClean    = "clean"
+	VerdictDetected = "detected"
+)
+
+// PathPlaceholder is replaced with the artifact path in scan-command; without it the path is appended
+const PathPlaceholder = "{}"
+
+// maxDetailLength caps the scanner output kept as the detail of a detection
+const maxDetailLength = 1000
+
+// Result is the verdict of a scanner on an artifact, with what it detected
+type Result struct {
+	Verdict string
+	Detail  string
+}
+
+// Scanner scans an artifact file before it is uploaded
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, path string) (Result, error)
+}
+
+// New returns the scanners configured by the scan-command and scan-url inputs, none if both are empty
+func New(command, url, token string) ([]Scanner, error) {
+	var scanners []Scanner
+	if strings.TrimSpace(command) != "" {
+		scanners = append(scanners, NewCommandScanner(command))
+	}
+	if url = strings.TrimSpace(url); url != "" {
+		if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://localhost") && !strings.HasPrefix(url, "http://127.0.0.1") {
+			return nil, fmt.Errorf("invalid scan-url %q: must be an https:// URL", url)
+		}
+		scanners = append(scanners, NewServiceScanner(url, token))
+	}
+	return scanners, nil
+}
+
+// runFunc runs a scanner command and returns its exit code and combined output
+// A command that couldn't be run, or was killed, is returned as an error
+// This allows tests to override the implementation
+var runFunc = func(ctx context.Context, name string, args ...string) (int, []byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return exitErr.ExitCode(), output.Bytes(), nil
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return 0, nil, fmt.Errorf("the %s command is not installed on the runner", name)
+	}
+	return 0, output.Bytes(), err
+}
+
+// CommandScanner runs a scanner CLI, such as clamscan, on each artifact
+// Exit status 0 means clean and 1 a detection, as with clamscan; any other status is a scanner error
+type CommandScanner struct {
+	args []string
+}
+
+// NewCommandScanner returns a scanner running command, split on spaces, with the artifact path in place of {} or
+// appended
+func NewCommandScanner(command string) *CommandScanner {
+	return &CommandScanner{args: strings.Fields(command)}
+}
+
+func (s *CommandScanner) Name() string {
+	return s.args[0]
+}
+
+func (s *CommandScanner) Scan(ctx context.Context, path string) (Result, error) {
+	args := make([]string, 0, len(s.args))
+	substituted := false
+	for _, arg := range s.args[1:] {
+		if strings.Contains(arg, PathPlaceholder) {
+			arg = strings.ReplaceAll(arg, PathPlaceholder, path)
+			substituted = true
+		}
+		args = append(args, arg)
+	}
+	if !substituted {
+		args = append(args, path)
+	}
+
+	code, output, err := runFunc(ctx, s.args[0], args...)
+	if err != nil {
+		return Result{}, err
+	}
+	switch code {
+	case 0:
+		return Result{Verdict: VerdictClean}, nil
+	case 1:
+		return Result{Verdict: VerdictDetected, Detail: truncate(strings.TrimSpace(string(output)))}, nil
+	default:
+		return Result{}, fmt.Errorf("%s exited with status %d: %s", s.args[0], code, truncate(strings.TrimSpace(string(output))))
+	}
+}
+
+// ServiceScanner posts each artifact to a scanning service
+// The service answers 200 with {"verdict": "clean"} or {"verdict": "detected", "detail": "<what was found>"}
+type ServiceScanner struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+// NewServiceScanner returns a scanner posting artifacts to url, with token as a Bearer token if set
+func NewServiceScanner(url, token string) *ServiceScanner {
+	return &ServiceScanner{
+		url:   url,
+		token: token,
+		// Large agent bundles take a while to upload and scan
+		httpClient: &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+func (s *ServiceScanner) Name() string {
+	return s.url
+}
+
+func (s *ServiceScanner) Scan(ctx context.Context, path string) (Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer file.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, file)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("scan request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read scan response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("scanning service returned status %d: %s", resp.StatusCode, truncate(strings.TrimSpace(string(body))))
+	}
+
+	var response struct {
+		Verdict string `json:"verdict"`
+		Detail  string `json:"detail"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return Result{}, fmt.Errorf("failed to parse scan response: %w", err)
+	}
+	switch response.Verdict {
+	case VerdictClean, VerdictDetected:
+		return Result{Verdict: response.Verdict, Detail: truncate(response.Detail)}, nil
+	default:
+		return Result{}, fmt.Errorf("scanning service returned unknown verdict %q", response.Verdict)
+	}
+}
+
+func truncate(s string) string {
+	if len(s) <= maxDetailLength {
+		return s
+	}
+	return s[:maxDetailLength] + "..."
+}
@@ -0,0 +1,139 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubRun replaces runFunc for the test, recording the command it was called with
+func stubRun(t *testing.T, code int, output string, err error) *[]string {
+	var called []string
+	original := runFunc
+	runFunc = func(ctx context.Context, name string, args ...string) (int, []byte, error) {
+		called = append([]string{name}, args...)
+		return code, []byte(output), err
+	}
+	t.Cleanup(func() { runFunc = original })
+	return &called
+}
+
+func TestCommandScanner(t *testing.T) {
+	t.Run("clean", func(t *testing.T) {
+		called := stubRun(t, 0, "", nil)
+
+		// method under test
+		result, err := NewCommandScanner("clamscan --no-summary").Scan(context.Background(), "/tmp/agent.tar.gz")
+
+		require.NoError(t, err)
+		assert.Equal(t, Result{Verdict: VerdictClean}, result)
+		assert.Equal(t, []string{"clamscan", "--no-summary", "/tmp/agent.tar.gz"}, *called)
+	})
+
+	t.Run("detected", func(t *testing.T) {
+		stubRun(t, 1, "/tmp/agent.tar.gz: Eicar-Signature FOUND\n", nil)
+
+		// method under test
+		result, err := NewCommandScanner("clamscan").Scan(context.Background(), "/tmp/agent.tar.gz")
+
+		require.NoError(t, err)
+		assert.Equal(t, Result{Verdict: VerdictDetected, Detail: "/tmp/agent.tar.gz: Eicar-Signature FOUND"}, result)
+	})
+
+	t.Run("path placeholder", func(t *testing.T) {
+		called := stubRun(t, 0, "", nil)
+
+		// method under test
+		_, err := NewCommandScanner("scanner --file={} --strict").Scan(context.Background(), "/tmp/agent.tar.gz")
+
+		require.NoError(t, err)
+		assert.Equal(t, []string{"scanner", "--file=/tmp/agent.tar.gz", "--strict"}, *called)
+	})
+
+	t.Run("scanner error", func(t *testing.T) {
+		stubRun(t, 2, "ERROR: Can't open database", nil)
+
+		// method under test
+		_, err := NewCommandScanner("clamscan").Scan(context.Background(), "/tmp/agent.tar.gz")
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "clamscan exited with status 2: ERROR: Can't open database")
+	})
+
+	t.Run("not installed", func(t *testing.T) {
+		stubRun(t, 0, "", fmt.Errorf("the clamscan command is not installed on the runner"))
+
+		// method under test
+		_, err := NewCommandScanner("clamscan").Scan(context.Background(), "/tmp/agent.tar.gz")
+
+		assert.ErrorContains(t, err, "not installed")
+	})
+}
+
+func TestServiceScanner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.tar.gz")
+	require.NoError(t, os.WriteFile(path, []byte("agent binary"), 0o644))
+
+	scanService := func(t *testing.T, status int, response string) *ServiceScanner {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			assert.Equal(t, "agent binary", string(body))
+			assert.Equal(t, "Bearer scan-token", r.Header.Get("Authorization"))
+			w.WriteHeader(status)
+			w.Write([]byte(response))
+		}))
+		t.Cleanup(server.Close)
+		return NewServiceScanner(server.URL, "scan-token")
+	}
+
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		expected Result
+		errorMsg string
+	}{
+		{name: "clean", status: http.StatusOK, response: `{"verdict":"clean"}`, expected: Result{Verdict: VerdictClean}},
+		{name: "detected", status: http.StatusOK, response: `{"verdict":"detected","detail":"Trojan.Generic"}`, expected: Result{Verdict: VerdictDetected, Detail: "Trojan.Generic"}},
+		{name: "unknown verdict", status: http.StatusOK, response: `{"verdict":"maybe"}`, errorMsg: `scanning service returned unknown verdict "maybe"`},
+		{name: "service error", status: http.StatusServiceUnavailable, response: "scanner overloaded", errorMsg: "scanning service returned status 503: scanner overloaded"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			result, err := scanService(t, tt.status, tt.response).Scan(context.Background(), path)
+
+			if tt.errorMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errorMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	scanners, err := New("", "", "")
+	require.NoError(t, err)
+	assert.Empty(t, scanners)
+
+	scanners, err = New("clamscan", "https://scanner.example.com/v1/scan", "token")
+	require.NoError(t, err)
+	require.Len(t, scanners, 2)
+	assert.Equal(t, "clamscan", scanners[0].Name())
+	assert.Equal(t, "https://scanner.example.com/v1/scan", scanners[1].Name())
+
+	_, err = New("", "http://scanner.example.com/v1/scan", "")
+	assert.ErrorContains(t, err, `invalid scan-url "http://scanner.example.com/v1/scan": must be an https:// URL`)
+}
@@ -0,0 +1,116 @@
+package schemadoc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Changes summarizes how the properties of a configuration schema changed from the previous version to the current
+// one, one entry per property added, removed or changed, ordered by property path
+// A property added or removed with its parent is left to the entry of the parent
+func Changes(previous, current []byte) ([]string, error) {
+	_, previousRows, err := flatten(previous)
+	if err != nil {
+		return nil, fmt.Errorf("invalid previous schema: %w", err)
+	}
+	_, currentRows, err := flatten(current)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := func(list []row) map[string]row {
+		byPath := make(map[string]row, len(list))
+		for _, r := range list {
+			byPath[r.path] = r
+		}
+		return byPath
+	}
+	before, after := rows(previousRows), rows(currentRows)
+	paths := make([]string, 0, len(before)+len(after))
+	for path := range before {
+		paths = append(paths, path)
+	}
+	for path := range after {
+		if _, ok := before[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var added, removed []string
+	var changes []string
+	for _, path := range paths {
+		was, existed := before[path]
+		is, exists := after[path]
+		switch {
+		case !existed:
+			if hasAncestor(added, path) {
+				continue
+			}
+			added = append(added, path)
+			entry := fmt.Sprintf("Added `%s`", path)
+			if is.typ != "" {
+				entry += fmt.Sprintf(" (%s)", is.typ)
+			}
+			if is.required {
+				entry += ", required"
+			}
+			changes = append(changes, entry)
+		case !exists:
+			if hasAncestor(removed, path) {
+				continue
+			}
+			removed = append(removed, path)
+			changes = append(changes, fmt.Sprintf("Removed `%s`", path))
+		default:
+			changes = append(changes, propertyChanges(path, was, is)...)
+		}
+	}
+	return changes, nil
+}
+
+// propertyChanges describes how a property present in both versions changed
+func propertyChanges(path string, was, is row) []string {
+	var changes []string
+	if was.typ != is.typ {
+		changes = append(changes, fmt.Sprintf("Changed the type of `%s` from %s to %s", path, orUnspecified(was.typ), orUnspecified(is.typ)))
+	}
+	if was.required != is.required {
+		if is.required {
+			changes = append(changes, fmt.Sprintf("Made `%s` required", path))
+		} else {
+			changes = append(changes, fmt.Sprintf("Made `%s` optional", path))
+		}
+	}
+	switch {
+	case was.defaultValue == is.defaultValue:
+	case was.defaultValue == "":
+		changes = append(changes, fmt.Sprintf("Set the default of `%s` to %s", path, is.defaultValue))
+	case is.defaultValue == "":
+		changes = append(changes, fmt.Sprintf("Removed the default of `%s`", path))
+	default:
+		changes = append(changes, fmt.Sprintf("Changed the default of `%s` from %s to %s", path, was.defaultValue, is.defaultValue))
+	}
+	if is.deprecated && !was.deprecated {
+		changes = append(changes, fmt.Sprintf("Deprecated `%s`", path))
+	}
+	return changes
+}
+
+// hasAncestor reports whether one of paths is a property path is nested in
+func hasAncestor(paths []string, path string) bool {
+	for _, parent := range paths {
+		if rest, ok := strings.CutPrefix(path, parent); ok && (strings.HasPrefix(rest, ".") || strings.HasPrefix(rest, "[]")) {
+			return true
+		}
+	}
+	return false
+}
+
+func orUnspecified(typ string) string {
+	if typ == "" {
+		return "unspecified"
+	}
+	return typ
+}
@@ -0,0 +1,65 @@
+package schemadoc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChanges(t *testing.T) {
+	previous := `{
+		"type": "object",
+		"required": ["license_key"],
+		"properties": {
+			"license_key": {"type": "string"},
+			"log_level": {"type": "string", "default": "info"},
+			"log_file": {"type": "string"},
+			"port": {"type": "string"},
+			"labels": {"type": "array", "items": {"type": "object", "properties": {"name": {"type": "string"}}}},
+			"proxy": {"type": "object", "properties": {"host": {"type": "string"}, "port": {"type": "integer", "default": 8080}}}
+		}
+	}`
+	current := `
+type: object
+required: [license_key, host]
+properties:
+  license_key: {type: string}
+  host: {type: string}
+  log_level: {type: string, default: warn}
+  port: {type: integer, deprecated: true}
+  labels: {type: array, items: {type: object, required: [name], properties: {name: {type: string}}}}
+  proxy: {type: object, properties: {host: {type: string}, port: {type: integer}}}
+  tls: {type: object, properties: {ca: {type: string}, verify: {type: boolean}}}
+`
+
+	// method under test
+	changes, err := Changes([]byte(previous), []byte(current))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"Added `host` (string), required",
+		"Made `labels[].name` required",
+		"Removed `log_file`",
+		"Changed the default of `log_level` from `\"info\"` to `\"warn\"`",
+		"Changed the type of `port` from string to integer",
+		"Deprecated `port`",
+		"Removed the default of `proxy.port`",
+		"Added `tls` (object)",
+	}, changes)
+
+	t.Run("unchanged", func(t *testing.T) {
+		// method under test
+		changes, err := Changes([]byte(previous), []byte(previous))
+
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("invalid previous schema", func(t *testing.T) {
+		// method under test
+		_, err := Changes([]byte(`[]`), []byte(current))
+
+		assert.ErrorContains(t, err, "invalid previous schema")
+	})
+}
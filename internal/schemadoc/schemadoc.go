@@ -0,0 +1,436 @@
+package schemadoc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// pathSegmentRegex limits the values a document path is made of to characters that are safe as a path segment
+var pathSegmentRegex = regexp.MustCompile(`^[A-Za-z0-9._+-]+$`)
+
+// Document is the Markdown documentation of the schema of one configuration definition
+type Document struct {
+	Type     string
+	Platform string
+	Markdown string
+}
+
+// Filename returns the name the document is written under: <type>.md, or <type>-<platform>.md for a definition of a
+// platform, lower case
+func (d Document) Filename() string {
+	if d.Platform == "" {
+		return strings.ToLower(d.Type) + ".md"
+	}
+	return strings.ToLower(d.Type+"-"+d.Platform) + ".md"
+}
+
+// Write writes the documents to dir/<agentType>/<version>/, returning the paths written
+func Write(dir, agentType, version string, docs []Document) ([]string, error) {
+	for name, value := range map[string]string{"agent type": agentType, "version": version} {
+		if err := validatePathSegment(name, value); err != nil {
+			return nil, err
+		}
+	}
+	versionDir := filepath.Join(dir, agentType, version)
+	if err := os.MkdirAll(versionDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create schema docs directory: %w", err)
+	}
+
+	paths := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		if err := validatePathSegment("configuration definition", strings.TrimSuffix(doc.Filename(), ".md")); err != nil {
+			return paths, err
+		}
+		path := filepath.Join(versionDir, doc.Filename())
+		if err := os.WriteFile(path, []byte(doc.Markdown), 0644); err != nil {
+			return paths, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func validatePathSegment(name, value string) error {
+	if value == "." || value == ".." || !pathSegmentRegex.MatchString(value) {
+		return fmt.Errorf("invalid %s %q: must only contain letters, digits, '.', '_', '+' or '-'", name, value)
+	}
+	return nil
+}
+
+// Render renders the schema of a configuration definition of agentType version as Markdown: its title and
+// description, then a table of every property, nested ones by their dotted path, with its type, whether it is
+// required, its default and its description
+// The schema may be JSON or YAML; $ref within the schema is followed
+func Render(agentType, version, definitionType, platform string, schema []byte) (Document, error) {
+	rootSchema, rows, err := flatten(schema)
+	if err != nil {
+		return Document{}, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s configuration of %s %s", definitionType, agentType, version)
+	if platform != "" {
+		fmt.Fprintf(&b, " (%s)", platform)
+	}
+	b.WriteString("\n\n")
+	if title, ok := rootSchema["title"].(string); ok && title != "" {
+		fmt.Fprintf(&b, "**%s**\n\n", strings.TrimSpace(title))
+	}
+	if description, ok := rootSchema["description"].(string); ok && description != "" {
+		fmt.Fprintf(&b, "%s\n\n", strings.TrimSpace(description))
+	}
+
+	if len(rows) == 0 {
+		b.WriteString("The schema documents no properties.\n")
+		return Document{Type: definitionType, Platform: platform, Markdown: b.String()}, nil
+	}
+	b.WriteString("| Property | Type | Required | Default | Description |\n|----------|------|----------|---------|-------------|\n")
+	for _, row := range rows {
+		required := "no"
+		if row.required {
+			required = "yes"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s |\n", escapeCell(row.path), escapeCell(row.typ), required, escapeCell(row.defaultValue), escapeCell(row.description))
+	}
+	return Document{Type: definitionType, Platform: platform, Markdown: b.String()}, nil
+}
+
+// flatten parses a JSON or YAML schema and returns it with a row for every property it documents
+func flatten(schema []byte) (map[string]any, []row, error) {
+	root, err := decode(schema)
+	if err != nil {
+		return nil, nil, err
+	}
+	rootSchema, ok := root.(map[string]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("a schema must be an object")
+	}
+	r := &renderer{root: rootSchema}
+	r.walk(rootSchema, "", false, map[string]bool{})
+	return rootSchema, r.rows, nil
+}
+
+// row documents one property
+type row struct {
+	path         string
+	typ          string
+	required     bool
+	deprecated   bool
+	defaultValue string
+	description  string
+}
+
+type renderer struct {
+	root map[string]any
+	rows []row
+}
+
+// walk adds a row for the property at path, unless it is the root, then the rows of the properties nested in it
+// seen holds the references being followed, so recursive schemas end
+func (r *renderer) walk(raw any, path string, required bool, seen map[string]bool) {
+	schema, _ := raw.(map[string]any)
+	schema, ref := r.resolve(schema)
+	if ref != "" {
+		if seen[ref] {
+			if path != "" {
+				r.rows = append(r.rows, row{path: path, typ: typeOf(schema, r), required: required, description: fmt.Sprintf("Recursive, as %s.", ref)})
+			}
+			return
+		}
+		seen = withRef(seen, ref)
+	}
+	if path != "" {
+		deprecated, _ := schema["deprecated"].(bool)
+		r.rows = append(r.rows, row{path: path, typ: typeOf(schema, r), required: required, deprecated: deprecated, defaultValue: defaultOf(schema), description: describe(schema)})
+	}
+
+	r.walkProperties(schema, path, seen)
+	if patterns, ok := schema["patternProperties"].(map[string]any); ok {
+		keys := make([]string, 0, len(patterns))
+		for pattern := range patterns {
+			keys = append(keys, pattern)
+		}
+		sort.Strings(keys)
+		for _, pattern := range keys {
+			r.walk(patterns[pattern], join(path, "<"+pattern+">"), false, seen)
+		}
+	}
+	if additional, ok := schema["additionalProperties"].(map[string]any); ok {
+		r.walk(additional, join(path, "*"), false, seen)
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		r.walkItems(items, path+"[]", seen)
+	}
+}
+
+// walkItems adds the rows of the properties of the items of an array at path, without a row for the items
+func (r *renderer) walkItems(schema map[string]any, path string, seen map[string]bool) {
+	schema, ref := r.resolve(schema)
+	if ref != "" {
+		if seen[ref] {
+			return
+		}
+		seen = withRef(seen, ref)
+	}
+	r.walkProperties(schema, path, seen)
+}
+
+// walkProperties adds the rows of the properties of schema, its allOf schemas included
+func (r *renderer) walkProperties(schema map[string]any, path string, seen map[string]bool) {
+	properties, requiredNames := r.properties(schema)
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r.walk(properties[name], join(path, name), requiredNames[name], seen)
+	}
+}
+
+// properties returns the properties of schema and the ones it requires, including those of its allOf schemas
+func (r *renderer) properties(schema map[string]any) (map[string]any, map[string]bool) {
+	properties := map[string]any{}
+	required := map[string]bool{}
+	schemas := []map[string]any{schema}
+	if allOf, ok := schema["allOf"].([]any); ok {
+		for _, sub := range allOf {
+			if subSchema, ok := sub.(map[string]any); ok {
+				schemas = append(schemas, r.resolveOnly(subSchema))
+			}
+		}
+	}
+	for _, s := range schemas {
+		if ps, ok := s["properties"].(map[string]any); ok {
+			for name, property := range ps {
+				properties[name] = property
+			}
+		}
+		if names, ok := s["required"].([]any); ok {
+			for _, name := range names {
+				required[fmt.Sprint(name)] = true
+			}
+		}
+	}
+	return properties, required
+}
+
+// resolve follows a $ref within the schema, returning the schema it points to and the reference, or schema itself
+// and no reference
+// Keywords next to $ref, such as a description, take precedence over those of the referenced schema
+func (r *renderer) resolve(schema map[string]any) (map[string]any, string) {
+	ref, ok := schema["$ref"].(string)
+	if !ok {
+		return schema, ""
+	}
+	target, ok := r.lookup(ref)
+	if !ok {
+		return schema, ""
+	}
+	merged := make(map[string]any, len(target)+len(schema))
+	for key, value := range target {
+		merged[key] = value
+	}
+	for key, value := range schema {
+		if key != "$ref" {
+			merged[key] = value
+		}
+	}
+	return merged, ref
+}
+
+func (r *renderer) resolveOnly(schema map[string]any) map[string]any {
+	resolved, _ := r.resolve(schema)
+	return resolved
+}
+
+// lookup finds the schema a JSON pointer fragment such as #/$defs/port points to
+func (r *renderer) lookup(ref string) (map[string]any, bool) {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, false
+	}
+	var current any = r.root
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[token]; !ok {
+			return nil, false
+		}
+	}
+	target, ok := current.(map[string]any)
+	return target, ok
+}
+
+func withRef(seen map[string]bool, ref string) map[string]bool {
+	copied := make(map[string]bool, len(seen)+1)
+	for key := range seen {
+		copied[key] = true
+	}
+	copied[ref] = true
+	return copied
+}
+
+// typeOf describes the type of a property: its type keyword, the types of its oneOf or anyOf alternatives, or the
+// type of its const or enum values; arrays name the type of their items
+func typeOf(schema map[string]any, r *renderer) string {
+	var types []string
+	switch t := schema["type"].(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, typ := range t {
+			types = append(types, fmt.Sprint(typ))
+		}
+	}
+	if len(types) == 0 {
+		for _, keyword := range []string{"oneOf", "anyOf"} {
+			alternatives, ok := schema[keyword].([]any)
+			if !ok {
+				continue
+			}
+			for _, alternative := range alternatives {
+				if sub, ok := alternative.(map[string]any); ok {
+					if typ := typeOf(r.resolveOnly(sub), r); typ != "" && !contains(types, typ) {
+						types = append(types, typ)
+					}
+				}
+			}
+		}
+	}
+	if len(types) == 0 {
+		values, _ := schema["enum"].([]any)
+		if constant, ok := schema["const"]; ok {
+			values = []any{constant}
+		}
+		for _, value := range values {
+			if typ := jsonType(value); !contains(types, typ) {
+				types = append(types, typ)
+			}
+		}
+	}
+
+	for i, typ := range types {
+		if typ != "array" {
+			continue
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			if itemType := typeOf(r.resolveOnly(items), r); itemType != "" {
+				types[i] = "array of " + itemType
+			}
+		}
+	}
+	return strings.Join(types, " or ")
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultOf returns the default of a property as inline code, or nothing
+func defaultOf(schema map[string]any) string {
+	value, ok := schema["default"]
+	if !ok {
+		return ""
+	}
+	return "`" + formatValue(value) + "`"
+}
+
+// describe returns the description of a property, followed by its allowed values and whether it is deprecated
+func describe(schema map[string]any) string {
+	var parts []string
+	if deprecated, _ := schema["deprecated"].(bool); deprecated {
+		parts = append(parts, "**Deprecated.**")
+	}
+	if description, ok := schema["description"].(string); ok && description != "" {
+		description = strings.TrimSpace(description)
+		if !strings.HasSuffix(description, ".") {
+			description += "."
+		}
+		parts = append(parts, description)
+	}
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		values := make([]string, len(enum))
+		for i, value := range enum {
+			values[i] = "`" + formatValue(value) + "`"
+		}
+		parts = append(parts, "One of: "+strings.Join(values, ", ")+".")
+	}
+	return strings.Join(parts, " ")
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func formatValue(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// escapeCell keeps a value on one Markdown table row
+func escapeCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\r", " ", "\n", " ").Replace(s)
+}
+
+// decode parses a YAML or JSON document into the values encoding/json gives with UseNumber
+func decode(data []byte) (any, error) {
+	var document any
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("the schema isn't representable as JSON: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,102 @@
+package schemadoc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const schema = `{
+	"title": "Agent configuration",
+	"description": "Settings of the agent",
+	"type": "object",
+	"required": ["license_key"],
+	"properties": {
+		"license_key": {"type": "string", "description": "The license key"},
+		"log_level": {"type": "string", "enum": ["info", "debug"], "default": "info", "description": "Level to log at | verbosity"},
+		"proxy": {"$ref": "#/$defs/proxy"},
+		"labels": {"type": "array", "items": {"type": "object", "properties": {"name": {"type": "string"}}}},
+		"port": {"oneOf": [{"type": "integer"}, {"type": "string"}], "deprecated": true}
+	},
+	"$defs": {
+		"proxy": {"type": "object", "required": ["host"], "properties": {"host": {"type": "string"}, "port": {"type": "integer", "default": 8080}}}
+	}
+}`
+
+func TestRender(t *testing.T) {
+	// method under test
+	doc, err := Render("dotnet-agent", "1.2.3", "agent-config", "linux", []byte(schema))
+
+	require.NoError(t, err)
+	assert.Equal(t, "agent-config-linux.md", doc.Filename())
+	assert.Contains(t, doc.Markdown, "### agent-config configuration of dotnet-agent 1.2.3 (linux)\n\n**Agent configuration**\n\nSettings of the agent\n\n")
+	assert.Contains(t, doc.Markdown, "| `license_key` | string | yes |  | The license key. |\n")
+	assert.Contains(t, doc.Markdown, "| `log_level` | string | no | `\"info\"` | Level to log at \\| verbosity. One of: `\"info\"`, `\"debug\"`. |\n")
+	assert.Contains(t, doc.Markdown, "| `proxy` | object | no |  |  |\n")
+	assert.Contains(t, doc.Markdown, "| `proxy.host` | string | yes |  |  |\n")
+	assert.Contains(t, doc.Markdown, "| `proxy.port` | integer | no | `8080` |  |\n")
+	assert.Contains(t, doc.Markdown, "| `labels` | array of object | no |  |  |\n")
+	assert.Contains(t, doc.Markdown, "| `labels[].name` | string | no |  |  |\n")
+	assert.Contains(t, doc.Markdown, "| `port` | integer or string | no |  | **Deprecated.** |\n")
+
+	t.Run("recursive schema", func(t *testing.T) {
+		recursive := "type: object\nproperties:\n  node:\n    $ref: '#/$defs/node'\n$defs:\n  node:\n    type: object\n    properties:\n      child:\n        $ref: '#/$defs/node'\n"
+
+		// method under test
+		doc, err := Render("dotnet-agent", "1.2.3", "agent-config", "", []byte(recursive))
+
+		require.NoError(t, err)
+		assert.Equal(t, "agent-config.md", doc.Filename())
+		assert.Contains(t, doc.Markdown, "| `node.child` | object | no |  | Recursive, as #/$defs/node. |\n")
+	})
+
+	t.Run("no properties", func(t *testing.T) {
+		// method under test
+		doc, err := Render("dotnet-agent", "1.2.3", "agent-config", "", []byte(`{"type": "string"}`))
+
+		require.NoError(t, err)
+		assert.Contains(t, doc.Markdown, "The schema documents no properties.")
+	})
+
+	t.Run("not an object", func(t *testing.T) {
+		// method under test
+		_, err := Render("dotnet-agent", "1.2.3", "agent-config", "", []byte(`["type"]`))
+
+		assert.ErrorContains(t, err, "must be an object")
+	})
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	docs := []Document{
+		{Type: "agent-config", Platform: "Linux", Markdown: "linux"},
+		{Type: "agent-config", Markdown: "all"},
+	}
+
+	// method under test
+	paths, err := Write(dir, "dotnet-agent", "1.2.3", docs)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "dotnet-agent", "1.2.3", "agent-config-linux.md"),
+		filepath.Join(dir, "dotnet-agent", "1.2.3", "agent-config.md"),
+	}, paths)
+	data, err := os.ReadFile(paths[0])
+	require.NoError(t, err)
+	assert.Equal(t, "linux", string(data))
+
+	t.Run("rejects a path segment", func(t *testing.T) {
+		// method under test
+		_, err := Write(dir, "dotnet-agent", "../1.2.3", docs)
+
+		assert.ErrorContains(t, err, "invalid version")
+
+		// method under test
+		_, err = Write(dir, "dotnet-agent", "1.2.3", []Document{{Type: "agent/config"}})
+
+		assert.ErrorContains(t, err, "invalid configuration definition")
+	})
+}
@@ -3,7 +3,6 @@ package sign
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +10,8 @@ import (
 	"strings"
 	"time"
 
+	"agent-metadata-action/internal/canonical"
+	"agent-metadata-action/internal/idempotency"
 	"agent-metadata-action/internal/logging"
 	"agent-metadata-action/internal/models"
 	"agent-metadata-action/internal/retry"
@@ -20,6 +21,7 @@ type Client struct {
 	baseURL    string
 	httpClient *http.Client
 	token      string
+	runID      string
 }
 
 // NewClient creates a new signing client
@@ -35,30 +37,45 @@ func NewClient(baseURL, token string) *Client {
 	}
 }
 
+// SetRunID scopes idempotency keys to a workflow run, so re-run attempts of the run reuse the keys of the first attempt
+// Without a run ID keys are scoped to the process (see idempotency.RunScope), so they only cover its retries
+func (c *Client) SetRunID(runID string) {
+	c.runID = runID
+}
+
 // SignArtifact signs an uploaded artifact
 // POST /v1/signing/{clientId}/sign
 // clientId: GitHub repository name (e.g., "dotnet-agent")
 // request: signing request with registry, repository, tag, digest
 // Returns error on failure (non-2xx or network error)
 func (c *Client) SignArtifact(ctx context.Context, clientId string, request *models.SigningRequest) error {
+	_, err := c.Sign(ctx, clientId, request)
+	return err
+}
+
+// Sign signs an uploaded artifact and returns the parsed response
+// A success response that contradicts the request (e.g. names a different digest) is returned as an error
+func (c *Client) Sign(ctx context.Context, clientId string, request *models.SigningRequest) (models.SigningResponse, error) {
 	logging.Log(ctx, "group", "Signing artifact")
 	defer logging.Log(ctx, "endgroup", "")
 
+	var response models.SigningResponse
+
 	// Validate inputs
 	logging.Debug(ctx, "Validating inputs...")
 	if clientId == "" {
 		logging.Error(ctx, "Signing client ID is required but was empty")
-		return retry.NewNonRetryableError(fmt.Errorf("signing client ID is required"))
+		return response, retry.NewNonRetryableError(fmt.Errorf("signing client ID is required"))
 	}
 	if request == nil {
 		logging.Error(ctx, "Signing request is required but was nil")
-		return retry.NewNonRetryableError(fmt.Errorf("signing request is required"))
+		return response, retry.NewNonRetryableError(fmt.Errorf("signing request is required"))
 	}
 
 	// Validate request fields
 	if err := request.Validate(); err != nil {
 		logging.Errorf(ctx, "Invalid signing request: %v", err)
-		return retry.NewNonRetryableError(fmt.Errorf("invalid signing request: %w", err))
+		return response, retry.NewNonRetryableError(fmt.Errorf("invalid signing request: %w", err))
 	}
 
 	logging.Debugf(ctx, "Signing client ID: %s", clientId)
@@ -74,10 +91,10 @@ func (c *Client) SignArtifact(ctx context.Context, clientId string, request *mod
 
 	// Marshal request to JSON
 	logging.Debug(ctx, "Marshaling request to JSON...")
-	jsonBody, err := json.Marshal(request)
+	jsonBody, err := canonical.Marshal(request)
 	if err != nil {
 		logging.Errorf(ctx, "Failed to marshal request: %v", err)
-		return retry.NewNonRetryableError(fmt.Errorf("failed to marshal request: %w", err))
+		return response, retry.NewNonRetryableError(fmt.Errorf("failed to marshal request: %w", err))
 	}
 	logging.Debugf(ctx, "JSON payload size: %d bytes", len(jsonBody))
 
@@ -86,12 +103,13 @@ func (c *Client) SignArtifact(ctx context.Context, clientId string, request *mod
 	req, err := http.NewRequestWithContext(ctx, "POST", requestURL, bytes.NewBuffer(jsonBody))
 	if err != nil {
 		logging.Errorf(ctx, "Failed to create request: %v", err)
-		return retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
+		return response, retry.NewNonRetryableError(fmt.Errorf("failed to create request: %w", err))
 	}
 
 	// Set headers
 	logging.Debug(ctx, "Setting request headers...")
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(idempotency.Header, idempotency.Key(clientId, idempotency.PayloadHash(jsonBody), idempotency.RunScope(c.runID)))
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
 	// SECURITY: Token is in header but not logged
 
@@ -103,7 +121,7 @@ func (c *Client) SignArtifact(ctx context.Context, clientId string, request *mod
 
 	if err != nil {
 		logging.Errorf(ctx, "HTTP request failed after %s: %v", duration, err)
-		return fmt.Errorf("failed to send signing request: %w", err)
+		return response, fmt.Errorf("failed to send signing request: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -115,7 +133,7 @@ func (c *Client) SignArtifact(ctx context.Context, clientId string, request *mod
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		logging.Errorf(ctx, "Failed to read response body: %v", err)
-		return fmt.Errorf("failed to read response: %w", err)
+		return response, fmt.Errorf("failed to read response: %w", err)
 	}
 	logging.Debugf(ctx, "Response body size: %d bytes", len(body))
 
@@ -137,18 +155,30 @@ func (c *Client) SignArtifact(ctx context.Context, clientId string, request *mod
 		// Don't retry: 4xx (client errors, except 408 and 429)
 		isRetryable := resp.StatusCode >= 500 || resp.StatusCode == 408 || resp.StatusCode == 429
 		if !isRetryable {
-			return retry.NewNonRetryableError(err)
+			return response, retry.NewNonRetryableError(err)
 		}
-		return err
+		return response, err
 	}
 
-	// Success logging
-	logging.Notice(ctx, "Artifact signed successfully")
 	if len(body) > 0 {
 		logging.Debugf(ctx, "Success response: %s", string(body))
 	}
 
-	return nil
+	// Retrying won't change a response that contradicts the request
+	response, err = models.ParseSigningResponse(body, request.Digest)
+	if err != nil {
+		logging.Errorf(ctx, "Artifact signing returned status %d with an unexpected response: %v", resp.StatusCode, err)
+		return response, retry.NewNonRetryableError(fmt.Errorf("artifact signing returned status %d with an unexpected response: %w", resp.StatusCode, err))
+	}
+
+	// Success logging
+	if response.ID != "" {
+		logging.Noticef(ctx, "Artifact signed successfully (ID: %s)", response.ID)
+	} else {
+		logging.Notice(ctx, "Artifact signed successfully")
+	}
+
+	return response, nil
 }
 
 // ParseRegistryURL extracts registry domain and repository path from OCI registry URL
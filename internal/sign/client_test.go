@@ -8,7 +8,9 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"agent-metadata-action/internal/idempotency"
 	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/retry"
 	"agent-metadata-action/internal/testutil"
 
 	"github.com/stretchr/testify/assert"
@@ -97,6 +99,66 @@ func TestSignArtifact_Success(t *testing.T) {
 	assert.NotContains(t, stderrStr, "::error::")
 }
 
+func TestSignArtifact_IdempotencyKey(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(idempotency.Header))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	testutil.CaptureOutput(t)
+
+	client := NewClient(server.URL, "test-token")
+	client.SetRunID("42")
+	request := models.SigningRequest{Registry: "docker.io", Repository: "newrelic/agents", Tag: "v1.2.3", Digest: "sha256:abc123"}
+	otherDigest := request
+	otherDigest.Digest = "sha256:def456"
+
+	// method under test
+	require.NoError(t, client.SignArtifact(context.Background(), "test-agent", &request))
+	require.NoError(t, client.SignArtifact(context.Background(), "test-agent", &request))
+	require.NoError(t, client.SignArtifact(context.Background(), "test-agent", &otherDigest))
+
+	require.Len(t, keys, 3)
+	assert.Regexp(t, `^[a-f0-9]{64}$`, keys[0])
+	assert.Equal(t, keys[0], keys[1], "repeated requests should reuse the key")
+	assert.NotEqual(t, keys[0], keys[2], "a different digest should get a new key")
+}
+
+func TestSign_Response(t *testing.T) {
+	var body string
+	requestCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+	testutil.CaptureOutput(t)
+
+	client := NewClient(server.URL, "test-token")
+	request := &models.SigningRequest{Registry: "docker.io", Repository: "newrelic/agents", Tag: "v1.2.3", Digest: "sha256:abc123"}
+
+	t.Run("returns the signature ID", func(t *testing.T) {
+		body = `{"success": true, "id": "sig-1", "digest": "sha256:abc123"}`
+
+		// method under test
+		response, err := client.Sign(context.Background(), "test-agent", request)
+		require.NoError(t, err)
+		assert.Equal(t, "sig-1", response.ID)
+	})
+
+	t.Run("wrong digest echoed", func(t *testing.T) {
+		body = `{"success": true, "digest": "sha256:def456"}`
+
+		// method under test
+		_, err := client.Sign(context.Background(), "test-agent", request)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unexpected response")
+		assert.True(t, retry.IsNonRetryable(err))
+	})
+}
+
 func TestSignArtifact_Created(t *testing.T) {
 	// Create test server that returns 201 Created
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
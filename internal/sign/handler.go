@@ -11,22 +11,26 @@ import (
 	"agent-metadata-action/internal/retry"
 )
 
-// SignIndex signs the manifest index
+// SignIndex signs the manifest index using the configured signing service
 // Retries failed signing operations up to 3 times
-// Returns error if signing fails after all retries
-func SignIndex(ctx context.Context, ociRegistry, indexDigest, version, token, githubRepo string) error {
+// Returns the signing service response, or an error if signing fails after all retries
+func SignIndex(ctx context.Context, ociRegistry, indexDigest, version, token, githubRepo string) (models.SigningResponse, error) {
+	client := NewClient(config.GetSigningURL(), token)
+	client.SetRunID(config.GetRunID())
+	return SignIndexWithClient(ctx, client, ociRegistry, indexDigest, version, githubRepo)
+}
+
+// SignIndexWithClient signs the manifest index with the given signing client
+func SignIndexWithClient(ctx context.Context, client *Client, ociRegistry, indexDigest, version, githubRepo string) (models.SigningResponse, error) {
 	logging.Notice(ctx, "Starting manifest index signing...")
 
 	// Parse registry URL once
 	registry, repository, err := ParseRegistryURL(ociRegistry)
 	if err != nil {
-		return retry.NewNonRetryableError(fmt.Errorf("failed to parse registry URL: %w", err))
+		return models.SigningResponse{}, retry.NewNonRetryableError(fmt.Errorf("failed to parse registry URL: %w", err))
 	}
 	logging.Debugf(ctx, "Parsed registry URL - Registry: %s, Repository: %s", registry, repository)
 
-	// Create signing client
-	client := NewClient(config.GetSigningURL(), token)
-
 	logging.Log(ctx, "group", "Signing manifest index")
 	defer logging.Log(ctx, "endgroup", "")
 
@@ -45,15 +49,18 @@ func SignIndex(ctx context.Context, ociRegistry, indexDigest, version, token, gi
 		Operation:   "Signing",
 	}
 
+	var response models.SigningResponse
 	err = retry.Do(ctx, retryConfig, func() error {
-		return client.SignArtifact(ctx, githubRepo, signingReq)
+		var err error
+		response, err = client.Sign(ctx, githubRepo, signingReq)
+		return err
 	})
 
 	if err != nil {
 		logging.Errorf(ctx, "Failed to sign manifest index: %v", err)
-		return err
+		return response, err
 	}
 
 	logging.Noticef(ctx, "Successfully signed manifest index (digest: %s)", indexDigest)
-	return nil
+	return response, nil
 }
@@ -49,7 +49,7 @@ func TestSignIndex_Success(t *testing.T) {
 	getStdout, _ := testutil.CaptureOutput(t)
 
 	// method under test
-	err := SignIndex(context.Background(), "docker.io/newrelic/agents", "sha256:abc123", "1.2.3", "test-token", "test-agent")
+	_, err := SignIndex(context.Background(), "docker.io/newrelic/agents", "sha256:abc123", "1.2.3", "test-token", "test-agent")
 
 	outputStr := getStdout()
 
@@ -84,7 +84,7 @@ func TestSignIndex_RetryOnFailure(t *testing.T) {
 	getStdout, _ := testutil.CaptureOutput(t)
 
 	// method under test
-	err := SignIndex(context.Background(), "docker.io/newrelic/agents", "sha256:abc123", "1.2.3", "test-token", "test-agent")
+	_, err := SignIndex(context.Background(), "docker.io/newrelic/agents", "sha256:abc123", "1.2.3", "test-token", "test-agent")
 
 	outputStr := getStdout()
 
@@ -116,7 +116,7 @@ func TestSignIndex_FailsAfterMaxRetries(t *testing.T) {
 	getStdout, _ := testutil.CaptureOutput(t)
 
 	// method under test
-	err := SignIndex(context.Background(), "docker.io/newrelic/agents", "sha256:abc123", "1.2.3", "test-token", "test-agent")
+	_, err := SignIndex(context.Background(), "docker.io/newrelic/agents", "sha256:abc123", "1.2.3", "test-token", "test-agent")
 
 	outputStr := getStdout()
 
@@ -177,7 +177,7 @@ func TestSignIndex_RegistryURLParsing(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			if tt.expectError {
 				// Only test error cases for URL parsing
-				err := SignIndex(context.Background(), tt.registryURL, "sha256:abc123", "1.2.3", "test-token", "test-agent")
+				_, err := SignIndex(context.Background(), tt.registryURL, "sha256:abc123", "1.2.3", "test-token", "test-agent")
 
 				require.Error(t, err)
 				assert.Contains(t, err.Error(), tt.errorContains)
@@ -0,0 +1,37 @@
+package sign
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ResignTaskFile is the file, relative to the workspace, a resign task is written to
+const ResignTaskFile = "agent-metadata-resign.json"
+
+// ResignTask is a follow-up task to sign a manifest index that was uploaded while the signing service was failing
+// It names everything the signing request needs, so a later workflow can sign the index without uploading again
+type ResignTask struct {
+	Registry   string `json:"registry"`
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest"`
+	Repository string `json:"repository"`
+	RunID      string `json:"runId,omitempty"`
+	Error      string `json:"error"`
+}
+
+// WriteResignTask writes task as JSON to path, creating its directory
+func WriteResignTask(path string, task ResignTask) error {
+	data, err := json.MarshalIndent(task, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal resign task: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create resign task directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Package validation collects the failures of a validation phase so they can be reported together
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error is a validation failure with the file and field it was found in
+type Error struct {
+	File  string // workspace-relative path, empty when the failure isn't tied to a file
+	Line  int    // 1-based line in File, 0 when unknown
+	Field string // path to the field such as configurationDefinitions[0].schema, empty for the whole file
+	Err   error
+}
+
+func (e *Error) Error() string {
+	var prefix []string
+	if e.File != "" {
+		location := e.File
+		if e.Line > 0 {
+			location = fmt.Sprintf("%s:%d", e.File, e.Line)
+		}
+		prefix = append(prefix, location)
+	}
+	if e.Field != "" {
+		prefix = append(prefix, e.Field)
+	}
+	if len(prefix) == 0 {
+		return e.Err.Error()
+	}
+	return strings.Join(prefix, ": ") + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors is every failure found in one validation phase, in the order they were found
+type Errors []error
+
+// Add records a failure of field in file; line is 0 when unknown
+// The failures of a nested check, returned as Errors, are each recorded under field
+func (e *Errors) Add(file string, line int, field string, err error) {
+	nested, ok := err.(Errors)
+	if !ok {
+		*e = append(*e, &Error{File: file, Line: line, Field: field, Err: err})
+		return
+	}
+	for _, err := range nested {
+		fieldErr, ok := err.(*Error)
+		if !ok {
+			e.Add(file, line, field, err)
+			continue
+		}
+		nestedField := field
+		if fieldErr.Field != "" {
+			nestedField = strings.TrimPrefix(field+"."+fieldErr.Field, ".")
+		}
+		e.Add(file, line, nestedField, fieldErr.Err)
+	}
+}
+
+// Addf records a failure of field in file with a formatted message
+func (e *Errors) Addf(file string, line int, field, format string, args ...any) {
+	e.Add(file, line, field, fmt.Errorf(format, args...))
+}
+
+// Append records err, flattening the failures of another phase; nil is ignored
+func (e *Errors) Append(err error) {
+	if err == nil {
+		return
+	}
+	if nested, ok := err.(Errors); ok {
+		*e = append(*e, nested...)
+		return
+	}
+	*e = append(*e, err)
+}
+
+// Err returns the failures as an error, or nil when there are none
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error lists every failure, one per line when there are several
+func (e Errors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d problems found:", len(e))
+	for _, err := range e {
+		b.WriteString("\n  - ")
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+func (e Errors) Unwrap() []error {
+	return e
+}
@@ -0,0 +1,73 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestError_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      *Error
+		expected string
+	}{
+		{name: "file, line and field", err: &Error{File: "defs.yml", Line: 3, Field: "items[0]", Err: errors.New("bad")}, expected: "defs.yml:3: items[0]: bad"},
+		{name: "file without line", err: &Error{File: "defs.yml", Field: "items[0]", Err: errors.New("bad")}, expected: "defs.yml: items[0]: bad"},
+		{name: "field only", err: &Error{Field: "items[0].schema", Err: errors.New("bad")}, expected: "items[0].schema: bad"},
+		{name: "no context", err: &Error{Err: errors.New("bad")}, expected: "bad"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// method under test
+			assert.Equal(t, tt.expected, tt.err.Error())
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var errs Errors
+	require.NoError(t, errs.Err())
+
+	errs.Add("defs.yml", 2, "items[0]", fs.ErrNotExist)
+	errs.Append(nil)
+	assert.Equal(t, "defs.yml:2: items[0]: file does not exist", errs.Err().Error())
+
+	var nested Errors
+	nested.Addf("", 0, "binaries[1]", "os is required for artifact %q", "agent")
+	errs.Append(nested)
+	errs.Append(fmt.Errorf("duplicate artifact name: 'agent'"))
+
+	// method under test
+	err := errs.Err()
+
+	require.Error(t, err)
+	assert.Len(t, errs, 3)
+	assert.Equal(t, "3 problems found:\n"+
+		"  - defs.yml:2: items[0]: file does not exist\n"+
+		"  - binaries[1]: os is required for artifact \"agent\"\n"+
+		"  - duplicate artifact name: 'agent'", err.Error())
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	var fieldErr *Error
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "defs.yml", fieldErr.File)
+}
+
+func TestErrors_AddNested(t *testing.T) {
+	var nested Errors
+	nested.Addf("", 0, "", "requires a description")
+	nested.Addf("", 0, "de", "must be a non-empty string")
+
+	var errs Errors
+	// method under test
+	errs.Add("defs.yml", 0, "items[0].description_i18n", nested)
+
+	require.Len(t, errs, 2)
+	assert.Equal(t, "defs.yml: items[0].description_i18n: requires a description", errs[0].Error())
+	assert.Equal(t, "defs.yml: items[0].description_i18n.de: must be a non-empty string", errs[1].Error())
+}
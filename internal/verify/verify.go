@@ -0,0 +1,189 @@
+package verify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/oci"
+	"agent-metadata-action/internal/results"
+)
+
+// Names of the checks, in the order they are run
+const (
+	CheckMetadata  = "metadata"
+	CheckIndex     = "index"
+	CheckPlatforms = "platforms"
+	CheckSignature = "signature"
+	CheckDigests   = "digests"
+)
+
+// Input is what verify mode found for a release
+// Registry is empty when the release has no binaries; Released is nil when there's no results file of the release
+// run to compare against
+type Input struct {
+	AgentType   string
+	Version     string
+	Metadata    *models.AgentMetadata
+	MetadataErr error
+	Registry    string
+	Release     oci.Release
+	ReleaseErr  error
+	Released    *results.Results
+}
+
+// Verify checks the release against what the release run recorded, returning the outcome of each check
+// Checks that need something that is missing fail rather than being skipped, except the registry checks of
+// releases without binaries and the comparisons without a results file
+func Verify(in Input) []results.Verify {
+	checks := []results.Verify{checkMetadata(in)}
+	if in.Registry == "" {
+		return checks
+	}
+	index := checkIndex(in)
+	checks = append(checks, index)
+	if !index.Passed {
+		return checks
+	}
+	if in.Released != nil {
+		checks = append(checks, checkPlatforms(in))
+	}
+	checks = append(checks, checkSignature(in))
+	if in.Released != nil {
+		checks = append(checks, checkDigests(in))
+	}
+	return checks
+}
+
+// Failed counts the checks that did not pass
+func Failed(checks []results.Verify) int {
+	failed := 0
+	for _, check := range checks {
+		if !check.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+func checkMetadata(in Input) results.Verify {
+	check := results.Verify{Check: CheckMetadata}
+	switch {
+	case in.MetadataErr != nil:
+		check.Detail = fmt.Sprintf("failed to fetch the metadata: %v", in.MetadataErr)
+	case in.Metadata == nil:
+		check.Detail = "the instrumentation service has no metadata for the version"
+	default:
+		check.Passed = true
+		check.Detail = fmt.Sprintf("%d configuration definitions, %d agent control definitions",
+			len(in.Metadata.ConfigurationDefinitions), len(in.Metadata.AgentControlDefinitions))
+	}
+	return check
+}
+
+func checkIndex(in Input) results.Verify {
+	check := results.Verify{Check: CheckIndex}
+	if in.ReleaseErr != nil {
+		check.Detail = in.ReleaseErr.Error()
+		return check
+	}
+	check.Passed = true
+	check.Detail = fmt.Sprintf("%s:%s is %s, listing %d manifests", in.Registry, in.Version, in.Release.Index.Digest, len(in.Release.Manifests))
+	return check
+}
+
+// checkPlatforms checks the index lists a manifest for every platform the release run recorded an artifact for
+func checkPlatforms(in Input) results.Verify {
+	listed := map[string]bool{}
+	for _, manifest := range in.Release.Manifests {
+		if manifest.Platform != nil {
+			listed[manifest.Platform.OS+"/"+manifest.Platform.Architecture] = true
+		}
+	}
+	var missing []string
+	expected := 0
+	for _, artifact := range in.Released.Artifacts {
+		if artifact.OS == "" || artifact.Error != "" {
+			continue
+		}
+		expected++
+		if platform := artifact.OS + "/" + artifact.Arch; !listed[platform] {
+			missing = append(missing, platform)
+		}
+	}
+	check := results.Verify{Check: CheckPlatforms, Passed: len(missing) == 0}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		check.Detail = "missing " + strings.Join(missing, ", ")
+	} else {
+		check.Detail = fmt.Sprintf("all %d platforms listed", expected)
+	}
+	return check
+}
+
+func checkSignature(in Input) results.Verify {
+	check := results.Verify{Check: CheckSignature, Passed: in.Release.Signed}
+	if in.Release.Signed {
+		check.Detail = fmt.Sprintf("%s has a signature", in.Release.Index.Digest)
+	} else {
+		check.Detail = fmt.Sprintf("%s has no signature", in.Release.Index.Digest)
+	}
+	return check
+}
+
+// checkDigests checks the index is the one the release run tagged, and lists the manifests it uploaded
+func checkDigests(in Input) results.Verify {
+	check := results.Verify{Check: CheckDigests}
+	digest := in.Release.Index.Digest.String()
+	if in.Released.Index == nil {
+		check.Detail = "the results file records no manifest index"
+		return check
+	}
+	if in.Released.Index.Digest != digest {
+		check.Detail = fmt.Sprintf("index is %s, but the release run tagged %s", digest, in.Released.Index.Digest)
+		return check
+	}
+	listed := map[string]bool{}
+	for _, manifest := range in.Release.Manifests {
+		listed[manifest.Digest.String()] = true
+	}
+	var missing []string
+	for _, artifact := range in.Released.Artifacts {
+		if artifact.Digest != "" && artifact.Error == "" && !listed[artifact.Digest] {
+			missing = append(missing, artifact.Name+" ("+artifact.Digest+")")
+		}
+	}
+	if len(missing) > 0 {
+		check.Detail = "index does not list " + strings.Join(missing, ", ")
+		return check
+	}
+	check.Passed = true
+	check.Detail = fmt.Sprintf("index %s and its manifests match the release run", digest)
+	return check
+}
+
+// Summary renders the checks as markdown for the job summary
+func Summary(agentType, version string, checks []results.Verify) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Verification of %s %s\n\n", agentType, version)
+	if failed := Failed(checks); failed > 0 {
+		fmt.Fprintf(&b, "**Failed** %d of %d checks.\n\n", failed, len(checks))
+	} else {
+		fmt.Fprintf(&b, "**Passed** all %d checks.\n\n", len(checks))
+	}
+	b.WriteString("| Result | Check | Detail |\n|--------|-------|--------|\n")
+	for _, check := range checks {
+		result := "fail"
+		if check.Passed {
+			result = "pass"
+		}
+		fmt.Fprintf(&b, "| %s | `%s` | %s |\n", result, check.Check, escapeCell(check.Detail))
+	}
+	return b.String()
+}
+
+// escapeCell keeps a detail on one markdown table row
+func escapeCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\r", " ", "\n", " ").Replace(s)
+}
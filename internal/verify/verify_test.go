@@ -0,0 +1,180 @@
+package verify
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/oci"
+	"agent-metadata-action/internal/results"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	indexDigest := digest.Digest("sha256:" + strings.Repeat("a", 64))
+	linux := digest.Digest("sha256:" + strings.Repeat("1", 64))
+	windows := digest.Digest("sha256:" + strings.Repeat("2", 64))
+	release := func(signed bool) oci.Release {
+		return oci.Release{
+			ResignTarget: oci.ResignTarget{
+				Index: ocispec.Descriptor{MediaType: ocispec.MediaTypeImageIndex, Digest: indexDigest},
+				Manifests: []ocispec.Descriptor{
+					{Digest: linux, Platform: &ocispec.Platform{OS: "linux", Architecture: "amd64"}},
+					{Digest: windows, Platform: &ocispec.Platform{OS: "windows", Architecture: "amd64"}},
+				},
+			},
+			Signed: signed,
+		}
+	}
+	released := func() *results.Results {
+		return &results.Results{
+			Index: &results.Index{Digest: indexDigest.String()},
+			Artifacts: []results.Artifact{
+				{Name: "linux-amd64", OS: "linux", Arch: "amd64", Digest: linux.String()},
+				{Name: "windows-amd64", OS: "windows", Arch: "amd64", Digest: windows.String()},
+			},
+		}
+	}
+	input := func() Input {
+		return Input{
+			AgentType: "NRDotNetAgent",
+			Version:   "1.2.3",
+			Metadata:  &models.AgentMetadata{},
+			Registry:  "registry.example.com/agents",
+			Release:   release(true),
+			Released:  released(),
+		}
+	}
+	passed := func(checks []results.Verify) map[string]bool {
+		outcome := map[string]bool{}
+		for _, check := range checks {
+			outcome[check.Check] = check.Passed
+		}
+		return outcome
+	}
+
+	t.Run("everything matches", func(t *testing.T) {
+		// method under test
+		checks := Verify(input())
+
+		require.Len(t, checks, 5)
+		assert.Equal(t, 0, Failed(checks))
+	})
+
+	t.Run("missing metadata", func(t *testing.T) {
+		in := input()
+		in.Metadata = nil
+
+		// method under test
+		checks := Verify(in)
+
+		assert.False(t, passed(checks)[CheckMetadata])
+		assert.Equal(t, 1, Failed(checks))
+	})
+
+	t.Run("metadata fetch error", func(t *testing.T) {
+		in := input()
+		in.MetadataErr = errors.New("service unavailable")
+
+		// method under test
+		checks := Verify(in)
+
+		assert.False(t, passed(checks)[CheckMetadata])
+		assert.Contains(t, checks[0].Detail, "service unavailable")
+	})
+
+	t.Run("missing index stops the registry checks", func(t *testing.T) {
+		in := input()
+		in.ReleaseErr = errors.New("failed to resolve 1.2.3")
+
+		// method under test
+		checks := Verify(in)
+
+		require.Len(t, checks, 2)
+		assert.False(t, passed(checks)[CheckIndex])
+	})
+
+	t.Run("missing platform", func(t *testing.T) {
+		in := input()
+		in.Released.Artifacts = append(in.Released.Artifacts, results.Artifact{Name: "darwin-arm64", OS: "darwin", Arch: "arm64"})
+
+		// method under test
+		checks := Verify(in)
+
+		assert.False(t, passed(checks)[CheckPlatforms])
+		assert.Contains(t, checks[2].Detail, "missing darwin/arm64")
+	})
+
+	t.Run("unsigned", func(t *testing.T) {
+		in := input()
+		in.Release = release(false)
+
+		// method under test
+		checks := Verify(in)
+
+		assert.False(t, passed(checks)[CheckSignature])
+		assert.Equal(t, 1, Failed(checks))
+	})
+
+	t.Run("index digest differs", func(t *testing.T) {
+		in := input()
+		in.Released.Index.Digest = "sha256:" + strings.Repeat("b", 64)
+
+		// method under test
+		checks := Verify(in)
+
+		assert.False(t, passed(checks)[CheckDigests])
+		assert.Contains(t, checks[4].Detail, "but the release run tagged")
+	})
+
+	t.Run("manifest not listed", func(t *testing.T) {
+		in := input()
+		in.Released.Artifacts[1].Digest = "sha256:" + strings.Repeat("3", 64)
+
+		// method under test
+		checks := Verify(in)
+
+		assert.False(t, passed(checks)[CheckDigests])
+		assert.Contains(t, checks[4].Detail, "windows-amd64")
+	})
+
+	t.Run("without a results file", func(t *testing.T) {
+		in := input()
+		in.Released = nil
+
+		// method under test
+		checks := Verify(in)
+
+		assert.Equal(t, map[string]bool{CheckMetadata: true, CheckIndex: true, CheckSignature: true}, passed(checks))
+	})
+
+	t.Run("without a registry", func(t *testing.T) {
+		in := input()
+		in.Registry = ""
+
+		// method under test
+		checks := Verify(in)
+
+		assert.Equal(t, map[string]bool{CheckMetadata: true}, passed(checks))
+	})
+}
+
+func TestSummary(t *testing.T) {
+	checks := []results.Verify{
+		{Check: CheckMetadata, Passed: true, Detail: "2 configuration definitions"},
+		{Check: CheckSignature, Passed: false, Detail: "no | signature"},
+	}
+
+	// method under test
+	summary := Summary("NRDotNetAgent", "1.2.3", checks)
+
+	assert.Contains(t, summary, "### Verification of NRDotNetAgent 1.2.3")
+	assert.Contains(t, summary, "**Failed** 1 of 2 checks.")
+	assert.Contains(t, summary, "| pass | `metadata` | 2 configuration definitions |")
+	assert.Contains(t, summary, "| fail | `signature` | no \\| signature |")
+}
@@ -0,0 +1,174 @@
+// Package agentmetadata submits agent metadata to the New Relic instrumentation metadata service and publishes and
+// signs agent binaries, for tools that need to do so without running the agent-metadata-action binary
+package agentmetadata
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"agent-metadata-action/internal/client"
+	"agent-metadata-action/internal/config"
+	"agent-metadata-action/internal/logging"
+	"agent-metadata-action/internal/models"
+	"agent-metadata-action/internal/oci"
+	"agent-metadata-action/internal/retry"
+	"agent-metadata-action/internal/sign"
+)
+
+// Service endpoints used when Config leaves them and the region empty
+const (
+	DefaultMetadataURL = config.MetadataURL
+	DefaultSigningURL  = config.SigningURL
+)
+
+// Regions accepted by Config.Region
+const (
+	RegionUS  = config.RegionUS
+	RegionEU  = config.RegionEU
+	RegionGov = config.RegionGov
+)
+
+// Payload versions accepted by Config.PayloadVersion
+const (
+	PayloadVersionAuto = client.PayloadVersionAuto
+	PayloadV1          = models.PayloadV1
+	PayloadV2          = models.PayloadV2
+	PayloadV3          = models.PayloadV3
+)
+
+// Config configures a Client
+type Config struct {
+	Token          string       // New Relic token used for the metadata and signing services
+	Region         string       // RegionUS (the default), RegionEU or RegionGov; selects the default service URLs
+	MetadataURL    string       // Defaults to the region's metadata service
+	SigningURL     string       // Defaults to the region's signing service
+	PayloadVersion string       // PayloadV1, PayloadV2, PayloadV3 or PayloadVersionAuto (the default)
+	Logger         *slog.Logger // Receives progress and diagnostic messages; nil discards them
+}
+
+// Client submits metadata and publishes artifacts
+type Client struct {
+	instrumentation *client.InstrumentationClient
+	signing         *sign.Client
+	logger          *slog.Logger
+}
+
+// NewClient creates a client from cfg
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("token is required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = RegionUS
+	}
+	regionURLs, err := config.RegionURLs(cfg.Region)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MetadataURL == "" {
+		cfg.MetadataURL = regionURLs.MetadataURL
+	}
+	if cfg.SigningURL == "" {
+		cfg.SigningURL = regionURLs.SigningURL
+	}
+	if cfg.PayloadVersion == "" {
+		cfg.PayloadVersion = PayloadVersionAuto
+	}
+	if cfg.PayloadVersion != PayloadVersionAuto && !models.IsPayloadVersion(cfg.PayloadVersion) {
+		return nil, fmt.Errorf("invalid payload version %q: must be %s or one of %v", cfg.PayloadVersion, PayloadVersionAuto, models.PayloadVersions)
+	}
+
+	instrumentation := client.NewInstrumentationClient(cfg.MetadataURL, cfg.Token)
+	instrumentation.SetPayloadVersion(cfg.PayloadVersion)
+
+	return &Client{
+		instrumentation: instrumentation,
+		signing:         sign.NewClient(cfg.SigningURL, cfg.Token),
+		logger:          cfg.Logger,
+	}, nil
+}
+
+// SubmitMetadata stores the metadata for an agent version, retrying transient failures
+func (c *Client) SubmitMetadata(ctx context.Context, agentType, version string, metadata *AgentMetadata) error {
+	return c.instrumentation.SendMetadata(c.context(ctx), agentType, version, toModel(metadata))
+}
+
+// GetMetadata returns the metadata stored for an agent version, or nil if there is none
+func (c *Client) GetMetadata(ctx context.Context, agentType, version string) (*AgentMetadata, error) {
+	metadata, err := c.instrumentation.GetMetadata(c.context(ctx), agentType, version)
+	if err != nil {
+		return nil, err
+	}
+	return fromModel(metadata), nil
+}
+
+// ListVersions returns the versions with stored metadata for an agent type
+func (c *Client) ListVersions(ctx context.Context, agentType string) ([]string, error) {
+	return c.instrumentation.ListVersions(c.context(ctx), agentType)
+}
+
+// ListAgentTypes returns the agent types registered with the instrumentation service
+func (c *Client) ListAgentTypes(ctx context.Context) ([]string, error) {
+	return c.instrumentation.ListAgentTypes(c.context(ctx))
+}
+
+// PublishArtifacts uploads the artifacts to the registry and tags a multi-platform index with version
+// Local artifact paths are resolved against workspace
+// Returns the digest of the index, which can be passed to SignIndex
+func (c *Client) PublishArtifacts(ctx context.Context, registry Registry, workspace, version string, artifacts []Artifact) (string, error) {
+	if registry.URL == "" {
+		return "", fmt.Errorf("registry URL is required")
+	}
+	ociConfig := toOCIConfig(registry, artifacts)
+	if err := ociConfig.Validate(); err != nil {
+		return "", err
+	}
+	return oci.HandleUploads(c.context(ctx), &ociConfig, workspace, version)
+}
+
+// SignIndex signs a manifest index published by PublishArtifacts, retrying transient failures
+// clientID identifies the caller to the signing service (the action uses the GitHub repository name)
+func (c *Client) SignIndex(ctx context.Context, registryURL, indexDigest, version, clientID string) error {
+	_, err := sign.SignIndexWithClient(c.context(ctx), c.signing, registryURL, indexDigest, version, clientID)
+	return err
+}
+
+// SignArtifact signs a single artifact, retrying transient failures
+func (c *Client) SignArtifact(ctx context.Context, clientID string, request SigningRequest) error {
+	ctx = c.context(ctx)
+	signingRequest := &models.SigningRequest{
+		Registry:   request.Registry,
+		Repository: request.Repository,
+		Tag:        request.Tag,
+		Digest:     request.Digest,
+	}
+	retryConfig := retry.Config{
+		MaxAttempts: 3,
+		BaseDelay:   2 * time.Second,
+		Operation:   "Signing",
+	}
+	return retry.Do(ctx, retryConfig, func() error {
+		return c.signing.SignArtifact(ctx, clientID, signingRequest)
+	})
+}
+
+// context routes log messages from the underlying packages to the configured logger instead of stdout
+func (c *Client) context(ctx context.Context) context.Context {
+	return logging.WithSink(ctx, func(level, message string) {
+		if c.logger == nil {
+			return
+		}
+		switch level {
+		case "debug", "group":
+			c.logger.DebugContext(ctx, message)
+		case "notice":
+			c.logger.InfoContext(ctx, message)
+		case "warn", "warning":
+			c.logger.WarnContext(ctx, message)
+		case "error":
+			c.logger.ErrorContext(ctx, message)
+		}
+	})
+}
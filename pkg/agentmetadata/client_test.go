@@ -0,0 +1,122 @@
+package agentmetadata
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+
+	"agent-metadata-action/internal/mockserver"
+	"agent-metadata-action/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, logger *slog.Logger) (*Client, *mockserver.Server) {
+	t.Helper()
+	server := mockserver.New("test-token")
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	c, err := NewClient(Config{Token: "test-token", MetadataURL: ts.URL, SigningURL: ts.URL, Logger: logger})
+	require.NoError(t, err)
+	return c, server
+}
+
+func TestClient_MetadataRoundTrip(t *testing.T) {
+	c, server := newTestClient(t, nil)
+	getStdout, _ := testutil.CaptureOutput(t)
+	ctx := context.Background()
+
+	metadata := &AgentMetadata{
+		ConfigurationDefinitions: []ConfigurationDefinition{{"platform": "ALL", "type": "agent-config", "version": "1.0.0"}},
+		Metadata:                 Metadata{"version": "1.2.3"},
+	}
+
+	// method under test
+	require.NoError(t, c.SubmitMetadata(ctx, "NRJavaAgent", "1.2.3", metadata))
+
+	got, err := c.GetMetadata(ctx, "NRJavaAgent", "1.2.3")
+	require.NoError(t, err)
+	assert.Equal(t, metadata.ConfigurationDefinitions, got.ConfigurationDefinitions)
+	assert.Equal(t, "1.2.3", got.Metadata["version"])
+
+	missing, err := c.GetMetadata(ctx, "NRJavaAgent", "9.9.9")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	versions, err := c.ListVersions(ctx, "NRJavaAgent")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.2.3"}, versions)
+
+	agentTypes, err := c.ListAgentTypes(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, agentTypes, "NRJavaAgent")
+
+	// The payload version is negotiated once, ahead of the first submission
+	requests := server.Requests()
+	require.Len(t, requests, 6)
+	assert.Equal(t, "/v1/capabilities", requests[0].Path)
+	assert.Empty(t, getStdout(), "the SDK must not print GitHub Actions workflow commands")
+}
+
+func TestClient_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	c, _ := newTestClient(t, slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	testutil.CaptureOutput(t)
+
+	// method under test
+	err := c.SubmitMetadata(context.Background(), "NRJavaAgent", "1.2.3", &AgentMetadata{Metadata: Metadata{"version": "1.2.3"}})
+	require.NoError(t, err)
+
+	assert.Contains(t, buf.String(), `level=INFO msg="Metadata successfully submitted to instrumentation service (ID: NRJavaAgent@1.2.3)"`)
+	assert.Contains(t, buf.String(), "level=DEBUG")
+	assert.NotContains(t, buf.String(), "::")
+}
+
+func TestClient_SignArtifact(t *testing.T) {
+	c, server := newTestClient(t, nil)
+	testutil.CaptureOutput(t)
+
+	request := SigningRequest{
+		Registry:   "docker.io",
+		Repository: "newrelic/agents",
+		Tag:        "1.2.3",
+		Digest:     "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+	}
+
+	// method under test
+	require.NoError(t, c.SignArtifact(context.Background(), "java-agent", request))
+	require.Len(t, server.Signed(), 1)
+
+	require.NoError(t, c.SignIndex(context.Background(), "docker.io/newrelic/agents", request.Digest, "1.2.3", "java-agent"))
+	signed := server.Signed()
+	require.Len(t, signed, 2)
+	assert.Equal(t, "newrelic/agents", signed[1].Repository)
+
+	request.Digest = "not-a-digest"
+	err := c.SignArtifact(context.Background(), "java-agent", request)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid signing request")
+}
+
+func TestNewClient_Errors(t *testing.T) {
+	_, err := NewClient(Config{})
+	assert.EqualError(t, err, "token is required")
+
+	_, err = NewClient(Config{Token: "token", Region: "apac"})
+	assert.EqualError(t, err, `invalid region "apac": must be one of [eu gov us]`)
+
+	_, err = NewClient(Config{Token: "token", PayloadVersion: "v9"})
+	assert.EqualError(t, err, `invalid payload version "v9": must be auto or one of [v1 v2 v3]`)
+
+	c, err := NewClient(Config{Token: "token"})
+	require.NoError(t, err)
+	_, err = c.PublishArtifacts(context.Background(), Registry{}, t.TempDir(), "1.2.3", nil)
+	assert.EqualError(t, err, "registry URL is required")
+
+	_, err = c.PublishArtifacts(context.Background(), Registry{URL: "localhost:5000/agents"}, t.TempDir(), "1.2.3", nil)
+	assert.EqualError(t, err, "binaries input is required when oci-registry is set")
+}
@@ -0,0 +1,109 @@
+package agentmetadata
+
+import (
+	"agent-metadata-action/internal/models"
+)
+
+// AgentMetadata is the metadata submitted for one agent version
+type AgentMetadata struct {
+	ConfigurationDefinitions []ConfigurationDefinition
+	Metadata                 Metadata
+	AgentControlDefinitions  []AgentControlDefinition
+	Bindings                 []any
+	BreakingChange           *string
+}
+
+// Metadata holds the version, release notes and other attributes of an agent version
+// Keys match the fields of the instrumentation service payload (e.g. "version", "features", "bugs")
+type Metadata map[string]any
+
+// ConfigurationDefinition describes an agent configuration schema
+// Keys match configurationDefinitions entries in .fleetControl/configurationDefinitions.yml
+type ConfigurationDefinition map[string]any
+
+// AgentControlDefinition describes how agent control deploys the agent on a platform
+// Keys match agentControlDefinitions entries in .fleetControl/agentControlDefinitions.yml
+type AgentControlDefinition map[string]any
+
+// Registry is the OCI repository artifacts are published to
+type Registry struct {
+	URL      string // e.g. docker.io/newrelic/agents
+	Username string
+	Password string
+}
+
+// Artifact is a binary to publish, given either as a file to upload or the digest of an already-pushed manifest
+type Artifact struct {
+	Name   string
+	Path   string // Relative to the workspace, or an s3://, gs:// or https:// URL
+	OS     string
+	Arch   string
+	Format string // tar, tar+gzip or zip
+	SHA256 string // Expected hex digest, verified before upload; required for https:// paths
+	Digest string // Digest of a manifest already in the registry, used instead of Path
+}
+
+// SigningRequest identifies an artifact in a registry to sign
+type SigningRequest struct {
+	Registry   string // Registry domain, e.g. docker.io
+	Repository string // Repository path, e.g. newrelic/agents
+	Tag        string
+	Digest     string // sha256:...
+}
+
+func toModel(m *AgentMetadata) *models.AgentMetadata {
+	if m == nil {
+		return nil
+	}
+	result := &models.AgentMetadata{
+		Metadata:       models.Metadata(m.Metadata),
+		Bindings:       m.Bindings,
+		BreakingChange: m.BreakingChange,
+	}
+	for _, definition := range m.ConfigurationDefinitions {
+		result.ConfigurationDefinitions = append(result.ConfigurationDefinitions, models.ConfigurationDefinition(definition))
+	}
+	for _, definition := range m.AgentControlDefinitions {
+		result.AgentControlDefinitions = append(result.AgentControlDefinitions, models.AgentControlDefinition(definition))
+	}
+	return result
+}
+
+func fromModel(m *models.AgentMetadata) *AgentMetadata {
+	if m == nil {
+		return nil
+	}
+	result := &AgentMetadata{
+		Metadata:       Metadata(m.Metadata),
+		Bindings:       m.Bindings,
+		BreakingChange: m.BreakingChange,
+	}
+	for _, definition := range m.ConfigurationDefinitions {
+		result.ConfigurationDefinitions = append(result.ConfigurationDefinitions, ConfigurationDefinition(definition))
+	}
+	for _, definition := range m.AgentControlDefinitions {
+		result.AgentControlDefinitions = append(result.AgentControlDefinitions, AgentControlDefinition(definition))
+	}
+	return result
+}
+
+func toOCIConfig(registry Registry, artifacts []Artifact) models.OCIConfig {
+	config := models.OCIConfig{
+		Registry:  registry.URL,
+		Username:  registry.Username,
+		Password:  registry.Password,
+		Artifacts: make([]models.ArtifactDefinition, 0, len(artifacts)),
+	}
+	for _, artifact := range artifacts {
+		config.Artifacts = append(config.Artifacts, models.ArtifactDefinition{
+			Name:   artifact.Name,
+			Path:   artifact.Path,
+			OS:     artifact.OS,
+			Arch:   artifact.Arch,
+			Format: artifact.Format,
+			SHA256: artifact.SHA256,
+			Digest: artifact.Digest,
+		})
+	}
+	return config
+}